@@ -2,21 +2,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/nshmdayo/distributed-cloud-storage/internal/api"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/blockchain"
 	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
 	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
 	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
-	configFile string
-	logLevel   string
+	configFile  string
+	logLevel    string
+	watchConfig bool
 )
 
 func main() {
@@ -27,8 +34,53 @@ func main() {
 		Run:   runAPIServer,
 	}
 
-	rootCmd.Flags().StringVarP(&configFile, "config", "c", "", "Config file path")
-	rootCmd.Flags().StringVarP(&logLevel, "log-level", "l", "info", "Log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Config file path")
+	rootCmd.Flags().StringVarP(&logLevel, "log-level", "l", "info", "Log level (debug, info, warn, error), overriding logging.level")
+	rootCmd.Flags().BoolVar(&watchConfig, "watch-config", false, "Reload log level, quota and scrub settings when the config file changes")
+
+	// Encrypt a config secret with the master key for use in the config file
+	var encryptSecretCmd = &cobra.Command{
+		Use:   "encrypt-secret [value]",
+		Short: "Encrypt a config value with the key in " + config.MasterKeyEnv,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := config.LoadMasterKey()
+			if err != nil {
+				return err
+			}
+			encrypted, err := config.EncryptSecret(args[0], key)
+			if err != nil {
+				return err
+			}
+			fmt.Println(encrypted)
+			return nil
+		},
+	}
+	// Move stored chunks into the configured storage directory layout
+	var migrateLayoutCmd = &cobra.Command{
+		Use:   "migrate-layout",
+		Short: "Move stored data to the configured path_depth and path_width",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadConfig(configFile)
+			if err != nil {
+				return err
+			}
+
+			logger, err := config.NewLogger(cfg.Logging)
+			if err != nil {
+				return err
+			}
+
+			layout := storage.Layout{Depth: cfg.Storage.PathDepth, Width: cfg.Storage.PathWidth}
+			moved, err := storage.MigrateLayout(cfg.Storage.Path, layout, logger)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Moved %d keys to %s\n", moved, layout)
+			return nil
+		},
+	}
+	rootCmd.AddCommand(encryptSecretCmd, migrateLayoutCmd, newConfigCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -36,24 +88,64 @@ func main() {
 	}
 }
 
-func runAPIServer(cmd *cobra.Command, args []string) {
-	// Setup logger
-	logger := logrus.New()
-	if level, err := logrus.ParseLevel(logLevel); err == nil {
-		logger.SetLevel(level)
+// newConfigCmd creates the config command for generating a config file
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the config file",
 	}
-	logger.SetFormatter(&logrus.JSONFormatter{})
 
+	var format string
+	var force bool
+	initCmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "Write a config file holding the default settings",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var path string
+			if len(args) == 1 {
+				path = args[0]
+			}
+			path, err := config.TemplatePath(path, format)
+			if err != nil {
+				return err
+			}
+			if err := config.WriteTemplate(path, force); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote default config to %s\n", path)
+			return nil
+		},
+	}
+	initCmd.Flags().StringVar(&format, "format", "", "Config format (yaml, toml, json), taken from the path when omitted")
+	initCmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing config file")
+
+	configCmd.AddCommand(initCmd)
+	return configCmd
+}
+
+func runAPIServer(cmd *cobra.Command, args []string) {
 	// Load configuration
 	cfg, err := config.LoadConfig(configFile)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// The --log-level flag overrides the configured level
+	if cmd.Flags().Changed("log-level") {
+		cfg.Logging.Level = logLevel
+	}
+
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Invalid config: %v", err)
 	}
 
+	// Setup logger
+	logger, err := config.NewLogger(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+
 	logger.WithFields(logrus.Fields{
 		"storage_dir": cfg.Storage.Path,
 		"api_port":    cfg.API.Port,
@@ -61,29 +153,122 @@ func runAPIServer(cmd *cobra.Command, args []string) {
 		"chunk_size":  cfg.Node.ChunkSize,
 	}).Info("Starting API server with configuration")
 
+	// Open metadata store
+	metadataStore, err := metadata.NewJSONStore(cfg.Storage.MetadataPath)
+	if err != nil {
+		log.Fatalf("Failed to open metadata store: %v", err)
+	}
+	defer metadataStore.Close()
+
 	// Initialize storage
-	fileStorage, err := storage.NewFileStorage(cfg.Storage.Path, logger)
+	fileStorage, err := storage.NewStorage(cfg.Storage, logger)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
-	// Generate or load encryption key
-	encKey, err := crypto.GenerateKey()
+	// Load the encryption key versions, generating the first key on first start
+	keys, generated, err := crypto.LoadKeyRing(cfg.Crypto.KeyFile)
 	if err != nil {
-		log.Fatalf("Failed to generate encryption key: %v", err)
+		log.Fatalf("Failed to load encryption key: %v", err)
+	}
+	if generated {
+		logger.WithField("key_file", cfg.Crypto.KeyFile).Info("Generated new encryption key")
 	}
+	if nonceMode, _ := crypto.ParseNonceMode(cfg.Crypto.NonceMode); nonceMode == crypto.NonceModeCounter { // Checked by Validate
+		if err := keys.EnableNonceCounters(cfg.Crypto.KeyFile); err != nil {
+			log.Fatalf("Failed to load nonce counters: %v", err)
+		}
+	}
+	_, encKey := keys.Current()
 
 	// Initialize chunk manager
 	chunkManager := storage.NewChunkManager(fileStorage, encKey, cfg.Node.ChunkSize, logger)
+	chunkManager.SetKeyRing(keys)
+	chunkManager.SetReplication(cfg.Node.ID, cfg.Node.Replicas)
+	chunkManager.SetMaxChunkSize(cfg.Node.MaxChunkSize)
+	chunkManager.EnableDeduplication(metadataStore)
+	chunkManager.SetDownloadWorkers(cfg.Storage.DownloadWorkers)
+	chunkManager.SetStoreWorkers(cfg.Storage.StoreWorkers)
+	hashAlgorithm, _ := types.ParseHashAlgorithm(cfg.Storage.HashAlgorithm) // Checked by Validate
+	chunkManager.SetHashAlgorithm(hashAlgorithm)
+	idScheme, _ := types.ParseIDScheme(cfg.Storage.IDScheme) // Checked by Validate
+	chunkManager.SetIDGenerator(types.NewIDGenerator(idScheme))
+	chunkCipher, _ := crypto.ParseCipher(cfg.Crypto.Algorithm) // Checked by Validate
+	chunkManager.SetCipher(chunkCipher)
+	if cfg.Storage.Compression {
+		if err := chunkManager.SetCompression(cfg.Storage.Codec); err != nil {
+			log.Fatalf("Failed to enable compression: %v", err)
+		}
+	}
+	if cfg.Storage.Redundancy == "erasure" {
+		if err := chunkManager.SetErasureCoding(cfg.Storage.DataShards, cfg.Storage.ParityShards); err != nil {
+			log.Fatalf("Failed to enable erasure coding: %v", err)
+		}
+	}
 
 	// Initialize API server
-	server := api.NewServer(fileStorage, chunkManager, logger)
+	server := api.NewServer(cfg, fileStorage, chunkManager, metadataStore, logger)
+
+	// Anchor uploads on-chain in the background
+	if cfg.Blockchain.AnchorUploads {
+		client, err := blockchain.NewRPCClient(cfg.Blockchain.RPCEndpoint)
+		if err != nil {
+			log.Fatalf("Failed to create blockchain client: %v", err)
+		}
+		anchorer := blockchain.NewAnchorer(client, cfg.Blockchain.ContractAddress, cfg.Blockchain.GasLimit, logger)
+		defer anchorer.Close()
+		server.SetAnchorer(anchorer)
+	}
+
+	// Apply safe config changes at runtime; the watcher gets its own copy as the server updates cfg
+	if watchConfig {
+		initial := *cfg
+		watcher := config.NewWatcher(configFile, &initial, logger)
+		watcher.OnChange(server.ApplyConfig)
+		if err := watcher.Start(); err != nil {
+			logger.WithError(err).Warn("Config watching disabled")
+		}
+	}
 
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.API.Host, cfg.API.Port)
-	logger.WithField("address", addr).Info("API server starting")
+	logger.WithFields(logrus.Fields{
+		"address": addr,
+		"tls":     cfg.API.TLS,
+	}).Info("API server starting")
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Start(addr)
+	}()
+
+	// Setup graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	if err := server.Start(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+		return
+	case <-sigChan:
+		logger.Info("Received shutdown signal, stopping API server...")
 	}
+
+	// Let in-flight requests finish within the grace period
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.API.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.WithError(err).Error("API server did not shut down cleanly")
+	}
+	if err := <-serverErr; err != nil {
+		logger.WithError(err).Error("API server stopped with error")
+	}
+
+	// No request can use the key any more
+	encKey.Zero()
+
+	logger.Info("API server stopped")
 }
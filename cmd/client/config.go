@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// clientEnvPrefix prefixes environment variables overriding client config values, e.g. DCS_CLIENT_SERVER
+const clientEnvPrefix = "DCS_CLIENT"
+
+// clientConfigDefaults are the built-in values used when no flag, env var or config file sets a key.
+// The key names match the command line flags they are bound to.
+var clientConfigDefaults = map[string]string{
+	"server": "http://localhost:8080",
+	"token":  "",
+	"output": outputTable,
+}
+
+// defaultClientConfigPath returns ~/.dcs/client.yaml
+func defaultClientConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".dcs", "client.yaml")
+	}
+	return filepath.Join(home, ".dcs", "client.yaml")
+}
+
+// loadClientConfig loads client settings into v with the precedence
+// flag > environment variable > config file > built-in default.
+// A missing config file is not an error.
+func loadClientConfig(v *viper.Viper, cmd *cobra.Command, path string) error {
+	for key, value := range clientConfigDefaults {
+		v.SetDefault(key, value)
+	}
+
+	v.SetEnvPrefix(clientEnvPrefix)
+	v.AutomaticEnv()
+
+	for key := range clientConfigDefaults {
+		if flag := cmd.Flags().Lookup(key); flag != nil {
+			if err := v.BindPFlag(key, flag); err != nil {
+				return fmt.Errorf("failed to bind flag %s: %w", key, err)
+			}
+		}
+	}
+
+	if err := readClientConfigFile(v, path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readClientConfigFile reads the config file at path into v, ignoring a missing file
+func readClientConfigFile(v *viper.Viper, path string) error {
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+
+	if err := v.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read client config %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyClientConfig copies the resolved settings into the command globals
+func applyClientConfig(v *viper.Viper) {
+	serverURL = strings.TrimSuffix(v.GetString("server"), "/")
+	authToken = v.GetString("token")
+	outputFormat = v.GetString("output")
+}
+
+// newConfigCmd creates the config command for reading and writing the client config file
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Read or write client settings",
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "Store a setting in the client config file",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := setClientConfig(configFile, args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Set %s in %s\n", args[0], configFile)
+			return nil
+		},
+	}
+
+	getCmd := &cobra.Command{
+		Use:   "get [key]",
+		Short: "Show the effective value of a setting, or of all settings",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keys := configKeys()
+			if len(args) == 1 {
+				if err := checkConfigKey(args[0]); err != nil {
+					return err
+				}
+				keys = args
+			}
+
+			for _, key := range keys {
+				if len(keys) == 1 {
+					fmt.Println(clientConfig.GetString(key))
+				} else {
+					fmt.Printf("%s: %s\n", key, clientConfig.GetString(key))
+				}
+			}
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(setCmd, getCmd)
+	return configCmd
+}
+
+// setClientConfig writes a single key to the config file at path, keeping other settings
+func setClientConfig(path, key, value string) error {
+	if err := checkConfigKey(key); err != nil {
+		return err
+	}
+	if key == "output" {
+		if err := checkOutputFormat(value); err != nil {
+			return err
+		}
+	}
+
+	v := viper.New()
+	if err := readClientConfigFile(v, path); err != nil {
+		return err
+	}
+	v.Set(key, value)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// The file can hold the auth token, so keep it private to the user
+	v.SetConfigPermissions(0600)
+	if err := v.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("failed to write client config: %w", err)
+	}
+	return nil
+}
+
+// checkConfigKey returns an error unless key is a known client setting
+func checkConfigKey(key string) error {
+	if _, ok := clientConfigDefaults[key]; !ok {
+		return fmt.Errorf("unknown config key: %s (expected one of %s)", key, strings.Join(configKeys(), ", "))
+	}
+	return nil
+}
+
+// configKeys returns the known client settings in sorted order
+func configKeys() []string {
+	keys := make([]string, 0, len(clientConfigDefaults))
+	for key := range clientConfigDefaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
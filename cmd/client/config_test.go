@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// newConfigTestCmd creates a command with the flags bound to client settings
+func newConfigTestCmd() *cobra.Command {
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().String("server", clientConfigDefaults["server"], "")
+	cmd.Flags().String("token", "", "")
+	cmd.Flags().String("output", outputTable, "")
+	return cmd
+}
+
+func TestClientConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      string
+		flag     string
+		expected string
+	}{
+		{"built-in default", "", "", "", "http://localhost:8080"},
+		{"file overrides default", "http://file:8080", "", "", "http://file:8080"},
+		{"env overrides file", "http://file:8080", "http://env:8080", "", "http://env:8080"},
+		{"flag overrides env", "http://file:8080", "http://env:8080", "http://flag:8080", "http://flag:8080"},
+		{"flag overrides file", "http://file:8080", "", "http://flag:8080", "http://flag:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "client.yaml")
+			if tt.file != "" {
+				if err := setClientConfig(path, "server", tt.file); err != nil {
+					t.Fatalf("Failed to write config: %v", err)
+				}
+			}
+
+			t.Setenv("DCS_CLIENT_SERVER", tt.env)
+			if tt.env == "" {
+				os.Unsetenv("DCS_CLIENT_SERVER")
+			}
+
+			cmd := newConfigTestCmd()
+			if tt.flag != "" {
+				if err := cmd.Flags().Set("server", tt.flag); err != nil {
+					t.Fatalf("Failed to set flag: %v", err)
+				}
+			}
+
+			v := viper.New()
+			if err := loadClientConfig(v, cmd, path); err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+
+			if got := v.GetString("server"); got != tt.expected {
+				t.Errorf("Expected server %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetClientConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".dcs", "client.yaml")
+
+	if err := setClientConfig(path, "server", "http://storage:9000"); err != nil {
+		t.Fatalf("Failed to set server: %v", err)
+	}
+	if err := setClientConfig(path, "token", "alice"); err != nil {
+		t.Fatalf("Failed to set token: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected config file to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected config file mode 0600, got %o", info.Mode().Perm())
+	}
+
+	v := viper.New()
+	if err := loadClientConfig(v, newConfigTestCmd(), path); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if v.GetString("server") != "http://storage:9000" {
+		t.Errorf("Expected earlier setting to be kept, got %s", v.GetString("server"))
+	}
+	if v.GetString("token") != "alice" {
+		t.Errorf("Expected token alice, got %s", v.GetString("token"))
+	}
+
+	if err := setClientConfig(path, "colour", "blue"); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+	if err := setClientConfig(path, "output", "yaml"); err == nil {
+		t.Error("Expected error for unsupported output format")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// apiError is the error object of an API error response
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// batchDeleteResult is the outcome of deleting one file in a batch
+type batchDeleteResult struct {
+	ID      string    `json:"id"`
+	Status  int       `json:"status"`
+	Message string    `json:"message"`
+	Error   *apiError `json:"error"`
+}
+
+// deleteTarget is a file about to be deleted, as described by the info endpoint
+type deleteTarget struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// runDelete deletes the files after confirming on in, unless assumeYes is set. With
+// dryRun it only reports what would be deleted. Output is written to w. It returns the
+// number of files that could not be deleted.
+func runDelete(in io.Reader, w io.Writer, ids []string, assumeYes, dryRun bool) (int, error) {
+	// Skip the lookups when nothing needs to be shown
+	if assumeYes && !dryRun {
+		return deleteAll(w, ids)
+	}
+
+	targets, err := fetchDeleteTargets(ids)
+	if err != nil {
+		return 0, err
+	}
+
+	if dryRun {
+		for _, target := range targets {
+			fmt.Fprintf(w, "Would delete %s: %s (%s)\n", target.ID, target.Name, utils.FormatBytes(target.Size))
+		}
+		return 0, nil
+	}
+
+	if !assumeYes {
+		confirmed, err := confirmDelete(in, w, targets)
+		if err != nil {
+			return 0, err
+		}
+		if !confirmed {
+			fmt.Fprintln(w, "Delete cancelled")
+			return 0, nil
+		}
+	}
+	return deleteAll(w, ids)
+}
+
+// fetchDeleteTargets looks up the name and size of every file. Nothing is deleted if
+// any lookup fails.
+func fetchDeleteTargets(ids []string) ([]deleteTarget, error) {
+	targets := make([]deleteTarget, 0, len(ids))
+	for _, id := range ids {
+		resp, err := apiClient().Get(serverURL + "/api/v1/files/" + id + "/info")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file info: %w", err)
+		}
+
+		var target deleteTarget
+		if resp.StatusCode != http.StatusOK {
+			var result map[string]interface{}
+			json.NewDecoder(resp.Body).Decode(&result)
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %v", id, errorMessage(result))
+		}
+		err = json.NewDecoder(resp.Body).Decode(&target)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file info: %w", err)
+		}
+
+		target.ID = id
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// confirmDelete lists the files and asks on in whether to delete them. Only an answer
+// of y or yes confirms.
+func confirmDelete(in io.Reader, w io.Writer, targets []deleteTarget) (bool, error) {
+	for _, target := range targets {
+		fmt.Fprintf(w, "%s: %s (%s)\n", target.ID, target.Name, utils.FormatBytes(target.Size))
+	}
+	if len(targets) == 1 {
+		fmt.Fprint(w, "Delete this file? [y/N]: ")
+	} else {
+		fmt.Fprintf(w, "Delete these %d files? [y/N]: ", len(targets))
+	}
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// deleteAll deletes a single file directly and several in one batch request
+func deleteAll(w io.Writer, ids []string) (int, error) {
+	if len(ids) > 1 {
+		return deleteFiles(w, ids)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, serverURL+"/api/v1/files/"+ids[0], nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(w, "Delete failed: %v\n", errorMessage(result))
+		return 1, nil
+	}
+	fmt.Fprintf(w, "File deleted successfully: %s\n", result["message"])
+	return 0, nil
+}
+
+// deleteFiles deletes the files in one batch request, writing a line per file to w.
+// It returns the number of files that could not be deleted.
+func deleteFiles(w io.Writer, ids []string) (int, error) {
+	body, err := json.Marshal(map[string][]string{"ids": ids})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, serverURL+"/api/v1/files/batch-delete", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete files: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Results []batchDeleteResult `json:"results"`
+		Failed  int                 `json:"failed"`
+		Error   *apiError           `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || result.Error != nil {
+		message := "unknown error"
+		if result.Error != nil {
+			message = result.Error.Message
+		}
+		return 0, fmt.Errorf("delete failed: %s", message)
+	}
+
+	for _, r := range result.Results {
+		if r.Error == nil {
+			fmt.Fprintf(w, "%s: %s\n", r.ID, r.Message)
+		} else {
+			fmt.Fprintf(w, "%s: delete failed: %s\n", r.ID, r.Error.Message)
+		}
+	}
+	return result.Failed, nil
+}
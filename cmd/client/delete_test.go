@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeleteFiles(t *testing.T) {
+	startTestServer(t)
+	dir := t.TempDir()
+
+	var ids []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		result, err := uploadOne(path, name, "", nil)
+		if err != nil {
+			t.Fatalf("Failed to upload %s: %v", name, err)
+		}
+		ids = append(ids, result["file_id"].(string))
+	}
+
+	var out bytes.Buffer
+	failed, err := deleteFiles(&out, []string{ids[0], "missing", ids[1]})
+	if err != nil {
+		t.Fatalf("Failed to delete files: %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("Expected 1 failure, got %d", failed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines of output, got %q", out.String())
+	}
+	if !strings.HasPrefix(lines[0], ids[0]+": File deleted") || !strings.HasPrefix(lines[2], ids[1]+": File deleted") {
+		t.Errorf("Expected both files to be deleted, got %q", out.String())
+	}
+	if lines[1] != "missing: delete failed: File not found" {
+		t.Errorf("Expected missing file to be reported, got %q", lines[1])
+	}
+}
+
+// uploadNamed uploads a file with the given content and returns its ID
+func uploadNamed(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	result, err := uploadOne(path, name, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to upload %s: %v", name, err)
+	}
+	return result["file_id"].(string)
+}
+
+func TestRunDeleteConfirmation(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		assumeYes bool
+		deleted   bool
+	}{
+		{"skipped with yes", "", true, true},
+		{"confirmed", "y\n", false, true},
+		{"confirmed in full", "YES\n", false, true},
+		{"declined", "n\n", false, false},
+		{"no answer", "", false, false},
+	}
+
+	startTestServer(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id := uploadNamed(t, "report.txt", "hello")
+
+			var out bytes.Buffer
+			failed, err := runDelete(strings.NewReader(test.input), &out, []string{id}, test.assumeYes, false)
+			if err != nil {
+				t.Fatalf("Failed to delete: %v", err)
+			}
+			if failed != 0 {
+				t.Errorf("Expected no failures, got %d", failed)
+			}
+
+			prompted := strings.Contains(out.String(), "[y/N]")
+			if prompted == test.assumeYes {
+				t.Errorf("Expected prompt %v, got output %q", !test.assumeYes, out.String())
+			}
+			if !test.assumeYes && !strings.Contains(out.String(), id+": report.txt (5 B)") {
+				t.Errorf("Expected prompt to show name and size, got %q", out.String())
+			}
+
+			_, err = fetchDeleteTargets([]string{id})
+			if deleted := err != nil; deleted != test.deleted {
+				t.Errorf("Expected deleted %v, got %v (output %q)", test.deleted, deleted, out.String())
+			}
+		})
+	}
+}
+
+func TestRunDeleteDryRun(t *testing.T) {
+	startTestServer(t)
+	ids := []string{uploadNamed(t, "a.txt", "aa"), uploadNamed(t, "b.txt", "bbb")}
+
+	var out bytes.Buffer
+	if _, err := runDelete(strings.NewReader(""), &out, ids, true, true); err != nil {
+		t.Fatalf("Failed dry run: %v", err)
+	}
+
+	expected := "Would delete " + ids[0] + ": a.txt (2 B)\nWould delete " + ids[1] + ": b.txt (3 B)\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	targets, err := fetchDeleteTargets(ids)
+	if err != nil {
+		t.Fatalf("Expected files to survive a dry run: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(targets))
+	}
+}
+
+func TestRunDeleteUnknownFile(t *testing.T) {
+	startTestServer(t)
+	id := uploadNamed(t, "keep.txt", "keep")
+
+	var out bytes.Buffer
+	if _, err := runDelete(strings.NewReader("y\n"), &out, []string{id, "missing"}, false, false); err == nil {
+		t.Error("Expected an error for an unknown file")
+	}
+	if _, err := fetchDeleteTargets([]string{id}); err != nil {
+		t.Errorf("Expected no file to be deleted when a lookup fails: %v", err)
+	}
+}
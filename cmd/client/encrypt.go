@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+)
+
+// readPassphrase reads a passphrase from a file, ignoring a trailing newline
+func readPassphrase(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+
+	passphrase := string(bytes.TrimRight(data, "\r\n"))
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
+
+// newClientKey derives a fresh key from the passphrase, returning it with its hex-encoded salt
+func newClientKey(passphrase string) (crypto.EncryptionKey, string, error) {
+	salt, err := crypto.GenerateSalt()
+	if err != nil {
+		return crypto.EncryptionKey{}, "", err
+	}
+	return crypto.DeriveKeyArgon2(passphrase, salt, crypto.DefaultKDFParams()), hex.EncodeToString(salt), nil
+}
+
+// clientKey re-derives the key of a client-encrypted file from the passphrase and stored salt
+func clientKey(passphrase, saltHex string) (crypto.EncryptionKey, error) {
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil || len(salt) != crypto.SaltSize {
+		return crypto.EncryptionKey{}, fmt.Errorf("invalid key salt: %s", saltHex)
+	}
+	return crypto.DeriveKeyArgon2(passphrase, salt, crypto.DefaultKDFParams()), nil
+}
+
+// encryptionInfo is the part of the file metadata describing client-side encryption
+type encryptionInfo struct {
+	ClientEncrypted bool   `json:"client_encrypted"`
+	KeySalt         string `json:"key_salt"`
+}
+
+// fetchEncryptionInfo reads whether a stored file was encrypted by the client
+func fetchEncryptionInfo(fileID string) (*encryptionInfo, error) {
+	resp, err := apiClient().Get(serverURL + "/api/v1/files/" + fileID + "/info")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return nil, fmt.Errorf("%v", errorMessage(result))
+	}
+
+	var info encryptionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to parse file info: %w", err)
+	}
+	return &info, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/api"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// startTestServer runs an API server for the duration of the test and points the client at it
+func startTestServer(t *testing.T) *storage.ChunkManager {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fileStorage, err := storage.NewFileStorage(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	chunkManager := storage.NewChunkManager(fileStorage, key, 4, logger)
+	server := api.NewServer(config.DefaultConfig(), fileStorage, chunkManager, metadata.NewMemoryStore(), logger)
+
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+
+	previous := serverURL
+	serverURL = ts.URL
+	t.Cleanup(func() { serverURL = previous })
+
+	return chunkManager
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	chunkManager := startTestServer(t)
+	dir := t.TempDir()
+
+	content := []byte(strings.Repeat("client-side secret ", 1000))
+	inputPath := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(inputPath, content, 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	result, err := uploadOne(inputPath, "secret.txt", "correct horse", nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	fileID, _ := result["file_id"].(string)
+
+	info, err := fetchEncryptionInfo(fileID)
+	if err != nil {
+		t.Fatalf("Failed to fetch file info: %v", err)
+	}
+	if !info.ClientEncrypted || info.KeySalt == "" {
+		t.Errorf("Expected client encryption marker and salt, got %+v", info)
+	}
+
+	// The server only ever sees ciphertext
+	resp, err := http.Get(serverURL + "/api/v1/files/" + fileID + "/info")
+	if err != nil {
+		t.Fatalf("Failed to fetch stored file: %v", err)
+	}
+	var fileInfo types.FileInfo
+	err = json.NewDecoder(resp.Body).Decode(&fileInfo)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to parse stored file: %v", err)
+	}
+	stored, err := chunkManager.RetrieveFile(context.Background(), &fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve stored data: %v", err)
+	}
+	if bytes.Contains(stored, []byte("client-side secret")) {
+		t.Error("Expected stored data to be encrypted")
+	}
+
+	outputPath := filepath.Join(dir, "out.txt")
+	if err := downloadOne(fileID, outputPath, "correct horse", false, nil); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	downloaded, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Errorf("Expected downloaded content to match original (%d bytes), got %d bytes", len(content), len(downloaded))
+	}
+
+	if err := downloadOne(fileID, filepath.Join(dir, "wrong.txt"), "wrong passphrase", false, nil); err == nil {
+		t.Error("Expected download with wrong passphrase to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wrong.txt")); !os.IsNotExist(err) {
+		t.Error("Expected failed download to remove its output file")
+	}
+
+	if err := downloadOne(fileID, filepath.Join(dir, "none.txt"), "", false, nil); err == nil {
+		t.Error("Expected download without passphrase to fail")
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		wantErr  bool
+	}{
+		{"trailing newline", "hunter2\n", "hunter2", false},
+		{"crlf", "hunter2\r\n", "hunter2", false},
+		{"inner spaces kept", " two words ", " two words ", false},
+		{"empty", "\n", "", true},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_"))
+		if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatalf("Failed to write passphrase file: %v", err)
+		}
+
+		passphrase, err := readPassphrase(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+		if passphrase != tt.expected {
+			t.Errorf("%s: expected passphrase %q, got %q", tt.name, tt.expected, passphrase)
+		}
+	}
+}
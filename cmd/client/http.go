@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// Backoff bounds between retried requests
+const (
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// retryClient wraps an http.Client, retrying idempotent requests that fail with a
+// connection error or a 5xx response using exponential backoff with jitter
+type retryClient struct {
+	client     *http.Client
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	token      string // Sent as X-Owner to identify the caller
+	sleep      func(time.Duration)
+}
+
+// newRetryClient creates a client making up to maxRetries retries per request.
+// A zero timeout means requests never time out.
+func newRetryClient(maxRetries int, timeout time.Duration) *retryClient {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &retryClient{
+		client:     &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		baseDelay:  retryBaseDelay,
+		maxDelay:   retryMaxDelay,
+		sleep:      time.Sleep,
+	}
+}
+
+// apiClient returns a client configured from the command line flags
+func apiClient() *retryClient {
+	client := newRetryClient(maxRetries, requestTimeout)
+	client.token = authToken
+	return client
+}
+
+// Get issues a GET request, retrying transient failures
+func (c *retryClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends the request. Only idempotent requests whose body can be replayed are
+// retried; 4xx responses are returned immediately.
+func (c *retryClient) Do(req *http.Request) (*http.Response, error) {
+	retries := c.maxRetries
+	if !isIdempotent(req.Method) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		retries = 0
+	}
+
+	if c.token != "" && req.Header.Get("X-Owner") == "" {
+		req.Header.Set("X-Owner", c.token)
+	}
+
+	// Retries keep the same request ID so server logs correlate every attempt
+	if req.Header.Get("X-Request-ID") == "" {
+		if id, err := utils.GenerateRandomID(32); err == nil {
+			req.Header.Set("X-Request-ID", id)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := c.client.Do(req)
+		if attempt >= retries || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		c.sleep(c.backoff(attempt))
+	}
+}
+
+// backoff returns the delay before the given retry: exponential in the attempt number,
+// capped at maxDelay, with jitter over the upper half to spread out concurrent clients
+func (c *retryClient) backoff(attempt int) time.Duration {
+	delay := c.maxDelay
+	if attempt < 30 && c.baseDelay<<uint(attempt) < c.maxDelay {
+		delay = c.baseDelay << uint(attempt)
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// shouldRetry reports whether a request failed in a way worth retrying
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// isIdempotent reports whether repeating a request with the method is safe
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// errorMessage extracts the message from a decoded API error response of the form
+// {"error": {"code": ..., "message": ...}}
+func errorMessage(result map[string]interface{}) string {
+	if apiErr, ok := result["error"].(map[string]interface{}); ok {
+		if message, ok := apiErr["message"].(string); ok {
+			return message
+		}
+	}
+	if result["error"] != nil {
+		return fmt.Sprint(result["error"])
+	}
+	return "unknown error"
+}
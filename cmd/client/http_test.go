@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer returns a server failing the first failures requests with status, then succeeding
+func newFlakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newTestRetryClient creates a retry client that records backoff delays instead of sleeping
+func newTestRetryClient(maxRetries int, delays *[]time.Duration) *retryClient {
+	client := newRetryClient(maxRetries, time.Second)
+	client.sleep = func(d time.Duration) { *delays = append(*delays, d) }
+	return client
+}
+
+func TestRetryClient(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		failures       int32
+		status         int
+		maxRetries     int
+		expectedStatus int
+		expectedCalls  int32
+	}{
+		{"succeeds after transient failures", http.MethodGet, 2, http.StatusServiceUnavailable, 3, http.StatusOK, 3},
+		{"gives up after max retries", http.MethodGet, 5, http.StatusInternalServerError, 2, http.StatusInternalServerError, 3},
+		{"client error is not retried", http.MethodGet, 1, http.StatusNotFound, 3, http.StatusNotFound, 1},
+		{"delete is retried", http.MethodDelete, 1, http.StatusBadGateway, 3, http.StatusOK, 2},
+		{"post is not retried", http.MethodPost, 1, http.StatusServiceUnavailable, 3, http.StatusServiceUnavailable, 1},
+		{"retries disabled", http.MethodGet, 1, http.StatusServiceUnavailable, 0, http.StatusServiceUnavailable, 1},
+	}
+
+	for _, tt := range tests {
+		server, calls := newFlakyServer(t, tt.failures, tt.status)
+
+		var delays []time.Duration
+		client := newTestRetryClient(tt.maxRetries, &delays)
+
+		req, err := http.NewRequest(tt.method, server.URL, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", tt.name, err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expectedStatus, resp.StatusCode)
+		}
+		if got := atomic.LoadInt32(calls); got != tt.expectedCalls {
+			t.Errorf("%s: expected %d calls, got %d", tt.name, tt.expectedCalls, got)
+		}
+		if len(delays) != int(tt.expectedCalls)-1 {
+			t.Errorf("%s: expected %d backoff delays, got %d", tt.name, tt.expectedCalls-1, len(delays))
+		}
+	}
+}
+
+func TestRetryClientReplaysBody(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("Expected body %q on every attempt, got %q", "payload", body)
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("payload"))
+	resp, err := newTestRetryClient(3, &delays).Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("Expected success on the second attempt, got status %d after %d calls", resp.StatusCode, calls)
+	}
+}
+
+func TestRetryClientConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	var delays []time.Duration
+	if _, err := newTestRetryClient(2, &delays).Get(url); err == nil {
+		t.Error("Expected connection error")
+	}
+	if len(delays) != 2 {
+		t.Errorf("Expected 2 retries on connection error, got %d", len(delays))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	client := newRetryClient(10, 0)
+
+	for attempt := 0; attempt < 10; attempt++ {
+		expected := retryBaseDelay << uint(attempt)
+		if expected > retryMaxDelay {
+			expected = retryMaxDelay
+		}
+
+		delay := client.backoff(attempt)
+		if delay < expected/2 || delay > expected {
+			t.Errorf("Attempt %d: expected delay in [%v, %v], got %v", attempt, expected/2, expected, delay)
+		}
+	}
+}
+
+func TestRetryClientRequestID(t *testing.T) {
+	var ids []string
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	var delays []time.Duration
+	client := newTestRetryClient(2, &delays)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(ids) != 2 || ids[0] == "" || ids[0] != ids[1] {
+		t.Errorf("Expected the same request ID on every attempt, got %v", ids)
+	}
+
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if ids[2] == ids[0] {
+		t.Errorf("Expected a new request ID for a new request, got %s twice", ids[2])
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// Output formats supported by the list command
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputWide  = "wide"
+)
+
+// listEntry is a file as returned by the list endpoint
+type listEntry struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+	Owner       string    `json:"owner"`
+	Version     int       `json:"version"`
+	Public      bool      `json:"public"`
+}
+
+// printFileList writes a list response body to w in the given output format
+func printFileList(w io.Writer, body []byte, format string) error {
+	if err := checkOutputFormat(format); err != nil {
+		return err
+	}
+
+	if format == outputJSON {
+		return printRawJSON(w, body)
+	}
+
+	var result struct {
+		Files []listEntry `json:"files"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if format == outputWide {
+		fmt.Fprintln(tw, "ID\tNAME\tSIZE\tVERSION\tCONTENT TYPE\tOWNER\tPUBLIC\tCREATED")
+	} else {
+		fmt.Fprintln(tw, "ID\tNAME\tSIZE\tCREATED")
+	}
+
+	for _, file := range result.Files {
+		created := file.CreatedAt.Local().Format("2006-01-02 15:04:05")
+		if format == outputWide {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%t\t%s\n",
+				file.ID, file.Name, utils.FormatBytes(file.Size), file.Version,
+				valueOrDash(file.ContentType), valueOrDash(file.Owner), file.Public, created)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", shortID(file.ID), file.Name, utils.FormatBytes(file.Size), created)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// printRawJSON prints a server response untouched so it can be piped into other tools
+func printRawJSON(w io.Writer, body []byte) error {
+	_, err := w.Write(body)
+	if err == nil && (len(body) == 0 || body[len(body)-1] != '\n') {
+		_, err = fmt.Fprintln(w)
+	}
+	return err
+}
+
+// checkOutputFormat returns an error unless format is a supported output format
+func checkOutputFormat(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputWide:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format: %s (expected %s, %s or %s)", format, outputTable, outputJSON, outputWide)
+}
+
+// shortID abbreviates a file ID for the table view
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// valueOrDash renders empty values as a dash so columns stay aligned
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const listResponse = `{"count":2,"files":[` +
+	`{"id":"0123456789abcdef0123","name":"report.pdf","size":1536,"content_type":"application/pdf","created_at":"2024-01-02T03:04:05Z","owner":"alice","version":2,"public":true},` +
+	`{"id":"fedcba9876543210fedc","name":"notes.txt","size":12,"content_type":"","created_at":"2024-01-03T03:04:05Z","owner":"","version":1,"public":false}]}`
+
+func TestPrintFileList(t *testing.T) {
+	tests := []struct {
+		format   string
+		header   []string
+		contains []string
+		excludes []string
+	}{
+		{
+			format:   outputTable,
+			header:   []string{"ID", "NAME", "SIZE", "CREATED"},
+			contains: []string{"0123456789ab", "report.pdf", "1.5 KB", "12 B"},
+			excludes: []string{"0123456789abcdef0123", "application/pdf", "alice"},
+		},
+		{
+			format:   outputWide,
+			header:   []string{"ID", "NAME", "SIZE", "VERSION", "CONTENT", "TYPE", "OWNER", "PUBLIC", "CREATED"},
+			contains: []string{"0123456789abcdef0123", "1.5 KB", "application/pdf", "alice", "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := printFileList(&out, []byte(listResponse), tt.format); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.format, err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("%s: expected header and 2 rows, got %d lines:\n%s", tt.format, len(lines), out.String())
+		}
+		if header := strings.Fields(lines[0]); strings.Join(header, " ") != strings.Join(tt.header, " ") {
+			t.Errorf("%s: expected header %v, got %v", tt.format, tt.header, header)
+		}
+
+		// Columns are aligned, so every row starts its second column at the same offset
+		nameColumn := strings.Index(lines[0], "NAME")
+		for _, line := range lines[1:] {
+			if line[nameColumn-1] != ' ' || line[nameColumn] == ' ' {
+				t.Errorf("%s: expected name column at offset %d, got %q", tt.format, nameColumn, line)
+			}
+		}
+
+		for _, want := range tt.contains {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s: expected output to contain %q, got:\n%s", tt.format, want, out.String())
+			}
+		}
+		for _, unwanted := range tt.excludes {
+			if strings.Contains(out.String(), unwanted) {
+				t.Errorf("%s: expected output not to contain %q, got:\n%s", tt.format, unwanted, out.String())
+			}
+		}
+	}
+}
+
+func TestPrintFileListJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := printFileList(&out, []byte(listResponse), outputJSON); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if out.String() != listResponse+"\n" {
+		t.Errorf("Expected raw server JSON, got %s", out.String())
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Errorf("Expected valid JSON, got error: %v", err)
+	}
+}
+
+func TestPrintFileListInvalidFormat(t *testing.T) {
+	var out bytes.Buffer
+	if err := printFileList(&out, []byte(listResponse), "yaml"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
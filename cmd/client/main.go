@@ -11,12 +11,36 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
-	serverURL string
+	serverURL   string
+	authToken   string
+	configFile  string
+	quiet       bool
+	recursive   bool
+	concurrency int
+	encrypt     bool
+	assumeYes   bool
+	dryRun      bool
+
+	resumeDownload bool
+
+	passphraseFile string
+	outputFormat   string
+	hashAlgorithm  string
+
+	maxRetries     int
+	requestTimeout time.Duration
+
+	// clientConfig holds the settings resolved from flags, env vars and the config file
+	clientConfig *viper.Viper
 )
 
 func main() {
@@ -26,15 +50,34 @@ func main() {
 		Long:  "Client CLI for the distributed cloud storage system",
 	}
 
-	rootCmd.PersistentFlags().StringVarP(&serverURL, "server", "s", "http://localhost:8080", "Server URL")
+	rootCmd.PersistentFlags().StringVarP(&serverURL, "server", "s", clientConfigDefaults["server"], "Server URL")
+	rootCmd.PersistentFlags().StringVar(&authToken, "token", "", "Token identifying the caller to the server")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", defaultClientConfigPath(), "Client config file")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress output")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 3, "Maximum retries for idempotent requests on connection errors or 5xx responses")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "timeout", 0, "Timeout for each HTTP request (0 disables)")
+
+	// Resolve settings from flags, env vars and the config file before any command runs
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		clientConfig = viper.New()
+		if err := loadClientConfig(clientConfig, cmd, configFile); err != nil {
+			return err
+		}
+		applyClientConfig(clientConfig)
+		return nil
+	}
 
 	// Upload command
 	var uploadCmd = &cobra.Command{
 		Use:   "upload [file]",
-		Short: "Upload a file",
+		Short: "Upload a file, or a directory with --recursive",
 		Args:  cobra.ExactArgs(1),
 		Run:   uploadFile,
 	}
+	uploadCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Upload a directory recursively, prefixing names with relative paths")
+	uploadCmd.Flags().IntVar(&concurrency, "concurrency", 4, "Maximum number of parallel uploads when uploading recursively")
+	uploadCmd.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt files locally before upload (requires --passphrase-file)")
+	uploadCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "File containing the encryption passphrase")
 
 	// Download command
 	var downloadCmd = &cobra.Command{
@@ -43,6 +86,8 @@ func main() {
 		Args:  cobra.ExactArgs(2),
 		Run:   downloadFile,
 	}
+	downloadCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "File containing the passphrase for client-encrypted files")
+	downloadCmd.Flags().BoolVar(&resumeDownload, "resume", false, "Continue a partially downloaded output file instead of starting over")
 
 	// List command
 	var listCmd = &cobra.Command{
@@ -50,14 +95,17 @@ func main() {
 		Short: "List all files",
 		Run:   listFiles,
 	}
+	listCmd.Flags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: table, json or wide")
 
 	// Delete command
 	var deleteCmd = &cobra.Command{
-		Use:   "delete [file-id]",
-		Short: "Delete a file",
-		Args:  cobra.ExactArgs(1),
+		Use:   "delete [file-id]...",
+		Short: "Delete one or more files",
+		Args:  cobra.MinimumNArgs(1),
 		Run:   deleteFile,
 	}
+	deleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Delete without asking for confirmation")
+	deleteCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be deleted without deleting anything")
 
 	// Info command
 	var infoCmd = &cobra.Command{
@@ -67,7 +115,16 @@ func main() {
 		Run:   getFileInfo,
 	}
 
-	rootCmd.AddCommand(uploadCmd, downloadCmd, listCmd, deleteCmd, infoCmd)
+	// Verify command
+	var verifyCmd = &cobra.Command{
+		Use:   "verify [file-id] [local-path]",
+		Short: "Check a local file against the server's hash",
+		Args:  cobra.ExactArgs(2),
+		Run:   verifyDownload,
+	}
+	verifyCmd.Flags().StringVar(&hashAlgorithm, "algo", "", "Hash algorithm: sha256, sha512 or blake2b-256 (default: the file's own)")
+
+	rootCmd.AddCommand(uploadCmd, downloadCmd, listCmd, deleteCmd, infoCmd, verifyCmd, newConfigCmd(), newNodeCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -78,10 +135,42 @@ func main() {
 func uploadFile(cmd *cobra.Command, args []string) {
 	filePath := args[0]
 
+	passphrase := ""
+	if encrypt {
+		if passphraseFile == "" {
+			log.Fatalf("--encrypt requires --passphrase-file")
+		}
+		var err error
+		if passphrase, err = readPassphrase(passphraseFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	if recursive {
+		uploadDirectory(filePath, passphrase)
+		return
+	}
+
+	result, err := uploadOne(filePath, filepath.Base(filePath), passphrase, progressOutput())
+	if err != nil {
+		log.Fatalf("Upload failed: %v", err)
+	}
+
+	fmt.Printf("File uploaded successfully!\n")
+	fmt.Printf("File ID: %s\n", result["file_id"])
+	fmt.Printf("File Name: %s\n", result["file_name"])
+	fmt.Printf("Size: %v bytes\n", result["size"])
+	fmt.Printf("Hash: %s\n", result["hash"])
+}
+
+// uploadOne uploads a single file under the given name and returns the server response.
+// A non-empty passphrase encrypts the file locally first. Progress is written to
+// progressOut unless it is nil.
+func uploadOne(filePath, name, passphrase string, progressOut io.Writer) (map[string]interface{}, error) {
 	// Open file
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
@@ -89,141 +178,255 @@ func uploadFile(cmd *cobra.Command, args []string) {
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 
+	// The name field keeps directory prefixes, which are stripped from the part filename
+	if err := writer.WriteField("name", name); err != nil {
+		return nil, fmt.Errorf("failed to write form field: %w", err)
+	}
+
+	// Mark client-encrypted files so downloads know to decrypt them
+	var key crypto.EncryptionKey
+	if passphrase != "" {
+		var salt string
+		if key, salt, err = newClientKey(passphrase); err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		writer.WriteField("client_encrypted", "true")
+		writer.WriteField("key_salt", salt)
+	}
+
 	// Add file
 	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
 	if err != nil {
-		log.Fatalf("Failed to create form file: %v", err)
+		return nil, fmt.Errorf("failed to create form file: %w", err)
 	}
 
-	if _, err := io.Copy(part, file); err != nil {
-		log.Fatalf("Failed to copy file: %v", err)
+	if passphrase != "" {
+		if err := crypto.EncryptStream(part, file, key); err != nil {
+			return nil, fmt.Errorf("failed to encrypt file: %w", err)
+		}
+	} else if _, err := io.Copy(part, file); err != nil {
+		return nil, fmt.Errorf("failed to copy file: %w", err)
 	}
 
 	writer.Close()
 
 	// Make request
-	req, err := http.NewRequest("POST", serverURL+"/api/v1/files", &body)
+	progress := newProgressReader(&body, int64(body.Len()), "Uploading "+name, progressOut)
+	req, err := http.NewRequest("POST", serverURL+"/api/v1/files", progress)
 	if err != nil {
-		log.Fatalf("Failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	req.ContentLength = int64(body.Len())
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := apiClient().Do(req)
 	if err != nil {
-		log.Fatalf("Failed to upload file: %v", err)
+		return nil, fmt.Errorf("failed to upload file: %w", err)
 	}
 	defer resp.Body.Close()
+	progress.Finish()
 
 	// Parse response
 	var result map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Fatalf("Failed to parse response: %v", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	if resp.StatusCode == http.StatusOK {
-		fmt.Printf("File uploaded successfully!\n")
-		fmt.Printf("File ID: %s\n", result["file_id"])
-		fmt.Printf("File Name: %s\n", result["file_name"])
-		fmt.Printf("Size: %v bytes\n", result["size"])
-		fmt.Printf("Hash: %s\n", result["hash"])
-	} else {
-		fmt.Printf("Upload failed: %v\n", result["error"])
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v", errorMessage(result))
 	}
+
+	return result, nil
 }
 
 func downloadFile(cmd *cobra.Command, args []string) {
 	fileID := args[0]
 	outputPath := args[1]
 
+	passphrase := ""
+	if passphraseFile != "" {
+		var err error
+		if passphrase, err = readPassphrase(passphraseFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	if err := downloadOne(fileID, outputPath, passphrase, resumeDownload, progressOutput()); err != nil {
+		log.Fatalf("Download failed: %v", err)
+	}
+
+	fmt.Printf("File downloaded successfully to: %s\n", outputPath)
+}
+
+// downloadOne downloads a file to outputPath, decrypting it with the passphrase if it was
+// encrypted by the client. With resume set, a partial output file left by an interrupted
+// download is continued from where it stopped. Progress is written to progressOut unless
+// it is nil.
+func downloadOne(fileID, outputPath, passphrase string, resume bool, progressOut io.Writer) error {
+	info, err := fetchEncryptionInfo(fileID)
+	if err != nil {
+		return err
+	}
+
+	var key crypto.EncryptionKey
+	if info.ClientEncrypted {
+		if passphrase == "" {
+			return fmt.Errorf("file is client-encrypted, --passphrase-file is required")
+		}
+		if key, err = clientKey(passphrase, info.KeySalt); err != nil {
+			return err
+		}
+	}
+
+	// Client-encrypted files decrypt as one stream, so only plain files can be resumed
+	resumable := !info.ClientEncrypted
+
+	req, err := http.NewRequest(http.MethodGet, serverURL+"/api/v1/files/"+fileID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Ask only for the missing tail, and only of the same content the partial file
+	// came from; the server sends the whole file instead if it has changed
+	var offset int64
+	if resume && resumable {
+		offset = partialDownload(outputPath)
+		if offset > 0 {
+			etag, _ := os.ReadFile(etagPath(outputPath))
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", string(etag))
+		}
+	}
+
 	// Make request
-	resp, err := http.Get(serverURL + "/api/v1/files/" + fileID)
+	resp, err := apiClient().Do(req)
 	if err != nil {
-		log.Fatalf("Failed to download file: %v", err)
+		return fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	var outFile *os.File
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		outFile, err = os.Create(outputPath)
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return fmt.Errorf("unexpected content range %q", resp.Header.Get("Content-Range"))
+		}
+		outFile, err = os.OpenFile(outputPath, os.O_WRONLY|os.O_APPEND, 0)
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 &&
+		resp.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset):
+		// The partial file already holds all of it
+		os.Remove(etagPath(outputPath))
+		return nil
+	default:
 		var result map[string]interface{}
 		json.NewDecoder(resp.Body).Decode(&result)
-		log.Fatalf("Download failed: %v", result["error"])
+		return fmt.Errorf("%v", errorMessage(result))
 	}
-
-	// Create output file
-	outFile, err := os.Create(outputPath)
 	if err != nil {
-		log.Fatalf("Failed to create output file: %v", err)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outFile.Close()
 
+	// Remember which content the output file holds so an interrupted download can resume
+	if etag := resp.Header.Get("ETag"); resumable && etag != "" {
+		if err := os.WriteFile(etagPath(outputPath), []byte(etag), 0644); err != nil {
+			return fmt.Errorf("failed to record download state: %w", err)
+		}
+	}
+
 	// Copy data
-	if _, err := io.Copy(outFile, resp.Body); err != nil {
-		log.Fatalf("Failed to write file: %v", err)
+	progress := newProgressReader(resp.Body, resp.ContentLength, "Downloading "+filepath.Base(outputPath), progressOut)
+	if info.ClientEncrypted {
+		err = crypto.DecryptStream(outFile, progress, key)
+	} else {
+		_, err = io.Copy(outFile, progress)
 	}
+	progress.Finish()
 
-	fmt.Printf("File downloaded successfully to: %s\n", outputPath)
+	if err != nil {
+		// Keep the partial file for --resume; anything else is not worth keeping
+		if !(resume && resumable) {
+			outFile.Close()
+			os.Remove(outputPath)
+			os.Remove(etagPath(outputPath))
+		}
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	os.Remove(etagPath(outputPath))
+	return nil
+}
+
+// partialDownload returns the size of a partial download at outputPath that can be
+// resumed, or 0 when there is none. Without the recorded ETag there is no telling what
+// the file holds, so it is downloaded again from the start.
+func partialDownload(outputPath string) int64 {
+	if _, err := os.Stat(etagPath(outputPath)); err != nil {
+		return 0
+	}
+	stat, err := os.Stat(outputPath)
+	if err != nil || !stat.Mode().IsRegular() {
+		return 0
+	}
+	return stat.Size()
+}
+
+// etagPath returns the file recording the ETag of a download in progress to outputPath
+func etagPath(outputPath string) string {
+	return outputPath + ".etag"
+}
+
+// progressOutput returns where progress is rendered, or nil when suppressed
+func progressOutput() io.Writer {
+	if quiet {
+		return nil
+	}
+	return os.Stderr
 }
 
 func listFiles(cmd *cobra.Command, args []string) {
 	// Make request
-	resp, err := http.Get(serverURL + "/api/v1/files")
+	resp, err := apiClient().Get(serverURL + "/api/v1/files")
 	if err != nil {
 		log.Fatalf("Failed to list files: %v", err)
 	}
 	defer resp.Body.Close()
 
-	// Parse response
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Fatalf("Failed to parse response: %v", err)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("Failed to read response: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("List failed: %v", result["error"])
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		log.Fatalf("List failed: %v", errorMessage(result))
 	}
 
-	files := result["files"].([]interface{})
-	fmt.Printf("Found %v files:\n\n", result["count"])
-
-	for _, fileInterface := range files {
-		file := fileInterface.(map[string]interface{})
-		fmt.Printf("ID: %s\n", file["id"])
-		fmt.Printf("Name: %s\n", file["name"])
-		fmt.Printf("Size: %v bytes\n", file["size"])
-		fmt.Printf("Content Type: %s\n", file["content_type"])
-		fmt.Printf("Created: %s\n", file["created_at"])
-		fmt.Printf("Owner: %s\n", file["owner"])
-		fmt.Println("---")
+	if err := printFileList(os.Stdout, body, outputFormat); err != nil {
+		log.Fatalf("%v", err)
 	}
 }
 
 func deleteFile(cmd *cobra.Command, args []string) {
-	fileID := args[0]
-
-	// Make request
-	req, err := http.NewRequest("DELETE", serverURL+"/api/v1/files/"+fileID, nil)
+	failed, err := runDelete(os.Stdin, os.Stdout, args, assumeYes, dryRun)
 	if err != nil {
-		log.Fatalf("Failed to create request: %v", err)
+		log.Fatalf("%v", err)
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Failed to delete file: %v", err)
+	if failed > 0 && len(args) > 1 {
+		log.Fatalf("Failed to delete %d of %d files", failed, len(args))
 	}
-	defer resp.Body.Close()
+}
 
-	// Parse response
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Fatalf("Failed to parse response: %v", err)
+func verifyDownload(cmd *cobra.Command, args []string) {
+	ok, err := verifyFile(os.Stdout, args[0], args[1], hashAlgorithm)
+	if err != nil {
+		log.Fatalf("Verify failed: %v", err)
 	}
-
-	if resp.StatusCode == http.StatusOK {
-		fmt.Printf("File deleted successfully: %s\n", result["message"])
-	} else {
-		fmt.Printf("Delete failed: %v\n", result["error"])
+	if !ok {
+		os.Exit(1)
 	}
 }
 
@@ -231,7 +434,7 @@ func getFileInfo(cmd *cobra.Command, args []string) {
 	fileID := args[0]
 
 	// Make request
-	resp, err := http.Get(serverURL + "/api/v1/files/" + fileID + "/info")
+	resp, err := apiClient().Get(serverURL + "/api/v1/files/" + fileID + "/info")
 	if err != nil {
 		log.Fatalf("Failed to get file info: %v", err)
 	}
@@ -244,7 +447,7 @@ func getFileInfo(cmd *cobra.Command, args []string) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Info failed: %v", result["error"])
+		log.Fatalf("Info failed: %v", errorMessage(result))
 	}
 
 	// Pretty print JSON
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// nodeStats is a response from the node stats endpoint
+type nodeStats struct {
+	StorageUsage  int64            `json:"storage_usage"`
+	FileCount     int              `json:"file_count"` // Objects in the storage backend
+	MetadataCount int              `json:"metadata_count"`
+	Uptime        time.Duration    `json:"uptime"`
+	Status        types.NodeStatus `json:"status"`
+}
+
+// newNodeCmd creates the node command for inspecting the server node
+func newNodeCmd() *cobra.Command {
+	nodeCmd := &cobra.Command{
+		Use:   "node",
+		Short: "Show node health and usage",
+	}
+
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "Show the node's identity, status and storage",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showNode(os.Stdout, "/api/v1/node/info", printNodeInfo)
+		},
+	}
+
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the node's usage, file count and uptime",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showNode(os.Stdout, "/api/v1/node/stats", printNodeStats)
+		},
+	}
+
+	for _, cmd := range []*cobra.Command{infoCmd, statsCmd} {
+		cmd.Flags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: table or json")
+	}
+
+	nodeCmd.AddCommand(infoCmd, statsCmd)
+	return nodeCmd
+}
+
+// showNode fetches a node endpoint and writes the response to w with printer
+func showNode(w io.Writer, path string, printer func(io.Writer, []byte, string) error) error {
+	if err := checkOutputFormat(outputFormat); err != nil {
+		return err
+	}
+
+	resp, err := apiClient().Get(serverURL + path)
+	if err != nil {
+		return fmt.Errorf("failed to reach node: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var result map[string]interface{}
+		json.Unmarshal(body, &result)
+		return fmt.Errorf("%v", errorMessage(result))
+	}
+
+	return printer(w, body, outputFormat)
+}
+
+// printNodeInfo writes a node info response body to w in the given output format
+func printNodeInfo(w io.Writer, body []byte, format string) error {
+	if format == outputJSON {
+		return printRawJSON(w, body)
+	}
+
+	var info types.NodeInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	storage := utils.FormatBytes(info.StorageUsed)
+	if info.StorageTotal > 0 {
+		storage = fmt.Sprintf("%s of %s (%.1f%%)", storage, utils.FormatBytes(info.StorageTotal),
+			float64(info.StorageUsed)/float64(info.StorageTotal)*100)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", valueOrDash(info.ID))
+	fmt.Fprintf(tw, "Address:\t%s:%d\n", info.Address, info.Port)
+	fmt.Fprintf(tw, "Status:\t%s\n", info.Status)
+	fmt.Fprintf(tw, "Storage used:\t%s\n", storage)
+	return tw.Flush()
+}
+
+// printNodeStats writes a node stats response body to w in the given output format
+func printNodeStats(w io.Writer, body []byte, format string) error {
+	if format == outputJSON {
+		return printRawJSON(w, body)
+	}
+
+	var stats nodeStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Status:\t%s\n", stats.Status)
+	fmt.Fprintf(tw, "Uptime:\t%s\n", stats.Uptime.Round(time.Second))
+	fmt.Fprintf(tw, "Storage used:\t%s\n", utils.FormatBytes(stats.StorageUsage))
+	fmt.Fprintf(tw, "Files:\t%d\n", stats.MetadataCount)
+	fmt.Fprintf(tw, "Stored objects:\t%d\n", stats.FileCount)
+	return tw.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	nodeInfoResponse = `{"id":"node-7","public_key":"","address":"10.0.0.7","port":8080,` +
+		`"storage_used":1572864,"storage_total":10485760,"status":0,"last_seen":"2024-01-02T03:04:05Z","reputation":1}`
+	nodeStatsResponse = `{"storage_usage":2048,"file_count":9,"metadata_count":3,"uptime":5430000000000,"status":1}`
+)
+
+// mockNode points the client at a server answering the node endpoints with canned responses
+func mockNode(t *testing.T) {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/node/info":
+			w.Write([]byte(nodeInfoResponse))
+		case "/api/v1/node/stats":
+			w.Write([]byte(nodeStatsResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	previous := serverURL
+	serverURL = ts.URL
+	t.Cleanup(func() { serverURL = previous })
+}
+
+func TestShowNode(t *testing.T) {
+	mockNode(t)
+
+	tests := []struct {
+		path     string
+		printer  func(io.Writer, []byte, string) error
+		expected []string
+	}{
+		{
+			path:    "/api/v1/node/info",
+			printer: printNodeInfo,
+			expected: []string{
+				"ID:            node-7",
+				"Address:       10.0.0.7:8080",
+				"Status:        online",
+				"Storage used:  1.5 MB of 10.0 MB (15.0%)",
+			},
+		},
+		{
+			path:    "/api/v1/node/stats",
+			printer: printNodeStats,
+			expected: []string{
+				"Status:          offline",
+				"Uptime:          1h30m30s",
+				"Storage used:    2.0 KB",
+				"Files:           3",
+				"Stored objects:  9",
+			},
+		},
+	}
+
+	previous := outputFormat
+	t.Cleanup(func() { outputFormat = previous })
+
+	for _, tt := range tests {
+		outputFormat = outputTable
+		var out bytes.Buffer
+		if err := showNode(&out, tt.path, tt.printer); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.path, err)
+		}
+		if got := strings.TrimSpace(out.String()); got != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tt.path, strings.Join(tt.expected, "\n"), got)
+		}
+	}
+
+	outputFormat = outputJSON
+	var out bytes.Buffer
+	if err := showNode(&out, "/api/v1/node/stats", printNodeStats); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != nodeStatsResponse+"\n" {
+		t.Errorf("Expected raw server JSON, got %s", out.String())
+	}
+
+	if err := showNode(&out, "/api/v1/node/missing", printNodeStats); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected the server error to be returned, got %v", err)
+	}
+}
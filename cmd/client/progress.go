@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// progressInterval limits how often the progress line is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// spinnerFrames are shown when the total size is unknown
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// progressReader wraps a reader, counting the bytes read and rendering a progress line
+type progressReader struct {
+	reader   io.Reader
+	total    int64 // Negative when unknown
+	read     int64
+	label    string
+	out      io.Writer // Nil disables rendering
+	lastDraw time.Time
+	frame    int
+}
+
+// newProgressReader creates a progress reader over r. total is the expected number of
+// bytes, or negative if unknown. Progress is written to out unless it is nil.
+func newProgressReader(r io.Reader, total int64, label string, out io.Writer) *progressReader {
+	return &progressReader{
+		reader: r,
+		total:  total,
+		label:  label,
+		out:    out,
+	}
+}
+
+// Read implements io.Reader
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.read += int64(n)
+
+	if time.Since(p.lastDraw) >= progressInterval {
+		p.render()
+	}
+	return n, err
+}
+
+// BytesRead returns the number of bytes read so far
+func (p *progressReader) BytesRead() int64 {
+	return p.read
+}
+
+// Finish draws the final progress line and ends it
+func (p *progressReader) Finish() {
+	if p.out == nil {
+		return
+	}
+	p.render()
+	fmt.Fprintln(p.out)
+}
+
+// render redraws the progress line in place
+func (p *progressReader) render() {
+	if p.out == nil {
+		return
+	}
+	p.lastDraw = time.Now()
+
+	if p.total >= 0 {
+		percent := 100
+		if p.total > 0 {
+			percent = int(p.read * 100 / p.total)
+		}
+		fmt.Fprintf(p.out, "\r%s %3d%% (%s / %s)", p.label, percent, utils.FormatBytes(p.read), utils.FormatBytes(p.total))
+		return
+	}
+
+	p.frame = (p.frame + 1) % len(spinnerFrames)
+	fmt.Fprintf(p.out, "\r%s %s %s", p.label, spinnerFrames[p.frame], utils.FormatBytes(p.read))
+}
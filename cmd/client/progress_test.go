@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProgressReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 2048)
+
+	tests := []struct {
+		name     string
+		total    int64
+		expected string
+	}{
+		{"known size", int64(len(data)), "Test 100% (2.0 KB / 2.0 KB)"},
+		{"unknown size", -1, " 2.0 KB"},
+		{"empty", 0, "Test 100% (0 B / 0 B)"},
+	}
+
+	for _, test := range tests {
+		input := data
+		if test.total == 0 {
+			input = nil
+		}
+
+		var out bytes.Buffer
+		progress := newProgressReader(bytes.NewReader(input), test.total, "Test", &out)
+
+		copied, err := io.Copy(io.Discard, progress)
+		if err != nil {
+			t.Fatalf("%s: copy failed: %v", test.name, err)
+		}
+		progress.Finish()
+
+		if progress.BytesRead() != copied || copied != int64(len(input)) {
+			t.Errorf("%s: expected %d bytes counted, got %d", test.name, len(input), progress.BytesRead())
+		}
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\r")
+		if last := lines[len(lines)-1]; !strings.HasPrefix(last, "Test ") || !strings.HasSuffix(last, test.expected) {
+			t.Errorf("%s: expected final line ending in %q, got %q", test.name, test.expected, last)
+		}
+	}
+}
+
+func TestProgressReaderQuiet(t *testing.T) {
+	progress := newProgressReader(strings.NewReader("quiet data"), 10, "Test", nil)
+
+	if _, err := io.Copy(io.Discard, progress); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	progress.Finish()
+
+	if progress.BytesRead() != 10 {
+		t.Errorf("Expected 10 bytes counted, got %d", progress.BytesRead())
+	}
+}
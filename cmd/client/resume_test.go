@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// plainTransport fetches responses without compression
+var plainTransport = &http.Transport{DisableCompression: true}
+
+// flakyProxy forwards requests to the test server, recording the Range headers it sees.
+// While cutAfter is positive, downloads are cut off after that many bytes.
+type flakyProxy struct {
+	target   string
+	cutAfter int64
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (p *flakyProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, _ := http.NewRequest(r.Method, p.target+r.URL.RequestURI(), r.Body)
+	req.Header = r.Header.Clone()
+	req.Header.Del("Accept-Encoding") // Cut off the plain content, not a compressed copy of it
+	resp, err := plainTransport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	download := strings.HasPrefix(r.URL.Path, "/api/v1/files/") && !strings.HasSuffix(r.URL.Path, "/info")
+	p.mu.Lock()
+	if download {
+		p.ranges = append(p.ranges, r.Header.Get("Range"))
+	}
+	cutAfter := p.cutAfter
+	p.mu.Unlock()
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if !download || cutAfter <= 0 {
+		io.Copy(w, resp.Body)
+		return
+	}
+	io.CopyN(w, resp.Body, cutAfter)
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+// startFlakyProxy points the client at a proxy in front of the test server
+func startFlakyProxy(t *testing.T) *flakyProxy {
+	t.Helper()
+
+	proxy := &flakyProxy{target: serverURL}
+	ts := httptest.NewServer(proxy)
+	t.Cleanup(ts.Close)
+
+	previous := serverURL
+	serverURL = ts.URL
+	t.Cleanup(func() { serverURL = previous })
+
+	return proxy
+}
+
+func TestResumeInterruptedDownload(t *testing.T) {
+	startTestServer(t)
+	dir := t.TempDir()
+
+	content := []byte(strings.Repeat("resumable download ", 500))
+	inputPath := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(inputPath, content, 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	result, err := uploadOne(inputPath, "big.txt", "", nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	fileID, _ := result["file_id"].(string)
+
+	proxy := startFlakyProxy(t)
+	proxy.cutAfter = 4000
+	outputPath := filepath.Join(dir, "out.txt")
+
+	if err := downloadOne(fileID, outputPath, "", true, nil); err == nil {
+		t.Fatal("Expected the interrupted download to fail")
+	}
+	partial, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Expected the partial file to be kept: %v", err)
+	}
+	if len(partial) != 4000 || !bytes.Equal(partial, content[:4000]) {
+		t.Fatalf("Expected the first 4000 bytes to be kept, got %d bytes", len(partial))
+	}
+	if _, err := os.Stat(etagPath(outputPath)); err != nil {
+		t.Errorf("Expected the ETag to be recorded: %v", err)
+	}
+
+	proxy.cutAfter = 0
+	if err := downloadOne(fileID, outputPath, "", true, nil); err != nil {
+		t.Fatalf("Resumed download failed: %v", err)
+	}
+
+	downloaded, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Errorf("Expected the resumed file to match the original (%d bytes), got %d bytes", len(content), len(downloaded))
+	}
+	if _, err := os.Stat(etagPath(outputPath)); !os.IsNotExist(err) {
+		t.Error("Expected the recorded ETag to be removed once complete")
+	}
+	if got := strings.Join(proxy.ranges, ","); got != ",bytes=4000-" {
+		t.Errorf("Expected the second request to ask for the rest, got ranges %q", got)
+	}
+}
+
+func TestResumeChangedDownload(t *testing.T) {
+	startTestServer(t)
+	dir := t.TempDir()
+
+	content := []byte("the current content of the file")
+	inputPath := filepath.Join(dir, "changed.txt")
+	if err := os.WriteFile(inputPath, content, 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	result, err := uploadOne(inputPath, "changed.txt", "", nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	fileID, _ := result["file_id"].(string)
+
+	tests := []struct {
+		name    string
+		partial string
+		etag    string // Recorded ETag, or none when empty
+		resume  bool
+	}{
+		{"changed on the server", "an older vers", `"stale"`, true},
+		{"no recorded ETag", "the current", "", true},
+		{"resume not requested", "the current", "", false},
+	}
+
+	for _, tt := range tests {
+		outputPath := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-"))
+		if err := os.WriteFile(outputPath, []byte(tt.partial), 0644); err != nil {
+			t.Fatalf("%s: Failed to write partial file: %v", tt.name, err)
+		}
+		if tt.etag != "" {
+			if err := os.WriteFile(etagPath(outputPath), []byte(tt.etag), 0644); err != nil {
+				t.Fatalf("%s: Failed to write ETag: %v", tt.name, err)
+			}
+		}
+
+		if err := downloadOne(fileID, outputPath, "", tt.resume, nil); err != nil {
+			t.Fatalf("%s: Download failed: %v", tt.name, err)
+		}
+		downloaded, _ := os.ReadFile(outputPath)
+		if !bytes.Equal(downloaded, content) {
+			t.Errorf("%s: Expected a fresh copy %q, got %q", tt.name, content, downloaded)
+		}
+	}
+}
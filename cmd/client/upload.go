@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// uploadTarget is a local file and the name it is stored under
+type uploadTarget struct {
+	Path string
+	Name string
+}
+
+// uploadResult is the outcome of uploading a single target
+type uploadResult struct {
+	Target uploadTarget
+	FileID string
+	Err    error
+}
+
+// collectUploads walks root and returns every regular file in lexical order. Each file is
+// named by its path relative to root's parent, so the directory name becomes a prefix.
+func collectUploads(root string) ([]uploadTarget, error) {
+	root = filepath.Clean(root)
+	prefix := filepath.Base(root)
+
+	var targets []uploadTarget
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		targets = append(targets, uploadTarget{
+			Path: path,
+			Name: filepath.ToSlash(filepath.Join(prefix, rel)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	return targets, nil
+}
+
+// uploadAll uploads the targets with at most concurrency uploads in flight, continuing
+// past failures. Results are returned in target order.
+func uploadAll(targets []uploadTarget, concurrency int, upload func(uploadTarget) (string, error)) []uploadResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]uploadResult, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, target uploadTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fileID, err := upload(target)
+			results[i] = uploadResult{Target: target, FileID: fileID, Err: err}
+		}(i, target)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// uploadDirectory uploads every file below root and prints a summary. A non-empty
+// passphrase encrypts each file locally first.
+func uploadDirectory(root, passphrase string) {
+	targets, err := collectUploads(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	results := uploadAll(targets, concurrency, func(target uploadTarget) (string, error) {
+		// Parallel progress bars would interleave, so report each file once it finishes
+		result, err := uploadOne(target.Path, target.Name, passphrase, nil)
+		if err != nil {
+			return "", err
+		}
+		fileID, _ := result["file_id"].(string)
+		return fileID, nil
+	})
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("FAILED  %s: %v\n", result.Target.Name, result.Err)
+			continue
+		}
+		fmt.Printf("OK      %s (%s)\n", result.Target.Name, result.FileID)
+	}
+
+	fmt.Printf("\nUploaded %d of %d files, %d failed\n", len(results)-failed, len(results), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCollectUploads(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "mydir")
+	files := []string{
+		"a.txt",
+		filepath.Join("sub", "b.txt"),
+		filepath.Join("sub", "deeper", "c.txt"),
+	}
+	for _, name := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	targets, err := collectUploads(root + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("Failed to collect uploads: %v", err)
+	}
+
+	expected := []string{"mydir/a.txt", "mydir/sub/b.txt", "mydir/sub/deeper/c.txt"}
+	if len(targets) != len(expected) {
+		t.Fatalf("Expected %d targets, got %d", len(expected), len(targets))
+	}
+	for i, target := range targets {
+		if target.Name != expected[i] {
+			t.Errorf("Target %d: expected name %s, got %s", i, expected[i], target.Name)
+		}
+		if target.Path != filepath.Join(root, files[i]) {
+			t.Errorf("Target %d: expected path %s, got %s", i, filepath.Join(root, files[i]), target.Path)
+		}
+	}
+
+	if _, err := collectUploads(filepath.Join(root, "missing")); err == nil {
+		t.Errorf("Expected error for missing directory")
+	}
+}
+
+func TestUploadAll(t *testing.T) {
+	var targets []uploadTarget
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		targets = append(targets, uploadTarget{Path: name, Name: name})
+	}
+
+	var inFlight, maxInFlight int32
+	results := uploadAll(targets, 2, func(target uploadTarget) (string, error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if target.Name == "c" {
+			return "", errors.New("upload failed")
+		}
+		return "id-" + target.Name, nil
+	})
+
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 uploads in flight, got %d", maxInFlight)
+	}
+
+	// Failures do not stop the remaining uploads
+	for i, result := range results {
+		if result.Target != targets[i] {
+			t.Errorf("Result %d: expected target %s, got %s", i, targets[i].Name, result.Target.Name)
+		}
+		if result.Target.Name == "c" {
+			if result.Err == nil {
+				t.Errorf("Expected failure for c")
+			}
+			continue
+		}
+		if result.Err != nil || result.FileID != "id-"+result.Target.Name {
+			t.Errorf("Result %d: unexpected result %+v", i, result)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// verifyInfo is the part of the file metadata needed to verify a local copy
+type verifyInfo struct {
+	Hash            string              `json:"hash"`
+	HashAlgorithm   types.HashAlgorithm `json:"hash_algorithm"`
+	ClientEncrypted bool                `json:"client_encrypted"`
+}
+
+// verifyFile compares the hash of the local file at path with the hash the server
+// holds for fileID, writing OK or the mismatching hashes to w. An empty algo uses
+// the algorithm the file was stored with. It reports whether the hashes match.
+func verifyFile(w io.Writer, fileID, path, algo string) (bool, error) {
+	var requested types.HashAlgorithm
+	if algo != "" {
+		var err error
+		if requested, err = types.ParseHashAlgorithm(algo); err != nil {
+			return false, err
+		}
+	}
+
+	resp, err := apiClient().Get(serverURL + "/api/v1/files/" + fileID + "/info")
+	if err != nil {
+		return false, fmt.Errorf("failed to get file info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return false, fmt.Errorf("%v", errorMessage(result))
+	}
+
+	var info verifyInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return false, fmt.Errorf("failed to parse file info: %w", err)
+	}
+
+	// The server only ever sees the ciphertext of client-encrypted files
+	if info.ClientEncrypted {
+		return false, fmt.Errorf("file is client-encrypted, the server hash does not cover the decrypted content")
+	}
+
+	algorithm := info.HashAlgorithm
+	if algorithm == "" {
+		algorithm = types.DefaultHashAlgorithm
+	}
+	expected := info.Hash
+	if requested != "" && requested != algorithm {
+		algorithm = requested
+		if expected, err = fetchChecksum(fileID, algorithm); err != nil {
+			return false, err
+		}
+	}
+
+	actual, err := hashLocalFile(path, algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	if actual != expected {
+		fmt.Fprintf(w, "MISMATCH %s (%s)\n", path, algorithm)
+		fmt.Fprintf(w, "  server: %s\n", expected)
+		fmt.Fprintf(w, "  local:  %s\n", actual)
+		return false, nil
+	}
+	fmt.Fprintf(w, "OK %s (%s %s)\n", path, algorithm, actual)
+	return true, nil
+}
+
+// fetchChecksum asks the server for a file's checksum with the given algorithm
+func fetchChecksum(fileID string, algorithm types.HashAlgorithm) (string, error) {
+	resp, err := apiClient().Get(serverURL + "/api/v1/files/" + fileID + "/checksum?algo=" + url.QueryEscape(string(algorithm)))
+	if err != nil {
+		return "", fmt.Errorf("failed to get checksum: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse checksum: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%v", errorMessage(result))
+	}
+
+	// The server reports chunks it cannot read instead of a checksum
+	checksum, _ := result["checksum"].(string)
+	if checksum == "" {
+		return "", fmt.Errorf("server could not compute checksum: %v", result["error"])
+	}
+	return checksum, nil
+}
+
+// hashLocalFile streams the file at path through the algorithm
+func hashLocalFile(path string, algorithm types.HashAlgorithm) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hasher := algorithm.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyFile(t *testing.T) {
+	startTestServer(t)
+	dir := t.TempDir()
+
+	content := []byte("the quick brown fox")
+	path := filepath.Join(dir, "fox.txt")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	result, err := uploadOne(path, "fox.txt", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	fileID := result["file_id"].(string)
+
+	corrupted := filepath.Join(dir, "corrupted.txt")
+	if err := os.WriteFile(corrupted, []byte("the quick brown cat"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		algo   string
+		ok     bool
+		prefix string
+	}{
+		{"matching", path, "", true, "OK "},
+		{"matching other algorithm", path, "blake2b-256", true, "OK "},
+		{"corrupted", corrupted, "", false, "MISMATCH "},
+		{"corrupted other algorithm", corrupted, "sha512", false, "MISMATCH "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			ok, err := verifyFile(&out, fileID, test.path, test.algo)
+			if err != nil {
+				t.Fatalf("Failed to verify: %v", err)
+			}
+			if ok != test.ok {
+				t.Errorf("Expected ok %v, got %v", test.ok, ok)
+			}
+			if !strings.HasPrefix(out.String(), test.prefix) {
+				t.Errorf("Expected output starting with %q, got %q", test.prefix, out.String())
+			}
+			if !test.ok && strings.Count(out.String(), "server: ") != 1 {
+				t.Errorf("Expected both hashes in output, got %q", out.String())
+			}
+		})
+	}
+
+	if _, err := verifyFile(&bytes.Buffer{}, fileID, path, "md5"); err == nil {
+		t.Error("Expected an error for an unsupported algorithm")
+	}
+}
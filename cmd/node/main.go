@@ -2,15 +2,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
 	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/p2p"
 	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -28,8 +33,35 @@ func main() {
 		Run:   runStorageNode,
 	}
 
-	rootCmd.Flags().StringVarP(&configFile, "config", "c", "", "Config file path")
-	rootCmd.Flags().StringVarP(&logLevel, "log-level", "l", "info", "Log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Config file path")
+	rootCmd.Flags().StringVarP(&logLevel, "log-level", "l", "info", "Log level (debug, info, warn, error), overriding logging.level")
+
+	// Inspect and drive the running node through its control endpoint
+	var peersCmd = &cobra.Command{
+		Use:   "peers",
+		Short: "List the peers known to the running node",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, err := controlSource()
+			if err != nil {
+				return err
+			}
+			return runPeers(cmd.OutOrStdout(), source)
+		},
+	}
+	var announceCmd = &cobra.Command{
+		Use:   "announce",
+		Short: "Announce the running node to its bootstrap and connected peers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, err := controlSource()
+			if err != nil {
+				return err
+			}
+			return runAnnounce(cmd.OutOrStdout(), source)
+		},
+	}
+	rootCmd.AddCommand(peersCmd, announceCmd, newConfigCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -37,24 +69,76 @@ func main() {
 	}
 }
 
-func runStorageNode(cmd *cobra.Command, args []string) {
-	// Setup logger
-	logger := logrus.New()
-	if level, err := logrus.ParseLevel(logLevel); err == nil {
-		logger.SetLevel(level)
+// newConfigCmd creates the config command for generating a config file
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the config file",
 	}
-	logger.SetFormatter(&logrus.JSONFormatter{})
 
+	var format string
+	var force bool
+	initCmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "Write a config file holding the default settings",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var path string
+			if len(args) == 1 {
+				path = args[0]
+			}
+			path, err := config.TemplatePath(path, format)
+			if err != nil {
+				return err
+			}
+			if err := config.WriteTemplate(path, force); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote default config to %s\n", path)
+			return nil
+		},
+	}
+	initCmd.Flags().StringVar(&format, "format", "", "Config format (yaml, toml, json), taken from the path when omitted")
+	initCmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing config file")
+
+	configCmd.AddCommand(initCmd)
+	return configCmd
+}
+
+// controlSource connects to the control endpoint of the node configured in the config file
+func controlSource() (peerSource, error) {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.P2P.ControlAddr == "" {
+		return nil, fmt.Errorf("the node control endpoint is disabled (p2p.control_addr is empty)")
+	}
+	return newControlClient(cfg.P2P.ControlAddr), nil
+}
+
+func runStorageNode(cmd *cobra.Command, args []string) {
 	// Load configuration
 	cfg, err := config.LoadConfig(configFile)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// The --log-level flag overrides the configured level
+	if cmd.Flags().Changed("log-level") {
+		cfg.Logging.Level = logLevel
+	}
+
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Invalid config: %v", err)
 	}
 
+	// Setup logger
+	logger, err := config.NewLogger(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+
 	logger.WithFields(logrus.Fields{
 		"storage_dir": cfg.Storage.Path,
 		"max_storage": cfg.Node.MaxStorage,
@@ -62,20 +146,58 @@ func runStorageNode(cmd *cobra.Command, args []string) {
 		"replicas":    cfg.Node.Replicas,
 	}).Info("Starting storage node with configuration")
 
+	// Open metadata store
+	metadataStore, err := metadata.NewJSONStore(cfg.Storage.MetadataPath)
+	if err != nil {
+		log.Fatalf("Failed to open metadata store: %v", err)
+	}
+	defer metadataStore.Close()
+
 	// Initialize storage
-	fileStorage, err := storage.NewFileStorage(cfg.Storage.Path, logger)
+	fileStorage, err := storage.NewStorage(cfg.Storage, logger)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
-	// Generate or load encryption key
-	encKey, err := crypto.GenerateKey()
+	// Load the encryption key versions, generating the first key on first start
+	keys, generated, err := crypto.LoadKeyRing(cfg.Crypto.KeyFile)
 	if err != nil {
-		log.Fatalf("Failed to generate encryption key: %v", err)
+		log.Fatalf("Failed to load encryption key: %v", err)
+	}
+	if generated {
+		logger.WithField("key_file", cfg.Crypto.KeyFile).Info("Generated new encryption key")
 	}
+	if nonceMode, _ := crypto.ParseNonceMode(cfg.Crypto.NonceMode); nonceMode == crypto.NonceModeCounter { // Checked by Validate
+		if err := keys.EnableNonceCounters(cfg.Crypto.KeyFile); err != nil {
+			log.Fatalf("Failed to load nonce counters: %v", err)
+		}
+	}
+	_, encKey := keys.Current()
 
 	// Initialize chunk manager
-	_ = storage.NewChunkManager(fileStorage, encKey, cfg.Node.ChunkSize, logger)
+	chunkManager := storage.NewChunkManager(fileStorage, encKey, cfg.Node.ChunkSize, logger)
+	chunkManager.SetKeyRing(keys)
+	chunkManager.SetReplication(cfg.Node.ID, cfg.Node.Replicas)
+	chunkManager.SetMaxChunkSize(cfg.Node.MaxChunkSize)
+	chunkManager.EnableDeduplication(metadataStore)
+	chunkManager.SetDownloadWorkers(cfg.Storage.DownloadWorkers)
+	chunkManager.SetStoreWorkers(cfg.Storage.StoreWorkers)
+	hashAlgorithm, _ := types.ParseHashAlgorithm(cfg.Storage.HashAlgorithm) // Checked by Validate
+	chunkManager.SetHashAlgorithm(hashAlgorithm)
+	idScheme, _ := types.ParseIDScheme(cfg.Storage.IDScheme) // Checked by Validate
+	chunkManager.SetIDGenerator(types.NewIDGenerator(idScheme))
+	chunkCipher, _ := crypto.ParseCipher(cfg.Crypto.Algorithm) // Checked by Validate
+	chunkManager.SetCipher(chunkCipher)
+	if cfg.Storage.Compression {
+		if err := chunkManager.SetCompression(cfg.Storage.Codec); err != nil {
+			log.Fatalf("Failed to enable compression: %v", err)
+		}
+	}
+	if cfg.Storage.Redundancy == "erasure" {
+		if err := chunkManager.SetErasureCoding(cfg.Storage.DataShards, cfg.Storage.ParityShards); err != nil {
+			log.Fatalf("Failed to enable erasure coding: %v", err)
+		}
+	}
 
 	logger.Info("Storage node initialized successfully")
 
@@ -83,8 +205,42 @@ func runStorageNode(cmd *cobra.Command, args []string) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start node services (P2P network, sync, etc.)
-	// This is where we would initialize P2P networking, blockchain connectivity, etc.
+	// Join the P2P network
+	host := p2p.NewHost(cfg.Node.ID, cfg.P2P, logger)
+
+	// Track peers and their reputation from announcements, heartbeats and chunk requests
+	registry := p2p.NewNodeRegistry(cfg.P2P.PeerTimeout, logger)
+	registry.Attach(host)
+	chunkManager.SetPeerTracker(registry)
+
+	// Serve locally stored chunks to peers and pull the ones missing here
+	chunkService := p2p.NewChunkService(host, fileStorage, logger)
+	chunkService.SetCircuitBreaker(p2p.NewCircuitBreaker(cfg.P2P.BreakerThreshold, cfg.P2P.BreakerCooldown, logger))
+	syncService := p2p.NewSyncService(chunkService, cfg.P2P.SyncFanout, logger)
+
+	if err := host.Start(); err != nil {
+		log.Fatalf("Failed to start P2P host: %v", err)
+	}
+
+	stop := make(chan struct{})
+	go host.Run(cfg.P2P.HeartbeatInterval, stop)
+
+	// Tell peers this node is alive and how much it stores
+	heartbeater := p2p.NewHeartbeater(host, fileStorage.GetUsage, cfg.Node.MaxStorage, logger)
+	go heartbeater.Run(cfg.P2P.HeartbeatInterval, stop)
+	go registry.Run(cfg.P2P.HeartbeatInterval, stop)
+	go syncService.Run(cfg.P2P.SyncInterval, stop)
+
+	// Serve node commands on the local control endpoint
+	var control *http.Server
+	if cfg.P2P.ControlAddr != "" {
+		control = &http.Server{Addr: cfg.P2P.ControlAddr, Handler: p2p.ControlHandler(host, registry)}
+		go func() {
+			if err := control.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.WithError(err).Error("Control endpoint stopped")
+			}
+		}()
+	}
 
 	logger.Info("Storage node started, waiting for shutdown signal...")
 
@@ -92,6 +248,17 @@ func runStorageNode(cmd *cobra.Command, args []string) {
 	<-sigChan
 	logger.Info("Received shutdown signal, stopping storage node...")
 
+	close(stop)
+	if control != nil {
+		control.Close()
+	}
+	if err := host.Close(); err != nil {
+		logger.WithError(err).Error("Failed to stop P2P host")
+	}
+
+	// No request can use the key any more
+	encKey.Zero()
+
 	// Cleanup and graceful shutdown
 	logger.Info("Storage node stopped")
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// peerSource is the registry of a running node
+type peerSource interface {
+	Peers() ([]types.NodeInfo, error)
+	Announce() (int, []types.NodeInfo, error)
+}
+
+// controlClient reaches a running node through its local control endpoint
+type controlClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// newControlClient creates a client for the control endpoint at addr
+func newControlClient(addr string) *controlClient {
+	return &controlClient{
+		baseURL: "http://" + addr,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Peers returns the peers in the node's registry
+func (c *controlClient) Peers() ([]types.NodeInfo, error) {
+	var result struct {
+		Peers []types.NodeInfo `json:"peers"`
+	}
+	if err := c.do(http.MethodGet, "/peers", &result); err != nil {
+		return nil, err
+	}
+	return result.Peers, nil
+}
+
+// Announce has the node announce itself, returning how many peers it reached and its registry
+func (c *controlClient) Announce() (int, []types.NodeInfo, error) {
+	var result struct {
+		Reached int              `json:"reached"`
+		Peers   []types.NodeInfo `json:"peers"`
+	}
+	if err := c.do(http.MethodPost, "/announce", &result); err != nil {
+		return 0, nil, err
+	}
+	return result.Reached, result.Peers, nil
+}
+
+// do sends a request to the control endpoint and decodes the response into result
+func (c *controlClient) do(method, path string, result interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach node (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("node returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// printPeers writes the peers as a table, showing how long ago each was last seen relative to now
+func printPeers(w io.Writer, peers []types.NodeInfo, now time.Time) error {
+	if len(peers) == 0 {
+		_, err := fmt.Fprintln(w, "No peers discovered")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tADDRESS\tSTATUS\tREPUTATION\tSTORAGE USED\tLAST SEEN")
+	for _, peer := range peers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%d\t%s\n",
+			peer.ID, valueOrDash(peer.Address), peer.Status, peer.Reputation, peer.StorageUsed, lastSeen(peer.LastSeen, now))
+	}
+	return tw.Flush()
+}
+
+// runPeers prints the peers known to the node
+func runPeers(w io.Writer, source peerSource) error {
+	peers, err := source.Peers()
+	if err != nil {
+		return err
+	}
+	return printPeers(w, peers, time.Now())
+}
+
+// runAnnounce has the node announce itself and prints the peers it knows afterwards
+func runAnnounce(w io.Writer, source peerSource) error {
+	reached, peers, err := source.Announce()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Announced to %d peer(s)\n", reached)
+	return printPeers(w, peers, time.Now())
+}
+
+// lastSeen formats how long before now a peer was last heard from
+func lastSeen(seen, now time.Time) string {
+	if seen.IsZero() {
+		return "never"
+	}
+	return now.Sub(seen).Round(time.Second).String() + " ago"
+}
+
+// valueOrDash renders empty values as a dash so columns stay aligned
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// fakeRegistry is a peerSource returning fixed peers
+type fakeRegistry struct {
+	peers   []types.NodeInfo
+	reached int
+	err     error
+}
+
+func (f *fakeRegistry) Peers() ([]types.NodeInfo, error) {
+	return f.peers, f.err
+}
+
+func (f *fakeRegistry) Announce() (int, []types.NodeInfo, error) {
+	return f.reached, f.peers, f.err
+}
+
+func testPeers(now time.Time) []types.NodeInfo {
+	return []types.NodeInfo{
+		{ID: "node-002", Address: "10.0.0.2:4001", Status: types.NodeStatusOnline, Reputation: 0.55, StorageUsed: 2048, LastSeen: now.Add(-3 * time.Second)},
+		{ID: "node-003", Status: types.NodeStatusOffline, Reputation: 0.4},
+	}
+}
+
+func TestPrintPeers(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var out bytes.Buffer
+	if err := printPeers(&out, testPeers(now), now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d lines:\n%s", len(lines), out.String())
+	}
+
+	tests := []struct {
+		line   string
+		fields []string
+	}{
+		{lines[0], []string{"ID", "ADDRESS", "STATUS", "REPUTATION", "STORAGE", "USED", "LAST", "SEEN"}},
+		{lines[1], []string{"node-002", "10.0.0.2:4001", types.NodeStatusOnline.String(), "0.55", "2048", "3s", "ago"}},
+		{lines[2], []string{"node-003", "-", types.NodeStatusOffline.String(), "0.40", "0", "never"}},
+	}
+
+	for _, tt := range tests {
+		if fields := strings.Fields(tt.line); strings.Join(fields, " ") != strings.Join(tt.fields, " ") {
+			t.Errorf("Expected row %v, got %v", tt.fields, fields)
+		}
+	}
+
+	// Columns are aligned, so every row starts its status column at the same offset
+	statusColumn := strings.Index(lines[0], "STATUS")
+	for _, line := range lines[1:] {
+		if line[statusColumn-1] != ' ' || line[statusColumn] == ' ' {
+			t.Errorf("Expected status column at offset %d, got %q", statusColumn, line)
+		}
+	}
+}
+
+func TestPrintPeersEmpty(t *testing.T) {
+	var out bytes.Buffer
+	if err := printPeers(&out, nil, time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "No peers discovered" {
+		t.Errorf("Expected no peers message, got %q", got)
+	}
+}
+
+func TestRunAnnounce(t *testing.T) {
+	source := &fakeRegistry{peers: testPeers(time.Now()), reached: 1}
+
+	var out bytes.Buffer
+	if err := runAnnounce(&out, source); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if lines[0] != "Announced to 1 peer(s)" {
+		t.Errorf("Expected announcement summary, got %q", lines[0])
+	}
+	if len(lines) != 4 {
+		t.Errorf("Expected summary, header and 2 rows, got %d lines:\n%s", len(lines), out.String())
+	}
+}
+
+func TestRunPeersError(t *testing.T) {
+	source := &fakeRegistry{err: errors.New("connection refused")}
+
+	var out bytes.Buffer
+	if err := runPeers(&out, source); err == nil {
+		t.Error("Expected error from unreachable node")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output on error, got %q", out.String())
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// appendFile handles appending the request body to the end of an existing file,
+// keeping its ID. Appends to the same file are applied one at a time, once downloads
+// already streaming it have finished.
+func (s *Server) appendFile(c *gin.Context) {
+	fileID := c.Param("id")
+
+	unlock := s.fileLocks.lock(fileID)
+	defer unlock()
+
+	fileInfo, exists := s.activeFile(fileID)
+	if !exists {
+		s.writeFileMissing(c, fileID)
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, false) {
+		return
+	}
+
+	if fileInfo.ClientEncrypted || fileInfo.Erasure != nil {
+		writeError(c, http.StatusConflict, "File cannot be appended to")
+		return
+	}
+
+	// The length is needed up front to check the size limits and reserve quota
+	size := c.Request.ContentLength
+	if size <= 0 {
+		writeError(c, http.StatusBadRequest, "Content-Length required")
+		return
+	}
+	if fileInfo.Size+size > s.config.Storage.MaxFileSize {
+		writeError(c, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+
+	if !s.reserveCapacity(c, size) {
+		return
+	}
+	defer s.releaseCapacity(size)
+
+	owner := fileInfo.Owner
+	if err := s.quotas.Reserve(owner, size); err != nil {
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			writeAPIError(c, http.StatusRequestEntityTooLarge, &APIError{Code: CodeQuotaExceeded, Message: "Storage quota exceeded"})
+			return
+		}
+		s.log(c).WithError(err).Error("Failed to reserve quota")
+		writeError(c, http.StatusInternalServerError, "Failed to append to file")
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, size)
+	updated, err := s.chunkManager.AppendFile(c.Request.Context(), fileInfo, body)
+	if err != nil {
+		s.releaseQuota(owner, size)
+		s.log(c).WithError(err).Error("Failed to append to file")
+		writeError(c, http.StatusInternalServerError, "Failed to append to file")
+		return
+	}
+	if appended := updated.Size - fileInfo.Size; appended < size {
+		s.releaseQuota(owner, size-appended)
+	}
+
+	// The file lock keeps it from being deleted meanwhile, so its entry is still there
+	s.updateFiles(func() {
+		s.files[fileID] = updated
+	})
+	s.anchorFile(updated)
+
+	s.log(c).WithFields(logrus.Fields{
+		"file_id":   fileInfo.ID,
+		"file_name": fileInfo.Name,
+		"appended":  updated.Size - fileInfo.Size,
+		"size":      updated.Size,
+	}).Info("Appended to file")
+
+	c.JSON(http.StatusOK, updated)
+}
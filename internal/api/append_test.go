@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// appendAs appends data to a file as the given owner
+func appendAs(server *Server, owner, fileID string, data []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/"+fileID+"/append", bytes.NewReader(data))
+	req.Header.Set("X-Owner", owner)
+	return serve(server, req)
+}
+
+func TestAppendFile(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "app.log", []byte("line 1\n"))
+	createdAt := server.files[fileID].CreatedAt
+
+	w := appendAs(server, "alice", fileID, []byte("line 2\n"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	fileInfo := server.files[fileID]
+	if fileInfo.Size != 14 {
+		t.Errorf("Expected size 14, got %d", fileInfo.Size)
+	}
+	if !fileInfo.UpdatedAt.After(createdAt) {
+		t.Errorf("Expected UpdatedAt to move past %v, got %v", createdAt, fileInfo.UpdatedAt)
+	}
+
+	w = getAs(server, "alice", "/api/v1/files/"+fileID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 downloading, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != "line 1\nline 2\n" {
+		t.Errorf("Expected appended content, got %q", got)
+	}
+
+	if usage, _ := server.quotas.Usage("alice"); usage != 14 {
+		t.Errorf("Expected quota usage 14, got %d", usage)
+	}
+}
+
+func TestAppendFileSerialized(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "app.log", []byte("start;"))
+
+	// Appends made one after the other land in order
+	for _, data := range []string{"first;", "second;"} {
+		if w := appendAs(server, "alice", fileID, []byte(data)); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 appending %q, got %d: %s", data, w.Code, w.Body.String())
+		}
+	}
+	if got := getAs(server, "alice", "/api/v1/files/"+fileID).Body.String(); got != "start;first;second;" {
+		t.Errorf("Expected %q, got %q", "start;first;second;", got)
+	}
+
+	// Concurrent appends are applied one at a time, so neither is lost or interleaved
+	var wg sync.WaitGroup
+	for _, data := range []string{"left;", "right;"} {
+		wg.Add(1)
+		go func(data string) {
+			defer wg.Done()
+			if w := appendAs(server, "alice", fileID, []byte(data)); w.Code != http.StatusOK {
+				t.Errorf("Expected status 200 appending %q, got %d", data, w.Code)
+			}
+		}(data)
+	}
+	wg.Wait()
+
+	got := getAs(server, "alice", "/api/v1/files/"+fileID).Body.String()
+	if got != "start;first;second;left;right;" && got != "start;first;second;right;left;" {
+		t.Errorf("Expected both concurrent appends in some order, got %q", got)
+	}
+}
+
+func TestAppendFileErrors(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "app.log", []byte("data"))
+
+	tests := []struct {
+		name       string
+		owner      string
+		fileID     string
+		data       []byte
+		wantStatus int
+	}{
+		{"missing file", "alice", "missing", []byte("more"), http.StatusNotFound},
+		{"other owner", "bob", fileID, []byte("more"), http.StatusForbidden},
+		{"empty body", "alice", fileID, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		if w := appendAs(server, tt.owner, tt.fileID, tt.data); w.Code != tt.wantStatus {
+			t.Errorf("%s: Expected status %d, got %d", tt.name, tt.wantStatus, w.Code)
+		}
+	}
+
+	if got := getAs(server, "alice", "/api/v1/files/"+fileID).Body.String(); got != "data" {
+		t.Errorf("Expected rejected appends to leave the file unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Supported formats of file archives
+const (
+	archiveFormatTarGz = "tar.gz"
+	archiveFormatZip   = "zip"
+)
+
+// archiveWriter adds files to an archive streamed into the response
+type archiveWriter interface {
+	// Add starts an entry of the given size and returns the writer for its content
+	Add(name string, size int64, modTime time.Time) (io.Writer, error)
+	Close() error
+}
+
+// tarGzArchive writes a gzip-compressed tar archive
+type tarGzArchive struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzArchive(w io.Writer) *tarGzArchive {
+	gz := gzip.NewWriter(w)
+	return &tarGzArchive{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+func (a *tarGzArchive) Add(name string, size int64, modTime time.Time) (io.Writer, error) {
+	header := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return a.tw, nil
+}
+
+func (a *tarGzArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// zipArchive writes a deflate-compressed zip archive
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) Add(name string, size int64, modTime time.Time) (io.Writer, error) {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	header.UncompressedSize64 = uint64(size)
+	return a.zw.CreateHeader(header)
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+// archiveEntryNames names each file's archive entry after the file, keeping only the base
+// name so entries cannot escape the extraction directory. Names shared by several files
+// are told apart by prefixing the file ID.
+func archiveEntryNames(files []*types.FileInfo) []string {
+	counts := make(map[string]int, len(files))
+	bases := make([]string, len(files))
+	for i, fileInfo := range files {
+		base := path.Base(strings.ReplaceAll(fileInfo.Name, "\\", "/"))
+		if base == "." || base == "/" || base == ".." {
+			base = fileInfo.ID
+		}
+		bases[i] = base
+		counts[base]++
+	}
+
+	names := make([]string, len(files))
+	for i, base := range bases {
+		if counts[base] > 1 {
+			base = files[i].ID + "-" + base
+		}
+		names[i] = base
+	}
+	return names
+}
+
+// archiveFiles resolves the files of an archive request, either the comma-separated ids,
+// each of which must be readable by the caller, or the caller's files matching the search
+// query q. It writes an error response and returns false when the request is invalid.
+func (s *Server) archiveFiles(c *gin.Context) ([]*types.FileInfo, bool) {
+	ids, q := c.Query("ids"), c.Query("q")
+	if (ids == "") == (q == "") {
+		writeError(c, http.StatusBadRequest, "Exactly one of ids or q is required")
+		return nil, false
+	}
+
+	var files []*types.FileInfo
+	if ids != "" {
+		seen := make(map[string]bool)
+		for _, id := range strings.Split(ids, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			fileInfo, exists := s.activeFile(id)
+			if !exists {
+				writeError(c, http.StatusNotFound, fmt.Sprintf("File not found: %s", id))
+				return nil, false
+			}
+			if !s.authorizeFile(c, fileInfo, true) {
+				return nil, false
+			}
+			files = append(files, fileInfo)
+		}
+	} else {
+		query, err := parseSearchQuery(q)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, "Invalid search query")
+			return nil, false
+		}
+
+		owner := s.principal(c)
+		s.eachFile(func(fileInfo *types.FileInfo) {
+			if fileInfo.Owner == owner && fileInfo.DeletedAt == nil && query.matches(fileInfo) {
+				files = append(files, fileInfo)
+			}
+		})
+		sort.Slice(files, func(i, j int) bool {
+			if files[i].Name != files[j].Name {
+				return files[i].Name < files[j].Name
+			}
+			return files[i].Version < files[j].Version
+		})
+	}
+
+	if len(files) == 0 {
+		writeError(c, http.StatusNotFound, "No files to archive")
+		return nil, false
+	}
+	if len(files) > maxBatchSize {
+		writeError(c, http.StatusRequestEntityTooLarge, "Too many files in archive")
+		return nil, false
+	}
+	return files, true
+}
+
+// downloadArchive handles streaming many files as one tar.gz or zip archive, each file
+// reassembled and decrypted into an entry named after it. Files are streamed one chunk at
+// a time, so memory use does not grow with the archive. A failure once the archive is
+// under way can only cut the response short.
+func (s *Server) downloadArchive(c *gin.Context) {
+	format := c.DefaultQuery("format", archiveFormatTarGz)
+	if format != archiveFormatTarGz && format != archiveFormatZip {
+		writeError(c, http.StatusBadRequest, "Unsupported archive format")
+		return
+	}
+
+	files, ok := s.archiveFiles(c)
+	if !ok {
+		return
+	}
+
+	var archive archiveWriter
+	if format == archiveFormatZip {
+		c.Header("Content-Type", "application/zip")
+		archive = &zipArchive{zw: zip.NewWriter(c.Writer)}
+	} else {
+		c.Header("Content-Type", "application/gzip")
+		archive = newTarGzArchive(c.Writer)
+	}
+	c.Header("Content-Disposition", "attachment; filename=files."+format)
+	c.Status(http.StatusOK)
+
+	var size int64
+	for i, name := range archiveEntryNames(files) {
+		fileInfo, unpin, exists := s.readFile(files[i])
+		if !exists {
+			s.log(c).WithField("file_id", files[i].ID).Error("File deleted while writing file archive")
+			c.Abort()
+			return
+		}
+		w, err := archive.Add(name, fileInfo.Size, fileInfo.UpdatedAt)
+		if err == nil {
+			err = s.chunkManager.RetrieveFileTo(c.Request.Context(), fileInfo, w)
+		}
+		unpin()
+		if err != nil {
+			s.log(c).WithError(err).WithField("file_id", fileInfo.ID).Error("Failed to write file archive")
+			c.Abort()
+			return
+		}
+		size += fileInfo.Size
+	}
+	if err := archive.Close(); err != nil {
+		s.log(c).WithError(err).Error("Failed to write file archive")
+		c.Abort()
+		return
+	}
+
+	s.log(c).WithFields(logrus.Fields{
+		"files":  len(files),
+		"size":   size,
+		"format": format,
+	}).Info("Served file archive")
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// readTarGz returns the entries of a tar.gz archive by name
+func readTarGz(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read gzip stream: %v", err)
+	}
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		entries[header.Name] = content
+	}
+	return entries
+}
+
+func TestDownloadArchive(t *testing.T) {
+	server := newTestServer(t)
+
+	first := []byte("first file content")
+	second := bytes.Repeat([]byte("second file spans several chunks "), 100)
+	firstID := uploadTestFile(t, server, "first.txt", first)
+	secondID := uploadTestFile(t, server, "second.txt", second)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/archive?ids="+firstID+","+secondID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("Expected a gzip archive, got %s", ct)
+	}
+
+	entries := readTarGz(t, w.Body.Bytes())
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if !bytes.Equal(entries["first.txt"], first) {
+		t.Errorf("Expected first.txt to hold %q, got %q", first, entries["first.txt"])
+	}
+	if !bytes.Equal(entries["second.txt"], second) {
+		t.Error("Expected second.txt to hold the uploaded content")
+	}
+
+	// The same files as a zip archive
+	w = serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/archive?format=zip&ids="+firstID+","+secondID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip archive: %v", err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != "first.txt" || zr.File[1].Name != "second.txt" {
+		t.Fatalf("Expected entries first.txt and second.txt, got %d entries", len(zr.File))
+	}
+	rc, _ := zr.File[1].Open()
+	content, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(content, second) {
+		t.Error("Expected zipped second.txt to hold the uploaded content")
+	}
+}
+
+func TestDownloadArchiveBySearch(t *testing.T) {
+	server := newTestServer(t)
+
+	uploadTestFile(t, server, "report-q1.txt", []byte("q1"))
+	uploadTestFile(t, server, "report-q2.txt", []byte("q2"))
+	uploadTestFile(t, server, "notes.txt", []byte("notes"))
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/archive?q=prefix:report-", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	entries := readTarGz(t, w.Body.Bytes())
+	if len(entries) != 2 || string(entries["report-q1.txt"]) != "q1" || string(entries["report-q2.txt"]) != "q2" {
+		t.Errorf("Expected entries report-q1.txt and report-q2.txt, got %v", entries)
+	}
+}
+
+func TestDownloadArchiveErrors(t *testing.T) {
+	server := newTestServer(t)
+
+	req := newUploadRequest(t, "private.txt", []byte("private"), nil)
+	req.Header.Set("X-Owner", "alice")
+	privateID := fileIDFromResponse(t, serve(server, req))
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"no selection", "", http.StatusBadRequest},
+		{"ids and query", "?ids=" + privateID + "&q=notes", http.StatusBadRequest},
+		{"bad format", "?format=rar&ids=" + privateID, http.StatusBadRequest},
+		{"unknown file", "?ids=missing", http.StatusNotFound},
+		{"other owner", "?ids=" + privateID, http.StatusForbidden},
+		{"no matches", "?q=prefix:nothing", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/archive"+test.query, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, w.Code)
+		}
+	}
+}
+
+func TestArchiveEntryNames(t *testing.T) {
+	files := []*types.FileInfo{
+		{ID: "a1", Name: "dir/same.txt"},
+		{ID: "b2", Name: "other\\same.txt"},
+		{ID: "c3", Name: "../escape.txt"},
+		{ID: "d4", Name: ".."},
+	}
+
+	names := archiveEntryNames(files)
+	want := []string{"a1-same.txt", "b2-same.txt", "escape.txt", "d4"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Expected entry %d to be named %s, got %s", i, want[i], names[i])
+		}
+	}
+}
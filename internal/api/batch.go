@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBatchSize is the largest number of file IDs accepted by a batch request
+const maxBatchSize = 1000
+
+// batchRequest is the body of the batch endpoints
+type batchRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// bindBatch parses a batch request, writing an error response if it is invalid
+func bindBatch(c *gin.Context) ([]string, bool) {
+	var req batchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.IDs) == 0 {
+		writeError(c, http.StatusBadRequest, "Invalid batch request")
+		return nil, false
+	}
+
+	if len(req.IDs) > maxBatchSize {
+		writeError(c, http.StatusRequestEntityTooLarge, "Too many files in batch")
+		return nil, false
+	}
+
+	return req.IDs, true
+}
+
+// batchDelete handles deleting many files in one request. Each ID is deleted
+// independently, so a failure does not abort the rest of the batch.
+func (s *Server) batchDelete(c *gin.Context) {
+	ids, ok := bindBatch(c)
+	if !ok {
+		return
+	}
+
+	permanent := c.Query("permanent") == "true"
+	allVersions := c.Query("all_versions") == "true"
+
+	results := make([]gin.H, 0, len(ids))
+	failed := 0
+	for _, id := range ids {
+		status, message := s.deleteOne(c, id, permanent, allVersions)
+		if status != http.StatusOK {
+			results = append(results, batchError(id, status, message))
+			failed++
+			continue
+		}
+		results = append(results, gin.H{"id": id, "status": status, "message": message})
+	}
+
+	s.log(c).WithFields(logrus.Fields{
+		"requested": len(ids),
+		"failed":    failed,
+	}).Info("Batch delete completed")
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"succeeded": len(ids) - failed,
+		"failed":    failed,
+	})
+}
+
+// batchInfo handles retrieving the metadata of many files in one request.
+// Missing or inaccessible files are reported per ID.
+func (s *Server) batchInfo(c *gin.Context) {
+	ids, ok := bindBatch(c)
+	if !ok {
+		return
+	}
+
+	owner := s.principal(c)
+	results := make([]gin.H, 0, len(ids))
+	found := 0
+	for _, id := range ids {
+		fileInfo, exists := s.activeFile(id)
+		switch {
+		case !exists:
+			results = append(results, batchError(id, http.StatusNotFound, "File not found"))
+		case !fileInfo.Public && fileInfo.Owner != owner:
+			results = append(results, batchError(id, http.StatusForbidden, "Access denied"))
+		default:
+			results = append(results, gin.H{"id": id, "status": http.StatusOK, "file": fileInfo})
+			found++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"found":   found,
+		"failed":  len(ids) - found,
+	})
+}
+
+// batchError builds the result entry for an ID that failed
+func batchError(id string, status int, message string) gin.H {
+	return gin.H{
+		"id":     id,
+		"status": status,
+		"error":  &APIError{Code: codeForStatus(status), Message: message},
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// batchResult is a single entry of a batch response
+type batchResult struct {
+	ID      string    `json:"id"`
+	Status  int       `json:"status"`
+	Message string    `json:"message"`
+	Error   *APIError `json:"error"`
+	File    *struct {
+		Name string `json:"name"`
+	} `json:"file"`
+}
+
+// postBatch sends a batch request as the given owner and returns the per-ID results
+func postBatch(t *testing.T, server *Server, owner, path, body string) []batchResult {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Owner", owner)
+	w := serve(server, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Batch request failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var result struct {
+		Results []batchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse batch response: %v", err)
+	}
+	return result.Results
+}
+
+func TestBatchDelete(t *testing.T) {
+	server := newTestServer(t)
+
+	a := uploadAs(t, server, "alice", "a.txt", []byte("a"))
+	b := uploadAs(t, server, "alice", "b.txt", []byte("b"))
+	bobs := uploadAs(t, server, "bob", "c.txt", []byte("c"))
+
+	body := `{"ids": ["` + a + `", "missing", "` + bobs + `", "` + b + `"]}`
+	results := postBatch(t, server, "alice", "/api/v1/files/batch-delete", body)
+
+	expected := []struct {
+		id     string
+		status int
+	}{
+		{a, http.StatusOK},
+		{"missing", http.StatusNotFound},
+		{bobs, http.StatusForbidden},
+		{b, http.StatusOK},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, want := range expected {
+		if results[i].ID != want.id || results[i].Status != want.status {
+			t.Errorf("Result %d: expected %s with status %d, got %s with status %d", i, want.id, want.status, results[i].ID, results[i].Status)
+		}
+	}
+
+	// Failures do not stop the rest of the batch
+	if _, exists := server.files[a]; exists {
+		t.Errorf("Expected %s to be deleted", a)
+	}
+	if _, exists := server.files[b]; exists {
+		t.Errorf("Expected %s to be deleted", b)
+	}
+	if _, exists := server.files[bobs]; !exists {
+		t.Errorf("Expected bob's file to be kept")
+	}
+}
+
+func TestBatchDeleteTrash(t *testing.T) {
+	server := newTrashTestServer(t)
+	fileID := uploadAs(t, server, "alice", "a.txt", []byte("a"))
+
+	results := postBatch(t, server, "alice", "/api/v1/files/batch-delete", `{"ids": ["`+fileID+`", "`+fileID+`"]}`)
+	if results[0].Status != http.StatusOK || results[0].Message != "File moved to trash" {
+		t.Errorf("Expected file to be trashed, got %+v", results[0])
+	}
+	if results[1].Status != http.StatusNotFound {
+		t.Errorf("Expected repeated ID to be reported missing, got %+v", results[1])
+	}
+	if server.files[fileID].DeletedAt == nil {
+		t.Errorf("Expected file to be in the trash")
+	}
+}
+
+func TestBatchInfo(t *testing.T) {
+	server := newTestServer(t)
+
+	a := uploadAs(t, server, "alice", "a.txt", []byte("a"))
+	private := uploadAs(t, server, "bob", "private.txt", []byte("p"))
+
+	req := newUploadRequest(t, "shared.txt", []byte("s"), map[string]string{"public": "true"})
+	req.Header.Set("X-Owner", "bob")
+	shared := fileIDFromResponse(t, serve(server, req))
+
+	body := `{"ids": ["` + a + `", "missing", "` + private + `", "` + shared + `"]}`
+	results := postBatch(t, server, "alice", "/api/v1/files/batch-info", body)
+
+	tests := []struct {
+		status int
+		name   string
+	}{
+		{http.StatusOK, "a.txt"},
+		{http.StatusNotFound, ""},
+		{http.StatusForbidden, ""},
+		{http.StatusOK, "shared.txt"},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("Expected %d results, got %d", len(tests), len(results))
+	}
+	for i, tt := range tests {
+		result := results[i]
+		if result.Status != tt.status {
+			t.Errorf("Result %d: expected status %d, got %d", i, tt.status, result.Status)
+		}
+		if tt.name == "" {
+			if result.File != nil || result.Error == nil || result.Error.Code == "" {
+				t.Errorf("Result %d: expected an error without metadata, got %+v", i, result)
+			}
+		} else if result.File == nil || result.File.Name != tt.name {
+			t.Errorf("Result %d: expected metadata for %s", i, tt.name)
+		}
+	}
+}
+
+func TestBatchInvalidRequest(t *testing.T) {
+	server := newTestServer(t)
+
+	ids := make([]string, maxBatchSize+1)
+	for i := range ids {
+		ids[i] = `"x"`
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"invalid json", `{"ids":`, http.StatusBadRequest},
+		{"no ids", `{"ids": []}`, http.StatusBadRequest},
+		{"too many", `{"ids": [` + strings.Join(ids, ",") + `]}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/api/v1/files/batch-delete", "/api/v1/files/batch-info"} {
+				req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+				if w := serve(server, req); w.Code != tt.expected {
+					t.Errorf("%s: expected status %d, got %d", path, tt.expected, w.Code)
+				}
+			}
+		})
+	}
+}
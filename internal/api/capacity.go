@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// capacityReservations counts the bytes promised to uploads that are not in storage yet,
+// so uploads checked at the same time cannot together overrun the node's storage limit
+type capacityReservations struct {
+	mu       sync.Mutex
+	reserved int64
+}
+
+// reserveCapacity reserves size bytes of the node's storage for an upload, rejecting it
+// with 507 when it would take the node's usage into the headroom below its storage limit
+// and writing the error response. Uploads are let through when usage cannot be measured.
+// The caller releases the reservation with releaseCapacity once the bytes are stored or
+// the upload has failed.
+func (s *Server) reserveCapacity(c *gin.Context, size int64) bool {
+	s.capacity.mu.Lock()
+	defer s.capacity.mu.Unlock()
+
+	limit := s.config.Node.MaxStorage
+	if limit <= 0 {
+		s.capacity.reserved += size
+		return true
+	}
+
+	// Usage is a counter kept by the storage backend, so it is cheap to read under the lock
+	usage, err := s.storage.GetUsage()
+	if err != nil {
+		s.log(c).WithError(err).Warn("Failed to get storage usage, skipping capacity check")
+		s.capacity.reserved += size
+		return true
+	}
+
+	available := limit - s.config.Node.StorageHeadroom - usage - s.capacity.reserved
+	if size <= available {
+		s.capacity.reserved += size
+		return true
+	}
+
+	if available < 0 {
+		available = 0
+	}
+	s.log(c).WithFields(logrus.Fields{
+		"size":      size,
+		"usage":     usage,
+		"reserved":  s.capacity.reserved,
+		"available": available,
+	}).Warn("Upload rejected, node storage is nearly full")
+	writeAPIError(c, http.StatusInsufficientStorage, &APIError{
+		Code:    CodeInsufficientStorage,
+		Message: "Insufficient storage on node",
+		Details: gin.H{"available": available},
+	})
+	return false
+}
+
+// releaseCapacity returns size bytes reserved by reserveCapacity
+func (s *Server) releaseCapacity(size int64) {
+	s.capacity.mu.Lock()
+	defer s.capacity.mu.Unlock()
+
+	// Sessions left open by an earlier run were reserved before the restart
+	s.capacity.reserved -= size
+	if s.capacity.reserved < 0 {
+		s.capacity.reserved = 0
+	}
+}
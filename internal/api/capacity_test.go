@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// newNearlyFullServer creates a test server that already stores a file and has room
+// for only free more bytes above its headroom
+func newNearlyFullServer(t *testing.T, free int64) *Server {
+	t.Helper()
+
+	server := newTestServerWithConfig(t, config.DefaultConfig())
+	uploadTestFile(t, server, "existing.txt", bytes.Repeat([]byte("x"), 256))
+
+	usage, err := server.storage.GetUsage()
+	if err != nil {
+		t.Fatalf("Failed to get usage: %v", err)
+	}
+	server.config.Node.StorageHeadroom = 1024
+	server.config.Node.MaxStorage = usage + server.config.Node.StorageHeadroom + free
+	return server
+}
+
+func TestCapacityGuard(t *testing.T) {
+	server := newNearlyFullServer(t, 500)
+
+	if w := doUpload(t, server, "small.txt", []byte("small")); w.Code != http.StatusOK {
+		t.Fatalf("Expected small upload to fit, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := doUpload(t, server, "large.txt", bytes.Repeat([]byte("y"), 600))
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("Expected status 507, got %d", w.Code)
+	}
+	apiErr := decodeError(t, w.Body.Bytes())
+	if apiErr["code"] != CodeInsufficientStorage {
+		t.Errorf("Expected error code %s, got %v", CodeInsufficientStorage, apiErr["code"])
+	}
+	if details, _ := apiErr["details"].(map[string]interface{}); details["available"] == nil {
+		t.Errorf("Expected available bytes in details, got %v", apiErr["details"])
+	}
+
+	// Resumable uploads are checked when they start
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/uploads", strings.NewReader(`{"name": "large.bin", "size": 600}`))
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusInsufficientStorage {
+		t.Errorf("Expected resumable upload to be rejected with 507, got %d", w.Code)
+	}
+}
+
+func TestCapacityGuardUnlimited(t *testing.T) {
+	server := newNearlyFullServer(t, 0)
+	server.config.Node.MaxStorage = 0
+
+	if w := doUpload(t, server, "any.txt", bytes.Repeat([]byte("z"), 4096)); w.Code != http.StatusOK {
+		t.Errorf("Expected upload without a storage limit to succeed, got %d", w.Code)
+	}
+}
+
+func TestCapacityReservedByOpenUploads(t *testing.T) {
+	server := newNearlyFullServer(t, 500)
+
+	// An open resumable upload holds its space before any of it is stored
+	uploadID := createTestUpload(t, server, "pending.bin", 300)
+	if w := doUpload(t, server, "other.bin", bytes.Repeat([]byte("y"), 300)); w.Code != http.StatusInsufficientStorage {
+		t.Errorf("Expected upload competing for reserved space to get 507, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/uploads/"+uploadID, nil)
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusOK {
+		t.Fatalf("Abort failed with status %d", w.Code)
+	}
+	if w := doUpload(t, server, "other.bin", bytes.Repeat([]byte("y"), 300)); w.Code != http.StatusOK {
+		t.Errorf("Expected upload to fit once the reservation is released, got %d", w.Code)
+	}
+	if server.capacity.reserved != 0 {
+		t.Errorf("Expected no capacity left reserved, got %d", server.capacity.reserved)
+	}
+}
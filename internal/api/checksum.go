@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// supportedChecksumAlgorithms lists the algorithm names accepted by the checksum endpoint
+func supportedChecksumAlgorithms() []string {
+	names := make([]string, 0, len(types.HashAlgorithms))
+	for _, algorithm := range types.HashAlgorithms {
+		names = append(names, string(algorithm))
+	}
+	return names
+}
+
+// getFileChecksum handles returning a file's checksum, by default with the algorithm
+// the file was stored with. The hash recorded at upload is returned as is unless
+// recompute=true, in which case the file is rehashed from its stored chunks and
+// compared with the recorded hash. Other algorithms are always computed.
+func (s *Server) getFileChecksum(c *gin.Context) {
+	fileInfo, exists := s.activeFile(c.Param("id"))
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, true) {
+		return
+	}
+
+	algorithm, err := types.ParseHashAlgorithm(c.Query("algo"))
+	if c.Query("algo") == "" && fileInfo.HashAlgorithm != "" {
+		algorithm = fileInfo.HashAlgorithm
+	}
+	if err != nil {
+		writeAPIError(c, http.StatusBadRequest, &APIError{
+			Code:    CodeInvalidRequest,
+			Message: "Unsupported checksum algorithm: " + c.Query("algo"),
+			Details: gin.H{"supported": supportedChecksumAlgorithms()},
+		})
+		return
+	}
+
+	response := gin.H{
+		"file_id":   fileInfo.ID,
+		"algorithm": algorithm,
+		"size":      fileInfo.Size,
+	}
+
+	recorded, hasRecorded := recordedChecksum(fileInfo, algorithm)
+	if hasRecorded && c.Query("recompute") != "true" {
+		response["checksum"] = recorded
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response["recomputed"] = true
+	fileInfo, unpin, exists := s.readFile(fileInfo)
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+	defer unpin()
+	checksum, err := s.chunkManager.ChecksumFile(c.Request.Context(), fileInfo, algorithm.New)
+	if err != nil {
+		s.log(c).WithError(err).WithField("file_id", fileInfo.ID).Warn("File failed checksum verification")
+		response["verified"] = false
+		response["error"] = err.Error()
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response["checksum"] = checksum
+	if hasRecorded {
+		response["verified"] = checksum == recorded
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// recordedChecksum returns the hash recorded for a file at upload if it was computed
+// with the given algorithm
+func recordedChecksum(fileInfo *types.FileInfo, algorithm types.HashAlgorithm) (string, bool) {
+	recorded := fileInfo.HashAlgorithm
+	if recorded == "" {
+		recorded = types.DefaultHashAlgorithm
+	}
+	if algorithm != recorded {
+		return "", false
+	}
+	return fileInfo.Hash, true
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// checksumResponse is the body returned by the checksum endpoint
+type checksumResponse struct {
+	FileID     string `json:"file_id"`
+	Algorithm  string `json:"algorithm"`
+	Checksum   string `json:"checksum"`
+	Recomputed bool   `json:"recomputed"`
+	Verified   *bool  `json:"verified"`
+	Error      string `json:"error"`
+}
+
+// getChecksum requests a file's checksum with the given query string
+func getChecksum(t *testing.T, server *Server, fileID, query string) checksumResponse {
+	t.Helper()
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID+"/checksum"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response checksumResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response
+}
+
+func TestFileChecksum(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("checksum me without downloading")
+	fileID := uploadTestFile(t, server, "sum.txt", content)
+
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		query      string
+		recomputed bool
+	}{
+		{"", false},
+		{"?algo=sha256", false},
+		{"?algo=SHA256&recompute=true", true},
+	}
+
+	for _, tt := range tests {
+		response := getChecksum(t, server, fileID, tt.query)
+		if response.Algorithm != "sha256" || response.Checksum != want {
+			t.Errorf("%q: expected sha256 %s, got %s %s", tt.query, want, response.Algorithm, response.Checksum)
+		}
+		if response.Recomputed != tt.recomputed {
+			t.Errorf("%q: expected recomputed %v, got %v", tt.query, tt.recomputed, response.Recomputed)
+		}
+		if tt.recomputed && (response.Verified == nil || !*response.Verified) {
+			t.Errorf("%q: expected recomputed checksum to verify, got %+v", tt.query, response)
+		}
+	}
+}
+
+func TestFileChecksumDetectsCorruption(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadTestFile(t, server, "rot.txt", []byte("a chunk of this will rot"))
+
+	chunk := server.files[fileID].Chunks[1]
+	server.storage.Store(context.Background(), chunk.ID, []byte("bit rot"))
+
+	// The recorded hash is returned without touching the chunks
+	if response := getChecksum(t, server, fileID, ""); response.Checksum != server.files[fileID].Hash {
+		t.Errorf("Expected recorded hash, got %+v", response)
+	}
+
+	response := getChecksum(t, server, fileID, "?recompute=true")
+	if response.Verified == nil || *response.Verified {
+		t.Errorf("Expected corrupted file to fail verification, got %+v", response)
+	}
+	if !strings.Contains(response.Error, "chunk 1") {
+		t.Errorf("Expected error naming chunk 1, got %q", response.Error)
+	}
+}
+
+func TestFileChecksumUnsupportedAlgorithm(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadTestFile(t, server, "sum.txt", []byte("content"))
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID+"/checksum?algo=md5", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	if apiErr := decodeError(t, w.Body.Bytes()); apiErr["code"] != CodeInvalidRequest {
+		t.Errorf("Expected code %s, got %v", CodeInvalidRequest, apiErr["code"])
+	}
+}
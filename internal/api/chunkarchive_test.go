@@ -0,0 +1,91 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestDownloadChunkArchive(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.API.AdminToken = "secret"
+	server := newTestServerWithConfig(t, cfg)
+
+	content := []byte("0123456789abcdefghij")
+	fileID := uploadTestFile(t, server, "archived.txt", content)
+	fileInfo, _ := server.lookupFile(fileID)
+	path := "/api/v1/admin/files/" + fileID + "/chunks.tar"
+
+	if w := serve(server, httptest.NewRequest(http.MethodGet, path, nil)); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without admin token, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/files/missing/chunks.tar", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	if w := serve(server, req); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown file, got %d", w.Code)
+	}
+
+	// Lose one chunk to check it is reported rather than breaking the archive
+	lost := fileInfo.Chunks[2]
+	if err := server.storage.Delete(context.Background(), lost.ID); err != nil {
+		t.Fatalf("Failed to delete chunk: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := serve(server, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-tar" {
+		t.Errorf("Expected a tar archive, got %s", ct)
+	}
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(w.Body)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries[header.Name] = data
+	}
+
+	if len(entries) != len(fileInfo.Chunks) {
+		t.Fatalf("Expected %d entries, got %d", len(fileInfo.Chunks), len(entries))
+	}
+	for _, chunkInfo := range fileInfo.Chunks {
+		name := chunkEntryName(chunkInfo)
+		if chunkInfo.ID == lost.ID {
+			if data, ok := entries[name+".missing"]; !ok || len(data) != 0 {
+				t.Errorf("Expected an empty %s.missing entry for the lost chunk", name)
+			}
+			continue
+		}
+
+		data, ok := entries[name]
+		if !ok {
+			t.Errorf("Expected an entry named %s", name)
+			continue
+		}
+		// The chunks are the stored bytes, still encrypted
+		if types.CalculateHash(data) != chunkInfo.Checksum {
+			t.Errorf("Expected %s to match the stored chunk checksum", name)
+		}
+		if bytes.Contains(data, content[chunkInfo.Index*4:chunkInfo.Index*4+4]) {
+			t.Errorf("Expected %s to be encrypted", name)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// getFileChunks handles listing how a file was split into chunks and where they are
+// stored, without the chunk data, for debugging reassembly
+func (s *Server) getFileChunks(c *gin.Context) {
+	fileID := c.Param("id")
+
+	fileInfo, exists := s.activeFile(fileID)
+	if !exists {
+		s.writeFileMissing(c, fileID)
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, false) {
+		return
+	}
+
+	chunks := fileInfo.Chunks
+	if chunks == nil {
+		chunks = []types.ChunkInfo{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"file_id": fileInfo.ID,
+		"count":   len(chunks),
+		"chunks":  chunks,
+	})
+}
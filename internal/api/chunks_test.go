@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestGetFileChunks(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("0123456789abcdefghij")
+	fileID := uploadAs(t, server, "alice", "layout.txt", content)
+	path := "/api/v1/files/" + fileID + "/chunks"
+
+	if w := getAs(server, "mallory", path); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another owner, got %d", w.Code)
+	}
+	if w := getAs(server, "alice", "/api/v1/files/missing/chunks"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown file, got %d", w.Code)
+	}
+
+	w := getAs(server, "alice", path)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var layout struct {
+		FileID string            `json:"file_id"`
+		Count  int               `json:"count"`
+		Chunks []types.ChunkInfo `json:"chunks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &layout); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	stored, _ := server.lookupFile(fileID)
+	if layout.FileID != fileID || layout.Count != len(stored.Chunks) || len(stored.Chunks) != 5 {
+		t.Fatalf("Expected %d chunks of %s, got %d of %s", len(stored.Chunks), fileID, layout.Count, layout.FileID)
+	}
+	if fmt.Sprint(layout.Chunks) != fmt.Sprint(stored.Chunks) {
+		t.Errorf("Expected chunks %+v, got %+v", stored.Chunks, layout.Chunks)
+	}
+
+	var total int64
+	for i, chunkInfo := range layout.Chunks {
+		if chunkInfo.Index != i || chunkInfo.Hash == "" || chunkInfo.Checksum == "" || len(chunkInfo.NodeIDs) == 0 {
+			t.Errorf("Chunk %d: expected index, hash, checksum and nodes, got %+v", i, chunkInfo)
+		}
+		total += chunkInfo.Size
+	}
+	if total != int64(len(content)) {
+		t.Errorf("Expected chunk sizes to add up to %d, got %d", len(content), total)
+	}
+}
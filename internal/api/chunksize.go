@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkChunkSize rejects a requested chunk size outside the node's configured bounds
+// with 400, writing the error response. Zero asks for the node's default chunk size.
+func (s *Server) checkChunkSize(c *gin.Context, size int) bool {
+	if size == 0 {
+		return true
+	}
+
+	min, max := s.config.Node.MinChunkSize, s.config.Node.MaxChunkSize
+	if size >= min && size <= max {
+		return true
+	}
+
+	writeAPIError(c, http.StatusBadRequest, &APIError{
+		Code:    CodeInvalidRequest,
+		Message: fmt.Sprintf("Chunk size must be between %d and %d bytes", min, max),
+		Details: gin.H{"min": min, "max": max},
+	})
+	return false
+}
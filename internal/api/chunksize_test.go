@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// newChunkSizeTestServer creates a test server accepting chunk sizes of 2 to 16 bytes
+func newChunkSizeTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Node.MinChunkSize = 2
+	cfg.Node.MaxChunkSize = 16
+	return newTestServerWithConfig(t, cfg)
+}
+
+func TestUploadChunkSize(t *testing.T) {
+	server := newChunkSizeTestServer(t)
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	tests := []struct {
+		name       string
+		chunkSize  string
+		wantStatus int
+		wantChunks int
+	}{
+		{"node default", "", http.StatusOK, 9},
+		{"smallest", "2", http.StatusOK, 18},
+		{"largest", "16", http.StatusOK, 3},
+		{"below min", "1", http.StatusBadRequest, 0},
+		{"above max", "17", http.StatusBadRequest, 0},
+		{"not a number", "big", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		fields := map[string]string{"name": tt.name + ".txt"}
+		if tt.chunkSize != "" {
+			fields["chunk_size"] = tt.chunkSize
+		}
+		w := serve(server, newUploadRequest(t, tt.name+".txt", content, fields))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, w.Code)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		fileID := fileIDFromResponse(t, w)
+		fileInfo, _ := server.lookupFile(fileID)
+		if len(fileInfo.Chunks) != tt.wantChunks {
+			t.Errorf("%s: expected %d chunks, got %d", tt.name, tt.wantChunks, len(fileInfo.Chunks))
+		}
+
+		w = serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil))
+		if !bytes.Equal(w.Body.Bytes(), content) {
+			t.Errorf("%s: downloaded %q, expected %q", tt.name, w.Body.String(), content)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)
+		req.Header.Set("Range", "bytes=5-20")
+		if w = serve(server, req); w.Body.String() != string(content[5:21]) {
+			t.Errorf("%s: range downloaded %q, expected %q", tt.name, w.Body.String(), content[5:21])
+		}
+	}
+}
+
+func TestResumableUploadChunkSize(t *testing.T) {
+	server := newChunkSizeTestServer(t)
+	content := []byte("resumable upload with its own chunk size")
+
+	body := `{"name": "sized.txt", "size": 40, "chunk_size": 32}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/uploads", strings.NewReader(body))
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for chunk size above max, got %d", w.Code)
+	}
+
+	body = `{"name": "sized.txt", "size": 40, "chunk_size": 16}`
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/uploads", strings.NewReader(body))
+	req.Header.Set("X-Owner", "alice")
+	w := serve(server, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var session struct {
+		UploadID   string `json:"upload_id"`
+		ChunkSize  int    `json:"chunk_size"`
+		ChunkCount int    `json:"chunk_count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if session.ChunkSize != 16 || session.ChunkCount != 3 {
+		t.Fatalf("Expected 3 chunks of 16 bytes, got %d of %d", session.ChunkCount, session.ChunkSize)
+	}
+
+	for index := 0; index < session.ChunkCount; index++ {
+		end := (index + 1) * 16
+		if end > len(content) {
+			end = len(content)
+		}
+		if w := putTestChunk(server, session.UploadID, index, content[index*16:end]); w.Code != http.StatusOK {
+			t.Fatalf("Chunk %d: expected status 200, got %d", index, w.Code)
+		}
+	}
+
+	w = completeTestUpload(server, session.UploadID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on complete, got %d: %s", w.Code, w.Body.String())
+	}
+
+	fileInfo, _ := server.lookupFile(fileIDFromResponse(t, w))
+	if fileInfo.ChunkSize != 16 || len(fileInfo.Chunks) != 3 {
+		t.Errorf("Expected 3 stored chunks of 16 bytes, got %d of %d", len(fileInfo.Chunks), fileInfo.ChunkSize)
+	}
+}
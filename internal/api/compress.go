@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressedTypes are content types that are already compressed, so compressing
+// them again only costs CPU
+var compressedTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zip":              true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+}
+
+// compressible reports whether a response with the given content type is worth compressing
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || compressedTypes[mediaType] {
+		return false
+	}
+
+	// Media formats are compressed already, apart from SVG which is XML
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, prefix := range []string{"image/", "video/", "audio/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header, preferring gzip
+// when both are acceptable. It returns an empty string when neither is.
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		q := 1.0
+		if name, value, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[coding] = q > 0
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if ok, listed := accepted[encoding]; ok || (!listed && accepted["*"]) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressWriter buffers the start of a response body until it can decide whether to
+// compress it, then streams the rest through the chosen encoder
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+
+	buf     bytes.Buffer
+	decided bool
+	encoder io.WriteCloser
+}
+
+// Write buffers data until minSize bytes are seen, then passes it on compressed or not
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf.Write(data)
+	if w.buf.Len() >= w.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// WriteString writes a string through Write
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports whether the body has begun, counting data that is still buffered, so
+// a handler failing partway through a response does not append an error body to it
+func (w *compressWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush sends any buffered data to the client
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide chooses whether to compress based on the buffered body and the response
+// headers, then writes the buffer out
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	header := w.Header()
+	if w.buf.Len() >= w.minSize && w.eligible() {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+
+		if w.encoding == "gzip" {
+			w.encoder = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.encoder, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+
+	data := w.buf.Bytes()
+	w.buf = bytes.Buffer{}
+	if len(data) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(data)
+	} else {
+		_, err = w.ResponseWriter.Write(data)
+	}
+	return err
+}
+
+// eligible reports whether the response headers allow compression
+func (w *compressWriter) eligible() bool {
+	header := w.Header()
+	switch w.Status() {
+	case http.StatusPartialContent, http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	return compressible(header.Get("Content-Type"))
+}
+
+// close writes out anything still buffered and finishes the compressed stream
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
+
+// compressionMiddleware compresses response bodies of at least minSize bytes for clients
+// that accept gzip or deflate
+func (s *Server) compressionMiddleware(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		// Caches must key responses on the encoding whether or not this one is compressed
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = writer
+		defer func() {
+			writer.close()
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
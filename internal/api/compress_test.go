@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"br", ""},
+		{"*", "gzip"},
+		{"gzip;q=0, *", "deflate"},
+		{"identity", ""},
+	}
+
+	for _, tt := range tests {
+		if encoding := negotiateEncoding(tt.header); encoding != tt.expected {
+			t.Errorf("Header %q: expected %q, got %q", tt.header, tt.expected, encoding)
+		}
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"application/json; charset=utf-8", true},
+		{"text/plain", true},
+		{"application/octet-stream", true},
+		{"image/svg+xml", true},
+		{"image/png", false},
+		{"video/mp4", false},
+		{"application/zip", false},
+		{"application/gzip", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if result := compressible(tt.contentType); result != tt.expected {
+			t.Errorf("Content type %q: expected %v, got %v", tt.contentType, tt.expected, result)
+		}
+	}
+}
+
+func TestCompressLargeList(t *testing.T) {
+	server := newTestServer(t)
+	for i := 0; i < 30; i++ {
+		uploadTestFile(t, server, fmt.Sprintf("file-%03d.txt", i), []byte("data"))
+	}
+
+	plain := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files", nil))
+	if plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("Expected uncompressed response without Accept-Encoding")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := serve(server, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", encoding)
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+	}
+	if w.Body.Len() >= plain.Body.Len() {
+		t.Errorf("Expected compressed body smaller than %d bytes, got %d", plain.Body.Len(), w.Body.Len())
+	}
+
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.Count != 30 {
+		t.Errorf("Expected 30 files in decompressed list, got %d (%v)", result.Count, err)
+	}
+}
+
+func TestCompressDownload(t *testing.T) {
+	server := newTestServer(t)
+	content := bytes.Repeat([]byte("compressible text "), 200)
+	fileID := uploadTestFile(t, server, "notes.txt", content)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)
+	req.Header.Set("Accept-Encoding", "deflate")
+	w := serve(server, req)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "deflate" {
+		t.Fatalf("Expected deflate encoding, got %q", encoding)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Error("Expected Content-Length to be dropped from compressed response")
+	}
+
+	body, err := io.ReadAll(flate.NewReader(w.Body))
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	if !bytes.Equal(body, content) {
+		t.Error("Expected decompressed download to match uploaded content")
+	}
+
+	// Range responses are sent as-is
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-2047")
+	w = serve(server, req)
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected uncompressed 206 for range request, got %d with %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+
+	// Small responses are not worth compressing
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if encoding := serve(server, req).Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Expected small response to be uncompressed, got %q", encoding)
+	}
+}
+
+func TestCompressStreamFailure(t *testing.T) {
+	server := newTestServer(t)
+	server.GetRouter().GET("/test/partial", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("partial")
+		server.finishStream(c, errors.New("chunk unavailable"), "Failed to stream file")
+	})
+
+	// The data written before the failure is still buffered, undecided, yet counts as
+	// the response having started
+	req := httptest.NewRequest(http.MethodGet, "/test/partial", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := serve(server, req)
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("Expected the partial body alone, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// copyFile handles duplicating a file for the caller under a new ID and optionally a new
+// name. The copy references the same chunks instead of storing the bytes again.
+func (s *Server) copyFile(c *gin.Context) {
+	fileInfo, exists := s.activeFile(c.Param("id"))
+	if !exists {
+		s.writeFileMissing(c, c.Param("id"))
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, true) {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"` // Empty keeps the name of the original
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "Invalid copy request")
+			return
+		}
+	}
+
+	// The chunks must not be released by an append or delete before the copy shares them
+	fileInfo, unlock, exists := s.rlockFile(fileInfo)
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+	defer unlock()
+	copied := *fileInfo
+
+	owner := s.principal(c)
+	name := copied.Name
+	if req.Name != "" {
+		if strings.TrimSpace(req.Name) == "" {
+			writeError(c, http.StatusBadRequest, "Invalid file name")
+			return
+		}
+		name = req.Name
+	}
+
+	// Taking the name of one of the caller's files would make the copy its next version
+	if len(s.versionsOf(&types.FileInfo{Name: name, Owner: owner}, true)) > 0 {
+		writeError(c, http.StatusConflict, "File name already in use")
+		return
+	}
+
+	fileID, err := utils.GenerateRandomID(64)
+	if err != nil {
+		s.log(c).WithError(err).Error("Failed to generate file ID")
+		writeError(c, http.StatusInternalServerError, "Failed to copy file")
+		return
+	}
+
+	// The copy counts against the caller's quota even though no bytes are stored
+	if err := s.quotas.Reserve(owner, copied.Size); err != nil {
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			writeAPIError(c, http.StatusRequestEntityTooLarge, &APIError{Code: CodeQuotaExceeded, Message: "Storage quota exceeded"})
+			return
+		}
+		s.log(c).WithError(err).Error("Failed to reserve quota")
+		writeError(c, http.StatusInternalServerError, "Failed to copy file")
+		return
+	}
+
+	if err := s.chunkManager.ShareChunks(&copied); err != nil {
+		s.releaseQuota(owner, copied.Size)
+		if errors.Is(err, storage.ErrChunksNotShareable) {
+			writeError(c, http.StatusConflict, "File cannot be copied")
+			return
+		}
+		s.log(c).WithError(err).Error("Failed to share file chunks")
+		writeError(c, http.StatusInternalServerError, "Failed to copy file")
+		return
+	}
+
+	source := copied.ID
+	copied.ID = fileID
+	copied.Name = name
+	copied.Owner = owner
+	copied.Public = false
+	copied.Chunks = append([]types.ChunkInfo(nil), copied.Chunks...)
+	copied.Tags = copyTags(copied.Tags)
+	copied.CreatedAt = time.Time{}
+	copied.UpdatedAt = time.Time{}
+	copied.DeletedAt = nil
+	copied.ExpiresAt = nil
+	copied.AnchorTx = ""
+	copied.Version = 0
+	copied.PreviousVersion = ""
+	s.saveFile(&copied)
+
+	s.log(c).WithFields(logrus.Fields{
+		"file_id":   copied.ID,
+		"file_name": copied.Name,
+		"source_id": source,
+	}).Info("File copied")
+
+	c.JSON(http.StatusCreated, &copied)
+}
+
+// copyTags returns an independent copy of a file's tags
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		copied[key] = value
+	}
+	return copied
+}
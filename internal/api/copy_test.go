@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// copyAs copies a file as the given owner with the given JSON body
+func copyAs(server *Server, owner, fileID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/"+fileID+"/copy", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Owner", owner)
+	return serve(server, req)
+}
+
+// copiedFile decodes the file info returned by the copy endpoint
+func copiedFile(t *testing.T, w *httptest.ResponseRecorder) *types.FileInfo {
+	t.Helper()
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var fileInfo types.FileInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &fileInfo); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return &fileInfo
+}
+
+func TestCopyFile(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("duplicated without re-uploading")
+	original := uploadAs(t, server, "alice", "report.txt", content)
+	stored, _ := server.storage.List()
+
+	copied := copiedFile(t, copyAs(server, "alice", original, `{"name": "report-copy.txt"}`))
+	if copied.ID == original {
+		t.Errorf("Expected the copy to get a new ID")
+	}
+	if copied.Name != "report-copy.txt" || copied.Version != 1 {
+		t.Errorf("Expected report-copy.txt version 1, got %s version %d", copied.Name, copied.Version)
+	}
+
+	// No bytes are stored for the copy
+	if keys, _ := server.storage.List(); len(keys) != len(stored) {
+		t.Errorf("Expected %d stored chunks after copying, got %d", len(stored), len(keys))
+	}
+
+	for _, id := range []string{original, copied.ID} {
+		if got := getAs(server, "alice", "/api/v1/files/"+id).Body.Bytes(); !bytes.Equal(got, content) {
+			t.Errorf("Expected %s to download %q, got %q", id, content, got)
+		}
+	}
+
+	// Deleting the original leaves the chunks the copy still references
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+original+"?permanent=true"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 deleting the original, got %d", w.Code)
+	}
+	if got := getAs(server, "alice", "/api/v1/files/"+copied.ID).Body.Bytes(); !bytes.Equal(got, content) {
+		t.Errorf("Expected the copy to download %q after deleting the original, got %q", content, got)
+	}
+
+	// Deleting the last copy frees the chunks
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+copied.ID+"?permanent=true"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 deleting the copy, got %d", w.Code)
+	}
+	if keys, _ := server.storage.List(); len(keys) != 0 {
+		t.Errorf("Expected no stored chunks after deleting both copies, got %d", len(keys))
+	}
+}
+
+func TestCopyFileOwnership(t *testing.T) {
+	server := newTestServer(t)
+	private := uploadAs(t, server, "alice", "private.txt", []byte("alice only"))
+
+	req := newUploadRequest(t, "public.txt", []byte("anyone may copy"), map[string]string{"public": "true"})
+	req.Header.Set("X-Owner", "alice")
+	public := fileIDFromResponse(t, serve(server, req))
+
+	tests := []struct {
+		name       string
+		owner      string
+		fileID     string
+		body       string
+		wantStatus int
+	}{
+		{"private file of another owner", "bob", private, "", http.StatusForbidden},
+		{"public file of another owner", "bob", public, "", http.StatusCreated},
+		{"name of an existing file", "alice", private, "", http.StatusConflict},
+		{"blank name", "alice", private, `{"name": " "}`, http.StatusBadRequest},
+		{"missing file", "alice", "missing", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if w := copyAs(server, tt.owner, tt.fileID, tt.body); w.Code != tt.wantStatus {
+			t.Errorf("%s: Expected status %d, got %d: %s", tt.name, tt.wantStatus, w.Code, w.Body.String())
+		}
+	}
+
+	if usage, _ := server.quotas.Usage("bob"); usage != int64(len("anyone may copy")) {
+		t.Errorf("Expected the copy to count against bob's quota, got %d", usage)
+	}
+}
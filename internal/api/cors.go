@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// corsPolicy decides which cross-origin requests the API answers
+type corsPolicy struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+// newCORSPolicy builds the policy from the config
+func newCORSPolicy(cfg config.CORSConfig) *corsPolicy {
+	policy := &corsPolicy{
+		origins:     make(map[string]bool),
+		methods:     strings.Join(cfg.AllowedMethods, ", "),
+		headers:     strings.Join(cfg.AllowedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		policy.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	if cfg.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	return policy
+}
+
+// allows reports whether requests from origin may be answered
+func (p *corsPolicy) allows(origin string) bool {
+	return p.anyOrigin || p.origins[strings.ToLower(origin)]
+}
+
+// lists reports whether origin is one of the configured origins, not counting "*"
+func (p *corsPolicy) lists(origin string) bool {
+	return p.origins[strings.ToLower(origin)]
+}
+
+// allowsMethod reports whether a preflight may go ahead with method
+func (p *corsPolicy) allowsMethod(method string) bool {
+	for _, allowed := range strings.Split(p.methods, ", ") {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether origin is the server itself, as browsers send Origin on
+// some same-origin requests too
+func sameOrigin(req *http.Request, origin string) bool {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return strings.EqualFold(origin, scheme+"://"+req.Host)
+}
+
+// corsMiddleware answers cross-origin requests from the configured origins. A matched
+// origin is echoed back rather than "*" so credentialed requests work, and requests
+// from other origins are refused with 403. Requests without an Origin header are not
+// cross-origin browser requests and pass through untouched.
+func (s *Server) corsMiddleware() gin.HandlerFunc {
+	policy := s.cors
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || sameOrigin(c.Request, origin) {
+			c.Next()
+			return
+		}
+
+		// Responses differ by origin, so shared caches must not mix them up
+		c.Writer.Header().Add("Vary", "Origin")
+
+		if !policy.allows(origin) {
+			s.log(c).WithField("origin", origin).Warn("Cross-origin request rejected")
+			writeError(c, http.StatusForbidden, "Origin not allowed")
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if policy.credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		// A preflight asks whether the real request may be sent; answer it here
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			if !policy.allowsMethod(c.GetHeader("Access-Control-Request-Method")) {
+				writeError(c, http.StatusForbidden, "Method not allowed for cross-origin requests")
+				return
+			}
+			c.Header("Access-Control-Allow-Methods", policy.methods)
+			c.Header("Access-Control-Allow-Headers", policy.headers)
+			if policy.maxAge != "" {
+				c.Header("Access-Control-Max-Age", policy.maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
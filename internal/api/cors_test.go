@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// newCORSTestServer creates a test server allowing only app.example.com, with credentials
+func newCORSTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.API.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	cfg.API.CORS.AllowCredentials = true
+	return newTestServerWithConfig(t, cfg)
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	server := newCORSTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := serve(server, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected the origin to be echoed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials to be allowed, got %q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	server := newCORSTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w := serve(server, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Origin, got %q", got)
+	}
+	if code := decodeError(t, w.Body.Bytes())["code"]; code != CodeForbidden {
+		t.Errorf("Expected code %s, got %v", CodeForbidden, code)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	server := newCORSTestServer(t)
+
+	tests := []struct {
+		name       string
+		origin     string
+		method     string
+		wantStatus int
+	}{
+		{"allowed", "https://app.example.com", http.MethodDelete, http.StatusNoContent},
+		{"disallowed origin", "https://evil.example.com", http.MethodDelete, http.StatusForbidden},
+		{"disallowed method", "https://app.example.com", "CONNECT", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/files/abc", nil)
+		req.Header.Set("Origin", tt.origin)
+		req.Header.Set("Access-Control-Request-Method", tt.method)
+		req.Header.Set("Access-Control-Request-Headers", "X-Owner")
+		w := serve(server, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, w.Code)
+			continue
+		}
+		if tt.wantStatus != http.StatusNoContent {
+			continue
+		}
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, HEAD, POST, PUT, PATCH, DELETE" {
+			t.Errorf("%s: unexpected Access-Control-Allow-Methods %q", tt.name, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization, Range, X-Owner, X-Request-ID" {
+			t.Errorf("%s: unexpected Access-Control-Allow-Headers %q", tt.name, got)
+		}
+		if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+			t.Errorf("%s: expected Access-Control-Max-Age 600, got %q", tt.name, got)
+		}
+	}
+}
+
+func TestCORSWithoutOrigin(t *testing.T) {
+	server := newCORSTestServer(t)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers for same-origin requests, got %q", got)
+	}
+}
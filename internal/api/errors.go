@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned in APIError.Code
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeGone                = "gone"
+	CodeTooLarge            = "too_large"
+	CodeRangeNotSatisfiable = "range_not_satisfiable"
+	CodeRateLimited         = "rate_limited"
+	CodeInternal            = "internal_error"
+	CodeUnavailable         = "unavailable"
+	CodeInsufficientStorage = "insufficient_storage"
+
+	CodeImmutableField   = "immutable_field"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeUploadIncomplete = "upload_incomplete"
+	CodeShareExpired     = "share_expired"
+	CodeShareInvalid     = "share_invalid"
+	CodeAdminRequired    = "admin_required"
+)
+
+// APIError is the body of every error response, wrapped as {"error": {...}}
+type APIError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Error returns the human-readable message
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// writeError aborts the request with an error carrying the default code for the status
+func writeError(c *gin.Context, status int, message string) {
+	writeAPIError(c, status, &APIError{Code: codeForStatus(status), Message: message})
+}
+
+// writeAPIError aborts the request with the given error
+func writeAPIError(c *gin.Context, status int, apiErr *APIError) {
+	c.AbortWithStatusJSON(status, gin.H{"error": apiErr})
+}
+
+// codeForStatus returns the generic error code for an HTTP status
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusRequestedRangeNotSatisfiable:
+		return CodeRangeNotSatisfiable
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusInsufficientStorage:
+		return CodeInsufficientStorage
+	}
+	return CodeInternal
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// decodeError parses an error response, checking it holds nothing but the error envelope
+func decodeError(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("Failed to parse error response: %v", err)
+	}
+	if len(envelope) != 1 || envelope["error"] == nil {
+		t.Fatalf("Expected only an error field, got %s", body)
+	}
+
+	var apiErr map[string]interface{}
+	if err := json.Unmarshal(envelope["error"], &apiErr); err != nil {
+		t.Fatalf("Expected error to be an object, got %s", envelope["error"])
+	}
+	return apiErr
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "notes.txt", []byte("notes"))
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		status  int
+		code    string
+		message string
+		details map[string]interface{}
+	}{
+		{
+			name:    "not found",
+			path:    "/api/v1/files/missing/info",
+			status:  http.StatusNotFound,
+			code:    CodeNotFound,
+			message: "File not found",
+		},
+		{
+			name:    "bad request",
+			path:    "/api/v1/search?q=",
+			status:  http.StatusBadRequest,
+			code:    CodeInvalidRequest,
+			message: "Invalid search query",
+		},
+		{
+			name:    "bad request with details",
+			path:    "/api/v1/files/" + fileID,
+			body:    `{"hash": "abc"}`,
+			status:  http.StatusBadRequest,
+			code:    CodeImmutableField,
+			message: "Field cannot be updated: hash",
+			details: map[string]interface{}{"field": "hash"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getAs(server, "alice", tt.path)
+			if tt.body != "" {
+				w = patchAs(server, "alice", tt.path, tt.body)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+
+			apiErr := decodeError(t, w.Body.Bytes())
+			if apiErr["code"] != tt.code {
+				t.Errorf("Expected code %s, got %v", tt.code, apiErr["code"])
+			}
+			if apiErr["message"] != tt.message {
+				t.Errorf("Expected message %q, got %v", tt.message, apiErr["message"])
+			}
+
+			details, hasDetails := apiErr["details"].(map[string]interface{})
+			if tt.details == nil && hasDetails {
+				t.Errorf("Expected no details, got %v", details)
+			}
+			for key, value := range tt.details {
+				if details[key] != value {
+					t.Errorf("Expected detail %s=%v, got %v", key, value, details[key])
+				}
+			}
+		})
+	}
+}
+
+func TestCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected string
+	}{
+		{http.StatusBadRequest, CodeInvalidRequest},
+		{http.StatusForbidden, CodeForbidden},
+		{http.StatusNotFound, CodeNotFound},
+		{http.StatusConflict, CodeConflict},
+		{http.StatusRequestEntityTooLarge, CodeTooLarge},
+		{http.StatusTooManyRequests, CodeRateLimited},
+		{http.StatusServiceUnavailable, CodeUnavailable},
+		{http.StatusInternalServerError, CodeInternal},
+		{http.StatusBadGateway, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		if code := codeForStatus(tt.status); code != tt.expected {
+			t.Errorf("Status %d: expected %s, got %s", tt.status, tt.expected, code)
+		}
+	}
+}
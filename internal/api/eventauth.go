@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// eventTokenTTL is how long a token issued for the event stream can be used to connect
+	eventTokenTTL = time.Minute
+
+	// eventTokenProtocol prefixes a token offered as a WebSocket subprotocol, which keeps
+	// it out of URLs and access logs
+	eventTokenProtocol = "token."
+)
+
+// errEventTokenInvalid is returned for event tokens that are malformed, tampered with or expired
+var errEventTokenInvalid = errors.New("invalid event token")
+
+// eventTokens issues and checks the tokens that authenticate event stream connections.
+// Browsers cannot add headers to WebSocket requests but do send client certificates on
+// behalf of any page, so callers fetch a token with a normal request, which CORS
+// guards, and present it when connecting. Tokens are used right away against the node
+// that issued them, so the signing key only lives in memory.
+type eventTokens struct {
+	key []byte
+	mu  sync.Mutex
+}
+
+// issue returns a token naming owner that expires at expiresAt
+func (et *eventTokens) issue(owner string, expiresAt time.Time) (string, error) {
+	key, err := et.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	payload := strconv.FormatInt(expiresAt.UnixMilli(), 10) + "." + owner
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(eventTokenMAC(key, payload)), nil
+}
+
+// verify checks a token's signature and expiry and returns the owner it names
+func (et *eventTokens) verify(token string, now time.Time) (string, error) {
+	key, err := et.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	encodedPayload, encodedMAC, found := strings.Cut(token, ".")
+	if !found {
+		return "", errEventTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", errEventTokenInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, eventTokenMAC(key, string(payload))) {
+		return "", errEventTokenInvalid
+	}
+
+	expiry, owner, found := strings.Cut(string(payload), ".")
+	if !found || owner == "" {
+		return "", errEventTokenInvalid
+	}
+	millis, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.After(time.UnixMilli(millis)) {
+		return "", errEventTokenInvalid
+	}
+	return owner, nil
+}
+
+// signingKey returns the key tokens are signed with, generating it on first use
+func (et *eventTokens) signingKey() ([]byte, error) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+
+	if et.key == nil {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate event token key: %w", err)
+		}
+		et.key = key
+	}
+	return et.key, nil
+}
+
+// eventTokenMAC returns the HMAC-SHA256 of a token payload
+func eventTokenMAC(key []byte, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// createEventToken issues the caller a token for connecting to the event stream
+func (s *Server) createEventToken(c *gin.Context) {
+	owner := s.principal(c)
+	if owner == "" {
+		writeError(c, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	expiresAt := time.Now().Add(eventTokenTTL).Truncate(time.Millisecond)
+	token, err := s.eventTokens.issue(owner, expiresAt)
+	if err != nil {
+		s.log(c).WithError(err).Error("Failed to issue event token")
+		writeError(c, http.StatusInternalServerError, "Failed to issue event token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": expiresAt})
+}
+
+// requestEventToken returns the event token a WebSocket request presents, from the
+// token query parameter or a subprotocol starting with eventTokenProtocol
+func requestEventToken(req *http.Request) string {
+	if token := req.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	for _, protocol := range strings.Split(req.Header.Get("Sec-WebSocket-Protocol"), ",") {
+		if token, found := strings.CutPrefix(strings.TrimSpace(protocol), eventTokenProtocol); found {
+			return token
+		}
+	}
+	return ""
+}
+
+// eventHandshake accepts a WebSocket connection to the event stream. Browsers do not
+// apply CORS to WebSockets, so "*" does not extend to them: besides clients sending no
+// Origin, only the server's own origin and those listed explicitly may connect.
+func (s *Server) eventHandshake(config *websocket.Config, req *http.Request) error {
+	if origin := req.Header.Get("Origin"); origin != "" && !sameOrigin(req, origin) && !s.cors.lists(origin) {
+		return fmt.Errorf("origin %s not allowed", origin)
+	}
+
+	// Answer with the first subprotocol that is not a token, or the token itself when it
+	// is all that was offered, as browsers drop connections that leave their
+	// subprotocols unanswered
+	selected := config.Protocol
+	for _, protocol := range config.Protocol {
+		if !strings.HasPrefix(protocol, eventTokenProtocol) {
+			selected = []string{protocol}
+			break
+		}
+	}
+	if len(selected) > 1 {
+		selected = selected[:1]
+	}
+	config.Protocol = selected
+	return nil
+}
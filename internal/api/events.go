@@ -0,0 +1,181 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"golang.org/x/net/websocket"
+)
+
+// Event types pushed to WebSocket subscribers
+const (
+	EventUploadProgress  = "upload.progress"
+	EventUploadCompleted = "upload.completed"
+	EventFileDeleted     = "file.deleted"
+)
+
+const (
+	// eventBuffer is how many events a subscriber may fall behind before new ones are
+	// dropped for it
+	eventBuffer = 64
+
+	// progressSteps is roughly how many progress events are published per upload
+	progressSteps = 20
+)
+
+// Event is a notification pushed to the WebSocket connections of a file's owner
+type Event struct {
+	Type     string    `json:"type"`
+	FileID   string    `json:"file_id,omitempty"`
+	FileName string    `json:"file_name,omitempty"`
+	UploadID string    `json:"upload_id,omitempty"` // Set for resumable uploads
+	Bytes    int64     `json:"bytes,omitempty"`     // Bytes processed so far
+	Total    int64     `json:"total,omitempty"`     // Size of the file
+	Time     time.Time `json:"time"`
+}
+
+// eventHub fans events out to the subscribers of each owner
+type eventHub struct {
+	subscribers map[string]map[chan Event]struct{}
+	mu          sync.RWMutex
+}
+
+// newEventHub creates a hub without subscribers
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[string]map[chan Event]struct{})}
+}
+
+// subscribe returns a channel receiving the owner's events and a function that ends
+// the subscription
+func (h *eventHub) subscribe(owner string) (<-chan Event, func()) {
+	events := make(chan Event, eventBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[owner] == nil {
+		h.subscribers[owner] = make(map[chan Event]struct{})
+	}
+	h.subscribers[owner][events] = struct{}{}
+	h.mu.Unlock()
+
+	return events, func() {
+		h.mu.Lock()
+		delete(h.subscribers[owner], events)
+		if len(h.subscribers[owner]) == 0 {
+			delete(h.subscribers, owner)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publish sends an event to the owner's subscribers. Subscribers that are not keeping
+// up miss the event rather than holding up the publisher.
+func (h *eventHub) publish(owner string, event Event) {
+	if owner == "" {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for events := range h.subscribers[owner] {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// publishDeleted tells the owner of a file that it was deleted
+func (s *Server) publishDeleted(fileInfo *types.FileInfo) {
+	s.events.publish(fileInfo.Owner, Event{Type: EventFileDeleted, FileID: fileInfo.ID, FileName: fileInfo.Name, Total: fileInfo.Size})
+}
+
+// progressReader publishes upload progress events as a file is read
+type progressReader struct {
+	r         io.Reader
+	hub       *eventHub
+	owner     string
+	event     Event
+	step      int64
+	published int64
+}
+
+// newProgressReader wraps r, publishing event with the bytes read so far about every
+// 1/progressSteps of event.Total
+func newProgressReader(r io.Reader, hub *eventHub, owner string, event Event) *progressReader {
+	event.Type = EventUploadProgress
+	step := event.Total / progressSteps
+	if step < 1 {
+		step = 1
+	}
+	return &progressReader{r: r, hub: hub, owner: owner, event: event, step: step}
+}
+
+// Read reads from the wrapped reader, publishing progress when a step has passed or
+// the reader is exhausted
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.event.Bytes += int64(n)
+	if p.event.Bytes-p.published >= p.step || (err == io.EOF && p.event.Bytes > p.published) {
+		p.published = p.event.Bytes
+		p.hub.publish(p.owner, p.event)
+	}
+	return n, err
+}
+
+// streamEvents handles a WebSocket connection receiving the events of the owner its
+// event token names
+func (s *Server) streamEvents(c *gin.Context) {
+	token := requestEventToken(c.Request)
+	if token == "" {
+		writeError(c, http.StatusUnauthorized, "Event token required")
+		return
+	}
+	owner, err := s.eventTokens.verify(token, time.Now())
+	if err != nil {
+		writeError(c, http.StatusUnauthorized, "Invalid or expired event token")
+		return
+	}
+
+	server := websocket.Server{Handshake: s.eventHandshake, Handler: func(conn *websocket.Conn) {
+		s.pushEvents(conn, owner)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// pushEvents writes the owner's events to conn as JSON messages until the client goes
+// away or the server shuts down
+func (s *Server) pushEvents(conn *websocket.Conn, owner string) {
+	events, unsubscribe := s.events.subscribe(owner)
+	defer unsubscribe()
+
+	// Clients only send to close the connection, so reading just watches for that
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard []byte
+		for websocket.Message.Receive(conn, &discard) == nil {
+		}
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			if err := websocket.JSON.Send(conn, event); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-s.done:
+			conn.Close()
+			return
+		}
+	}
+}
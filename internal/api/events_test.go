@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"golang.org/x/net/websocket"
+)
+
+// eventToken fetches an event stream token as owner
+func eventToken(t *testing.T, server *Server, owner string) string {
+	t.Helper()
+
+	w := requestAs(server, http.MethodPost, owner, "/api/v1/ws/token")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for event token, got %d", w.Code)
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Token == "" {
+		t.Fatalf("Expected an event token, got %s (%v)", w.Body.String(), err)
+	}
+	return resp.Token
+}
+
+// eventsConfig returns the config for connecting to the event stream of a running test
+// server from origin, with query added to the URL
+func eventsConfig(t *testing.T, ts *httptest.Server, origin, query string) *websocket.Config {
+	t.Helper()
+
+	cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/v1/ws"+query, origin)
+	if err != nil {
+		t.Fatalf("Failed to create websocket config: %v", err)
+	}
+	return cfg
+}
+
+// dialEvents connects to the event stream of a running test server as owner and waits
+// until the subscription is in place
+func dialEvents(t *testing.T, server *Server, ts *httptest.Server, owner string) *websocket.Conn {
+	t.Helper()
+	return dialEventsConfig(t, server, eventsConfig(t, ts, ts.URL, "?token="+eventToken(t, server, owner)), owner)
+}
+
+// dialEventsConfig connects to the event stream with cfg and waits until owner's
+// subscription is in place
+func dialEventsConfig(t *testing.T, server *Server, cfg *websocket.Config, owner string) *websocket.Conn {
+	t.Helper()
+
+	conn, err := websocket.DialConfig(cfg)
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		server.events.mu.RLock()
+		subscribed := len(server.events.subscribers[owner]) > 0
+		server.events.mu.RUnlock()
+		if subscribed {
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Websocket never subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// receiveEvent reads the next event from conn
+func receiveEvent(t *testing.T, conn *websocket.Conn) Event {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event Event
+	if err := websocket.JSON.Receive(conn, &event); err != nil {
+		t.Fatalf("Failed to receive event: %v", err)
+	}
+	return event
+}
+
+func TestEventStreamUpload(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+
+	conn := dialEvents(t, server, ts, "alice")
+
+	content := []byte("streamed progress content")
+	fileID := uploadAs(t, server, "alice", "live.txt", content)
+
+	var progress []Event
+	for {
+		event := receiveEvent(t, conn)
+		if event.Type == EventUploadCompleted {
+			if event.FileID != fileID || event.FileName != "live.txt" || event.Total != int64(len(content)) {
+				t.Errorf("Unexpected completion event: %+v", event)
+			}
+			break
+		}
+		if event.Type != EventUploadProgress {
+			t.Fatalf("Expected progress or completion, got %+v", event)
+		}
+		progress = append(progress, event)
+	}
+
+	if len(progress) < 2 {
+		t.Fatalf("Expected several progress events, got %d", len(progress))
+	}
+	for i, event := range progress {
+		if event.FileID != fileID || event.Total != int64(len(content)) {
+			t.Errorf("Unexpected progress event: %+v", event)
+		}
+		if i > 0 && event.Bytes <= progress[i-1].Bytes {
+			t.Errorf("Expected progress to increase, got %d after %d", event.Bytes, progress[i-1].Bytes)
+		}
+	}
+	if last := progress[len(progress)-1]; last.Bytes != int64(len(content)) {
+		t.Errorf("Expected final progress of %d bytes, got %d", len(content), last.Bytes)
+	}
+
+	// Deleting the file is reported too
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+fileID); w.Code != http.StatusOK {
+		t.Fatalf("Expected delete to succeed, got %d", w.Code)
+	}
+	if event := receiveEvent(t, conn); event.Type != EventFileDeleted || event.FileID != fileID {
+		t.Errorf("Expected deletion event for %s, got %+v", fileID, event)
+	}
+}
+
+func TestEventStreamOwnerOnly(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+
+	conn := dialEvents(t, server, ts, "alice")
+
+	// Bob's upload is not sent to alice, so her first event is her own upload
+	uploadAs(t, server, "bob", "bob.txt", []byte("bob"))
+	fileID := uploadAs(t, server, "alice", "alice.txt", []byte("alice"))
+
+	if event := receiveEvent(t, conn); event.FileID != fileID {
+		t.Errorf("Expected only alice's events, got %+v", event)
+	}
+}
+
+func TestEventStreamRequiresAuth(t *testing.T) {
+	server := newTestServer(t)
+
+	if w := serve(server, httptest.NewRequest(http.MethodPost, "/api/v1/ws/token", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for an anonymous token request, got %d", w.Code)
+	}
+
+	token := eventToken(t, server, "alice")
+	expired, err := server.eventTokens.issue("alice", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		owner  string
+	}{
+		{"no token", "/api/v1/ws", ""},
+		{"owner header only", "/api/v1/ws", "alice"},
+		{"tampered token", "/api/v1/ws?token=" + strings.Split(expired, ".")[0] + "." + strings.Split(token, ".")[1], ""},
+		{"expired token", "/api/v1/ws?token=" + expired, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.owner != "" {
+			req.Header.Set("X-Owner", tt.owner)
+		}
+		w := serve(server, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401, got %d", tt.name, w.Code)
+			continue
+		}
+		if code := decodeError(t, w.Body.Bytes())["code"]; code != CodeUnauthorized {
+			t.Errorf("%s: expected error code %s, got %v", tt.name, CodeUnauthorized, code)
+		}
+	}
+}
+
+func TestEventStreamTokenProtocol(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+
+	// The token can travel as a subprotocol, which is not the one selected when the
+	// client offers another
+	cfg := eventsConfig(t, ts, ts.URL, "")
+	cfg.Protocol = []string{"events", eventTokenProtocol + eventToken(t, server, "alice")}
+	conn := dialEventsConfig(t, server, cfg, "alice")
+	if protocol := conn.Config().Protocol; len(protocol) != 1 || protocol[0] != "events" {
+		t.Errorf("Expected the events subprotocol to be selected, got %v", protocol)
+	}
+
+	fileID := uploadAs(t, server, "alice", "alice.txt", []byte("alice"))
+	for {
+		event := receiveEvent(t, conn)
+		if event.Type == EventUploadCompleted {
+			if event.FileID != fileID {
+				t.Errorf("Expected completion of %s, got %+v", fileID, event)
+			}
+			break
+		}
+	}
+}
+
+func TestEventStreamOrigin(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.API.CORS.AllowedOrigins = []string{"*", "https://app.example.com"}
+	server := newTestServerWithConfig(t, cfg)
+	ts := httptest.NewServer(server.GetRouter())
+	t.Cleanup(ts.Close)
+
+	// "*" lets any page call the API, but not open the event stream
+	evil := eventsConfig(t, ts, "https://evil.example.com", "?token="+eventToken(t, server, "alice"))
+	if conn, err := websocket.DialConfig(evil); err == nil {
+		conn.Close()
+		t.Errorf("Expected a connection from an unlisted origin to be refused")
+	}
+
+	dialEvents(t, server, ts, "alice")
+	dialEventsConfig(t, server, eventsConfig(t, ts, "https://app.example.com", "?token="+eventToken(t, server, "bob")), "bob")
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// expirySweepInterval is how often the reaper looks for files whose TTL has passed
+const expirySweepInterval = time.Minute
+
+// expired reports whether the file's TTL has passed as of now
+func expired(fileInfo *types.FileInfo, now time.Time) bool {
+	return fileInfo.ExpiresAt != nil && !now.Before(*fileInfo.ExpiresAt)
+}
+
+// parseTTL reads an optional TTL such as "24h", returning the expiry time it gives
+// from now or nil when no TTL is set
+func parseTTL(value string, now time.Time) (*time.Time, bool) {
+	if value == "" {
+		return nil, true
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return nil, false
+	}
+	expiresAt := now.Add(ttl)
+	return &expiresAt, true
+}
+
+// writeFileMissing answers a request for a file that activeFile did not return:
+// 410 when the file is still held but has expired, 404 otherwise
+func (s *Server) writeFileMissing(c *gin.Context, fileID string) {
+	if fileInfo, exists := s.lookupFile(fileID); exists && fileInfo.DeletedAt == nil && expired(fileInfo, time.Now()) {
+		writeError(c, http.StatusGone, "File has expired")
+		return
+	}
+	writeError(c, http.StatusNotFound, "File not found")
+}
+
+// reapExpired purges files whose TTL has passed as of now, returning the number of
+// files purged
+func (s *Server) reapExpired(now time.Time) int {
+	var reaped []*types.FileInfo
+	s.eachFile(func(fileInfo *types.FileInfo) {
+		if expired(fileInfo, now) {
+			reaped = append(reaped, fileInfo)
+		}
+	})
+
+	purged := 0
+	for _, fileInfo := range reaped {
+		if err := s.purgeFile(fileInfo); err != nil {
+			s.logger.WithError(err).WithField("file_id", fileInfo.ID).Warn("Failed to purge expired file")
+			continue
+		}
+		// Trashed files were already announced as deleted
+		if fileInfo.DeletedAt == nil {
+			s.publishDeleted(fileInfo)
+		}
+		purged++
+	}
+	return purged
+}
+
+// runReaper periodically purges expired files until stop is closed
+func (s *Server) runReaper(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if purged := s.reapExpired(now); purged > 0 {
+				s.logger.WithField("purged", purged).Info("Reaped expired files")
+			}
+		}
+	}
+}
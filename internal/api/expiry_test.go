@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// uploadWithTTL builds an upload request from alice carrying the given TTL
+func uploadWithTTL(t *testing.T, name string, content []byte, ttl string) *http.Request {
+	t.Helper()
+
+	req := newUploadRequest(t, name, content, map[string]string{"ttl": ttl})
+	req.Header.Set("X-Owner", "alice")
+	return req
+}
+
+// expireFile moves a file's expiry into the past
+func expireFile(server *Server, fileID string) {
+	past := time.Now().Add(-time.Second)
+	server.updateFiles(func() {
+		server.files[fileID].ExpiresAt = &past
+	})
+}
+
+func TestUploadTTL(t *testing.T) {
+	tests := []struct {
+		ttl        string
+		wantStatus int
+	}{
+		{"1h", http.StatusOK},
+		{"90s", http.StatusOK},
+		{"", http.StatusOK},
+		{"0s", http.StatusBadRequest},
+		{"-1h", http.StatusBadRequest},
+		{"tomorrow", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		server := newTestServer(t)
+		w := serve(server, uploadWithTTL(t, "ttl.txt", []byte("temporary"), tt.ttl))
+		if w.Code != tt.wantStatus {
+			t.Errorf("ttl %q: Expected status %d, got %d: %s", tt.ttl, tt.wantStatus, w.Code, w.Body.String())
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		fileInfo := server.files[fileIDFromResponse(t, w)]
+		if tt.ttl == "" {
+			if fileInfo.ExpiresAt != nil {
+				t.Errorf("ttl %q: Expected no expiry, got %v", tt.ttl, fileInfo.ExpiresAt)
+			}
+			continue
+		}
+		ttl, _ := time.ParseDuration(tt.ttl)
+		if fileInfo.ExpiresAt == nil || fileInfo.ExpiresAt.Sub(time.Now()) > ttl {
+			t.Errorf("ttl %q: Expected expiry within %s, got %v", tt.ttl, ttl, fileInfo.ExpiresAt)
+		}
+	}
+}
+
+func TestExpiredFileGone(t *testing.T) {
+	server := newTestServer(t)
+	fileID := fileIDFromResponse(t, serve(server, uploadWithTTL(t, "share.txt", []byte("for a while"), "1h")))
+
+	if w := getAs(server, "alice", "/api/v1/files/"+fileID); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 before expiry, got %d", w.Code)
+	}
+	if count := listCount(t, server, ""); count != 1 {
+		t.Errorf("Expected 1 listed file before expiry, got %d", count)
+	}
+
+	expireFile(server, fileID)
+
+	for _, path := range []string{"/api/v1/files/" + fileID, "/api/v1/files/" + fileID + "/info"} {
+		w := getAs(server, "alice", path)
+		if w.Code != http.StatusGone {
+			t.Errorf("%s: Expected status 410 after expiry, got %d", path, w.Code)
+			continue
+		}
+		if code := decodeError(t, w.Body.Bytes())["code"]; code != CodeGone {
+			t.Errorf("%s: Expected code %s, got %v", path, CodeGone, code)
+		}
+	}
+	if count := listCount(t, server, ""); count != 0 {
+		t.Errorf("Expected expired file to be left out of listings, got %d", count)
+	}
+}
+
+func TestReapExpired(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("reclaim me")
+	expiring := fileIDFromResponse(t, serve(server, uploadWithTTL(t, "expiring.txt", content, "1h")))
+	kept := uploadAs(t, server, "alice", "kept.txt", []byte("keep me"))
+	chunkID := server.files[expiring].Chunks[0].ID
+
+	usageBefore, _ := server.quotas.Usage("alice")
+
+	// Nothing is reaped before the TTL passes
+	if purged := server.reapExpired(time.Now()); purged != 0 {
+		t.Errorf("Expected nothing reaped before expiry, got %d", purged)
+	}
+
+	if purged := server.reapExpired(time.Now().Add(2 * time.Hour)); purged != 1 {
+		t.Errorf("Expected 1 file reaped after expiry, got %d", purged)
+	}
+	if server.storage.Exists(chunkID) {
+		t.Errorf("Expected chunks of the expired file to be deleted")
+	}
+	if _, exists := server.lookupFile(expiring); exists {
+		t.Errorf("Expected metadata of the expired file to be deleted")
+	}
+	if _, exists := server.lookupFile(kept); !exists {
+		t.Errorf("Expected file without a TTL to be kept")
+	}
+
+	usageAfter, _ := server.quotas.Usage("alice")
+	if usageBefore-usageAfter != int64(len(content)) {
+		t.Errorf("Expected %d bytes of quota reclaimed, got %d", len(content), usageBefore-usageAfter)
+	}
+
+	if w := getAs(server, "alice", "/api/v1/files/"+expiring); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a reaped file, got %d", w.Code)
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// The in-memory file metadata is shared by concurrent handlers and background sweeps.
+// All access goes through these helpers. Stored entries are never modified once they are
+// in s.files: a change stores an updated copy in place of the entry, and the slices and
+// maps of an entry are replaced rather than changed. A *FileInfo read from s.files is
+// therefore a snapshot that stays consistent after the lock is released, and lookupFile
+// and activeFile hand out copies so callers cannot change the stored entry by mistake.
+
+// lookupFile returns a copy of the metadata of the file with the given ID, including
+// trashed files
+func (s *Server) lookupFile(fileID string) (*types.FileInfo, bool) {
+	s.filesMu.RLock()
+	defer s.filesMu.RUnlock()
+
+	fileInfo, exists := s.files[fileID]
+	if !exists {
+		return nil, false
+	}
+	return copyFileInfo(fileInfo), true
+}
+
+// eachFile calls fn for every stored file, including trashed files. The read lock is
+// held throughout, so fn must not call back into methods that take it, and must not
+// modify the entries it is given.
+func (s *Server) eachFile(fn func(fileInfo *types.FileInfo)) {
+	s.filesMu.RLock()
+	defer s.filesMu.RUnlock()
+
+	for _, fileInfo := range s.files {
+		fn(fileInfo)
+	}
+}
+
+// fileCount returns the number of stored files, including trashed files
+func (s *Server) fileCount() int {
+	s.filesMu.RLock()
+	defer s.filesMu.RUnlock()
+
+	return len(s.files)
+}
+
+// fileIDMode returns the configured way of identifying new files
+func (s *Server) fileIDMode() types.FileIDMode {
+	mode, _ := types.ParseFileIDMode(s.config.Storage.FileIDMode) // Checked by Validate
+	return mode
+}
+
+// updateFiles runs fn with the write lock held, for changing stored metadata. fn must
+// store updated copies in s.files rather than modify the entries in it.
+func (s *Server) updateFiles(fn func()) {
+	s.filesMu.Lock()
+	defer s.filesMu.Unlock()
+
+	fn()
+}
+
+// modifyFile applies fn to a copy of the stored file with the given ID and, if fn reports
+// a change, stores the copy in place of the entry. It returns a copy of the file as stored
+// afterwards, and false if the file does not exist or fn left it unchanged.
+func (s *Server) modifyFile(fileID string, fn func(fileInfo *types.FileInfo) bool) (*types.FileInfo, bool) {
+	s.filesMu.Lock()
+	defer s.filesMu.Unlock()
+
+	stored, exists := s.files[fileID]
+	if !exists {
+		return nil, false
+	}
+
+	updated := copyFileInfo(stored)
+	if !fn(updated) {
+		return copyFileInfo(stored), false
+	}
+	s.files[fileID] = updated
+	return copyFileInfo(updated), true
+}
+
+// copyFileInfo returns a shallow copy of a file's metadata. Slices and maps are shared,
+// which is safe as long as they are replaced rather than changed.
+func copyFileInfo(fileInfo *types.FileInfo) *types.FileInfo {
+	copied := *fileInfo
+	return &copied
+}
+
+// fileLocks orders operations on the same file while leaving other files free. Reads of
+// a file's content share its lock while they take hold of its chunks, while appends,
+// metadata updates and deletes take it exclusively.
+type fileLocks struct {
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+// fileLock is the lock of one file, counting the holders and waiters still using it
+type fileLock struct {
+	sync.RWMutex
+	users int
+}
+
+// lock locks the file with the given ID exclusively, returning the function that unlocks it
+func (l *fileLocks) lock(fileID string) func() {
+	lock := l.acquire(fileID)
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.release(fileID, lock)
+	}
+}
+
+// rlock locks the file with the given ID for reading, returning the function that unlocks it
+func (l *fileLocks) rlock(fileID string) func() {
+	lock := l.acquire(fileID)
+	lock.RLock()
+	return func() {
+		lock.RUnlock()
+		l.release(fileID, lock)
+	}
+}
+
+// acquire returns the lock of a file, registering the caller as one of its users
+func (l *fileLocks) acquire(fileID string) *fileLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locks == nil {
+		l.locks = make(map[string]*fileLock)
+	}
+	lock := l.locks[fileID]
+	if lock == nil {
+		lock = &fileLock{}
+		l.locks[fileID] = lock
+	}
+	lock.users++
+	return lock
+}
+
+// release drops a user of a file's lock, forgetting the lock once nobody uses it
+func (l *fileLocks) release(fileID string, lock *fileLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lock.users--; lock.users == 0 {
+		delete(l.locks, fileID)
+	}
+}
+
+// rlockFile takes the shared lock of a file and returns a fresh copy of its metadata read
+// under it, so appends and deletes wait until the returned function is called. It returns
+// false, holding no lock, if the file is gone or trashed.
+func (s *Server) rlockFile(fileInfo *types.FileInfo) (*types.FileInfo, func(), bool) {
+	unlock := s.fileLocks.rlock(fileInfo.ID)
+	current, exists := s.lookupFile(fileInfo.ID)
+	if !exists || current.DeletedAt != nil {
+		unlock()
+		return nil, nil, false
+	}
+	return current, unlock, true
+}
+
+// readFile returns a fresh copy of a file's metadata for streaming its content, with its
+// chunks pinned until the returned function is called. The lock is only held while the
+// snapshot is taken, so a long download does not hold up appends and deletes; chunks
+// they release meanwhile are removed once the download lets go of them. It returns
+// false, pinning nothing, if the file is gone or trashed.
+func (s *Server) readFile(fileInfo *types.FileInfo) (*types.FileInfo, func(), bool) {
+	current, unlock, exists := s.rlockFile(fileInfo)
+	if !exists {
+		return nil, nil, false
+	}
+	defer unlock()
+
+	return current, s.chunkManager.PinChunks(current.Chunks), true
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestConcurrentUploadAndList(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.API.RateLimit.Enabled = false
+	server := newTestServerWithConfig(t, cfg)
+
+	const workers = 8
+	const perWorker = 10
+
+	// Requests are built up front since t.Fatal must not be called from other goroutines
+	uploads := make([][]*http.Request, workers)
+	for w := range uploads {
+		for i := 0; i < perWorker; i++ {
+			// Half the uploads share names so concurrent uploads also race on versioning
+			name := fmt.Sprintf("file-%d.txt", i)
+			if w%2 == 0 {
+				name = fmt.Sprintf("worker-%d-%d.txt", w, i)
+			}
+			content := []byte(fmt.Sprintf("worker %d file %d", w, i))
+			uploads[w] = append(uploads[w], newUploadRequest(t, name, content, nil))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(requests []*http.Request) {
+			defer wg.Done()
+			for _, req := range requests {
+				if resp := serve(server, req); resp.Code != http.StatusOK {
+					t.Errorf("Expected upload status 200, got %d: %s", resp.Code, resp.Body.String())
+				}
+			}
+		}(uploads[w])
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				for _, path := range []string{"/api/v1/files", "/api/v1/search?q=file", "/api/v1/node/usage"} {
+					if resp := serve(server, httptest.NewRequest(http.MethodGet, path, nil)); resp.Code != http.StatusOK {
+						t.Errorf("Expected %s status 200, got %d", path, resp.Code)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count := server.fileCount(); count != workers*perWorker {
+		t.Errorf("Expected %d files, got %d", workers*perWorker, count)
+	}
+
+	// Each shared name must have been numbered 1..n without duplicates
+	for i := 0; i < perWorker; i++ {
+		versions := server.versionsOf(&types.FileInfo{Name: fmt.Sprintf("file-%d.txt", i)}, true)
+		for j, version := range versions {
+			if version.Version != j+1 {
+				t.Errorf("Expected %s version %d, got %d", version.Name, j+1, version.Version)
+			}
+		}
+	}
+}
+
+// TestConcurrentChangesAndDownloads runs appends and metadata updates against downloads of
+// the same file. Run with -race it also checks that no handler reads stored metadata while
+// another changes it.
+func TestConcurrentChangesAndDownloads(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.API.RateLimit.Enabled = false
+	server := newTestServerWithConfig(t, cfg)
+	fileID := uploadAs(t, server, "alice", "live.log", []byte("start;"))
+	path := "/api/v1/files/" + fileID
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if w := appendAs(server, "alice", fileID, []byte("more;")); w.Code != http.StatusOK {
+				t.Errorf("Expected append status 200, got %d: %s", w.Code, w.Body.String())
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			body := fmt.Sprintf(`{"content_type": "text/plain-%d", "public": %v, "tags": {"round": "%d"}}`, i, i%2 == 0, i)
+			if w := patchAs(server, "alice", path, body); w.Code != http.StatusOK {
+				t.Errorf("Expected update status 200, got %d: %s", w.Code, w.Body.String())
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds*2; i++ {
+			w := getAs(server, "alice", path)
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected download status 200, got %d: %s", w.Code, w.Body.String())
+				continue
+			}
+
+			// Each download sees one whole state of the file, described by its headers
+			body := w.Body.String()
+			rest := strings.TrimPrefix(body, "start;")
+			if rest != strings.Repeat("more;", len(rest)/5) {
+				t.Errorf("Expected start; followed by whole appends, got %q", body)
+			}
+			if length := w.Header().Get("Content-Length"); length != strconv.Itoa(len(body)) {
+				t.Errorf("Expected Content-Length %d, got %s", len(body), length)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"`+types.CalculateHash([]byte(body))+`"` {
+				t.Errorf("Expected ETag of the content sent, got %s", etag)
+			}
+		}
+	}()
+	wg.Wait()
+
+	want := "start;" + strings.Repeat("more;", rounds)
+	if got := getAs(server, "alice", path).Body.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	fileInfo, _ := server.lookupFile(fileID)
+	if fileInfo.ContentType != fmt.Sprintf("text/plain-%d", rounds-1) || fileInfo.Tags["round"] != strconv.Itoa(rounds-1) {
+		t.Errorf("Expected the last update to stick, got %s with tags %v", fileInfo.ContentType, fileInfo.Tags)
+	}
+}
+
+func TestAppendDuringDownload(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "live.log", []byte("abcdef"))
+	fileInfo, _ := server.lookupFile(fileID)
+
+	// A download in progress holds the chunks of its snapshot, not the file's lock
+	snapshot, unpin, exists := server.readFile(fileInfo)
+	if !exists {
+		t.Fatal("Expected the file to be readable")
+	}
+	defer unpin()
+
+	done := make(chan int)
+	go func() { done <- appendAs(server, "alice", fileID, []byte("gh")).Code }()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Fatalf("Expected append status 200, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Append waited for the download to finish")
+	}
+
+	// The last chunk was replaced, yet the download still reads the old one
+	replaced := snapshot.Chunks[len(snapshot.Chunks)-1]
+	var buf bytes.Buffer
+	if err := server.chunkManager.RetrieveFileTo(context.Background(), snapshot, &buf); err != nil || buf.String() != "abcdef" {
+		t.Fatalf("Expected the snapshot to stream as abcdef, got %q (%v)", buf.String(), err)
+	}
+	if !server.storage.Exists(replaced.ID) {
+		t.Fatal("Expected the replaced chunk to stay while the download reads it")
+	}
+
+	unpin()
+	if server.storage.Exists(replaced.ID) {
+		t.Error("Expected the replaced chunk to be removed once the download is done")
+	}
+	if got := getAs(server, "alice", "/api/v1/files/"+fileID).Body.String(); got != "abcdefgh" {
+		t.Errorf("Expected abcdefgh after the append, got %q", got)
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// healthCheckTimeout bounds each backend probe so a hung backend reports unhealthy
+const healthCheckTimeout = 5 * time.Second
+
+// healthBucket is the metadata bucket used for probe records
+const healthBucket = "health"
+
+// healthCheck handles health check requests, probing the storage and metadata backends
+func (s *Server) healthCheck(c *gin.Context) {
+	components, healthy := s.checkComponents(c)
+
+	status := http.StatusOK
+	state := "healthy"
+	if !healthy {
+		status = http.StatusServiceUnavailable
+		state = "unhealthy"
+	}
+
+	c.JSON(status, gin.H{
+		"status":     state,
+		"components": components,
+		"timestamp":  time.Now(),
+		"version":    "1.0.0",
+	})
+}
+
+// readyCheck handles readiness probes: the server is ready while it is not shutting
+// down and its backends are healthy
+func (s *Server) readyCheck(c *gin.Context) {
+	select {
+	case <-s.done:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": "shutting down"})
+		return
+	default:
+	}
+
+	components, healthy := s.checkComponents(c)
+	if !healthy {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "components": components})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// checkComponents probes every backend, returning per-component status and whether all are healthy
+func (s *Server) checkComponents(c *gin.Context) (gin.H, bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]func() error{
+		"storage":  func() error { return s.probeStorage(ctx) },
+		"metadata": s.probeMetadata,
+	}
+
+	components := gin.H{}
+	healthy := true
+	for name, check := range checks {
+		if err := probe(check, healthCheckTimeout); err != nil {
+			s.log(c).WithError(err).WithField("component", name).Warn("Health check failed")
+			components[name] = gin.H{"status": "unhealthy", "error": err.Error()}
+			healthy = false
+			continue
+		}
+		components[name] = gin.H{"status": "healthy"}
+	}
+	return components, healthy
+}
+
+// probeStorage writes, reads back and deletes a canary object in the storage backend
+func (s *Server) probeStorage(ctx context.Context) error {
+	key, err := utils.GenerateRandomID(64)
+	if err != nil {
+		return err
+	}
+	canary := []byte("health check " + key)
+
+	if err := s.storage.Store(ctx, key, canary); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	data, err := s.storage.Retrieve(ctx, key)
+	deleteErr := s.storage.Delete(context.Background(), key) // Clean up even after a timeout
+	if err != nil {
+		return fmt.Errorf("read failed: %w", err)
+	}
+	if !bytes.Equal(data, canary) {
+		return fmt.Errorf("read back corrupted data")
+	}
+	if deleteErr != nil {
+		return fmt.Errorf("delete failed: %w", deleteErr)
+	}
+	return nil
+}
+
+// probeMetadata writes, reads back and deletes a canary record in the metadata store
+func (s *Server) probeMetadata() error {
+	key, err := utils.GenerateRandomID(32)
+	if err != nil {
+		return err
+	}
+
+	if err := s.metadata.Put(healthBucket, key, key); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	var value string
+	err = s.metadata.Get(healthBucket, key, &value)
+	deleteErr := s.metadata.Delete(healthBucket, key)
+	if err != nil {
+		return fmt.Errorf("read failed: %w", err)
+	}
+	if value != key {
+		return fmt.Errorf("read back corrupted data")
+	}
+	if deleteErr != nil {
+		return fmt.Errorf("delete failed: %w", deleteErr)
+	}
+	return nil
+}
+
+// probe runs a check, failing it if it does not finish within timeout
+func probe(check func() error, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() { result <- check() }()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
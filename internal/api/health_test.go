@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// failingStorage is a storage backend whose writes fail while broken is set
+type failingStorage struct {
+	storage.Storage
+	broken bool
+}
+
+func (f *failingStorage) Store(ctx context.Context, key string, data []byte) error {
+	if f.broken {
+		return errors.New("disk full")
+	}
+	return f.Storage.Store(ctx, key, data)
+}
+
+// failingStore is a metadata store whose writes fail while broken is set
+type failingStore struct {
+	metadata.Store
+	broken bool
+}
+
+func (f *failingStore) Put(bucket, key string, value interface{}) error {
+	if f.broken {
+		return errors.New("store unavailable")
+	}
+	return f.Store.Put(bucket, key, value)
+}
+
+// newHealthTestServer creates a server whose storage and metadata backends can be broken
+func newHealthTestServer(t *testing.T) (*Server, *failingStorage, *failingStore) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fileStorage, err := storage.NewFileStorage(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	backend := &failingStorage{Storage: fileStorage}
+	store := &failingStore{Store: metadata.NewMemoryStore()}
+	chunkManager := storage.NewChunkManager(backend, key, 4, logger)
+	return NewServer(config.DefaultConfig(), backend, chunkManager, store, logger), backend, store
+}
+
+// componentStatus returns the status reported for each component
+func componentStatus(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+
+	var result struct {
+		Components map[string]struct {
+			Status string `json:"status"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to parse health response: %v", err)
+	}
+
+	status := make(map[string]string)
+	for name, component := range result.Components {
+		status[name] = component.Status
+	}
+	return status
+}
+
+func TestHealthCheck(t *testing.T) {
+	server, backend, store := newHealthTestServer(t)
+
+	tests := []struct {
+		name           string
+		brokenStorage  bool
+		brokenMetadata bool
+		expected       int
+		storage        string
+		metadata       string
+	}{
+		{"healthy", false, false, http.StatusOK, "healthy", "healthy"},
+		{"storage failing", true, false, http.StatusServiceUnavailable, "unhealthy", "healthy"},
+		{"metadata failing", false, true, http.StatusServiceUnavailable, "healthy", "unhealthy"},
+		{"both failing", true, true, http.StatusServiceUnavailable, "unhealthy", "unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.broken = tt.brokenStorage
+			store.broken = tt.brokenMetadata
+
+			w := getAs(server, "", "/api/v1/health")
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+
+			status := componentStatus(t, w.Body.Bytes())
+			if status["storage"] != tt.storage || status["metadata"] != tt.metadata {
+				t.Errorf("Expected storage %s and metadata %s, got %v", tt.storage, tt.metadata, status)
+			}
+
+			if w := getAs(server, "", "/api/v1/ready"); w.Code != tt.expected {
+				t.Errorf("Expected readiness status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+
+	// Probes clean up after themselves
+	backend.broken = false
+	getAs(server, "", "/api/v1/health")
+	if keys, _ := backend.List(); len(keys) != 0 {
+		t.Errorf("Expected no canary objects left, got %v", keys)
+	}
+}
+
+func TestReadyDuringShutdown(t *testing.T) {
+	server, _, _ := newHealthTestServer(t)
+
+	if w := getAs(server, "", "/api/v1/ready"); w.Code != http.StatusOK {
+		t.Fatalf("Expected ready server, got status %d", w.Code)
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Failed to shut down: %v", err)
+	}
+	if w := getAs(server, "", "/api/v1/ready"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while shutting down, got %d", w.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// updateChunk records the new layout of a re-encrypted chunk on every file referencing it
+func (s *Server) updateChunk(updated types.ChunkInfo) {
+	s.updateFiles(func() {
+		for fileID, fileInfo := range s.files {
+			var chunks []types.ChunkInfo
+			for i, chunkInfo := range fileInfo.Chunks {
+				if chunkInfo.ID != updated.ID {
+					continue
+				}
+				if chunks == nil {
+					chunks = append([]types.ChunkInfo(nil), fileInfo.Chunks...)
+				}
+				chunks[i].Checksum = updated.Checksum
+				chunks[i].KeyVersion = updated.KeyVersion
+			}
+			if chunks != nil {
+				changed := copyFileInfo(fileInfo)
+				changed.Chunks = chunks
+				s.files[fileID] = changed
+			}
+		}
+	})
+}
+
+// getKeys handles reporting the master key versions and the re-encryption progress
+func (s *Server) getKeys(c *gin.Context) {
+	keys := s.chunkManager.KeyRing()
+	current, _ := keys.Current()
+
+	c.JSON(http.StatusOK, gin.H{
+		"key_version": current,
+		"versions":    keys.Versions(),
+		"rekey":       s.rekeyer.Status(),
+	})
+}
+
+// rotateKey handles generating a new master key version. New chunks are encrypted under
+// it at once, while existing chunks are re-encrypted in the background.
+func (s *Server) rotateKey(c *gin.Context) {
+	keys := s.chunkManager.KeyRing()
+	version, err := keys.Rotate(s.config.Crypto.KeyFile)
+	if err != nil {
+		s.log(c).WithError(err).Error("Failed to rotate master key")
+		writeError(c, http.StatusInternalServerError, "Failed to rotate key")
+		return
+	}
+
+	s.log(c).WithFields(logrus.Fields{
+		"key_version": version,
+	}).Info("Master key rotated")
+
+	c.JSON(http.StatusOK, gin.H{
+		"key_version": version,
+		"versions":    keys.Versions(),
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+func TestRotateKey(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.API.AdminToken = "secret"
+	cfg.Crypto.KeyFile = filepath.Join(t.TempDir(), "master.key")
+	server := newTestServerWithConfig(t, cfg)
+
+	content := []byte("encrypted under the first key")
+	fileID := uploadTestFile(t, server, "before.txt", content)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys/rotate", nil)
+	if w := serve(server, req); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without admin token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/admin/keys/rotate", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := serve(server, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var rotated struct {
+		KeyVersion uint32 `json:"key_version"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &rotated); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if rotated.KeyVersion != 2 {
+		t.Errorf("Expected key version 2, got %d", rotated.KeyVersion)
+	}
+
+	// Files written before the rotation download until and after they are re-encrypted
+	w = serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil))
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("Expected %q before re-encryption, got %q", content, w.Body.String())
+	}
+
+	if report := server.rekeyer.Rekey(); report.Rekeyed == 0 {
+		t.Errorf("Expected chunks to be re-encrypted")
+	}
+	fileInfo, _ := server.lookupFile(fileID)
+	for _, chunkInfo := range fileInfo.Chunks {
+		if chunkInfo.KeyVersion != 2 {
+			t.Errorf("Expected chunk %d recorded under key version 2, got %d", chunkInfo.Index, chunkInfo.KeyVersion)
+		}
+	}
+
+	w = serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil))
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("Expected %q after re-encryption, got %q", content, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/admin/keys", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w = serve(server, req)
+	var status struct {
+		KeyVersion uint32   `json:"key_version"`
+		Versions   []uint32 `json:"versions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if status.KeyVersion != 2 || len(status.Versions) != 2 {
+		t.Errorf("Expected version 2 of [1 2], got %d of %v", status.KeyVersion, status.Versions)
+	}
+}
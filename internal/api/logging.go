@@ -0,0 +1,77 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDHeader carries the ID correlating a request across client and server logs
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key holding the request ID
+const requestIDKey = "request_id"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// requestIDMiddleware reuses the caller's X-Request-ID or generates one, storing it
+// in the context and echoing it in the response
+func (s *Server) requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			generated, err := utils.GenerateRandomID(32)
+			if err != nil {
+				s.logger.WithError(err).Warn("Failed to generate request ID")
+			}
+			id = generated
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// accessLogMiddleware writes a structured log line for every request
+func (s *Server) accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		s.log(c).WithFields(logrus.Fields{
+			"method":    c.Request.Method,
+			"path":      path,
+			"status":    c.Writer.Status(),
+			"latency":   time.Since(start).String(),
+			"client_ip": c.ClientIP(),
+			"bytes":     c.Writer.Size(),
+			"owner":     s.principal(c),
+		}).Info("Request handled")
+	}
+}
+
+// log returns a logger entry tagged with the ID of the request
+func (s *Server) log(c *gin.Context) *logrus.Entry {
+	return s.logger.WithField(requestIDKey, c.GetString(requestIDKey))
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
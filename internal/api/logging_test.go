@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestID(t *testing.T) {
+	server := newTestServer(t)
+
+	var logs bytes.Buffer
+	server.logger.SetOutput(&logs)
+	server.logger.SetFormatter(&logrus.JSONFormatter{})
+
+	// A supplied ID round-trips and tags the request's log lines
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files/missing/info", nil)
+	req.Header.Set(requestIDHeader, "client-req-42")
+	w := serve(server, req)
+	if id := w.Header().Get(requestIDHeader); id != "client-req-42" {
+		t.Errorf("Expected request ID client-req-42, got %q", id)
+	}
+
+	var entry map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse log line %q: %v", line, err)
+		}
+		if entry["msg"] == "Request handled" {
+			break
+		}
+	}
+	if entry["request_id"] != "client-req-42" {
+		t.Errorf("Expected access log to carry the request ID, got %v", entry)
+	}
+	if entry["status"] != float64(http.StatusNotFound) || entry["path"] != "/api/v1/files/missing/info" {
+		t.Errorf("Expected access log to record status and path, got %v", entry)
+	}
+
+	// Missing or unsafe IDs are replaced with generated ones
+	for _, supplied := range []string{"", "bad id\nwith newline", strings.Repeat("x", maxRequestIDLength+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		if supplied != "" {
+			req.Header.Set(requestIDHeader, supplied)
+		}
+		id := serve(server, req).Header().Get(requestIDHeader)
+		if len(id) != 32 || id == supplied {
+			t.Errorf("Expected a generated request ID for %q, got %q", supplied, id)
+		}
+	}
+
+	first := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)).Header().Get(requestIDHeader)
+	second := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)).Header().Get(requestIDHeader)
+	if first == second {
+		t.Errorf("Expected distinct generated request IDs, got %s twice", first)
+	}
+}
+
+func TestHandlerLogsCarryRequestID(t *testing.T) {
+	server := newTestServer(t)
+
+	var logs bytes.Buffer
+	server.logger.SetOutput(&logs)
+	server.logger.SetFormatter(&logrus.JSONFormatter{})
+
+	req := newUploadRequest(t, "a.txt", []byte("data"), nil)
+	req.Header.Set(requestIDHeader, "upload-1")
+	fileIDFromResponse(t, serve(server, req))
+
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]interface{}
+		json.Unmarshal([]byte(line), &entry)
+		if entry["msg"] == "File uploaded successfully" {
+			found = true
+			if entry["request_id"] != "upload-1" {
+				t.Errorf("Expected handler log to carry the request ID, got %v", entry)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected an upload log line, got %s", logs.String())
+	}
+}
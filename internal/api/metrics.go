@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metrics"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// serverMetrics holds the metrics collected by the API server
+type serverMetrics struct {
+	registry        *metrics.Registry
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	fileOperations  *metrics.CounterVec
+}
+
+// fileOperations maps routes to the file operation they perform
+var fileOperations = map[string]string{
+	"POST /api/v1/files":                "upload",
+	"POST /api/v1/uploads/:id/complete": "upload",
+	"GET /api/v1/files/:id":             "download",
+	"DELETE /api/v1/files/:id":          "delete",
+	"POST /api/v1/files/:id/restore":    "restore",
+	"GET /api/v1/files":                 "list",
+	"GET /api/v1/files/:id/info":        "info",
+	"GET /api/v1/files/:id/versions":    "versions",
+	"PUT /api/v1/uploads/:id/:index":    "upload_chunk",
+}
+
+// newServerMetrics registers the server metrics, including gauges read from the server state
+func newServerMetrics(s *Server) *serverMetrics {
+	registry := metrics.NewRegistry()
+
+	m := &serverMetrics{
+		registry: registry,
+		requests: registry.NewCounterVec("dcs_http_requests_total",
+			"Total HTTP requests by method, route and status class", "method", "route", "status"),
+		requestDuration: registry.NewHistogramVec("dcs_http_request_duration_seconds",
+			"HTTP request duration in seconds", metrics.DefaultBuckets, "method", "route"),
+		fileOperations: registry.NewCounterVec("dcs_file_operations_total",
+			"Total file operations by operation and result", "operation", "result"),
+	}
+
+	registry.NewGaugeFunc("dcs_storage_used_bytes", "Bytes used by the storage backend", func() float64 {
+		usage, err := s.storage.GetUsage()
+		if err != nil {
+			s.logger.WithError(err).Warn("Failed to get storage usage for metrics")
+		}
+		return float64(usage)
+	})
+	registry.NewGaugeFunc("dcs_files", "Number of stored files, excluding trashed files", func() float64 {
+		count := 0
+		s.eachFile(func(fileInfo *types.FileInfo) {
+			if fileInfo.DeletedAt == nil {
+				count++
+			}
+		})
+		return float64(count)
+	})
+
+	return m
+}
+
+// metricsMiddleware records request counts, latencies and file operation results
+func (s *Server) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		status := c.Writer.Status()
+
+		errs := []error{
+			s.metrics.requests.Inc(method, route, fmt.Sprintf("%dxx", status/100)),
+			s.metrics.requestDuration.Observe(time.Since(start).Seconds(), method, route),
+		}
+
+		if operation, exists := fileOperations[method+" "+route]; exists {
+			result := "success"
+			if status >= http.StatusBadRequest {
+				result = "error"
+			}
+			errs = append(errs, s.metrics.fileOperations.Inc(operation, result))
+		}
+
+		if err := errors.Join(errs...); err != nil {
+			s.log(c).WithError(err).Warn("Failed to record request metrics")
+		}
+	}
+}
+
+// serveMetrics handles Prometheus scrapes
+func (s *Server) serveMetrics(c *gin.Context) {
+	s.metrics.registry.Handler().ServeHTTP(c.Writer, c.Request)
+}
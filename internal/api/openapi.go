@@ -0,0 +1,580 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// openAPIVersion is the version of the OpenAPI specification the document follows
+const openAPIVersion = "3.0.3"
+
+// swaggerUIVersion is the Swagger UI release loaded by the docs page
+const swaggerUIVersion = "5.17.14"
+
+// routeParam matches the :name segments of gin routes
+var routeParam = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// apiOperation documents one route of the API
+type apiOperation struct {
+	Method      string
+	Path        string // gin route, e.g. /api/v1/files/:id
+	Summary     string
+	Tag         string
+	Admin       bool    // Requires X-Admin-Token
+	Params      []gin.H // Query and header parameters; path parameters are added from Path
+	Body        gin.H   // JSON request body schema, nil when there is none
+	Form        gin.H   // multipart/form-data request body schema
+	RawBody     bool    // Request body is raw bytes
+	Status      int     // Success status
+	Response    gin.H   // JSON success response schema
+	ContentType string  // Success response media type when it is not JSON
+	Errors      []int   // Error statuses answered with the error envelope
+}
+
+// Reusable schemas for the plain values in the API
+var (
+	stringSchema   = gin.H{"type": "string"}
+	integerSchema  = gin.H{"type": "integer", "format": "int64"}
+	booleanSchema  = gin.H{"type": "boolean"}
+	dateTimeSchema = gin.H{"type": "string", "format": "date-time"}
+	stringMap      = gin.H{"type": "object", "additionalProperties": stringSchema}
+)
+
+// objectSchema returns the schema of an object with the given properties
+func objectSchema(properties gin.H, required ...string) gin.H {
+	schema := gin.H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// arraySchema returns the schema of an array of items
+func arraySchema(items gin.H) gin.H {
+	return gin.H{"type": "array", "items": items}
+}
+
+// schemaRef refers to a schema in the document's components
+func schemaRef(name string) gin.H {
+	return gin.H{"$ref": "#/components/schemas/" + name}
+}
+
+// queryParam documents a query string parameter
+func queryParam(name string, schema gin.H, description string) gin.H {
+	return gin.H{"name": name, "in": "query", "schema": schema, "description": description}
+}
+
+// schemaRegistry derives component schemas from Go types through their JSON tags
+type schemaRegistry struct {
+	schemas gin.H
+}
+
+// ref returns the schema of t, registering named structs as components
+func (r *schemaRegistry) ref(t reflect.Type) gin.H {
+	if t.Kind() == reflect.Pointer {
+		return r.ref(t.Elem())
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return dateTimeSchema
+	case reflect.TypeOf(time.Duration(0)):
+		return gin.H{"type": "integer", "format": "int64", "description": "Nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return stringSchema
+	case reflect.Bool:
+		return booleanSchema
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return gin.H{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return integerSchema
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return gin.H{"type": "string", "format": "byte"}
+		}
+		return arraySchema(r.ref(t.Elem()))
+	case reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": r.ref(t.Elem())}
+	case reflect.Struct:
+		if _, exists := r.schemas[t.Name()]; !exists {
+			r.schemas[t.Name()] = gin.H{} // Placeholder so self-references terminate
+			r.schemas[t.Name()] = r.structSchema(t)
+		}
+		return schemaRef(t.Name())
+	}
+	return gin.H{}
+}
+
+// structSchema builds the object schema of a struct from its exported JSON fields.
+// Fields without omitempty are required.
+func (r *schemaRegistry) structSchema(t reflect.Type) gin.H {
+	properties := gin.H{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = r.ref(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	return objectSchema(properties, required...)
+}
+
+// buildOpenAPI assembles the OpenAPI document describing the operations
+func buildOpenAPI(operations []apiOperation) gin.H {
+	registry := &schemaRegistry{schemas: gin.H{}}
+	for _, value := range []interface{}{
+		types.FileInfo{}, types.NodeInfo{}, APIError{}, Event{},
+		UsageBreakdown{}, storage.ScrubStatus{}, storage.GCStatus{}, storage.RekeyStatus{}, storage.QuotaStatus{},
+	} {
+		registry.ref(reflect.TypeOf(value))
+	}
+	registry.schemas["ErrorResponse"] = objectSchema(gin.H{"error": schemaRef("APIError")}, "error")
+
+	paths := gin.H{}
+	for _, op := range operations {
+		path := routeParam.ReplaceAllString(op.Path, "{$1}")
+		item, _ := paths[path].(gin.H)
+		if item == nil {
+			item = gin.H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = op.document()
+	}
+
+	return gin.H{
+		"openapi": openAPIVersion,
+		"info": gin.H{
+			"title":       "Distributed Cloud Storage API",
+			"version":     "1.0.0",
+			"description": "HTTP API of a distributed cloud storage node. Errors use the envelope {\"error\": APIError}.",
+		},
+		"servers": []gin.H{{"url": "/"}},
+		"paths":   paths,
+		"components": gin.H{
+			"schemas": registry.schemas,
+			"securitySchemes": gin.H{
+				"owner": gin.H{"type": "apiKey", "in": "header", "name": "X-Owner", "description": "Caller identity when no client certificate is presented"},
+				"admin": gin.H{"type": "apiKey", "in": "header", "name": "X-Admin-Token"},
+			},
+		},
+		"security": []gin.H{{"owner": []string{}}, {}},
+	}
+}
+
+// document builds the OpenAPI operation object
+func (op apiOperation) document() gin.H {
+	operation := gin.H{
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+		"operationId": operationID(op.Method, op.Path),
+	}
+
+	params := []gin.H{}
+	for _, match := range routeParam.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, gin.H{"name": match[1], "in": "path", "required": true, "schema": stringSchema})
+	}
+	params = append(params, op.Params...)
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+
+	switch {
+	case op.Body != nil:
+		operation["requestBody"] = gin.H{"required": true, "content": gin.H{"application/json": gin.H{"schema": op.Body}}}
+	case op.Form != nil:
+		operation["requestBody"] = gin.H{"required": true, "content": gin.H{"multipart/form-data": gin.H{"schema": op.Form}}}
+	case op.RawBody:
+		operation["requestBody"] = gin.H{"required": true, "content": gin.H{"application/octet-stream": gin.H{"schema": gin.H{"type": "string", "format": "binary"}}}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := gin.H{"description": http.StatusText(status)}
+	switch {
+	case op.ContentType != "":
+		success["content"] = gin.H{op.ContentType: gin.H{"schema": gin.H{"type": "string", "format": "binary"}}}
+	case op.Response != nil:
+		success["content"] = gin.H{"application/json": gin.H{"schema": op.Response}}
+	}
+	responses := gin.H{strconv.Itoa(status): success}
+
+	errorContent := gin.H{"application/json": gin.H{"schema": schemaRef("ErrorResponse")}}
+	for _, code := range op.Errors {
+		responses[strconv.Itoa(code)] = gin.H{"description": http.StatusText(code), "content": errorContent}
+	}
+	operation["responses"] = responses
+
+	if op.Admin {
+		operation["security"] = []gin.H{{"admin": []string{}}}
+	}
+	return operation
+}
+
+// operationID derives a unique operation ID from a method and route
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(strings.TrimPrefix(path, "/api/v1"), func(r rune) bool {
+		return r == '/' || r == '-' || r == ':' || r == '.' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// apiOperations documents every route served by the API
+func apiOperations() []apiOperation {
+	fileRef := schemaRef("FileInfo")
+	message := objectSchema(gin.H{"message": stringSchema}, "message")
+	uploaded := objectSchema(gin.H{
+		"file_id":   stringSchema,
+		"file_name": stringSchema,
+		"size":      integerSchema,
+		"hash":      stringSchema,
+	}, "file_id", "file_name", "size", "hash")
+	fileEntry := objectSchema(gin.H{
+		"id":           stringSchema,
+		"name":         stringSchema,
+		"size":         integerSchema,
+		"content_type": stringSchema,
+		"created_at":   dateTimeSchema,
+		"owner":        stringSchema,
+		"version":      integerSchema,
+		"deleted_at":   dateTimeSchema,
+		"expires_at":   dateTimeSchema,
+		"tags":         stringMap,
+	})
+	batchBody := objectSchema(gin.H{"ids": arraySchema(stringSchema)}, "ids")
+	batchResult := objectSchema(gin.H{
+		"id":      stringSchema,
+		"status":  integerSchema,
+		"message": stringSchema,
+		"file":    fileRef,
+		"error":   schemaRef("APIError"),
+	}, "id", "status")
+	deleteParams := []gin.H{
+		queryParam("permanent", booleanSchema, "Delete immediately instead of moving to the trash"),
+		queryParam("all_versions", booleanSchema, "Delete every version of the file"),
+	}
+
+	return []apiOperation{
+		{Method: http.MethodGet, Path: "/metrics", Summary: "Prometheus metrics", Tag: "node",
+			ContentType: "text/plain"},
+		{Method: http.MethodGet, Path: "/docs", Summary: "Swagger UI for this document", Tag: "docs",
+			ContentType: "text/html"},
+		{Method: http.MethodGet, Path: "/api/v1/openapi.json", Summary: "This OpenAPI document", Tag: "docs",
+			Response: gin.H{"type": "object"}},
+
+		// Files
+		{Method: http.MethodPost, Path: "/api/v1/files", Summary: "Upload a file", Tag: "files",
+			Form: objectSchema(gin.H{
+				"file":             gin.H{"type": "string", "format": "binary"},
+				"name":             stringSchema,
+				"public":           booleanSchema,
+				"tag":              arraySchema(stringSchema),
+				"client_encrypted": booleanSchema,
+				"key_salt":         stringSchema,
+				"chunk_size":       integerSchema,
+				"ttl":              gin.H{"type": "string", "description": "Go duration after which the file is deleted, e.g. 24h"},
+			}, "file"),
+			Response: uploaded,
+			Errors:   []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusServiceUnavailable, http.StatusInsufficientStorage, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/api/v1/files/:id", Summary: "Download a file", Tag: "files",
+			Params: []gin.H{
+				queryParam("version", integerSchema, "Download this version of the file"),
+				{"name": "Range", "in": "header", "schema": stringSchema, "description": "Single byte range, answered with 206"},
+				{"name": "If-Range", "in": "header", "schema": stringSchema, "description": "ETag the range applies to; the whole file is sent when it has changed"},
+			},
+			ContentType: "application/octet-stream",
+			Errors:      []int{http.StatusForbidden, http.StatusNotFound, http.StatusGone, http.StatusRequestedRangeNotSatisfiable, http.StatusInternalServerError}},
+		{Method: http.MethodDelete, Path: "/api/v1/files/:id", Summary: "Delete a file", Tag: "files",
+			Params: deleteParams, Response: message,
+			Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/api/v1/files", Summary: "List files", Tag: "files",
+			Params: []gin.H{
+				queryParam("include_deleted", booleanSchema, "Include files in the trash"),
+				queryParam("tag", arraySchema(stringSchema), "key=value tag that must match; repeatable"),
+			},
+			Response: objectSchema(gin.H{"files": arraySchema(fileEntry), "count": integerSchema}, "files", "count"),
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/api/v1/files/:id/info", Summary: "Get file metadata", Tag: "files",
+			Response: fileRef, Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusGone}},
+		{Method: http.MethodPatch, Path: "/api/v1/files/:id", Summary: "Update file metadata", Tag: "files",
+			Body: objectSchema(gin.H{
+				"name":         stringSchema,
+				"content_type": stringSchema,
+				"public":       booleanSchema,
+				"tags":         stringMap,
+			}),
+			Response: fileRef,
+			Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/api/v1/files/:id/append", Summary: "Append data to a file", Tag: "files",
+			RawBody:  true,
+			Response: fileRef,
+			Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusGone, http.StatusRequestEntityTooLarge, http.StatusServiceUnavailable, http.StatusInsufficientStorage, http.StatusInternalServerError}},
+		{Method: http.MethodPost, Path: "/api/v1/files/:id/copy", Summary: "Copy a file without storing its bytes again", Tag: "files",
+			Body:     objectSchema(gin.H{"name": stringSchema}),
+			Status:   http.StatusCreated,
+			Response: fileRef,
+			Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusGone, http.StatusRequestEntityTooLarge, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/api/v1/files/:id/versions", Summary: "List the versions of a file", Tag: "files",
+			Response: objectSchema(gin.H{
+				"name": stringSchema,
+				"versions": arraySchema(objectSchema(gin.H{
+					"id":               stringSchema,
+					"version":          integerSchema,
+					"previous_version": stringSchema,
+					"size":             integerSchema,
+					"hash":             stringSchema,
+					"created_at":       dateTimeSchema,
+				})),
+				"count": integerSchema,
+			}, "name", "versions", "count"),
+			Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/api/v1/files/:id/checksum", Summary: "Get or recompute a file checksum", Tag: "files",
+			Params: []gin.H{
+				queryParam("algo", gin.H{"type": "string", "enum": supportedChecksumAlgorithms()}, "Hash algorithm, by default the one the file was stored with"),
+				queryParam("recompute", booleanSchema, "Rehash the stored chunks and compare with the recorded hash"),
+			},
+			Response: objectSchema(gin.H{
+				"file_id":    stringSchema,
+				"algorithm":  stringSchema,
+				"size":       integerSchema,
+				"checksum":   stringSchema,
+				"recomputed": booleanSchema,
+				"verified":   booleanSchema,
+				"error":      stringSchema,
+			}, "file_id", "algorithm", "size"),
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/api/v1/files/:id/chunks", Summary: "List a file's chunk layout", Tag: "files",
+			Response: objectSchema(gin.H{
+				"file_id": stringSchema,
+				"count":   integerSchema,
+				"chunks":  arraySchema(schemaRef("ChunkInfo")),
+			}, "file_id", "count", "chunks"),
+			Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusGone}},
+		{Method: http.MethodPost, Path: "/api/v1/files/:id/restore", Summary: "Restore a file from the trash", Tag: "files",
+			Response: fileRef, Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/api/v1/files/batch-delete", Summary: "Delete many files", Tag: "files",
+			Params: deleteParams, Body: batchBody,
+			Response: objectSchema(gin.H{
+				"results":   arraySchema(batchResult),
+				"succeeded": integerSchema,
+				"failed":    integerSchema,
+			}, "results", "succeeded", "failed"),
+			Errors: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge}},
+		{Method: http.MethodPost, Path: "/api/v1/files/batch-info", Summary: "Get the metadata of many files", Tag: "files",
+			Body: batchBody,
+			Response: objectSchema(gin.H{
+				"results": arraySchema(batchResult),
+				"found":   integerSchema,
+				"failed":  integerSchema,
+			}, "results", "found", "failed"),
+			Errors: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge}},
+		{Method: http.MethodGet, Path: "/api/v1/files/archive", Summary: "Download many files as one archive", Tag: "files",
+			Params: []gin.H{
+				queryParam("ids", stringSchema, "Comma-separated IDs of the files to include"),
+				queryParam("q", stringSchema, "Search query selecting the caller's files to include, instead of ids"),
+				queryParam("format", gin.H{"type": "string", "enum": []string{archiveFormatTarGz, archiveFormatZip}}, "Archive format, tar.gz by default"),
+			},
+			ContentType: "application/octet-stream",
+			Errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge}},
+		{Method: http.MethodGet, Path: "/api/v1/search", Summary: "Search the caller's files", Tag: "files",
+			Params: []gin.H{
+				queryParam("q", stringSchema, "Whitespace-separated prefix:, tag:key=value and free-text terms"),
+				queryParam("limit", integerSchema, "Maximum results"),
+				queryParam("offset", integerSchema, "Results to skip"),
+			},
+			Response: objectSchema(gin.H{
+				"results": arraySchema(fileEntry),
+				"count":   integerSchema,
+				"total":   integerSchema,
+				"limit":   integerSchema,
+				"offset":  integerSchema,
+			}, "results", "count", "total", "limit", "offset"),
+			Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+
+		// Share links
+		{Method: http.MethodPost, Path: "/api/v1/files/:id/share", Summary: "Create a share link", Tag: "shares",
+			Body:   objectSchema(gin.H{"ttl": gin.H{"type": "string", "description": "Go duration, e.g. 24h"}, "single_use": booleanSchema}),
+			Status: http.StatusCreated,
+			Response: objectSchema(gin.H{
+				"share_id":   stringSchema,
+				"token":      stringSchema,
+				"url":        stringSchema,
+				"expires_at": dateTimeSchema,
+				"single_use": booleanSchema,
+			}, "share_id", "token", "url", "expires_at", "single_use"),
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+		{Method: http.MethodDelete, Path: "/api/v1/files/:id/share/:share_id", Summary: "Revoke a share link", Tag: "shares",
+			Response: message, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/api/v1/shared/:token", Summary: "Download a file through a share link", Tag: "shares",
+			ContentType: "application/octet-stream",
+			Errors:      []int{http.StatusForbidden, http.StatusNotFound}},
+
+		// Resumable uploads
+		{Method: http.MethodPost, Path: "/api/v1/uploads", Summary: "Start a resumable upload", Tag: "uploads",
+			Body: objectSchema(gin.H{
+				"name":         stringSchema,
+				"size":         integerSchema,
+				"content_type": stringSchema,
+				"public":       booleanSchema,
+				"chunk_size":   integerSchema,
+			}, "name", "size"),
+			Status:   http.StatusCreated,
+			Response: objectSchema(gin.H{"upload_id": stringSchema, "chunk_size": integerSchema, "chunk_count": integerSchema, "expires_at": dateTimeSchema}, "upload_id", "chunk_size", "chunk_count"),
+			Errors:   []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusInsufficientStorage}},
+		{Method: http.MethodGet, Path: "/api/v1/uploads/:id", Summary: "Get the progress of an upload", Tag: "uploads",
+			Response: objectSchema(gin.H{
+				"upload_id":   stringSchema,
+				"file_name":   stringSchema,
+				"size":        integerSchema,
+				"chunk_size":  integerSchema,
+				"chunk_count": integerSchema,
+				"received":    arraySchema(integerSchema),
+				"missing":     arraySchema(integerSchema),
+				"expires_at":  dateTimeSchema,
+			}, "upload_id", "file_name", "size", "chunk_size", "chunk_count", "received", "missing"),
+			Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		{Method: http.MethodPut, Path: "/api/v1/uploads/:id/:index", Summary: "Upload one chunk", Tag: "uploads",
+			RawBody:  true,
+			Response: objectSchema(gin.H{"upload_id": stringSchema, "index": integerSchema}, "upload_id", "index"),
+			Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusServiceUnavailable}},
+		{Method: http.MethodPost, Path: "/api/v1/uploads/:id/complete", Summary: "Assemble a finished upload", Tag: "uploads",
+			Response: uploaded,
+			Errors:   []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodDelete, Path: "/api/v1/uploads/:id", Summary: "Abort an upload", Tag: "uploads",
+			Response: message, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+
+		// Node
+		{Method: http.MethodGet, Path: "/api/v1/node/info", Summary: "Get node information", Tag: "node",
+			Response: schemaRef("NodeInfo")},
+		{Method: http.MethodGet, Path: "/api/v1/node/stats", Summary: "Get node statistics", Tag: "node",
+			Response: objectSchema(gin.H{
+				"storage_usage":  integerSchema,
+				"file_count":     integerSchema,
+				"metadata_count": integerSchema,
+				"uptime":         integerSchema,
+				"status":         integerSchema,
+			})},
+		{Method: http.MethodGet, Path: "/api/v1/node/scrub", Summary: "Get integrity scrub status", Tag: "node",
+			Response: schemaRef("ScrubStatus")},
+		{Method: http.MethodGet, Path: "/api/v1/node/gc", Summary: "Get garbage collection status", Tag: "node",
+			Response: schemaRef("GCStatus")},
+		{Method: http.MethodGet, Path: "/api/v1/node/peers", Summary: "List known peers", Tag: "node",
+			Response: objectSchema(gin.H{"peers": arraySchema(schemaRef("NodeInfo")), "count": integerSchema, "online": integerSchema}, "peers", "count", "online")},
+		{Method: http.MethodGet, Path: "/api/v1/node/placement/:chunk_id", Summary: "Get the nodes a chunk is placed on", Tag: "node",
+			Response: objectSchema(gin.H{"chunk_id": stringSchema, "nodes": arraySchema(stringSchema)}, "chunk_id", "nodes")},
+		{Method: http.MethodGet, Path: "/api/v1/node/usage", Summary: "Get storage usage by owner and content type", Tag: "node",
+			Response: schemaRef("UsageBreakdown")},
+		{Method: http.MethodGet, Path: "/api/v1/ws", Summary: "WebSocket stream of the caller's Event messages", Tag: "events",
+			Params: []gin.H{
+				queryParam("token", stringSchema, "Event token; may instead be offered as a token.<token> subprotocol"),
+			},
+			Status: http.StatusSwitchingProtocols,
+			Errors: []int{http.StatusUnauthorized, http.StatusForbidden}},
+		{Method: http.MethodPost, Path: "/api/v1/ws/token", Summary: "Issue a short-lived token for connecting to the event stream", Tag: "events",
+			Response: objectSchema(gin.H{"token": stringSchema, "expires_at": dateTimeSchema}, "token", "expires_at"),
+			Errors:   []int{http.StatusUnauthorized}},
+
+		// Health
+		{Method: http.MethodGet, Path: "/api/v1/health", Summary: "Check backend health", Tag: "health",
+			Response: objectSchema(gin.H{
+				"status":     stringSchema,
+				"components": gin.H{"type": "object", "additionalProperties": objectSchema(gin.H{"status": stringSchema, "error": stringSchema})},
+				"timestamp":  dateTimeSchema,
+				"version":    stringSchema,
+			})},
+		{Method: http.MethodGet, Path: "/api/v1/ready", Summary: "Check readiness", Tag: "health",
+			Response: objectSchema(gin.H{"status": stringSchema, "reason": stringSchema})},
+
+		// Admin
+		{Method: http.MethodPut, Path: "/api/v1/admin/quotas/:owner", Summary: "Set an owner's storage quota", Tag: "admin", Admin: true,
+			Body:     objectSchema(gin.H{"quota": integerSchema}, "quota"),
+			Response: objectSchema(gin.H{"owner": stringSchema, "quota": integerSchema}, "owner", "quota"),
+			Errors:   []int{http.StatusBadRequest, http.StatusForbidden}},
+		{Method: http.MethodGet, Path: "/api/v1/admin/quotas/:owner", Summary: "Get an owner's storage quota and usage", Tag: "admin", Admin: true,
+			Response: schemaRef("QuotaStatus"),
+			Errors:   []int{http.StatusForbidden, http.StatusInternalServerError}},
+		{Method: http.MethodPost, Path: "/api/v1/admin/reindex", Summary: "Rebuild lost file metadata from stored chunks", Tag: "admin", Admin: true,
+			Response: objectSchema(gin.H{
+				"recovered":           integerSchema,
+				"files":               arraySchema(stringSchema),
+				"unassignable_chunks": integerSchema,
+				"unassigned":          arraySchema(stringSchema),
+			}, "recovered", "files", "unassignable_chunks", "unassigned"),
+			Errors: []int{http.StatusForbidden, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/api/v1/admin/keys", Summary: "Get master key versions and re-encryption status", Tag: "admin", Admin: true,
+			Response: objectSchema(gin.H{
+				"key_version": integerSchema,
+				"versions":    arraySchema(integerSchema),
+				"rekey":       schemaRef("RekeyStatus"),
+			}, "key_version", "versions", "rekey"),
+			Errors: []int{http.StatusForbidden}},
+		{Method: http.MethodPost, Path: "/api/v1/admin/keys/rotate", Summary: "Rotate the master encryption key", Tag: "admin", Admin: true,
+			Response: objectSchema(gin.H{
+				"key_version": integerSchema,
+				"versions":    arraySchema(integerSchema),
+			}, "key_version", "versions"),
+			Errors: []int{http.StatusForbidden, http.StatusInternalServerError}},
+		{Method: http.MethodGet, Path: "/api/v1/admin/files/:id/chunks.tar", Summary: "Download a file's encrypted chunks as a tar archive", Tag: "admin", Admin: true,
+			ContentType: "application/x-tar",
+			Errors:      []int{http.StatusForbidden, http.StatusNotFound}},
+	}
+}
+
+// getOpenAPI handles serving the OpenAPI document
+func (s *Server) getOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, s.openAPI)
+}
+
+// swaggerUIPage renders the Swagger UI from a CDN against the OpenAPI document
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Distributed Cloud Storage API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/api/v1/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// serveDocs handles serving the Swagger UI
+func (s *Server) serveDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// templateParam matches the {name} segments of OpenAPI paths
+var templateParam = regexp.MustCompile(`\{([A-Za-z_]+)\}`)
+
+// collectRefs gathers every $ref in a decoded JSON document
+func collectRefs(node interface{}, refs *[]string) {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				*refs = append(*refs, ref)
+			}
+			collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range value {
+			collectRefs(child, refs)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	server := newTestServer(t)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse OpenAPI document: %v", err)
+	}
+
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		t.Errorf("Expected OpenAPI 3 document, got version %v", doc["openapi"])
+	}
+	info, _ := doc["info"].(map[string]interface{})
+	if info["title"] == "" || info["title"] == nil || info["version"] == nil {
+		t.Errorf("Expected info with title and version, got %v", info)
+	}
+
+	paths, ok := doc["paths"].(map[string]interface{})
+	if !ok || len(paths) == 0 {
+		t.Fatal("Expected paths in the document")
+	}
+
+	// Every route is documented
+	for _, route := range server.GetRouter().Routes() {
+		path := routeParam.ReplaceAllString(route.Path, "{$1}")
+		item, _ := paths[path].(map[string]interface{})
+		if item[strings.ToLower(route.Method)] == nil {
+			t.Errorf("Route %s %s is missing from the document", route.Method, route.Path)
+		}
+	}
+
+	operationIDs := make(map[string]bool)
+	for path, item := range paths {
+		for method, value := range item.(map[string]interface{}) {
+			operation := value.(map[string]interface{})
+
+			id, _ := operation["operationId"].(string)
+			if id == "" || operationIDs[id] {
+				t.Errorf("%s %s: expected a unique operationId, got %q", method, path, id)
+			}
+			operationIDs[id] = true
+
+			if responses, _ := operation["responses"].(map[string]interface{}); len(responses) == 0 {
+				t.Errorf("%s %s: expected responses", method, path)
+			}
+
+			// Path templates and path parameters must agree
+			declared := make(map[string]bool)
+			params, _ := operation["parameters"].([]interface{})
+			for _, param := range params {
+				param := param.(map[string]interface{})
+				if param["in"] == "path" {
+					if param["required"] != true {
+						t.Errorf("%s %s: path parameter %v must be required", method, path, param["name"])
+					}
+					declared[param["name"].(string)] = true
+				}
+			}
+			for _, match := range templateParam.FindAllStringSubmatch(path, -1) {
+				if !declared[match[1]] {
+					t.Errorf("%s %s: path parameter %s is not declared", method, path, match[1])
+				}
+			}
+			if len(declared) != len(templateParam.FindAllString(path, -1)) {
+				t.Errorf("%s %s: declares path parameters not in the path", method, path)
+			}
+		}
+	}
+
+	// Every reference resolves to a component schema
+	components, _ := doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	var refs []string
+	collectRefs(doc, &refs)
+	for _, ref := range refs {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if name == ref || schemas[name] == nil {
+			t.Errorf("Unresolved reference %s", ref)
+		}
+	}
+
+	// File metadata schemas come from FileInfo and the error envelope from APIError
+	fileInfo, _ := schemas["FileInfo"].(map[string]interface{})
+	properties, _ := fileInfo["properties"].(map[string]interface{})
+	for _, field := range []string{"id", "name", "size", "hash", "chunks", "version"} {
+		if properties[field] == nil {
+			t.Errorf("Expected FileInfo schema to have %s, got %v", field, properties)
+		}
+	}
+	if schemas["ErrorResponse"] == nil || schemas["APIError"] == nil {
+		t.Error("Expected error envelope schemas")
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	server := newTestServer(t)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("Expected HTML, got %s", contentType)
+	}
+	if !strings.Contains(w.Body.String(), "/api/v1/openapi.json") {
+		t.Error("Expected docs page to load the OpenAPI document")
+	}
+}
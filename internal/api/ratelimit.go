@@ -0,0 +1,129 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// rateLimitSweepInterval is how often idle client buckets are dropped
+const rateLimitSweepInterval = time.Minute
+
+// rateLimitExempt are the routes never rate limited, so probes and scrapes keep working
+var rateLimitExempt = map[string]bool{
+	"/metrics":       true,
+	"/api/v1/health": true,
+	"/api/v1/ready":  true,
+}
+
+// tokenBucket holds the tokens available to one client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a set of token buckets keyed by client, each refilling at rate
+// tokens per second up to burst
+type rateLimiter struct {
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// newRateLimiter creates a limiter allowing rate requests per second with bursts of burst
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the key's bucket. When none is available it returns false
+// and how long until one will be.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely, since they behave like new ones.
+// Callers must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitMiddleware limits each client separately for reads and writes, keyed by the
+// identity of a verified client certificate or else by IP address. The X-Owner header is
+// not used, as a client could send a new value with every request to get a fresh bucket.
+func (s *Server) rateLimitMiddleware(cfg config.RateLimitConfig) gin.HandlerFunc {
+	reads := newRateLimiter(cfg.ReadRate, cfg.ReadBurst)
+	writes := newRateLimiter(cfg.WriteRate, cfg.WriteBurst)
+
+	return func(c *gin.Context) {
+		if rateLimitExempt[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP()
+		if identity := clientCertIdentity(c.Request.TLS); identity != "" {
+			key = "cert:" + identity
+		}
+
+		limiter := writes
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			limiter = reads
+		}
+
+		if ok, wait := limiter.allow(key); !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			s.log(c).WithField("client", key).Warn("Rate limit exceeded")
+			writeError(c, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// newRateLimitedServer creates a test server with small read and write limits
+func newRateLimitedServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.API.RateLimit = config.RateLimitConfig{
+		Enabled:    true,
+		ReadRate:   1,
+		ReadBurst:  3,
+		WriteRate:  1,
+		WriteBurst: 1,
+	}
+	return newTestServerWithConfig(t, cfg)
+}
+
+func TestRateLimitExceeded(t *testing.T) {
+	server := newRateLimitedServer(t)
+
+	for i := 0; i < 3; i++ {
+		if w := requestAs(server, http.MethodGet, "alice", "/api/v1/files"); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d within burst to succeed, got %d", i, w.Code)
+		}
+	}
+
+	w := requestAs(server, http.MethodGet, "alice", "/api/v1/files")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 after burst, got %d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", retryAfter)
+	}
+	if code := decodeError(t, w.Body.Bytes())["code"]; code != CodeRateLimited {
+		t.Errorf("Expected error code %s, got %v", CodeRateLimited, code)
+	}
+
+	// Other addresses have their own buckets
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+	req.RemoteAddr = "192.0.2.7:1234"
+	if w := serve(server, req); w.Code != http.StatusOK {
+		t.Errorf("Expected another client address to be unaffected, got %d", w.Code)
+	}
+
+	// Health checks are never limited
+	if w := requestAs(server, http.MethodGet, "alice", "/api/v1/health"); w.Code == http.StatusTooManyRequests {
+		t.Error("Expected health check to bypass rate limiting")
+	}
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	server := newRateLimitedServer(t)
+
+	// X-Forwarded-For from a client that is not a trusted proxy does not name another client
+	forwarded := func(server *Server, client string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+		req.Header.Set("X-Forwarded-For", client)
+		return serve(server, req).Code
+	}
+	for i := 0; i < 3; i++ {
+		if code := forwarded(server, fmt.Sprintf("198.51.100.%d", i)); code != http.StatusOK {
+			t.Fatalf("Expected request %d within burst to succeed, got %d", i, code)
+		}
+	}
+	if code := forwarded(server, "198.51.100.3"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected spoofed X-Forwarded-For to be limited, got %d", code)
+	}
+
+	// Behind a trusted proxy, each forwarded client has its own bucket
+	cfg := config.DefaultConfig()
+	cfg.API.RateLimit = server.config.API.RateLimit
+	cfg.API.TrustedProxies = []string{"192.0.2.0/24"} // httptest requests come from 192.0.2.1
+	proxied := newTestServerWithConfig(t, cfg)
+	for i := 0; i < 5; i++ {
+		if code := forwarded(proxied, fmt.Sprintf("198.51.100.%d", i)); code != http.StatusOK {
+			t.Errorf("Expected forwarded client %d to have its own bucket, got %d", i, code)
+		}
+	}
+}
+
+func TestRateLimitIgnoresOwnerHeader(t *testing.T) {
+	server := newRateLimitedServer(t)
+
+	// A client rotating X-Owner from one address still shares one bucket
+	for i := 0; i < 3; i++ {
+		if w := requestAs(server, http.MethodGet, fmt.Sprintf("owner-%d", i), "/api/v1/files"); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d within burst to succeed, got %d", i, w.Code)
+		}
+	}
+	if w := requestAs(server, http.MethodGet, "owner-3", "/api/v1/files"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected rotated X-Owner to be limited, got %d", w.Code)
+	}
+
+	// Clients authenticated by certificate are limited by identity instead of address
+	withCert := func(name string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: name}}}}}
+		return req
+	}
+	for i := 0; i < 3; i++ {
+		if w := serve(server, withCert("svc-a")); w.Code != http.StatusOK {
+			t.Fatalf("Expected certificate request %d within burst to succeed, got %d", i, w.Code)
+		}
+	}
+	if w := serve(server, withCert("svc-a")); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected certificate identity to be limited, got %d", w.Code)
+	}
+	if w := serve(server, withCert("svc-b")); w.Code != http.StatusOK {
+		t.Errorf("Expected another certificate identity to be unaffected, got %d", w.Code)
+	}
+}
+
+func TestRateLimitReadWriteSeparate(t *testing.T) {
+	server := newRateLimitedServer(t)
+
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/missing"); w.Code == http.StatusTooManyRequests {
+		t.Fatalf("Expected first write to be allowed, got %d", w.Code)
+	}
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/missing"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected second write to be limited, got %d", w.Code)
+	}
+
+	// Exhausting the write budget leaves reads untouched
+	if w := requestAs(server, http.MethodGet, "alice", "/api/v1/files"); w.Code != http.StatusOK {
+		t.Errorf("Expected read to be allowed, got %d", w.Code)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(2, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("client"); !ok {
+			t.Fatalf("Expected request %d within burst to be allowed", i)
+		}
+	}
+
+	ok, wait := limiter.allow("client")
+	if ok {
+		t.Fatal("Expected request beyond burst to be denied")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("Expected wait of 500ms, got %v", wait)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.allow("client"); !ok {
+		t.Error("Expected a token to be available after refilling")
+	}
+	if ok, _ := limiter.allow("client"); ok {
+		t.Error("Expected only one token to have refilled")
+	}
+
+	// Idle buckets refill to the burst and are swept
+	now = now.Add(rateLimitSweepInterval)
+	limiter.allow("other")
+	if _, exists := limiter.buckets["client"]; exists {
+		t.Error("Expected idle bucket to be swept")
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// recoveredNamePrefix names rebuilt files, whose real names are not stored with their chunks
+const recoveredNamePrefix = "recovered-"
+
+// reindexFiles handles rebuilding lost file metadata from the headers of stored chunks.
+// Chunks of known files and of in-progress uploads are left alone.
+func (s *Server) reindexFiles(c *gin.Context) {
+	staged, err := s.uploads.StagedKeys()
+	if err != nil {
+		s.log(c).WithError(err).Error("Failed to list staged upload chunks")
+		writeError(c, http.StatusInternalServerError, "Failed to reindex")
+		return
+	}
+
+	known := make(map[string]bool, len(staged))
+	for _, key := range staged {
+		known[key] = true
+	}
+	for _, chunkInfo := range s.storedChunks() {
+		known[chunkInfo.ID] = true
+	}
+
+	result, err := s.chunkManager.Reindex(func(chunkID string) bool { return known[chunkID] })
+	if err != nil {
+		s.log(c).WithError(err).Error("Failed to reindex chunks")
+		writeError(c, http.StatusInternalServerError, "Failed to reindex")
+		return
+	}
+
+	recovered := []string{}
+	for _, fileInfo := range result.Files {
+		if _, exists := s.lookupFile(fileInfo.ID); exists {
+			continue
+		}
+
+		fileInfo.Name = recoveredNamePrefix + fileInfo.ID[:16]
+		fileInfo.ContentType = unknownContentType
+		s.saveFile(fileInfo)
+		recovered = append(recovered, fileInfo.ID)
+	}
+
+	s.log(c).WithFields(logrus.Fields{
+		"recovered":  len(recovered),
+		"unassigned": len(result.Unassigned),
+	}).Info("Rebuilt file metadata from stored chunks")
+
+	unassigned := result.Unassigned
+	if unassigned == nil {
+		unassigned = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"recovered":           len(recovered),
+		"files":               recovered,
+		"unassignable_chunks": len(unassigned),
+		"unassigned":          unassigned,
+	})
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// Search result page sizes
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 1000
+)
+
+// searchQuery is a parsed search expression. Every term must match.
+type searchQuery struct {
+	prefixes []string    // prefix:<p> - name starts with p
+	tags     [][2]string // tag:<key>=<value> - exact tag
+	tagKeys  []string    // tag:<key> - tag present with any value
+	text     []string    // free text - name or a tag value contains it, case-insensitively
+}
+
+// parseSearchQuery parses a whitespace-separated query of prefix:, tag: and free-text terms
+func parseSearchQuery(q string) (*searchQuery, error) {
+	query := &searchQuery{}
+	for _, term := range strings.Fields(q) {
+		switch {
+		case strings.HasPrefix(term, "prefix:"):
+			prefix := strings.TrimPrefix(term, "prefix:")
+			if prefix == "" {
+				return nil, fmt.Errorf("empty prefix term")
+			}
+			query.prefixes = append(query.prefixes, prefix)
+		case strings.HasPrefix(term, "tag:"):
+			key, value, found := strings.Cut(strings.TrimPrefix(term, "tag:"), "=")
+			if key == "" {
+				return nil, fmt.Errorf("empty tag term")
+			}
+			if found {
+				query.tags = append(query.tags, [2]string{key, value})
+			} else {
+				query.tagKeys = append(query.tagKeys, key)
+			}
+		default:
+			query.text = append(query.text, strings.ToLower(term))
+		}
+	}
+
+	if len(query.prefixes) == 0 && len(query.tags) == 0 && len(query.tagKeys) == 0 && len(query.text) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	return query, nil
+}
+
+// matches reports whether a file satisfies every term of the query
+func (q *searchQuery) matches(fileInfo *types.FileInfo) bool {
+	for _, prefix := range q.prefixes {
+		if !strings.HasPrefix(fileInfo.Name, prefix) {
+			return false
+		}
+	}
+	for _, tag := range q.tags {
+		if value, exists := fileInfo.Tags[tag[0]]; !exists || value != tag[1] {
+			return false
+		}
+	}
+	for _, key := range q.tagKeys {
+		if _, exists := fileInfo.Tags[key]; !exists {
+			return false
+		}
+	}
+	for _, text := range q.text {
+		if !containsText(fileInfo, text) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsText reports whether the file name or any tag value contains the lowercase text
+func containsText(fileInfo *types.FileInfo, text string) bool {
+	if strings.Contains(strings.ToLower(fileInfo.Name), text) {
+		return true
+	}
+	for _, value := range fileInfo.Tags {
+		if strings.Contains(strings.ToLower(value), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchFiles handles searching the caller's files by name and tags
+func (s *Server) searchFiles(c *gin.Context) {
+	query, err := parseSearchQuery(c.Query("q"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "Invalid search query")
+		return
+	}
+
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		writeError(c, http.StatusBadRequest, "Invalid pagination parameters")
+		return
+	}
+
+	// Exact tag terms narrow the candidates through the tag index
+	var tagged map[string]bool
+	if len(query.tags) > 0 {
+		tags := make(map[string]string, len(query.tags))
+		for _, tag := range query.tags {
+			tags[tag[0]] = tag[1]
+		}
+		if tagged, err = s.taggedFiles(tags); err != nil {
+			s.log(c).WithError(err).Error("Failed to look up tagged files")
+			writeError(c, http.StatusInternalServerError, "Failed to search files")
+			return
+		}
+	}
+
+	owner := s.principal(c)
+	var matches []*types.FileInfo
+	s.eachFile(func(fileInfo *types.FileInfo) {
+		if fileInfo.Owner != owner || fileInfo.DeletedAt != nil {
+			return
+		}
+		if tagged != nil && !tagged[fileInfo.ID] {
+			return
+		}
+		if query.matches(fileInfo) {
+			matches = append(matches, fileInfo)
+		}
+	})
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].Version < matches[j].Version
+	})
+
+	results := []gin.H{}
+	for i := offset; i < len(matches) && i < offset+limit; i++ {
+		fileInfo := matches[i]
+		entry := gin.H{
+			"id":           fileInfo.ID,
+			"name":         fileInfo.Name,
+			"size":         fileInfo.Size,
+			"content_type": fileInfo.ContentType,
+			"created_at":   fileInfo.CreatedAt,
+			"version":      fileInfo.Version,
+		}
+		if len(fileInfo.Tags) > 0 {
+			entry["tags"] = fileInfo.Tags
+		}
+		results = append(results, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"count":   len(results),
+		"total":   len(matches),
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// pageParams parses the limit and offset query parameters
+func pageParams(c *gin.Context) (limit, offset int, ok bool) {
+	limit = defaultSearchLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			return 0, 0, false
+		}
+		limit = n
+	}
+
+	if value := c.Query("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+
+	return limit, offset, true
+}
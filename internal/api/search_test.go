@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// searchAs runs a search as the given owner and returns the matching names and total
+func searchAs(t *testing.T, server *Server, owner string, params url.Values) ([]string, int) {
+	t.Helper()
+
+	w := getAs(server, owner, "/api/v1/search?"+params.Encode())
+	if w.Code != http.StatusOK {
+		t.Fatalf("Search failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var result struct {
+		Results []struct {
+			Name string `json:"name"`
+		} `json:"results"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse search response: %v", err)
+	}
+
+	names := []string{}
+	for _, r := range result.Results {
+		names = append(names, r.Name)
+	}
+	return names, result.Total
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{"report", false},
+		{"prefix:docs/ tag:env=prod quarterly", false},
+		{"tag:draft", false},
+		{"tag:empty=", false},
+		{"", true},
+		{"   ", true},
+		{"prefix:", true},
+		{"tag:", true},
+		{"tag:=value", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := parseSearchQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSearchFiles(t *testing.T) {
+	server := newTestServer(t)
+
+	uploadTagged(t, server, "docs/Quarterly-Report.pdf", []byte("q3"), "env=prod", "team=finance")
+	uploadTagged(t, server, "docs/notes.txt", []byte("notes"), "env=dev", "project=apollo")
+	uploadTagged(t, server, "images/report-chart.png", []byte("png"), "env=prod")
+	uploadTagged(t, server, "archive/old.zip", []byte("zip"), "team=finance", "status=archived")
+	req := newUploadRequest(t, "report-bob.pdf", []byte("bob"), map[string]string{"name": "docs/report-bob.pdf"})
+	req.Header.Set("X-Owner", "bob")
+	fileIDFromResponse(t, serve(server, req))
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"name substring", "report", []string{"docs/Quarterly-Report.pdf", "images/report-chart.png"}},
+		{"tag value text", "apollo", []string{"docs/notes.txt"}},
+		{"prefix", "prefix:docs/", []string{"docs/Quarterly-Report.pdf", "docs/notes.txt"}},
+		{"exact tag", "tag:team=finance", []string{"archive/old.zip", "docs/Quarterly-Report.pdf"}},
+		{"tag key", "tag:status", []string{"archive/old.zip"}},
+		{"prefix and tag", "prefix:docs/ tag:env=prod", []string{"docs/Quarterly-Report.pdf"}},
+		{"tag and text", "tag:env=prod chart", []string{"images/report-chart.png"}},
+		{"conflicting tags", "tag:env=prod tag:env=dev", []string{}},
+		{"no match", "missing", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, total := searchAs(t, server, "alice", url.Values{"q": {tt.query}})
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+			if total != len(tt.expected) {
+				t.Errorf("Expected total %d, got %d", len(tt.expected), total)
+			}
+		})
+	}
+
+	// Results are scoped to the caller's own files
+	if names, _ := searchAs(t, server, "bob", url.Values{"q": {"report"}}); !reflect.DeepEqual(names, []string{"docs/report-bob.pdf"}) {
+		t.Errorf("Expected only bob's file, got %v", names)
+	}
+
+	if w := getAs(server, "alice", "/api/v1/search?q="); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for empty query, got %d", w.Code)
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"log-1.txt", "log-2.txt", "log-3.txt", "log-4.txt", "log-5.txt"} {
+		uploadAs(t, server, "alice", name, []byte(name))
+	}
+
+	names, total := searchAs(t, server, "alice", url.Values{"q": {"log"}, "limit": {"2"}, "offset": {"2"}})
+	if !reflect.DeepEqual(names, []string{"log-3.txt", "log-4.txt"}) {
+		t.Errorf("Expected second page, got %v", names)
+	}
+	if total != 5 {
+		t.Errorf("Expected total 5, got %d", total)
+	}
+
+	if names, _ := searchAs(t, server, "alice", url.Values{"q": {"log"}, "offset": {"10"}}); len(names) != 0 {
+		t.Errorf("Expected empty page past the end, got %v", names)
+	}
+
+	for _, params := range []string{"limit=0", "limit=abc", "offset=-1", "limit=100000"} {
+		if w := getAs(server, "alice", "/api/v1/search?q=log&"+params); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", params, w.Code)
+		}
+	}
+}
@@ -2,37 +2,106 @@
 package api
 
 import (
-	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/blockchain"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/p2p"
 	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
 	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
 	"github.com/sirupsen/logrus"
 )
 
+// multipartOverhead is the allowance for multipart headers and boundaries on top of MaxFileSize
+const multipartOverhead = 64 * 1024
+
 // Server represents the API server
 type Server struct {
+	config       *config.Config
 	router       *gin.Engine
 	storage      storage.Storage
 	chunkManager *storage.ChunkManager
-	logger       *logrus.Logger
-	files        map[string]*types.FileInfo // In-memory metadata store (should be replaced with proper DB)
+	metadata     metadata.Store
+	quotas       *storage.QuotaManager
+	uploads      *storage.UploadManager
+	scrubber     *storage.Scrubber
+	gc           *storage.GarbageCollector
+	rekeyer      *storage.Rekeyer
+	metrics      *serverMetrics
+	tags         *metadata.TagIndex
+	shares       *storage.ShareManager
+	peers        *p2p.NodeRegistry    // Known P2P peers; nil when the server is not on the network
+	anchorer     *blockchain.Anchorer // Anchors uploads on-chain; nil when disabled
+	uploadSlots  *uploadLimiter       // Caps concurrent uploads; nil when unlimited
+	events       *eventHub            // Pushes upload and delete events to WebSocket clients
+	eventTokens  eventTokens          // Authenticate WebSocket connections to the event stream
+	cors         *corsPolicy
+	openAPI      gin.H // OpenAPI document served at /api/v1/openapi.json
+	usage        usageCache
+	capacity     capacityReservations // Storage promised to uploads not stored yet
+	fileLocks    fileLocks            // Orders changes to a file against each other and against reads of its content
+
+	// Lifecycle
+	httpServer     *http.Server
+	redirectServer *http.Server  // Redirects plain HTTP to HTTPS; nil when not serving TLS
+	scrubStop      chan struct{} // Closed to stop the running scrub loop
+	done           chan struct{}
+	started        time.Time
+	shutdownOnce   sync.Once
+	mu             sync.Mutex
+	logger         *logrus.Logger
+	files          map[string]*types.FileInfo // In-memory metadata store (should be replaced with proper DB)
+	filesMu        sync.RWMutex               // Guards files and the entries in it
 }
 
 // NewServer creates a new API server
-func NewServer(storage storage.Storage, chunkManager *storage.ChunkManager, logger *logrus.Logger) *Server {
+func NewServer(cfg *config.Config, fileStorage storage.Storage, chunkManager *storage.ChunkManager, store metadata.Store, logger *logrus.Logger) *Server {
 	server := &Server{
-		storage:      storage,
+		config:       cfg,
+		storage:      fileStorage,
 		chunkManager: chunkManager,
+		metadata:     store,
+		quotas:       storage.NewQuotaManager(store, cfg.Storage.DefaultQuota),
+		uploads:      storage.NewUploadManager(store, chunkManager),
+		tags:         metadata.NewTagIndex(store),
+		shares:       storage.NewShareManager(store),
+		uploadSlots:  newUploadLimiter(cfg.API.Uploads),
+		events:       newEventHub(),
+		cors:         newCORSPolicy(cfg.API.CORS),
+		openAPI:      buildOpenAPI(apiOperations()),
 		logger:       logger,
 		files:        make(map[string]*types.FileInfo),
+		done:         make(chan struct{}),
+		usage:        usageCache{ttl: usageCacheTTL},
+		started:      time.Now(),
 	}
 
+	server.scrubber = storage.NewScrubber(chunkManager, server.storedChunks, logger)
+	server.gc = storage.NewGarbageCollector(fileStorage, server.referencedChunks, server.uploads, cfg.Storage.GCGracePeriod, logger)
+	server.gc.SetDryRun(cfg.Storage.GCDryRun)
+	server.rekeyer = storage.NewRekeyer(chunkManager, server.storedChunks, server.updateChunk, logger)
+	server.uploads.SetFileIDMode(server.fileIDMode())
+	server.uploads.SetSessionTTL(cfg.API.Uploads.SessionTTL)
+	server.uploads.SetMaxStaged(cfg.API.Uploads.MaxStaged)
+	server.metrics = newServerMetrics(server)
+
 	server.setupRoutes()
 	return server
 }
@@ -40,40 +109,102 @@ func NewServer(storage storage.Storage, chunkManager *storage.ChunkManager, logg
 // setupRoutes configures the API routes
 func (s *Server) setupRoutes() {
 	s.router = gin.New()
+	// Only configured proxies may name the client, which keys rate limits and access logs
+	s.router.SetTrustedProxies(s.config.API.TrustedProxies) // Checked by Validate
 
 	// Middleware
-	s.router.Use(gin.Logger())
+	s.router.Use(s.requestIDMiddleware())
+	s.router.Use(s.accessLogMiddleware())
 	s.router.Use(gin.Recovery())
 	s.router.Use(s.corsMiddleware())
+	s.router.Use(s.metricsMiddleware())
+	if s.config.API.RequestTimeout > 0 {
+		s.router.Use(timeoutMiddleware(s.config.API.RequestTimeout))
+	}
+	if s.config.API.Compression.Enabled {
+		s.router.Use(s.compressionMiddleware(s.config.API.Compression.MinSize))
+	}
+	if s.config.API.RateLimit.Enabled {
+		s.router.Use(s.rateLimitMiddleware(s.config.API.RateLimit))
+	}
+
+	// Prometheus scrape endpoint
+	s.router.GET("/metrics", s.serveMetrics)
+
+	// API documentation
+	s.router.GET("/docs", s.serveDocs)
 
 	// API routes
 	api := s.router.Group("/api/v1")
 	{
+		api.GET("/openapi.json", s.getOpenAPI)
+
 		// File operations
-		api.POST("/files", s.uploadFile)
+		api.POST("/files", s.limitUploads, s.uploadFile)
 		api.GET("/files/:id", s.downloadFile)
 		api.DELETE("/files/:id", s.deleteFile)
 		api.GET("/files", s.listFiles)
 		api.GET("/files/:id/info", s.getFileInfo)
+		api.PATCH("/files/:id", s.updateFile)
+		api.POST("/files/:id/append", s.limitUploads, s.appendFile)
+		api.POST("/files/:id/copy", s.copyFile)
+		api.GET("/files/:id/versions", s.listVersions)
+		api.GET("/files/:id/checksum", s.getFileChecksum)
+		api.GET("/files/:id/chunks", s.getFileChunks)
+		api.POST("/files/:id/restore", s.restoreFile)
+		api.POST("/files/batch-delete", s.batchDelete)
+		api.POST("/files/batch-info", s.batchInfo)
+		api.GET("/files/archive", s.downloadArchive)
+		api.GET("/search", s.searchFiles)
+
+		// Share links
+		api.POST("/files/:id/share", s.createShare)
+		api.DELETE("/files/:id/share/:share_id", s.revokeShare)
+		api.GET("/shared/:token", s.downloadShared)
+
+		// Resumable uploads
+		api.POST("/uploads", s.createUpload)
+		api.GET("/uploads/:id", s.getUpload)
+		api.PUT("/uploads/:id/:index", s.limitUploads, s.uploadChunk)
+		api.POST("/uploads/:id/complete", s.completeUpload)
+		api.DELETE("/uploads/:id", s.abortUpload)
 
 		// Node operations
 		api.GET("/node/info", s.getNodeInfo)
 		api.GET("/node/stats", s.getNodeStats)
+		api.GET("/node/scrub", s.getScrubStatus)
+		api.GET("/node/gc", s.getGCStatus)
+		api.GET("/node/peers", s.getNodePeers)
+		api.GET("/node/placement/:chunk_id", s.getChunkPlacement)
+		api.GET("/node/usage", s.getNodeUsage)
+
+		// Live upload and delete events for the caller
+		api.GET("/ws", s.streamEvents)
+		api.POST("/ws/token", s.createEventToken)
 
 		// Health check
 		api.GET("/health", s.healthCheck)
+		api.GET("/ready", s.readyCheck)
+
+		// Admin operations
+		admin := api.Group("/admin", s.adminMiddleware())
+		admin.PUT("/quotas/:owner", s.setQuota)
+		admin.GET("/quotas/:owner", s.getQuota)
+		admin.POST("/reindex", s.reindexFiles)
+		admin.GET("/keys", s.getKeys)
+		admin.POST("/keys/rotate", s.rotateKey)
+		admin.GET("/files/:id/chunks.tar", s.downloadChunkArchive)
 	}
 }
 
-// corsMiddleware adds CORS headers
-func (s *Server) corsMiddleware() gin.HandlerFunc {
+// adminMiddleware restricts access to callers presenting the configured admin token
+func (s *Server) adminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		token := s.config.API.AdminToken
+		provided := c.GetHeader("X-Admin-Token")
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusOK)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(provided)) != 1 {
+			writeAPIError(c, http.StatusForbidden, &APIError{Code: CodeAdminRequired, Message: "Admin access required"})
 			return
 		}
 
@@ -81,44 +212,180 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// principal returns the identity of the caller making the request. A verified client
+// certificate takes precedence, so a caller authenticated by mutual TLS cannot act as
+// someone else through the X-Owner header.
+func (s *Server) principal(c *gin.Context) string {
+	if identity := clientCertIdentity(c.Request.TLS); identity != "" {
+		return identity
+	}
+	return c.GetHeader("X-Owner") // Simple owner identification
+}
+
+// authorizeFile checks that the caller owns the file, writing a 403 response if not.
+// Public files are exempt from the check when allowPublic is set, which is used for read-only access.
+func (s *Server) authorizeFile(c *gin.Context, fileInfo *types.FileInfo, allowPublic bool) bool {
+	if allowPublic && fileInfo.Public {
+		return true
+	}
+
+	if fileInfo.Owner != s.principal(c) {
+		writeError(c, http.StatusForbidden, "Access denied")
+		return false
+	}
+
+	return true
+}
+
 // uploadFile handles file upload
 func (s *Server) uploadFile(c *gin.Context) {
+	// Reject oversized uploads before reading the body
+	maxFileSize := s.config.Storage.MaxFileSize
+	if c.Request.ContentLength > maxFileSize+multipartOverhead {
+		writeError(c, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileSize+multipartOverhead)
+
 	// Parse multipart form
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to parse form file")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file"})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(c, http.StatusRequestEntityTooLarge, "File too large")
+			return
+		}
+		s.log(c).WithError(err).Error("Failed to parse form file")
+		writeError(c, http.StatusBadRequest, "Invalid file")
 		return
 	}
 	defer file.Close()
 
-	// Read file data
-	data, err := io.ReadAll(file)
+	if header.Size > maxFileSize {
+		writeError(c, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+
+	if !s.reserveCapacity(c, header.Size) {
+		return
+	}
+	defer s.releaseCapacity(header.Size)
+
+	// Client-encrypted uploads carry the salt the client needs to re-derive its key
+	clientEncrypted := c.PostForm("client_encrypted") == "true"
+	keySalt := c.PostForm("key_salt")
+	if clientEncrypted {
+		if salt, err := hex.DecodeString(keySalt); err != nil || len(salt) != crypto.SaltSize {
+			writeError(c, http.StatusBadRequest, "Invalid key salt")
+			return
+		}
+	}
+
+	// Tags are sent as repeated "key=value" tag fields
+	tags, err := metadata.ParseTags(c.PostFormArray("tag"))
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to read file data")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		writeError(c, http.StatusBadRequest, "Invalid tag")
+		return
+	}
+
+	// Uploads may ask for their own chunk size, e.g. larger chunks for big archives
+	var chunkSize int
+	if value := c.PostForm("chunk_size"); value != "" {
+		if chunkSize, err = strconv.Atoi(value); err != nil || chunkSize <= 0 {
+			writeError(c, http.StatusBadRequest, "Invalid chunk size")
+			return
+		}
+	}
+	if !s.checkChunkSize(c, chunkSize) {
+		return
+	}
+
+	// Temporary files carry a TTL such as "24h" after which they are deleted
+	expiresAt, ok := parseTTL(c.PostForm("ttl"), time.Now())
+	if !ok {
+		writeError(c, http.StatusBadRequest, "Invalid TTL")
+		return
+	}
+
+	// Reserve quota for the owner before storing anything
+	owner := s.principal(c)
+	if err := s.quotas.Reserve(owner, header.Size); err != nil {
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			writeAPIError(c, http.StatusRequestEntityTooLarge, &APIError{Code: CodeQuotaExceeded, Message: "Storage quota exceeded"})
+			return
+		}
+		s.log(c).WithError(err).Error("Failed to reserve quota")
+		writeError(c, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+
+	// The name field can carry a path-like name, which multipart strips from the filename
+	fileName := header.Filename
+	if name := c.PostForm("name"); name != "" {
+		fileName = name
+	}
+
+	// Compute the file ID in a first pass so the data never has to be held in memory
+	idMode := s.fileIDMode()
+	ids := s.chunkManager.IDGenerator()
+	fileID, contentID, err := types.GenerateFileIDsWith(ids, idMode, fileName, file)
+	if err != nil {
+		s.releaseQuota(owner, header.Size)
+		s.log(c).WithError(err).Error("Failed to read file data")
+		writeError(c, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+
+	// Identical content under the same name belongs to whoever stored it first
+	if existing, exists := s.lookupFile(fileID); exists && existing.Owner != owner {
+		s.releaseQuota(owner, header.Size)
+		writeError(c, http.StatusConflict, "File already exists")
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		s.releaseQuota(owner, header.Size)
+		s.log(c).WithError(err).Error("Failed to rewind file data")
+		writeError(c, http.StatusInternalServerError, "Failed to read file")
 		return
 	}
 
 	// Create file info
 	fileInfo := &types.FileInfo{
-		ID:          types.GenerateFileID(header.Filename, data),
-		Name:        header.Filename,
+		ID:          fileID,
+		Name:        fileName,
 		ContentType: header.Header.Get("Content-Type"),
-		Owner:       c.GetHeader("X-Owner"), // Simple owner identification
+		Owner:       owner,
+		Public:      c.PostForm("public") == "true",
+		Tags:        tags,
+		IDMode:      idMode,
+		ContentID:   contentID,
+		IDScheme:    ids.Scheme(),
+		ChunkSize:   chunkSize,
+		ExpiresAt:   expiresAt,
+	}
+	if clientEncrypted {
+		fileInfo.ClientEncrypted = true
+		fileInfo.KeySalt = keySalt
 	}
 
-	// Store file
-	if err := s.chunkManager.StoreFile(fileInfo, data); err != nil {
-		s.logger.WithError(err).Error("Failed to store file")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+	// Store file, reporting progress to the owner's WebSocket connections
+	progress := newProgressReader(file, s.events, owner, Event{FileID: fileID, FileName: fileName, Total: header.Size})
+	if err := s.chunkManager.StoreFileStream(c.Request.Context(), fileInfo, progress); err != nil {
+		s.releaseQuota(owner, header.Size)
+		s.log(c).WithError(err).Error("Failed to store file")
+		writeError(c, http.StatusInternalServerError, "Failed to store file")
 		return
 	}
 
-	// Store metadata (in production, this should be in a proper database)
-	s.files[fileInfo.ID] = fileInfo
+	if !s.saveFile(fileInfo) {
+		s.releaseQuota(owner, header.Size)
+		writeError(c, http.StatusConflict, "File already exists")
+		return
+	}
+	s.events.publish(owner, Event{Type: EventUploadCompleted, FileID: fileInfo.ID, FileName: fileInfo.Name, Bytes: fileInfo.Size, Total: fileInfo.Size})
 
-	s.logger.WithFields(logrus.Fields{
+	s.log(c).WithFields(logrus.Fields{
 		"file_id":   fileInfo.ID,
 		"file_name": fileInfo.Name,
 		"size":      fileInfo.Size,
@@ -132,82 +399,371 @@ func (s *Server) uploadFile(c *gin.Context) {
 	})
 }
 
+// saveFile records the metadata of a newly stored file. Re-uploading identical content
+// replaces the previous entry, releasing its chunks and quota; otherwise a file with the
+// same name and owner as an existing one becomes its next version. A file whose ID is
+// already taken by another owner is not saved: its chunks are released and false is
+// returned, leaving the caller to release its quota.
+func (s *Server) saveFile(fileInfo *types.FileInfo) bool {
+	// Numbering and storing happen under one lock so concurrent uploads of the same
+	// name cannot claim the same version
+	s.filesMu.Lock()
+	existing, replaced := s.files[fileInfo.ID]
+	if replaced && existing.Owner != fileInfo.Owner {
+		s.filesMu.Unlock()
+
+		// Deduplication counts the new file's references to chunks it shares with the
+		// existing one, so releasing them leaves the existing file's chunks in place
+		if err := s.chunkManager.DeleteFile(context.Background(), fileInfo); err != nil {
+			s.logger.WithError(err).WithField("file_id", fileInfo.ID).Warn("Failed to release rejected file chunks")
+		}
+		return false
+	}
+	if replaced {
+		fileInfo.Version = existing.Version
+		fileInfo.PreviousVersion = existing.PreviousVersion
+	} else if versions := s.versionsLocked(fileInfo, true); len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		fileInfo.Version = latest.Version + 1
+		fileInfo.PreviousVersion = latest.ID
+	} else {
+		fileInfo.Version = 1
+	}
+
+	// Stored content is stamped by the chunk manager; only metadata rebuilt without
+	// timestamps needs them here
+	now := time.Now()
+	if fileInfo.CreatedAt.IsZero() {
+		fileInfo.CreatedAt = now
+	}
+	if fileInfo.UpdatedAt.IsZero() {
+		fileInfo.UpdatedAt = now
+	}
+
+	// Store metadata (in production, this should be in a proper database)
+	s.files[fileInfo.ID] = fileInfo
+	s.filesMu.Unlock()
+
+	if replaced {
+		// Run to completion even if the client has gone, so no chunk is left behind
+		if err := s.chunkManager.DeleteFile(context.Background(), existing); err != nil {
+			s.logger.WithError(err).WithField("file_id", existing.ID).Warn("Failed to release replaced file chunks")
+		}
+		s.releaseQuota(existing.Owner, existing.Size)
+		s.unindexTags(existing)
+	}
+	s.indexTags(fileInfo)
+	s.anchorFile(fileInfo)
+	return true
+}
+
+// anchorFile queues a stored file's root hash for anchoring on-chain, recording the
+// transaction hash on the file once submitted
+func (s *Server) anchorFile(fileInfo *types.FileInfo) {
+	if s.anchorer == nil {
+		return
+	}
+
+	root := fileInfo.MerkleRoot
+	if root == "" {
+		root = fileInfo.Hash
+	}
+
+	s.anchorer.Submit(fileInfo.ID, root, func(txHash string) {
+		s.modifyFile(fileInfo.ID, func(stored *types.FileInfo) bool {
+			// Content changed since it was queued is anchored by its own submission
+			if stored.MerkleRoot != fileInfo.MerkleRoot || stored.Hash != fileInfo.Hash {
+				return false
+			}
+			stored.AnchorTx = txHash
+			return true
+		})
+	})
+}
+
 // downloadFile handles file download
 func (s *Server) downloadFile(c *gin.Context) {
 	fileID := c.Param("id")
 
 	// Get file info
-	fileInfo, exists := s.files[fileID]
+	fileInfo, exists := s.activeFile(fileID)
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		s.writeFileMissing(c, fileID)
 		return
 	}
 
-	// Retrieve file data
-	data, err := s.chunkManager.RetrieveFile(fileInfo)
-	if err != nil {
-		s.logger.WithError(err).Error("Failed to retrieve file")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve file"})
+	if !s.authorizeFile(c, fileInfo, true) {
+		return
+	}
+
+	// Optionally serve another version of the same file
+	if version := c.Query("version"); version != "" {
+		if fileInfo, exists = s.findVersion(fileInfo, version); !exists {
+			writeError(c, http.StatusNotFound, "Version not found")
+			return
+		}
+	}
+
+	// The chunks streamed stay in place until the download is done, matching the size and
+	// hash sent in the headers, even if the file is appended to or deleted meanwhile
+	fileInfo, unpin, exists := s.readFile(fileInfo)
+	if !exists {
+		s.writeFileMissing(c, fileID)
 		return
 	}
+	defer unpin()
 
-	// Set response headers
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.Name))
+	c.Header("Accept-Ranges", "bytes")
+	etag := fileETag(fileInfo)
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+
+	// Serve a partial response for single-range requests, unless the client asks for the
+	// range of content that has since changed
+	rangeHeader := c.GetHeader("Range")
+	if ifRange := c.GetHeader("If-Range"); ifRange != "" && ifRange != etag {
+		rangeHeader = ""
+	}
+	if rangeHeader != "" {
+		start, end, ok, err := parseRange(rangeHeader, fileInfo.Size)
+		if err != nil {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", fileInfo.Size))
+			writeError(c, http.StatusRequestedRangeNotSatisfiable, "Range not satisfiable")
+			return
+		}
+
+		if ok {
+			c.Header("Content-Type", fileInfo.ContentType)
+			c.Header("Content-Length", strconv.FormatInt(end-start+1, 10))
+			c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileInfo.Size))
+			c.Status(http.StatusPartialContent)
+
+			err := s.chunkManager.RetrieveFileRangeTo(c.Request.Context(), fileInfo, start, end, c.Writer)
+			if !s.finishStream(c, err, "Failed to retrieve file range") {
+				return
+			}
+
+			s.log(c).WithFields(logrus.Fields{
+				"file_id": fileInfo.ID,
+				"start":   start,
+				"end":     end,
+			}).Info("File range downloaded successfully")
+			return
+		}
+	}
+
+	if s.sendFile(c, fileInfo) {
+		s.log(c).WithFields(logrus.Fields{
+			"file_id":   fileInfo.ID,
+			"file_name": fileInfo.Name,
+		}).Info("File downloaded successfully")
+	}
+}
+
+// fileETag returns the entity tag of a file's content, which changes whenever the content
+// does, or "" for files stored without a hash
+func fileETag(fileInfo *types.FileInfo) string {
+	if fileInfo.Hash == "" {
+		return ""
+	}
+	return `"` + fileInfo.Hash + `"`
+}
+
+// sendFile streams the whole content of a file as the response, reporting whether it
+// succeeded
+func (s *Server) sendFile(c *gin.Context, fileInfo *types.FileInfo) bool {
 	c.Header("Content-Type", fileInfo.ContentType)
 	c.Header("Content-Length", strconv.FormatInt(fileInfo.Size, 10))
+	c.Status(http.StatusOK)
 
-	// Send file data
-	c.DataFromReader(http.StatusOK, fileInfo.Size, fileInfo.ContentType, bytes.NewReader(data), nil)
+	return s.finishStream(c, s.chunkManager.RetrieveFileTo(c.Request.Context(), fileInfo, c.Writer), "Failed to retrieve file")
+}
 
-	s.logger.WithFields(logrus.Fields{
-		"file_id":   fileInfo.ID,
-		"file_name": fileInfo.Name,
-	}).Info("File downloaded successfully")
+// finishStream handles the outcome of streaming file content into the response. When
+// retrieval failed before anything was sent the response becomes a 500; after that the
+// client can only be cut off, which it notices from the short body.
+func (s *Server) finishStream(c *gin.Context, err error, message string) bool {
+	if err == nil {
+		return true
+	}
+
+	s.log(c).WithError(err).Error(message)
+	if c.Writer.Written() {
+		c.Abort()
+		return false
+	}
+
+	// Drop the headers describing the file so they do not apply to the error body
+	header := c.Writer.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Range")
+	writeError(c, http.StatusInternalServerError, "Failed to retrieve file")
+	return false
+}
+
+// parseRange parses a single-range "bytes=" Range header against a file size.
+// It returns ok=false when the header should be ignored (unknown unit or multiple ranges)
+// and an error when the range cannot be satisfied.
+func parseRange(header string, size int64) (start, end int64, ok bool, err error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, false, nil
+	}
+
+	spec := strings.TrimSpace(strings.TrimPrefix(header, prefix))
+	if strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+
+	startStr, endStr, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false, fmt.Errorf("invalid range: %s", header)
+	}
+	startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+	if startStr == "" {
+		// Suffix range: last N bytes
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, false, fmt.Errorf("invalid range: %s", header)
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, size - 1, true, nil
+	}
+
+	start, err = strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false, fmt.Errorf("invalid range: %s", header)
+	}
+
+	end = size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, fmt.Errorf("invalid range: %s", header)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, true, nil
 }
 
 // deleteFile handles file deletion
 func (s *Server) deleteFile(c *gin.Context) {
-	fileID := c.Param("id")
+	permanent := c.Query("permanent") == "true"
+	allVersions := c.Query("all_versions") == "true"
 
-	// Get file info
-	fileInfo, exists := s.files[fileID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	status, message := s.deleteOne(c, c.Param("id"), permanent, allVersions)
+	if status != http.StatusOK {
+		writeError(c, status, message)
 		return
 	}
 
-	// Delete file chunks
-	if err := s.chunkManager.DeleteFile(fileInfo); err != nil {
-		s.logger.WithError(err).Error("Failed to delete file")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
-		return
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// deleteOne deletes a file on behalf of the caller, returning the HTTP status and the message
+// or error describing the outcome. Deleted files go to the trash unless retention is
+// disabled or permanent deletion is requested.
+func (s *Server) deleteOne(c *gin.Context, fileID string, permanent, allVersions bool) (int, string) {
+	fileInfo, exists := s.lookupFile(fileID)
+	if !exists {
+		return http.StatusNotFound, "File not found"
+	}
+
+	if fileInfo.Owner != s.principal(c) {
+		return http.StatusForbidden, "Access denied"
 	}
 
-	// Remove metadata
-	delete(s.files, fileID)
+	permanent = permanent || s.config.Storage.TrashRetention == 0
+	if fileInfo.DeletedAt != nil && !permanent {
+		return http.StatusNotFound, "File not found"
+	}
 
-	s.logger.WithFields(logrus.Fields{
-		"file_id":   fileInfo.ID,
-		"file_name": fileInfo.Name,
-	}).Info("File deleted successfully")
+	// Delete only this version unless the whole chain is requested
+	targets := []*types.FileInfo{fileInfo}
+	if allVersions {
+		targets = s.versionsOf(fileInfo, permanent)
+	}
+
+	if !permanent {
+		for _, target := range targets {
+			s.trashFile(target)
+			s.publishDeleted(target)
+		}
+		return http.StatusOK, "File moved to trash"
+	}
+
+	for _, target := range targets {
+		if err := s.purgeFile(target); err != nil {
+			s.log(c).WithError(err).Error("Failed to delete file")
+			return http.StatusInternalServerError, "Failed to delete file"
+		}
+		s.publishDeleted(target)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully"})
+	return http.StatusOK, "File deleted successfully"
 }
 
 // listFiles handles file listing
 func (s *Server) listFiles(c *gin.Context) {
 	var files []gin.H
+	includeDeleted := c.Query("include_deleted") == "true"
+	now := time.Now()
 
-	for _, fileInfo := range s.files {
-		files = append(files, gin.H{
+	// Repeated tag=key=value parameters must all match
+	filter, err := metadata.ParseTags(c.QueryArray("tag"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "Invalid tag filter")
+		return
+	}
+	var tagged map[string]bool
+	if filter != nil {
+		if tagged, err = s.taggedFiles(filter); err != nil {
+			s.log(c).WithError(err).Error("Failed to look up tagged files")
+			writeError(c, http.StatusInternalServerError, "Failed to list files")
+			return
+		}
+	}
+
+	s.eachFile(func(fileInfo *types.FileInfo) {
+		if fileInfo.DeletedAt != nil && !includeDeleted {
+			return
+		}
+		if expired(fileInfo, now) {
+			return
+		}
+		if tagged != nil && !tagged[fileInfo.ID] {
+			return
+		}
+
+		entry := gin.H{
 			"id":           fileInfo.ID,
 			"name":         fileInfo.Name,
 			"size":         fileInfo.Size,
 			"content_type": fileInfo.ContentType,
 			"created_at":   fileInfo.CreatedAt,
 			"owner":        fileInfo.Owner,
-		})
-	}
+			"version":      fileInfo.Version,
+		}
+		if fileInfo.DeletedAt != nil {
+			entry["deleted_at"] = fileInfo.DeletedAt
+		}
+		if fileInfo.ExpiresAt != nil {
+			entry["expires_at"] = fileInfo.ExpiresAt
+		}
+		if len(fileInfo.Tags) > 0 {
+			entry["tags"] = fileInfo.Tags
+		}
+		files = append(files, entry)
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"files": files,
@@ -219,29 +775,159 @@ func (s *Server) listFiles(c *gin.Context) {
 func (s *Server) getFileInfo(c *gin.Context) {
 	fileID := c.Param("id")
 
-	fileInfo, exists := s.files[fileID]
+	fileInfo, exists := s.activeFile(fileID)
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		s.writeFileMissing(c, fileID)
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, true) {
 		return
 	}
 
 	c.JSON(http.StatusOK, fileInfo)
 }
 
-// getNodeInfo handles node information retrieval
+// updatableFields are the file metadata fields a PATCH request may change
+var updatableFields = map[string]bool{
+	"name":         true,
+	"content_type": true,
+	"public":       true,
+	"tags":         true,
+}
+
+// updateFile handles changing a file's metadata without touching its chunks.
+// The file ID is kept, and a rename applies to every version so the chain stays together.
+func (s *Server) updateFile(c *gin.Context) {
+	unlock := s.fileLocks.lock(c.Param("id"))
+	defer unlock()
+
+	fileInfo, exists := s.activeFile(c.Param("id"))
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, false) {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "Invalid update request")
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || len(fields) == 0 {
+		writeError(c, http.StatusBadRequest, "Invalid update request")
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !updatableFields[name] {
+			writeAPIError(c, http.StatusBadRequest, &APIError{
+				Code:    CodeImmutableField,
+				Message: fmt.Sprintf("Field cannot be updated: %s", name),
+				Details: gin.H{"field": name},
+			})
+			return
+		}
+	}
+
+	var req struct {
+		Name        *string            `json:"name"`
+		ContentType *string            `json:"content_type"`
+		Public      *bool              `json:"public"`
+		Tags        *map[string]string `json:"tags"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(c, http.StatusBadRequest, "Invalid update request")
+		return
+	}
+	if req.Tags != nil {
+		if err := metadata.ValidateTags(*req.Tags); err != nil {
+			writeError(c, http.StatusBadRequest, "Invalid tag")
+			return
+		}
+	}
+
+	var versions []*types.FileInfo
+	if req.Name != nil && *req.Name != fileInfo.Name {
+		if strings.TrimSpace(*req.Name) == "" {
+			writeError(c, http.StatusBadRequest, "Invalid file name")
+			return
+		}
+
+		// Taking another chain's name would merge the two version chains
+		renamed := &types.FileInfo{Name: *req.Name, Owner: fileInfo.Owner}
+		if len(s.versionsOf(renamed, true)) > 0 {
+			writeError(c, http.StatusConflict, "File name already in use")
+			return
+		}
+		versions = s.versionsOf(fileInfo, true)
+	}
+
+	if req.Tags != nil {
+		fileInfo = s.setTags(fileInfo, *req.Tags)
+	}
+
+	now := time.Now()
+	s.updateFiles(func() {
+		for _, version := range versions {
+			if stored, ok := s.files[version.ID]; ok {
+				renamed := copyFileInfo(stored)
+				renamed.Name = *req.Name
+				renamed.UpdatedAt = now
+				s.files[version.ID] = renamed
+			}
+		}
+
+		stored, ok := s.files[fileInfo.ID]
+		if !ok {
+			return
+		}
+		updated := copyFileInfo(stored)
+		if req.ContentType != nil {
+			updated.ContentType = *req.ContentType
+		}
+		if req.Public != nil {
+			updated.Public = *req.Public
+		}
+		updated.UpdatedAt = now
+		s.files[fileInfo.ID] = updated
+		fileInfo = copyFileInfo(updated)
+	})
+
+	s.log(c).WithFields(logrus.Fields{
+		"file_id":   fileInfo.ID,
+		"file_name": fileInfo.Name,
+	}).Info("File metadata updated")
+
+	c.JSON(http.StatusOK, fileInfo)
+}
+
+// getNodeInfo handles node information retrieval. The node has no identity key pair to
+// report, and reputation is only kept for peers, so both are left out.
 func (s *Server) getNodeInfo(c *gin.Context) {
 	usage, err := s.storage.GetUsage()
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get storage usage")
+		s.log(c).WithError(err).Error("Failed to get storage usage")
 		usage = 0
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"node_id":      "node-001", // Should be dynamic
-		"status":       "online",
-		"storage_used": usage,
-		"files_count":  len(s.files),
-		"last_seen":    time.Now(),
+	c.JSON(http.StatusOK, types.NodeInfo{
+		ID:           s.config.Node.ID,
+		Address:      s.config.API.Host,
+		Port:         s.config.API.Port,
+		StorageUsed:  usage,
+		StorageTotal: s.config.Node.MaxStorage,
+		Status:       s.nodeStatus(),
+		LastSeen:     time.Now(),
 	})
 }
 
@@ -249,37 +935,258 @@ func (s *Server) getNodeInfo(c *gin.Context) {
 func (s *Server) getNodeStats(c *gin.Context) {
 	usage, err := s.storage.GetUsage()
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get storage usage")
+		s.log(c).WithError(err).Error("Failed to get storage usage")
 		usage = 0
 	}
 
 	filesList, err := s.storage.List()
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to list files")
+		s.log(c).WithError(err).Error("Failed to list files")
 		filesList = []string{}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"storage_usage":  usage,
 		"file_count":     len(filesList),
-		"metadata_count": len(s.files),
-		"uptime":         time.Since(time.Now()), // Should track actual uptime
+		"metadata_count": s.fileCount(),
+		"uptime":         time.Since(s.started),
+		"status":         s.nodeStatus(),
+	})
+}
+
+// nodeStatus reports the node offline once the server has started shutting down
+func (s *Server) nodeStatus() types.NodeStatus {
+	select {
+	case <-s.done:
+		return types.NodeStatusOffline
+	default:
+		return types.NodeStatusOnline
+	}
+}
+
+// getNodePeers handles listing the P2P peers known to this node
+func (s *Server) getNodePeers(c *gin.Context) {
+	peers := []types.NodeInfo{}
+	if s.peers != nil {
+		peers = s.peers.Peers()
+	}
+
+	online := 0
+	for _, peer := range peers {
+		if peer.Status == types.NodeStatusOnline {
+			online++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"peers":  peers,
+		"count":  len(peers),
+		"online": online,
 	})
 }
 
-// healthCheck handles health check requests
-func (s *Server) healthCheck(c *gin.Context) {
+// getChunkPlacement handles reporting which nodes a chunk is placed on, for debugging
+// replica placement
+func (s *Server) getChunkPlacement(c *gin.Context) {
+	chunkID := c.Param("chunk_id")
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now(),
-		"version":   "1.0.0",
+		"chunk_id": chunkID,
+		"nodes":    s.chunkManager.ChunkPlacement(chunkID),
 	})
 }
 
-// Start starts the HTTP server
+// getScrubStatus handles integrity scrub status retrieval
+func (s *Server) getScrubStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.scrubber.Status())
+}
+
+// getGCStatus handles orphaned chunk garbage collection status retrieval
+func (s *Server) getGCStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.gc.Status())
+}
+
+// storedChunks returns the chunks of every stored file, including trashed ones
+func (s *Server) storedChunks() []types.ChunkInfo {
+	var chunks []types.ChunkInfo
+	s.eachFile(func(fileInfo *types.FileInfo) {
+		chunks = append(chunks, fileInfo.Chunks...)
+	})
+	return chunks
+}
+
+// referencedChunks returns the chunks the garbage collector must keep: those of every
+// stored file, and those released while a download still reads them
+func (s *Server) referencedChunks() []types.ChunkInfo {
+	return append(s.storedChunks(), s.chunkManager.PinnedChunks()...)
+}
+
+// setQuota handles setting an owner's storage quota
+func (s *Server) setQuota(c *gin.Context) {
+	var req struct {
+		Quota *int64 `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == nil {
+		writeError(c, http.StatusBadRequest, "Invalid quota")
+		return
+	}
+
+	owner := c.Param("owner")
+	if err := s.quotas.SetQuota(owner, *req.Quota); err != nil {
+		writeError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.log(c).WithFields(logrus.Fields{
+		"owner": owner,
+		"quota": *req.Quota,
+	}).Info("Quota updated")
+
+	c.JSON(http.StatusOK, gin.H{
+		"owner": owner,
+		"quota": *req.Quota,
+	})
+}
+
+// getQuota handles reading an owner's storage quota and usage
+func (s *Server) getQuota(c *gin.Context) {
+	status, err := s.quotas.Status(c.Param("owner"))
+	if err != nil {
+		s.log(c).WithError(err).Error("Failed to read quota")
+		writeError(c, http.StatusInternalServerError, "Failed to read quota")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// releaseQuota returns bytes to an owner's quota, logging failures
+func (s *Server) releaseQuota(owner string, size int64) {
+	if err := s.quotas.Release(owner, size); err != nil {
+		s.logger.WithError(err).WithField("owner", owner).Warn("Failed to release quota")
+	}
+}
+
+// Start starts the HTTP server, or the HTTPS server when TLS is enabled, and blocks
+// until it is shut down
 func (s *Server) Start(addr string) error {
-	s.logger.WithField("address", addr).Info("Starting API server")
-	return s.router.Run(addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	if s.config.API.TLS {
+		return s.ServeTLS(listener)
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves HTTP requests on the listener until the server is shut down.
+// It returns nil after a graceful shutdown.
+func (s *Server) Serve(listener net.Listener) error {
+	s.logger.WithField("address", listener.Addr().String()).Info("Starting API server")
+
+	// Connection-level errors such as rejected TLS handshakes go to the server log
+	errorLog := s.logger.WriterLevel(logrus.WarnLevel)
+	defer errorLog.Close()
+	httpServer := &http.Server{Handler: s.router, ErrorLog: log.New(errorLog, "", 0)}
+
+	if s.config.Storage.TrashRetention > 0 {
+		go s.runTrashSweeper(trashSweepInterval, s.done)
+	}
+	go s.runReaper(expirySweepInterval, s.done)
+	if s.config.API.Uploads.SessionTTL > 0 {
+		go s.runUploadSweeper(uploadSweepInterval, s.done)
+	}
+	go s.rekeyer.Run(s.config.Crypto.RekeyInterval, s.done)
+	if s.config.Storage.GCInterval > 0 {
+		go s.gc.Run(s.config.Storage.GCInterval, s.done)
+	}
+
+	s.mu.Lock()
+	s.httpServer = httpServer
+	s.startScrubber()
+	s.mu.Unlock()
+
+	if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops background work and gracefully shuts down the HTTP server,
+// waiting for in-flight requests to finish until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	httpServer := s.httpServer
+	redirectServer := s.redirectServer
+	s.stopScrubber()
+	s.mu.Unlock()
+
+	if redirectServer != nil {
+		redirectServer.Close()
+	}
+	if httpServer == nil {
+		return nil
+	}
+
+	s.logger.Info("Shutting down API server")
+	return httpServer.Shutdown(ctx)
+}
+
+// SetAnchorer anchors the root hash of every uploaded file on-chain
+func (s *Server) SetAnchorer(anchorer *blockchain.Anchorer) {
+	s.anchorer = anchorer
+}
+
+// SetPeerRegistry exposes the node's P2P peers through the API
+func (s *Server) SetPeerRegistry(registry *p2p.NodeRegistry) {
+	s.peers = registry
+}
+
+// ApplyConfig applies settings changed by a config reload: the default quota and the scrub interval
+func (s *Server) ApplyConfig(cfg *config.Config) {
+	s.quotas.SetDefaultQuota(cfg.Storage.DefaultQuota)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config.Storage.DefaultQuota = cfg.Storage.DefaultQuota
+	if cfg.Storage.ScrubInterval == s.config.Storage.ScrubInterval {
+		return
+	}
+	s.config.Storage.ScrubInterval = cfg.Storage.ScrubInterval
+
+	// Restart the scrub loop with the new interval if the server is running
+	if s.httpServer != nil {
+		s.startScrubber()
+	}
+}
+
+// startScrubber (re)starts the periodic scrubber with the configured interval.
+// Callers must hold s.mu.
+func (s *Server) startScrubber() {
+	s.stopScrubber()
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	if s.config.Storage.ScrubInterval > 0 {
+		s.scrubStop = make(chan struct{})
+		go s.scrubber.Run(s.config.Storage.ScrubInterval, s.scrubStop)
+	}
+}
+
+// stopScrubber stops the periodic scrubber if it is running. Callers must hold s.mu.
+func (s *Server) stopScrubber() {
+	if s.scrubStop != nil {
+		close(s.scrubStop)
+		s.scrubStop = nil
+	}
 }
 
 // GetRouter returns the gin router for testing
@@ -0,0 +1,983 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/blockchain"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/p2p"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestServer creates a server backed by temporary storage with a small chunk size
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	return newTestServerWithConfig(t, config.DefaultConfig())
+}
+
+// newTestServerWithConfig creates a test server using the given configuration
+func newTestServerWithConfig(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fileStorage, err := storage.NewFileStorage(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	store := metadata.NewMemoryStore()
+	chunkManager := storage.NewChunkManager(fileStorage, key, 4, logger)
+	chunkManager.EnableDeduplication(store)
+	return NewServer(cfg, fileStorage, chunkManager, store, logger)
+}
+
+// uploadTestFile uploads content through the API and returns the file ID
+func uploadTestFile(t *testing.T, server *Server, name string, content []byte) string {
+	t.Helper()
+
+	return fileIDFromResponse(t, doUpload(t, server, name, content))
+}
+
+// doUpload performs a multipart upload request and returns the recorded response
+func doUpload(t *testing.T, server *Server, name string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	return serve(server, newUploadRequest(t, name, content, nil))
+}
+
+// newUploadRequest builds a multipart upload request with optional extra form fields
+func newUploadRequest(t *testing.T, name string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// serve runs a request through the server router and returns the recorded response
+func serve(server *Server, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	server.GetRouter().ServeHTTP(w, req)
+	return w
+}
+
+// fileIDFromResponse extracts the file ID from an upload response
+func fileIDFromResponse(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse upload response: %v", err)
+	}
+
+	return result["file_id"].(string)
+}
+
+func TestDownloadRange(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("0123456789abcdefghij")
+	fileID := uploadTestFile(t, server, "range.txt", content)
+
+	tests := []struct {
+		name         string
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"full file", "", http.StatusOK, string(content), ""},
+		{"mid-file range", "bytes=3-9", http.StatusPartialContent, "3456789", "bytes 3-9/20"},
+		{"open-ended range", "bytes=15-", http.StatusPartialContent, "fghij", "bytes 15-19/20"},
+		{"suffix range", "bytes=-2", http.StatusPartialContent, "ij", "bytes 18-19/20"},
+		{"unsatisfiable range", "bytes=50-60", http.StatusRequestedRangeNotSatisfiable, "", "bytes */20"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)
+		if test.rangeHeader != "" {
+			req.Header.Set("Range", test.rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		server.GetRouter().ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, w.Code)
+			continue
+		}
+
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: expected body %q, got %q", test.name, test.body, w.Body.String())
+		}
+
+		if got := w.Header().Get("Content-Range"); got != test.contentRange {
+			t.Errorf("%s: expected Content-Range %q, got %q", test.name, test.contentRange, got)
+		}
+
+		if test.status != http.StatusRequestedRangeNotSatisfiable && w.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("%s: expected Accept-Ranges bytes", test.name)
+		}
+	}
+}
+
+func TestDownloadIfRange(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("0123456789abcdefghij")
+	fileID := uploadTestFile(t, server, "etag.txt", content)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag on the download")
+	}
+
+	tests := []struct {
+		name    string
+		ifRange string
+		status  int
+		body    string
+	}{
+		{"matching ETag", etag, http.StatusPartialContent, "fghij"},
+		{"changed ETag", `"stale"`, http.StatusOK, string(content)},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)
+		req.Header.Set("Range", "bytes=15-")
+		req.Header.Set("If-Range", test.ifRange)
+		w := serve(server, req)
+
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, w.Code)
+			continue
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected body %q, got %q", test.name, test.body, w.Body.String())
+		}
+		if w.Header().Get("ETag") != etag {
+			t.Errorf("%s: expected ETag %s, got %s", test.name, etag, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestDownloadMissingChunk(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadTestFile(t, server, "broken.txt", []byte("0123456789abcdefghij"))
+
+	fileInfo, _ := server.lookupFile(fileID)
+	if err := server.storage.Delete(context.Background(), fileInfo.Chunks[0].ID); err != nil {
+		t.Fatalf("Failed to delete chunk: %v", err)
+	}
+
+	for _, rangeHeader := range []string{"", "bytes=0-5"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		w := serve(server, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Range %q: expected status 500, got %d", rangeHeader, w.Code)
+			continue
+		}
+		if got := w.Header().Get("Content-Range"); got != "" {
+			t.Errorf("Range %q: expected no Content-Range on error, got %q", rangeHeader, got)
+		}
+		if code := decodeError(t, w.Body.Bytes())["code"]; code != CodeInternal {
+			t.Errorf("Range %q: expected code %s, got %v", rangeHeader, CodeInternal, code)
+		}
+	}
+}
+
+func TestUploadMaxFileSize(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Storage.MaxFileSize = 100
+	server := newTestServerWithConfig(t, cfg)
+
+	// Just under the limit
+	w := doUpload(t, server, "small.bin", bytes.Repeat([]byte("a"), 99))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for file under limit, got %d", w.Code)
+	}
+
+	// Exactly at the limit
+	w = doUpload(t, server, "exact.bin", bytes.Repeat([]byte("b"), 100))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for file at limit, got %d", w.Code)
+	}
+
+	// Just over the limit
+	w = doUpload(t, server, "large.bin", bytes.Repeat([]byte("c"), 101))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for file over limit, got %d", w.Code)
+	}
+
+	// Far over the limit is rejected from Content-Length alone
+	w = doUpload(t, server, "huge.bin", bytes.Repeat([]byte("d"), 2*multipartOverhead))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for huge file, got %d", w.Code)
+	}
+}
+
+func TestOwnerAccessControl(t *testing.T) {
+	server := newTestServer(t)
+
+	req := newUploadRequest(t, "private.txt", []byte("private data"), nil)
+	req.Header.Set("X-Owner", "alice")
+	privateID := fileIDFromResponse(t, serve(server, req))
+
+	req = newUploadRequest(t, "public.txt", []byte("public data"), map[string]string{"public": "true"})
+	req.Header.Set("X-Owner", "alice")
+	publicID := fileIDFromResponse(t, serve(server, req))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		owner  string
+		status int
+	}{
+		{"owner download", http.MethodGet, "/api/v1/files/" + privateID, "alice", http.StatusOK},
+		{"owner info", http.MethodGet, "/api/v1/files/" + privateID + "/info", "alice", http.StatusOK},
+		{"non-owner download", http.MethodGet, "/api/v1/files/" + privateID, "bob", http.StatusForbidden},
+		{"non-owner info", http.MethodGet, "/api/v1/files/" + privateID + "/info", "bob", http.StatusForbidden},
+		{"anonymous download", http.MethodGet, "/api/v1/files/" + privateID, "", http.StatusForbidden},
+		{"non-owner delete", http.MethodDelete, "/api/v1/files/" + privateID, "bob", http.StatusForbidden},
+		{"public download", http.MethodGet, "/api/v1/files/" + publicID, "bob", http.StatusOK},
+		{"public info", http.MethodGet, "/api/v1/files/" + publicID + "/info", "", http.StatusOK},
+		{"public non-owner delete", http.MethodDelete, "/api/v1/files/" + publicID, "bob", http.StatusForbidden},
+		{"owner delete", http.MethodDelete, "/api/v1/files/" + privateID, "alice", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.owner != "" {
+			req.Header.Set("X-Owner", test.owner)
+		}
+
+		if w := serve(server, req); w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, w.Code)
+		}
+	}
+}
+
+func TestReuploadByAnotherOwner(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("alice's data")
+	fileID := uploadAs(t, server, "alice", "report.txt", content)
+
+	// The same name and content from another owner yields the same ID, which stays alice's
+	req := newUploadRequest(t, "report.txt", content, nil)
+	req.Header.Set("X-Owner", "mallory")
+	if w := serve(server, req); w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 for another owner's file ID, got %d", w.Code)
+	}
+
+	if w := getAs(server, "alice", "/api/v1/files/"+fileID); w.Code != http.StatusOK || w.Body.String() != string(content) {
+		t.Errorf("Expected alice's file to stay readable, got %d %q", w.Code, w.Body.String())
+	}
+	if w := requestAs(server, http.MethodDelete, "mallory", "/api/v1/files/"+fileID+"?permanent=true"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected mallory not to gain the file, got %d deleting it", w.Code)
+	}
+	if usage, _ := server.quotas.Usage("mallory"); usage != 0 {
+		t.Errorf("Expected the rejected upload to release its quota, got %d", usage)
+	}
+
+	// Completing a resumable upload of another owner's file is refused once it is stored
+	bobContent := []byte("bob's data")
+	bobID := uploadAs(t, server, "bob", "shared.txt", bobContent)
+	uploadID := createTestUpload(t, server, "shared.txt", len(bobContent))
+	for i := 0; i*4 < len(bobContent); i++ {
+		chunk := bobContent[i*4 : min(i*4+4, len(bobContent))]
+		if w := putTestChunk(server, uploadID, i, chunk); w.Code != http.StatusOK {
+			t.Fatalf("Chunk %d failed with status %d", i, w.Code)
+		}
+	}
+	if w := completeTestUpload(server, uploadID); w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 completing an upload of another owner's file ID, got %d", w.Code)
+	}
+	if w := getAs(server, "bob", "/api/v1/files/"+bobID); w.Code != http.StatusOK || w.Body.String() != string(bobContent) {
+		t.Errorf("Expected bob's file to stay readable, got %d %q", w.Code, w.Body.String())
+	}
+	if usage, _ := server.quotas.Usage("alice"); usage != int64(len(content)) {
+		t.Errorf("Expected alice to be charged only for her own file, got %d", usage)
+	}
+}
+
+func TestOwnerQuota(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Storage.DefaultQuota = 20
+	cfg.API.AdminToken = "secret"
+	server := newTestServerWithConfig(t, cfg)
+
+	upload := func(name string, size int) *httptest.ResponseRecorder {
+		req := newUploadRequest(t, name, bytes.Repeat([]byte("q"), size), nil)
+		req.Header.Set("X-Owner", "alice")
+		return serve(server, req)
+	}
+
+	// Upload up to the quota
+	firstID := fileIDFromResponse(t, upload("first.bin", 12))
+	fileIDFromResponse(t, upload("second.bin", 8))
+
+	// Rejected past the quota
+	if w := upload("third.bin", 1); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 past quota, got %d", w.Code)
+	}
+
+	// Deleting recovers capacity
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/files/"+firstID, nil)
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusOK {
+		t.Fatalf("Delete failed with status %d", w.Code)
+	}
+
+	if w := upload("third.bin", 10); w.Code != http.StatusOK {
+		t.Errorf("Expected upload to succeed after delete, got %d", w.Code)
+	}
+
+	// Admin can raise the quota
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/admin/quotas/alice", strings.NewReader(`{"quota": 100}`))
+	req.Header.Set("X-Admin-Token", "secret")
+	if w := serve(server, req); w.Code != http.StatusOK {
+		t.Fatalf("Set quota failed with status %d", w.Code)
+	}
+
+	if w := upload("fourth.bin", 50); w.Code != http.StatusOK {
+		t.Errorf("Expected upload to succeed after quota increase, got %d", w.Code)
+	}
+
+	// Admin endpoints require the token
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/admin/quotas/alice", strings.NewReader(`{"quota": 1}`))
+	req.Header.Set("X-Admin-Token", "wrong")
+	if w := serve(server, req); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without admin token, got %d", w.Code)
+	}
+}
+
+func TestAdminQuotaAtRuntime(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Storage.DefaultQuota = 1000
+	cfg.API.AdminToken = "secret"
+	server := newTestServerWithConfig(t, cfg)
+
+	adminRequest := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/admin/quotas/bob", strings.NewReader(body))
+		req.Header.Set("X-Admin-Token", "secret")
+		return serve(server, req)
+	}
+	getQuota := func() storage.QuotaStatus {
+		t.Helper()
+		w := adminRequest(http.MethodGet, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Get quota failed with status %d", w.Code)
+		}
+		var status storage.QuotaStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Failed to parse quota: %v", err)
+		}
+		return status
+	}
+	upload := func(name string, size int) *httptest.ResponseRecorder {
+		req := newUploadRequest(t, name, bytes.Repeat([]byte("b"), size), nil)
+		req.Header.Set("X-Owner", "bob")
+		return serve(server, req)
+	}
+
+	if status := getQuota(); status.Owner != "bob" || status.Quota != 1000 || status.Used != 0 || !status.Default {
+		t.Errorf("Expected the default quota with nothing used, got %+v", status)
+	}
+
+	fileIDFromResponse(t, upload("first.bin", 30))
+
+	// Lowering the quota takes effect on the next upload
+	if w := adminRequest(http.MethodPut, `{"quota": 40}`); w.Code != http.StatusOK {
+		t.Fatalf("Set quota failed with status %d", w.Code)
+	}
+	if status := getQuota(); status.Quota != 40 || status.Used != 30 || status.Default {
+		t.Errorf("Expected quota 40 with 30 bytes used, got %+v", status)
+	}
+	if w := upload("second.bin", 20); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 past the new quota, got %d", w.Code)
+	}
+	if status := getQuota(); status.Used != 30 {
+		t.Errorf("Expected the rejected upload not to count, got %d bytes used", status.Used)
+	}
+	fileIDFromResponse(t, upload("third.bin", 10))
+
+	// Reading quotas is an admin operation too
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/quotas/bob", nil)
+	if w := serve(server, req); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without admin token, got %d", w.Code)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Storage.DefaultQuota = 10
+	server := newTestServerWithConfig(t, cfg)
+
+	upload := func(name string, size int) *httptest.ResponseRecorder {
+		req := newUploadRequest(t, name, bytes.Repeat([]byte("r"), size), nil)
+		req.Header.Set("X-Owner", "alice")
+		return serve(server, req)
+	}
+
+	if w := upload("big.bin", 20); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 past the default quota, got %d", w.Code)
+	}
+
+	reloaded := *cfg
+	reloaded.Storage.DefaultQuota = 100
+	reloaded.Storage.ScrubInterval = time.Hour
+	server.ApplyConfig(&reloaded)
+
+	if w := upload("big.bin", 20); w.Code != http.StatusOK {
+		t.Errorf("Expected upload to succeed after raising the default quota, got %d", w.Code)
+	}
+	if server.config.Storage.ScrubInterval != time.Hour {
+		t.Errorf("Expected scrub interval 1h, got %s", server.config.Storage.ScrubInterval)
+	}
+}
+
+func TestScrubStatus(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadTestFile(t, server, "scrub.txt", []byte("verify these chunks"))
+
+	chunk := server.files[fileID].Chunks[0]
+	server.storage.Store(context.Background(), chunk.ID, []byte("bit rot"))
+	server.scrubber.Scrub()
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/scrub", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var status storage.ScrubStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if status.LastRun == nil || status.LastRun.Corrupted != 1 || status.LastRun.Failures[0].ChunkID != chunk.ID {
+		t.Errorf("Expected scrub to flag chunk %s, got %+v", chunk.ID, status.LastRun)
+	}
+}
+
+func TestGCStatus(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Storage.GCGracePeriod = 0
+	server := newTestServerWithConfig(t, cfg)
+	fileID := uploadTestFile(t, server, "kept.txt", []byte("referenced by metadata"))
+
+	orphan := types.CalculateHash([]byte("orphan"))
+	server.storage.Store(context.Background(), orphan, []byte("leaked"))
+	server.gc.Collect()
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/gc", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var status storage.GCStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if status.LastRun == nil || len(status.LastRun.Deleted) != 1 || status.LastRun.Deleted[0] != orphan {
+		t.Errorf("Expected GC to delete orphan %s, got %+v", orphan, status.LastRun)
+	}
+
+	if w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)); w.Code != http.StatusOK {
+		t.Errorf("Expected referenced file to stay downloadable, got %d", w.Code)
+	}
+}
+
+func TestNodeInfo(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Node.ID = "node-042"
+	cfg.Node.MaxStorage = 1 << 30
+	cfg.API.Host = "10.0.0.42"
+	cfg.API.Port = 9090
+	server := newTestServerWithConfig(t, cfg)
+	uploadTestFile(t, server, "info.txt", []byte("counted as used storage"))
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/info", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var info types.NodeInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if info.ID != "node-042" || info.Address != "10.0.0.42" || info.Port != 9090 {
+		t.Errorf("Expected node-042 at 10.0.0.42:9090, got %s at %s:%d", info.ID, info.Address, info.Port)
+	}
+	if info.StorageTotal != 1<<30 {
+		t.Errorf("Expected storage total %d, got %d", 1<<30, info.StorageTotal)
+	}
+	if usage, _ := server.storage.GetUsage(); info.StorageUsed != usage || usage == 0 {
+		t.Errorf("Expected storage used %d, got %d", usage, info.StorageUsed)
+	}
+	if info.Status != types.NodeStatusOnline || info.LastSeen.IsZero() {
+		t.Errorf("Expected an online node seen now, got status %d at %v", info.Status, info.LastSeen)
+	}
+
+	// The node has no key pair, and reputation is only kept for peers
+	var fields map[string]json.RawMessage
+	json.Unmarshal(w.Body.Bytes(), &fields)
+	for _, name := range []string{"public_key", "reputation"} {
+		if value, exists := fields[name]; exists {
+			t.Errorf("Expected no %s, got %s", name, value)
+		}
+	}
+}
+
+func TestNodeStatsUptime(t *testing.T) {
+	server := newTestServer(t)
+	server.started = time.Now().Add(-90 * time.Second)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/stats", nil))
+	var stats struct {
+		Uptime time.Duration    `json:"uptime"`
+		Status types.NodeStatus `json:"status"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if stats.Uptime < 90*time.Second || stats.Uptime > time.Hour {
+		t.Errorf("Expected uptime since the server started, got %v", stats.Uptime)
+	}
+	if stats.Status != types.NodeStatusOnline {
+		t.Errorf("Expected an online node, got status %d", stats.Status)
+	}
+}
+
+func TestNodePeers(t *testing.T) {
+	server := newTestServer(t)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/peers", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"peers":[]`) {
+		t.Errorf("Expected empty peer list without a registry, got %d: %s", w.Code, w.Body.String())
+	}
+
+	registry := p2p.NewNodeRegistry(time.Minute, server.logger)
+	registry.Register(types.NodeInfo{ID: "node-002", Address: "/ip4/10.0.0.2/tcp/4001/p2p/node-002"})
+	registry.Register(types.NodeInfo{ID: "node-003"})
+	registry.RecordFailure("node-003")
+	server.SetPeerRegistry(registry)
+
+	w = serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/peers", nil))
+	var result struct {
+		Peers  []types.NodeInfo `json:"peers"`
+		Count  int              `json:"count"`
+		Online int              `json:"online"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.Count != 2 || result.Online != 2 || result.Peers[0].ID != "node-002" {
+		t.Fatalf("Expected two online peers, got %+v", result)
+	}
+	if result.Peers[1].Reputation >= result.Peers[0].Reputation {
+		t.Errorf("Expected failed peer to have lower reputation, got %+v", result.Peers)
+	}
+}
+
+// recordingClient is a blockchain client that records the transactions it is sent
+type recordingClient struct {
+	txs []blockchain.Transaction
+}
+
+func (r *recordingClient) SendTransaction(ctx context.Context, tx blockchain.Transaction) (string, error) {
+	r.txs = append(r.txs, tx)
+	return "0xanchored", nil
+}
+
+func TestAnchorUploads(t *testing.T) {
+	server := newTestServer(t)
+	client := &recordingClient{}
+	anchorer := blockchain.NewAnchorer(client, "0x"+strings.Repeat("11", 20), 500000, server.logger)
+	server.SetAnchorer(anchorer)
+
+	fileID := uploadTestFile(t, server, "anchored.txt", []byte("prove this file exists"))
+	anchorer.Close()
+
+	fileInfo := server.files[fileID]
+	if fileInfo.AnchorTx != "0xanchored" {
+		t.Errorf("Expected anchor transaction to be recorded, got %q", fileInfo.AnchorTx)
+	}
+
+	if len(client.txs) != 1 {
+		t.Fatalf("Expected one anchoring transaction, got %d", len(client.txs))
+	}
+	expected, _ := blockchain.AnchorCallData(fileID, fileInfo.MerkleRoot)
+	if !bytes.Equal(client.txs[0].Data, expected) {
+		t.Errorf("Expected call data anchoring %s with root %s, got %x", fileID, fileInfo.MerkleRoot, client.txs[0].Data)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	server := newTestServer(t)
+
+	fileID := uploadTestFile(t, server, "metrics.txt", []byte("count me"))
+	serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil))
+	serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/missing", nil))
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	tests := []string{
+		`dcs_file_operations_total{operation="upload",result="success"} 1`,
+		`dcs_file_operations_total{operation="download",result="success"} 1`,
+		`dcs_file_operations_total{operation="download",result="error"} 1`,
+		`dcs_http_requests_total{method="GET",route="/api/v1/files/:id",status="4xx"} 1`,
+		`dcs_http_request_duration_seconds_count{method="POST",route="/api/v1/files"} 1`,
+		`dcs_files 1`,
+	}
+
+	for _, line := range tests {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected metrics to contain %q", line)
+		}
+	}
+
+	if !strings.Contains(body, "dcs_storage_used_bytes ") {
+		t.Errorf("Expected storage usage gauge")
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	server := newTestServer(t)
+
+	// A slow handler stands in for an in-flight upload
+	entered := make(chan struct{})
+	server.GetRouter().GET("/slow", func(c *gin.Context) {
+		close(entered)
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	// Without keep-alives the transport cannot leave a spare idle connection open,
+	// which Shutdown would wait on until the context expires
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	baseURL := "http://" + listener.Addr().String()
+	resp, err := client.Get(baseURL + "/api/v1/health")
+	if err != nil {
+		t.Fatalf("Health request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	slowResult := make(chan string, 1)
+	go func() {
+		resp, err := client.Get(baseURL + "/slow")
+		if err != nil {
+			slowResult <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slowResult <- string(body)
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if result := <-slowResult; result != "done" {
+		t.Errorf("Expected in-flight request to complete, got %q", result)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Expected Serve to return nil after shutdown, got %v", err)
+	}
+
+	if _, err := client.Get(baseURL + "/api/v1/health"); err == nil {
+		t.Errorf("Expected requests to fail after shutdown")
+	}
+}
+
+func TestUploadNameField(t *testing.T) {
+	server := newTestServer(t)
+
+	req := newUploadRequest(t, "c.txt", []byte("nested"), map[string]string{"name": "mydir/sub/c.txt"})
+	fileID := fileIDFromResponse(t, serve(server, req))
+
+	if name := server.files[fileID].Name; name != "mydir/sub/c.txt" {
+		t.Errorf("Expected name mydir/sub/c.txt, got %s", name)
+	}
+}
+
+func TestUploadMetadata(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("metadata of a fresh upload")
+
+	w := doUpload(t, server, "fresh.txt", content)
+	fileID := fileIDFromResponse(t, w)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["hash"] != types.CalculateHash(content) || response["size"] != float64(len(content)) {
+		t.Errorf("Expected response with hash and size, got %v", response)
+	}
+
+	w = serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID+"/info", nil))
+	var info types.FileInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse file info: %v", err)
+	}
+	if info.Hash == "" || info.Hash != types.CalculateHash(content) {
+		t.Errorf("Expected hash %s, got %q", types.CalculateHash(content), info.Hash)
+	}
+	if info.Size != int64(len(content)) {
+		t.Errorf("Expected size %d, got %d", len(content), info.Size)
+	}
+	if !info.IsEncrypted || info.CreatedAt.IsZero() || info.UpdatedAt.IsZero() {
+		t.Errorf("Expected encrypted file with timestamps, got %+v", info)
+	}
+}
+
+// patchAs performs a PATCH request with a JSON body as the given owner
+func patchAs(server *Server, owner, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Owner", owner)
+	return serve(server, req)
+}
+
+func TestUpdateFile(t *testing.T) {
+	server := newTestServer(t)
+
+	v1 := uploadAs(t, server, "alice", "draft.txt", []byte("first draft"))
+	v2 := uploadAs(t, server, "alice", "draft.txt", []byte("second draft"))
+	uploadAs(t, server, "alice", "taken.txt", []byte("other file"))
+	chunks := server.files[v2].Chunks
+	server.files[v2].UpdatedAt = time.Time{}
+
+	w := patchAs(server, "alice", "/api/v1/files/"+v2, `{"name": "final.txt", "content_type": "text/plain", "public": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	fileInfo := server.files[v2]
+	if fileInfo.Name != "final.txt" || fileInfo.ContentType != "text/plain" || !fileInfo.Public {
+		t.Errorf("Expected updated metadata, got name %s, content type %s, public %v", fileInfo.Name, fileInfo.ContentType, fileInfo.Public)
+	}
+	if fileInfo.UpdatedAt.IsZero() {
+		t.Errorf("Expected UpdatedAt to be set")
+	}
+	if len(fileInfo.Chunks) != len(chunks) || fileInfo.Chunks[0].ID != chunks[0].ID {
+		t.Errorf("Expected chunks to be unchanged")
+	}
+
+	// The rename keeps the ID and carries the whole version chain along
+	if name := server.files[v1].Name; name != "final.txt" {
+		t.Errorf("Expected previous version to be renamed, got %s", name)
+	}
+	if w := getAs(server, "alice", "/api/v1/files/"+v2); w.Body.String() != "second draft" {
+		t.Errorf("Expected second draft, got %q", w.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		owner    string
+		body     string
+		expected int
+	}{
+		{"name in use", "alice", `{"name": "taken.txt"}`, http.StatusConflict},
+		{"empty name", "alice", `{"name": " "}`, http.StatusBadRequest},
+		{"empty body", "alice", `{}`, http.StatusBadRequest},
+		{"invalid json", "alice", `{"name":`, http.StatusBadRequest},
+		{"wrong type", "alice", `{"public": "yes"}`, http.StatusBadRequest},
+		{"other owner", "bob", `{"name": "stolen.txt"}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := patchAs(server, tt.owner, "/api/v1/files/"+v2, tt.body); w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+
+	if w := patchAs(server, "alice", "/api/v1/files/missing", `{"name": "x"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing file, got %d", w.Code)
+	}
+}
+
+func TestUpdateFileImmutableFields(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "report.txt", []byte("quarterly numbers"))
+	original := *server.files[fileID]
+
+	for _, field := range []string{"id", "hash", "size", "owner", "chunks", "created_at", "version"} {
+		t.Run(field, func(t *testing.T) {
+			body := `{"name": "renamed.txt", "` + field + `": "x"}`
+			w := patchAs(server, "alice", "/api/v1/files/"+fileID, body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), field) {
+				t.Errorf("Expected error to name %s, got %s", field, w.Body.String())
+			}
+		})
+	}
+
+	fileInfo := server.files[fileID]
+	if fileInfo.Name != original.Name || fileInfo.Hash != original.Hash || fileInfo.Size != original.Size {
+		t.Errorf("Expected rejected updates to leave the file unchanged")
+	}
+}
+
+func TestFileIDModes(t *testing.T) {
+	content := []byte("same bytes under two names")
+
+	tests := []struct {
+		mode          string
+		wantContentID string
+	}{
+		{"name", ""},
+		{"dedup", types.GenerateContentID(content)},
+	}
+
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.Storage.FileIDMode = tt.mode
+		server := newTestServerWithConfig(t, cfg)
+
+		a := uploadTestFile(t, server, "a.txt", content)
+		b := uploadTestFile(t, server, "b.txt", content)
+
+		// Each name keeps its own metadata entry in either mode
+		if a == b {
+			t.Errorf("%s: expected distinct file IDs for different names", tt.mode)
+		}
+		for _, id := range []string{a, b} {
+			fileInfo := server.files[id]
+			if string(fileInfo.IDMode) != tt.mode {
+				t.Errorf("%s: expected recorded mode %q, got %q", tt.mode, tt.mode, fileInfo.IDMode)
+			}
+			if fileInfo.ContentID != tt.wantContentID {
+				t.Errorf("%s: expected content ID %q, got %q", tt.mode, tt.wantContentID, fileInfo.ContentID)
+			}
+		}
+
+		// Deleting one name must leave the shared content readable under the other
+		if resp := requestAs(server, http.MethodDelete, "", "/api/v1/files/"+a+"?permanent=true"); resp.Code != http.StatusOK {
+			t.Fatalf("%s: expected delete status 200, got %d", tt.mode, resp.Code)
+		}
+		resp := requestAs(server, http.MethodGet, "", "/api/v1/files/"+b)
+		if resp.Code != http.StatusOK || !bytes.Equal(resp.Body.Bytes(), content) {
+			t.Errorf("%s: expected remaining file content, got %d %q", tt.mode, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestRandomIDScheme(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("identified at random")
+	contentHashID := uploadTestFile(t, server, "before.txt", content)
+
+	server.chunkManager.SetIDGenerator(types.RandomIDs{})
+	first := uploadTestFile(t, server, "random.txt", content)
+	second := uploadTestFile(t, server, "other.txt", content)
+
+	if first == types.GenerateFileID("random.txt", content) || first == second {
+		t.Errorf("Expected random file IDs, got %s and %s", first, second)
+	}
+	for _, tt := range []struct {
+		fileID string
+		want   types.IDScheme
+	}{
+		{contentHashID, types.IDSchemeContentHash},
+		{first, types.IDSchemeRandom},
+		{second, types.IDSchemeRandom},
+	} {
+		fileInfo, _ := server.lookupFile(tt.fileID)
+		if fileInfo.IDScheme != tt.want {
+			t.Errorf("Expected %s recorded for %s, got %q", tt.want, fileInfo.Name, fileInfo.IDScheme)
+		}
+
+		// Files resolve by their recorded IDs whatever generator is configured now
+		resp := requestAs(server, http.MethodGet, "", "/api/v1/files/"+tt.fileID)
+		if resp.Code != http.StatusOK || !bytes.Equal(resp.Body.Bytes(), content) {
+			t.Errorf("Expected %s to download, got %d %q", fileInfo.Name, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestChunkPlacement(t *testing.T) {
+	server := newTestServer(t)
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/placement/abc123", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var result struct {
+		ChunkID string   `json:"chunk_id"`
+		Nodes   []string `json:"nodes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	// Without peers every chunk stays on the local node only
+	if result.ChunkID != "abc123" || strings.Join(result.Nodes, ",") != "local" {
+		t.Errorf("Expected abc123 placed on the local node, got %+v", result)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Lifetimes of share links
+const (
+	defaultShareTTL = 24 * time.Hour
+	maxShareTTL     = 30 * 24 * time.Hour
+)
+
+// createShare handles issuing a time-limited link to download a file without authentication
+func (s *Server) createShare(c *gin.Context) {
+	fileInfo, exists := s.activeFile(c.Param("id"))
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, false) {
+		return
+	}
+
+	var req struct {
+		TTL       string `json:"ttl"`
+		SingleUse bool   `json:"single_use"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "Invalid share request")
+			return
+		}
+	}
+
+	ttl := defaultShareTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > maxShareTTL {
+			writeError(c, http.StatusBadRequest, "Invalid share ttl")
+			return
+		}
+	}
+
+	link, token, err := s.shares.Create(fileInfo.ID, fileInfo.Owner, ttl, req.SingleUse)
+	if err != nil {
+		s.log(c).WithError(err).Error("Failed to create share link")
+		writeError(c, http.StatusInternalServerError, "Failed to create share link")
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	s.log(c).WithFields(logrus.Fields{
+		"file_id":    fileInfo.ID,
+		"share_id":   link.ID,
+		"expires_at": link.ExpiresAt,
+	}).Info("Share link created")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"share_id":   link.ID,
+		"token":      token,
+		"url":        fmt.Sprintf("%s://%s/api/v1/shared/%s", scheme, c.Request.Host, token),
+		"expires_at": link.ExpiresAt,
+		"single_use": link.SingleUse,
+	})
+}
+
+// revokeShare handles invalidating a share link before it expires
+func (s *Server) revokeShare(c *gin.Context) {
+	fileInfo, exists := s.lookupFile(c.Param("id"))
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, false) {
+		return
+	}
+
+	link, err := s.shares.Get(c.Param("share_id"))
+	if err != nil || link.FileID != fileInfo.ID {
+		if err != nil && !errors.Is(err, storage.ErrShareRevoked) {
+			s.log(c).WithError(err).Error("Failed to load share link")
+			writeError(c, http.StatusInternalServerError, "Failed to revoke share link")
+			return
+		}
+		writeError(c, http.StatusNotFound, "Share link not found")
+		return
+	}
+
+	if err := s.shares.Revoke(link.ID); err != nil && !errors.Is(err, storage.ErrShareRevoked) {
+		s.log(c).WithError(err).Error("Failed to revoke share link")
+		writeError(c, http.StatusInternalServerError, "Failed to revoke share link")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked"})
+}
+
+// downloadShared handles downloading a file through a share link, without authentication
+func (s *Server) downloadShared(c *gin.Context) {
+	link, err := s.shares.Resolve(c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrShareExpired):
+			writeAPIError(c, http.StatusForbidden, &APIError{Code: CodeShareExpired, Message: "Share link expired"})
+		case errors.Is(err, storage.ErrShareInvalid), errors.Is(err, storage.ErrShareRevoked):
+			writeAPIError(c, http.StatusForbidden, &APIError{Code: CodeShareInvalid, Message: "Invalid share link"})
+		default:
+			s.log(c).WithError(err).Error("Failed to resolve share link")
+			writeError(c, http.StatusInternalServerError, "Failed to retrieve file")
+		}
+		return
+	}
+
+	// The link dies with the file, and never follows the ID to another owner's upload
+	fileInfo, exists := s.activeFile(link.FileID)
+	if !exists || fileInfo.Owner != link.Owner {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+	fileInfo, unpin, exists := s.readFile(fileInfo)
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+	defer unpin()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.Name))
+	if s.sendFile(c, fileInfo) {
+		s.log(c).WithFields(logrus.Fields{
+			"file_id":  fileInfo.ID,
+			"share_id": link.ID,
+		}).Info("Shared file downloaded")
+	}
+}
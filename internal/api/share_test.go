@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createShareAs requests a share link for a file and returns the response fields
+func createShareAs(t *testing.T, server *Server, owner, fileID, body string) (token, shareID string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/"+fileID+"/share", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Owner", owner)
+	w := serve(server, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create share failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var result struct {
+		ShareID string `json:"share_id"`
+		Token   string `json:"token"`
+		URL     string `json:"url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse share response: %v", err)
+	}
+	if !strings.HasSuffix(result.URL, "/api/v1/shared/"+result.Token) {
+		t.Errorf("Expected URL to carry the token, got %s", result.URL)
+	}
+	return result.Token, result.ShareID
+}
+
+func TestShareLink(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "photo.jpg", []byte("holiday photo"))
+
+	token, _ := createShareAs(t, server, "alice", fileID, "")
+
+	// No owner header is needed to follow the link
+	w := getAs(server, "", "/api/v1/shared/"+token)
+	if w.Code != http.StatusOK || w.Body.String() != "holiday photo" {
+		t.Fatalf("Expected shared file, got status %d: %q", w.Code, w.Body.String())
+	}
+	if w := getAs(server, "", "/api/v1/shared/"+token); w.Code != http.StatusOK {
+		t.Errorf("Expected link to be reusable, got status %d", w.Code)
+	}
+
+	// Only the owner can share a file
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/"+fileID+"/share", nil)
+	req.Header.Set("X-Owner", "mallory")
+	if w := serve(server, req); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another owner, got %d", w.Code)
+	}
+
+	// Deleting the file kills the link
+	requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+fileID)
+	if w := getAs(server, "", "/api/v1/shared/"+token); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestShareLinkExpired(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "photo.jpg", []byte("holiday photo"))
+
+	token, _ := createShareAs(t, server, "alice", fileID, `{"ttl": "10ms"}`)
+	time.Sleep(20 * time.Millisecond)
+
+	if w := getAs(server, "", "/api/v1/shared/"+token); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for expired link, got %d", w.Code)
+	}
+}
+
+func TestShareLinkTampered(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "photo.jpg", []byte("holiday photo"))
+
+	token, _ := createShareAs(t, server, "alice", fileID, "")
+
+	// Change a character inside the signature
+	i := len(token) - 5
+	replacement := "A"
+	if token[i] == 'A' {
+		replacement = "B"
+	}
+	tampered := token[:i] + replacement + token[i+1:]
+
+	for _, bad := range []string{tampered, "garbage", token[:len(token)/2]} {
+		if w := getAs(server, "", "/api/v1/shared/"+bad); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for token %q, got %d", bad, w.Code)
+		}
+	}
+}
+
+func TestShareLinkRevokeAndSingleUse(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "photo.jpg", []byte("holiday photo"))
+
+	token, shareID := createShareAs(t, server, "alice", fileID, "")
+	if w := requestAs(server, http.MethodDelete, "bob", "/api/v1/files/"+fileID+"/share/"+shareID); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 revoking another owner's link, got %d", w.Code)
+	}
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+fileID+"/share/"+shareID); w.Code != http.StatusOK {
+		t.Fatalf("Revoke failed with status %d", w.Code)
+	}
+	if w := getAs(server, "", "/api/v1/shared/"+token); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for revoked link, got %d", w.Code)
+	}
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+fileID+"/share/"+shareID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 revoking twice, got %d", w.Code)
+	}
+
+	token, _ = createShareAs(t, server, "alice", fileID, `{"single_use": true}`)
+	if w := getAs(server, "", "/api/v1/shared/"+token); w.Code != http.StatusOK {
+		t.Fatalf("Expected first use to succeed, got status %d", w.Code)
+	}
+	if w := getAs(server, "", "/api/v1/shared/"+token); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for reused single-use link, got %d", w.Code)
+	}
+}
+
+func TestShareInvalidTTL(t *testing.T) {
+	server := newTestServer(t)
+	fileID := uploadAs(t, server, "alice", "photo.jpg", []byte("holiday photo"))
+
+	for _, body := range []string{`{"ttl": "soon"}`, `{"ttl": "-1h"}`, `{"ttl": "8760h"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/files/"+fileID+"/share", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Owner", "alice")
+		if w := serve(server, req); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// indexTags adds a file to the tag index. Failures only degrade tag filtering, so they are logged.
+func (s *Server) indexTags(fileInfo *types.FileInfo) {
+	if err := s.tags.Add(fileInfo.ID, fileInfo.Tags); err != nil {
+		s.logger.WithError(err).WithField("file_id", fileInfo.ID).Warn("Failed to index file tags")
+	}
+}
+
+// unindexTags removes a file from the tag index
+func (s *Server) unindexTags(fileInfo *types.FileInfo) {
+	if err := s.tags.Remove(fileInfo.ID, fileInfo.Tags); err != nil {
+		s.logger.WithError(err).WithField("file_id", fileInfo.ID).Warn("Failed to unindex file tags")
+	}
+}
+
+// setTags replaces the tags of a file, keeping the index in step, and returns the file
+// as updated
+func (s *Server) setTags(fileInfo *types.FileInfo, tags map[string]string) *types.FileInfo {
+	s.unindexTags(fileInfo)
+	if len(tags) == 0 {
+		tags = nil
+	}
+	if updated, ok := s.modifyFile(fileInfo.ID, func(fileInfo *types.FileInfo) bool {
+		fileInfo.Tags = tags
+		return true
+	}); ok {
+		fileInfo = updated
+	}
+	s.indexTags(fileInfo)
+	return fileInfo
+}
+
+// taggedFiles returns the IDs of the files carrying every one of the given tags
+func (s *Server) taggedFiles(tags map[string]string) (map[string]bool, error) {
+	ids, err := s.tags.Lookup(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		matches[id] = true
+	}
+	return matches, nil
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// uploadTagged uploads content as alice with the given "key=value" tag fields
+func uploadTagged(t *testing.T, server *Server, name string, content []byte, tags ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("name", name)
+	for _, tag := range tags {
+		writer.WriteField("tag", tag)
+	}
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Owner", "alice")
+	return serve(server, req)
+}
+
+// listNames returns the sorted names of the files reported by the list endpoint
+func listNames(t *testing.T, server *Server, query string) []string {
+	t.Helper()
+
+	w := requestAs(server, http.MethodGet, "alice", "/api/v1/files"+query)
+	if w.Code != http.StatusOK {
+		t.Fatalf("List failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var result struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse list response: %v", err)
+	}
+
+	names := []string{}
+	for _, file := range result.Files {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestUploadTags(t *testing.T) {
+	server := newTestServer(t)
+
+	fileID := fileIDFromResponse(t, uploadTagged(t, server, "report.pdf", []byte("q3"), "env=prod", "team=finance"))
+
+	w := requestAs(server, http.MethodGet, "alice", "/api/v1/files/"+fileID+"/info")
+	var info struct {
+		Tags map[string]string `json:"tags"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse info response: %v", err)
+	}
+	expected := map[string]string{"env": "prod", "team": "finance"}
+	if !reflect.DeepEqual(info.Tags, expected) {
+		t.Errorf("Expected tags %v, got %v", expected, info.Tags)
+	}
+
+	if w := uploadTagged(t, server, "bad.txt", []byte("x"), "notatag"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid tag, got %d", w.Code)
+	}
+}
+
+func TestUpdateTags(t *testing.T) {
+	server := newTestServer(t)
+	fileID := fileIDFromResponse(t, uploadTagged(t, server, "notes.txt", []byte("notes"), "status=draft"))
+
+	w := patchAs(server, "alice", "/api/v1/files/"+fileID, `{"tags": {"status": "final", "owner": "ops"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	if names := listNames(t, server, "?tag=status=draft"); len(names) != 0 {
+		t.Errorf("Expected old tag to be unindexed, got %v", names)
+	}
+	if names := listNames(t, server, "?tag=status=final"); !reflect.DeepEqual(names, []string{"notes.txt"}) {
+		t.Errorf("Expected new tag to be indexed, got %v", names)
+	}
+
+	if w := patchAs(server, "alice", "/api/v1/files/"+fileID, `{"tags": {"": "x"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for empty tag key, got %d", w.Code)
+	}
+
+	// An empty map clears the tags
+	patchAs(server, "alice", "/api/v1/files/"+fileID, `{"tags": {}}`)
+	if tags := server.files[fileID].Tags; tags != nil {
+		t.Errorf("Expected tags to be cleared, got %v", tags)
+	}
+	if names := listNames(t, server, "?tag=status=final"); len(names) != 0 {
+		t.Errorf("Expected cleared tags to be unindexed, got %v", names)
+	}
+}
+
+func TestListTagFilter(t *testing.T) {
+	server := newTestServer(t)
+
+	uploadTagged(t, server, "a.txt", []byte("a"), "env=prod", "team=web")
+	uploadTagged(t, server, "b.txt", []byte("b"), "env=prod", "team=storage")
+	uploadTagged(t, server, "c.txt", []byte("c"), "env=dev", "team=storage")
+	uploadTagged(t, server, "d.txt", []byte("d"))
+
+	tests := []struct {
+		name     string
+		tags     []string
+		expected []string
+	}{
+		{"no filter", nil, []string{"a.txt", "b.txt", "c.txt", "d.txt"}},
+		{"single tag", []string{"env=prod"}, []string{"a.txt", "b.txt"}},
+		{"all tags must match", []string{"env=prod", "team=storage"}, []string{"b.txt"}},
+		{"no match", []string{"env=dev", "team=web"}, []string{}},
+		{"unknown value", []string{"env=staging"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := ""
+			if len(tt.tags) > 0 {
+				query = "?" + url.Values{"tag": tt.tags}.Encode()
+			}
+			if names := listNames(t, server, query); !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+
+	if w := requestAs(server, http.MethodGet, "alice", "/api/v1/files?tag=env"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid filter, got %d", w.Code)
+	}
+}
+
+func TestPurgeUnindexesTags(t *testing.T) {
+	server := newTestServer(t)
+	fileID := fileIDFromResponse(t, uploadTagged(t, server, "tmp.txt", []byte("tmp"), "kind=scratch"))
+
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+fileID+"?permanent=true"); w.Code != http.StatusOK {
+		t.Fatalf("Delete failed with status %d", w.Code)
+	}
+
+	ids, err := server.tags.Lookup(map[string]string{"kind": "scratch"})
+	if err != nil {
+		t.Fatalf("Failed to look up tags: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("Expected deleted file to be unindexed, got %v", ids)
+	}
+}
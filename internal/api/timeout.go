@@ -0,0 +1,21 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutMiddleware bounds the request's context by timeout. Handlers pass the context
+// down the storage path, so chunk reads and writes stop once it expires, just as they
+// do when the client disconnects.
+func timeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
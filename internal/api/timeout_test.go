@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(timeoutMiddleware(20 * time.Millisecond))
+
+	var deadline time.Time
+	var hasDeadline bool
+	var ctxErr error
+	router.GET("/slow", func(c *gin.Context) {
+		deadline, hasDeadline = c.Request.Context().Deadline()
+		select {
+		case <-c.Request.Context().Done():
+			ctxErr = c.Request.Context().Err()
+		case <-time.After(time.Second):
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if !hasDeadline || deadline.After(start.Add(time.Second)) {
+		t.Errorf("Expected the request context to carry the timeout, got %v", deadline)
+	}
+	if ctxErr != context.DeadlineExceeded {
+		t.Errorf("Expected the request context to expire, got %v", ctxErr)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the handler to be cut short, took %s", elapsed)
+	}
+}
+
+func TestDownloadCancelledRequest(t *testing.T) {
+	server := newTestServer(t)
+
+	content := []byte("content that is never sent")
+	fileID := uploadTestFile(t, server, "cancelled.txt", content)
+
+	// A client that has already gone away gets nothing streamed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil).WithContext(ctx)
+	w := serve(server, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+	if bytes.Contains(w.Body.Bytes(), content) {
+		t.Error("Expected no file content in the response")
+	}
+}
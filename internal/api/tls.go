@@ -0,0 +1,133 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// loadTLSConfig loads the API certificate and key, failing with a descriptive error if
+// either is missing or they do not form a valid pair. When a client CA is configured,
+// client certificates are verified against it and, if required, demanded.
+func loadTLSConfig(cfg config.APIConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("TLS is enabled but cert_file or key_file is not set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate %s and key %s: %w", cfg.CertFile, cfg.KeyFile, err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pemData, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA %s: %w", cfg.ClientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("client CA %s contains no PEM certificates", cfg.ClientCAFile)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if cfg.RequireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if cfg.RequireClientCert {
+		return nil, fmt.Errorf("require_client_cert is set but client_ca_file is not")
+	}
+
+	return tlsConfig, nil
+}
+
+// clientCertIdentity returns the identity named by a verified client certificate: its
+// common name, or failing that its first DNS, email or URI subject alternative name.
+// It returns an empty string when the connection carries no verified certificate.
+func clientCertIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	cert := state.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return ""
+}
+
+// ServeTLS serves HTTPS requests on the listener with the configured certificate until
+// the server is shut down. When a redirect address is configured, plain HTTP requests
+// to it are redirected to HTTPS.
+func (s *Server) ServeTLS(listener net.Listener) error {
+	tlsConfig, err := loadTLSConfig(s.config.API)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	if addr := s.config.API.RedirectAddr; addr != "" {
+		if err := s.startRedirect(addr); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	return s.Serve(tls.NewListener(listener, tlsConfig))
+}
+
+// startRedirect listens on addr for plain HTTP requests and redirects them to HTTPS
+func (s *Server) startRedirect(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for HTTP redirects on %s: %w", addr, err)
+	}
+
+	redirect := &http.Server{Handler: httpsRedirect(s.config.API.Port)}
+	s.mu.Lock()
+	s.redirectServer = redirect
+	s.mu.Unlock()
+
+	go func() {
+		if err := redirect.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.WithError(err).Error("HTTP redirect server stopped")
+		}
+	}()
+	return nil
+}
+
+// httpsRedirect permanently redirects requests to the same host and path over HTTPS on
+// the given port
+func httpsRedirect(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		// 308 keeps the method and body, so uploads are not turned into GETs
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// issueCert creates a certificate from template signed by parent, or self-signed when
+// parent is nil, and returns it with its private key
+func issueCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+// newTestCA creates a self-signed certificate authority
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	return issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+}
+
+// writePEM writes a certificate and, when key is set, its private key to dir under name,
+// returning the certificate and key paths
+func writePEM(t *testing.T, dir, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) (certFile, keyFile string) {
+	t.Helper()
+
+	certFile = filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if key == nil {
+		return certFile, ""
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	keyFile = filepath.Join(dir, name+"-key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 and its key to dir
+// and returns their paths along with the parsed certificate
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	cert, key := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	certFile, keyFile = writePEM(t, dir, "server", cert, key)
+	return certFile, keyFile, cert
+}
+
+// clientCert issues a client certificate for commonName and dnsNames signed by ca
+func clientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, commonName string, dnsNames ...string) *tls.Certificate {
+	t.Helper()
+
+	cert, key := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	return &tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+}
+
+// presentCert makes a client send cert whatever CAs the server asks for, so that an
+// untrusted certificate reaches the server rather than being withheld by the client
+func presentCert(cert *tls.Certificate) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		if cert == nil {
+			return &tls.Certificate{}, nil
+		}
+		return cert, nil
+	}
+}
+
+// startTLSServer serves server over TLS on a local port, shutting it down when the test
+// ends, and returns its base URL
+func startTLSServer(t *testing.T, server *Server) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ServeTLS(listener)
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		<-serveErr
+	})
+	return "https://" + listener.Addr().String()
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, cert := writeSelfSignedCert(t, t.TempDir())
+
+	cfg := config.DefaultConfig()
+	cfg.API.TLS = true
+	cfg.API.CertFile = certFile
+	cfg.API.KeyFile = keyFile
+	server := newTestServerWithConfig(t, cfg)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ServeTLS(listener)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		DisableKeepAlives: true,
+	}}
+
+	resp, err := client.Get("https://" + listener.Addr().String() + "/api/v1/health")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.TLS == nil {
+		t.Errorf("Expected response over TLS")
+	}
+
+	// Plain HTTP must not be served on the TLS listener
+	plain := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	if resp, err := plain.Get("http://" + listener.Addr().String() + "/api/v1/health"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("Expected plain HTTP request to be rejected")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-serveErr; err != nil {
+		t.Errorf("Expected ServeTLS to return nil after shutdown, got %v", err)
+	}
+}
+
+func TestServeTLSInvalidCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _, _ := writeSelfSignedCert(t, dir)
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  string
+	}{
+		{"missing key setting", certFile, "", "cert_file or key_file is not set"},
+		{"missing file", certFile, filepath.Join(dir, "missing.pem"), "failed to load TLS certificate"},
+		{"invalid key", certFile, garbage, "failed to load TLS certificate"},
+	}
+
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.API.TLS = true
+		cfg.API.CertFile = tt.certFile
+		cfg.API.KeyFile = tt.keyFile
+		server := newTestServerWithConfig(t, cfg)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+
+		// A bad certificate must fail immediately rather than on the first handshake
+		err = server.ServeTLS(listener)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+		if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+			t.Errorf("%s: expected listener to be closed", tt.name)
+		}
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int
+		host     string
+		target   string
+		location string
+	}{
+		{"custom port", 8443, "example.com:8080", "/api/v1/files?limit=5", "https://example.com:8443/api/v1/files?limit=5"},
+		{"default port", 443, "example.com", "/api/v1/health", "https://example.com/api/v1/health"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		httpsRedirect(tt.port).ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s: expected status 308, got %d", tt.name, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s: expected Location %q, got %q", tt.name, tt.location, location)
+		}
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, serverCert := writeSelfSignedCert(t, dir)
+	ca, caKey := newTestCA(t, "clients")
+	caFile, _ := writePEM(t, dir, "client-ca", ca, nil)
+	untrusted, untrustedKey := newTestCA(t, "untrusted")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert)
+
+	tests := []struct {
+		name     string
+		require  bool
+		cert     *tls.Certificate
+		header   string
+		wantErr  bool
+		identity string
+	}{
+		{"valid cert", true, clientCert(t, ca, caKey, "node-001"), "", false, "node-001"},
+		{"san when cn empty", true, clientCert(t, ca, caKey, "", "node-002.cluster.local"), "", false, "node-002.cluster.local"},
+		{"cert overrides header", true, clientCert(t, ca, caKey, "node-001"), "mallory", false, "node-001"},
+		{"untrusted ca", true, clientCert(t, untrusted, untrustedKey, "node-001"), "", true, ""},
+		{"missing cert when required", true, nil, "", true, ""},
+		{"untrusted ca when optional", false, clientCert(t, untrusted, untrustedKey, "node-001"), "", true, ""},
+		{"missing cert when optional", false, nil, "alice", false, "alice"},
+	}
+
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.API.TLS = true
+		cfg.API.CertFile = certFile
+		cfg.API.KeyFile = keyFile
+		cfg.API.ClientCAFile = caFile
+		cfg.API.RequireClientCert = tt.require
+		server := newTestServerWithConfig(t, cfg)
+		server.GetRouter().GET("/whoami", func(c *gin.Context) {
+			c.String(http.StatusOK, server.principal(c))
+		})
+		baseURL := startTLSServer(t, server)
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, GetClientCertificate: presentCert(tt.cert)},
+			DisableKeepAlives: true,
+		}}
+		req, _ := http.NewRequest(http.MethodGet, baseURL+"/whoami", nil)
+		if tt.header != "" {
+			req.Header.Set("X-Owner", tt.header)
+		}
+
+		resp, err := client.Do(req)
+		if tt.wantErr {
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("%s: expected handshake to be rejected, got status %d", tt.name, resp.StatusCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: request failed: %v", tt.name, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.identity {
+			t.Errorf("%s: expected identity %q, got %q", tt.name, tt.identity, body)
+		}
+	}
+}
+
+func TestLoadTLSConfigClientCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeSelfSignedCert(t, dir)
+	ca, _ := newTestCA(t, "clients")
+	caFile, _ := writePEM(t, dir, "client-ca", ca, nil)
+
+	tests := []struct {
+		name       string
+		caFile     string
+		require    bool
+		clientAuth tls.ClientAuthType
+	}{
+		{"disabled", "", false, tls.NoClientCert},
+		{"optional", caFile, false, tls.VerifyClientCertIfGiven},
+		{"required", caFile, true, tls.RequireAndVerifyClientCert},
+	}
+
+	for _, tt := range tests {
+		tlsConfig, err := loadTLSConfig(config.APIConfig{
+			CertFile:          certFile,
+			KeyFile:           keyFile,
+			ClientCAFile:      tt.caFile,
+			RequireClientCert: tt.require,
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if tlsConfig.ClientAuth != tt.clientAuth {
+			t.Errorf("%s: expected client auth %v, got %v", tt.name, tt.clientAuth, tlsConfig.ClientAuth)
+		}
+	}
+
+	// A CA file without certificates is a configuration error, not an empty trust pool
+	if _, err := loadTLSConfig(config.APIConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}); err == nil {
+		t.Errorf("Expected error for client CA without certificates")
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// newTrashTestServer creates a test server with soft-delete enabled
+func newTrashTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Storage.TrashRetention = time.Hour
+	return newTestServerWithConfig(t, cfg)
+}
+
+// requestAs performs a request without a body as the given owner
+func requestAs(server *Server, method, owner, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-Owner", owner)
+	return serve(server, req)
+}
+
+// listCount returns the number of files reported by the list endpoint
+func listCount(t *testing.T, server *Server, query string) int {
+	t.Helper()
+
+	w := requestAs(server, http.MethodGet, "alice", "/api/v1/files"+query)
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse list response: %v", err)
+	}
+	return result.Count
+}
+
+func TestSoftDeleteRestore(t *testing.T) {
+	server := newTrashTestServer(t)
+	fileID := uploadAs(t, server, "alice", "keep.txt", []byte("do not lose me"))
+
+	if w := requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+fileID); w.Code != http.StatusOK {
+		t.Fatalf("Delete failed with status %d", w.Code)
+	}
+
+	// Trashed files are hidden
+	if w := requestAs(server, http.MethodGet, "alice", "/api/v1/files/"+fileID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for trashed file, got %d", w.Code)
+	}
+	if count := listCount(t, server, ""); count != 0 {
+		t.Errorf("Expected 0 listed files, got %d", count)
+	}
+	if count := listCount(t, server, "?include_deleted=true"); count != 1 {
+		t.Errorf("Expected 1 listed file including deleted, got %d", count)
+	}
+
+	// Only the owner can restore
+	if w := requestAs(server, http.MethodPost, "bob", "/api/v1/files/"+fileID+"/restore"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner restore, got %d", w.Code)
+	}
+	if w := requestAs(server, http.MethodPost, "alice", "/api/v1/files/"+fileID+"/restore"); w.Code != http.StatusOK {
+		t.Fatalf("Restore failed with status %d", w.Code)
+	}
+	if w := requestAs(server, http.MethodPost, "alice", "/api/v1/files/"+fileID+"/restore"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 restoring an active file, got %d", w.Code)
+	}
+
+	if w := requestAs(server, http.MethodGet, "alice", "/api/v1/files/"+fileID); w.Body.String() != "do not lose me" {
+		t.Errorf("Expected restored content, got %q", w.Body.String())
+	}
+}
+
+func TestTrashRetention(t *testing.T) {
+	server := newTrashTestServer(t)
+	fileID := uploadAs(t, server, "alice", "expire.txt", []byte("temporary"))
+	chunkID := server.files[fileID].Chunks[0].ID
+
+	requestAs(server, http.MethodDelete, "alice", "/api/v1/files/"+fileID)
+
+	// Within the retention window the chunks are kept
+	if purged := server.sweepTrash(time.Now().Add(30 * time.Minute)); purged != 0 {
+		t.Errorf("Expected nothing purged within retention, got %d", purged)
+	}
+	if !server.storage.Exists(chunkID) {
+		t.Errorf("Expected chunk to be kept within retention")
+	}
+
+	// After the retention window the file is removed permanently
+	if purged := server.sweepTrash(time.Now().Add(2 * time.Hour)); purged != 1 {
+		t.Errorf("Expected 1 file purged after retention, got %d", purged)
+	}
+	if server.storage.Exists(chunkID) {
+		t.Errorf("Expected chunk to be deleted after retention")
+	}
+	if w := requestAs(server, http.MethodPost, "alice", "/api/v1/files/"+fileID+"/restore"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 restoring a purged file, got %d", w.Code)
+	}
+}
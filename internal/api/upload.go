@@ -0,0 +1,291 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// uploadSweepInterval is how often expired upload sessions are discarded
+const uploadSweepInterval = time.Minute
+
+// createUpload handles starting a resumable upload session
+func (s *Server) createUpload(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name"`
+		Size        *int64 `json:"size"`
+		ContentType string `json:"content_type"`
+		Public      bool   `json:"public"`
+		ChunkSize   int    `json:"chunk_size"` // Zero for the node's default
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" || req.Size == nil || *req.Size < 0 {
+		writeError(c, http.StatusBadRequest, "Invalid upload request")
+		return
+	}
+
+	if !s.checkChunkSize(c, req.ChunkSize) {
+		return
+	}
+
+	if *req.Size > s.config.Storage.MaxFileSize {
+		writeError(c, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+
+	// The session holds storage and quota for its whole size until it completes, is
+	// aborted or expires, so staged chunks count against the node and owner from the start
+	if !s.reserveCapacity(c, *req.Size) {
+		return
+	}
+
+	owner := s.principal(c)
+	if err := s.quotas.Reserve(owner, *req.Size); err != nil {
+		s.releaseCapacity(*req.Size)
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			writeAPIError(c, http.StatusRequestEntityTooLarge, &APIError{Code: CodeQuotaExceeded, Message: "Storage quota exceeded"})
+			return
+		}
+		s.log(c).WithError(err).Error("Failed to reserve quota")
+		writeError(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	session, err := s.uploads.CreateSession(req.Name, req.ContentType, owner, *req.Size, req.Public, req.ChunkSize)
+	if err != nil {
+		s.releaseCapacity(*req.Size)
+		s.releaseQuota(owner, *req.Size)
+		if errors.Is(err, storage.ErrStagingFull) {
+			writeAPIError(c, http.StatusInsufficientStorage, &APIError{Code: CodeInsufficientStorage, Message: "Too many uploads in progress on node"})
+			return
+		}
+		s.log(c).WithError(err).Error("Failed to create upload session")
+		writeError(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	s.log(c).WithFields(logrus.Fields{
+		"upload_id": session.ID,
+		"file_name": session.Name,
+		"size":      session.Size,
+	}).Info("Upload session created")
+
+	response := gin.H{
+		"upload_id":   session.ID,
+		"chunk_size":  session.ChunkSize,
+		"chunk_count": session.ChunkCount(),
+	}
+	if session.ExpiresAt != nil {
+		response["expires_at"] = session.ExpiresAt
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// getUpload handles retrieving the progress of an upload session
+func (s *Server) getUpload(c *gin.Context) {
+	session, ok := s.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"upload_id":   session.ID,
+		"file_name":   session.Name,
+		"size":        session.Size,
+		"chunk_size":  session.ChunkSize,
+		"chunk_count": session.ChunkCount(),
+		"received":    session.Received,
+		"missing":     session.Missing(),
+	}
+	if session.ExpiresAt != nil {
+		response["expires_at"] = session.ExpiresAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// uploadChunk handles receiving a single chunk of an upload session
+func (s *Server) uploadChunk(c *gin.Context) {
+	session, ok := s.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "Invalid chunk index")
+		return
+	}
+
+	// Chunks are never larger than the session chunk size
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, int64(session.ChunkSize))
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(c, http.StatusRequestEntityTooLarge, "Chunk too large")
+			return
+		}
+		writeError(c, http.StatusBadRequest, "Failed to read chunk")
+		return
+	}
+
+	if err := s.uploads.PutChunk(c.Request.Context(), session.ID, index, data); err != nil {
+		if errors.Is(err, storage.ErrInvalidChunk) {
+			writeError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.log(c).WithError(err).WithField("upload_id", session.ID).Error("Failed to store upload chunk")
+		writeError(c, http.StatusInternalServerError, "Failed to store chunk")
+		return
+	}
+
+	// Progress counts the chunks received so far, whichever order they arrive in
+	if updated, err := s.uploads.GetSession(session.ID); err == nil {
+		received := int64(len(updated.Received)) * int64(updated.ChunkSize)
+		if received > updated.Size {
+			received = updated.Size
+		}
+		s.events.publish(session.Owner, Event{Type: EventUploadProgress, UploadID: session.ID, FileName: session.Name, Bytes: received, Total: session.Size})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upload_id": session.ID,
+		"index":     index,
+	})
+}
+
+// completeUpload handles assembling a finished upload session into a file
+func (s *Server) completeUpload(c *gin.Context) {
+	session, ok := s.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	if missing := session.Missing(); len(missing) > 0 {
+		writeAPIError(c, http.StatusConflict, &APIError{
+			Code:    CodeUploadIncomplete,
+			Message: "Upload is missing chunks",
+			Details: gin.H{"missing": missing},
+		})
+		return
+	}
+
+	// The storage and quota reserved with the session stay held while the session remains
+	fileInfo, err := s.uploads.Complete(c.Request.Context(), session.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUploadNotFound) {
+			writeError(c, http.StatusNotFound, "Upload not found")
+			return
+		}
+		if errors.Is(err, storage.ErrUploadIncomplete) {
+			writeAPIError(c, http.StatusConflict, &APIError{Code: CodeUploadIncomplete, Message: "Upload is missing chunks"})
+			return
+		}
+		s.log(c).WithError(err).WithField("upload_id", session.ID).Error("Failed to complete upload")
+		writeError(c, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+	s.releaseCapacity(session.Size)
+
+	if !s.saveFile(fileInfo) {
+		s.releaseQuota(session.Owner, session.Size)
+		writeError(c, http.StatusConflict, "File already exists")
+		return
+	}
+	s.events.publish(session.Owner, Event{Type: EventUploadCompleted, UploadID: session.ID, FileID: fileInfo.ID, FileName: fileInfo.Name, Bytes: fileInfo.Size, Total: fileInfo.Size})
+
+	s.log(c).WithFields(logrus.Fields{
+		"upload_id": session.ID,
+		"file_id":   fileInfo.ID,
+		"file_name": fileInfo.Name,
+		"size":      fileInfo.Size,
+	}).Info("File uploaded successfully")
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":   fileInfo.ID,
+		"file_name": fileInfo.Name,
+		"size":      fileInfo.Size,
+		"hash":      fileInfo.Hash,
+	})
+}
+
+// abortUpload handles discarding an upload session
+func (s *Server) abortUpload(c *gin.Context) {
+	session, ok := s.loadUpload(c)
+	if !ok {
+		return
+	}
+
+	aborted, err := s.uploads.Abort(session.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUploadNotFound) {
+			writeError(c, http.StatusNotFound, "Upload not found")
+			return
+		}
+		s.log(c).WithError(err).WithField("upload_id", session.ID).Error("Failed to abort upload")
+		writeError(c, http.StatusInternalServerError, "Failed to abort upload")
+		return
+	}
+	s.releaseCapacity(aborted.Size)
+	s.releaseQuota(aborted.Owner, aborted.Size)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload aborted"})
+}
+
+// loadUpload fetches the upload session named in the request and checks the caller owns it,
+// writing an error response if not
+func (s *Server) loadUpload(c *gin.Context) (*storage.UploadSession, bool) {
+	session, err := s.uploads.GetSession(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrUploadNotFound) {
+			writeError(c, http.StatusNotFound, "Upload not found")
+			return nil, false
+		}
+		s.log(c).WithError(err).Error("Failed to load upload session")
+		writeError(c, http.StatusInternalServerError, "Failed to load upload")
+		return nil, false
+	}
+
+	if session.Owner != s.principal(c) {
+		writeError(c, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+
+	return session, true
+}
+
+// sweepUploads discards upload sessions whose TTL has passed as of now and releases
+// the storage and quota they held, returning the number of sessions discarded
+func (s *Server) sweepUploads(now time.Time) int {
+	swept, err := s.uploads.Sweep(now)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to sweep expired uploads")
+	}
+	for _, session := range swept {
+		s.releaseCapacity(session.Size)
+		s.releaseQuota(session.Owner, session.Size)
+	}
+	return len(swept)
+}
+
+// runUploadSweeper periodically discards expired upload sessions until stop is closed
+func (s *Server) runUploadSweeper(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if swept := s.sweepUploads(now); swept > 0 {
+				s.logger.WithField("swept", swept).Info("Discarded expired uploads")
+			}
+		}
+	}
+}
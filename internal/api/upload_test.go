@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createTestUpload starts an upload session and returns its ID
+func createTestUpload(t *testing.T, server *Server, name string, size int) string {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"name": %q, "size": %d}`, name, size)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/uploads", strings.NewReader(body))
+	req.Header.Set("X-Owner", "alice")
+	w := serve(server, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create upload failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return result["upload_id"].(string)
+}
+
+// putTestChunk uploads one chunk of a session
+func putTestChunk(server *Server, uploadID string, index int, data []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/uploads/%s/%d", uploadID, index), bytes.NewReader(data))
+	req.Header.Set("X-Owner", "alice")
+	return serve(server, req)
+}
+
+// completeTestUpload finalizes an upload session
+func completeTestUpload(server *Server, uploadID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/uploads/"+uploadID+"/complete", nil)
+	req.Header.Set("X-Owner", "alice")
+	return serve(server, req)
+}
+
+func TestResumableUpload(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("chunked upload data")
+	uploadID := createTestUpload(t, server, "chunked.txt", len(content))
+
+	// Out of order, with a duplicate
+	for _, index := range []int{3, 1, 4, 1, 0} {
+		end := (index + 1) * 4
+		if end > len(content) {
+			end = len(content)
+		}
+		if w := putTestChunk(server, uploadID, index, content[index*4:end]); w.Code != http.StatusOK {
+			t.Fatalf("Chunk %d failed with status %d: %s", index, w.Code, w.Body.String())
+		}
+	}
+
+	// Completing with chunk 2 missing fails and reports it
+	w := completeTestUpload(server, uploadID)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 with missing chunk, got %d", w.Code)
+	}
+	var result struct {
+		Error APIError `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.Error.Code != CodeUploadIncomplete {
+		t.Errorf("Expected code %s, got %s", CodeUploadIncomplete, result.Error.Code)
+	}
+	details, _ := result.Error.Details.(map[string]interface{})
+	if missing := fmt.Sprint(details["missing"]); missing != "[2]" {
+		t.Errorf("Expected missing [2], got %s", missing)
+	}
+
+	if w := putTestChunk(server, uploadID, 2, content[8:12]); w.Code != http.StatusOK {
+		t.Fatalf("Resending chunk failed with status %d", w.Code)
+	}
+
+	fileID := fileIDFromResponse(t, completeTestUpload(server, uploadID))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files/"+fileID, nil)
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Body.String() != string(content) {
+		t.Errorf("Expected %q, got %q", content, w.Body.String())
+	}
+
+	// The session is gone once completed
+	if w := completeTestUpload(server, uploadID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for completed session, got %d", w.Code)
+	}
+}
+
+func TestResumableUploadAccess(t *testing.T) {
+	server := newTestServer(t)
+	uploadID := createTestUpload(t, server, "owned.txt", 8)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/uploads/"+uploadID+"/0", strings.NewReader("abcd"))
+	req.Header.Set("X-Owner", "bob")
+	if w := serve(server, req); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-owner, got %d", w.Code)
+	}
+
+	if w := putTestChunk(server, uploadID, 0, []byte("abcdefgh")); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for oversized chunk, got %d", w.Code)
+	}
+
+	if w := putTestChunk(server, uploadID, 5, []byte("abcd")); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for out of range chunk, got %d", w.Code)
+	}
+}
+
+func TestResumableUploadQuota(t *testing.T) {
+	server := newTestServer(t)
+	server.quotas.SetQuota("alice", 10)
+
+	// The session reserves its whole size before any chunk arrives
+	uploadID := createTestUpload(t, server, "reserved.txt", 8)
+	if usage, _ := server.quotas.Usage("alice"); usage != 8 {
+		t.Errorf("Expected 8 bytes reserved by the session, got %d", usage)
+	}
+
+	body := `{"name": "over.txt", "size": 4}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/uploads", strings.NewReader(body))
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for a session over quota, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/uploads/"+uploadID, nil)
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusOK {
+		t.Fatalf("Abort failed with status %d: %s", w.Code, w.Body.String())
+	}
+	if usage, _ := server.quotas.Usage("alice"); usage != 0 {
+		t.Errorf("Expected the reservation released on abort, got %d", usage)
+	}
+
+	// Completing keeps the reservation as the file's usage
+	uploadID = createTestUpload(t, server, "kept.txt", 8)
+	putTestChunk(server, uploadID, 0, []byte("abcd"))
+	putTestChunk(server, uploadID, 1, []byte("efgh"))
+	if w := completeTestUpload(server, uploadID); w.Code != http.StatusOK {
+		t.Fatalf("Complete failed with status %d: %s", w.Code, w.Body.String())
+	}
+	if usage, _ := server.quotas.Usage("alice"); usage != 8 {
+		t.Errorf("Expected 8 bytes used after completion, got %d", usage)
+	}
+}
+
+func TestResumableUploadExpiry(t *testing.T) {
+	server := newTestServer(t)
+	ttl := server.config.API.Uploads.SessionTTL
+
+	uploadID := createTestUpload(t, server, "abandoned.txt", 8)
+	putTestChunk(server, uploadID, 0, []byte("abcd"))
+
+	if swept := server.sweepUploads(time.Now()); swept != 0 {
+		t.Errorf("Expected no uploads swept before the TTL, got %d", swept)
+	}
+	if swept := server.sweepUploads(time.Now().Add(2 * ttl)); swept != 1 {
+		t.Errorf("Expected the upload swept after the TTL, got %d", swept)
+	}
+
+	if usage, _ := server.quotas.Usage("alice"); usage != 0 {
+		t.Errorf("Expected the reservation released on expiry, got %d", usage)
+	}
+	if w := putTestChunk(server, uploadID, 1, []byte("efgh")); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an expired upload, got %d", w.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// uploadRetryAfter is the Retry-After sent when an upload is turned away because the
+// node is busy and no queue timeout says how long a wait could be
+const uploadRetryAfter = 5 * time.Second
+
+// uploadLimiter caps how many uploads are processed at once. Uploads over the cap wait
+// for a slot while there is room in the queue and are rejected otherwise.
+type uploadLimiter struct {
+	slots   chan struct{}
+	queue   chan struct{}
+	timeout time.Duration // Longest wait in the queue, 0 for as long as the client stays
+}
+
+// newUploadLimiter creates a limiter from the config, or returns nil when uploads are
+// unlimited
+func newUploadLimiter(cfg config.UploadLimitConfig) *uploadLimiter {
+	if cfg.MaxConcurrent <= 0 {
+		return nil
+	}
+	return &uploadLimiter{
+		slots:   make(chan struct{}, cfg.MaxConcurrent),
+		queue:   make(chan struct{}, cfg.QueueSize),
+		timeout: cfg.QueueTimeout,
+	}
+}
+
+// acquire takes an upload slot, waiting in the queue if all are busy. It returns false
+// when the queue is full, the wait times out or ctx is done.
+func (l *uploadLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case l.queue <- struct{}{}:
+	default:
+		return false
+	}
+	defer func() { <-l.queue }()
+
+	var expired <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-expired:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *uploadLimiter) release() {
+	<-l.slots
+}
+
+// retryAfter returns the Retry-After value in seconds for a rejected upload
+func (l *uploadLimiter) retryAfter() string {
+	wait := uploadRetryAfter
+	if l.timeout > 0 {
+		wait = l.timeout
+	}
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// limitUploads runs the upload handlers that follow it only once an upload slot is free,
+// answering 503 with Retry-After when the node is saturated
+func (s *Server) limitUploads(c *gin.Context) {
+	if s.uploadSlots == nil {
+		c.Next()
+		return
+	}
+
+	if !s.uploadSlots.acquire(c.Request.Context()) {
+		c.Header("Retry-After", s.uploadSlots.retryAfter())
+		s.log(c).Warn("Upload rejected, too many uploads in progress")
+		writeError(c, http.StatusServiceUnavailable, "Too many uploads in progress")
+		return
+	}
+	defer s.uploadSlots.release()
+
+	c.Next()
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+)
+
+// newUploadLimitedServer creates a test server with the given upload limits
+func newUploadLimitedServer(t *testing.T, limits config.UploadLimitConfig) *Server {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.API.Uploads = limits
+	return newTestServerWithConfig(t, cfg)
+}
+
+// blockingUploadBody is a multipart upload whose body stalls after the headers until
+// release is closed, keeping the upload in progress
+type blockingUploadBody struct {
+	head    *bytes.Reader
+	tail    *bytes.Reader
+	release chan struct{}
+}
+
+// newBlockingUploadRequest creates an upload request that stays in the handler until
+// release is closed
+func newBlockingUploadRequest(t *testing.T, name string, release chan struct{}) *http.Request {
+	t.Helper()
+
+	var head bytes.Buffer
+	writer := multipart.NewWriter(&head)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte("payload"))
+	headLen := head.Len()
+	writer.Close()
+
+	body := head.Bytes()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files", &blockingUploadBody{
+		head:    bytes.NewReader(body[:headLen]),
+		tail:    bytes.NewReader(body[headLen:]),
+		release: release,
+	})
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// Read returns the headers, then waits for release before returning the rest
+func (b *blockingUploadBody) Read(p []byte) (int, error) {
+	if b.head.Len() > 0 {
+		return b.head.Read(p)
+	}
+	<-b.release
+	return b.tail.Read(p)
+}
+
+func TestUploadLimitRejectsWhenSaturated(t *testing.T) {
+	server := newUploadLimitedServer(t, config.UploadLimitConfig{MaxConcurrent: 1, QueueTimeout: 2 * time.Second})
+
+	release := make(chan struct{})
+	done := make(chan int)
+	slow := newBlockingUploadRequest(t, "slow.txt", release)
+	go func() {
+		done <- serve(server, slow).Code
+	}()
+
+	// Wait until the slow upload holds the only slot
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.uploadSlots.slots) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Slow upload never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	w := doUpload(t, server, "fast.txt", []byte("fast"))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 with no queue room, got %d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", retryAfter)
+	}
+	if code := decodeError(t, w.Body.Bytes())["code"]; code != CodeUnavailable {
+		t.Errorf("Expected error code %s, got %v", CodeUnavailable, code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("Expected slow upload to succeed, got %d", code)
+	}
+
+	// The freed slot is available again
+	if w := doUpload(t, server, "after.txt", []byte("after")); w.Code != http.StatusOK {
+		t.Errorf("Expected upload after release to succeed, got %d", w.Code)
+	}
+}
+
+func TestUploadLimitCapsConcurrency(t *testing.T) {
+	const limit, uploads = 2, 8
+	server := newUploadLimitedServer(t, config.UploadLimitConfig{MaxConcurrent: limit, QueueSize: uploads})
+
+	var active, peak int32
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/upload", server.limitUploads, func(c *gin.Context) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		c.Status(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	codes := make([]int, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", nil))
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Expected queued upload %d to succeed, got %d", i, code)
+		}
+	}
+	if peak > limit {
+		t.Errorf("Expected at most %d concurrent uploads, got %d", limit, peak)
+	}
+	if peak < 1 {
+		t.Errorf("Expected uploads to run, got peak %d", peak)
+	}
+}
+
+func TestUploadLimiterQueueTimeout(t *testing.T) {
+	limiter := newUploadLimiter(config.UploadLimitConfig{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond})
+	if !limiter.acquire(context.Background()) {
+		t.Fatal("Expected first acquire to succeed")
+	}
+
+	start := time.Now()
+	if limiter.acquire(context.Background()) {
+		t.Fatal("Expected queued acquire to time out")
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("Expected to wait for the queue timeout, waited %s", waited)
+	}
+	if len(limiter.queue) != 0 {
+		t.Errorf("Expected timed out upload to leave the queue, got %d queued", len(limiter.queue))
+	}
+
+	limiter.release()
+	if !limiter.acquire(context.Background()) {
+		t.Error("Expected acquire after release to succeed")
+	}
+
+	if newUploadLimiter(config.UploadLimitConfig{}) != nil {
+		t.Error("Expected no limiter when uploads are unlimited")
+	}
+}
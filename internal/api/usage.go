@@ -0,0 +1,78 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// usageCacheTTL is how long a computed usage breakdown is served before it is recomputed
+const usageCacheTTL = 30 * time.Second
+
+// Labels for files without an owner or content type
+const (
+	anonymousOwner     = "anonymous"
+	unknownContentType = "application/octet-stream"
+)
+
+// UsageBreakdown splits the bytes held by stored files by owner and content type.
+// Sizes are logical file sizes, so chunks shared through deduplication count once per file.
+type UsageBreakdown struct {
+	Total         int64            `json:"total"`
+	Files         int              `json:"files"`
+	ByOwner       map[string]int64 `json:"by_owner"`
+	ByContentType map[string]int64 `json:"by_content_type"`
+	ComputedAt    time.Time        `json:"computed_at"`
+}
+
+// usageCache holds the last computed breakdown
+type usageCache struct {
+	breakdown *UsageBreakdown
+	ttl       time.Duration
+	mu        sync.Mutex
+}
+
+// GetUsageBreakdown returns the usage of every stored file, trashed ones included since
+// they still take up space. The result is cached for a short while.
+func (s *Server) GetUsageBreakdown() UsageBreakdown {
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+
+	if cached := s.usage.breakdown; cached != nil && time.Since(cached.ComputedAt) < s.usage.ttl {
+		return *cached
+	}
+
+	breakdown := UsageBreakdown{
+		ByOwner:       make(map[string]int64),
+		ByContentType: make(map[string]int64),
+		ComputedAt:    time.Now(),
+	}
+	s.eachFile(func(fileInfo *types.FileInfo) {
+		owner := fileInfo.Owner
+		if owner == "" {
+			owner = anonymousOwner
+		}
+
+		contentType := unknownContentType
+		if mediaType, _, err := mime.ParseMediaType(fileInfo.ContentType); err == nil {
+			contentType = mediaType
+		}
+
+		breakdown.Total += fileInfo.Size
+		breakdown.Files++
+		breakdown.ByOwner[owner] += fileInfo.Size
+		breakdown.ByContentType[contentType] += fileInfo.Size
+	})
+
+	s.usage.breakdown = &breakdown
+	return breakdown
+}
+
+// getNodeUsage handles storage usage retrieval broken down by owner and content type
+func (s *Server) getNodeUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, s.GetUsageBreakdown())
+}
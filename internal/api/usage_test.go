@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNodeUsage(t *testing.T) {
+	server := newTestServer(t)
+	uploadAs(t, server, "alice", "a.txt", []byte("alice's notes"))
+	uploadAs(t, server, "alice", "b.bin", []byte("alice's binary data"))
+	uploadAs(t, server, "bob", "c.txt", []byte("bob's notes"))
+	uploadTestFile(t, server, "d.txt", []byte("nobody's"))
+
+	w := serve(server, httptest.NewRequest(http.MethodGet, "/api/v1/node/usage", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var usage UsageBreakdown
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	var want int64
+	for _, fileInfo := range server.files {
+		want += fileInfo.Size
+	}
+	if usage.Total != want || usage.Files != 4 {
+		t.Errorf("Expected 4 files totalling %d bytes, got %d files totalling %d", want, usage.Files, usage.Total)
+	}
+
+	owners := map[string]int64{
+		"alice":     int64(len("alice's notes") + len("alice's binary data")),
+		"bob":       int64(len("bob's notes")),
+		"anonymous": int64(len("nobody's")),
+	}
+	for owner, size := range owners {
+		if usage.ByOwner[owner] != size {
+			t.Errorf("Expected %s to use %d bytes, got %d", owner, size, usage.ByOwner[owner])
+		}
+	}
+
+	for name, totals := range map[string]map[string]int64{"owner": usage.ByOwner, "content type": usage.ByContentType} {
+		var sum int64
+		for _, size := range totals {
+			sum += size
+		}
+		if sum != usage.Total {
+			t.Errorf("Expected usage by %s to sum to %d, got %d", name, usage.Total, sum)
+		}
+	}
+}
+
+func TestNodeUsageCached(t *testing.T) {
+	server := newTestServer(t)
+	uploadTestFile(t, server, "a.txt", []byte("first"))
+
+	first := server.GetUsageBreakdown()
+	uploadTestFile(t, server, "b.txt", []byte("second"))
+
+	if cached := server.GetUsageBreakdown(); cached.Total != first.Total || !cached.ComputedAt.Equal(first.ComputedAt) {
+		t.Errorf("Expected cached breakdown, got total %d computed at %s", cached.Total, cached.ComputedAt)
+	}
+
+	// Once the cache expires the new file is counted
+	server.usage.breakdown.ComputedAt = time.Now().Add(-usageCacheTTL)
+	if fresh := server.GetUsageBreakdown(); fresh.Total != first.Total+int64(len("second")) {
+		t.Errorf("Expected recomputed total %d, got %d", first.Total+int64(len("second")), fresh.Total)
+	}
+}
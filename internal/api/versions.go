@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// listVersions handles listing every version of a file, oldest first
+func (s *Server) listVersions(c *gin.Context) {
+	fileInfo, exists := s.activeFile(c.Param("id"))
+	if !exists {
+		writeError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !s.authorizeFile(c, fileInfo, true) {
+		return
+	}
+
+	versions := []gin.H{}
+	for _, version := range s.versionsOf(fileInfo, false) {
+		versions = append(versions, gin.H{
+			"id":               version.ID,
+			"version":          version.Version,
+			"previous_version": version.PreviousVersion,
+			"size":             version.Size,
+			"hash":             version.Hash,
+			"created_at":       version.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":     fileInfo.Name,
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// versionsOf returns the stored versions of a file, ordered by version number.
+// Versions are the files sharing the same name and owner; trashed versions are
+// only included when includeDeleted is set.
+func (s *Server) versionsOf(fileInfo *types.FileInfo, includeDeleted bool) []*types.FileInfo {
+	s.filesMu.RLock()
+	defer s.filesMu.RUnlock()
+
+	return s.versionsLocked(fileInfo, includeDeleted)
+}
+
+// versionsLocked is versionsOf for callers already holding filesMu
+func (s *Server) versionsLocked(fileInfo *types.FileInfo, includeDeleted bool) []*types.FileInfo {
+	var versions []*types.FileInfo
+	for _, candidate := range s.files {
+		if candidate.DeletedAt != nil && !includeDeleted {
+			continue
+		}
+		if candidate.Name == fileInfo.Name && candidate.Owner == fileInfo.Owner {
+			versions = append(versions, candidate)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	return versions
+}
+
+// findVersion returns the version of a file with the given version number
+func (s *Server) findVersion(fileInfo *types.FileInfo, version string) (*types.FileInfo, bool) {
+	number, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, candidate := range s.versionsOf(fileInfo, false) {
+		if candidate.Version == number {
+			return candidate, true
+		}
+	}
+	return nil, false
+}
+
+// removeVersion removes a file's metadata, relinking the next version to the removed one's predecessor
+func (s *Server) removeVersion(fileInfo *types.FileInfo) {
+	s.updateFiles(func() {
+		delete(s.files, fileInfo.ID)
+		for id, candidate := range s.files {
+			if candidate.PreviousVersion == fileInfo.ID {
+				relinked := copyFileInfo(candidate)
+				relinked.PreviousVersion = fileInfo.PreviousVersion
+				s.files[id] = relinked
+			}
+		}
+	})
+	s.unindexTags(fileInfo)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// uploadAs uploads content as the given owner and returns the file ID
+func uploadAs(t *testing.T, server *Server, owner, name string, content []byte) string {
+	t.Helper()
+
+	req := newUploadRequest(t, name, content, nil)
+	req.Header.Set("X-Owner", owner)
+	return fileIDFromResponse(t, serve(server, req))
+}
+
+// getAs performs a GET request as the given owner
+func getAs(server *Server, owner, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("X-Owner", owner)
+	return serve(server, req)
+}
+
+func TestFileVersions(t *testing.T) {
+	server := newTestServer(t)
+
+	v1 := uploadAs(t, server, "alice", "notes.txt", []byte("first draft"))
+	v2 := uploadAs(t, server, "alice", "notes.txt", []byte("second draft"))
+	v3 := uploadAs(t, server, "alice", "notes.txt", []byte("final draft"))
+
+	// Same name from another owner starts its own chain
+	uploadAs(t, server, "bob", "notes.txt", []byte("bob's notes"))
+
+	w := getAs(server, "alice", "/api/v1/files/"+v3+"/versions")
+	if w.Code != http.StatusOK {
+		t.Fatalf("List versions failed with status %d", w.Code)
+	}
+
+	var result struct {
+		Versions []struct {
+			ID              string `json:"id"`
+			Version         int    `json:"version"`
+			PreviousVersion string `json:"previous_version"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	expected := []string{v1, v2, v3}
+	if len(result.Versions) != len(expected) {
+		t.Fatalf("Expected %d versions, got %d", len(expected), len(result.Versions))
+	}
+	for i, version := range result.Versions {
+		if version.ID != expected[i] || version.Version != i+1 {
+			t.Errorf("Version %d: expected %s, got %s (version %d)", i+1, expected[i], version.ID, version.Version)
+		}
+		if i > 0 && version.PreviousVersion != expected[i-1] {
+			t.Errorf("Version %d: expected previous %s, got %s", i+1, expected[i-1], version.PreviousVersion)
+		}
+	}
+
+	// Old versions remain downloadable
+	if w := getAs(server, "alice", "/api/v1/files/"+v3+"?version=1"); w.Body.String() != "first draft" {
+		t.Errorf("Expected first draft, got %q", w.Body.String())
+	}
+	if w := getAs(server, "alice", "/api/v1/files/"+v2); w.Body.String() != "second draft" {
+		t.Errorf("Expected second draft, got %q", w.Body.String())
+	}
+	if w := getAs(server, "alice", "/api/v1/files/"+v3+"?version=9"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown version, got %d", w.Code)
+	}
+
+	// Deleting a single version relinks the chain
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/files/"+v2, nil)
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusOK {
+		t.Fatalf("Delete version failed with status %d", w.Code)
+	}
+	if previous := server.files[v3].PreviousVersion; previous != v1 {
+		t.Errorf("Expected previous version %s after delete, got %s", v1, previous)
+	}
+
+	// Deleting the whole chain removes every version
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/files/"+v3+"?all_versions=true", nil)
+	req.Header.Set("X-Owner", "alice")
+	if w := serve(server, req); w.Code != http.StatusOK {
+		t.Fatalf("Delete chain failed with status %d", w.Code)
+	}
+	for _, id := range []string{v1, v3} {
+		if w := getAs(server, "alice", "/api/v1/files/"+id); w.Code != http.StatusNotFound {
+			t.Errorf("Expected version %s to be deleted, got status %d", id, w.Code)
+		}
+	}
+	if len(server.files) != 1 {
+		t.Errorf("Expected only bob's file to remain, got %d files", len(server.files))
+	}
+}
@@ -0,0 +1,177 @@
+// Package blockchain anchors file hashes in a smart contract so that ownership and
+// integrity of stored files can be proven later
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/sha3"
+)
+
+// AnchorSignature is the contract function called to anchor a file:
+// anchor(bytes32 fileId, bytes32 root)
+const AnchorSignature = "anchor(bytes32,bytes32)"
+
+const (
+	// anchorQueueSize is how many anchors may wait to be submitted before new ones are dropped
+	anchorQueueSize = 256
+
+	// anchorTimeout bounds a single anchoring transaction submission
+	anchorTimeout = 30 * time.Second
+)
+
+// Transaction is a contract call to submit
+type Transaction struct {
+	To   string // Contract address, 0x-prefixed hex
+	Data []byte // ABI-encoded call data
+	Gas  uint64
+}
+
+// Client submits transactions to an Ethereum-compatible node
+type Client interface {
+	// SendTransaction submits tx and returns its transaction hash
+	SendTransaction(ctx context.Context, tx Transaction) (string, error)
+}
+
+// anchorJob is a file waiting to be anchored
+type anchorJob struct {
+	fileID string
+	root   string
+	done   func(txHash string)
+}
+
+// Anchorer submits file anchors to the contract in the background. Anchoring is best
+// effort: submissions that fail are logged and dropped.
+type Anchorer struct {
+	client   Client
+	contract string
+	gasLimit uint64
+	timeout  time.Duration
+	logger   *logrus.Logger
+
+	queue     chan anchorJob
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewAnchorer creates an anchorer calling the contract at the given address and starts
+// its submission worker
+func NewAnchorer(client Client, contract string, gasLimit uint64, logger *logrus.Logger) *Anchorer {
+	a := &Anchorer{
+		client:   client,
+		contract: contract,
+		gasLimit: gasLimit,
+		timeout:  anchorTimeout,
+		logger:   logger,
+		queue:    make(chan anchorJob, anchorQueueSize),
+	}
+
+	a.wg.Add(1)
+	go a.run()
+	return a
+}
+
+// Submit queues a file's ID and root hash for anchoring without blocking. done is called
+// from the worker with the transaction hash once the anchor is submitted. It returns
+// false when the queue is full and the anchor was dropped.
+func (a *Anchorer) Submit(fileID, root string, done func(txHash string)) bool {
+	select {
+	case a.queue <- anchorJob{fileID: fileID, root: root, done: done}:
+		return true
+	default:
+		a.logger.WithField("file_id", fileID).Warn("Anchor queue full, dropping anchor")
+		return false
+	}
+}
+
+// Close submits the anchors already queued and stops the worker
+func (a *Anchorer) Close() {
+	a.closeOnce.Do(func() {
+		close(a.queue)
+	})
+	a.wg.Wait()
+}
+
+// run submits queued anchors one at a time until the queue is closed
+func (a *Anchorer) run() {
+	defer a.wg.Done()
+
+	for job := range a.queue {
+		txHash, err := a.anchor(job.fileID, job.root)
+		if err != nil {
+			a.logger.WithError(err).WithField("file_id", job.fileID).Warn("Failed to anchor file")
+			continue
+		}
+
+		a.logger.WithFields(logrus.Fields{
+			"file_id": job.fileID,
+			"root":    job.root,
+			"tx_hash": txHash,
+		}).Info("File anchored")
+
+		if job.done != nil {
+			job.done(txHash)
+		}
+	}
+}
+
+// anchor submits a single anchoring transaction
+func (a *Anchorer) anchor(fileID, root string) (string, error) {
+	data, err := AnchorCallData(fileID, root)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	txHash, err := a.client.SendTransaction(ctx, Transaction{To: a.contract, Data: data, Gas: a.gasLimit})
+	if err != nil {
+		return "", fmt.Errorf("failed to submit anchor transaction: %w", err)
+	}
+	return txHash, nil
+}
+
+// AnchorCallData ABI-encodes a call to anchor(bytes32,bytes32) for a hex-encoded
+// 32-byte file ID and root hash
+func AnchorCallData(fileID, root string) ([]byte, error) {
+	id, err := decodeBytes32(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID: %w", err)
+	}
+	rootBytes, err := decodeBytes32(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root hash: %w", err)
+	}
+
+	data := make([]byte, 0, 4+64)
+	data = append(data, functionSelector(AnchorSignature)...)
+	data = append(data, id...)
+	data = append(data, rootBytes...)
+	return data, nil
+}
+
+// functionSelector returns the first four bytes of the Keccak-256 hash of a function signature
+func functionSelector(signature string) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(signature))
+	return hasher.Sum(nil)[:4]
+}
+
+// decodeBytes32 decodes a hex string, with an optional 0x prefix, that must be 32 bytes long
+func decodeBytes32(s string) ([]byte, error) {
+	decoded, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != 32 {
+		return nil, fmt.Errorf("expected 32 bytes, got %d", len(decoded))
+	}
+	return decoded, nil
+}
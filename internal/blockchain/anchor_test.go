@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// mockClient records submitted transactions and returns sequential hashes
+type mockClient struct {
+	txs  []Transaction
+	fail bool
+	mu   sync.Mutex
+}
+
+func (m *mockClient) SendTransaction(ctx context.Context, tx Transaction) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return "", errors.New("node unavailable")
+	}
+	m.txs = append(m.txs, tx)
+	return "0x" + strings.Repeat("0", 63) + string(rune('0'+len(m.txs))), nil
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestFunctionSelector(t *testing.T) {
+	// Well-known selector of the ERC-20 transfer function
+	if selector := hex.EncodeToString(functionSelector("transfer(address,uint256)")); selector != "a9059cbb" {
+		t.Errorf("Expected selector a9059cbb, got %s", selector)
+	}
+}
+
+func TestAnchorCallData(t *testing.T) {
+	fileID := strings.Repeat("ab", 32)
+	root := "0x" + strings.Repeat("cd", 32)
+
+	data, err := AnchorCallData(fileID, root)
+	if err != nil {
+		t.Fatalf("Failed to encode call data: %v", err)
+	}
+	if len(data) != 68 {
+		t.Fatalf("Expected 68 bytes of call data, got %d", len(data))
+	}
+	if !bytes.Equal(data[:4], functionSelector(AnchorSignature)) {
+		t.Errorf("Expected anchor selector, got %x", data[:4])
+	}
+	if hex.EncodeToString(data[4:36]) != fileID || hex.EncodeToString(data[36:]) != strings.Repeat("cd", 32) {
+		t.Errorf("Expected file ID and root arguments, got %x", data[4:])
+	}
+
+	for _, bad := range [][2]string{{"abcd", root}, {fileID, "not hex"}} {
+		if _, err := AnchorCallData(bad[0], bad[1]); err == nil {
+			t.Errorf("Expected error encoding %q and %q", bad[0], bad[1])
+		}
+	}
+}
+
+func TestAnchorerSubmit(t *testing.T) {
+	client := &mockClient{}
+	anchorer := NewAnchorer(client, "0x"+strings.Repeat("11", 20), 500000, newTestLogger())
+
+	fileID := strings.Repeat("ab", 32)
+	root := strings.Repeat("cd", 32)
+	var txHash string
+	if !anchorer.Submit(fileID, root, func(hash string) { txHash = hash }) {
+		t.Fatal("Expected anchor to be queued")
+	}
+	anchorer.Close()
+
+	if len(client.txs) != 1 {
+		t.Fatalf("Expected one transaction, got %d", len(client.txs))
+	}
+	tx := client.txs[0]
+	expected, _ := AnchorCallData(fileID, root)
+	if tx.To != "0x"+strings.Repeat("11", 20) || tx.Gas != 500000 || !bytes.Equal(tx.Data, expected) {
+		t.Errorf("Unexpected transaction %+v", tx)
+	}
+	if txHash != "0x"+strings.Repeat("0", 63)+"1" {
+		t.Errorf("Expected callback with transaction hash, got %q", txHash)
+	}
+}
+
+func TestAnchorerFailureIsBestEffort(t *testing.T) {
+	client := &mockClient{fail: true}
+	anchorer := NewAnchorer(client, "0x"+strings.Repeat("11", 20), 0, newTestLogger())
+
+	called := false
+	anchorer.Submit(strings.Repeat("ab", 32), strings.Repeat("cd", 32), func(string) { called = true })
+	anchorer.Submit("invalid", "invalid", func(string) { called = true })
+	anchorer.Close()
+
+	if called {
+		t.Error("Expected no callback for failed anchors")
+	}
+}
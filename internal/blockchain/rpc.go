@@ -0,0 +1,141 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RPCClient submits transactions through an Ethereum JSON-RPC endpoint over HTTP.
+// Transactions are sent with eth_sendTransaction, so they are signed by the node's first
+// unlocked account rather than by a locally held key.
+type RPCClient struct {
+	endpoint string
+	client   *http.Client
+
+	from   string // Sending account, looked up on first use
+	nextID int
+	mu     sync.Mutex
+}
+
+// NewRPCClient creates a client for an http or https JSON-RPC endpoint
+func NewRPCClient(endpoint string) (*RPCClient, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RPC endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid RPC endpoint %q: only http and https are supported", endpoint)
+	}
+
+	return &RPCClient{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// SendTransaction submits tx from the node's first account and returns its hash
+func (c *RPCClient) SendTransaction(ctx context.Context, tx Transaction) (string, error) {
+	from, err := c.account(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := map[string]string{
+		"from": from,
+		"to":   tx.To,
+		"data": "0x" + hex.EncodeToString(tx.Data),
+	}
+	if tx.Gas > 0 {
+		params["gas"] = "0x" + strconv.FormatUint(tx.Gas, 16)
+	}
+
+	var txHash string
+	if err := c.call(ctx, "eth_sendTransaction", []interface{}{params}, &txHash); err != nil {
+		return "", err
+	}
+	return txHash, nil
+}
+
+// account returns the sending account, asking the node on first use
+func (c *RPCClient) account(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	from := c.from
+	c.mu.Unlock()
+	if from != "" {
+		return from, nil
+	}
+
+	var accounts []string
+	if err := c.call(ctx, "eth_accounts", []interface{}{}, &accounts); err != nil {
+		return "", err
+	}
+	if len(accounts) == 0 {
+		return "", fmt.Errorf("RPC node has no unlocked accounts")
+	}
+
+	c.mu.Lock()
+	c.from = accounts[0]
+	c.mu.Unlock()
+	return accounts[0], nil
+}
+
+// call performs a JSON-RPC call and decodes its result into result
+func (c *RPCClient) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed: status %d", method, resp.StatusCode)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s failed: %s (code %d)", method, response.Error.Message, response.Error.Code)
+	}
+
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
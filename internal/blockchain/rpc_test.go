@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRPCClientSendTransaction(t *testing.T) {
+	var sent map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int               `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result interface{}
+		switch req.Method {
+		case "eth_accounts":
+			result = []string{"0xsender"}
+		case "eth_sendTransaction":
+			json.Unmarshal(req.Params[0], &sent)
+			result = "0xtxhash"
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"jsonrpc": "2.0", "id": req.ID,
+				"error": map[string]interface{}{"code": -32601, "message": "method not found"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer server.Close()
+
+	client, err := NewRPCClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	txHash, err := client.SendTransaction(context.Background(), Transaction{To: "0xcontract", Data: []byte{0xde, 0xad}, Gas: 500000})
+	if err != nil {
+		t.Fatalf("Failed to send transaction: %v", err)
+	}
+	if txHash != "0xtxhash" {
+		t.Errorf("Expected tx hash 0xtxhash, got %s", txHash)
+	}
+
+	expected := map[string]string{"from": "0xsender", "to": "0xcontract", "data": "0xdead", "gas": "0x7a120"}
+	for key, value := range expected {
+		if sent[key] != value {
+			t.Errorf("Expected %s %s, got %s", key, value, sent[key])
+		}
+	}
+
+	if err := client.call(context.Background(), "eth_unknown", nil, new(string)); err == nil {
+		t.Error("Expected RPC error to be returned")
+	}
+}
+
+func TestNewRPCClientScheme(t *testing.T) {
+	if _, err := NewRPCClient("wss://node.example.com"); err == nil {
+		t.Error("Expected websocket endpoint to be rejected")
+	}
+}
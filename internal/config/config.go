@@ -2,10 +2,20 @@
 package config
 
 import (
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
@@ -18,6 +28,9 @@ type Config struct {
 	Crypto     CryptoConfig     `mapstructure:"crypto"`
 	Blockchain BlockchainConfig `mapstructure:"blockchain"`
 	Logging    LoggingConfig    `mapstructure:"logging"`
+
+	// Original form of secrets loaded from env references or encrypted values
+	secrets map[string]secretRef
 }
 
 // NodeConfig contains node-specific configuration
@@ -25,26 +38,118 @@ type NodeConfig struct {
 	ID         string `mapstructure:"id"`
 	DataDir    string `mapstructure:"data_dir"`
 	StorageDir string `mapstructure:"storage_dir"`
-	MaxStorage int64  `mapstructure:"max_storage"`
+	MaxStorage int64  `mapstructure:"max_storage"` // Bytes the node may store, 0 for unlimited
 	Replicas   int    `mapstructure:"replicas"`
 	ChunkSize  int    `mapstructure:"chunk_size"`
+
+	MinChunkSize int `mapstructure:"min_chunk_size"` // Smallest chunk size an upload may ask for
+	MaxChunkSize int `mapstructure:"max_chunk_size"` // Largest chunk size an upload may ask for
+
+	StorageHeadroom int64 `mapstructure:"storage_headroom"` // Bytes below MaxStorage kept free; uploads that would eat into them are rejected
 }
 
 // APIConfig contains API server configuration
 type APIConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	TLS      bool   `mapstructure:"tls"`
-	CertFile string `mapstructure:"cert_file"`
-	KeyFile  string `mapstructure:"key_file"`
+	Host       string `mapstructure:"host"`
+	Port       int    `mapstructure:"port"`
+	TLS        bool   `mapstructure:"tls"`
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	AdminToken string `mapstructure:"admin_token"`
+
+	RedirectAddr string `mapstructure:"redirect_addr"` // Plain HTTP address redirecting to HTTPS when TLS is on; empty disables
+
+	ClientCAFile      string `mapstructure:"client_ca_file"`      // CA bundle verifying client certificates; empty disables mutual TLS
+	RequireClientCert bool   `mapstructure:"require_client_cert"` // Reject clients without a certificate signed by ClientCAFile
+
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"` // Grace period for in-flight requests on shutdown
+	RequestTimeout  time.Duration `mapstructure:"request_timeout"`  // Longest a request may spend on storage before it is cancelled; 0 for no limit
+
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // Proxy IPs or CIDRs whose X-Forwarded-For names the client; empty trusts none
+
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Compression CompressionConfig `mapstructure:"compression"`
+	Uploads     UploadLimitConfig `mapstructure:"uploads"`
+	CORS        CORSConfig        `mapstructure:"cors"`
+}
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`   // Origins such as "https://app.example.com"; "*" allows any
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`   // Methods allowed in cross-origin requests
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`   // Request headers allowed in cross-origin requests
+	AllowCredentials bool          `mapstructure:"allow_credentials"` // Let browsers send cookies and auth headers; needs explicit origins
+	MaxAge           time.Duration `mapstructure:"max_age"`           // How long browsers may cache a preflight response, 0 to not say
+}
+
+// UploadLimitConfig bounds how many uploads the node processes at once and how much
+// resumable uploads may stage
+type UploadLimitConfig struct {
+	MaxConcurrent int           `mapstructure:"max_concurrent"` // Uploads processed at once, 0 for unlimited
+	QueueSize     int           `mapstructure:"queue_size"`     // Uploads allowed to wait for a slot; more are rejected with 503
+	QueueTimeout  time.Duration `mapstructure:"queue_timeout"`  // How long a queued upload waits before it is rejected, 0 for no limit
+	SessionTTL    time.Duration `mapstructure:"session_ttl"`    // How long a resumable upload may stay open before it is discarded, 0 for no limit
+	MaxStaged     int64         `mapstructure:"max_staged"`     // Bytes all open resumable uploads may stage together, 0 for unlimited
+}
+
+// CompressionConfig controls gzip/deflate compression of API responses
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	MinSize int  `mapstructure:"min_size"` // Smallest response body in bytes worth compressing
+}
+
+// RateLimitConfig contains per-client token bucket limits. Clients are keyed by their
+// verified client certificate identity, or by IP address otherwise.
+type RateLimitConfig struct {
+	Enabled    bool    `mapstructure:"enabled"`
+	ReadRate   float64 `mapstructure:"read_rate"`   // Sustained GET/HEAD requests per second
+	ReadBurst  int     `mapstructure:"read_burst"`  // GET/HEAD requests allowed at once
+	WriteRate  float64 `mapstructure:"write_rate"`  // Sustained requests per second for other methods
+	WriteBurst int     `mapstructure:"write_burst"` // Other requests allowed at once
 }
 
 // StorageConfig contains storage-related configuration
 type StorageConfig struct {
-	Backend     string `mapstructure:"backend"`
-	Path        string `mapstructure:"path"`
-	MaxFileSize int64  `mapstructure:"max_file_size"`
-	Compression bool   `mapstructure:"compression"`
+	Backend       string `mapstructure:"backend"`
+	Path          string `mapstructure:"path"`
+	MetadataPath  string `mapstructure:"metadata_path"`
+	MaxFileSize   int64  `mapstructure:"max_file_size"`
+	Compression   bool   `mapstructure:"compression"`
+	Codec         string `mapstructure:"codec"`          // Compression codec, currently "gzip"
+	HashAlgorithm string `mapstructure:"hash_algorithm"` // Hash for new files and chunks: sha256, sha512 or blake2b-256
+	FileIDMode    string `mapstructure:"file_id_mode"`   // "name" hashes name and content, "dedup" also records a content-only ID
+	IDScheme      string `mapstructure:"id_scheme"`      // "content-hash" derives file and chunk IDs from content, "random" draws them at random
+	DefaultQuota  int64  `mapstructure:"default_quota"`  // Per-owner quota in bytes, 0 for unlimited
+	Redundancy    string `mapstructure:"redundancy"`     // "replication" or "erasure"
+	DataShards    int    `mapstructure:"data_shards"`    // Erasure coding data shards per stripe
+	ParityShards  int    `mapstructure:"parity_shards"`  // Erasure coding parity shards per stripe
+
+	DownloadWorkers int `mapstructure:"download_workers"` // Chunks fetched concurrently per download, 1 for sequential
+	StoreWorkers    int `mapstructure:"store_workers"`    // Chunks encrypted and stored concurrently per upload, 1 for sequential
+
+	PathDepth int `mapstructure:"path_depth"` // Levels of key-prefix subdirectories in the filesystem backend
+	PathWidth int `mapstructure:"path_width"` // Key characters per subdirectory name
+
+	Durable bool `mapstructure:"durable"` // fsync chunk files and their directories in the filesystem backend before a write completes
+
+	TrashRetention time.Duration `mapstructure:"trash_retention"` // How long deleted files stay restorable, 0 deletes immediately
+	ScrubInterval  time.Duration `mapstructure:"scrub_interval"`  // How often stored chunks are verified, 0 disables scrubbing
+
+	GCInterval    time.Duration `mapstructure:"gc_interval"`     // How often orphaned chunks are collected, 0 disables collection
+	GCGracePeriod time.Duration `mapstructure:"gc_grace_period"` // How long a chunk must stay unreferenced before it is deleted
+	GCDryRun      bool          `mapstructure:"gc_dry_run"`      // Report orphans without deleting them
+
+	S3 S3Config `mapstructure:"s3"` // Used when Backend is "s3"
+}
+
+// S3Config contains the settings of an S3-compatible storage backend
+type S3Config struct {
+	Endpoint        string `mapstructure:"endpoint"` // e.g. "https://s3.amazonaws.com" or "http://localhost:9000"
+	Region          string `mapstructure:"region"`
+	Bucket          string `mapstructure:"bucket"`
+	Prefix          string `mapstructure:"prefix"` // Prepended to every object key
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
 }
 
 // P2PConfig contains P2P network configuration
@@ -53,15 +158,28 @@ type P2PConfig struct {
 	BootstrapPeers []string `mapstructure:"bootstrap_peers"`
 	MaxPeers       int      `mapstructure:"max_peers"`
 	PrivateKey     string   `mapstructure:"private_key"`
+
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // How often peers are told this node is alive
+	PeerTimeout       time.Duration `mapstructure:"peer_timeout"`       // Silence after which a peer is marked offline
+	ControlAddr       string        `mapstructure:"control_addr"`       // Local HTTP address for node commands; empty disables
+	SyncInterval      time.Duration `mapstructure:"sync_interval"`      // How often chunks are reconciled with peers
+	SyncFanout        int           `mapstructure:"sync_fanout"`        // Peers compared with per sync round
+	BreakerThreshold  int           `mapstructure:"breaker_threshold"`  // Consecutive chunk fetch failures before a peer is skipped
+	BreakerCooldown   time.Duration `mapstructure:"breaker_cooldown"`   // How long a failing peer is skipped before it is tried again
+	MaxMessageSize    int64         `mapstructure:"max_message_size"`   // Largest message a peer may send; larger ones close the connection
 }
 
 // CryptoConfig contains cryptographic configuration
 type CryptoConfig struct {
 	Algorithm   string `mapstructure:"algorithm"`
 	KeySize     int    `mapstructure:"key_size"`
+	KeyFile     string `mapstructure:"key_file"` // Master key; rotated key versions are saved next to it as <key_file>.v<N>
 	EnableTLS   bool   `mapstructure:"enable_tls"`
 	TLSCertPath string `mapstructure:"tls_cert_path"`
 	TLSKeyPath  string `mapstructure:"tls_key_path"`
+
+	RekeyInterval time.Duration `mapstructure:"rekey_interval"` // How often chunks under an old key version are re-encrypted, 0 only re-encrypts chunks as they are read
+	NonceMode     string        `mapstructure:"nonce_mode"`     // "random" draws AES-GCM nonces at random, "counter" counts them per key version, saved next to the key file
 }
 
 // BlockchainConfig contains blockchain-related configuration
@@ -71,13 +189,14 @@ type BlockchainConfig struct {
 	ContractAddress string `mapstructure:"contract_address"`
 	PrivateKey      string `mapstructure:"private_key"`
 	GasLimit        uint64 `mapstructure:"gas_limit"`
+	AnchorUploads   bool   `mapstructure:"anchor_uploads"` // Submit each uploaded file's root hash to the contract
 }
 
 // LoggingConfig contains logging configuration
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
-	Format string `mapstructure:"format"`
-	Output string `mapstructure:"output"`
+	Level  string `mapstructure:"level"`  // debug, info, warn or error
+	Format string `mapstructure:"format"` // "json" or "text"
+	Output string `mapstructure:"output"` // "stdout", "stderr" or a file path to append to
 }
 
 // DefaultConfig returns a configuration with default values
@@ -92,26 +211,88 @@ func DefaultConfig() *Config {
 			MaxStorage: 10 * 1024 * 1024 * 1024, // 10GB
 			Replicas:   3,
 			ChunkSize:  1024 * 1024, // 1MB
+
+			MinChunkSize: 64 * 1024,        // 64KB
+			MaxChunkSize: 64 * 1024 * 1024, // 64MB
+
+			StorageHeadroom: 100 * 1024 * 1024, // 100MB
 		},
 		API: APIConfig{
-			Host: "localhost",
-			Port: 8080,
-			TLS:  false,
+			Host:            "localhost",
+			Port:            8080,
+			TLS:             false,
+			ShutdownTimeout: 30 * time.Second,
+			RateLimit: RateLimitConfig{
+				Enabled:    true,
+				ReadRate:   100,
+				ReadBurst:  200,
+				WriteRate:  20,
+				WriteBurst: 40,
+			},
+			Compression: CompressionConfig{
+				Enabled: true,
+				MinSize: 1024,
+			},
+			TrustedProxies: []string{},
+			Uploads: UploadLimitConfig{
+				MaxConcurrent: 16,
+				QueueSize:     64,
+				QueueTimeout:  30 * time.Second,
+				SessionTTL:    24 * time.Hour,
+				MaxStaged:     1024 * 1024 * 1024, // 1GB
+			},
+			CORS: CORSConfig{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "Range", "X-Owner", "X-Request-ID"},
+				MaxAge:         10 * time.Minute,
+			},
 		},
 		Storage: StorageConfig{
-			Backend:     "filesystem",
-			Path:        filepath.Join(dataDir, "files"),
-			MaxFileSize: 100 * 1024 * 1024, // 100MB
-			Compression: true,
+			Backend:         "filesystem",
+			Path:            filepath.Join(dataDir, "files"),
+			MetadataPath:    filepath.Join(dataDir, "metadata.json"),
+			MaxFileSize:     100 * 1024 * 1024, // 100MB
+			Compression:     true,
+			Codec:           "gzip",
+			HashAlgorithm:   "sha256",
+			FileIDMode:      "name",
+			IDScheme:        "content-hash",
+			ScrubInterval:   24 * time.Hour,
+			GCInterval:      6 * time.Hour,
+			GCGracePeriod:   24 * time.Hour,
+			Redundancy:      "replication",
+			DataShards:      4,
+			ParityShards:    2,
+			DownloadWorkers: 4,
+			StoreWorkers:    4,
+			PathDepth:       1,
+			PathWidth:       2,
+			S3: S3Config{
+				Region: "us-east-1",
+			},
 		},
 		P2P: P2PConfig{
-			ListenAddr: "/ip4/0.0.0.0/tcp/4001",
-			MaxPeers:   100,
+			ListenAddr:        "/ip4/0.0.0.0/tcp/4001",
+			BootstrapPeers:    []string{},
+			MaxPeers:          100,
+			HeartbeatInterval: 10 * time.Second,
+			PeerTimeout:       30 * time.Second,
+			ControlAddr:       "127.0.0.1:4002",
+			SyncInterval:      5 * time.Minute,
+			SyncFanout:        3,
+			BreakerThreshold:  5,
+			BreakerCooldown:   30 * time.Second,
+			MaxMessageSize:    96 * 1024 * 1024, // 96MB, room for a base64-encoded 64MB chunk
 		},
 		Crypto: CryptoConfig{
 			Algorithm: "AES-256-GCM",
 			KeySize:   32,
+			KeyFile:   filepath.Join(dataDir, "keys", "master.key"),
 			EnableTLS: true,
+
+			RekeyInterval: time.Hour,
+			NonceMode:     "random",
 		},
 		Blockchain: BlockchainConfig{
 			Network:  "polygon-mumbai",
@@ -125,51 +306,129 @@ func DefaultConfig() *Config {
 	}
 }
 
-// LoadConfig loads configuration from file and environment variables
+// configFormats are the supported config file extensions, in the order they are searched
+var configFormats = []string{"yaml", "yml", "toml", "json"}
+
+// configSearchPaths are the directories searched for config.<format> when no path is given
+var configSearchPaths = []string{".", "./config", "$HOME/.dcs"}
+
+// LoadConfig loads configuration from file and environment variables.
+// The file format is detected from the extension of configPath; without a path,
+// config.yaml, config.toml and config.json are searched for in that order.
 func LoadConfig(configPath string) (*Config, error) {
 	config := DefaultConfig()
+	v := viper.New()
 
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
+	if configPath == "" {
+		configPath = findConfigFile()
+	}
 
 	if configPath != "" {
-		viper.SetConfigFile(configPath)
-	} else {
-		viper.AddConfigPath(".")
-		viper.AddConfigPath("./config")
-		viper.AddConfigPath("$HOME/.dcs")
+		format, err := configFormat(configPath)
+		if err != nil {
+			return nil, err
+		}
+		v.SetConfigFile(configPath)
+		v.SetConfigType(format)
 	}
 
 	// Environment variables
-	viper.SetEnvPrefix("DCS")
-	viper.AutomaticEnv()
+	v.SetEnvPrefix("DCS")
+	v.AutomaticEnv()
 
 	// Read config file
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+	if configPath != "" {
+		if err := v.ReadInConfig(); err != nil {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
 
 	// Unmarshal config
-	if err := viper.Unmarshal(config); err != nil {
+	if err := v.Unmarshal(config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if err := config.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
-// Save saves the configuration to file
-func (c *Config) Save(filepath string) error {
-	viper.Set("node", c.Node)
-	viper.Set("api", c.API)
-	viper.Set("storage", c.Storage)
-	viper.Set("p2p", c.P2P)
-	viper.Set("crypto", c.Crypto)
-	viper.Set("blockchain", c.Blockchain)
-	viper.Set("logging", c.Logging)
+// findConfigFile returns the first config file found in the search paths, or "" if there is none
+func findConfigFile() string {
+	for _, dir := range configSearchPaths {
+		dir = os.ExpandEnv(dir)
+		for _, format := range configFormats {
+			path := filepath.Join(dir, "config."+format)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// configFormat returns the config format matching the extension of path
+func configFormat(path string) (string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	for _, format := range configFormats {
+		if ext == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported config format %q: expected one of %s", ext, strings.Join(configFormats, ", "))
+}
+
+// Save saves the configuration to file, in the format matching the file extension.
+// Secrets are never written in decrypted form.
+func (c *Config) Save(path string) error {
+	format, err := configFormat(path)
+	if err != nil {
+		return err
+	}
+
+	out, err := c.protectSecrets()
+	if err != nil {
+		return err
+	}
+
+	v := viper.New()
+	v.SetConfigType(format)
+	for key, value := range settingsMap(reflect.ValueOf(*out)) {
+		v.Set(key, value)
+	}
+
+	if err := v.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// settingsMap converts a config struct to nested maps keyed by the mapstructure tags,
+// so every format writes the same key names LoadConfig reads
+func settingsMap(value reflect.Value) map[string]interface{} {
+	settings := make(map[string]interface{})
+	for i := 0; i < value.NumField(); i++ {
+		if !value.Type().Field(i).IsExported() {
+			continue
+		}
+
+		key := value.Type().Field(i).Tag.Get("mapstructure")
+		field := value.Field(i)
 
-	return viper.WriteConfigAs(filepath)
+		switch {
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			settings[key] = time.Duration(field.Int()).String()
+		case field.Kind() == reflect.Struct:
+			settings[key] = settingsMap(field)
+		case field.Kind() == reflect.Slice && field.IsNil():
+			settings[key] = reflect.MakeSlice(field.Type(), 0, 0).Interface()
+		default:
+			settings[key] = field.Interface()
+		}
+	}
+	return settings
 }
 
 // Validate validates the configuration
@@ -178,6 +437,21 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid chunk size: %d", c.Node.ChunkSize)
 	}
 
+	if c.Node.MinChunkSize <= 0 || c.Node.MaxChunkSize < c.Node.MinChunkSize {
+		return fmt.Errorf("invalid chunk size bounds: %d-%d", c.Node.MinChunkSize, c.Node.MaxChunkSize)
+	}
+	if c.Node.ChunkSize < c.Node.MinChunkSize || c.Node.ChunkSize > c.Node.MaxChunkSize {
+		return fmt.Errorf("chunk size %d outside bounds %d-%d", c.Node.ChunkSize, c.Node.MinChunkSize, c.Node.MaxChunkSize)
+	}
+
+	if c.Node.MaxStorage < 0 {
+		return fmt.Errorf("invalid max storage: %d", c.Node.MaxStorage)
+	}
+
+	if c.Node.StorageHeadroom < 0 || (c.Node.MaxStorage > 0 && c.Node.StorageHeadroom >= c.Node.MaxStorage) {
+		return fmt.Errorf("invalid storage headroom: %d must be below max storage %d", c.Node.StorageHeadroom, c.Node.MaxStorage)
+	}
+
 	if c.Node.Replicas <= 0 {
 		return fmt.Errorf("invalid replicas count: %d", c.Node.Replicas)
 	}
@@ -186,9 +460,327 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid API port: %d", c.API.Port)
 	}
 
+	if c.API.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout: %s", c.API.ShutdownTimeout)
+	}
+
+	if c.API.RequestTimeout < 0 {
+		return fmt.Errorf("invalid request timeout: %s", c.API.RequestTimeout)
+	}
+
+	for _, proxy := range c.API.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid trusted proxy: %s", proxy)
+			}
+		}
+	}
+
+	if rl := c.API.RateLimit; rl.Enabled {
+		if rl.ReadRate <= 0 || rl.WriteRate <= 0 {
+			return fmt.Errorf("invalid rate limit: rates must be positive")
+		}
+		if rl.ReadBurst < 1 || rl.WriteBurst < 1 {
+			return fmt.Errorf("invalid rate limit: bursts must be at least 1")
+		}
+	}
+
+	if c.API.Compression.MinSize < 0 {
+		return fmt.Errorf("invalid compression min size: %d", c.API.Compression.MinSize)
+	}
+
+	if u := c.API.Uploads; u.MaxConcurrent < 0 || u.QueueSize < 0 || u.QueueTimeout < 0 || u.SessionTTL < 0 || u.MaxStaged < 0 {
+		return fmt.Errorf("invalid upload limits: values must not be negative")
+	}
+
+	for _, origin := range c.API.CORS.AllowedOrigins {
+		if origin == "*" {
+			// Browsers refuse credentialed responses allowing every origin
+			if c.API.CORS.AllowCredentials {
+				return fmt.Errorf("invalid CORS config: allow_credentials requires explicit origins, not \"*\"")
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("invalid CORS origin: %q", origin)
+		}
+	}
+	if c.API.CORS.MaxAge < 0 {
+		return fmt.Errorf("invalid CORS max age: %s", c.API.CORS.MaxAge)
+	}
+
+	if c.API.TLS {
+		if err := checkFile("API TLS certificate", c.API.CertFile); err != nil {
+			return err
+		}
+		if err := checkFile("API TLS key", c.API.KeyFile); err != nil {
+			return err
+		}
+		if c.API.ClientCAFile != "" {
+			if err := checkFile("API client CA", c.API.ClientCAFile); err != nil {
+				return err
+			}
+		} else if c.API.RequireClientCert {
+			return fmt.Errorf("require_client_cert requires client_ca_file to be set")
+		}
+	} else if c.API.RedirectAddr != "" {
+		return fmt.Errorf("redirect_addr requires TLS to be enabled")
+	} else if c.API.ClientCAFile != "" || c.API.RequireClientCert {
+		return fmt.Errorf("client certificate authentication requires TLS to be enabled")
+	}
+
 	if c.Storage.MaxFileSize <= 0 {
 		return fmt.Errorf("invalid max file size: %d", c.Storage.MaxFileSize)
 	}
 
+	if c.Storage.DefaultQuota < 0 {
+		return fmt.Errorf("invalid default quota: %d", c.Storage.DefaultQuota)
+	}
+
+	switch c.Storage.Backend {
+	case "", "filesystem":
+	case "s3":
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			return fmt.Errorf("s3 backend requires an endpoint and bucket")
+		}
+	default:
+		return fmt.Errorf("invalid storage backend: %s", c.Storage.Backend)
+	}
+
+	if c.Storage.Compression && c.Storage.Codec != "gzip" {
+		return fmt.Errorf("unsupported compression codec: %s", c.Storage.Codec)
+	}
+
+	if _, err := types.ParseHashAlgorithm(c.Storage.HashAlgorithm); err != nil {
+		return err
+	}
+	if _, err := types.ParseFileIDMode(c.Storage.FileIDMode); err != nil {
+		return err
+	}
+	if _, err := types.ParseIDScheme(c.Storage.IDScheme); err != nil {
+		return err
+	}
+
+	if c.Storage.GCInterval < 0 {
+		return fmt.Errorf("invalid gc interval: %s", c.Storage.GCInterval)
+	}
+
+	if c.Storage.GCGracePeriod < 0 {
+		return fmt.Errorf("invalid gc grace period: %s", c.Storage.GCGracePeriod)
+	}
+
+	if c.Storage.TrashRetention < 0 {
+		return fmt.Errorf("invalid trash retention: %s", c.Storage.TrashRetention)
+	}
+
+	if c.Storage.PathDepth < 0 || c.Storage.PathDepth > 4 {
+		return fmt.Errorf("invalid storage path depth: %d", c.Storage.PathDepth)
+	}
+
+	if c.Storage.PathDepth > 0 && (c.Storage.PathWidth < 1 || c.Storage.PathWidth > 8) {
+		return fmt.Errorf("invalid storage path width: %d", c.Storage.PathWidth)
+	}
+
+	if c.Storage.DownloadWorkers < 0 {
+		return fmt.Errorf("invalid download workers: %d", c.Storage.DownloadWorkers)
+	}
+	if c.Storage.StoreWorkers < 0 {
+		return fmt.Errorf("invalid store workers: %d", c.Storage.StoreWorkers)
+	}
+
+	if c.Storage.ScrubInterval < 0 {
+		return fmt.Errorf("invalid scrub interval: %s", c.Storage.ScrubInterval)
+	}
+
+	switch c.Storage.Redundancy {
+	case "", "replication":
+	case "erasure":
+		if c.Storage.DataShards <= 0 || c.Storage.ParityShards <= 0 {
+			return fmt.Errorf("invalid erasure coding shards: %d data, %d parity", c.Storage.DataShards, c.Storage.ParityShards)
+		}
+	default:
+		return fmt.Errorf("invalid redundancy mode: %s", c.Storage.Redundancy)
+	}
+
+	if err := validateMultiaddr(c.P2P.ListenAddr); err != nil {
+		return fmt.Errorf("invalid P2P listen address %q: %w", c.P2P.ListenAddr, err)
+	}
+
+	if c.P2P.MaxPeers <= 0 {
+		return fmt.Errorf("invalid max peers: %d", c.P2P.MaxPeers)
+	}
+
+	if c.P2P.HeartbeatInterval <= 0 {
+		return fmt.Errorf("invalid heartbeat interval: %s", c.P2P.HeartbeatInterval)
+	}
+
+	if c.P2P.PeerTimeout <= c.P2P.HeartbeatInterval {
+		return fmt.Errorf("invalid peer timeout: %s must be longer than the heartbeat interval", c.P2P.PeerTimeout)
+	}
+
+	if c.P2P.SyncInterval <= 0 {
+		return fmt.Errorf("invalid sync interval: %s", c.P2P.SyncInterval)
+	}
+
+	if c.P2P.SyncFanout <= 0 {
+		return fmt.Errorf("invalid sync fanout: %d", c.P2P.SyncFanout)
+	}
+
+	if c.P2P.BreakerThreshold <= 0 {
+		return fmt.Errorf("invalid breaker threshold: %d", c.P2P.BreakerThreshold)
+	}
+
+	if c.P2P.BreakerCooldown <= 0 {
+		return fmt.Errorf("invalid breaker cooldown: %s", c.P2P.BreakerCooldown)
+	}
+
+	// Chunks travel base64-encoded, a third larger than their size
+	if c.P2P.MaxMessageSize <= int64(c.Node.MaxChunkSize)*4/3 {
+		return fmt.Errorf("invalid max message size: %d cannot carry a chunk of %d bytes", c.P2P.MaxMessageSize, c.Node.MaxChunkSize)
+	}
+
+	cipher, err := crypto.ParseCipher(c.Crypto.Algorithm)
+	if err != nil {
+		return fmt.Errorf("invalid crypto algorithm: %w", err)
+	}
+	if keySize := cipherKeySizes[cipher]; c.Crypto.KeySize != keySize {
+		return fmt.Errorf("invalid key size %d for %s: expected %d", c.Crypto.KeySize, cipher, keySize)
+	}
+	if c.Crypto.RekeyInterval < 0 {
+		return fmt.Errorf("invalid rekey interval: %s", c.Crypto.RekeyInterval)
+	}
+	if _, err := crypto.ParseNonceMode(c.Crypto.NonceMode); err != nil {
+		return err
+	}
+
+	if c.Blockchain.RPCEndpoint != "" {
+		endpoint, err := url.Parse(c.Blockchain.RPCEndpoint)
+		if err != nil {
+			return fmt.Errorf("invalid blockchain RPC endpoint: %w", err)
+		}
+		switch endpoint.Scheme {
+		case "http", "https", "ws", "wss":
+		default:
+			return fmt.Errorf("invalid blockchain RPC endpoint %q: unsupported scheme %q", c.Blockchain.RPCEndpoint, endpoint.Scheme)
+		}
+		if endpoint.Host == "" {
+			return fmt.Errorf("invalid blockchain RPC endpoint %q: missing host", c.Blockchain.RPCEndpoint)
+		}
+	}
+
+	if c.Blockchain.ContractAddress != "" && !isHexString(c.Blockchain.ContractAddress, 20) {
+		return fmt.Errorf("invalid blockchain contract address: %s", c.Blockchain.ContractAddress)
+	}
+
+	if c.Blockchain.PrivateKey != "" && !isHexString(c.Blockchain.PrivateKey, 32) {
+		return fmt.Errorf("invalid blockchain private key: expected 32 hex-encoded bytes")
+	}
+
+	if c.Blockchain.AnchorUploads && (c.Blockchain.RPCEndpoint == "" || c.Blockchain.ContractAddress == "") {
+		return fmt.Errorf("anchoring uploads requires a blockchain RPC endpoint and contract address")
+	}
+
+	if c.Logging.Level != "" {
+		if _, err := logrus.ParseLevel(c.Logging.Level); err != nil {
+			return fmt.Errorf("invalid log level: %s", c.Logging.Level)
+		}
+	}
+	if _, err := logFormatter(c.Logging.Format); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// cipherKeySizes holds the key size in bytes required by each supported cipher
+var cipherKeySizes = map[crypto.Cipher]int{
+	crypto.CipherAES256GCM:        32,
+	crypto.CipherChaCha20Poly1305: 32,
+}
+
+// checkFile returns an error unless path names an existing regular file
+func checkFile(description, path string) error {
+	if path == "" {
+		return fmt.Errorf("%s file is required", description)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("invalid %s file: %w", description, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("invalid %s file: %s is a directory", description, path)
+	}
 	return nil
 }
+
+// multiaddrProtocols lists the multiaddr protocols accepted in listen addresses and
+// whether each one takes a value
+var multiaddrProtocols = map[string]bool{
+	"ip4": true, "ip6": true, "dns": true, "dns4": true, "dns6": true,
+	"tcp": true, "udp": true, "p2p": true,
+	"quic": false, "quic-v1": false, "ws": false, "wss": false,
+}
+
+// validateMultiaddr checks that addr is a multiaddr such as /ip4/0.0.0.0/tcp/4001
+func validateMultiaddr(addr string) error {
+	if !strings.HasPrefix(addr, "/") {
+		return fmt.Errorf("must start with /")
+	}
+
+	parts := strings.Split(addr[1:], "/")
+	switch parts[0] {
+	case "ip4", "ip6", "dns", "dns4", "dns6":
+	default:
+		return fmt.Errorf("must start with an ip4, ip6 or dns component")
+	}
+
+	for i := 0; i < len(parts); i++ {
+		protocol := parts[i]
+		hasValue, known := multiaddrProtocols[protocol]
+		if !known {
+			return fmt.Errorf("unknown protocol %q", protocol)
+		}
+		if !hasValue {
+			continue
+		}
+
+		i++
+		if i >= len(parts) || parts[i] == "" {
+			return fmt.Errorf("missing value for %s", protocol)
+		}
+		if err := validateMultiaddrValue(protocol, parts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateMultiaddrValue checks the value of a single multiaddr component
+func validateMultiaddrValue(protocol, value string) error {
+	switch protocol {
+	case "ip4":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid IPv4 address %q", value)
+		}
+	case "ip6":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid IPv6 address %q", value)
+		}
+	case "tcp", "udp":
+		if port, err := strconv.Atoi(value); err != nil || port < 0 || port > 65535 {
+			return fmt.Errorf("invalid %s port %q", protocol, value)
+		}
+	}
+	return nil
+}
+
+// isHexString reports whether s is a hex encoding of size bytes, with an optional 0x prefix
+func isHexString(s string, size int) bool {
+	s = strings.TrimPrefix(s, "0x")
+	if len(s) != size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,650 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateDefaultConfig(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected default config to be valid, got %v", err)
+	}
+}
+
+func TestValidateP2P(t *testing.T) {
+	tests := []struct {
+		name       string
+		listenAddr string
+		maxPeers   int
+		wantErr    string
+	}{
+		{"ip4 tcp", "/ip4/0.0.0.0/tcp/4001", 100, ""},
+		{"ip6 udp quic", "/ip6/::1/udp/4001/quic-v1", 100, ""},
+		{"dns with peer id", "/dns4/node.example.com/tcp/4001/p2p/QmPeer", 100, ""},
+		{"host:port", "0.0.0.0:4001", 100, "must start with /"},
+		{"empty", "", 100, "must start with /"},
+		{"transport first", "/tcp/4001", 100, "must start with an ip4"},
+		{"bad ip4", "/ip4/300.0.0.1/tcp/4001", 100, "invalid IPv4 address"},
+		{"ip4 in ip6", "/ip6/127.0.0.1/tcp/4001", 100, "invalid IPv6 address"},
+		{"bad port", "/ip4/0.0.0.0/tcp/70000", 100, "invalid tcp port"},
+		{"missing port", "/ip4/0.0.0.0/tcp", 100, "missing value for tcp"},
+		{"unknown protocol", "/ip4/0.0.0.0/sctp/4001", 100, "unknown protocol"},
+		{"zero max peers", "/ip4/0.0.0.0/tcp/4001", 0, "invalid max peers"},
+		{"negative max peers", "/ip4/0.0.0.0/tcp/4001", -1, "invalid max peers"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.P2P.ListenAddr = tt.listenAddr
+		cfg.P2P.MaxPeers = tt.maxPeers
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateSync(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		fanout   int
+		wantErr  string
+	}{
+		{"defaults", 5 * time.Minute, 3, ""},
+		{"zero interval", 0, 3, "invalid sync interval"},
+		{"zero fanout", time.Minute, 0, "invalid sync fanout"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.P2P.SyncInterval = tt.interval
+		cfg.P2P.SyncFanout = tt.fanout
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		cooldown  time.Duration
+		wantErr   string
+	}{
+		{"defaults", 5, 30 * time.Second, ""},
+		{"zero threshold", 0, 30 * time.Second, "invalid breaker threshold"},
+		{"zero cooldown", 5, 0, "invalid breaker cooldown"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.P2P.BreakerThreshold = tt.threshold
+		cfg.P2P.BreakerCooldown = tt.cooldown
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateMaxMessageSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		wantErr string
+	}{
+		{"defaults", DefaultConfig().P2P.MaxMessageSize, ""},
+		{"zero", 0, "invalid max message size"},
+		{"smaller than an encoded chunk", 64 * 1024 * 1024, "cannot carry a chunk"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.P2P.MaxMessageSize = tt.size
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr string
+	}{
+		{"no limit", 0, ""},
+		{"limit", time.Minute, ""},
+		{"negative", -time.Second, "invalid request timeout"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.API.RequestTimeout = tt.timeout
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateCrypto(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		keySize   int
+		wantErr   string
+	}{
+		{"aes", "AES-256-GCM", 32, ""},
+		{"chacha", "ChaCha20-Poly1305", 32, ""},
+		{"lowercase", "aes-256-gcm", 32, ""},
+		{"unsupported", "DES", 32, "unsupported cipher algorithm"},
+		{"empty", "", 32, "unsupported cipher algorithm"},
+		{"zero key size", "AES-256-GCM", 0, "invalid key size 0"},
+		{"short key", "ChaCha20-Poly1305", 16, "invalid key size 16"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Crypto.Algorithm = tt.algorithm
+		cfg.Crypto.KeySize = tt.keySize
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateRekeyInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantErr  string
+	}{
+		{"default", time.Hour, ""},
+		{"only on read", 0, ""},
+		{"negative", -time.Minute, "invalid rekey interval"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Crypto.RekeyInterval = tt.interval
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateNonceMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr string
+	}{
+		{"random", ""},
+		{"counter", ""},
+		{"", ""},
+		{"sequential", "unsupported nonce mode"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Crypto.NonceMode = tt.mode
+		checkValidateError(t, tt.mode, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateHashAlgorithm(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		wantErr   string
+	}{
+		{"sha256", ""},
+		{"sha512", ""},
+		{"blake2b-256", ""},
+		{"", ""},
+		{"md5", "unsupported hash algorithm"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Storage.HashAlgorithm = tt.algorithm
+		checkValidateError(t, tt.algorithm, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateRateLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		rateLimit RateLimitConfig
+		wantErr   string
+	}{
+		{"valid", RateLimitConfig{Enabled: true, ReadRate: 10, ReadBurst: 1, WriteRate: 0.5, WriteBurst: 2}, ""},
+		{"disabled ignores values", RateLimitConfig{Enabled: false}, ""},
+		{"zero read rate", RateLimitConfig{Enabled: true, ReadRate: 0, ReadBurst: 1, WriteRate: 1, WriteBurst: 1}, "rates must be positive"},
+		{"negative write rate", RateLimitConfig{Enabled: true, ReadRate: 1, ReadBurst: 1, WriteRate: -1, WriteBurst: 1}, "rates must be positive"},
+		{"zero burst", RateLimitConfig{Enabled: true, ReadRate: 1, ReadBurst: 0, WriteRate: 1, WriteBurst: 1}, "bursts must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.API.RateLimit = tt.rateLimit
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		wantErr string
+	}{
+		{"none", nil, ""},
+		{"addresses and ranges", []string{"10.0.0.1", "192.168.0.0/16", "::1"}, ""},
+		{"hostname", []string{"proxy.local"}, "invalid trusted proxy"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.API.TrustedProxies = tt.proxies
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateStorageHeadroom(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxStorage int64
+		headroom   int64
+		wantErr    string
+	}{
+		{"defaults", DefaultConfig().Node.MaxStorage, DefaultConfig().Node.StorageHeadroom, ""},
+		{"unlimited", 0, 1024, ""},
+		{"negative max storage", -1, 0, "invalid max storage"},
+		{"negative headroom", 1024, -1, "invalid storage headroom"},
+		{"headroom fills storage", 1024, 1024, "invalid storage headroom"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Node.MaxStorage = tt.maxStorage
+		cfg.Node.StorageHeadroom = tt.headroom
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateChunkSizeBounds(t *testing.T) {
+	tests := []struct {
+		name                string
+		chunkSize, min, max int
+		wantErr             string
+	}{
+		{"defaults", DefaultConfig().Node.ChunkSize, DefaultConfig().Node.MinChunkSize, DefaultConfig().Node.MaxChunkSize, ""},
+		{"fixed size", 4096, 4096, 4096, ""},
+		{"zero min", 4096, 0, 8192, "invalid chunk size bounds"},
+		{"max below min", 4096, 8192, 4096, "invalid chunk size bounds"},
+		{"default below min", 1024, 4096, 8192, "outside bounds"},
+		{"default above max", 16384, 4096, 8192, "outside bounds"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Node.ChunkSize = tt.chunkSize
+		cfg.Node.MinChunkSize = tt.min
+		cfg.Node.MaxChunkSize = tt.max
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateUploadLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		uploads UploadLimitConfig
+		wantErr string
+	}{
+		{"defaults", DefaultConfig().API.Uploads, ""},
+		{"unlimited", UploadLimitConfig{}, ""},
+		{"negative limit", UploadLimitConfig{MaxConcurrent: -1}, "values must not be negative"},
+		{"negative queue", UploadLimitConfig{MaxConcurrent: 1, QueueSize: -1}, "values must not be negative"},
+		{"negative timeout", UploadLimitConfig{MaxConcurrent: 1, QueueTimeout: -time.Second}, "values must not be negative"},
+		{"negative session TTL", UploadLimitConfig{SessionTTL: -time.Hour}, "values must not be negative"},
+		{"negative staged limit", UploadLimitConfig{MaxStaged: -1}, "values must not be negative"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.API.Uploads = tt.uploads
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name    string
+		cors    CORSConfig
+		wantErr string
+	}{
+		{"defaults", DefaultConfig().API.CORS, ""},
+		{"explicit origins with credentials", CORSConfig{AllowedOrigins: []string{"https://app.example.com", "http://localhost:3000"}, AllowCredentials: true}, ""},
+		{"wildcard with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "allow_credentials requires explicit origins"},
+		{"origin without scheme", CORSConfig{AllowedOrigins: []string{"app.example.com"}}, "invalid CORS origin"},
+		{"origin with path", CORSConfig{AllowedOrigins: []string{"https://app.example.com/ui"}}, "invalid CORS origin"},
+		{"negative max age", CORSConfig{MaxAge: -time.Second}, "invalid CORS max age"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.API.CORS = tt.cors
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.WriteFile(path, []byte("pem"), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tls      bool
+		certFile string
+		keyFile  string
+		wantErr  string
+	}{
+		{"tls disabled", false, "", "", ""},
+		{"files exist", true, certFile, keyFile, ""},
+		{"missing cert setting", true, "", keyFile, "API TLS certificate file is required"},
+		{"missing key setting", true, certFile, "", "API TLS key file is required"},
+		{"cert not found", true, filepath.Join(dir, "missing.pem"), keyFile, "no such file"},
+		{"key is directory", true, certFile, dir, "is a directory"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.API.TLS = tt.tls
+		cfg.API.CertFile = tt.certFile
+		cfg.API.KeyFile = tt.keyFile
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateRedirectRequiresTLS(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.API.RedirectAddr = ":8081"
+	checkValidateError(t, "redirect without tls", cfg.Validate(), "redirect_addr requires TLS")
+}
+
+func TestValidateClientCA(t *testing.T) {
+	dir := t.TempDir()
+	pemFile := filepath.Join(dir, "file.pem")
+	if err := os.WriteFile(pemFile, []byte("pem"), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", pemFile, err)
+	}
+
+	tests := []struct {
+		name    string
+		tls     bool
+		caFile  string
+		require bool
+		wantErr string
+	}{
+		{"optional client certs", true, pemFile, false, ""},
+		{"required client certs", true, pemFile, true, ""},
+		{"require without ca", true, "", true, "require_client_cert requires client_ca_file"},
+		{"ca not found", true, filepath.Join(dir, "missing.pem"), false, "no such file"},
+		{"ca without tls", false, pemFile, false, "requires TLS to be enabled"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.API.TLS = tt.tls
+		if tt.tls {
+			cfg.API.CertFile = pemFile
+			cfg.API.KeyFile = pemFile
+		}
+		cfg.API.ClientCAFile = tt.caFile
+		cfg.API.RequireClientCert = tt.require
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateBlockchain(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   string
+		contract   string
+		privateKey string
+		wantErr    string
+	}{
+		{"unset", "", "", "", ""},
+		{"valid", "https://rpc.example.com", "0x" + strings.Repeat("ab", 20), strings.Repeat("01", 32), ""},
+		{"websocket endpoint", "wss://rpc.example.com/ws", "", "", ""},
+		{"bad scheme", "ftp://rpc.example.com", "", "", "unsupported scheme"},
+		{"missing host", "http://", "", "", "missing host"},
+		{"short contract", "", "0x1234", "", "invalid blockchain contract address"},
+		{"non-hex contract", "", "0x" + strings.Repeat("zz", 20), "", "invalid blockchain contract address"},
+		{"short private key", "", "", "abcd", "invalid blockchain private key"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Blockchain.RPCEndpoint = tt.endpoint
+		cfg.Blockchain.ContractAddress = tt.contract
+		cfg.Blockchain.PrivateKey = tt.privateKey
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateAnchorUploads(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Blockchain.AnchorUploads = true
+	checkValidateError(t, "anchoring without contract", cfg.Validate(), "requires a blockchain RPC endpoint and contract address")
+
+	cfg.Blockchain.RPCEndpoint = "https://rpc.example.com"
+	cfg.Blockchain.ContractAddress = "0x" + strings.Repeat("ab", 20)
+	checkValidateError(t, "anchoring configured", cfg.Validate(), "")
+}
+
+// checkValidateError checks that err is nil when wantErr is empty, or contains wantErr otherwise
+func checkValidateError(t *testing.T, name string, err error, wantErr string) {
+	t.Helper()
+
+	if wantErr == "" {
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+		}
+		return
+	}
+
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("%s: expected error containing %q, got %v", name, wantErr, err)
+	}
+}
+
+// Equivalent config files in every supported format
+var configFixtures = map[string]string{
+	"yaml": `
+node:
+  id: "node-7"
+  chunk_size: 2048
+api:
+  port: 9090
+  shutdown_timeout: "45s"
+storage:
+  backend: "s3"
+  trash_retention: "168h"
+  s3:
+    endpoint: "http://localhost:9000"
+    bucket: "chunks"
+p2p:
+  bootstrap_peers: ["/ip4/10.0.0.1/tcp/4001", "/ip4/10.0.0.2/tcp/4001"]
+blockchain:
+  gas_limit: 750000
+`,
+	"toml": `
+[node]
+id = "node-7"
+chunk_size = 2048
+
+[api]
+port = 9090
+shutdown_timeout = "45s"
+
+[storage]
+backend = "s3"
+trash_retention = "168h"
+
+[storage.s3]
+endpoint = "http://localhost:9000"
+bucket = "chunks"
+
+[p2p]
+bootstrap_peers = ["/ip4/10.0.0.1/tcp/4001", "/ip4/10.0.0.2/tcp/4001"]
+
+[blockchain]
+gas_limit = 750000
+`,
+	"json": `{
+  "node": {"id": "node-7", "chunk_size": 2048},
+  "api": {"port": 9090, "shutdown_timeout": "45s"},
+  "storage": {
+    "backend": "s3",
+    "trash_retention": "168h",
+    "s3": {"endpoint": "http://localhost:9000", "bucket": "chunks"}
+  },
+  "p2p": {"bootstrap_peers": ["/ip4/10.0.0.1/tcp/4001", "/ip4/10.0.0.2/tcp/4001"]},
+  "blockchain": {"gas_limit": 750000}
+}`,
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+
+	var loaded []*Config
+	for _, format := range []string{"yaml", "toml", "json"} {
+		path := filepath.Join(dir, "config."+format)
+		if err := os.WriteFile(path, []byte(configFixtures[format]), 0600); err != nil {
+			t.Fatalf("Failed to write %s config: %v", format, err)
+		}
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("Failed to load %s config: %v", format, err)
+		}
+
+		if cfg.Node.ID != "node-7" || cfg.API.Port != 9090 || cfg.API.ShutdownTimeout != 45*time.Second {
+			t.Errorf("%s: expected file values to be loaded, got node %q port %d timeout %s",
+				format, cfg.Node.ID, cfg.API.Port, cfg.API.ShutdownTimeout)
+		}
+		if cfg.Crypto.Algorithm != "AES-256-GCM" {
+			t.Errorf("%s: expected unset values to keep defaults, got algorithm %q", format, cfg.Crypto.Algorithm)
+		}
+		loaded = append(loaded, cfg)
+	}
+
+	for i, cfg := range loaded[1:] {
+		if !reflect.DeepEqual(loaded[0], cfg) {
+			t.Errorf("Expected config %d to match the yaml config:\nyaml: %+v\ngot:  %+v", i+1, loaded[0], cfg)
+		}
+	}
+}
+
+func TestSaveConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+
+	original := DefaultConfig()
+	original.Node.ID = "saved-node"
+	original.API.ShutdownTimeout = 90 * time.Second
+	original.Storage.S3.Bucket = "saved-bucket"
+	original.P2P.BootstrapPeers = []string{"/ip4/10.0.0.3/tcp/4001"}
+
+	for _, format := range []string{"yaml", "toml", "json"} {
+		path := filepath.Join(dir, "saved."+format)
+		if err := original.Save(path); err != nil {
+			t.Fatalf("Failed to save %s config: %v", format, err)
+		}
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("Failed to load saved %s config: %v", format, err)
+		}
+		if !reflect.DeepEqual(original, cfg) {
+			t.Errorf("%s: expected saved config to round trip:\nwant: %+v\ngot:  %+v", format, original, cfg)
+		}
+	}
+
+	if err := original.Save(filepath.Join(dir, "saved.ini")); err == nil {
+		t.Error("Expected error saving to an unsupported format")
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	previous := configSearchPaths
+	configSearchPaths = []string{dir}
+	defer func() { configSearchPaths = previous }()
+
+	if path := findConfigFile(); path != "" {
+		t.Errorf("Expected no config file, got %s", path)
+	}
+
+	// toml is preferred over json, and yaml over both
+	for _, format := range []string{"json", "toml", "yaml"} {
+		path := filepath.Join(dir, "config."+format)
+		if err := os.WriteFile(path, []byte(configFixtures[format]), 0600); err != nil {
+			t.Fatalf("Failed to write %s config: %v", format, err)
+		}
+
+		if found := findConfigFile(); found != path {
+			t.Errorf("Expected %s, got %s", path, found)
+		}
+	}
+}
+
+func TestValidateIDScheme(t *testing.T) {
+	tests := []struct {
+		scheme  string
+		wantErr string
+	}{
+		{"content-hash", ""},
+		{"random", ""},
+		{"", ""},
+		{"snowflake", "unsupported ID scheme"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Storage.IDScheme = tt.scheme
+		checkValidateError(t, tt.scheme, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateFileIDMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr string
+	}{
+		{"name", ""},
+		{"dedup", ""},
+		{"content", "unsupported file ID mode"},
+		{"", ""},
+		{"path", "unsupported file ID mode"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Storage.FileIDMode = tt.mode
+		checkValidateError(t, tt.mode, cfg.Validate(), tt.wantErr)
+	}
+}
+
+func TestValidateStoreWorkers(t *testing.T) {
+	tests := []struct {
+		workers int
+		wantErr string
+	}{
+		{0, ""},
+		{1, ""},
+		{8, ""},
+		{-1, "invalid store workers"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Storage.StoreWorkers = tt.workers
+		checkValidateError(t, strconv.Itoa(tt.workers), cfg.Validate(), tt.wantErr)
+	}
+}
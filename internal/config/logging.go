@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewLogger creates a logger with the level, format and output of the config. Format is
+// "json" or "text", and output is "stdout", "stderr" or the path of a file that log
+// lines are appended to. Empty settings fall back to info level JSON on stdout.
+func NewLogger(cfg LoggingConfig) (*logrus.Logger, error) {
+	logger := logrus.New()
+
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		var err error
+		if level, err = logrus.ParseLevel(cfg.Level); err != nil {
+			return nil, fmt.Errorf("invalid log level: %s", cfg.Level)
+		}
+	}
+	logger.SetLevel(level)
+
+	formatter, err := logFormatter(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetFormatter(formatter)
+
+	out, err := logOutput(cfg.Output)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetOutput(out)
+
+	return logger, nil
+}
+
+// logFormatter returns the formatter for a log format name
+func logFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "", "json":
+		return &logrus.JSONFormatter{}, nil
+	case "text":
+		return &logrus.TextFormatter{FullTimestamp: true}, nil
+	default:
+		return nil, fmt.Errorf("invalid log format: %s (expected json or text)", format)
+	}
+}
+
+// logOutput returns the writer for a log output setting, opening log files for appending
+func logOutput(output string) (io.Writer, error) {
+	switch output {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	default:
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		return file, nil
+	}
+}
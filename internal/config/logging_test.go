@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewLoggerFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr string
+	}{
+		{"", "*logrus.JSONFormatter", ""},
+		{"json", "*logrus.JSONFormatter", ""},
+		{"text", "*logrus.TextFormatter", ""},
+		{"xml", "", "invalid log format"},
+	}
+
+	for _, tt := range tests {
+		logger, err := NewLogger(LoggingConfig{Format: tt.format})
+		if tt.wantErr != "" {
+			checkValidateError(t, "format "+tt.format, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Format %q: unexpected error: %v", tt.format, err)
+		}
+		if got := fmt.Sprintf("%T", logger.Formatter); got != tt.want {
+			t.Errorf("Format %q: expected %s, got %s", tt.format, tt.want, got)
+		}
+	}
+}
+
+func TestNewLoggerLevel(t *testing.T) {
+	logger, err := NewLogger(LoggingConfig{Level: "warn"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logger.GetLevel() != logrus.WarnLevel {
+		t.Errorf("Expected level warn, got %s", logger.GetLevel())
+	}
+
+	if logger, _ := NewLogger(LoggingConfig{}); logger.GetLevel() != logrus.InfoLevel {
+		t.Errorf("Expected default level info, got %s", logger.GetLevel())
+	}
+
+	_, err = NewLogger(LoggingConfig{Level: "loud"})
+	checkValidateError(t, "unknown level", err, "invalid log level")
+}
+
+func TestNewLoggerOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		want   *os.File
+	}{
+		{"", os.Stdout},
+		{"stdout", os.Stdout},
+		{"stderr", os.Stderr},
+	}
+
+	for _, tt := range tests {
+		logger, err := NewLogger(LoggingConfig{Output: tt.output})
+		if err != nil {
+			t.Fatalf("Output %q: unexpected error: %v", tt.output, err)
+		}
+		if logger.Out != tt.want {
+			t.Errorf("Output %q: expected %s, got %v", tt.output, tt.want.Name(), logger.Out)
+		}
+	}
+
+	// Log files are appended to, not truncated
+	path := filepath.Join(t.TempDir(), "api.log")
+	if err := os.WriteFile(path, []byte("{\"msg\":\"earlier\"}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	logger, err := NewLogger(LoggingConfig{Format: "json", Output: path})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	logger.WithField("file_id", "abc").Info("stored")
+	logger.Out.(*os.File).Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d: %q", len(lines), data)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", lines[1], err)
+	}
+	if entry["msg"] != "stored" || entry["file_id"] != "abc" {
+		t.Errorf("Unexpected log entry: %v", entry)
+	}
+
+	_, err = NewLogger(LoggingConfig{Output: filepath.Join(t.TempDir(), "missing", "api.log")})
+	checkValidateError(t, "missing log directory", err, "failed to open log file")
+}
+
+func TestValidateLogging(t *testing.T) {
+	tests := []struct {
+		name    string
+		logging LoggingConfig
+		wantErr string
+	}{
+		{"defaults", DefaultConfig().Logging, ""},
+		{"text to file", LoggingConfig{Level: "debug", Format: "text", Output: "/var/log/dcs.log"}, ""},
+		{"unknown level", LoggingConfig{Level: "loud", Format: "json"}, "invalid log level"},
+		{"unknown format", LoggingConfig{Level: "info", Format: "xml"}, "invalid log format"},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Logging = tt.logging
+		checkValidateError(t, tt.name, cfg.Validate(), tt.wantErr)
+	}
+}
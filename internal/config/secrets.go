@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+)
+
+// MasterKeyEnv names the environment variable holding the hex-encoded key used to
+// decrypt encrypted config values
+const MasterKeyEnv = "DCS_MASTER_KEY"
+
+// encryptedPrefix marks a config value encrypted with the master key
+const encryptedPrefix = "enc:"
+
+// envReference matches a ${env:VAR} reference in a config value
+var envReference = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// secretRef remembers how a secret was written in the config file
+type secretRef struct {
+	raw      string // Value as written in the file
+	resolved string // Value after interpolation and decryption
+}
+
+// secretFields returns the settings that may hold secrets, keyed by setting name
+func (c *Config) secretFields() map[string]*string {
+	return map[string]*string{
+		"api.admin_token":              &c.API.AdminToken,
+		"storage.s3.secret_access_key": &c.Storage.S3.SecretAccessKey,
+		"p2p.private_key":              &c.P2P.PrivateKey,
+		"crypto.key_file":              &c.Crypto.KeyFile,
+		"crypto.tls_key_path":          &c.Crypto.TLSKeyPath,
+		"blockchain.private_key":       &c.Blockchain.PrivateKey,
+	}
+}
+
+// resolveSecrets replaces ${env:VAR} references and encrypted values in secret settings
+// with their plaintext, remembering the original form so Save can write it back
+func (c *Config) resolveSecrets() error {
+	var key crypto.EncryptionKey
+	for name, field := range c.secretFields() {
+		raw := *field
+		value := envReference.ReplaceAllStringFunc(raw, func(ref string) string {
+			return os.Getenv(envReference.FindStringSubmatch(ref)[1])
+		})
+		if missing := missingEnv(raw); missing != "" {
+			return fmt.Errorf("%s references unset environment variable %s", name, missing)
+		}
+
+		if strings.HasPrefix(value, encryptedPrefix) {
+			if key == nil {
+				var err error
+				if key, err = LoadMasterKey(); err != nil {
+					return fmt.Errorf("failed to decrypt %s: %w", name, err)
+				}
+			}
+
+			plaintext, err := DecryptSecret(value, key)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s: %w", name, err)
+			}
+			value = plaintext
+		}
+
+		if value != raw {
+			if c.secrets == nil {
+				c.secrets = make(map[string]secretRef)
+			}
+			c.secrets[name] = secretRef{raw: raw, resolved: value}
+		}
+		*field = value
+	}
+	return nil
+}
+
+// protectSecrets returns a copy of the config safe to write to disk. Secrets loaded from
+// env references or encrypted values are restored to their original form; other secrets
+// are encrypted when a master key is configured.
+func (c *Config) protectSecrets() (*Config, error) {
+	out := *c
+	out.secrets = nil
+
+	var key crypto.EncryptionKey
+	for name, field := range out.secretFields() {
+		if ref, ok := c.secrets[name]; ok && ref.resolved == *field {
+			*field = ref.raw
+			continue
+		}
+		if *field == "" || os.Getenv(MasterKeyEnv) == "" {
+			continue
+		}
+
+		if key == nil {
+			var err error
+			if key, err = LoadMasterKey(); err != nil {
+				return nil, err
+			}
+		}
+		encrypted, err := EncryptSecret(*field, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", name, err)
+		}
+		*field = encrypted
+	}
+	return &out, nil
+}
+
+// missingEnv returns the first variable referenced by value that is not set
+func missingEnv(value string) string {
+	for _, match := range envReference.FindAllStringSubmatch(value, -1) {
+		if _, ok := os.LookupEnv(match[1]); !ok {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// LoadMasterKey reads the master key from the DCS_MASTER_KEY environment variable
+func LoadMasterKey() (crypto.EncryptionKey, error) {
+	encoded := os.Getenv(MasterKeyEnv)
+	if encoded == "" {
+		return nil, fmt.Errorf("%s is not set", MasterKeyEnv)
+	}
+
+	key, err := hex.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 hex-encoded bytes", MasterKeyEnv)
+	}
+	return crypto.EncryptionKey(key), nil
+}
+
+// EncryptSecret encrypts a config value with the master key, returning it in the
+// enc:<base64> form accepted by LoadConfig
+func EncryptSecret(value string, key crypto.EncryptionKey) (string, error) {
+	ciphertext, err := crypto.Encrypt([]byte(value), key)
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptSecret decrypts a value produced by EncryptSecret
+func DecryptSecret(value string, key crypto.EncryptionKey) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+
+	plaintext, err := crypto.Decrypt(ciphertext, key)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
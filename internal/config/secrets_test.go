@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+)
+
+// setMasterKey generates a master key and exports it for the duration of the test
+func setMasterKey(t *testing.T) crypto.EncryptionKey {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	t.Setenv(MasterKeyEnv, hex.EncodeToString(key))
+	return key
+}
+
+// loadConfigString writes content to a yaml file and loads it
+func loadConfigString(t *testing.T, content string) (*Config, string, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	return cfg, path, err
+}
+
+func TestEnvSecretInterpolation(t *testing.T) {
+	t.Setenv("TEST_CHAIN_KEY", "abcdef")
+	t.Setenv("TEST_KEY_DIR", "/run/secrets")
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		wantErr  string
+	}{
+		{"whole value", "${env:TEST_CHAIN_KEY}", "abcdef", ""},
+		{"embedded reference", "${env:TEST_KEY_DIR}/master.key", "/run/secrets/master.key", ""},
+		{"plain value", "literal", "literal", ""},
+		{"unset variable", "${env:TEST_UNSET_VARIABLE}", "", "TEST_UNSET_VARIABLE"},
+	}
+
+	for _, tt := range tests {
+		cfg, _, err := loadConfigString(t, "blockchain:\n  private_key: \""+tt.value+"\"\n")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: failed to load config: %v", tt.name, err)
+		}
+		if cfg.Blockchain.PrivateKey != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, cfg.Blockchain.PrivateKey)
+		}
+	}
+}
+
+func TestEncryptedSecretRoundTrip(t *testing.T) {
+	key := setMasterKey(t)
+
+	encrypted, err := EncryptSecret("p2p-private-key", key)
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+
+	cfg, path, err := loadConfigString(t, "p2p:\n  private_key: \""+encrypted+"\"\napi:\n  admin_token: \"plain-token\"\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.P2P.PrivateKey != "p2p-private-key" {
+		t.Errorf("Expected decrypted private key, got %q", cfg.P2P.PrivateKey)
+	}
+
+	// Saving writes the original ciphertext back and encrypts the plaintext token
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read saved config: %v", err)
+	}
+	if !strings.Contains(string(saved), encrypted) {
+		t.Error("Expected saved config to keep the original encrypted value")
+	}
+	for _, secret := range []string{"p2p-private-key", "plain-token"} {
+		if strings.Contains(string(saved), secret) {
+			t.Errorf("Expected saved config not to contain %q in plaintext", secret)
+		}
+	}
+
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if reloaded.P2P.PrivateKey != "p2p-private-key" || reloaded.API.AdminToken != "plain-token" {
+		t.Errorf("Expected secrets to survive the round trip, got %q and %q", reloaded.P2P.PrivateKey, reloaded.API.AdminToken)
+	}
+
+	// Without the master key the config cannot be loaded
+	os.Unsetenv(MasterKeyEnv)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), MasterKeyEnv) {
+		t.Errorf("Expected error mentioning %s, got %v", MasterKeyEnv, err)
+	}
+}
+
+func TestSaveKeepsEnvReferences(t *testing.T) {
+	t.Setenv("TEST_ADMIN_TOKEN", "from-env")
+
+	cfg, path, err := loadConfigString(t, "api:\n  admin_token: \"${env:TEST_ADMIN_TOKEN}\"\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.API.AdminToken != "from-env" {
+		t.Errorf("Expected token from-env, got %q", cfg.API.AdminToken)
+	}
+
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	saved, _ := os.ReadFile(path)
+	if !strings.Contains(string(saved), "${env:TEST_ADMIN_TOKEN}") || strings.Contains(string(saved), "from-env") {
+		t.Errorf("Expected saved config to keep the env reference, got:\n%s", saved)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrConfigExists is returned when writing a config template over an existing file
+// without force
+var ErrConfigExists = errors.New("config file already exists")
+
+// templateHeader opens a generated config template in the formats that allow comments
+const templateHeader = `# Distributed Cloud Storage configuration
+#
+# Generated with the default value of every setting; edit the values to suit this node.
+# config.example.yaml describes what each setting does. Secret settings (admin_token,
+# s3 secret_access_key, p2p and blockchain private_key, crypto key_file and tls_key_path)
+# may be written as "${env:VAR}" or as "enc:..." values produced by ` + "`api encrypt-secret`" + `.
+
+`
+
+// TemplatePath returns the path a config template is written to. An empty path names
+// config.<format> in the working directory; an empty format is taken from the path,
+// defaulting to yaml, and must otherwise match the path's extension.
+func TemplatePath(path, format string) (string, error) {
+	if path == "" {
+		if format == "" {
+			format = "yaml"
+		}
+		path = "config." + format
+	}
+
+	ext, err := configFormat(path)
+	if err != nil {
+		return "", err
+	}
+	if format != "" && format != ext && !(format == "yaml" && ext == "yml") {
+		return "", fmt.Errorf("config path %s does not match format %s", path, format)
+	}
+	return path, nil
+}
+
+// WriteTemplate writes the default configuration to path, in the format matching the
+// file extension, as a starting point for a new node. An existing file is only
+// overwritten with force.
+func WriteTemplate(path string, force bool) error {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%w: %s (use --force to overwrite)", ErrConfigExists, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to check config file: %w", err)
+		}
+	}
+
+	if err := DefaultConfig().Save(path); err != nil {
+		return err
+	}
+
+	// JSON has no comments, so only the other formats get the header
+	format, _ := configFormat(path)
+	if format == "json" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := os.WriteFile(path, append([]byte(templateHeader), data...), 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
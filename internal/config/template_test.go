@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteTemplate(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, format := range []string{"yaml", "toml", "json"} {
+		path, err := TemplatePath(filepath.Join(dir, "config."+format), format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		if err := WriteTemplate(path, false); err != nil {
+			t.Fatalf("%s: failed to write template: %v", format, err)
+		}
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: failed to load template: %v", format, err)
+		}
+		if !reflect.DeepEqual(DefaultConfig(), cfg) {
+			t.Errorf("%s: expected template to hold the defaults:\nwant: %+v\ngot:  %+v", format, DefaultConfig(), cfg)
+		}
+
+		data, _ := os.ReadFile(path)
+		if commented := strings.HasPrefix(string(data), "#"); commented != (format != "json") {
+			t.Errorf("%s: expected header comment only outside json, got commented=%v", format, commented)
+		}
+	}
+}
+
+func TestWriteTemplateOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("node:\n  id: mine\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	if err := WriteTemplate(path, false); !errors.Is(err, ErrConfigExists) {
+		t.Fatalf("Expected ErrConfigExists, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "node:\n  id: mine\n" {
+		t.Errorf("Expected existing config to be kept, got %q", data)
+	}
+
+	if err := WriteTemplate(path, true); err != nil {
+		t.Fatalf("Failed to overwrite with force: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+	if cfg.Node.ID != DefaultConfig().Node.ID {
+		t.Errorf("Expected forced template to replace the config, got node %q", cfg.Node.ID)
+	}
+}
+
+func TestTemplatePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"", "", "config.yaml", false},
+		{"", "toml", "config.toml", false},
+		{"node.json", "", "node.json", false},
+		{"node.yml", "yaml", "node.yml", false},
+		{"node.yaml", "json", "", true},
+		{"", "ini", "", true},
+		{"node.conf", "", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := TemplatePath(test.path, test.format)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("TemplatePath(%q, %q) = %q, %v; want %q (error %v)", test.path, test.format, got, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// immutableSettings are the settings that cannot change without a restart. A reload
+// changing any of them is rejected as a whole.
+var immutableSettings = map[string]func(*Config) interface{}{
+	"node.id":               func(c *Config) interface{} { return c.Node.ID },
+	"node.data_dir":         func(c *Config) interface{} { return c.Node.DataDir },
+	"node.storage_dir":      func(c *Config) interface{} { return c.Node.StorageDir },
+	"api.host":              func(c *Config) interface{} { return c.API.Host },
+	"api.port":              func(c *Config) interface{} { return c.API.Port },
+	"storage.backend":       func(c *Config) interface{} { return c.Storage.Backend },
+	"storage.path":          func(c *Config) interface{} { return c.Storage.Path },
+	"storage.metadata_path": func(c *Config) interface{} { return c.Storage.MetadataPath },
+	"storage.path_depth":    func(c *Config) interface{} { return c.Storage.PathDepth },
+	"storage.path_width":    func(c *Config) interface{} { return c.Storage.PathWidth },
+	"crypto.algorithm":      func(c *Config) interface{} { return c.Crypto.Algorithm },
+	"crypto.key_file":       func(c *Config) interface{} { return c.Crypto.KeyFile },
+	"crypto.nonce_mode":     func(c *Config) interface{} { return c.Crypto.NonceMode },
+}
+
+// Watcher reloads the config file when it changes. Only the log level, default quota and
+// scrub interval are applied at runtime; subscribers are notified after each reload.
+type Watcher struct {
+	path     string
+	logger   *logrus.Logger
+	current  *Config
+	handlers []func(*Config)
+	mu       sync.Mutex
+}
+
+// NewWatcher creates a watcher for the config file at path, searching the default
+// locations when path is empty. current is the configuration the process started with.
+func NewWatcher(path string, current *Config, logger *logrus.Logger) *Watcher {
+	if path == "" {
+		path = findConfigFile()
+	}
+	return &Watcher{
+		path:    path,
+		logger:  logger,
+		current: current,
+	}
+}
+
+// OnChange registers a function called with the updated configuration after each reload
+func (w *Watcher) OnChange(fn func(*Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handlers = append(w.handlers, fn)
+}
+
+// Current returns the configuration with all reloads applied
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.current
+}
+
+// Start begins watching the config file for changes
+func (w *Watcher) Start() error {
+	if w.path == "" {
+		return fmt.Errorf("no config file to watch")
+	}
+
+	v := viper.New()
+	v.SetConfigFile(w.path)
+	format, err := configFormat(w.path)
+	if err != nil {
+		return err
+	}
+	v.SetConfigType(format)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	v.OnConfigChange(func(event fsnotify.Event) {
+		if err := w.Reload(); err != nil {
+			w.logger.WithError(err).WithField("path", w.path).Error("Config reload failed")
+		}
+	})
+	v.WatchConfig()
+
+	w.logger.WithField("path", w.path).Info("Watching config file for changes")
+	return nil
+}
+
+// Reload re-reads the config file and applies the settings that can change at runtime
+func (w *Watcher) Reload() error {
+	loaded, err := LoadConfig(w.path)
+	if err != nil {
+		return err
+	}
+	if err := loaded.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	level, err := logrus.ParseLevel(loaded.Logging.Level)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	w.mu.Lock()
+	previous := w.current
+	if changed := immutableChanges(previous, loaded); len(changed) > 0 {
+		w.mu.Unlock()
+		return fmt.Errorf("settings cannot change without a restart: %s", strings.Join(changed, ", "))
+	}
+
+	updated := *previous
+	updated.Logging.Level = loaded.Logging.Level
+	updated.Storage.DefaultQuota = loaded.Storage.DefaultQuota
+	updated.Storage.ScrubInterval = loaded.Storage.ScrubInterval
+	w.current = &updated
+	handlers := append([]func(*Config){}, w.handlers...)
+	w.mu.Unlock()
+
+	if updated.Logging.Level != previous.Logging.Level {
+		w.logger.SetLevel(level)
+	}
+
+	if !reflect.DeepEqual(&updated, loaded) {
+		w.logger.WithField("path", w.path).Warn("Config file has changes that only take effect after a restart")
+	}
+
+	w.logger.WithFields(logrus.Fields{
+		"path":           w.path,
+		"log_level":      updated.Logging.Level,
+		"default_quota":  updated.Storage.DefaultQuota,
+		"scrub_interval": updated.Storage.ScrubInterval.String(),
+	}).Info("Config reloaded")
+
+	for _, handler := range handlers {
+		handler(&updated)
+	}
+	return nil
+}
+
+// immutableChanges returns the names of the immutable settings that differ between two configurations
+func immutableChanges(previous, loaded *Config) []string {
+	var changed []string
+	for name, get := range immutableSettings {
+		if get(previous) != get(loaded) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
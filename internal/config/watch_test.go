@@ -0,0 +1,119 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeWatchedConfig writes a yaml config with the given log level and storage path
+func writeWatchedConfig(t *testing.T, path, level, storagePath string) {
+	t.Helper()
+
+	content := "logging:\n  level: \"" + level + "\"\nstorage:\n  path: \"" + storagePath + "\"\n  default_quota: 1024\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+// newTestWatcher loads the config at path and creates a watcher for it
+func newTestWatcher(t *testing.T, path string) (*Watcher, *logrus.Logger) {
+	t.Helper()
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.InfoLevel)
+	return NewWatcher(path, cfg, logger), logger
+}
+
+func TestWatcherReloadsLogLevel(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeWatchedConfig(t, path, "info", "/data/files")
+
+	watcher, logger := newTestWatcher(t, path)
+
+	changes := make(chan *Config, 1)
+	watcher.OnChange(func(cfg *Config) { changes <- cfg })
+
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+
+	writeWatchedConfig(t, path, "debug", "/data/files")
+
+	select {
+	case cfg := <-changes:
+		if cfg.Logging.Level != "debug" {
+			t.Errorf("Expected reloaded level debug, got %s", cfg.Logging.Level)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for config reload")
+	}
+
+	if logger.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected logger level debug, got %s", logger.GetLevel())
+	}
+	if watcher.Current().Logging.Level != "debug" {
+		t.Errorf("Expected current config level debug, got %s", watcher.Current().Logging.Level)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	tests := []struct {
+		name        string
+		level       string
+		storagePath string
+		quota       string
+		wantErr     string
+	}{
+		{"runtime settings", "warn", "/data/files", "4096", ""},
+		{"immutable setting", "warn", "/elsewhere", "4096", "storage.path"},
+		{"invalid log level", "loud", "/data/files", "4096", "invalid log level"},
+		{"invalid quota", "warn", "/data/files", "-1", "invalid default quota"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		writeWatchedConfig(t, path, "info", "/data/files")
+		watcher, logger := newTestWatcher(t, path)
+
+		content := "logging:\n  level: \"" + tt.level + "\"\nstorage:\n  path: \"" + tt.storagePath + "\"\n  default_quota: " + tt.quota + "\n  scrub_interval: \"1h\"\n"
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		err := watcher.Reload()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+			}
+			if logger.GetLevel() != logrus.InfoLevel || watcher.Current().Storage.DefaultQuota != 1024 {
+				t.Errorf("%s: expected rejected reload to leave settings unchanged", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		current := watcher.Current()
+		if current.Storage.DefaultQuota != 4096 || current.Storage.ScrubInterval != time.Hour {
+			t.Errorf("%s: expected quota 4096 and scrub interval 1h, got %d and %s",
+				tt.name, current.Storage.DefaultQuota, current.Storage.ScrubInterval)
+		}
+		if logger.GetLevel() != logrus.WarnLevel {
+			t.Errorf("%s: expected logger level warn, got %s", tt.name, logger.GetLevel())
+		}
+	}
+}
@@ -6,8 +6,17 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
 )
 
 // EncryptionKey represents an encryption key
@@ -23,60 +32,244 @@ func GenerateKey() (EncryptionKey, error) {
 	return EncryptionKey(key), nil
 }
 
-// DeriveKey derives an encryption key from a password using SHA-256
-func DeriveKey(password string) EncryptionKey {
-	hash := sha256.Sum256([]byte(password))
-	return EncryptionKey(hash[:])
+// Zero overwrites the key bytes with zeros so the key does not linger in memory once it
+// is no longer needed. The key, and every slice sharing its bytes, must not be used
+// after it is zeroed.
+func (k EncryptionKey) Zero() {
+	for i := range k {
+		k[i] = 0
+	}
 }
 
-// Encrypt encrypts data using AES-256-GCM
-func Encrypt(data []byte, key EncryptionKey) ([]byte, error) {
+// SaveKey writes an encryption key to disk, readable only by the owner
+func SaveKey(key EncryptionKey, path string) error {
 	if len(key) != 32 {
-		return nil, errors.New("key must be 32 bytes for AES-256")
+		return fmt.Errorf("invalid key length: %d", len(key))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
 	}
 
-	block, err := aes.NewCipher(key)
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	// WriteFile does not change permissions of an existing file
+	return os.Chmod(path, 0600)
+}
+
+// LoadKey reads an encryption key previously written by SaveKey
+func LoadKey(path string) (EncryptionKey, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	if len(data) != 32 {
+		return nil, fmt.Errorf("invalid key file %s: expected 32 bytes, got %d", path, len(data))
+	}
+
+	return EncryptionKey(data), nil
+}
+
+// LoadOrGenerateKey loads the key at path, generating and saving a new one if the file does not exist.
+// The returned bool reports whether a new key was generated.
+func LoadOrGenerateKey(path string) (EncryptionKey, bool, error) {
+	key, err := LoadKey(path)
+	if err == nil {
+		return key, false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	key, err = GenerateKey()
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	if err := SaveKey(key, path); err != nil {
+		return nil, false, err
+	}
+
+	return key, true, nil
+}
+
+// SaltSize is the size in bytes of salts generated by GenerateSalt
+const SaltSize = 16
+
+// KDFParams holds the Argon2id cost parameters used for key derivation
+type KDFParams struct {
+	Time        uint32 `json:"time"`
+	Memory      uint32 `json:"memory"` // in KiB
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// DefaultKDFParams returns the recommended Argon2id parameters
+func DefaultKDFParams() KDFParams {
+	return KDFParams{
+		Time:        1,
+		Memory:      64 * 1024, // 64MB
+		Parallelism: 4,
+	}
+}
+
+// GenerateSalt generates a random salt for key derivation
+func GenerateSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
 		return nil, err
 	}
+	return salt, nil
+}
 
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
+// DeriveKey derives an encryption key from a password using SHA-256
+//
+// Deprecated: SHA-256 is too fast to resist brute-force attacks on weak
+// passwords. Use DeriveKeyArgon2 instead.
+func DeriveKey(password string) EncryptionKey {
+	hash := sha256.Sum256([]byte(password))
+	return EncryptionKey(hash[:])
 }
 
-// Decrypt decrypts data using AES-256-GCM
-func Decrypt(ciphertext []byte, key EncryptionKey) ([]byte, error) {
+// fileKeyInfo labels file subkeys so they cannot collide with keys HKDF derives
+// from the master key for other purposes
+const fileKeyInfo = "distributed-cloud-storage file key v1:"
+
+// DeriveFileKey derives the subkey that encrypts one file's chunks from the master key
+// using HKDF-SHA256. The same master key and file ID always yield the same subkey, so
+// nothing but the master key needs to be stored, while compromising one subkey does
+// not expose other files.
+func DeriveFileKey(master EncryptionKey, fileID string) EncryptionKey {
+	key := make([]byte, 32)
+	reader := hkdf.New(sha256.New, master, nil, []byte(fileKeyInfo+fileID))
+	if _, err := io.ReadFull(reader, key); err != nil {
+		// HKDF-SHA256 can produce up to 8160 bytes, so reading 32 cannot fail
+		panic(fmt.Sprintf("hkdf: %v", err))
+	}
+	return EncryptionKey(key)
+}
+
+// DeriveKeyArgon2 derives an AES-256 encryption key from a password and salt using Argon2id.
+// The salt must be stored alongside the encrypted data so the key can be re-derived later.
+func DeriveKeyArgon2(password string, salt []byte, params KDFParams) EncryptionKey {
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Parallelism, 32)
+	return EncryptionKey(key)
+}
+
+// Cipher identifies an AEAD cipher supported by the crypto package
+type Cipher byte
+
+const (
+	CipherAES256GCM Cipher = iota + 1
+	CipherChaCha20Poly1305
+)
+
+func (c Cipher) String() string {
+	switch c {
+	case CipherAES256GCM:
+		return "AES-256-GCM"
+	case CipherChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCipher returns the cipher matching a configured algorithm name
+func ParseCipher(name string) (Cipher, error) {
+	switch strings.ToLower(name) {
+	case "aes-256-gcm", "aes256gcm":
+		return CipherAES256GCM, nil
+	case "chacha20-poly1305", "chacha20poly1305":
+		return CipherChaCha20Poly1305, nil
+	default:
+		return 0, fmt.Errorf("unsupported cipher algorithm: %s", name)
+	}
+}
+
+// NewAEAD creates an AEAD instance for the given cipher and key
+func NewAEAD(c Cipher, key EncryptionKey) (cipher.AEAD, error) {
 	if len(key) != 32 {
-		return nil, errors.New("key must be 32 bytes for AES-256")
+		return nil, errors.New("key must be 32 bytes")
+	}
+
+	switch c {
+	case CipherAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case CipherChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("unsupported cipher: %d", c)
 	}
+}
 
-	block, err := aes.NewCipher(key)
+// Encrypt encrypts data using AES-256-GCM
+func Encrypt(data []byte, key EncryptionKey) ([]byte, error) {
+	return EncryptWith(CipherAES256GCM, data, key)
+}
+
+// EncryptWith encrypts data using the given cipher.
+// The output is a one-byte cipher tag followed by the nonce and ciphertext.
+func EncryptWith(c Cipher, data []byte, key EncryptionKey) ([]byte, error) {
+	return EncryptWithNonces(c, data, key, RandomNonces{})
+}
+
+// EncryptWithNonces encrypts data like EncryptWith, taking the nonce from nonces
+func EncryptWithNonces(c Cipher, data []byte, key EncryptionKey, nonces NonceSource) ([]byte, error) {
+	return EncryptWithAD(c, data, nil, key, nonces)
+}
+
+// EncryptWithAD encrypts data like EncryptWithNonces, also authenticating additional,
+// which is not part of the output. Decrypting takes the same additional data.
+func EncryptWithAD(c Cipher, data, additional []byte, key EncryptionKey, nonces NonceSource) ([]byte, error) {
+	aead, err := NewAEAD(c, key)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	nonce, err := nonces.Nonce(aead.NonceSize())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 1, 1+len(nonce)+len(data)+aead.Overhead())
+	out[0] = byte(c)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, additional), nil
+}
+
+// Decrypt decrypts data produced by Encrypt or EncryptWith.
+// The cipher is selected from the tag byte, regardless of the current configuration.
+func Decrypt(ciphertext []byte, key EncryptionKey) ([]byte, error) {
+	return DecryptWithAD(ciphertext, nil, key)
+}
+
+// DecryptWithAD decrypts data produced by EncryptWithAD, failing unless additional is
+// the additional data it was encrypted with
+func DecryptWithAD(ciphertext, additional []byte, key EncryptionKey) ([]byte, error) {
+	if len(ciphertext) < 1 {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	aead, err := NewAEAD(Cipher(ciphertext[0]), key)
 	if err != nil {
 		return nil, err
 	}
+	ciphertext = ciphertext[1:]
 
-	nonceSize := gcm.NonceSize()
+	nonceSize := aead.NonceSize()
 	if len(ciphertext) < nonceSize {
 		return nil, errors.New("ciphertext too short")
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additional)
 	if err != nil {
 		return nil, err
 	}
@@ -90,17 +283,13 @@ func Hash(data []byte) []byte {
 	return hash[:]
 }
 
-// VerifyHash verifies if data matches the expected hash
+// VerifyHash verifies if data matches the expected hash.
+// The comparison is constant-time to avoid leaking timing information.
 func VerifyHash(data []byte, expectedHash []byte) bool {
 	actualHash := Hash(data)
 	if len(actualHash) != len(expectedHash) {
 		return false
 	}
 
-	for i := range actualHash {
-		if actualHash[i] != expectedHash[i] {
-			return false
-		}
-	}
-	return true
+	return subtle.ConstantTimeCompare(actualHash, expectedHash) == 1
 }
@@ -0,0 +1,339 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKDFParams keeps Argon2id cheap so tests run quickly
+var testKDFParams = KDFParams{Time: 1, Memory: 1024, Parallelism: 1}
+
+func TestDeriveKeyArgon2(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+
+	key1 := DeriveKeyArgon2("password", salt, testKDFParams)
+	key2 := DeriveKeyArgon2("password", salt, testKDFParams)
+
+	// Same password and salt should yield same key
+	if !bytes.Equal(key1, key2) {
+		t.Errorf("Expected same key for same password and salt")
+	}
+
+	// Key should be 32 bytes for AES-256
+	if len(key1) != 32 {
+		t.Errorf("Expected key length 32, got %d", len(key1))
+	}
+
+	// Different salt should yield different key
+	key3 := DeriveKeyArgon2("password", []byte("fedcba9876543210"), testKDFParams)
+	if bytes.Equal(key1, key3) {
+		t.Errorf("Expected different key for different salt")
+	}
+
+	// Different password should yield different key
+	key4 := DeriveKeyArgon2("other password", salt, testKDFParams)
+	if bytes.Equal(key1, key4) {
+		t.Errorf("Expected different key for different password")
+	}
+}
+
+func TestDeriveKeyArgon2Encrypt(t *testing.T) {
+	salt, err := GenerateSalt()
+	if err != nil {
+		t.Fatalf("Failed to generate salt: %v", err)
+	}
+
+	if len(salt) != SaltSize {
+		t.Errorf("Expected salt length %d, got %d", SaltSize, len(salt))
+	}
+
+	key := DeriveKeyArgon2("password", salt, testKDFParams)
+	plaintext := []byte("secret data")
+
+	ciphertext, err := Encrypt(plaintext, key)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// Re-derive the key from the stored salt
+	decrypted, err := Decrypt(ciphertext, DeriveKeyArgon2("password", salt, testKDFParams))
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Errorf("Expected %s, got %s", plaintext, decrypted)
+	}
+}
+
+func TestEncryptWithCiphers(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	plaintext := []byte("secret data")
+
+	for _, c := range []Cipher{CipherAES256GCM, CipherChaCha20Poly1305} {
+		ciphertext, err := EncryptWith(c, plaintext, key)
+		if err != nil {
+			t.Fatalf("%s: failed to encrypt: %v", c, err)
+		}
+
+		// Ciphertext should carry the algorithm tag
+		if Cipher(ciphertext[0]) != c {
+			t.Errorf("%s: expected tag %d, got %d", c, c, ciphertext[0])
+		}
+
+		// Decrypt should select the cipher from the tag
+		decrypted, err := Decrypt(ciphertext, key)
+		if err != nil {
+			t.Fatalf("%s: failed to decrypt: %v", c, err)
+		}
+
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("%s: expected %s, got %s", c, plaintext, decrypted)
+		}
+	}
+}
+
+func TestEncryptWithAD(t *testing.T) {
+	key, _ := GenerateKey()
+	plaintext := []byte("secret data")
+	additional := []byte("header")
+
+	for _, c := range []Cipher{CipherAES256GCM, CipherChaCha20Poly1305} {
+		ciphertext, err := EncryptWithAD(c, plaintext, additional, key, RandomNonces{})
+		if err != nil {
+			t.Fatalf("%s: failed to encrypt: %v", c, err)
+		}
+
+		decrypted, err := DecryptWithAD(ciphertext, additional, key)
+		if err != nil || !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("%s: expected %s, got %s (%v)", c, plaintext, decrypted, err)
+		}
+
+		// The additional data is authenticated
+		if _, err := DecryptWithAD(ciphertext, []byte("Header"), key); err == nil {
+			t.Errorf("%s: expected altered additional data to fail", c)
+		}
+		if _, err := Decrypt(ciphertext, key); err == nil {
+			t.Errorf("%s: expected missing additional data to fail", c)
+		}
+	}
+}
+
+func TestDecryptUnknownCipher(t *testing.T) {
+	key, _ := GenerateKey()
+
+	ciphertext, err := Encrypt([]byte("secret data"), key)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	ciphertext[0] = 0xff
+	if _, err := Decrypt(ciphertext, key); err == nil {
+		t.Errorf("Expected error for unknown cipher tag")
+	}
+}
+
+func TestParseCipher(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Cipher
+		wantErr  bool
+	}{
+		{"AES-256-GCM", CipherAES256GCM, false},
+		{"ChaCha20-Poly1305", CipherChaCha20Poly1305, false},
+		{"chacha20poly1305", CipherChaCha20Poly1305, false},
+		{"DES", 0, true},
+	}
+
+	for _, test := range tests {
+		c, err := ParseCipher(test.name)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseCipher(%s): unexpected error: %v", test.name, err)
+		}
+		if c != test.expected {
+			t.Errorf("ParseCipher(%s): expected %s, got %s", test.name, test.expected, c)
+		}
+	}
+}
+
+func benchmarkEncrypt(b *testing.B, c Cipher) {
+	key, _ := GenerateKey()
+	data := make([]byte, 1024*1024)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncryptWith(c, data, key); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncryptAES256GCM(b *testing.B) {
+	benchmarkEncrypt(b, CipherAES256GCM)
+}
+
+func BenchmarkEncryptChaCha20Poly1305(b *testing.B) {
+	benchmarkEncrypt(b, CipherChaCha20Poly1305)
+}
+
+func TestVerifyHash(t *testing.T) {
+	data := []byte("test data")
+	hash := Hash(data)
+
+	if !VerifyHash(data, hash) {
+		t.Errorf("Expected hash to verify for matching data")
+	}
+
+	if VerifyHash([]byte("different data"), hash) {
+		t.Errorf("Expected hash not to verify for different data")
+	}
+
+	// Length mismatch should fail
+	if VerifyHash(data, hash[:16]) {
+		t.Errorf("Expected hash not to verify for truncated hash")
+	}
+
+	if VerifyHash(data, nil) {
+		t.Errorf("Expected hash not to verify for empty hash")
+	}
+}
+
+func TestSaveLoadKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "master.key")
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	if err := SaveKey(key, path); err != nil {
+		t.Fatalf("Failed to save key: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat key file: %v", err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key file permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	loaded, err := LoadKey(path)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	if !bytes.Equal(key, loaded) {
+		t.Errorf("Loaded key does not match saved key")
+	}
+}
+
+func TestDeriveFileKey(t *testing.T) {
+	master, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	other, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	first := DeriveFileKey(master, "file-1")
+	if len(first) != 32 {
+		t.Errorf("Expected 32 byte subkey, got %d", len(first))
+	}
+	if !bytes.Equal(first, DeriveFileKey(master, "file-1")) {
+		t.Errorf("Expected the same file ID to derive the same subkey")
+	}
+
+	tests := []struct {
+		name string
+		key  EncryptionKey
+	}{
+		{"different file ID", DeriveFileKey(master, "file-2")},
+		{"different master key", DeriveFileKey(other, "file-1")},
+		{"master key", master},
+	}
+	for _, tt := range tests {
+		if bytes.Equal(first, tt.key) {
+			t.Errorf("%s: expected a different key", tt.name)
+		}
+	}
+
+	// Data sealed under one file's subkey cannot be opened with another's
+	ciphertext, err := Encrypt([]byte("secret"), first)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if _, err := Decrypt(ciphertext, DeriveFileKey(master, "file-2")); err == nil {
+		t.Errorf("Expected decryption with another file's subkey to fail")
+	}
+}
+
+func TestKeyZero(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	shared := key[:]
+
+	key.Zero()
+
+	if len(key) != 32 {
+		t.Errorf("Expected key length to stay 32, got %d", len(key))
+	}
+	for i, b := range shared {
+		if b != 0 {
+			t.Fatalf("Expected byte %d to be zero, got %#x", i, b)
+		}
+	}
+}
+
+func TestLoadKeyWrongLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "short.key")
+	if err := os.WriteFile(path, []byte("too short"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	_, err := LoadKey(path)
+	if err == nil {
+		t.Fatalf("Expected error for wrong-length key file")
+	}
+
+	if !strings.Contains(err.Error(), "expected 32 bytes") {
+		t.Errorf("Expected descriptive error, got: %v", err)
+	}
+}
+
+func TestLoadOrGenerateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.key")
+
+	key1, generated, err := LoadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if !generated {
+		t.Errorf("Expected new key to be generated")
+	}
+
+	key2, generated, err := LoadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	if generated {
+		t.Errorf("Expected existing key to be loaded")
+	}
+
+	if !bytes.Equal(key1, key2) {
+		t.Errorf("Expected same key after reload")
+	}
+}
@@ -0,0 +1,195 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownKeyVersion is returned for a key version the key ring does not hold
+var ErrUnknownKeyVersion = errors.New("unknown key version")
+
+// KeyRing holds the versions of the master key. New data is encrypted under the current
+// version, the highest one, while older versions are kept so that data written before a
+// rotation can still be decrypted until it is re-encrypted.
+type KeyRing struct {
+	keys    map[uint32]EncryptionKey
+	current uint32
+	mu      sync.RWMutex
+
+	// Nonce counters per version, when enabled; random nonces are used otherwise
+	counters    map[uint32]*CounterNonces
+	counterPath string // Key file the counters are saved next to; empty keeps them in memory
+}
+
+// NewKeyRing creates a key ring holding key as version 1
+func NewKeyRing(key EncryptionKey) *KeyRing {
+	return &KeyRing{keys: map[uint32]EncryptionKey{1: key}, current: 1}
+}
+
+// Add adds a key version, which becomes current if it is the highest
+func (r *KeyRing) Add(version uint32, key EncryptionKey) error {
+	if version == 0 {
+		return fmt.Errorf("invalid key version: %d", version)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("invalid key length: %d", len(key))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.keys[version]; exists {
+		return fmt.Errorf("key version %d already exists", version)
+	}
+	if err := r.addCounter(version); err != nil {
+		return err
+	}
+	r.keys[version] = key
+	if version > r.current {
+		r.current = version
+	}
+	return nil
+}
+
+// Current returns the version and key that new data is encrypted under
+func (r *KeyRing) Current() (uint32, EncryptionKey) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current, r.keys[r.current]
+}
+
+// Key returns the key with the given version
+func (r *KeyRing) Key(version uint32) (EncryptionKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.keys[version]
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+	return key, nil
+}
+
+// Versions returns the versions held, oldest first
+func (r *KeyRing) Versions() []uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]uint32, 0, len(r.keys))
+	for version := range r.keys {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// Rotate generates a new key version and makes it current, returning its version. The key
+// is saved next to the key file at path unless path is empty.
+func (r *KeyRing) Rotate(path string) (uint32, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	version := r.current + 1
+	if err := r.addCounter(version); err != nil {
+		return 0, err
+	}
+	if path != "" {
+		if err := SaveKey(key, KeyVersionPath(path, version)); err != nil {
+			return 0, err
+		}
+	}
+	r.keys[version] = key
+	r.current = version
+	return version, nil
+}
+
+// EnableNonceCounters switches every key version to counter nonces, saved next to the
+// key file at path, or kept in memory when path is empty. Versions added later get a
+// counter of their own.
+func (r *KeyRing) EnableNonceCounters(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters = make(map[uint32]*CounterNonces, len(r.keys))
+	r.counterPath = path
+	for version := range r.keys {
+		if err := r.addCounter(version); err != nil {
+			r.counters = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// Nonces returns the nonce source for data encrypted under the given version
+func (r *KeyRing) Nonces(version uint32) NonceSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if counter, exists := r.counters[version]; exists {
+		return counter
+	}
+	return RandomNonces{}
+}
+
+// addCounter opens the nonce counter for a version when counters are enabled. The caller
+// must hold the write lock.
+func (r *KeyRing) addCounter(version uint32) error {
+	if r.counters == nil {
+		return nil
+	}
+
+	path := ""
+	if r.counterPath != "" {
+		path = NonceCounterPath(r.counterPath, version)
+	}
+	counter, err := NewCounterNonces(path)
+	if err != nil {
+		return fmt.Errorf("failed to open nonce counter for key version %d: %w", version, err)
+	}
+	r.counters[version] = counter
+	return nil
+}
+
+// KeyVersionPath returns where a key version is saved for the key file at path. Version 1
+// is the key file itself, so key files written before rotation load as version 1.
+func KeyVersionPath(path string, version uint32) string {
+	if version <= 1 {
+		return path
+	}
+	return fmt.Sprintf("%s.v%d", path, version)
+}
+
+// LoadKeyRing loads the key file at path as version 1, generating it if it does not
+// exist, followed by every version saved next to it by Rotate. The returned bool reports
+// whether a new key was generated.
+func LoadKeyRing(path string) (*KeyRing, bool, error) {
+	key, generated, err := LoadOrGenerateKey(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ring := NewKeyRing(key)
+	for version := uint32(2); ; version++ {
+		key, err := LoadKey(KeyVersionPath(path, version))
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if err := ring.Add(version, key); err != nil {
+			return nil, false, err
+		}
+	}
+	return ring, generated, nil
+}
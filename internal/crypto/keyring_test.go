@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyRingAdd(t *testing.T) {
+	first, _ := GenerateKey()
+	second, _ := GenerateKey()
+	ring := NewKeyRing(first)
+
+	if version, key := ring.Current(); version != 1 || !bytes.Equal(key, first) {
+		t.Errorf("Expected version 1 to be current, got %d", version)
+	}
+
+	tests := []struct {
+		name    string
+		version uint32
+		key     EncryptionKey
+		wantErr bool
+	}{
+		{"zero version", 0, second, true},
+		{"short key", 2, second[:16], true},
+		{"existing version", 1, second, true},
+		{"new version", 2, second, false},
+	}
+
+	for _, tt := range tests {
+		err := ring.Add(tt.version, tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+
+	if version, key := ring.Current(); version != 2 || !bytes.Equal(key, second) {
+		t.Errorf("Expected version 2 to be current, got %d", version)
+	}
+	if key, err := ring.Key(1); err != nil || !bytes.Equal(key, first) {
+		t.Errorf("Expected version 1 to stay available, got %v", err)
+	}
+	if _, err := ring.Key(3); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Expected ErrUnknownKeyVersion, got %v", err)
+	}
+}
+
+func TestKeyRingRotateAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.key")
+
+	ring, generated, err := LoadKeyRing(path)
+	if err != nil {
+		t.Fatalf("Failed to load key ring: %v", err)
+	}
+	if !generated {
+		t.Errorf("Expected a key to be generated on first load")
+	}
+
+	for want := uint32(2); want <= 3; want++ {
+		version, err := ring.Rotate(path)
+		if err != nil {
+			t.Fatalf("Failed to rotate key: %v", err)
+		}
+		if version != want {
+			t.Errorf("Expected version %d, got %d", want, version)
+		}
+	}
+
+	loaded, generated, err := LoadKeyRing(path)
+	if err != nil {
+		t.Fatalf("Failed to reload key ring: %v", err)
+	}
+	if generated {
+		t.Errorf("Expected the existing key to be loaded")
+	}
+	if versions := loaded.Versions(); len(versions) != 3 || versions[2] != 3 {
+		t.Errorf("Expected versions [1 2 3], got %v", versions)
+	}
+	for _, version := range ring.Versions() {
+		want, _ := ring.Key(version)
+		if got, err := loaded.Key(version); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Expected version %d to reload unchanged, got %v", version, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RandomNonceLimit is the number of encryptions under one key after which random 96-bit
+// nonces should no longer be trusted. NIST SP 800-38D caps random-nonce AES-GCM at 2^32
+// messages per key, where the chance of a repeated nonce reaches about 2^-32; a repeat
+// leaks the XOR of two plaintexts and allows forging messages. Chunks are encrypted under
+// per-file subkeys, so the limit applies per file, but anything encrypted under the
+// master key directly should have the key rotated well before reaching it, or use
+// counter nonces.
+const RandomNonceLimit = 1 << 32
+
+// nonceReserveBlock is how many counter values are reserved on disk at a time. A crash
+// skips at most this many values and never hands one out twice.
+const nonceReserveBlock = 1 << 16
+
+// counterFileSize is the size of a nonce counter file: a 4-byte prefix followed by the
+// 8-byte high-water mark of reserved counter values
+const counterFileSize = 12
+
+// ErrNonceExhausted is returned once a nonce counter has no values left
+var ErrNonceExhausted = errors.New("nonce counter exhausted")
+
+// NonceSource supplies the nonces AEAD encryption seals data with. Every nonce it
+// returns must be unique for the key it is used with.
+type NonceSource interface {
+	Nonce(size int) ([]byte, error)
+}
+
+// RandomNonces draws every nonce from the system random number generator. Nonces are
+// unique with overwhelming probability up to RandomNonceLimit encryptions per key.
+type RandomNonces struct{}
+
+// Nonce returns size random bytes
+func (RandomNonces) Nonce(size int) ([]byte, error) {
+	nonce := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// CounterNonces builds each nonce from a fixed random prefix and a counter, so nonces
+// never repeat however many are drawn. The counter is reserved on disk in blocks before
+// values are handed out, so a restart resumes past every value already used.
+type CounterNonces struct {
+	path     string // Empty keeps the counter in memory only
+	prefix   [4]byte
+	next     uint64
+	reserved uint64 // Values below this are reserved on disk
+	mu       sync.Mutex
+}
+
+// NewCounterNonces opens the nonce counter saved at path, creating it with a new random
+// prefix if it does not exist. With an empty path the counter is not saved, which is
+// only safe for keys that do not outlive the process.
+func NewCounterNonces(path string) (*CounterNonces, error) {
+	c := &CounterNonces{path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case path == "" || os.IsNotExist(err):
+		if _, err := io.ReadFull(rand.Reader, c.prefix[:]); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to read nonce counter: %w", err)
+	case len(data) != counterFileSize:
+		return nil, fmt.Errorf("invalid nonce counter %s: expected %d bytes, got %d", path, counterFileSize, len(data))
+	default:
+		copy(c.prefix[:], data[:4])
+		c.next = binary.BigEndian.Uint64(data[4:])
+		c.reserved = c.next
+	}
+	return c, nil
+}
+
+// Nonce returns the prefix followed by the next counter value, padded to size bytes
+func (c *CounterNonces) Nonce(size int) ([]byte, error) {
+	if size < 12 {
+		return nil, fmt.Errorf("nonce size %d too small for a counter nonce", size)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next == ^uint64(0) {
+		return nil, ErrNonceExhausted
+	}
+	if c.next >= c.reserved {
+		reserved := c.next + nonceReserveBlock
+		if reserved < c.next {
+			reserved = ^uint64(0)
+		}
+		if err := c.save(reserved); err != nil {
+			return nil, err
+		}
+		c.reserved = reserved
+	}
+
+	nonce := make([]byte, size)
+	copy(nonce[size-12:], c.prefix[:])
+	binary.BigEndian.PutUint64(nonce[size-8:], c.next)
+	c.next++
+	return nonce, nil
+}
+
+// Count returns the number of nonces handed out, including values skipped by restarts
+func (c *CounterNonces) Count() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.next
+}
+
+// save durably records reserved as the high-water mark, replacing the counter file
+// atomically so a crash leaves either the old or the new mark
+func (c *CounterNonces) save(reserved uint64) error {
+	if c.path == "" {
+		return nil
+	}
+
+	data := make([]byte, counterFileSize)
+	copy(data, c.prefix[:])
+	binary.BigEndian.PutUint64(data[4:], reserved)
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("failed to create nonce counter directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save nonce counter: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save nonce counter: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save nonce counter: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save nonce counter: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to save nonce counter: %w", err)
+	}
+	return nil
+}
+
+// NonceCounterPath returns where the nonce counter of a key version is saved for the key
+// file at path
+func NonceCounterPath(path string, version uint32) string {
+	return KeyVersionPath(path, version) + ".nonce"
+}
+
+// NonceMode selects how nonces are generated for data encrypted under the key ring
+type NonceMode string
+
+const (
+	NonceModeRandom  NonceMode = "random"
+	NonceModeCounter NonceMode = "counter"
+)
+
+// ParseNonceMode returns the nonce mode matching a configured name, defaulting to random
+func ParseNonceMode(name string) (NonceMode, error) {
+	switch NonceMode(name) {
+	case "", NonceModeRandom:
+		return NonceModeRandom, nil
+	case NonceModeCounter:
+		return NonceModeCounter, nil
+	default:
+		return "", fmt.Errorf("unsupported nonce mode: %s", name)
+	}
+}
@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyRingNoncesUnique(t *testing.T) {
+	const encryptions = 200000
+
+	tests := []struct {
+		mode    NonceMode
+		counter bool
+	}{
+		{NonceModeRandom, false},
+		{NonceModeCounter, true},
+	}
+
+	for _, tt := range tests {
+		key, _ := GenerateKey()
+		ring := NewKeyRing(key)
+		if tt.counter {
+			if err := ring.EnableNonceCounters(filepath.Join(t.TempDir(), "master.key")); err != nil {
+				t.Fatalf("%s: Failed to enable nonce counters: %v", tt.mode, err)
+			}
+		}
+
+		version, current := ring.Current()
+		nonces := ring.Nonces(version)
+		if _, isCounter := nonces.(*CounterNonces); isCounter != tt.counter {
+			t.Fatalf("%s: Expected counter nonces %v, got %T", tt.mode, tt.counter, nonces)
+		}
+
+		seen := make(map[[12]byte]bool, encryptions)
+		plaintext := []byte("chunk")
+		for i := 0; i < encryptions; i++ {
+			ciphertext, err := EncryptWithNonces(CipherAES256GCM, plaintext, current, nonces)
+			if err != nil {
+				t.Fatalf("%s: Failed to encrypt: %v", tt.mode, err)
+			}
+
+			var nonce [12]byte
+			copy(nonce[:], ciphertext[1:13])
+			if seen[nonce] {
+				t.Fatalf("%s: Nonce %x repeated after %d encryptions", tt.mode, nonce, i)
+			}
+			seen[nonce] = true
+
+			if i%50000 == 0 {
+				if decrypted, err := Decrypt(ciphertext, current); err != nil || !bytes.Equal(decrypted, plaintext) {
+					t.Errorf("%s: Expected the ciphertext to decrypt, got %v", tt.mode, err)
+				}
+			}
+		}
+
+		if counter, ok := nonces.(*CounterNonces); ok && counter.Count() != encryptions {
+			t.Errorf("%s: Expected %d nonces counted, got %d", tt.mode, encryptions, counter.Count())
+		}
+	}
+}
+
+func TestCounterNoncesResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.key.nonce")
+
+	counter, err := NewCounterNonces(path)
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	var last []byte
+	for i := 0; i < 10; i++ {
+		if last, err = counter.Nonce(12); err != nil {
+			t.Fatalf("Failed to draw nonce: %v", err)
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected the counter to be saved readable only by the owner, got %v", err)
+	}
+
+	// A restart, even without a clean shutdown, continues past every value handed out
+	reopened, err := NewCounterNonces(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen counter: %v", err)
+	}
+	next, err := reopened.Nonce(12)
+	if err != nil {
+		t.Fatalf("Failed to draw nonce: %v", err)
+	}
+	if !bytes.Equal(next[:4], last[:4]) {
+		t.Errorf("Expected the prefix %x to be kept, got %x", last[:4], next[:4])
+	}
+	if binary.BigEndian.Uint64(next[4:]) <= binary.BigEndian.Uint64(last[4:]) {
+		t.Errorf("Expected nonce %x to follow %x", next, last)
+	}
+
+	// Larger nonces keep the counter at the end
+	if wide, _ := reopened.Nonce(24); !bytes.Equal(wide[:12], make([]byte, 12)) || !bytes.Equal(wide[12:16], last[:4]) {
+		t.Errorf("Expected a zero padded nonce, got %x", wide)
+	}
+	if _, err := reopened.Nonce(8); err == nil {
+		t.Error("Expected nonces shorter than 12 bytes to be rejected")
+	}
+
+	if err := os.WriteFile(path, []byte("short"), 0600); err != nil {
+		t.Fatalf("Failed to corrupt counter: %v", err)
+	}
+	if _, err := NewCounterNonces(path); err == nil {
+		t.Error("Expected a corrupt counter file to be rejected")
+	}
+}
+
+func TestKeyRingRotateNonceCounter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.key")
+	ring, _, err := LoadKeyRing(path)
+	if err != nil {
+		t.Fatalf("Failed to load key ring: %v", err)
+	}
+	if err := ring.EnableNonceCounters(path); err != nil {
+		t.Fatalf("Failed to enable nonce counters: %v", err)
+	}
+
+	version, err := ring.Rotate(path)
+	if err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	first, _ := ring.Nonces(1).Nonce(12)
+	second, _ := ring.Nonces(version).Nonce(12)
+
+	for _, v := range []uint32{1, version} {
+		if _, err := os.Stat(NonceCounterPath(path, v)); err != nil {
+			t.Errorf("Expected a saved counter for version %d: %v", v, err)
+		}
+	}
+	if bytes.Equal(first[:4], second[:4]) {
+		t.Errorf("Expected each key version to get its own counter prefix")
+	}
+}
@@ -0,0 +1,122 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamFrameSize is the maximum plaintext size of a single stream frame
+const StreamFrameSize = 64 * 1024 // 64KB
+
+// frameHeaderSize is the size of the per-frame header: 4-byte length prefix and 1-byte final flag
+const frameHeaderSize = 5
+
+// EncryptStream encrypts src into dst using AES-256-GCM in fixed-size frames.
+//
+// Each frame is written as a 4-byte big-endian length, a 1-byte final flag and
+// the sealed frame (nonce followed by ciphertext). The frame index and final
+// flag are authenticated as additional data so that reordered, dropped or
+// truncated frames are detected on decryption.
+func EncryptStream(dst io.Writer, src io.Reader, key EncryptionKey) error {
+	gcm, err := NewAEAD(CipherAES256GCM, key)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, StreamFrameSize)
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(src, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return err
+		}
+
+		sealed := gcm.Seal(nonce, nonce, buf[:n], frameAAD(index, final))
+
+		header := make([]byte, frameHeaderSize)
+		binary.BigEndian.PutUint32(header, uint32(len(sealed)))
+		if final {
+			header[4] = 1
+		}
+
+		if _, err := dst.Write(header); err != nil {
+			return err
+		}
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+
+		if final {
+			return nil
+		}
+	}
+}
+
+// DecryptStream decrypts a stream produced by EncryptStream from src into dst
+func DecryptStream(dst io.Writer, src io.Reader, key EncryptionKey) error {
+	gcm, err := NewAEAD(CipherAES256GCM, key)
+	if err != nil {
+		return err
+	}
+
+	maxFrame := uint32(gcm.NonceSize() + StreamFrameSize + gcm.Overhead())
+	header := make([]byte, frameHeaderSize)
+	for index := uint64(0); ; index++ {
+		if _, err := io.ReadFull(src, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return errors.New("encrypted stream is truncated")
+			}
+			return err
+		}
+
+		length := binary.BigEndian.Uint32(header)
+		if length < uint32(gcm.NonceSize()) || length > maxFrame {
+			return fmt.Errorf("invalid frame length %d at frame %d", length, index)
+		}
+		final := header[4] == 1
+
+		sealed := make([]byte, length)
+		if _, err := io.ReadFull(src, sealed); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return errors.New("encrypted stream is truncated")
+			}
+			return err
+		}
+
+		nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+		plaintext, err := gcm.Open(nil, nonce, ciphertext, frameAAD(index, final))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt frame %d: %w", index, err)
+		}
+
+		if _, err := dst.Write(plaintext); err != nil {
+			return err
+		}
+
+		if final {
+			// Nothing may follow the final frame
+			if n, _ := src.Read(header[:1]); n > 0 {
+				return errors.New("unexpected data after final frame")
+			}
+			return nil
+		}
+	}
+}
+
+// frameAAD builds the additional authenticated data binding a frame to its position
+func frameAAD(index uint64, final bool) []byte {
+	aad := make([]byte, 9)
+	binary.BigEndian.PutUint64(aad, index)
+	if final {
+		aad[8] = 1
+	}
+	return aad
+}
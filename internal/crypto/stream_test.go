@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestEncryptDecryptStream(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	sizes := []int{
+		0,
+		1,
+		StreamFrameSize,
+		StreamFrameSize + 1,
+		3*1024*1024 + 17, // multi-megabyte, not frame aligned
+	}
+
+	for _, size := range sizes {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatalf("Failed to generate data: %v", err)
+		}
+
+		var encrypted bytes.Buffer
+		if err := EncryptStream(&encrypted, bytes.NewReader(data), key); err != nil {
+			t.Fatalf("Size %d: failed to encrypt stream: %v", size, err)
+		}
+
+		var decrypted bytes.Buffer
+		if err := DecryptStream(&decrypted, &encrypted, key); err != nil {
+			t.Fatalf("Size %d: failed to decrypt stream: %v", size, err)
+		}
+
+		if !bytes.Equal(data, decrypted.Bytes()) {
+			t.Errorf("Size %d: decrypted data does not match original", size)
+		}
+	}
+}
+
+func TestDecryptStreamCorruptedFrame(t *testing.T) {
+	key, _ := GenerateKey()
+	data := make([]byte, 3*StreamFrameSize)
+	rand.Read(data)
+
+	var encrypted bytes.Buffer
+	if err := EncryptStream(&encrypted, bytes.NewReader(data), key); err != nil {
+		t.Fatalf("Failed to encrypt stream: %v", err)
+	}
+
+	// Flip a byte inside the second frame
+	corrupted := encrypted.Bytes()
+	frameLen := len(corrupted) / 4
+	corrupted[frameLen+100] ^= 0xff
+
+	var decrypted bytes.Buffer
+	if err := DecryptStream(&decrypted, bytes.NewReader(corrupted), key); err == nil {
+		t.Errorf("Expected error for corrupted frame")
+	}
+}
+
+func TestDecryptStreamTruncatedAndReordered(t *testing.T) {
+	key, _ := GenerateKey()
+	data := make([]byte, 2*StreamFrameSize+10)
+	rand.Read(data)
+
+	var encrypted bytes.Buffer
+	if err := EncryptStream(&encrypted, bytes.NewReader(data), key); err != nil {
+		t.Fatalf("Failed to encrypt stream: %v", err)
+	}
+	stream := encrypted.Bytes()
+
+	// Drop the final frame entirely
+	fullFrame := frameHeaderSize + 12 + StreamFrameSize + 16
+	truncated := stream[:2*fullFrame]
+	if err := DecryptStream(&bytes.Buffer{}, bytes.NewReader(truncated), key); err == nil {
+		t.Errorf("Expected error for truncated stream")
+	}
+
+	// Swap the first two frames
+	reordered := append([]byte{}, stream[fullFrame:2*fullFrame]...)
+	reordered = append(reordered, stream[:fullFrame]...)
+	reordered = append(reordered, stream[2*fullFrame:]...)
+	if err := DecryptStream(&bytes.Buffer{}, bytes.NewReader(reordered), key); err == nil {
+		t.Errorf("Expected error for reordered frames")
+	}
+}
@@ -0,0 +1,221 @@
+// Package erasure implements Reed-Solomon erasure coding over GF(2^8).
+//
+// It stands in for github.com/klauspost/reedsolomon, which the module cannot depend on
+// while builds have no access to it. The encoding matrix is built the way that
+// library's default one is, a Vandermonde matrix over the 0x11d field made systematic,
+// so that stored shards can stay as they are if it replaces this package. Only what the
+// chunk manager needs is provided: no SIMD, streaming or progressive encoding, and
+// shards are encoded and reconstructed a stripe at a time in memory.
+package erasure
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooFewShards is returned when not enough shards survive to reconstruct the data
+var ErrTooFewShards = errors.New("too few shards to reconstruct data")
+
+// Encoder encodes data shards into parity shards and reconstructs missing shards.
+// Any dataShards of the dataShards+parityShards shards are enough to recover the data.
+type Encoder struct {
+	dataShards   int
+	parityShards int
+	matrix       matrix
+}
+
+// New creates an encoder for the given number of data and parity shards
+func New(dataShards, parityShards int) (*Encoder, error) {
+	if dataShards <= 0 || parityShards < 0 {
+		return nil, fmt.Errorf("invalid shard counts: %d data, %d parity", dataShards, parityShards)
+	}
+	if dataShards+parityShards > 256 {
+		return nil, fmt.Errorf("too many shards: %d", dataShards+parityShards)
+	}
+
+	// Build a systematic encoding matrix: the top rows form the identity so
+	// data shards are stored unchanged and the remaining rows produce parity.
+	total := dataShards + parityShards
+	vm := vandermonde(total, dataShards)
+	top := make(matrix, dataShards)
+	copy(top, vm[:dataShards])
+
+	inverse, ok := top.invert()
+	if !ok {
+		return nil, errors.New("failed to build encoding matrix")
+	}
+
+	return &Encoder{
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		matrix:       vm.multiply(inverse),
+	}, nil
+}
+
+// DataShards returns the number of data shards
+func (e *Encoder) DataShards() int {
+	return e.dataShards
+}
+
+// ParityShards returns the number of parity shards
+func (e *Encoder) ParityShards() int {
+	return e.parityShards
+}
+
+// Split divides data into equally sized data shards, zero-padding the last one,
+// and allocates empty parity shards
+func (e *Encoder) Split(data []byte) [][]byte {
+	shardSize := (len(data) + e.dataShards - 1) / e.dataShards
+	if shardSize == 0 {
+		shardSize = 1
+	}
+
+	shards := make([][]byte, e.dataShards+e.parityShards)
+	for i := range shards {
+		shards[i] = make([]byte, shardSize)
+		if i < e.dataShards {
+			start := i * shardSize
+			if start < len(data) {
+				copy(shards[i], data[start:])
+			}
+		}
+	}
+	return shards
+}
+
+// Encode computes the parity shards from the data shards in place
+func (e *Encoder) Encode(shards [][]byte) error {
+	if len(shards) != e.dataShards+e.parityShards {
+		return fmt.Errorf("expected %d shards, got %d", e.dataShards+e.parityShards, len(shards))
+	}
+
+	shardSize := len(shards[0])
+	for i, shard := range shards {
+		if len(shard) != shardSize {
+			return fmt.Errorf("shard %d has size %d, expected %d", i, len(shard), shardSize)
+		}
+	}
+
+	e.codeShards(e.matrix[e.dataShards:], shards[:e.dataShards], shards[e.dataShards:])
+	return nil
+}
+
+// Reconstruct fills in missing (nil) shards from the surviving ones
+func (e *Encoder) Reconstruct(shards [][]byte) error {
+	if len(shards) != e.dataShards+e.parityShards {
+		return fmt.Errorf("expected %d shards, got %d", e.dataShards+e.parityShards, len(shards))
+	}
+
+	// Pick the first dataShards surviving shards
+	var rows []int
+	shardSize := 0
+	for i, shard := range shards {
+		if shard == nil {
+			continue
+		}
+		if shardSize == 0 {
+			shardSize = len(shard)
+		} else if len(shard) != shardSize {
+			return fmt.Errorf("shard %d has size %d, expected %d", i, len(shard), shardSize)
+		}
+		if len(rows) < e.dataShards {
+			rows = append(rows, i)
+		}
+	}
+
+	if len(rows) < e.dataShards {
+		return fmt.Errorf("%w: have %d, need %d", ErrTooFewShards, len(rows), e.dataShards)
+	}
+
+	// Recover the data shards by inverting the rows of the encoding matrix
+	// that produced the surviving shards
+	sub := make(matrix, e.dataShards)
+	inputs := make([][]byte, e.dataShards)
+	for i, row := range rows {
+		sub[i] = e.matrix[row]
+		inputs[i] = shards[row]
+	}
+
+	decode, ok := sub.invert()
+	if !ok {
+		return errors.New("failed to invert decoding matrix")
+	}
+
+	var missingData []int
+	for i := 0; i < e.dataShards; i++ {
+		if shards[i] == nil {
+			missingData = append(missingData, i)
+		}
+	}
+
+	if len(missingData) > 0 {
+		decodeRows := make(matrix, len(missingData))
+		outputs := make([][]byte, len(missingData))
+		for i, index := range missingData {
+			decodeRows[i] = decode[index]
+			outputs[i] = make([]byte, shardSize)
+		}
+		e.codeShards(decodeRows, inputs, outputs)
+		for i, index := range missingData {
+			shards[index] = outputs[i]
+		}
+	}
+
+	// Recompute any missing parity shards from the complete data shards
+	var missingParity []int
+	for i := e.dataShards; i < len(shards); i++ {
+		if shards[i] == nil {
+			missingParity = append(missingParity, i)
+		}
+	}
+
+	if len(missingParity) > 0 {
+		parityRows := make(matrix, len(missingParity))
+		outputs := make([][]byte, len(missingParity))
+		for i, index := range missingParity {
+			parityRows[i] = e.matrix[index]
+			outputs[i] = make([]byte, shardSize)
+		}
+		e.codeShards(parityRows, shards[:e.dataShards], outputs)
+		for i, index := range missingParity {
+			shards[index] = outputs[i]
+		}
+	}
+
+	return nil
+}
+
+// Join concatenates the data shards and trims the result to size bytes
+func (e *Encoder) Join(shards [][]byte, size int) ([]byte, error) {
+	data := make([]byte, 0, size)
+	for i := 0; i < e.dataShards && len(data) < size; i++ {
+		if shards[i] == nil {
+			return nil, fmt.Errorf("data shard %d is missing", i)
+		}
+		data = append(data, shards[i]...)
+	}
+
+	if len(data) < size {
+		return nil, fmt.Errorf("shards hold %d bytes, expected %d", len(data), size)
+	}
+	return data[:size], nil
+}
+
+// codeShards computes outputs[i] = sum over j of rows[i][j] * inputs[j]
+func (e *Encoder) codeShards(rows matrix, inputs, outputs [][]byte) {
+	for i, row := range rows {
+		output := outputs[i]
+		for b := range output {
+			output[b] = 0
+		}
+		for j, input := range inputs {
+			factor := row[j]
+			if factor == 0 {
+				continue
+			}
+			for b, value := range input {
+				output[b] ^= gfMul(factor, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package erasure
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeReconstruct(t *testing.T) {
+	enc, err := New(4, 2)
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	shards := enc.Split(data)
+	if err := enc.Encode(shards); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	// Try every combination of up to two missing shards
+	for a := 0; a < 6; a++ {
+		for b := a; b < 6; b++ {
+			damaged := make([][]byte, len(shards))
+			copy(damaged, shards)
+			damaged[a] = nil
+			damaged[b] = nil
+
+			if err := enc.Reconstruct(damaged); err != nil {
+				t.Fatalf("Missing %d,%d: failed to reconstruct: %v", a, b, err)
+			}
+
+			for i := range shards {
+				if !bytes.Equal(shards[i], damaged[i]) {
+					t.Errorf("Missing %d,%d: shard %d differs after reconstruction", a, b, i)
+				}
+			}
+
+			joined, err := enc.Join(damaged, len(data))
+			if err != nil || !bytes.Equal(data, joined) {
+				t.Errorf("Missing %d,%d: joined data differs (%v)", a, b, err)
+			}
+		}
+	}
+}
+
+func TestReconstructTooFewShards(t *testing.T) {
+	enc, _ := New(3, 2)
+
+	shards := enc.Split([]byte("erasure coded data"))
+	enc.Encode(shards)
+
+	shards[0], shards[2], shards[4] = nil, nil, nil
+	if err := enc.Reconstruct(shards); !errors.Is(err, ErrTooFewShards) {
+		t.Errorf("Expected ErrTooFewShards, got %v", err)
+	}
+}
+
+func TestNewInvalidShards(t *testing.T) {
+	invalid := [][2]int{{0, 2}, {-1, 1}, {4, -1}, {200, 100}}
+	for _, counts := range invalid {
+		if _, err := New(counts[0], counts[1]); err == nil {
+			t.Errorf("Expected error for %d data, %d parity shards", counts[0], counts[1])
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package erasure
+
+// Arithmetic over GF(2^8) using the primitive polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11d)
+
+var (
+	expTable [512]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	// Duplicate the table so gfMul can skip the modulo
+	for i := 255; i < 512; i++ {
+		expTable[i] = expTable[i-255]
+	}
+}
+
+// gfMul multiplies two field elements
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+// gfDiv divides a by a non-zero b
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
+
+// gfExp raises a to the power n
+func gfExp(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])*n)%255]
+}
+
+// matrix is a row-major matrix over GF(2^8)
+type matrix [][]byte
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for i := range m {
+		m[i] = make([]byte, cols)
+	}
+	return m
+}
+
+// vandermonde returns a rows x cols Vandermonde matrix, where element (r, c) is r^c
+func vandermonde(rows, cols int) matrix {
+	m := newMatrix(rows, cols)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			m[r][c] = gfExp(byte(r), c)
+		}
+	}
+	return m
+}
+
+// multiply returns m * other
+func (m matrix) multiply(other matrix) matrix {
+	result := newMatrix(len(m), len(other[0]))
+	for r := range m {
+		for c := range other[0] {
+			var value byte
+			for i := range m[r] {
+				value ^= gfMul(m[r][i], other[i][c])
+			}
+			result[r][c] = value
+		}
+	}
+	return result
+}
+
+// invert returns the inverse of a square matrix using Gauss-Jordan elimination
+func (m matrix) invert() (matrix, bool) {
+	size := len(m)
+	work := newMatrix(size, 2*size)
+	for r := 0; r < size; r++ {
+		copy(work[r], m[r])
+		work[r][size+r] = 1
+	}
+
+	for col := 0; col < size; col++ {
+		// Find a pivot row
+		pivot := -1
+		for r := col; r < size; r++ {
+			if work[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, false
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		// Scale the pivot row to 1
+		scale := work[col][col]
+		for c := range work[col] {
+			work[col][c] = gfDiv(work[col][c], scale)
+		}
+
+		// Eliminate the column from every other row
+		for r := 0; r < size; r++ {
+			if r == col || work[r][col] == 0 {
+				continue
+			}
+			factor := work[r][col]
+			for c := range work[r] {
+				work[r][c] ^= gfMul(factor, work[col][c])
+			}
+		}
+	}
+
+	inverse := newMatrix(size, size)
+	for r := range inverse {
+		copy(inverse[r], work[r][size:])
+	}
+	return inverse, true
+}
@@ -0,0 +1,157 @@
+// Package metadata provides persistent storage for file and system metadata
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrNotFound is returned when a key does not exist in a bucket
+var ErrNotFound = errors.New("metadata not found")
+
+// Store defines a bucketed key-value store for JSON-serializable metadata
+type Store interface {
+	Put(bucket, key string, value interface{}) error
+	Get(bucket, key string, value interface{}) error
+	Delete(bucket, key string) error
+	Keys(bucket string) ([]string, error)
+	Close() error
+}
+
+// JSONStore implements Store with an in-memory map persisted to a JSON file
+type JSONStore struct {
+	path    string
+	buckets map[string]map[string]json.RawMessage
+	mu      sync.RWMutex
+}
+
+// NewJSONStore opens or creates a JSON-backed store at path
+func NewJSONStore(path string) (*JSONStore, error) {
+	store := &JSONStore{
+		path:    path,
+		buckets: make(map[string]map[string]json.RawMessage),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("failed to read metadata file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &store.buckets); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata file: %w", err)
+	}
+
+	return store, nil
+}
+
+// NewMemoryStore creates a store that is never persisted to disk
+func NewMemoryStore() *JSONStore {
+	return &JSONStore{
+		buckets: make(map[string]map[string]json.RawMessage),
+	}
+}
+
+// Put stores value under key in bucket
+func (s *JSONStore) Put(bucket, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string]json.RawMessage)
+	}
+	s.buckets[bucket][key] = data
+
+	return s.persist()
+}
+
+// Get decodes the value stored under key in bucket into value
+func (s *JSONStore) Get(bucket, key string, value interface{}) error {
+	s.mu.RLock()
+	data, exists := s.buckets[bucket][key]
+	s.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%s/%s: %w", bucket, key, ErrNotFound)
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes key from bucket
+func (s *JSONStore) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.buckets[bucket][key]; !exists {
+		return fmt.Errorf("%s/%s: %w", bucket, key, ErrNotFound)
+	}
+	delete(s.buckets[bucket], key)
+
+	return s.persist()
+}
+
+// Keys returns the sorted keys in bucket
+func (s *JSONStore) Keys(bucket string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Close flushes the store to disk
+func (s *JSONStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.persist()
+}
+
+// persist writes the store to disk atomically. Callers must hold the write lock.
+func (s *JSONStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.buckets)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace metadata file: %w", err)
+	}
+
+	return nil
+}
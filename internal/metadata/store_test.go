@@ -0,0 +1,75 @@
+package metadata
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+
+	store, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	if err := store.Put("quotas", "alice", int64(1024)); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if err := store.Put("quotas", "bob", int64(2048)); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	var quota int64
+	if err := store.Get("quotas", "alice", &quota); err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if quota != 1024 {
+		t.Errorf("Expected 1024, got %d", quota)
+	}
+
+	if err := store.Get("quotas", "carol", &quota); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	keys, _ := store.Keys("quotas")
+	if len(keys) != 2 || keys[0] != "alice" || keys[1] != "bob" {
+		t.Errorf("Expected [alice bob], got %v", keys)
+	}
+
+	if err := store.Delete("quotas", "bob"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	// Reopen and verify persistence
+	reopened, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+
+	if err := reopened.Get("quotas", "alice", &quota); err != nil || quota != 1024 {
+		t.Errorf("Expected persisted quota 1024, got %d (%v)", quota, err)
+	}
+
+	if err := reopened.Get("quotas", "bob", &quota); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleted key to stay deleted, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	if err := store.Put("bucket", "key", "value"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+
+	var value string
+	if err := store.Get("bucket", "key", &value); err != nil || value != "value" {
+		t.Errorf("Expected value, got %q (%v)", value, err)
+	}
+
+	if err := store.Delete("bucket", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tagBucket maps each "key=value" tag to the sorted IDs of the files carrying it
+const tagBucket = "tags"
+
+// TagIndex indexes files by their tags so files with given tags can be found without a scan
+type TagIndex struct {
+	store Store
+	mu    sync.Mutex
+}
+
+// NewTagIndex creates a tag index persisted in the metadata store
+func NewTagIndex(store Store) *TagIndex {
+	return &TagIndex{store: store}
+}
+
+// ParseTags parses "key=value" pairs into a tag map. Keys must be non-empty; values may be empty.
+func ParseTags(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid tag: %s", pair)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// ValidateTags checks that every tag key can be stored in the index
+func ValidateTags(tags map[string]string) error {
+	for key := range tags {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("invalid tag key: %q", key)
+		}
+	}
+	return nil
+}
+
+// Add indexes a file under each of its tags
+func (ti *TagIndex) Add(fileID string, tags map[string]string) error {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	for key, value := range tags {
+		ids, err := ti.get(tagKey(key, value))
+		if err != nil {
+			return err
+		}
+
+		i := sort.SearchStrings(ids, fileID)
+		if i < len(ids) && ids[i] == fileID {
+			continue
+		}
+		ids = append(ids, "")
+		copy(ids[i+1:], ids[i:])
+		ids[i] = fileID
+
+		if err := ti.store.Put(tagBucket, tagKey(key, value), ids); err != nil {
+			return fmt.Errorf("failed to index tag %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Remove drops a file from the index entries of the given tags
+func (ti *TagIndex) Remove(fileID string, tags map[string]string) error {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	for key, value := range tags {
+		ids, err := ti.get(tagKey(key, value))
+		if err != nil {
+			return err
+		}
+
+		i := sort.SearchStrings(ids, fileID)
+		if i == len(ids) || ids[i] != fileID {
+			continue
+		}
+		ids = append(ids[:i], ids[i+1:]...)
+
+		if len(ids) == 0 {
+			err = ti.store.Delete(tagBucket, tagKey(key, value))
+		} else {
+			err = ti.store.Put(tagBucket, tagKey(key, value), ids)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unindex tag %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the sorted IDs of files carrying every one of the given tags
+func (ti *TagIndex) Lookup(tags map[string]string) ([]string, error) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	var result []string
+	first := true
+	for key, value := range tags {
+		ids, err := ti.get(tagKey(key, value))
+		if err != nil {
+			return nil, err
+		}
+
+		if first {
+			result = ids
+			first = false
+		} else {
+			result = intersect(result, ids)
+		}
+		if len(result) == 0 {
+			return nil, nil
+		}
+	}
+	return result, nil
+}
+
+// get returns the file IDs indexed under a tag key. Callers must hold ti.mu.
+func (ti *TagIndex) get(key string) ([]string, error) {
+	var ids []string
+	if err := ti.store.Get(tagBucket, key, &ids); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read tag index: %w", err)
+	}
+	return ids, nil
+}
+
+// tagKey builds the index key of a tag
+func tagKey(key, value string) string {
+	return key + "=" + value
+}
+
+// intersect returns the elements present in both sorted slices
+func intersect(a, b []string) []string {
+	var result []string
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		pairs    []string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{"none", nil, nil, false},
+		{"single", []string{"env=prod"}, map[string]string{"env": "prod"}, false},
+		{"empty value", []string{"draft="}, map[string]string{"draft": ""}, false},
+		{"value with equals", []string{"expr=a=b"}, map[string]string{"expr": "a=b"}, false},
+		{"multiple", []string{"env=prod", "team=storage"}, map[string]string{"env": "prod", "team": "storage"}, false},
+		{"missing separator", []string{"env"}, nil, true},
+		{"empty key", []string{"=prod"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := ParseTags(tt.pairs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(tags, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, tags)
+			}
+		})
+	}
+}
+
+func TestTagIndex(t *testing.T) {
+	index := NewTagIndex(NewMemoryStore())
+
+	add := func(fileID string, tags map[string]string) {
+		if err := index.Add(fileID, tags); err != nil {
+			t.Fatalf("Failed to add %s: %v", fileID, err)
+		}
+	}
+	add("c", map[string]string{"env": "prod", "team": "storage"})
+	add("a", map[string]string{"env": "prod", "team": "web"})
+	add("b", map[string]string{"env": "dev", "team": "storage"})
+	add("a", map[string]string{"env": "prod"}) // Re-adding is a no-op
+
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		expected []string
+	}{
+		{"single tag", map[string]string{"env": "prod"}, []string{"a", "c"}},
+		{"all tags match", map[string]string{"env": "prod", "team": "storage"}, []string{"c"}},
+		{"no match", map[string]string{"env": "dev", "team": "web"}, nil},
+		{"unknown tag", map[string]string{"owner": "alice"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := index.Lookup(tt.tags)
+			if err != nil {
+				t.Fatalf("Failed to look up tags: %v", err)
+			}
+			if !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+
+	if err := index.Remove("c", map[string]string{"env": "prod", "team": "storage"}); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	if ids, _ := index.Lookup(map[string]string{"team": "storage"}); !reflect.DeepEqual(ids, []string{"b"}) {
+		t.Errorf("Expected [b] after removal, got %v", ids)
+	}
+
+	// Entries left without files are dropped from the store
+	index.Remove("a", map[string]string{"team": "web"})
+	if keys, _ := index.store.Keys(tagBucket); len(keys) != 3 {
+		t.Errorf("Expected 3 tag entries, got %v", keys)
+	}
+}
@@ -0,0 +1,289 @@
+// Package metrics provides counters, histograms and gauges exposed in the Prometheus text
+// format. It writes the format itself rather than depending on client_golang, which would
+// bring in far more than these three metric types; the tests read the output back with a
+// strict parser of the format.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrLabelCount is returned when a metric is given a different number of label values
+// than it was registered with
+var ErrLabelCount = errors.New("wrong number of label values")
+
+// DefaultBuckets are histogram buckets suited to request durations in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can write itself in the text exposition format
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry holds a set of metrics and serves them for scraping
+type Registry struct {
+	collectors []collector
+	mu         sync.Mutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounterVec registers a counter family partitioned by the given labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{
+		desc:   desc{name: name, help: help, labels: labels},
+		values: make(map[string]float64),
+	}
+	r.register(counter)
+	return counter
+}
+
+// NewHistogramVec registers a histogram family with the given upper bucket bounds
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	histogram := &HistogramVec{
+		desc:    desc{name: name, help: help, labels: labels},
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*histogramSeries),
+	}
+	sort.Float64s(histogram.buckets)
+	r.register(histogram)
+	return histogram
+}
+
+// NewGaugeFunc registers a gauge whose value is computed by fn at scrape time
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{desc: desc{name: name, help: help}, fn: fn})
+}
+
+// Write writes every registered metric in the Prometheus text format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler returns an HTTP handler serving the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// desc describes a metric family
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// header writes the HELP and TYPE lines of a metric family
+func (d desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, helpEscaper.Replace(d.help), d.name, kind)
+}
+
+// key joins label values into a map key, checking the label count. Invalid UTF-8 is
+// replaced first, as the exposition format requires, which also keeps the \xff
+// separator out of the values.
+func (d desc) key(values []string) (string, error) {
+	if len(values) != len(d.labels) {
+		return "", fmt.Errorf("%w: %s expects %d, got %d", ErrLabelCount, d.name, len(d.labels), len(values))
+	}
+
+	valid := make([]string, len(values))
+	for i, value := range values {
+		valid[i] = strings.ToValidUTF8(value, "\uFFFD")
+	}
+	return strings.Join(valid, "\xff"), nil
+}
+
+// labelPairs formats label values, plus optional extra pairs, as {name="value",...}
+func (d desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, labelPair(d.labels[i], value))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, labelPair(extra[i], extra[i+1]))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// The text format escapes only backslashes, double quotes (in label values) and line
+// feeds; Go's %q escapes would be read back as different characters
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// labelPair formats one name="value" pair
+func labelPair(name, value string) string {
+	return name + `="` + labelValueEscaper.Replace(value) + `"`
+}
+
+// CounterVec is a family of monotonically increasing counters
+type CounterVec struct {
+	desc
+	values map[string]float64
+	mu     sync.Mutex
+}
+
+// Inc increments the counter with the given label values by one
+func (c *CounterVec) Inc(labelValues ...string) error {
+	return c.Add(1, labelValues...)
+}
+
+// Add increases the counter with the given label values by v
+func (c *CounterVec) Add(v float64, labelValues ...string) error {
+	key, err := c.key(labelValues)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] += v
+	return nil
+}
+
+// Value returns the current value of the counter with the given label values
+func (c *CounterVec) Value(labelValues ...string) (float64, error) {
+	key, err := c.key(labelValues)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[key], nil
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), formatFloat(c.values[key]))
+	}
+}
+
+// HistogramVec is a family of histograms with cumulative buckets
+type HistogramVec struct {
+	desc
+	buckets []float64
+	series  map[string]*histogramSeries
+	mu      sync.Mutex
+}
+
+// histogramSeries holds the observations of one label combination
+type histogramSeries struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Observe records a value in the histogram with the given label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) error {
+	key, err := h.key(labelValues)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, exists := h.series[key]
+	if !exists {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += v
+	return nil
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key), series.count)
+	}
+}
+
+// gaugeFunc is a gauge computed on demand
+type gaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// sortedKeys returns the keys of a map in sorted order for stable output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat formats a sample value as Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,486 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCounterVec(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "Test counter", "op")
+
+	counter.Inc("read")
+	counter.Inc("read")
+	counter.Add(2.5, "write")
+
+	if got, err := counter.Value("read"); err != nil || got != 2 {
+		t.Errorf("Expected 2, got %v (%v)", got, err)
+	}
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+
+	expected := "# HELP test_total Test counter\n" +
+		"# TYPE test_total counter\n" +
+		"test_total{op=\"read\"} 2\n" +
+		"test_total{op=\"write\"} 2.5\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "Test counter", "op")
+	histogram := registry.NewHistogramVec("test_seconds", "Test histogram", DefaultBuckets, "method", "route")
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"counter without labels", counter.Inc()},
+		{"counter with extra labels", counter.Add(1, "read", "extra")},
+		{"histogram with missing labels", histogram.Observe(0.1, "GET")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, ErrLabelCount) {
+				t.Errorf("Expected ErrLabelCount, got %v", tt.err)
+			}
+		})
+	}
+	if _, err := counter.Value(); !errors.Is(err, ErrLabelCount) {
+		t.Errorf("Expected ErrLabelCount from Value, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+	if strings.Contains(buf.String(), "test_total{") || strings.Contains(buf.String(), "test_seconds_count") {
+		t.Errorf("Expected rejected values not to be recorded, got:\n%s", buf.String())
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	registry := NewRegistry()
+	histogram := registry.NewHistogramVec("test_seconds", "Test histogram", []float64{1, 0.1}, "route")
+
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(5, "/a")
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+
+	tests := []string{
+		"# TYPE test_seconds histogram",
+		"test_seconds_bucket{route=\"/a\",le=\"0.1\"} 1",
+		"test_seconds_bucket{route=\"/a\",le=\"1\"} 2",
+		"test_seconds_bucket{route=\"/a\",le=\"+Inf\"} 3",
+		"test_seconds_sum{route=\"/a\"} 5.55",
+		"test_seconds_count{route=\"/a\"} 3",
+	}
+
+	for _, line := range tests {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	registry := NewRegistry()
+	value := 3.0
+	registry.NewGaugeFunc("test_gauge", "Test gauge", func() float64 { return value })
+
+	value = 7
+	var buf bytes.Buffer
+	registry.Write(&buf)
+
+	if !strings.Contains(buf.String(), "test_gauge 7\n") {
+		t.Errorf("Expected gauge value read at scrape time, got:\n%s", buf.String())
+	}
+}
+
+func TestHandlerExpositionFormat(t *testing.T) {
+	registry := NewRegistry()
+	help := "Requests with \"quotes\", a \\ backslash\nand a second line"
+	counter := registry.NewCounterVec("test_requests_total", help, "route")
+	histogram := registry.NewHistogramVec("test_duration_seconds", "Test histogram", DefaultBuckets, "route")
+	registry.NewGaugeFunc("test_gauge", "Test gauge", func() float64 { return math.Inf(1) })
+
+	routes := []string{"/plain", `/quote"d`, `/back\slash`, "/line\nfeed", "/tab\there", "/ファイル", "/invalid\xff"}
+	for i, route := range routes {
+		counter.Add(float64(i+1), route)
+		histogram.Observe(0.02, route)
+		histogram.Observe(float64(i), route)
+	}
+
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected text exposition content type, got %q", ct)
+	}
+
+	families, err := parseExposition(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to parse exposition:\n%s\nerror: %v", w.Body.String(), err)
+	}
+
+	requests := families["test_requests_total"]
+	if requests == nil || requests.kind != "counter" || requests.help != help {
+		t.Fatalf("Expected counter family with its help text, got %+v", requests)
+	}
+	for i, route := range routes {
+		want := strings.ToValidUTF8(route, "\uFFFD")
+		if got, ok := requests.value("test_requests_total", map[string]string{"route": want}); !ok || got != float64(i+1) {
+			t.Errorf("Expected route %q to read back as %v, got %v (found %v)", route, i+1, got, ok)
+		}
+	}
+
+	durations := families["test_duration_seconds"]
+	if durations == nil || durations.kind != "histogram" {
+		t.Fatalf("Expected histogram family, got %+v", durations)
+	}
+	if got, ok := durations.value("test_duration_seconds_count", map[string]string{"route": "/ファイル"}); !ok || got != 2 {
+		t.Errorf("Expected histogram count 2, got %v (found %v)", got, ok)
+	}
+
+	if got, ok := families["test_gauge"].value("test_gauge", nil); !ok || !math.IsInf(got, 1) {
+		t.Errorf("Expected gauge +Inf, got %v (found %v)", got, ok)
+	}
+}
+
+// family is a metric family read back from the text exposition format
+type family struct {
+	kind    string
+	help    string
+	samples []sample
+}
+
+// sample is one sample line of a family
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// value returns the value of the sample with the given name and exactly the given labels
+func (f *family) value(name string, labels map[string]string) (float64, bool) {
+	if f == nil {
+		return 0, false
+	}
+	for _, s := range f.samples {
+		if s.name == name && fmt.Sprint(s.labels) == fmt.Sprint(labels) {
+			return s.value, true
+		}
+	}
+	return 0, false
+}
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// parseExposition strictly parses the Prometheus text exposition format (version
+// 0.0.4), as a scraper would, and checks the rules a scraper enforces: HELP and TYPE
+// at most once and before the family's samples, families not interleaved, no
+// duplicate series, only the defined escapes, valid UTF-8, and histograms with
+// ordered cumulative buckets ending in +Inf that agree with their count.
+func parseExposition(r io.Reader) (map[string]*family, error) {
+	families := make(map[string]*family)
+	seen := make(map[string]bool)
+	var current string
+	get := func(name string) *family {
+		if families[name] == nil {
+			families[name] = &family{kind: "untyped"}
+		}
+		return families[name]
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if !utf8.ValidString(line) {
+			return nil, fmt.Errorf("line %d: invalid UTF-8", lineNo)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "#")), " ", 3)
+			if len(fields) < 2 || (fields[0] != "HELP" && fields[0] != "TYPE") {
+				continue // Plain comment
+			}
+			name := fields[1]
+			if !metricNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("line %d: invalid metric name %q", lineNo, name)
+			}
+			f := get(name)
+			if len(f.samples) > 0 {
+				return nil, fmt.Errorf("line %d: %s for %s after its samples", lineNo, fields[0], name)
+			}
+			text := ""
+			if len(fields) == 3 {
+				text = fields[2]
+			}
+			if fields[0] == "HELP" {
+				if f.help != "" {
+					return nil, fmt.Errorf("line %d: second HELP for %s", lineNo, name)
+				}
+				help, err := unescape(text, false)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				f.help = help
+			} else {
+				switch text {
+				case "counter", "gauge", "histogram", "summary", "untyped":
+				default:
+					return nil, fmt.Errorf("line %d: unknown type %q", lineNo, text)
+				}
+				if f.kind != "untyped" {
+					return nil, fmt.Errorf("line %d: second TYPE for %s", lineNo, name)
+				}
+				f.kind = text
+			}
+			continue
+		}
+
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		name := s.name
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			if base := strings.TrimSuffix(s.name, suffix); base != s.name && families[base] != nil && families[base].kind == "histogram" {
+				name = base
+			}
+		}
+		if name != current {
+			if seen[name] {
+				return nil, fmt.Errorf("line %d: samples of %s are not grouped together", lineNo, name)
+			}
+			seen[name], current = true, name
+		}
+		f := get(name)
+		if f.kind == "counter" && s.value < 0 {
+			return nil, fmt.Errorf("line %d: negative counter %s", lineNo, s.name)
+		}
+		for _, other := range f.samples {
+			if other.name == s.name && fmt.Sprint(other.labels) == fmt.Sprint(s.labels) {
+				return nil, fmt.Errorf("line %d: duplicate series %s%v", lineNo, s.name, s.labels)
+			}
+		}
+		f.samples = append(f.samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, f := range families {
+		if f.kind == "histogram" {
+			if err := checkHistogram(name, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return families, nil
+}
+
+// parseSample parses a sample line: name, optional labels, value and optional timestamp
+func parseSample(line string) (sample, error) {
+	s := sample{labels: make(map[string]string)}
+	end := strings.IndexAny(line, "{ ")
+	if end < 0 {
+		return s, fmt.Errorf("sample without value: %q", line)
+	}
+	s.name, line = line[:end], line[end:]
+	if !metricNamePattern.MatchString(s.name) {
+		return s, fmt.Errorf("invalid metric name %q", s.name)
+	}
+
+	if strings.HasPrefix(line, "{") {
+		line = line[1:]
+		for !strings.HasPrefix(line, "}") {
+			eq := strings.Index(line, `="`)
+			if eq < 0 {
+				return s, fmt.Errorf("malformed labels in %s", s.name)
+			}
+			label := line[:eq]
+			if !labelNamePattern.MatchString(label) {
+				return s, fmt.Errorf("invalid label name %q", label)
+			}
+			if _, dup := s.labels[label]; dup {
+				return s, fmt.Errorf("duplicate label %q", label)
+			}
+			line = line[eq+2:]
+
+			var raw strings.Builder
+			closed := false
+			for i := 0; i < len(line); i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					raw.WriteString(line[i : i+2])
+					i++
+					continue
+				}
+				if line[i] == '"' {
+					line, closed = line[i+1:], true
+					break
+				}
+				raw.WriteByte(line[i])
+			}
+			if !closed {
+				return s, fmt.Errorf("unterminated label value in %s", s.name)
+			}
+			value, err := unescape(raw.String(), true)
+			if err != nil {
+				return s, err
+			}
+			s.labels[label] = value
+
+			if strings.HasPrefix(line, ",") {
+				line = line[1:]
+			} else if !strings.HasPrefix(line, "}") {
+				return s, fmt.Errorf("malformed labels in %s", s.name)
+			}
+		}
+		line = line[1:]
+	}
+
+	fields := strings.Fields(line)
+	if !strings.HasPrefix(line, " ") || len(fields) < 1 || len(fields) > 2 {
+		return s, fmt.Errorf("malformed value in %s", s.name)
+	}
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return s, err
+	}
+	s.value = value
+	if len(fields) == 2 {
+		if _, err := strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return s, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+	}
+	return s, nil
+}
+
+// parseValue parses a sample value, which may be +Inf, -Inf or NaN
+func parseValue(text string) (float64, error) {
+	switch text {
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || strings.ContainsAny(text, "_xXpP") {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+	return value, nil
+}
+
+// unescape resolves the escapes allowed in HELP text (\\ and \n) and label values
+// (also \"), rejecting any other
+func unescape(text string, quotes bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			b.WriteByte(text[i])
+			continue
+		}
+		if i++; i == len(text) {
+			return "", fmt.Errorf("trailing backslash in %q", text)
+		}
+		switch {
+		case text[i] == '\\':
+			b.WriteByte('\\')
+		case text[i] == 'n':
+			b.WriteByte('\n')
+		case text[i] == '"' && quotes:
+			b.WriteByte('"')
+		default:
+			return "", fmt.Errorf("invalid escape \\%c in %q", text[i], text)
+		}
+	}
+	return b.String(), nil
+}
+
+// checkHistogram checks that each series of a histogram family has buckets in
+// increasing order of le with non-decreasing counts, ending in +Inf equal to its count,
+// and a sum
+func checkHistogram(name string, f *family) error {
+	type series struct {
+		bounds, counts []float64
+		count          float64
+		hasCount       bool
+		hasSum         bool
+	}
+	all := make(map[string]*series)
+	get := func(labels map[string]string) *series {
+		key := fmt.Sprint(labels)
+		if all[key] == nil {
+			all[key] = &series{}
+		}
+		return all[key]
+	}
+
+	for _, s := range f.samples {
+		labels := make(map[string]string)
+		for k, v := range s.labels {
+			if k != "le" {
+				labels[k] = v
+			}
+		}
+		switch s.name {
+		case name + "_bucket":
+			le, ok := s.labels["le"]
+			if !ok {
+				return fmt.Errorf("%s bucket without le", name)
+			}
+			bound, err := parseValue(le)
+			if err != nil {
+				return fmt.Errorf("%s bucket: %w", name, err)
+			}
+			ser := get(labels)
+			ser.bounds = append(ser.bounds, bound)
+			ser.counts = append(ser.counts, s.value)
+		case name + "_count":
+			ser := get(labels)
+			ser.count, ser.hasCount = s.value, true
+		case name + "_sum":
+			get(labels).hasSum = true
+		default:
+			return fmt.Errorf("unexpected sample %s in histogram %s", s.name, name)
+		}
+	}
+
+	for key, ser := range all {
+		if !ser.hasCount || !ser.hasSum || len(ser.bounds) == 0 {
+			return fmt.Errorf("%s%s: missing buckets, sum or count", name, key)
+		}
+		if !sort.Float64sAreSorted(ser.bounds) || !sort.Float64sAreSorted(ser.counts) {
+			return fmt.Errorf("%s%s: buckets are not cumulative in order", name, key)
+		}
+		last := len(ser.bounds) - 1
+		if !math.IsInf(ser.bounds[last], 1) || ser.counts[last] != ser.count {
+			return fmt.Errorf("%s%s: +Inf bucket missing or not equal to count", name, key)
+		}
+	}
+	return nil
+}
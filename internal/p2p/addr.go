@@ -0,0 +1,66 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseMultiaddr converts a TCP multiaddr such as /ip4/10.0.0.1/tcp/4001/p2p/node-002 into
+// a dialable host:port address and the optional peer ID it names
+func ParseMultiaddr(addr string) (hostPort, peerID string, err error) {
+	if !strings.HasPrefix(addr, "/") {
+		return "", "", fmt.Errorf("invalid multiaddr %q: must start with /", addr)
+	}
+
+	var host, port string
+	parts := strings.Split(addr[1:], "/")
+	for i := 0; i+1 < len(parts); i += 2 {
+		protocol, value := parts[i], parts[i+1]
+		switch protocol {
+		case "ip4", "ip6", "dns", "dns4", "dns6":
+			host = value
+		case "tcp":
+			port = value
+		case "p2p":
+			peerID = value
+		default:
+			return "", "", fmt.Errorf("invalid multiaddr %q: unsupported protocol %s", addr, protocol)
+		}
+	}
+	if len(parts)%2 != 0 {
+		return "", "", fmt.Errorf("invalid multiaddr %q: missing value for %s", addr, parts[len(parts)-1])
+	}
+
+	if host == "" || port == "" {
+		return "", "", fmt.Errorf("invalid multiaddr %q: host and tcp port are required", addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", "", fmt.Errorf("invalid multiaddr %q: bad tcp port %s", addr, port)
+	}
+
+	return net.JoinHostPort(host, port), peerID, nil
+}
+
+// FormatMultiaddr builds the multiaddr of a TCP address, naming the peer when peerID is set
+func FormatMultiaddr(hostPort, peerID string) (string, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", hostPort, err)
+	}
+
+	protocol := "dns"
+	if ip := net.ParseIP(host); ip != nil {
+		protocol = "ip6"
+		if ip.To4() != nil {
+			protocol = "ip4"
+		}
+	}
+
+	addr := fmt.Sprintf("/%s/%s/tcp/%s", protocol, host, port)
+	if peerID != "" {
+		addr += "/p2p/" + peerID
+	}
+	return addr, nil
+}
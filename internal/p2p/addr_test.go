@@ -0,0 +1,70 @@
+package p2p
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMultiaddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		hostPort string
+		peerID   string
+		wantErr  string
+	}{
+		{"/ip4/127.0.0.1/tcp/4001", "127.0.0.1:4001", "", ""},
+		{"/ip4/10.0.0.2/tcp/4001/p2p/node-002", "10.0.0.2:4001", "node-002", ""},
+		{"/ip6/::1/tcp/4001", "[::1]:4001", "", ""},
+		{"/dns4/node.example.com/tcp/4001", "node.example.com:4001", "", ""},
+		{"127.0.0.1:4001", "", "", "must start with /"},
+		{"/ip4/127.0.0.1/udp/4001/quic-v1", "", "", "unsupported protocol udp"},
+		{"/ip4/127.0.0.1", "", "", "tcp port are required"},
+		{"/ip4/127.0.0.1/tcp", "", "", "missing value for tcp"},
+		{"/ip4/127.0.0.1/tcp/port", "", "", "bad tcp port"},
+	}
+
+	for _, tt := range tests {
+		hostPort, peerID, err := ParseMultiaddr(tt.addr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.addr, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.addr, err)
+			continue
+		}
+		if hostPort != tt.hostPort || peerID != tt.peerID {
+			t.Errorf("%s: expected %s and %q, got %s and %q", tt.addr, tt.hostPort, tt.peerID, hostPort, peerID)
+		}
+	}
+}
+
+func TestFormatMultiaddr(t *testing.T) {
+	tests := []struct {
+		hostPort string
+		peerID   string
+		expected string
+	}{
+		{"127.0.0.1:4001", "", "/ip4/127.0.0.1/tcp/4001"},
+		{"[::1]:4001", "node-001", "/ip6/::1/tcp/4001/p2p/node-001"},
+		{"node.example.com:4001", "", "/dns/node.example.com/tcp/4001"},
+	}
+
+	for _, tt := range tests {
+		addr, err := FormatMultiaddr(tt.hostPort, tt.peerID)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.hostPort, err)
+			continue
+		}
+		if addr != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.hostPort, tt.expected, addr)
+		}
+
+		hostPort, peerID, err := ParseMultiaddr(addr)
+		if err != nil || hostPort != tt.hostPort || peerID != tt.peerID {
+			t.Errorf("%s: expected round trip, got %s %q %v", addr, hostPort, peerID, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package p2p
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCircuitOpen is returned for requests to a peer whose circuit breaker is open
+var ErrCircuitOpen = errors.New("peer circuit open")
+
+// CircuitState is the state of a peer's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through while counting consecutive failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects requests until the cooldown has passed
+	CircuitOpen
+	// CircuitHalfOpen lets a single trial request through to see if the peer recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker stops requests to failing peers. A peer's circuit opens after threshold
+// consecutive failures and rejects requests for cooldown, after which one trial request
+// is let through: its success closes the circuit and its failure opens it again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	circuits  map[string]*circuit
+	logger    *logrus.Logger
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// circuit is the breaker state of one peer
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker creates a breaker opening a peer's circuit after threshold
+// consecutive failures for cooldown
+func NewCircuitBreaker(threshold int, cooldown time.Duration, logger *logrus.Logger) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a request may be sent to the peer. Once an open circuit has
+// cooled down, the first caller is allowed through as the trial request and the circuit
+// turns half-open, rejecting others until the trial's outcome is recorded.
+func (b *CircuitBreaker) Allow(peerID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, exists := b.circuits[peerID]
+	if !exists {
+		return true
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		if b.now().Sub(c.openedAt) < b.cooldown {
+			return false
+		}
+		c.state = CircuitHalfOpen
+		return true
+	case CircuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// RecordSuccess closes the peer's circuit
+func (b *CircuitBreaker) RecordSuccess(peerID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, exists := b.circuits[peerID]; exists && c.state != CircuitClosed {
+		b.logger.WithField("peer_id", peerID).Info("Peer recovered, closing circuit")
+	}
+	delete(b.circuits, peerID)
+}
+
+// RecordFailure counts a failed request to the peer, opening its circuit at the
+// threshold or when the trial request of a half-open circuit fails
+func (b *CircuitBreaker) RecordFailure(peerID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, exists := b.circuits[peerID]
+	if !exists {
+		c = &circuit{}
+		b.circuits[peerID] = c
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || (c.state == CircuitClosed && c.failures >= b.threshold) {
+		c.state = CircuitOpen
+		c.openedAt = b.now()
+		b.logger.WithFields(logrus.Fields{
+			"peer_id":  peerID,
+			"failures": c.failures,
+			"cooldown": b.cooldown,
+		}).Warn("Peer failing, opening circuit")
+	}
+}
+
+// Ready reports whether requests to the peer would currently be allowed, without taking
+// the trial request of a cooled down circuit
+func (b *CircuitBreaker) Ready(peerID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, exists := b.circuits[peerID]
+	if !exists {
+		return true
+	}
+	switch c.state {
+	case CircuitOpen:
+		return b.now().Sub(c.openedAt) >= b.cooldown
+	case CircuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// State returns the state of the peer's circuit
+func (b *CircuitBreaker) State(peerID string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, exists := b.circuits[peerID]; exists {
+		return c.state
+	}
+	return CircuitClosed
+}
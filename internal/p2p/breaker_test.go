@@ -0,0 +1,169 @@
+package p2p
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestBreaker creates a circuit breaker with a controllable clock
+func newTestBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *time.Time) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	now := time.Unix(1700000000, 0)
+	breaker := NewCircuitBreaker(threshold, cooldown, logger)
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	breaker, now := newTestBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		breaker.RecordFailure("node-002")
+	}
+	if !breaker.Allow("node-002") || breaker.State("node-002") != CircuitClosed {
+		t.Fatalf("Expected circuit to stay closed below the threshold, got %s", breaker.State("node-002"))
+	}
+
+	// A success resets the count of consecutive failures
+	breaker.RecordSuccess("node-002")
+	breaker.RecordFailure("node-002")
+	breaker.RecordFailure("node-002")
+	if breaker.State("node-002") != CircuitClosed {
+		t.Fatalf("Expected success to reset failures, got %s", breaker.State("node-002"))
+	}
+
+	breaker.RecordFailure("node-002")
+	if breaker.State("node-002") != CircuitOpen || breaker.Allow("node-002") || breaker.Ready("node-002") {
+		t.Fatalf("Expected circuit to open at the threshold, got %s", breaker.State("node-002"))
+	}
+	if !breaker.Allow("node-003") {
+		t.Error("Expected other peers to be unaffected")
+	}
+
+	*now = now.Add(30 * time.Second)
+	if breaker.Allow("node-002") {
+		t.Error("Expected open circuit to reject requests during the cooldown")
+	}
+
+	// After the cooldown a single trial request is let through
+	*now = now.Add(30 * time.Second)
+	if !breaker.Ready("node-002") || breaker.State("node-002") != CircuitOpen {
+		t.Error("Expected Ready not to take the trial request")
+	}
+	if !breaker.Allow("node-002") || breaker.State("node-002") != CircuitHalfOpen {
+		t.Fatalf("Expected trial request after the cooldown, got %s", breaker.State("node-002"))
+	}
+	if breaker.Allow("node-002") {
+		t.Error("Expected half-open circuit to allow only one trial request")
+	}
+
+	// A failed trial opens the circuit for another cooldown
+	breaker.RecordFailure("node-002")
+	if breaker.State("node-002") != CircuitOpen || breaker.Allow("node-002") {
+		t.Fatalf("Expected failed trial to reopen the circuit, got %s", breaker.State("node-002"))
+	}
+
+	*now = now.Add(time.Minute)
+	if !breaker.Allow("node-002") {
+		t.Fatal("Expected trial request after the second cooldown")
+	}
+	breaker.RecordSuccess("node-002")
+	if breaker.State("node-002") != CircuitClosed || !breaker.Allow("node-002") {
+		t.Errorf("Expected successful trial to close the circuit, got %s", breaker.State("node-002"))
+	}
+}
+
+func TestFetchChunkSkipsPeerWithOpenCircuit(t *testing.T) {
+	local, _ := newTestChunkService(t, "node-001")
+	local.timeout = 50 * time.Millisecond
+	breaker, now := newTestBreaker(2, time.Minute)
+	local.SetCircuitBreaker(breaker)
+
+	// The peer only counts the requests it gets and never answers
+	remote := newTestHost(t, "node-002")
+	requests := make(chan struct{}, 10)
+	remote.Handle(types.MessageTypeChunkRequest, func(msg *types.NetworkMessage) {
+		requests <- struct{}{}
+	})
+	if _, err := local.host.Connect(remote.Addr()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := local.FetchChunk("node-002", "abc"); !errors.Is(err, ErrChunkTimeout) {
+			t.Fatalf("Expected ErrChunkTimeout, got %v", err)
+		}
+	}
+	waitFor(t, "requests", func() bool { return len(requests) == 2 })
+
+	// With the circuit open requests fail at once without reaching the peer
+	start := time.Now()
+	if _, err := local.FetchChunk("node-002", "abc"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= local.timeout {
+		t.Errorf("Expected open circuit to fail fast, took %s", elapsed)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(requests) != 2 {
+		t.Errorf("Expected no request to reach the peer, got %d", len(requests))
+	}
+
+	// After the cooldown the peer is tried again
+	*now = now.Add(time.Minute)
+	if _, err := local.FetchChunk("node-002", "abc"); !errors.Is(err, ErrChunkTimeout) {
+		t.Fatalf("Expected trial request to reach the peer, got %v", err)
+	}
+	waitFor(t, "trial request", func() bool { return len(requests) == 3 })
+	if breaker.State("node-002") != CircuitOpen {
+		t.Errorf("Expected failed trial to reopen the circuit, got %s", breaker.State("node-002"))
+	}
+}
+
+func TestFetchChunkNotFoundKeepsCircuitClosed(t *testing.T) {
+	local, _ := newTestChunkService(t, "node-001")
+	remote, _ := newTestChunkService(t, "node-002")
+	breaker, _ := newTestBreaker(1, time.Minute)
+	local.SetCircuitBreaker(breaker)
+
+	if _, err := local.host.Connect(remote.host.Addr()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// A peer answering that it lacks the chunk is healthy
+	if _, err := local.FetchChunk("node-002", types.CalculateHash([]byte("missing"))); !errors.Is(err, ErrChunkNotFound) {
+		t.Fatalf("Expected ErrChunkNotFound, got %v", err)
+	}
+	if breaker.State("node-002") != CircuitClosed {
+		t.Errorf("Expected circuit to stay closed, got %s", breaker.State("node-002"))
+	}
+}
+
+func TestSyncSkipsPeerWithOpenCircuit(t *testing.T) {
+	node, nodeStorage := newTestSyncService(t, "node-001", 0)
+	failing, failingStorage := newTestSyncService(t, "node-002", 0)
+	healthy, healthyStorage := newTestSyncService(t, "node-003", 0)
+	breaker, _ := newTestBreaker(1, time.Minute)
+	node.chunks.SetCircuitBreaker(breaker)
+
+	storeTestChunks(t, failingStorage, "only on the failing peer")
+	ids := storeTestChunks(t, healthyStorage, "on the healthy peer")
+	connectSync(t, node, failing)
+	connectSync(t, node, healthy)
+
+	breaker.RecordFailure("node-002")
+
+	if pulled := node.Sync(); pulled != 1 {
+		t.Fatalf("Expected 1 chunk pulled from the healthy peer, got %d", pulled)
+	}
+	if got := listChunks(t, nodeStorage); len(got) != 1 || got[0] != ids[0] {
+		t.Errorf("Expected only the healthy peer's chunk, got %v", got)
+	}
+}
@@ -0,0 +1,225 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultChunkRequestTimeout bounds how long a chunk request waits for its response
+const defaultChunkRequestTimeout = 30 * time.Second
+
+// Chunk response statuses
+const (
+	ChunkStatusOK       = "ok"
+	ChunkStatusNotFound = "not_found"
+	ChunkStatusError    = "error"
+)
+
+var (
+	// ErrChunkNotFound is returned when the peer does not hold the requested chunk
+	ErrChunkNotFound = errors.New("chunk not found on peer")
+
+	// ErrChunkChecksum is returned when a received chunk does not match its checksum
+	ErrChunkChecksum = errors.New("chunk checksum mismatch")
+
+	// ErrChunkTimeout is returned when a peer does not answer a chunk request in time
+	ErrChunkTimeout = errors.New("chunk request timed out")
+)
+
+// ChunkRequest is the payload of a MessageTypeChunkRequest message
+type ChunkRequest struct {
+	RequestID string `json:"request_id"`
+	ChunkID   string `json:"chunk_id"`
+}
+
+// ChunkResponse is the payload of a MessageTypeChunkResponse message
+type ChunkResponse struct {
+	RequestID string `json:"request_id"`
+	ChunkID   string `json:"chunk_id"`
+	Status    string `json:"status"`
+	Data      []byte `json:"data,omitempty"`
+	Checksum  string `json:"checksum,omitempty"` // Hash of Data as stored by the peer
+	Error     string `json:"error,omitempty"`
+}
+
+// ChunkService serves locally stored chunks to peers and fetches chunks from them
+type ChunkService struct {
+	host    *Host
+	storage storage.Storage
+	timeout time.Duration
+	breaker *CircuitBreaker // Skips failing peers; nil tries every peer
+	logger  *logrus.Logger
+
+	pending map[string]*pendingRequest
+	mu      sync.Mutex
+}
+
+// pendingRequest is a chunk request awaiting its response
+type pendingRequest struct {
+	peerID    string
+	responses chan ChunkResponse
+}
+
+// NewChunkService creates a chunk service answering requests on host from storage
+func NewChunkService(host *Host, store storage.Storage, logger *logrus.Logger) *ChunkService {
+	service := &ChunkService{
+		host:    host,
+		storage: store,
+		timeout: defaultChunkRequestTimeout,
+		logger:  logger,
+		pending: make(map[string]*pendingRequest),
+	}
+
+	host.Handle(types.MessageTypeChunkRequest, service.handleRequest)
+	host.Handle(types.MessageTypeChunkResponse, service.handleResponse)
+	return service
+}
+
+// SetCircuitBreaker makes chunk fetches skip peers whose circuit breaker is open
+func (s *ChunkService) SetCircuitBreaker(breaker *CircuitBreaker) {
+	s.breaker = breaker
+}
+
+// ready reports whether chunks may currently be fetched from the peer
+func (s *ChunkService) ready(peerID string) bool {
+	return s.breaker == nil || s.breaker.Ready(peerID)
+}
+
+// FetchChunk requests a chunk from a peer, verifying the data against its checksum.
+// Requests to a peer whose circuit breaker is open fail at once with ErrCircuitOpen.
+func (s *ChunkService) FetchChunk(peerID, chunkID string) ([]byte, error) {
+	if s.breaker == nil {
+		return s.fetchChunk(peerID, chunkID)
+	}
+	if !s.breaker.Allow(peerID) {
+		return nil, fmt.Errorf("chunk %s from %s: %w", chunkID, peerID, ErrCircuitOpen)
+	}
+
+	data, err := s.fetchChunk(peerID, chunkID)
+	// A peer answering that it lacks the chunk is still healthy
+	if err != nil && !errors.Is(err, ErrChunkNotFound) {
+		s.breaker.RecordFailure(peerID)
+	} else {
+		s.breaker.RecordSuccess(peerID)
+	}
+	return data, err
+}
+
+// fetchChunk requests a chunk from a peer and waits for its response
+func (s *ChunkService) fetchChunk(peerID, chunkID string) ([]byte, error) {
+	requestID, err := utils.GenerateRandomID(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate request ID: %w", err)
+	}
+
+	pending := &pendingRequest{peerID: peerID, responses: make(chan ChunkResponse, 1)}
+	s.mu.Lock()
+	s.pending[requestID] = pending
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, requestID)
+		s.mu.Unlock()
+	}()
+
+	request := ChunkRequest{RequestID: requestID, ChunkID: chunkID}
+	if err := s.host.Send(peerID, types.MessageTypeChunkRequest, request); err != nil {
+		return nil, fmt.Errorf("failed to request chunk %s from %s: %w", chunkID, peerID, err)
+	}
+
+	var response ChunkResponse
+	select {
+	case response = <-pending.responses:
+	case <-time.After(s.timeout):
+		return nil, fmt.Errorf("chunk %s from %s: %w", chunkID, peerID, ErrChunkTimeout)
+	}
+
+	switch response.Status {
+	case ChunkStatusOK:
+	case ChunkStatusNotFound:
+		return nil, fmt.Errorf("chunk %s from %s: %w", chunkID, peerID, ErrChunkNotFound)
+	default:
+		return nil, fmt.Errorf("peer %s failed to serve chunk %s: %s", peerID, chunkID, response.Error)
+	}
+
+	if types.CalculateHash(response.Data) != response.Checksum {
+		return nil, fmt.Errorf("chunk %s from %s: %w", chunkID, peerID, ErrChunkChecksum)
+	}
+	return response.Data, nil
+}
+
+// handleRequest answers a peer's chunk request from local storage
+func (s *ChunkService) handleRequest(msg *types.NetworkMessage) {
+	var request ChunkRequest
+	if err := DecodeData(msg, &request); err != nil {
+		s.logger.WithError(err).WithField("peer_id", msg.From).Warn("Ignoring invalid chunk request")
+		return
+	}
+
+	// Serve in the background so a large response does not hold up the peer's other messages
+	go func() {
+		response := s.lookup(request)
+		if err := s.host.Send(msg.From, types.MessageTypeChunkResponse, response); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"peer_id":  msg.From,
+				"chunk_id": request.ChunkID,
+			}).Warn("Failed to send chunk response")
+		}
+	}()
+}
+
+// lookup builds the response to a chunk request
+func (s *ChunkService) lookup(request ChunkRequest) ChunkResponse {
+	response := ChunkResponse{RequestID: request.RequestID, ChunkID: request.ChunkID}
+
+	data, err := s.storage.Retrieve(context.Background(), request.ChunkID)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		response.Status = ChunkStatusNotFound
+	case err != nil:
+		s.logger.WithError(err).WithField("chunk_id", request.ChunkID).Error("Failed to read requested chunk")
+		response.Status = ChunkStatusError
+		response.Error = "failed to read chunk"
+	default:
+		response.Status = ChunkStatusOK
+		response.Data = data
+		response.Checksum = types.CalculateHash(data)
+	}
+	return response
+}
+
+// handleResponse delivers a chunk response to the request waiting for it
+func (s *ChunkService) handleResponse(msg *types.NetworkMessage) {
+	var response ChunkResponse
+	if err := DecodeData(msg, &response); err != nil {
+		s.logger.WithError(err).WithField("peer_id", msg.From).Warn("Ignoring invalid chunk response")
+		return
+	}
+
+	s.mu.Lock()
+	pending, exists := s.pending[response.RequestID]
+	s.mu.Unlock()
+
+	// Only the peer that was asked may answer
+	if !exists || pending.peerID != msg.From {
+		s.logger.WithFields(logrus.Fields{
+			"peer_id":    msg.From,
+			"request_id": response.RequestID,
+		}).Debug("Ignoring unexpected chunk response")
+		return
+	}
+
+	select {
+	case pending.responses <- response:
+	default:
+	}
+}
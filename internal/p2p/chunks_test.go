@@ -0,0 +1,111 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestChunkService starts a host serving chunks from its own temporary storage
+func newTestChunkService(t *testing.T, nodeID string) (*ChunkService, storage.Storage) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fileStorage, err := storage.NewFileStorage(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	host := newTestHost(t, nodeID)
+	return NewChunkService(host, fileStorage, logger), fileStorage
+}
+
+func TestFetchChunkFromPeer(t *testing.T) {
+	local, localStorage := newTestChunkService(t, "node-001")
+	remote, remoteStorage := newTestChunkService(t, "node-002")
+
+	data := []byte("chunk held only by the remote node")
+	chunkID := types.CalculateHash(data)
+	if err := remoteStorage.Store(context.Background(), chunkID, data); err != nil {
+		t.Fatalf("Failed to store chunk: %v", err)
+	}
+
+	if _, err := local.host.Connect(remote.host.Addr()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	fetched, err := local.FetchChunk("node-002", chunkID)
+	if err != nil {
+		t.Fatalf("Failed to fetch chunk: %v", err)
+	}
+	if !bytes.Equal(fetched, data) {
+		t.Errorf("Expected %q, got %q", data, fetched)
+	}
+	if localStorage.Exists(chunkID) {
+		t.Error("Expected fetching not to store the chunk locally")
+	}
+
+	// Unknown chunks get a typed not-found answer
+	_, err = local.FetchChunk("node-002", types.CalculateHash([]byte("missing")))
+	if !errors.Is(err, ErrChunkNotFound) {
+		t.Errorf("Expected ErrChunkNotFound, got %v", err)
+	}
+
+	// Requests to peers that are not connected fail immediately
+	if _, err := local.FetchChunk("node-003", chunkID); !errors.Is(err, ErrPeerNotConnected) {
+		t.Errorf("Expected ErrPeerNotConnected, got %v", err)
+	}
+}
+
+func TestFetchChunkChecksumMismatch(t *testing.T) {
+	local, _ := newTestChunkService(t, "node-001")
+	remote := newTestHost(t, "node-002")
+
+	// The remote peer answers with data that does not match the checksum it claims
+	remote.Handle(types.MessageTypeChunkRequest, func(msg *types.NetworkMessage) {
+		var request ChunkRequest
+		DecodeData(msg, &request)
+		go remote.Send(msg.From, types.MessageTypeChunkResponse, ChunkResponse{
+			RequestID: request.RequestID,
+			ChunkID:   request.ChunkID,
+			Status:    ChunkStatusOK,
+			Data:      []byte("tampered"),
+			Checksum:  types.CalculateHash([]byte("original")),
+		})
+	})
+
+	if _, err := local.host.Connect(remote.Addr()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if _, err := local.FetchChunk("node-002", "abc"); !errors.Is(err, ErrChunkChecksum) {
+		t.Errorf("Expected ErrChunkChecksum, got %v", err)
+	}
+}
+
+func TestFetchChunkTimeout(t *testing.T) {
+	local, _ := newTestChunkService(t, "node-001")
+	local.timeout = 50 * time.Millisecond
+
+	// A peer without a chunk service never answers
+	remote := newTestHost(t, "node-002")
+	if _, err := local.host.Connect(remote.Addr()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if _, err := local.FetchChunk("node-002", "abc"); !errors.Is(err, ErrChunkTimeout) {
+		t.Errorf("Expected ErrChunkTimeout, got %v", err)
+	}
+	if len(local.pending) != 0 {
+		t.Errorf("Expected timed out request to be cleaned up, got %d pending", len(local.pending))
+	}
+}
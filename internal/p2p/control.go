@@ -0,0 +1,37 @@
+package p2p
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ControlHandler serves the local control endpoints used by node commands:
+// GET /peers lists the registry and POST /announce announces the node to its peers
+func ControlHandler(host *Host, registry *NodeRegistry) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/peers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"peers": registry.Peers()})
+	})
+
+	mux.HandleFunc("/announce", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		reached := host.Announce()
+		writeJSON(w, map[string]interface{}{"reached": reached, "peers": registry.Peers()})
+	})
+
+	return mux
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
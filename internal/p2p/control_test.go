@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestControlAnnounce(t *testing.T) {
+	bootstrap := newTestHost(t, "node-001")
+	host := newTestHost(t, "node-002", bootstrap.Addr())
+	registry, _ := newTestRegistry(time.Minute)
+	registry.Attach(host)
+
+	handler := ControlHandler(host, registry)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/announce", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var result struct {
+		Reached int `json:"reached"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.Reached != 1 {
+		t.Errorf("Expected announcement to reach 1 peer, got %d", result.Reached)
+	}
+
+	waitFor(t, "bootstrap peer registration", func() bool {
+		_, exists := registry.Get("node-001")
+		return exists
+	})
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/peers", nil))
+
+	var peers struct {
+		Peers []types.NodeInfo `json:"peers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &peers); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(peers.Peers) != 1 || peers.Peers[0].ID != "node-001" {
+		t.Errorf("Expected node-001 in peers, got %+v", peers.Peers)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/announce", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET /announce, got %d", w.Code)
+	}
+}
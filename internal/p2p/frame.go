@@ -0,0 +1,70 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// frameHeaderSize is the size of the big-endian length that precedes every message
+const frameHeaderSize = 4
+
+// defaultMaxMessageSize is the largest message accepted when no limit is configured,
+// enough for a base64-encoded chunk of the largest allowed chunk size
+const defaultMaxMessageSize = 96 * 1024 * 1024
+
+// ErrMessageTooLarge is returned when a peer announces a message over the size limit
+var ErrMessageTooLarge = errors.New("message too large")
+
+// writeFrame writes msg as one length-prefixed frame
+func writeFrame(w io.Writer, msg *types.NetworkMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[frameHeaderSize:], payload)
+	_, err = w.Write(frame)
+	return err
+}
+
+// frameReader reads length-prefixed messages, refusing any larger than maxSize before
+// reading their payload
+type frameReader struct {
+	r       *bufio.Reader
+	maxSize int64
+}
+
+// newFrameReader returns a reader of the messages sent over r
+func newFrameReader(r io.Reader, maxSize int64) *frameReader {
+	return &frameReader{r: bufio.NewReader(r), maxSize: maxSize}
+}
+
+// read reads the next message into msg
+func (fr *frameReader) read(msg *types.NetworkMessage) error {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(fr.r, header[:]); err != nil {
+		return err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[:]))
+	if size > fr.maxSize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrMessageTooLarge, size, fr.maxSize)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(fr.r, payload); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(payload, msg); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+	return nil
+}
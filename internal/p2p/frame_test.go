@@ -0,0 +1,48 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, from := range []string{"node-001", "node-002"} {
+		if err := writeFrame(&buf, &types.NetworkMessage{Type: types.MessageTypeHeartbeat, From: from}); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+
+	reader := newFrameReader(&buf, defaultMaxMessageSize)
+	for _, want := range []string{"node-001", "node-002"} {
+		var msg types.NetworkMessage
+		if err := reader.read(&msg); err != nil {
+			t.Fatalf("Failed to read frame: %v", err)
+		}
+		if msg.From != want || msg.Type != types.MessageTypeHeartbeat {
+			t.Errorf("Expected heartbeat from %s, got %s from %s", want, msg.Type, msg.From)
+		}
+	}
+
+	var msg types.NetworkMessage
+	if err := reader.read(&msg); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF after the last frame, got %v", err)
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	// Only the header is sent: the size is refused before any payload is read
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:], 1025)
+
+	var msg types.NetworkMessage
+	err := newFrameReader(bytes.NewReader(header[:]), 1024).read(&msg)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Heartbeat is the payload of heartbeat messages, describing the sender's current state
+type Heartbeat struct {
+	Status       types.NodeStatus `json:"status"`
+	StorageUsed  int64            `json:"storage_used"`
+	StorageTotal int64            `json:"storage_total"` // 0 for unlimited
+	SentAt       time.Time        `json:"sent_at"`
+}
+
+// broadcaster sends a message to every connected peer
+type broadcaster interface {
+	Broadcast(msgType types.MessageType, data interface{})
+}
+
+// Heartbeater periodically tells every connected peer that the node is alive, along with
+// its storage usage and status
+type Heartbeater struct {
+	host         broadcaster
+	usage        func() (int64, error)
+	storageTotal int64
+	logger       *logrus.Logger
+
+	status types.NodeStatus
+	mu     sync.Mutex
+
+	now       func() time.Time
+	newTicker func(interval time.Duration) (<-chan time.Time, func())
+}
+
+// NewHeartbeater creates a heartbeater sending through host. usage reports the bytes the
+// node currently stores and storageTotal the bytes it may store, 0 for unlimited.
+func NewHeartbeater(host broadcaster, usage func() (int64, error), storageTotal int64, logger *logrus.Logger) *Heartbeater {
+	return &Heartbeater{
+		host:         host,
+		usage:        usage,
+		storageTotal: storageTotal,
+		logger:       logger,
+		status:       types.NodeStatusOnline,
+		now:          time.Now,
+		newTicker: func(interval time.Duration) (<-chan time.Time, func()) {
+			ticker := time.NewTicker(interval)
+			return ticker.C, ticker.Stop
+		},
+	}
+}
+
+// SetStatus sets the status reported in later heartbeats
+func (hb *Heartbeater) SetStatus(status types.NodeStatus) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	hb.status = status
+}
+
+// Heartbeat builds the payload of the next heartbeat. A failure to read the storage
+// usage is logged and reported as no usage rather than skipping the heartbeat.
+func (hb *Heartbeater) Heartbeat() Heartbeat {
+	used, err := hb.usage()
+	if err != nil {
+		hb.logger.WithError(err).Warn("Failed to get storage usage for heartbeat")
+		used = 0
+	}
+
+	hb.mu.Lock()
+	status := hb.status
+	hb.mu.Unlock()
+
+	return Heartbeat{
+		Status:       status,
+		StorageUsed:  used,
+		StorageTotal: hb.storageTotal,
+		SentAt:       hb.now(),
+	}
+}
+
+// Run sends a heartbeat to every connected peer every interval until stop is closed
+func (hb *Heartbeater) Run(interval time.Duration, stop <-chan struct{}) {
+	tick, stopTicker := hb.newTicker(interval)
+	defer stopTicker()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+			hb.host.Broadcast(types.MessageTypeHeartbeat, hb.Heartbeat())
+		}
+	}
+}
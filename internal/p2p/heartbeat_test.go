@@ -0,0 +1,112 @@
+package p2p
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// recordingBroadcaster records the messages it is asked to broadcast
+type recordingBroadcaster struct {
+	sent chan interface{}
+}
+
+func (b *recordingBroadcaster) Broadcast(msgType types.MessageType, data interface{}) {
+	if msgType == types.MessageTypeHeartbeat {
+		b.sent <- data
+	}
+}
+
+// newTestHeartbeater creates a heartbeater with a controllable clock and ticker. The
+// returned channel delivers ticks, and the interval the ticker was created with is
+// written to interval.
+func newTestHeartbeater(usage func() (int64, error), interval *time.Duration) (*Heartbeater, *recordingBroadcaster, *time.Time, chan time.Time) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	host := &recordingBroadcaster{sent: make(chan interface{}, 1)}
+	heartbeater := NewHeartbeater(host, usage, 1<<30, logger)
+
+	now := time.Unix(1700000000, 0)
+	heartbeater.now = func() time.Time { return now }
+
+	ticks := make(chan time.Time)
+	heartbeater.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		*interval = d
+		return ticks, func() {}
+	}
+	return heartbeater, host, &now, ticks
+}
+
+func TestHeartbeatPayload(t *testing.T) {
+	used := int64(512)
+	var usageErr error
+	var interval time.Duration
+	heartbeater, _, now, _ := newTestHeartbeater(func() (int64, error) { return used, usageErr }, &interval)
+
+	tests := []struct {
+		name   string
+		status types.NodeStatus
+		err    error
+		want   Heartbeat
+	}{
+		{"online", types.NodeStatusOnline, nil, Heartbeat{Status: types.NodeStatusOnline, StorageUsed: 512, StorageTotal: 1 << 30, SentAt: *now}},
+		{"maintenance", types.NodeStatusMaintenance, nil, Heartbeat{Status: types.NodeStatusMaintenance, StorageUsed: 512, StorageTotal: 1 << 30, SentAt: *now}},
+		{"usage unavailable", types.NodeStatusOnline, errors.New("disk gone"), Heartbeat{Status: types.NodeStatusOnline, StorageTotal: 1 << 30, SentAt: *now}},
+	}
+
+	for _, tt := range tests {
+		heartbeater.SetStatus(tt.status)
+		usageErr = tt.err
+		if got := heartbeater.Heartbeat(); got != tt.want {
+			t.Errorf("%s: Expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHeartbeaterRun(t *testing.T) {
+	var interval time.Duration
+	heartbeater, host, now, ticks := newTestHeartbeater(func() (int64, error) { return 7, nil }, &interval)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		heartbeater.Run(15*time.Second, stop)
+		close(done)
+	}()
+
+	// Every tick sends one heartbeat stamped with the clock's time
+	for i := 0; i < 3; i++ {
+		*now = now.Add(15 * time.Second)
+		ticks <- *now
+
+		select {
+		case data := <-host.sent:
+			beat := data.(Heartbeat)
+			if !beat.SentAt.Equal(*now) || beat.StorageUsed != 7 {
+				t.Errorf("Expected heartbeat at %v reporting 7 bytes, got %+v", *now, beat)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for heartbeat")
+		}
+	}
+	if interval != 15*time.Second {
+		t.Errorf("Expected ticker interval 15s, got %s", interval)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Run to return once stopped")
+	}
+	select {
+	case data := <-host.sent:
+		t.Errorf("Expected no heartbeat without a tick, got %+v", data)
+	default:
+	}
+}
@@ -0,0 +1,530 @@
+// Package p2p connects storage nodes to each other and exchanges NetworkMessages between them.
+//
+// Nodes talk over plain TCP, sending JSON-encoded NetworkMessages each prefixed with its
+// length; a message over the configured size limit closes the connection before its body
+// is read. Every connection opens with a node announcement from each side so both ends know who they
+// are talking to; heartbeats sent by a Heartbeater then keep peers informed that the node
+// is alive, and a connection silent for longer than the peer timeout is closed. Node IDs are self-declared: nothing in the handshake proves that a peer owns
+// the ID it announces, so the network must only be reachable by trusted nodes.
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// handshakeTimeout bounds how long a new connection may take to announce itself
+const handshakeTimeout = 10 * time.Second
+
+// writeTimeout bounds how long sending one message may block on a slow peer
+const writeTimeout = 30 * time.Second
+
+// ErrPeerNotConnected is returned when sending to a peer without an open connection
+var ErrPeerNotConnected = errors.New("peer not connected")
+
+// Handler processes a message received from a peer. msg.From is the ID the sending peer
+// announced in its handshake. It is fixed for the connection, so a peer cannot send
+// messages under other IDs once connected, but it is not verified: a node may announce
+// any ID, including that of another node.
+type Handler func(msg *types.NetworkMessage)
+
+// Host is a node's endpoint in the P2P network
+type Host struct {
+	id             string
+	listenAddr     string
+	bootstrapPeers []string
+	maxPeers       int
+	maxMessageSize int64
+	idleTimeout    time.Duration // Silence after which a connection is closed, 0 for none
+	logger         *logrus.Logger
+
+	listener net.Listener
+	peers    map[string]*peerConn
+	handlers map[types.MessageType]Handler
+	mu       sync.RWMutex
+	wg       sync.WaitGroup
+	closed   chan struct{}
+}
+
+// peerConn is an open connection to a peer
+type peerConn struct {
+	info     types.NodeInfo
+	conn     net.Conn
+	outbound bool   // Whether this node dialed the connection
+	dialAddr string // Multiaddr dialed for outbound connections
+
+	writeMu sync.Mutex
+}
+
+// NewHost creates a host for the given node that has not started listening yet.
+// Connections are closed once silent for longer than the peer timeout, since heartbeats
+// arrive well within it.
+func NewHost(nodeID string, cfg config.P2PConfig, logger *logrus.Logger) *Host {
+	maxMessageSize := cfg.MaxMessageSize
+	if maxMessageSize <= 0 {
+		maxMessageSize = defaultMaxMessageSize
+	}
+
+	return &Host{
+		id:             nodeID,
+		listenAddr:     cfg.ListenAddr,
+		bootstrapPeers: cfg.BootstrapPeers,
+		maxPeers:       cfg.MaxPeers,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    cfg.PeerTimeout,
+		logger:         logger,
+		peers:          make(map[string]*peerConn),
+		handlers:       make(map[types.MessageType]Handler),
+		closed:         make(chan struct{}),
+	}
+}
+
+// ID returns the host's node ID
+func (h *Host) ID() string {
+	return h.id
+}
+
+// Handle registers the handler called for messages of the given type. Node
+// announcements and heartbeats update the peer table before the handler runs.
+func (h *Host) Handle(msgType types.MessageType, handler Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handlers[msgType] = handler
+}
+
+// Start begins accepting connections on the configured listen address
+func (h *Host) Start() error {
+	hostPort, _, err := ParseMultiaddr(h.listenAddr)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", hostPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", h.listenAddr, err)
+	}
+	h.listener = listener
+
+	h.wg.Add(1)
+	go h.acceptLoop()
+
+	h.logger.WithFields(logrus.Fields{
+		"node_id": h.id,
+		"address": h.Addr(),
+	}).Info("P2P host listening")
+	return nil
+}
+
+// Addr returns the multiaddr peers can dial to reach this host, including its node ID
+func (h *Host) Addr() string {
+	if h.listener == nil {
+		return ""
+	}
+	addr, _ := FormatMultiaddr(h.listener.Addr().String(), h.id)
+	return addr
+}
+
+// Close stops accepting connections and disconnects from every peer
+func (h *Host) Close() error {
+	select {
+	case <-h.closed:
+		return nil
+	default:
+	}
+	close(h.closed)
+
+	var err error
+	if h.listener != nil {
+		err = h.listener.Close()
+	}
+
+	h.mu.Lock()
+	for _, peer := range h.peers {
+		peer.conn.Close()
+	}
+	h.mu.Unlock()
+
+	h.wg.Wait()
+	return err
+}
+
+// Run connects to the bootstrap peers and redials those whose connections have dropped
+// every interval until stop is closed
+func (h *Host) Run(interval time.Duration, stop <-chan struct{}) {
+	h.connectBootstrapPeers()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-h.closed:
+			return
+		case <-ticker.C:
+			h.connectBootstrapPeers()
+		}
+	}
+}
+
+// Announce connects to any bootstrap peers not yet connected and sends this node's
+// announcement to every connected peer, returning how many peers were reached
+func (h *Host) Announce() int {
+	h.connectBootstrapPeers()
+
+	h.mu.RLock()
+	reached := len(h.peers)
+	h.mu.RUnlock()
+
+	h.Broadcast(types.MessageTypeNodeAnnouncement, h.announcement())
+	return reached
+}
+
+// connectBootstrapPeers dials every bootstrap peer not already connected
+func (h *Host) connectBootstrapPeers() {
+	h.mu.RLock()
+	connected := make(map[string]bool)
+	for _, peer := range h.peers {
+		if peer.dialAddr != "" {
+			connected[peer.dialAddr] = true
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, addr := range h.bootstrapPeers {
+		if connected[addr] {
+			continue
+		}
+		if _, err := h.Connect(addr); err != nil {
+			h.logger.WithError(err).WithField("address", addr).Warn("Failed to connect to bootstrap peer")
+		}
+	}
+}
+
+// Connect dials the peer at the given multiaddr and returns its node ID once both
+// sides have announced themselves
+func (h *Host) Connect(addr string) (string, error) {
+	hostPort, expectedID, err := ParseMultiaddr(addr)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.DialTimeout("tcp", hostPort, handshakeTimeout)
+	if err != nil {
+		return "", fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+
+	peer := &peerConn{conn: conn, outbound: true, dialAddr: addr}
+	reader, announcement, err := h.handshake(peer)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("handshake with %s failed: %w", addr, err)
+	}
+	if expectedID != "" && peer.info.ID != expectedID {
+		conn.Close()
+		return "", fmt.Errorf("peer at %s is %s, expected %s", addr, peer.info.ID, expectedID)
+	}
+
+	if !h.addPeer(peer) {
+		conn.Close()
+		return peer.info.ID, nil
+	}
+	h.dispatch(peer, announcement)
+
+	h.wg.Add(1)
+	go h.readLoop(peer, reader)
+	return peer.info.ID, nil
+}
+
+// acceptLoop handles inbound connections until the listener is closed
+func (h *Host) acceptLoop() {
+	defer h.wg.Done()
+
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			select {
+			case <-h.closed:
+			default:
+				h.logger.WithError(err).Error("Failed to accept P2P connection")
+			}
+			return
+		}
+
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+
+			peer := &peerConn{conn: conn}
+			reader, announcement, err := h.handshake(peer)
+			if err != nil {
+				h.logger.WithError(err).WithField("remote_addr", conn.RemoteAddr().String()).Warn("P2P handshake failed")
+				conn.Close()
+				return
+			}
+
+			if !h.addPeer(peer) {
+				conn.Close()
+				return
+			}
+			h.dispatch(peer, announcement)
+
+			h.wg.Add(1)
+			go h.readLoop(peer, reader)
+		}()
+	}
+}
+
+// handshake exchanges node announcements over a new connection, filling in peer.info.
+// It returns the reader for the rest of the connection and the peer's announcement.
+func (h *Host) handshake(peer *peerConn) (*frameReader, *types.NetworkMessage, error) {
+	peer.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer peer.conn.SetDeadline(time.Time{})
+
+	if err := h.send(peer, types.MessageTypeNodeAnnouncement, h.announcement()); err != nil {
+		return nil, nil, err
+	}
+
+	reader := newFrameReader(peer.conn, h.maxMessageSize)
+	var msg types.NetworkMessage
+	if err := reader.read(&msg); err != nil {
+		return nil, nil, fmt.Errorf("failed to read announcement: %w", err)
+	}
+	if msg.Type != types.MessageTypeNodeAnnouncement {
+		return nil, nil, fmt.Errorf("expected node announcement, got %s", msg.Type)
+	}
+
+	var info types.NodeInfo
+	if err := DecodeData(&msg, &info); err != nil {
+		return nil, nil, err
+	}
+	if info.ID == "" || info.ID != msg.From {
+		return nil, nil, fmt.Errorf("announcement has mismatched node ID %q", info.ID)
+	}
+	if info.ID == h.id {
+		return nil, nil, fmt.Errorf("connected to self")
+	}
+
+	// The announced address may be a wildcard, so dial back via the address the peer connected from
+	if remote, ok := peer.conn.RemoteAddr().(*net.TCPAddr); ok && info.Port > 0 {
+		info.Address, _ = FormatMultiaddr(net.JoinHostPort(remote.IP.String(), fmt.Sprint(info.Port)), info.ID)
+	}
+	info.Status = types.NodeStatusOnline
+	info.LastSeen = time.Now()
+	peer.info = info
+
+	return reader, &msg, nil
+}
+
+// announcement describes this node to peers
+func (h *Host) announcement() types.NodeInfo {
+	info := types.NodeInfo{ID: h.id, Address: h.Addr(), Status: types.NodeStatusOnline}
+	if h.listener != nil {
+		if addr, ok := h.listener.Addr().(*net.TCPAddr); ok {
+			info.Port = addr.Port
+		}
+	}
+	return info
+}
+
+// addPeer records a connected peer. When the peer is already connected, both ends keep
+// the connection dialed by the node with the smaller ID so simultaneous dials settle on
+// the same one. It returns false when the new connection should be dropped.
+func (h *Host) addPeer(peer *peerConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	select {
+	case <-h.closed:
+		return false
+	default:
+	}
+
+	existing, exists := h.peers[peer.info.ID]
+	if exists {
+		if !h.preferred(peer) {
+			return false
+		}
+		existing.conn.Close()
+	} else if h.maxPeers > 0 && len(h.peers) >= h.maxPeers {
+		h.logger.WithField("peer_id", peer.info.ID).Warn("Rejecting peer, too many connections")
+		return false
+	}
+
+	h.peers[peer.info.ID] = peer
+	h.logger.WithFields(logrus.Fields{
+		"peer_id":  peer.info.ID,
+		"address":  peer.info.Address,
+		"outbound": peer.outbound,
+	}).Info("Peer connected")
+	return true
+}
+
+// preferred reports whether a connection was dialed by the node with the smaller ID
+func (h *Host) preferred(peer *peerConn) bool {
+	if peer.outbound {
+		return h.id < peer.info.ID
+	}
+	return peer.info.ID < h.id
+}
+
+// readLoop dispatches messages from a peer until its connection closes, goes idle or
+// carries a malformed or oversized message
+func (h *Host) readLoop(peer *peerConn, reader *frameReader) {
+	defer h.wg.Done()
+	defer h.removePeer(peer)
+
+	for {
+		if h.idleTimeout > 0 {
+			peer.conn.SetReadDeadline(time.Now().Add(h.idleTimeout))
+		}
+
+		var msg types.NetworkMessage
+		if err := reader.read(&msg); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				h.logger.WithError(err).WithField("peer_id", peer.info.ID).Warn("Closing P2P connection")
+			}
+			return
+		}
+
+		// Keep to the identity announced in the handshake rather than one claimed per message
+		msg.From = peer.info.ID
+		h.dispatch(peer, &msg)
+	}
+}
+
+// dispatch updates the peer table for a message and passes it to its handler.
+// Announcements are handed on with the identity and address observed by this host
+// rather than those the peer claims.
+func (h *Host) dispatch(peer *peerConn, msg *types.NetworkMessage) {
+	h.mu.Lock()
+	peer.info.LastSeen = time.Now()
+	if msg.Type == types.MessageTypeNodeAnnouncement {
+		var info types.NodeInfo
+		if err := DecodeData(msg, &info); err == nil {
+			peer.info.PublicKey = info.PublicKey
+			peer.info.StorageUsed = info.StorageUsed
+			peer.info.StorageTotal = info.StorageTotal
+		}
+		msg.Data = peer.info
+	}
+	handler := h.handlers[msg.Type]
+	h.mu.Unlock()
+
+	h.logger.WithFields(logrus.Fields{
+		"peer_id": msg.From,
+		"type":    msg.Type.String(),
+	}).Debug("Received P2P message")
+
+	if handler != nil {
+		handler(msg)
+	}
+}
+
+// removePeer forgets a peer whose connection has closed
+func (h *Host) removePeer(peer *peerConn) {
+	peer.conn.Close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// A replacement connection may already be registered
+	if h.peers[peer.info.ID] == peer {
+		delete(h.peers, peer.info.ID)
+		h.logger.WithField("peer_id", peer.info.ID).Info("Peer disconnected")
+	}
+}
+
+// Peers returns the connected peers ordered by ID
+func (h *Host) Peers() []types.NodeInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	peers := make([]types.NodeInfo, 0, len(h.peers))
+	for _, peer := range h.peers {
+		peers = append(peers, peer.info)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID < peers[j].ID
+	})
+	return peers
+}
+
+// Send delivers a message to a connected peer
+func (h *Host) Send(peerID string, msgType types.MessageType, data interface{}) error {
+	h.mu.RLock()
+	peer, exists := h.peers[peerID]
+	h.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("%s: %w", peerID, ErrPeerNotConnected)
+	}
+
+	return h.send(peer, msgType, data)
+}
+
+// Broadcast delivers a message to every connected peer, logging failures
+func (h *Host) Broadcast(msgType types.MessageType, data interface{}) {
+	h.mu.RLock()
+	peers := make([]*peerConn, 0, len(h.peers))
+	for _, peer := range h.peers {
+		peers = append(peers, peer)
+	}
+	h.mu.RUnlock()
+
+	for _, peer := range peers {
+		if err := h.send(peer, msgType, data); err != nil {
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"peer_id": peer.info.ID,
+				"type":    msgType.String(),
+			}).Warn("Failed to send P2P message")
+		}
+	}
+}
+
+// send writes a message to a peer connection
+func (h *Host) send(peer *peerConn, msgType types.MessageType, data interface{}) error {
+	msg := types.NetworkMessage{
+		Type:      msgType,
+		From:      h.id,
+		To:        peer.info.ID,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	peer.writeMu.Lock()
+	defer peer.writeMu.Unlock()
+
+	// The handshake sets its own deadline, which a write deadline here would replace
+	if peer.info.ID != "" {
+		peer.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	if err := writeFrame(peer.conn, &msg); err != nil {
+		return fmt.Errorf("failed to send %s: %w", msgType, err)
+	}
+	return nil
+}
+
+// DecodeData decodes the payload of a message into v. Payloads of received messages
+// arrive as generic JSON values, so they are re-encoded and decoded into v's type.
+func DecodeData(msg *types.NetworkMessage, v interface{}) error {
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", msg.Type, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s payload: %w", msg.Type, err)
+	}
+	return nil
+}
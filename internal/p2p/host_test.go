@@ -0,0 +1,232 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestHost starts a host listening on a random local port
+func newTestHost(t *testing.T, nodeID string, bootstrapPeers ...string) *Host {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	host := NewHost(nodeID, config.P2PConfig{
+		ListenAddr:     "/ip4/127.0.0.1/tcp/0",
+		BootstrapPeers: bootstrapPeers,
+		MaxPeers:       10,
+	}, logger)
+	if err := host.Start(); err != nil {
+		t.Fatalf("Failed to start host %s: %v", nodeID, err)
+	}
+	t.Cleanup(func() { host.Close() })
+	return host
+}
+
+// waitFor polls cond until it holds or the timeout expires
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// hasPeer reports whether host is connected to the peer with the given ID
+func hasPeer(host *Host, peerID string) bool {
+	for _, peer := range host.Peers() {
+		if peer.ID == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHostsConnectAndExchangeHeartbeat(t *testing.T) {
+	first := newTestHost(t, "node-001")
+
+	heartbeats := make(chan *types.NetworkMessage, 1)
+	first.Handle(types.MessageTypeHeartbeat, func(msg *types.NetworkMessage) {
+		heartbeats <- msg
+	})
+
+	second := newTestHost(t, "node-002", first.Addr())
+	stop := make(chan struct{})
+	defer close(stop)
+	go second.Run(20*time.Millisecond, stop)
+	heartbeater := NewHeartbeater(second, func() (int64, error) { return 42, nil }, 0, second.logger)
+	go heartbeater.Run(20*time.Millisecond, stop)
+
+	waitFor(t, "hosts to connect", func() bool {
+		return hasPeer(first, "node-002") && hasPeer(second, "node-001")
+	})
+
+	select {
+	case msg := <-heartbeats:
+		if msg.From != "node-002" || msg.To != "node-001" {
+			t.Errorf("Expected heartbeat from node-002 to node-001, got %s to %s", msg.From, msg.To)
+		}
+		var beat Heartbeat
+		if err := DecodeData(msg, &beat); err != nil || beat.StorageUsed != 42 {
+			t.Errorf("Expected heartbeat reporting 42 bytes used, got %+v (%v)", beat, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for heartbeat")
+	}
+
+	// The inbound side learns a dialable address for the peer
+	peer := first.Peers()[0]
+	if peer.Address != second.Addr() || peer.Status != types.NodeStatusOnline {
+		t.Errorf("Expected online peer at %s, got %+v", second.Addr(), peer)
+	}
+}
+
+func TestHostSendTypedPayload(t *testing.T) {
+	first := newTestHost(t, "node-001")
+	second := newTestHost(t, "node-002")
+
+	received := make(chan types.NodeInfo, 2)
+	second.Handle(types.MessageTypeNodeAnnouncement, func(msg *types.NetworkMessage) {
+		var info types.NodeInfo
+		if err := DecodeData(msg, &info); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		received <- info
+	})
+
+	peerID, err := first.Connect(second.Addr())
+	if err != nil || peerID != "node-002" {
+		t.Fatalf("Expected to connect to node-002, got %q: %v", peerID, err)
+	}
+
+	if err := first.Send("node-002", types.MessageTypeNodeAnnouncement, types.NodeInfo{ID: "node-001", StorageUsed: 42}); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	// The handshake announcement is delivered first, then the one sent explicitly
+	for _, expected := range []int64{0, 42} {
+		select {
+		case info := <-received:
+			if info.ID != "node-001" || info.StorageUsed != expected {
+				t.Errorf("Expected node-001 using %d bytes, got %s using %d", expected, info.ID, info.StorageUsed)
+			}
+			if info.Address != first.Addr() {
+				t.Errorf("Expected observed address %s, got %s", first.Addr(), info.Address)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+	}
+
+	if err := first.Send("node-999", types.MessageTypeHeartbeat, nil); err == nil {
+		t.Error("Expected error sending to unknown peer")
+	}
+}
+
+func TestHostRejectsWrongPeerID(t *testing.T) {
+	first := newTestHost(t, "node-001")
+	second := newTestHost(t, "node-002")
+
+	addr, _ := FormatMultiaddr(second.listener.Addr().String(), "node-003")
+	if _, err := first.Connect(addr); err == nil {
+		t.Error("Expected connecting to the wrong peer ID to fail")
+	}
+
+	if _, err := first.Connect(first.Addr()); err == nil {
+		t.Error("Expected connecting to self to fail")
+	}
+}
+
+func TestHostSimultaneousConnect(t *testing.T) {
+	first := newTestHost(t, "node-001")
+	second := newTestHost(t, "node-002")
+
+	done := make(chan struct{})
+	go func() {
+		first.Connect(second.Addr())
+		close(done)
+	}()
+	second.Connect(first.Addr())
+	<-done
+
+	waitFor(t, "a single connection to survive", func() bool {
+		return hasPeer(first, "node-002") && hasPeer(second, "node-001")
+	})
+
+	// Both ends settle on the same connection, so messages still flow
+	received := make(chan struct{}, 1)
+	second.Handle(types.MessageTypeHeartbeat, func(msg *types.NetworkMessage) {
+		received <- struct{}{}
+	})
+	waitFor(t, "heartbeat delivery", func() bool {
+		first.Send("node-002", types.MessageTypeHeartbeat, nil)
+		select {
+		case <-received:
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	})
+}
+
+// dialRaw opens a connection to host and completes the handshake as nodeID, returning
+// the connection for the test to write raw frames to
+func dialRaw(t *testing.T, host *Host, nodeID string) net.Conn {
+	t.Helper()
+
+	hostPort, _, _ := ParseMultiaddr(host.Addr())
+	conn, err := net.Dial("tcp", hostPort)
+	if err != nil {
+		t.Fatalf("Failed to dial host: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	announcement := &types.NetworkMessage{Type: types.MessageTypeNodeAnnouncement, From: nodeID, Data: types.NodeInfo{ID: nodeID}}
+	if err := writeFrame(conn, announcement); err != nil {
+		t.Fatalf("Failed to announce: %v", err)
+	}
+	waitFor(t, "raw peer to connect", func() bool { return hasPeer(host, nodeID) })
+	return conn
+}
+
+func TestHostDropsOversizedMessage(t *testing.T) {
+	host := newTestHost(t, "node-001")
+	conn := dialRaw(t, host, "node-002")
+
+	// Announce a frame far over the limit without sending its body
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:], uint32(host.maxMessageSize+1))
+	conn.Write(header[:])
+
+	waitFor(t, "oversized peer to be dropped", func() bool { return !hasPeer(host, "node-002") })
+}
+
+func TestHostDropsIdlePeer(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	host := NewHost("node-001", config.P2PConfig{
+		ListenAddr:  "/ip4/127.0.0.1/tcp/0",
+		MaxPeers:    10,
+		PeerTimeout: 100 * time.Millisecond,
+	}, logger)
+	if err := host.Start(); err != nil {
+		t.Fatalf("Failed to start host: %v", err)
+	}
+	t.Cleanup(func() { host.Close() })
+
+	dialRaw(t, host, "node-002")
+	waitFor(t, "silent peer to be dropped", func() bool { return !hasPeer(host, "node-002") })
+}
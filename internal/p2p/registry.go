@@ -0,0 +1,196 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// initialReputation is the reputation of a newly seen peer, midway between 0 and 1
+	initialReputation = 0.5
+
+	// reputationWeight is how far each chunk request moves reputation towards 1 on
+	// success or 0 on failure
+	reputationWeight = 0.1
+)
+
+// NodeRegistry tracks the known peers of a node, their liveness and reputation
+type NodeRegistry struct {
+	timeout time.Duration
+	nodes   map[string]*types.NodeInfo
+	logger  *logrus.Logger
+	now     func() time.Time
+	mu      sync.RWMutex
+}
+
+// NewNodeRegistry creates a registry that marks peers offline when nothing is heard
+// from them for timeout
+func NewNodeRegistry(timeout time.Duration, logger *logrus.Logger) *NodeRegistry {
+	return &NodeRegistry{
+		timeout: timeout,
+		nodes:   make(map[string]*types.NodeInfo),
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Attach records the peers a host learns about through announcements and heartbeats
+func (r *NodeRegistry) Attach(host *Host) {
+	host.Handle(types.MessageTypeNodeAnnouncement, func(msg *types.NetworkMessage) {
+		var info types.NodeInfo
+		if err := DecodeData(msg, &info); err != nil {
+			r.logger.WithError(err).WithField("peer_id", msg.From).Warn("Ignoring invalid node announcement")
+			return
+		}
+		info.ID = msg.From
+		r.Register(info)
+	})
+	host.Handle(types.MessageTypeHeartbeat, func(msg *types.NetworkMessage) {
+		r.Heartbeat(msg.From)
+
+		var beat Heartbeat
+		if msg.Data != nil && DecodeData(msg, &beat) == nil {
+			r.recordStorage(msg.From, beat.StorageUsed, beat.StorageTotal)
+		}
+	})
+}
+
+// Register records an announced peer as online, keeping its reputation if already known
+func (r *NodeRegistry) Register(info types.NodeInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info.Status = types.NodeStatusOnline
+	info.LastSeen = r.now()
+	info.Reputation = initialReputation
+	if existing, exists := r.nodes[info.ID]; exists {
+		info.Reputation = existing.Reputation
+		if info.Address == "" {
+			info.Address = existing.Address
+		}
+	} else {
+		r.logger.WithFields(logrus.Fields{
+			"peer_id": info.ID,
+			"address": info.Address,
+		}).Info("Registered new peer")
+	}
+
+	r.nodes[info.ID] = &info
+}
+
+// Heartbeat records that a peer is alive, registering it if it is unknown
+func (r *NodeRegistry) Heartbeat(peerID string) {
+	r.mu.Lock()
+	node, exists := r.nodes[peerID]
+	if exists {
+		if node.Status == types.NodeStatusOffline {
+			r.logger.WithField("peer_id", peerID).Info("Peer back online")
+		}
+		node.Status = types.NodeStatusOnline
+		node.LastSeen = r.now()
+	}
+	r.mu.Unlock()
+
+	if !exists {
+		r.Register(types.NodeInfo{ID: peerID})
+	}
+}
+
+// recordStorage records the storage usage a known peer reported
+func (r *NodeRegistry) recordStorage(peerID string, used, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if node, exists := r.nodes[peerID]; exists {
+		node.StorageUsed = used
+		node.StorageTotal = total
+	}
+}
+
+// RecordSuccess raises a peer's reputation after it served a chunk request
+func (r *NodeRegistry) RecordSuccess(peerID string) {
+	r.adjustReputation(peerID, 1)
+}
+
+// RecordFailure lowers a peer's reputation after a chunk request to it failed
+func (r *NodeRegistry) RecordFailure(peerID string) {
+	r.adjustReputation(peerID, 0)
+}
+
+// adjustReputation moves a known peer's reputation part of the way towards target
+func (r *NodeRegistry) adjustReputation(peerID string, target float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if node, exists := r.nodes[peerID]; exists {
+		node.Reputation += (target - node.Reputation) * reputationWeight
+	}
+}
+
+// CheckTimeouts marks peers offline that have not been heard from within the timeout
+// and returns their IDs
+func (r *NodeRegistry) CheckTimeouts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var expired []string
+	for id, node := range r.nodes {
+		if node.Status == types.NodeStatusOnline && now.Sub(node.LastSeen) > r.timeout {
+			node.Status = types.NodeStatusOffline
+			expired = append(expired, id)
+			r.logger.WithFields(logrus.Fields{
+				"peer_id":   id,
+				"last_seen": node.LastSeen,
+			}).Warn("Peer went offline")
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// Run checks for timed out peers every interval until stop is closed
+func (r *NodeRegistry) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.CheckTimeouts()
+		}
+	}
+}
+
+// Get returns a known peer
+func (r *NodeRegistry) Get(peerID string) (types.NodeInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	node, exists := r.nodes[peerID]
+	if !exists {
+		return types.NodeInfo{}, false
+	}
+	return *node, true
+}
+
+// Peers returns every known peer ordered by ID
+func (r *NodeRegistry) Peers() []types.NodeInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	peers := make([]types.NodeInfo, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		peers = append(peers, *node)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID < peers[j].ID
+	})
+	return peers
+}
@@ -0,0 +1,124 @@
+package p2p
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestRegistry creates a registry with a controllable clock
+func newTestRegistry(timeout time.Duration) (*NodeRegistry, *time.Time) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	now := time.Unix(1700000000, 0)
+	registry := NewNodeRegistry(timeout, logger)
+	registry.now = func() time.Time { return now }
+	return registry, &now
+}
+
+func TestRegistryRegister(t *testing.T) {
+	registry, _ := newTestRegistry(time.Minute)
+
+	registry.Register(types.NodeInfo{ID: "node-002", Address: "/ip4/10.0.0.2/tcp/4001/p2p/node-002", StorageUsed: 10})
+	registry.Register(types.NodeInfo{ID: "node-001"})
+
+	peers := registry.Peers()
+	if len(peers) != 2 || peers[0].ID != "node-001" || peers[1].ID != "node-002" {
+		t.Fatalf("Expected peers node-001 and node-002, got %+v", peers)
+	}
+
+	peer := peers[1]
+	if peer.Status != types.NodeStatusOnline || peer.Reputation != initialReputation || peer.StorageUsed != 10 {
+		t.Errorf("Expected online peer with initial reputation, got %+v", peer)
+	}
+
+	// Re-announcing updates details but keeps the earned reputation and known address
+	registry.RecordSuccess("node-002")
+	earned, _ := registry.Get("node-002")
+	registry.Register(types.NodeInfo{ID: "node-002", StorageUsed: 20})
+
+	peer, _ = registry.Get("node-002")
+	if peer.Reputation != earned.Reputation || peer.StorageUsed != 20 || peer.Address != "/ip4/10.0.0.2/tcp/4001/p2p/node-002" {
+		t.Errorf("Expected re-announced peer to keep reputation and address, got %+v", peer)
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	registry, now := newTestRegistry(30 * time.Second)
+
+	registry.Register(types.NodeInfo{ID: "node-002"})
+	registry.Register(types.NodeInfo{ID: "node-003"})
+
+	*now = now.Add(20 * time.Second)
+	registry.Heartbeat("node-003")
+	if expired := registry.CheckTimeouts(); len(expired) != 0 {
+		t.Errorf("Expected no peers to expire yet, got %v", expired)
+	}
+
+	*now = now.Add(15 * time.Second)
+	expired := registry.CheckTimeouts()
+	if len(expired) != 1 || expired[0] != "node-002" {
+		t.Fatalf("Expected node-002 to expire, got %v", expired)
+	}
+	if peer, _ := registry.Get("node-002"); peer.Status != types.NodeStatusOffline {
+		t.Errorf("Expected node-002 offline, got %s", peer.Status)
+	}
+	if peer, _ := registry.Get("node-003"); peer.Status != types.NodeStatusOnline {
+		t.Errorf("Expected node-003 still online, got %s", peer.Status)
+	}
+
+	// Offline peers are only reported once, and come back with a heartbeat
+	if expired := registry.CheckTimeouts(); len(expired) != 0 {
+		t.Errorf("Expected offline peer not to expire again, got %v", expired)
+	}
+	registry.Heartbeat("node-002")
+	if peer, _ := registry.Get("node-002"); peer.Status != types.NodeStatusOnline || !peer.LastSeen.Equal(*now) {
+		t.Errorf("Expected heartbeat to bring node-002 back online, got %+v", peer)
+	}
+}
+
+func TestRegistryReputation(t *testing.T) {
+	registry, _ := newTestRegistry(time.Minute)
+	registry.Register(types.NodeInfo{ID: "node-002"})
+
+	previous := initialReputation
+	for i := 0; i < 5; i++ {
+		registry.RecordFailure("node-002")
+		peer, _ := registry.Get("node-002")
+		if peer.Reputation >= previous || peer.Reputation < 0 {
+			t.Fatalf("Expected failure %d to lower reputation below %f, got %f", i, previous, peer.Reputation)
+		}
+		previous = peer.Reputation
+	}
+
+	registry.RecordSuccess("node-002")
+	if peer, _ := registry.Get("node-002"); peer.Reputation <= previous || peer.Reputation > 1 {
+		t.Errorf("Expected success to raise reputation above %f, got %f", previous, peer.Reputation)
+	}
+
+	// Outcomes for unknown peers are ignored
+	registry.RecordFailure("node-999")
+	if _, exists := registry.Get("node-999"); exists {
+		t.Error("Expected unknown peer to stay unregistered")
+	}
+}
+
+func TestRegistryAttach(t *testing.T) {
+	first := newTestHost(t, "node-001")
+	registry, _ := newTestRegistry(time.Minute)
+	registry.Attach(first)
+
+	second := newTestHost(t, "node-002")
+	if _, err := second.Connect(first.Addr()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	waitFor(t, "peer registration", func() bool {
+		peer, exists := registry.Get("node-002")
+		return exists && peer.Address == second.Addr()
+	})
+}
@@ -0,0 +1,251 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrSyncTimeout is returned when a peer does not answer a sync request in time
+var ErrSyncTimeout = errors.New("sync request timed out")
+
+// SyncRequest is the payload of a MessageTypeSyncRequest message, asking a peer for
+// the chunks it holds
+type SyncRequest struct {
+	RequestID string `json:"request_id"`
+}
+
+// SyncResponse is the payload of a MessageTypeSyncResponse message
+type SyncResponse struct {
+	RequestID string   `json:"request_id"`
+	ChunkIDs  []string `json:"chunk_ids"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// SyncService keeps a node's chunks converged with its peers through anti-entropy:
+// each round it asks a few peers which chunks they hold and pulls the ones it lacks
+type SyncService struct {
+	chunks  *ChunkService
+	fanout  int
+	timeout time.Duration
+	filter  func(chunkID string) bool
+	logger  *logrus.Logger
+
+	pending map[string]*pendingSync
+	rounds  sync.Mutex // Serializes sync rounds
+	mu      sync.Mutex
+}
+
+// pendingSync is a sync request awaiting its response
+type pendingSync struct {
+	peerID    string
+	responses chan SyncResponse
+}
+
+// NewSyncService creates a sync service that compares chunk sets with up to fanout
+// peers per round and pulls missing chunks through chunks
+func NewSyncService(chunks *ChunkService, fanout int, logger *logrus.Logger) *SyncService {
+	service := &SyncService{
+		chunks:  chunks,
+		fanout:  fanout,
+		timeout: defaultChunkRequestTimeout,
+		logger:  logger,
+		pending: make(map[string]*pendingSync),
+	}
+
+	chunks.host.Handle(types.MessageTypeSyncRequest, service.handleRequest)
+	chunks.host.Handle(types.MessageTypeSyncResponse, service.handleResponse)
+	return service
+}
+
+// SetFilter limits syncing to the chunks this node is responsible for. By default every
+// chunk held by a peer is pulled.
+func (s *SyncService) SetFilter(responsible func(chunkID string) bool) {
+	s.filter = responsible
+}
+
+// Sync runs one anti-entropy round against up to fanout randomly chosen peers and
+// returns how many chunks were pulled
+func (s *SyncService) Sync() int {
+	s.rounds.Lock()
+	defer s.rounds.Unlock()
+
+	// Peers whose circuit breaker is open are left out so the round goes to healthy ones
+	var peers []types.NodeInfo
+	for _, peer := range s.chunks.host.Peers() {
+		if s.chunks.ready(peer.ID) {
+			peers = append(peers, peer)
+		}
+	}
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if s.fanout > 0 && len(peers) > s.fanout {
+		peers = peers[:s.fanout]
+	}
+
+	pulled := 0
+	for _, peer := range peers {
+		n, err := s.syncWith(peer.ID)
+		pulled += n
+		if err != nil {
+			s.logger.WithError(err).WithField("peer_id", peer.ID).Warn("Failed to sync with peer")
+		}
+	}
+	return pulled
+}
+
+// syncWith pulls the chunks a peer holds that are missing locally
+func (s *SyncService) syncWith(peerID string) (int, error) {
+	remote, err := s.requestChunkIDs(peerID)
+	if err != nil {
+		return 0, err
+	}
+
+	local, err := s.chunks.storage.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list local chunks: %w", err)
+	}
+	held := make(map[string]bool, len(local))
+	for _, chunkID := range local {
+		held[chunkID] = true
+	}
+
+	pulled := 0
+	for _, chunkID := range remote {
+		if held[chunkID] || (s.filter != nil && !s.filter(chunkID)) {
+			continue
+		}
+
+		data, err := s.chunks.FetchChunk(peerID, chunkID)
+		if errors.Is(err, ErrCircuitOpen) {
+			// The peer kept failing; the rest can come from healthy peers
+			return pulled, err
+		}
+		if err != nil {
+			// Keep going: the chunk can still come from another peer or a later round
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"peer_id":  peerID,
+				"chunk_id": chunkID,
+			}).Warn("Failed to pull missing chunk")
+			continue
+		}
+		if err := s.chunks.storage.Store(context.Background(), chunkID, data); err != nil {
+			return pulled, fmt.Errorf("failed to store chunk %s: %w", chunkID, err)
+		}
+		held[chunkID] = true
+		pulled++
+	}
+
+	if pulled > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"peer_id": peerID,
+			"pulled":  pulled,
+		}).Info("Pulled missing chunks from peer")
+	}
+	return pulled, nil
+}
+
+// requestChunkIDs asks a peer for the IDs of the chunks it holds
+func (s *SyncService) requestChunkIDs(peerID string) ([]string, error) {
+	requestID, err := utils.GenerateRandomID(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate request ID: %w", err)
+	}
+
+	pending := &pendingSync{peerID: peerID, responses: make(chan SyncResponse, 1)}
+	s.mu.Lock()
+	s.pending[requestID] = pending
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, requestID)
+		s.mu.Unlock()
+	}()
+
+	if err := s.chunks.host.Send(peerID, types.MessageTypeSyncRequest, SyncRequest{RequestID: requestID}); err != nil {
+		return nil, fmt.Errorf("failed to request chunk list from %s: %w", peerID, err)
+	}
+
+	select {
+	case response := <-pending.responses:
+		if response.Error != "" {
+			return nil, fmt.Errorf("peer %s failed to list chunks: %s", peerID, response.Error)
+		}
+		return response.ChunkIDs, nil
+	case <-time.After(s.timeout):
+		return nil, fmt.Errorf("chunk list from %s: %w", peerID, ErrSyncTimeout)
+	}
+}
+
+// Run syncs with peers every interval until stop is closed
+func (s *SyncService) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Sync()
+		}
+	}
+}
+
+// handleRequest answers a peer's sync request with the chunks held locally
+func (s *SyncService) handleRequest(msg *types.NetworkMessage) {
+	var request SyncRequest
+	if err := DecodeData(msg, &request); err != nil {
+		s.logger.WithError(err).WithField("peer_id", msg.From).Warn("Ignoring invalid sync request")
+		return
+	}
+
+	go func() {
+		response := SyncResponse{RequestID: request.RequestID}
+		chunkIDs, err := s.chunks.storage.List()
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to list chunks for sync")
+			response.Error = "failed to list chunks"
+		} else {
+			response.ChunkIDs = chunkIDs
+		}
+
+		if err := s.chunks.host.Send(msg.From, types.MessageTypeSyncResponse, response); err != nil {
+			s.logger.WithError(err).WithField("peer_id", msg.From).Warn("Failed to send sync response")
+		}
+	}()
+}
+
+// handleResponse delivers a sync response to the request waiting for it
+func (s *SyncService) handleResponse(msg *types.NetworkMessage) {
+	var response SyncResponse
+	if err := DecodeData(msg, &response); err != nil {
+		s.logger.WithError(err).WithField("peer_id", msg.From).Warn("Ignoring invalid sync response")
+		return
+	}
+
+	s.mu.Lock()
+	pending, exists := s.pending[response.RequestID]
+	s.mu.Unlock()
+
+	// Only the peer that was asked may answer
+	if !exists || pending.peerID != msg.From {
+		s.logger.WithFields(logrus.Fields{
+			"peer_id":    msg.From,
+			"request_id": response.RequestID,
+		}).Debug("Ignoring unexpected sync response")
+		return
+	}
+
+	select {
+	case pending.responses <- response:
+	default:
+	}
+}
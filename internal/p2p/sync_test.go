@@ -0,0 +1,143 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/storage"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestSyncService starts a node with its own storage, chunk and sync services
+func newTestSyncService(t *testing.T, nodeID string, fanout int) (*SyncService, storage.Storage) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	chunks, fileStorage := newTestChunkService(t, nodeID)
+	return NewSyncService(chunks, fanout, logger), fileStorage
+}
+
+// storeTestChunks stores chunks with the given contents and returns their IDs
+func storeTestChunks(t *testing.T, store storage.Storage, contents ...string) []string {
+	t.Helper()
+
+	var ids []string
+	for _, content := range contents {
+		id := types.CalculateHash([]byte(content))
+		if err := store.Store(context.Background(), id, []byte(content)); err != nil {
+			t.Fatalf("Failed to store chunk: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// connectSync connects node to peer and waits until both sides see each other
+func connectSync(t *testing.T, node, peer *SyncService) {
+	t.Helper()
+
+	if _, err := node.chunks.host.Connect(peer.chunks.host.Addr()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	waitFor(t, "connection", func() bool {
+		return hasPeer(peer.chunks.host, node.chunks.host.ID())
+	})
+}
+
+// listChunks returns the sorted chunk IDs held by store
+func listChunks(t *testing.T, store storage.Storage) []string {
+	t.Helper()
+
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("Failed to list chunks: %v", err)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSyncRejoiningNodeConverges(t *testing.T) {
+	first, firstStorage := newTestSyncService(t, "node-001", 3)
+	second, secondStorage := newTestSyncService(t, "node-002", 3)
+	rejoining, rejoiningStorage := newTestSyncService(t, "node-003", 3)
+
+	// The rejoining node holds what was stored before it went offline; the others
+	// have since received new chunks, some on only one of them
+	want := storeTestChunks(t, rejoiningStorage, "old-a", "old-b")
+	storeTestChunks(t, firstStorage, "old-a", "old-b")
+	storeTestChunks(t, secondStorage, "old-a", "old-b")
+	want = append(want, storeTestChunks(t, firstStorage, "new-c", "new-d")...)
+	want = append(want, storeTestChunks(t, secondStorage, "new-e")...)
+	storeTestChunks(t, secondStorage, "new-d")
+	sort.Strings(want)
+
+	connectSync(t, rejoining, first)
+	connectSync(t, rejoining, second)
+
+	if pulled := rejoining.Sync(); pulled != 3 {
+		t.Errorf("Expected 3 chunks pulled, got %d", pulled)
+	}
+
+	got := listChunks(t, rejoiningStorage)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected chunks %v, got %v", want, got)
+	}
+
+	// Pulled chunks are stored intact
+	for _, id := range got {
+		data, err := rejoiningStorage.Retrieve(context.Background(), id)
+		if err != nil || types.CalculateHash(data) != id {
+			t.Errorf("Expected intact chunk %s, got error %v", id, err)
+		}
+	}
+
+	// A converged node has nothing left to pull
+	if pulled := rejoining.Sync(); pulled != 0 {
+		t.Errorf("Expected nothing pulled once converged, got %d", pulled)
+	}
+}
+
+func TestSyncFanout(t *testing.T) {
+	node, nodeStorage := newTestSyncService(t, "node-001", 1)
+
+	for i := 2; i <= 4; i++ {
+		peer, peerStorage := newTestSyncService(t, fmt.Sprintf("node-%03d", i), 1)
+		storeTestChunks(t, peerStorage, fmt.Sprintf("only on peer %d", i))
+		connectSync(t, node, peer)
+	}
+
+	// Each round compares with a single peer, so every peer is reached eventually
+	deadline := time.Now().Add(5 * time.Second)
+	for len(listChunks(t, nodeStorage)) < 3 {
+		if pulled := node.Sync(); pulled > 1 {
+			t.Fatalf("Expected at most 1 chunk pulled per round with fanout 1, got %d", pulled)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out converging, have %d chunks", len(listChunks(t, nodeStorage)))
+		}
+	}
+}
+
+func TestSyncFilter(t *testing.T) {
+	node, nodeStorage := newTestSyncService(t, "node-001", 3)
+	peer, peerStorage := newTestSyncService(t, "node-002", 3)
+
+	ids := storeTestChunks(t, peerStorage, "wanted", "not ours")
+	node.SetFilter(func(chunkID string) bool { return chunkID == ids[0] })
+	connectSync(t, node, peer)
+
+	if pulled := node.Sync(); pulled != 1 {
+		t.Errorf("Expected 1 chunk pulled, got %d", pulled)
+	}
+	if !nodeStorage.Exists(ids[0]) || nodeStorage.Exists(ids[1]) {
+		t.Errorf("Expected only the responsible chunk to be pulled, got %v", listChunks(t, nodeStorage))
+	}
+}
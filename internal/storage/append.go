@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// AppendFile stores the data read from r as new chunks at the end of a file and returns
+// the updated file info, leaving fileInfo itself untouched. A last chunk that is not of
+// the file's chunk size is rewritten together with the new data so that every chunk but
+// the last keeps that size. The whole-file hash is recomputed by streaming the existing
+// content, so appends are not safe to run concurrently on the same file.
+func (cm *ChunkManager) AppendFile(ctx context.Context, fileInfo *types.FileInfo, r io.Reader) (*types.FileInfo, error) {
+	if fileInfo.Erasure != nil {
+		return nil, fmt.Errorf("cannot append to erasure coded file %s", fileInfo.ID)
+	}
+
+	// With nothing to append the file stays as it is. Otherwise the rewritten last chunk
+	// always differs from the one it replaces, so releasing that cannot hit the new one.
+	var first [1]byte
+	n, err := io.ReadFull(r, first[:])
+	if n == 0 {
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read appended data: %w", err)
+		}
+		updated := *fileInfo
+		return &updated, nil
+	}
+	r = io.MultiReader(bytes.NewReader(first[:n]), r)
+
+	chunkSize, err := cm.fileChunkSize(fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(fileInfo.Chunks))
+	for i, chunkInfo := range fileInfo.Chunks {
+		hashes[i] = chunkInfo.Hash
+	}
+	if err := verifyMerkleRoot(fileInfo, hashes); err != nil {
+		return nil, err
+	}
+
+	algorithm := fileInfo.HashAlgorithm
+	if algorithm == "" {
+		algorithm = types.DefaultHashAlgorithm
+	}
+	hasher := newFileHasher(algorithm)
+
+	// Chunks before a partial last chunk are kept and only read to rehash the file
+	kept := fileInfo.Chunks
+	var replaced []types.ChunkInfo
+	var tail []byte
+	if n := len(kept); n > 0 && kept[n-1].Size != int64(chunkSize) {
+		last := kept[n-1]
+		if tail, err = cm.retrieveChunk(ctx, last); err != nil {
+			return nil, err
+		}
+		kept, replaced = kept[:n-1], kept[n-1:]
+	}
+
+	var size int64
+	for _, chunkInfo := range kept {
+		size += chunkInfo.Size
+	}
+	if size > 0 {
+		if err := cm.RetrieveFileRangeTo(ctx, fileInfo, 0, size-1, hasher); err != nil {
+			return nil, err
+		}
+	}
+
+	var added []types.ChunkInfo
+	address := cm.addressHeader(fileInfo)
+	err = utils.SplitReader(io.MultiReader(bytes.NewReader(tail), r), chunkSize, func(index int, chunk []byte) error {
+		hasher.Write(chunk)
+		size += int64(len(chunk))
+
+		chunkInfo, err := cm.storeChunk(ctx, address, len(kept)+index, chunk, algorithm)
+		if err != nil {
+			return err
+		}
+		added = append(added, chunkInfo)
+		return nil
+	})
+	if err != nil {
+		cm.deleteChunks(added)
+		return nil, err
+	}
+
+	updated := *fileInfo
+	updated.Chunks = append(append([]types.ChunkInfo(nil), kept...), added...)
+	updated.ChunkSize = chunkSize
+	updated.Replicas = minReplicas(updated.Chunks)
+	updated.Size = size
+	updated.Hash = hasher.sum()
+	updated.HashAlgorithm = algorithm
+	if err := setMerkleRoot(&updated); err != nil {
+		cm.deleteChunks(added)
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+
+	// The rewritten last chunk is no longer part of the file
+	cm.deleteChunks(replaced)
+
+	cm.logger.WithFields(logrus.Fields{
+		"file_id": fileInfo.ID,
+		"chunks":  len(added),
+		"size":    updated.Size,
+	}).Debug("Appended file chunks")
+
+	return &updated, nil
+}
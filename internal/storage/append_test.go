@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestAppendFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		appended string
+	}{
+		{"partial last chunk", "0123456", "789ab"},
+		{"full last chunk", "01234567", "89"},
+		{"empty file", "", "abc"},
+		{"nothing appended", "0123456", ""},
+	}
+
+	for _, tt := range tests {
+		cm, fileStorage := newTestChunkManager(t, 4)
+		fileInfo := &types.FileInfo{ID: types.GenerateFileID("log.txt", []byte(tt.original))}
+		if err := cm.StoreFile(context.Background(), fileInfo, []byte(tt.original)); err != nil {
+			t.Fatalf("%s: failed to store: %v", tt.name, err)
+		}
+		before := fileInfo.Chunks
+
+		updated, err := cm.AppendFile(context.Background(), fileInfo, bytes.NewReader([]byte(tt.appended)))
+		if err != nil {
+			t.Fatalf("%s: failed to append: %v", tt.name, err)
+		}
+		if len(fileInfo.Chunks) != len(before) || fileInfo.Size != int64(len(tt.original)) {
+			t.Errorf("%s: expected the original file info to be left untouched", tt.name)
+		}
+
+		want := []byte(tt.original + tt.appended)
+		if updated.ID != fileInfo.ID {
+			t.Errorf("%s: expected ID %s to be kept, got %s", tt.name, fileInfo.ID, updated.ID)
+		}
+		if updated.Size != int64(len(want)) {
+			t.Errorf("%s: expected size %d, got %d", tt.name, len(want), updated.Size)
+		}
+		if updated.Hash != types.CalculateHash(want) {
+			t.Errorf("%s: expected hash of the whole content, got %s", tt.name, updated.Hash)
+		}
+
+		// Every chunk but the last is full, so range reads can skip to their chunk
+		for i, chunkInfo := range updated.Chunks {
+			if chunkInfo.Index != i {
+				t.Errorf("%s: expected chunk %d to have index %d, got %d", tt.name, i, i, chunkInfo.Index)
+			}
+			if i < len(updated.Chunks)-1 && chunkInfo.Size != 4 {
+				t.Errorf("%s: expected chunk %d to be full, got %d bytes", tt.name, i, chunkInfo.Size)
+			}
+		}
+
+		retrieved, err := cm.RetrieveFile(context.Background(), updated)
+		if err != nil {
+			t.Fatalf("%s: failed to retrieve: %v", tt.name, err)
+		}
+		if !bytes.Equal(retrieved, want) {
+			t.Errorf("%s: expected %q, got %q", tt.name, want, retrieved)
+		}
+		if len(want) > 2 {
+			got, err := cm.RetrieveFileRange(context.Background(), updated, 1, int64(len(want)-2))
+			if err != nil {
+				t.Fatalf("%s: failed to retrieve range: %v", tt.name, err)
+			}
+			if !bytes.Equal(got, want[1:len(want)-1]) {
+				t.Errorf("%s: expected range %q, got %q", tt.name, want[1:len(want)-1], got)
+			}
+		}
+
+		// A rewritten last chunk is deleted
+		keys, _ := fileStorage.List()
+		if len(keys) != len(updated.Chunks) {
+			t.Errorf("%s: expected %d stored chunks, got %d", tt.name, len(updated.Chunks), len(keys))
+		}
+	}
+}
+
+func TestAppendFileErasure(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 4)
+	fileInfo := &types.FileInfo{ID: "coded", Erasure: &types.ErasureInfo{DataShards: 2, ParityShards: 1}}
+
+	if _, err := cm.AppendFile(context.Background(), fileInfo, bytes.NewReader([]byte("more"))); err == nil {
+		t.Errorf("Expected error appending to an erasure coded file")
+	}
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix starts the names of files still being written. Such names never match a
+// storage key, so reads and listings ignore them until they are renamed into place.
+const tempPrefix = ".tmp-"
+
+// writeFileAtomic writes the content of r to path through a temporary file in the same
+// directory that is renamed into place once complete, so path never holds a partial
+// write. With durable set, the file is synced before the rename and the directory after
+// it, so the write survives a power loss once writeFileAtomic returns.
+func writeFileAtomic(path string, r io.Reader, durable bool) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, tempPrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		return err
+	}
+	if durable {
+		if err = tmp.Sync(); err != nil {
+			return err
+		}
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	if durable {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// syncDir flushes a directory's entries, making renames into it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// removeTempFiles deletes temporary files below basePath left behind by writes that
+// were interrupted by a crash, returning how many were removed
+func removeTempFiles(basePath string) (int, error) {
+	removed := 0
+	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasPrefix(info.Name(), tempPrefix) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,737 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/erasure"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultChunkSize is used when the chunk manager is created without a positive chunk size
+const defaultChunkSize = 1024 * 1024 // 1MB
+
+// localNodeID identifies the local node in ChunkInfo.NodeIDs when no node ID is configured
+const localNodeID = "local"
+
+// ErrChunkCorrupted is returned when a stored chunk no longer matches the checksum or hash
+// recorded for it
+var ErrChunkCorrupted = errors.New("chunk failed integrity verification")
+
+// ChunkIntegrityError identifies the chunk of a file that failed integrity verification.
+// It matches ErrChunkCorrupted with errors.Is.
+type ChunkIntegrityError struct {
+	Index   int
+	ChunkID string
+}
+
+func (e *ChunkIntegrityError) Error() string {
+	return fmt.Sprintf("chunk %d failed integrity verification", e.Index)
+}
+
+func (e *ChunkIntegrityError) Unwrap() error {
+	return ErrChunkCorrupted
+}
+
+// ChunkManager splits files into encrypted chunks and stores them
+type ChunkManager struct {
+	storage   Storage
+	keys      *crypto.KeyRing
+	chunkSize int
+	logger    *logrus.Logger
+
+	// Largest chunk size a file may ask to be stored with, 0 for no limit
+	maxChunkSize int
+
+	// Replication state
+	nodeID   string
+	replicas int
+	peers    map[string]Peer
+	ring     *HashRing // Places chunk replicas on peers
+	tracker  PeerTracker
+	erasure  *erasure.Encoder
+
+	// Number of chunks fetched concurrently on retrieval
+	downloadWorkers int
+
+	// Number of chunks encrypted and stored concurrently per file
+	storeWorkers int
+
+	// Codec applied to chunks before encryption
+	compression byte
+
+	// Cipher new chunks are encrypted with; stored chunks name their own
+	cipher crypto.Cipher
+
+	// Algorithm for file and chunk hashes of newly stored files
+	hashAlgorithm types.HashAlgorithm
+
+	// Generator of new file and chunk IDs
+	ids types.IDGenerator
+
+	// Told about chunks read under an old master key version
+	staleKey func(types.ChunkInfo)
+	rekeyMu  sync.Mutex // Serializes re-encryption so stored chunks match their checksums
+
+	mu sync.RWMutex
+
+	// Deduplication state
+	refs  metadata.Store
+	refMu sync.Mutex
+
+	// Chunks being read, whose removal waits for the readers
+	pins chunkPins
+}
+
+// NewChunkManager creates a new chunk manager encrypting under key as the only master
+// key version
+func NewChunkManager(storage Storage, key crypto.EncryptionKey, chunkSize int, logger *logrus.Logger) *ChunkManager {
+	return &ChunkManager{
+		storage:   storage,
+		keys:      crypto.NewKeyRing(key),
+		chunkSize: chunkSize,
+		logger:    logger,
+		nodeID:    localNodeID,
+		replicas:  1,
+		peers:     make(map[string]Peer),
+		ring:      NewHashRing(defaultVirtualNodes),
+
+		cipher:        crypto.CipherAES256GCM,
+		hashAlgorithm: types.DefaultHashAlgorithm,
+		ids:           types.ContentHashIDs{},
+	}
+}
+
+// SetCipher selects the cipher newly stored chunks are encrypted with. Stored chunks
+// record their cipher and stay readable whichever is selected.
+func (cm *ChunkManager) SetCipher(c crypto.Cipher) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.cipher = c
+}
+
+// chunkCipher returns the cipher newly stored chunks are encrypted with
+func (cm *ChunkManager) chunkCipher() crypto.Cipher {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.cipher
+}
+
+// SetHashAlgorithm selects the algorithm used to hash newly stored files and chunks.
+// Files keep the algorithm they were stored with.
+func (cm *ChunkManager) SetHashAlgorithm(algorithm types.HashAlgorithm) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.hashAlgorithm = algorithm
+}
+
+// SetIDGenerator selects how new files and chunks are identified. Stored files keep the
+// IDs they were given.
+func (cm *ChunkManager) SetIDGenerator(ids types.IDGenerator) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.ids = ids
+}
+
+// IDGenerator returns the generator of new file and chunk IDs
+func (cm *ChunkManager) IDGenerator() types.IDGenerator {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.ids
+}
+
+// SetMaxChunkSize sets the largest chunk size a file may ask to be stored with. It also
+// bounds the chunks a reindex accepts, as their file's chunk size is not known then.
+func (cm *ChunkManager) SetMaxChunkSize(size int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.maxChunkSize = size
+}
+
+// fileChunkSize returns the chunk size to split a file with: the one requested on
+// fileInfo when set, the manager's otherwise
+func (cm *ChunkManager) fileChunkSize(fileInfo *types.FileInfo) (int, error) {
+	if fileInfo.ChunkSize == 0 {
+		if cm.chunkSize <= 0 {
+			return defaultChunkSize, nil
+		}
+		return cm.chunkSize, nil
+	}
+
+	cm.mu.RLock()
+	limit := cm.maxChunkSize
+	cm.mu.RUnlock()
+
+	if fileInfo.ChunkSize < 0 || (limit > 0 && fileInfo.ChunkSize > limit) {
+		return 0, fmt.Errorf("invalid chunk size: %d", fileInfo.ChunkSize)
+	}
+	return fileInfo.ChunkSize, nil
+}
+
+// StoreFile splits data into chunks, encrypts and stores them, and records the chunk layout on fileInfo
+func (cm *ChunkManager) StoreFile(ctx context.Context, fileInfo *types.FileInfo, data []byte) error {
+	return cm.StoreFileStream(ctx, fileInfo, bytes.NewReader(data))
+}
+
+// StoreFileStream reads r incrementally, chunking, hashing and encrypting as it goes,
+// so that at most one chunk per store worker is held in memory at a time. fileInfo.ID must be set, and
+// fileInfo.ChunkSize may ask for chunks of another size than the manager's. Once ctx is
+// done no further chunks are stored and the ones already stored are released.
+func (cm *ChunkManager) StoreFileStream(ctx context.Context, fileInfo *types.FileInfo, r io.Reader) error {
+	cm.mu.RLock()
+	enc := cm.erasure
+	algorithm := cm.hashAlgorithm
+	workers := cm.storeWorkers
+	cm.mu.RUnlock()
+
+	chunkSize, err := cm.fileChunkSize(fileInfo)
+	if err != nil {
+		return err
+	}
+
+	if enc != nil {
+		return cm.storeFileErasure(ctx, fileInfo, r, enc, algorithm, chunkSize)
+	}
+
+	var chunks []types.ChunkInfo
+	var size int64
+	hasher := newFileHasher(algorithm)
+	address := cm.addressHeader(fileInfo)
+
+	if workers > 1 {
+		chunks, size, err = cm.storeChunksParallel(ctx, address, r, chunkSize, algorithm, hasher, workers)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = utils.SplitReader(r, chunkSize, func(index int, chunk []byte) error {
+			hasher.Write(chunk)
+			size += int64(len(chunk))
+
+			chunkInfo, err := cm.storeChunk(ctx, address, index, chunk, algorithm)
+			if err != nil {
+				return err
+			}
+			chunks = append(chunks, chunkInfo)
+			return nil
+		})
+		if err != nil {
+			cm.deleteChunks(chunks)
+			return err
+		}
+	}
+
+	fileInfo.Chunks = chunks
+	fileInfo.ChunkSize = chunkSize
+	fileInfo.Replicas = minReplicas(chunks)
+	fileInfo.Size = size
+	fileInfo.Hash = hasher.sum()
+	fileInfo.HashAlgorithm = algorithm
+	fileInfo.IsEncrypted = true
+	if err := setMerkleRoot(fileInfo); err != nil {
+		cm.deleteChunks(chunks)
+		return err
+	}
+	stampFile(fileInfo)
+
+	cm.logger.WithFields(logrus.Fields{
+		"file_id": fileInfo.ID,
+		"chunks":  len(chunks),
+		"size":    fileInfo.Size,
+	}).Debug("Stored file chunks")
+
+	return nil
+}
+
+// stampFile records when a file's content was stored, keeping the creation time of a
+// file that already has one
+func stampFile(fileInfo *types.FileInfo) {
+	now := time.Now()
+	if fileInfo.CreatedAt.IsZero() {
+		fileInfo.CreatedAt = now
+	}
+	fileInfo.UpdatedAt = now
+}
+
+// RetrieveFile retrieves and decrypts all chunks of a file and reassembles them
+func (cm *ChunkManager) RetrieveFile(ctx context.Context, fileInfo *types.FileInfo) ([]byte, error) {
+	if fileInfo.Erasure != nil {
+		var buf bytes.Buffer
+		buf.Grow(int(fileInfo.Size))
+		if err := cm.RetrieveFileTo(ctx, fileInfo, &buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	cm.mu.RLock()
+	workers := cm.downloadWorkers
+	cm.mu.RUnlock()
+
+	data := make([]byte, 0, fileInfo.Size)
+	hashes := make([]string, 0, len(fileInfo.Chunks))
+
+	if workers > 1 {
+		chunks, err := cm.retrieveChunksParallel(ctx, fileInfo, workers)
+		if err != nil {
+			return nil, err
+		}
+		for i, chunk := range chunks {
+			hashes = append(hashes, fileInfo.Chunks[i].Hash)
+			data = append(data, chunk...)
+		}
+		if err := verifyMerkleRoot(fileInfo, hashes); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	for _, chunkInfo := range fileInfo.Chunks {
+		// Each chunk is verified against its Merkle leaf as it is retrieved
+		chunk, err := cm.retrieveChunk(ctx, chunkInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, chunkInfo.Hash)
+		data = append(data, chunk...)
+	}
+
+	if err := verifyMerkleRoot(fileInfo, hashes); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ChecksumFile recomputes a file's checksum from its stored chunks with the hash returned
+// by newHash, decrypting and verifying one chunk at a time rather than reassembling the
+// whole file in memory
+func (cm *ChunkManager) ChecksumFile(ctx context.Context, fileInfo *types.FileInfo, newHash func() hash.Hash) (string, error) {
+	h := newHash()
+
+	// Erasure coded files are decoded a stripe at a time
+	if fileInfo.Erasure != nil {
+		if err := cm.RetrieveFileTo(ctx, fileInfo, h); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	for _, chunkInfo := range fileInfo.Chunks {
+		chunk, err := cm.retrieveChunk(ctx, chunkInfo)
+		if err != nil {
+			return "", err
+		}
+		h.Write(chunk)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// RetrieveFileRange retrieves the bytes in the inclusive range [start, end] of a file,
+// fetching only the chunks that overlap the range
+func (cm *ChunkManager) RetrieveFileRange(ctx context.Context, fileInfo *types.FileInfo, start, end int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if start >= 0 && end >= start {
+		buf.Grow(int(end - start + 1))
+	}
+	if err := cm.RetrieveFileRangeTo(ctx, fileInfo, start, end, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RetrieveFileTo decrypts a file's chunks one at a time and writes them to w in order,
+// so only a single chunk is held in memory rather than the whole file. It stops with the
+// context's error once ctx is done, such as when the client it streams to disconnects.
+func (cm *ChunkManager) RetrieveFileTo(ctx context.Context, fileInfo *types.FileInfo, w io.Writer) error {
+	// Each chunk or stripe is checked against its recorded hashes before it is written,
+	// so checking the recorded hashes against the root up front covers the whole file
+	// before any of it reaches w
+	hashes := make([]string, len(fileInfo.Chunks))
+	for i, chunkInfo := range fileInfo.Chunks {
+		hashes[i] = chunkInfo.Hash
+	}
+	if err := verifyMerkleRoot(fileInfo, hashes); err != nil {
+		return err
+	}
+
+	if fileInfo.Size == 0 {
+		return nil
+	}
+	return cm.RetrieveFileRangeTo(ctx, fileInfo, 0, fileInfo.Size-1, w)
+}
+
+// RetrieveFileRangeTo writes the bytes in the inclusive range [start, end] of a file to
+// w, fetching and decrypting only the chunks that overlap the range, one at a time.
+// Erasure coded files are decoded one stripe at a time in the same way.
+func (cm *ChunkManager) RetrieveFileRangeTo(ctx context.Context, fileInfo *types.FileInfo, start, end int64, w io.Writer) error {
+	if start < 0 || end < start || end >= fileInfo.Size {
+		return fmt.Errorf("invalid range %d-%d for file of size %d", start, end, fileInfo.Size)
+	}
+
+	if fileInfo.Erasure != nil {
+		return cm.retrieveErasureRangeTo(ctx, fileInfo, start, end, w)
+	}
+
+	// Skip straight to the first chunk of the range
+	if fileInfo.ChunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size: %d", fileInfo.ChunkSize)
+	}
+	first := int(start / int64(fileInfo.ChunkSize))
+	if first >= len(fileInfo.Chunks) {
+		return fmt.Errorf("file %s has %d chunks, range starts in chunk %d", fileInfo.ID, len(fileInfo.Chunks), first)
+	}
+
+	offset := int64(first) * int64(fileInfo.ChunkSize)
+	for _, chunkInfo := range fileInfo.Chunks[first:] {
+		chunkStart, chunkEnd := offset, offset+chunkInfo.Size-1
+		offset += chunkInfo.Size
+
+		if chunkEnd < start {
+			continue
+		}
+		if chunkStart > end {
+			break
+		}
+
+		chunk, err := cm.retrieveChunk(ctx, chunkInfo)
+		if err != nil {
+			return err
+		}
+
+		from := max64(start, chunkStart) - chunkStart
+		to := min64(end, chunkEnd) - chunkStart + 1
+		if _, err := w.Write(chunk[from:to]); err != nil {
+			return fmt.Errorf("failed to write chunk %d: %w", chunkInfo.Index, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteFile deletes all chunks of a file, including replicas held by peers. Chunks
+// pinned by a read are deleted once it is done.
+func (cm *ChunkManager) DeleteFile(ctx context.Context, fileInfo *types.FileInfo) error {
+	for _, chunkInfo := range fileInfo.Chunks {
+		unreferenced, err := cm.releaseChunk(chunkInfo)
+		if err != nil {
+			return fmt.Errorf("failed to release chunk %d: %w", chunkInfo.Index, err)
+		}
+		if !unreferenced || cm.deferRemoval(chunkInfo) {
+			continue
+		}
+
+		cm.deleteReplicas(chunkInfo.ID, chunkInfo.NodeIDs)
+		if err := cm.storage.Delete(ctx, chunkInfo.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to delete chunk %d: %w", chunkInfo.Index, err)
+		}
+	}
+	return nil
+}
+
+// addressHeader returns the header naming the ID that a file's chunk IDs and encryption
+// key derive from. Files with a content ID share it with every file holding the same
+// bytes, so their chunks coincide; that is only safe while deduplication counts the
+// references to them.
+func (cm *ChunkManager) addressHeader(fileInfo *types.FileInfo) chunkHeader {
+	cm.refMu.Lock()
+	counted := cm.refs != nil
+	cm.refMu.Unlock()
+
+	if fileInfo.ContentID != "" && counted {
+		return chunkHeader{FileID: fileInfo.ContentID, Flags: chunkFlagContentAddress}
+	}
+	return chunkHeader{FileID: fileInfo.ID}
+}
+
+// storeChunk encrypts and stores a single chunk of the file named by address, hashed
+// with algorithm, reusing an identical stored chunk when deduplication is enabled
+func (cm *ChunkManager) storeChunk(ctx context.Context, address chunkHeader, index int, chunk []byte, algorithm types.HashAlgorithm) (types.ChunkInfo, error) {
+	fileID := address.FileID
+	hash := types.CalculateHashWith(algorithm, chunk)
+	if chunkInfo, reused, err := cm.reuseChunk(hash, index, int64(len(chunk))); err != nil {
+		return types.ChunkInfo{}, fmt.Errorf("failed to look up chunk %d: %w", index, err)
+	} else if reused {
+		chunkInfo.HashAlgorithm = algorithm
+		return chunkInfo, nil
+	}
+
+	address.Index = index
+	address.Algorithm = algorithm
+	keyVersion, key := cm.currentKey(fileID)
+	address.KeyVersion = keyVersion
+	encrypted, err := cm.sealChunk(key, address, chunk)
+	if err != nil {
+		return types.ChunkInfo{}, fmt.Errorf("failed to encrypt chunk %d: %w", index, err)
+	}
+
+	chunkID, err := cm.IDGenerator().ChunkID(fileID, index, chunk) // Storage keys stay SHA-256 hex whatever the content hash
+	if err != nil {
+		return types.ChunkInfo{}, fmt.Errorf("failed to identify chunk %d: %w", index, err)
+	}
+	cm.keepChunk(chunkID)
+	if err := cm.storage.Store(ctx, chunkID, encrypted); err != nil {
+		return types.ChunkInfo{}, fmt.Errorf("failed to store chunk %d: %w", index, err)
+	}
+
+	cm.mu.RLock()
+	nodeIDs := []string{cm.nodeID}
+	cm.mu.RUnlock()
+	nodeIDs = append(nodeIDs, cm.replicateChunk(chunkID, encrypted)...)
+
+	chunkInfo := types.ChunkInfo{
+		ID:       chunkID,
+		Index:    index,
+		Size:     int64(len(chunk)),
+		Hash:     hash,
+		NodeIDs:  nodeIDs,
+		Checksum: types.CalculateHash(encrypted),
+		KeyID:    fileID,
+
+		KeyVersion: int(keyVersion),
+	}
+
+	chunkInfo, err = cm.registerChunk(chunkInfo)
+	if err != nil {
+		cm.removeChunk(chunkInfo)
+		return types.ChunkInfo{}, fmt.Errorf("failed to register chunk %d: %w", index, err)
+	}
+	chunkInfo.HashAlgorithm = algorithm
+
+	return chunkInfo, nil
+}
+
+// retrieveChunk retrieves, decrypts and decompresses a single chunk and verifies it against
+// its recorded hash, falling back to a replica if the local copy is unavailable or corrupted.
+// A chunk that fails verification everywhere yields a *ChunkIntegrityError.
+func (cm *ChunkManager) retrieveChunk(ctx context.Context, chunkInfo types.ChunkInfo) ([]byte, error) {
+	encrypted, err := cm.storage.Retrieve(ctx, chunkInfo.ID)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to retrieve chunk %d: %w", chunkInfo.Index, err)
+	} else {
+		var chunk []byte
+		if chunk, err = cm.openVerifiedChunk(chunkInfo, encrypted); err == nil {
+			// Chunks under an old key version are re-encrypted lazily as they are read
+			cm.noteKeyVersion(chunkInfo, encrypted)
+			return chunk, nil
+		}
+	}
+
+	replica, replicaErr := cm.retrieveReplica(chunkInfo.ID, chunkInfo.NodeIDs)
+	if replicaErr != nil {
+		return nil, err
+	}
+	cm.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Retrieved chunk from replica")
+
+	chunk, err := cm.openVerifiedChunk(chunkInfo, replica)
+	if err != nil {
+		return nil, err
+	}
+	cm.noteKeyVersion(chunkInfo, replica)
+
+	return chunk, nil
+}
+
+// openVerifiedChunk decrypts a stored chunk and checks it against its recorded hash. Stored
+// bytes that fail to decrypt and no longer match their recorded checksum are reported as
+// corrupted rather than as a decryption failure.
+func (cm *ChunkManager) openVerifiedChunk(chunkInfo types.ChunkInfo, stored []byte) ([]byte, error) {
+	chunk, err := cm.openStoredChunk(chunkInfo, stored)
+	if err != nil {
+		if chunkInfo.Checksum != "" && types.CalculateHash(stored) != chunkInfo.Checksum {
+			return nil, &ChunkIntegrityError{Index: chunkInfo.Index, ChunkID: chunkInfo.ID}
+		}
+		return nil, fmt.Errorf("failed to decrypt chunk %d: %w", chunkInfo.Index, err)
+	}
+	if err := verifyChunk(chunkInfo, chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// chunkKey returns the key for chunks encrypted on behalf of keyID under the current
+// master key version
+func (cm *ChunkManager) chunkKey(keyID string) crypto.EncryptionKey {
+	_, key := cm.currentKey(keyID)
+	return key
+}
+
+// currentKey returns the current master key version and the key for chunks encrypted
+// on behalf of keyID under it
+func (cm *ChunkManager) currentKey(keyID string) (uint32, crypto.EncryptionKey) {
+	version, master := cm.keyRing().Current()
+	return version, deriveChunkKey(master, keyID)
+}
+
+// versionKey returns the key for chunks encrypted on behalf of keyID under the given
+// master key version
+func (cm *ChunkManager) versionKey(version uint32, keyID string) (crypto.EncryptionKey, error) {
+	master, err := cm.keyRing().Key(version)
+	if err != nil {
+		return nil, err
+	}
+	return deriveChunkKey(master, keyID), nil
+}
+
+// decryptAnyVersion decrypts data encrypted on behalf of keyID with crypto.Encrypt
+// under any master key version, trying the newest first. It is meant for short-lived
+// data such as staged uploads, which does not record the version it was encrypted under.
+func (cm *ChunkManager) decryptAnyVersion(keyID string, ciphertext []byte) ([]byte, error) {
+	versions := cm.keyRing().Versions()
+
+	var err error
+	for i := len(versions) - 1; i >= 0; i-- {
+		var key crypto.EncryptionKey
+		if key, err = cm.versionKey(versions[i], keyID); err != nil {
+			continue
+		}
+		var plaintext []byte
+		if plaintext, err = crypto.Decrypt(ciphertext, key); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, err
+}
+
+// deriveChunkKey returns the file's HKDF subkey of master, or master itself for chunks
+// stored before per-file keys (empty keyID)
+func deriveChunkKey(master crypto.EncryptionKey, keyID string) crypto.EncryptionKey {
+	if keyID == "" {
+		return master
+	}
+	return crypto.DeriveFileKey(master, keyID)
+}
+
+// deleteChunks releases chunks a file no longer uses, such as those already stored by
+// a failed store
+func (cm *ChunkManager) deleteChunks(chunks []types.ChunkInfo) {
+	for _, chunkInfo := range chunks {
+		unreferenced, err := cm.releaseChunk(chunkInfo)
+		if err != nil {
+			cm.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Failed to release chunk")
+			continue
+		}
+		if unreferenced {
+			cm.removeChunk(chunkInfo)
+		}
+	}
+}
+
+// removeChunk deletes a chunk and its replicas, logging failures, or defers that while
+// the chunk is pinned. It is not bound to the request's context, so chunks left by a
+// cancelled store are still cleaned up.
+func (cm *ChunkManager) removeChunk(chunkInfo types.ChunkInfo) {
+	if cm.deferRemoval(chunkInfo) {
+		return
+	}
+	cm.deleteReplicas(chunkInfo.ID, chunkInfo.NodeIDs)
+	if err := cm.storage.Delete(context.Background(), chunkInfo.ID); err != nil {
+		cm.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Failed to clean up chunk")
+	}
+}
+
+// minReplicas returns the smallest number of copies held of any chunk
+func minReplicas(chunks []types.ChunkInfo) int {
+	if len(chunks) == 0 {
+		return 0
+	}
+
+	replicas := len(chunks[0].NodeIDs)
+	for _, chunkInfo := range chunks[1:] {
+		if len(chunkInfo.NodeIDs) < replicas {
+			replicas = len(chunkInfo.NodeIDs)
+		}
+	}
+	return replicas
+}
+
+// setMerkleRoot records the Merkle root over the file's chunk hashes
+func setMerkleRoot(fileInfo *types.FileInfo) error {
+	hashes := make([]string, len(fileInfo.Chunks))
+	for i, chunkInfo := range fileInfo.Chunks {
+		hashes[i] = chunkInfo.Hash
+	}
+
+	root, err := types.ComputeMerkleRoot(hashes)
+	if err != nil {
+		return fmt.Errorf("failed to compute merkle root: %w", err)
+	}
+
+	fileInfo.MerkleRoot = root
+	return nil
+}
+
+// verifyMerkleRoot checks the hashes of the retrieved chunks against the file's Merkle root
+func verifyMerkleRoot(fileInfo *types.FileInfo, hashes []string) error {
+	root, err := types.ComputeMerkleRoot(hashes)
+	if err != nil {
+		return fmt.Errorf("failed to compute merkle root: %w", err)
+	}
+
+	if root != fileInfo.MerkleRoot {
+		return fmt.Errorf("file %s failed merkle root verification", fileInfo.ID)
+	}
+	return nil
+}
+
+// chunkHash hashes chunk data with the algorithm recorded for the chunk
+func chunkHash(chunkInfo types.ChunkInfo, data []byte) string {
+	return types.CalculateHashWith(chunkInfo.HashAlgorithm, data)
+}
+
+// verifyChunk checks a decrypted chunk against the hash recorded for it
+func verifyChunk(chunkInfo types.ChunkInfo, chunk []byte) error {
+	if chunkHash(chunkInfo, chunk) != chunkInfo.Hash {
+		return &ChunkIntegrityError{Index: chunkInfo.Index, ChunkID: chunkInfo.ID}
+	}
+	return nil
+}
+
+// fileHasher computes the whole-file hash while a file is streamed
+type fileHasher struct {
+	hash.Hash
+}
+
+func newFileHasher(algorithm types.HashAlgorithm) *fileHasher {
+	return &fileHasher{algorithm.New()}
+}
+
+func (h *fileHasher) sum() string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,733 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestChunkManager creates a chunk manager backed by temporary storage
+func newTestChunkManager(t *testing.T, chunkSize int) (*ChunkManager, *FileStorage) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fileStorage, err := NewFileStorage(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	return NewChunkManager(fileStorage, key, chunkSize, logger), fileStorage
+}
+
+func TestStoreRetrieveFile(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	data := []byte("hello distributed world")
+
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("test.txt", data), Name: "test.txt"}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if len(fileInfo.Chunks) != 6 {
+		t.Errorf("Expected 6 chunks, got %d", len(fileInfo.Chunks))
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file: %v", err)
+	}
+
+	if !bytes.Equal(data, retrieved) {
+		t.Errorf("Expected %s, got %s", data, retrieved)
+	}
+
+	if err := cm.DeleteFile(context.Background(), fileInfo); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	keys, _ := fileStorage.List()
+	if len(keys) != 0 {
+		t.Errorf("Expected no stored chunks after delete, got %d", len(keys))
+	}
+}
+
+func TestRetrieveFileRange(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 4)
+	data := []byte("0123456789abcdefghij")
+
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("range.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	tests := []struct {
+		start, end int64
+		expected   string
+	}{
+		{0, 19, "0123456789abcdefghij"},
+		{0, 0, "0"},
+		{3, 9, "3456789"}, // spans chunk boundaries
+		{4, 7, "4567"},    // exactly one chunk
+		{18, 19, "ij"},    // end of file
+		{10, 10, "a"},     // single byte mid-chunk
+	}
+
+	for _, test := range tests {
+		got, err := cm.RetrieveFileRange(context.Background(), fileInfo, test.start, test.end)
+		if err != nil {
+			t.Errorf("Range %d-%d: unexpected error: %v", test.start, test.end, err)
+			continue
+		}
+		if string(got) != test.expected {
+			t.Errorf("Range %d-%d: expected %s, got %s", test.start, test.end, test.expected, got)
+		}
+	}
+
+	if _, err := cm.RetrieveFileRange(context.Background(), fileInfo, 5, 20); err == nil {
+		t.Errorf("Expected error for range past end of file")
+	}
+}
+
+func TestRetrieveFileToMatchesBuffered(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 1024)
+	erasureCM, _ := newTestChunkManager(t, 1024)
+	if err := erasureCM.SetErasureCoding(2, 1); err != nil {
+		t.Fatalf("Failed to enable erasure coding: %v", err)
+	}
+
+	managers := []struct {
+		name string
+		cm   *ChunkManager
+	}{
+		{"replicated", cm},
+		{"erasure", erasureCM},
+	}
+
+	for _, m := range managers {
+		for _, size := range []int{0, 1, 1024, 1025, 10*1024 + 7} {
+			data := bytes.Repeat([]byte("abcdefg"), size/7+1)[:size]
+			fileInfo := &types.FileInfo{ID: types.GenerateFileID("stream.bin", data)}
+			if err := m.cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+				t.Fatalf("%s size %d: failed to store: %v", m.name, size, err)
+			}
+
+			buffered, err := m.cm.RetrieveFile(context.Background(), fileInfo)
+			if err != nil {
+				t.Fatalf("%s size %d: failed to retrieve: %v", m.name, size, err)
+			}
+
+			var streamed bytes.Buffer
+			if err := m.cm.RetrieveFileTo(context.Background(), fileInfo, &streamed); err != nil {
+				t.Fatalf("%s size %d: failed to stream: %v", m.name, size, err)
+			}
+			if !bytes.Equal(streamed.Bytes(), buffered) {
+				t.Errorf("%s size %d: streamed output differs from buffered retrieval", m.name, size)
+			}
+			if !bytes.Equal(streamed.Bytes(), data) {
+				t.Errorf("%s size %d: streamed output differs from stored data", m.name, size)
+			}
+
+			if size < 2 {
+				continue
+			}
+			start, end := int64(size/3), int64(size-2)
+			var streamedRange bytes.Buffer
+			if err := m.cm.RetrieveFileRangeTo(context.Background(), fileInfo, start, end, &streamedRange); err != nil {
+				t.Fatalf("%s size %d: failed to stream range: %v", m.name, size, err)
+			}
+			if !bytes.Equal(streamedRange.Bytes(), buffered[start:end+1]) {
+				t.Errorf("%s size %d: streamed range %d-%d differs from buffered retrieval", m.name, size, start, end)
+			}
+		}
+	}
+}
+
+func TestRetrieveFileToVerifiesBeforeWriting(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	data := []byte("verified before it is written")
+
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("verify.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	// A wrong root is caught before any chunk is written
+	root := fileInfo.MerkleRoot
+	fileInfo.MerkleRoot = types.CalculateHash([]byte("wrong root"))
+	var out bytes.Buffer
+	if err := cm.RetrieveFileTo(context.Background(), fileInfo, &out); err == nil {
+		t.Errorf("Expected streaming with wrong merkle root to fail")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing written with wrong merkle root, got %d bytes", out.Len())
+	}
+	fileInfo.MerkleRoot = root
+
+	// A tampered chunk stops the stream before its content is written
+	fileStorage.Store(context.Background(), fileInfo.Chunks[2].ID, mustSeal(t, cm, fileInfo.Chunks[2], []byte("evil")))
+	out.Reset()
+	if err := cm.RetrieveFileTo(context.Background(), fileInfo, &out); err == nil {
+		t.Errorf("Expected streaming of tampered chunk to fail")
+	}
+	if out.String() != string(data[:8]) {
+		t.Errorf("Expected only the chunks before the tampered one, got %q", out.String())
+	}
+}
+
+func TestStoreFileChunkSize(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 4)
+	cm.SetMaxChunkSize(64)
+	data := []byte("files split with their own chunk sizes")
+
+	tests := []struct {
+		chunkSize  int
+		wantSize   int
+		wantChunks int
+	}{
+		{0, 4, 10}, // the manager's chunk size
+		{1, 1, 38},
+		{7, 7, 6},
+		{64, 64, 1},
+	}
+
+	for _, tt := range tests {
+		fileInfo := &types.FileInfo{ID: types.GenerateFileID("sized.txt", data), ChunkSize: tt.chunkSize}
+		if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+			t.Fatalf("Chunk size %d: failed to store: %v", tt.chunkSize, err)
+		}
+		if fileInfo.ChunkSize != tt.wantSize || len(fileInfo.Chunks) != tt.wantChunks {
+			t.Errorf("Chunk size %d: expected %d chunks of %d, got %d of %d",
+				tt.chunkSize, tt.wantChunks, tt.wantSize, len(fileInfo.Chunks), fileInfo.ChunkSize)
+		}
+
+		retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+		if err != nil {
+			t.Fatalf("Chunk size %d: failed to retrieve: %v", tt.chunkSize, err)
+		}
+		if !bytes.Equal(retrieved, data) {
+			t.Errorf("Chunk size %d: expected %q, got %q", tt.chunkSize, data, retrieved)
+		}
+
+		for _, r := range [][2]int64{{0, 0}, {3, 9}, {20, 37}} {
+			got, err := cm.RetrieveFileRange(context.Background(), fileInfo, r[0], r[1])
+			if err != nil {
+				t.Fatalf("Chunk size %d: failed to retrieve range %d-%d: %v", tt.chunkSize, r[0], r[1], err)
+			}
+			if !bytes.Equal(got, data[r[0]:r[1]+1]) {
+				t.Errorf("Chunk size %d: range %d-%d expected %q, got %q", tt.chunkSize, r[0], r[1], data[r[0]:r[1]+1], got)
+			}
+		}
+
+		if err := cm.DeleteFile(context.Background(), fileInfo); err != nil {
+			t.Fatalf("Chunk size %d: failed to delete: %v", tt.chunkSize, err)
+		}
+	}
+
+	tooLarge := &types.FileInfo{ID: types.GenerateFileID("large.txt", data), ChunkSize: 65}
+	if err := cm.StoreFile(context.Background(), tooLarge, data); err == nil {
+		t.Errorf("Expected chunk size above the maximum to be rejected")
+	}
+}
+
+func TestStoreFileStreamMatchesBuffered(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 1024)
+
+	for _, size := range []int{0, 1, 1024, 1025, 10*1024 + 7} {
+		data := bytes.Repeat([]byte("abcdefg"), size/7+1)[:size]
+		fileID := types.GenerateFileID("stream.bin", data)
+
+		buffered := &types.FileInfo{ID: fileID}
+		if err := cm.StoreFile(context.Background(), buffered, data); err != nil {
+			t.Fatalf("Size %d: failed to store buffered: %v", size, err)
+		}
+
+		// Stream through a reader that returns short reads
+		streamed := &types.FileInfo{ID: fileID}
+		if err := cm.StoreFileStream(context.Background(), streamed, &shortReader{data: data, max: 100}); err != nil {
+			t.Fatalf("Size %d: failed to store streamed: %v", size, err)
+		}
+
+		if streamed.Size != buffered.Size || streamed.Hash != buffered.Hash {
+			t.Errorf("Size %d: expected size/hash %d/%s, got %d/%s",
+				size, buffered.Size, buffered.Hash, streamed.Size, streamed.Hash)
+		}
+
+		if streamed.Hash != types.CalculateHash(data) {
+			t.Errorf("Size %d: streamed hash does not match content hash", size)
+		}
+
+		if len(streamed.Chunks) != len(buffered.Chunks) {
+			t.Fatalf("Size %d: expected %d chunks, got %d", size, len(buffered.Chunks), len(streamed.Chunks))
+		}
+
+		for i := range streamed.Chunks {
+			s, b := streamed.Chunks[i], buffered.Chunks[i]
+			if s.ID != b.ID || s.Index != b.Index || s.Size != b.Size || s.Hash != b.Hash {
+				t.Errorf("Size %d: chunk %d layout differs: %+v vs %+v", size, i, s, b)
+			}
+		}
+
+		retrieved, err := cm.RetrieveFile(context.Background(), streamed)
+		if err != nil {
+			t.Fatalf("Size %d: failed to retrieve: %v", size, err)
+		}
+		if !bytes.Equal(data, retrieved) {
+			t.Errorf("Size %d: retrieved data does not match", size)
+		}
+	}
+}
+
+// shortReader returns at most max bytes per Read call
+type shortReader struct {
+	data []byte
+	max  int
+}
+
+func (r *shortReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if n > r.max {
+		n = r.max
+	}
+	n = copy(p[:n], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestPerFileKeys(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+
+	data := []byte("encrypted under a file subkey")
+	fileInfo := &types.FileInfo{ID: "file-with-subkey"}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	for _, chunkInfo := range fileInfo.Chunks {
+		if chunkInfo.KeyID != fileInfo.ID {
+			t.Errorf("Expected chunk %d key ID %q, got %q", chunkInfo.Index, fileInfo.ID, chunkInfo.KeyID)
+		}
+	}
+
+	// The master key alone does not open the chunks
+	sealed, err := fileStorage.Retrieve(context.Background(), fileInfo.Chunks[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to read chunk: %v", err)
+	}
+	if _, err := cm.openChunk(cm.chunkKey(""), sealed, fileInfo.Chunks[0].Size); err == nil {
+		t.Errorf("Expected chunk not to decrypt with the master key")
+	}
+
+	// Chunks stored before per-file keys have no key ID and use the master key
+	legacy := fileInfo.Chunks[0]
+	legacy.KeyID = ""
+	fileStorage.Store(context.Background(), legacy.ID, mustSeal(t, cm, legacy, data[:4]))
+	if chunk, err := cm.retrieveChunk(context.Background(), legacy); err != nil || !bytes.Equal(chunk, data[:4]) {
+		t.Errorf("Expected legacy chunk %q, got %q (%v)", data[:4], chunk, err)
+	}
+}
+
+func TestChunkCipher(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 8)
+	cm.SetCipher(crypto.CipherChaCha20Poly1305)
+	data := []byte("sealed with chacha20-poly1305")
+
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("chacha.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	// cipherOf returns the cipher tag of a stored chunk
+	cipherOf := func(chunkID string) crypto.Cipher {
+		t.Helper()
+		stored, err := fileStorage.Retrieve(context.Background(), chunkID)
+		if err != nil {
+			t.Fatalf("Failed to read chunk: %v", err)
+		}
+		_, sealed, err := decodeChunkHeader(stored)
+		if err != nil {
+			t.Fatalf("Failed to decode chunk header: %v", err)
+		}
+		return crypto.Cipher(sealed[0])
+	}
+	for _, chunkInfo := range fileInfo.Chunks {
+		if c := cipherOf(chunkInfo.ID); c != crypto.CipherChaCha20Poly1305 {
+			t.Errorf("Expected chunk %d to be sealed with ChaCha20-Poly1305, got %s", chunkInfo.Index, c)
+		}
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil || !bytes.Equal(retrieved, data) {
+		t.Fatalf("Expected %q, got %q (%v)", data, retrieved, err)
+	}
+
+	// Chunks stay readable after switching ciphers, as each names its own
+	cm.SetCipher(crypto.CipherAES256GCM)
+	if retrieved, err := cm.RetrieveFile(context.Background(), fileInfo); err != nil || !bytes.Equal(retrieved, data) {
+		t.Errorf("Expected ChaCha20-Poly1305 chunks to stay readable, got %q (%v)", retrieved, err)
+	}
+
+	other := []byte("sealed with aes-256-gcm")
+	otherInfo := &types.FileInfo{ID: types.GenerateFileID("aes.txt", other)}
+	if err := cm.StoreFile(context.Background(), otherInfo, other); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+	if c := cipherOf(otherInfo.Chunks[0].ID); c != crypto.CipherAES256GCM {
+		t.Errorf("Expected new chunks to be sealed with AES-256-GCM, got %s", c)
+	}
+}
+
+func TestContentAddressedChunks(t *testing.T) {
+	data := []byte("identical bytes, two names")
+	contentID := types.GenerateContentID(data)
+
+	tests := []struct {
+		name        string
+		dedup       bool
+		wantAddress func(fileInfo *types.FileInfo) string
+	}{
+		{"with deduplication", true, func(*types.FileInfo) string { return contentID }},
+		{"without deduplication", false, func(fileInfo *types.FileInfo) string { return fileInfo.ID }},
+	}
+
+	for _, tt := range tests {
+		cm, fileStorage := newTestChunkManager(t, 4)
+		if tt.dedup {
+			cm.EnableDeduplication(metadata.NewMemoryStore())
+		}
+
+		var files []*types.FileInfo
+		for _, name := range []string{"a.txt", "b.txt"} {
+			fileInfo := &types.FileInfo{ID: types.GenerateFileID(name, data), ContentID: contentID}
+			if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+				t.Fatalf("%s: failed to store %s: %v", tt.name, name, err)
+			}
+			files = append(files, fileInfo)
+		}
+
+		for _, fileInfo := range files {
+			if keyID := fileInfo.Chunks[0].KeyID; keyID != tt.wantAddress(fileInfo) {
+				t.Errorf("%s: expected chunks addressed by %q, got %q", tt.name, tt.wantAddress(fileInfo), keyID)
+			}
+		}
+		if !tt.dedup {
+			continue
+		}
+
+		// Both files share one stored copy, which outlives deleting either of them
+		if keys, _ := fileStorage.List(); len(keys) != len(files[0].Chunks) {
+			t.Errorf("%s: expected %d stored chunks, got %d", tt.name, len(files[0].Chunks), len(keys))
+		}
+		if err := cm.DeleteFile(context.Background(), files[0]); err != nil {
+			t.Fatalf("%s: failed to delete file: %v", tt.name, err)
+		}
+		if retrieved, err := cm.RetrieveFile(context.Background(), files[1]); err != nil || !bytes.Equal(retrieved, data) {
+			t.Errorf("%s: expected remaining file to be readable, got %q (%v)", tt.name, retrieved, err)
+		}
+	}
+}
+
+func TestStoreFileSetsMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		erasure bool
+	}{
+		{"replicated", false},
+		{"erasure coded", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm, _ := newTestChunkManager(t, 4)
+			if tt.erasure {
+				if err := cm.SetErasureCoding(2, 1); err != nil {
+					t.Fatalf("Failed to enable erasure coding: %v", err)
+				}
+			}
+
+			data := []byte("metadata filled in by the chunk manager")
+			fileInfo := &types.FileInfo{ID: types.GenerateFileID("meta.txt", data), Name: "meta.txt"}
+			before := time.Now()
+			if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+				t.Fatalf("Failed to store file: %v", err)
+			}
+
+			if fileInfo.Size != int64(len(data)) {
+				t.Errorf("Expected size %d, got %d", len(data), fileInfo.Size)
+			}
+			if fileInfo.Hash != types.CalculateHash(data) {
+				t.Errorf("Expected hash %s, got %q", types.CalculateHash(data), fileInfo.Hash)
+			}
+			if !fileInfo.IsEncrypted {
+				t.Error("Expected file to be marked encrypted")
+			}
+			if fileInfo.CreatedAt.Before(before) || fileInfo.UpdatedAt.Before(before) {
+				t.Errorf("Expected timestamps to be set, got created %v updated %v", fileInfo.CreatedAt, fileInfo.UpdatedAt)
+			}
+		})
+	}
+
+	// Restoring content keeps the original creation time
+	cm, _ := newTestChunkManager(t, 4)
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fileInfo := &types.FileInfo{ID: "existing", CreatedAt: created}
+	if err := cm.StoreFile(context.Background(), fileInfo, []byte("again")); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+	if !fileInfo.CreatedAt.Equal(created) || !fileInfo.UpdatedAt.After(created) {
+		t.Errorf("Expected created %v kept and updated advanced, got %v and %v", created, fileInfo.CreatedAt, fileInfo.UpdatedAt)
+	}
+}
+
+func TestStoreFileHashAlgorithm(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 4)
+	cm.SetHashAlgorithm(types.HashSHA512)
+
+	data := []byte("hashed with sha-512")
+	fileInfo := &types.FileInfo{ID: "sha512-file"}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if fileInfo.HashAlgorithm != types.HashSHA512 || fileInfo.Hash != types.CalculateHashWith(types.HashSHA512, data) {
+		t.Errorf("Expected SHA-512 file hash, got %s %s", fileInfo.HashAlgorithm, fileInfo.Hash)
+	}
+	for _, chunkInfo := range fileInfo.Chunks {
+		if chunkInfo.HashAlgorithm != types.HashSHA512 || len(chunkInfo.Hash) != 128 {
+			t.Errorf("Chunk %d: expected SHA-512 hash, got %s %s", chunkInfo.Index, chunkInfo.HashAlgorithm, chunkInfo.Hash)
+		}
+	}
+
+	// Files keep verifying with their own algorithm after the default changes
+	cm.SetHashAlgorithm(types.HashSHA256)
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file: %v", err)
+	}
+	if !bytes.Equal(retrieved, data) {
+		t.Errorf("Expected %q, got %q", data, retrieved)
+	}
+}
+
+func TestRetrieveCorruptedChunk(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		cm, fileStorage := newTestChunkManager(t, 4)
+		cm.SetDownloadWorkers(workers)
+		data := []byte("corrupted on disk after it was stored")
+
+		fileInfo := &types.FileInfo{ID: types.GenerateFileID("corrupt.txt", data)}
+		if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+			t.Fatalf("Failed to store file: %v", err)
+		}
+
+		// Flip a byte of the third chunk's ciphertext in place
+		stored, err := fileStorage.Retrieve(context.Background(), fileInfo.Chunks[2].ID)
+		if err != nil {
+			t.Fatalf("Failed to read chunk: %v", err)
+		}
+		stored[len(stored)-1] ^= 0xff
+		fileStorage.Store(context.Background(), fileInfo.Chunks[2].ID, stored)
+
+		checkCorrupted := func(name string, err error) {
+			t.Helper()
+			var integrityErr *ChunkIntegrityError
+			if !errors.As(err, &integrityErr) || !errors.Is(err, ErrChunkCorrupted) {
+				t.Fatalf("%d workers, %s: expected ChunkIntegrityError, got %v", workers, name, err)
+			}
+			if integrityErr.Index != 2 || integrityErr.ChunkID != fileInfo.Chunks[2].ID {
+				t.Errorf("%d workers, %s: expected chunk 2 to be reported, got chunk %d", workers, name, integrityErr.Index)
+			}
+		}
+
+		_, err = cm.RetrieveFile(context.Background(), fileInfo)
+		checkCorrupted("buffered", err)
+
+		var out bytes.Buffer
+		checkCorrupted("streamed", cm.RetrieveFileTo(context.Background(), fileInfo, &out))
+		if out.String() != string(data[:8]) {
+			t.Errorf("%d workers: expected only the chunks before the corrupted one, got %q", workers, out.String())
+		}
+	}
+}
+
+func TestMerkleRootVerification(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	data := []byte("merkle verified file data")
+
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("merkle.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if fileInfo.MerkleRoot == "" {
+		t.Fatalf("Expected merkle root to be recorded")
+	}
+
+	// Every chunk should prove membership under the root
+	hashes := make([]string, len(fileInfo.Chunks))
+	for i, chunkInfo := range fileInfo.Chunks {
+		hashes[i] = chunkInfo.Hash
+	}
+	for i := range hashes {
+		proof, _ := types.MerkleProof(hashes, i)
+		if !types.VerifyChunkProof(fileInfo.MerkleRoot, hashes[i], proof, i) {
+			t.Errorf("Chunk %d: proof failed against file root", i)
+		}
+	}
+
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err != nil {
+		t.Fatalf("Failed to retrieve intact file: %v", err)
+	}
+
+	// Swap a chunk on disk for validly encrypted but different content
+	fileStorage.Store(context.Background(), fileInfo.Chunks[2].ID, mustSeal(t, cm, fileInfo.Chunks[2], []byte("evil")))
+
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err == nil {
+		t.Errorf("Expected retrieval of tampered chunk to fail")
+	}
+
+	// A tampered root is detected even when all chunks are intact
+	fileStorage.Store(context.Background(), fileInfo.Chunks[2].ID, mustSeal(t, cm, fileInfo.Chunks[2], data[8:12]))
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err != nil {
+		t.Fatalf("Failed to retrieve restored file: %v", err)
+	}
+
+	fileInfo.MerkleRoot = types.CalculateHash([]byte("wrong root"))
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err == nil {
+		t.Errorf("Expected retrieval with wrong merkle root to fail")
+	}
+}
+
+// mustSeal encodes and encrypts data the way the chunk manager stores chunkInfo
+func mustSeal(t *testing.T, cm *ChunkManager, chunkInfo types.ChunkInfo, data []byte) []byte {
+	t.Helper()
+	header := chunkHeader{FileID: chunkInfo.KeyID, Index: chunkInfo.Index}
+	encrypted, err := cm.sealChunk(cm.chunkKey(chunkInfo.KeyID), header, data)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	return encrypted
+}
+
+// slowStorage is a storage backend whose reads take delay, giving up early when the
+// context is done
+type slowStorage struct {
+	*FileStorage
+	delay time.Duration
+	reads int32
+}
+
+func (s *slowStorage) Retrieve(ctx context.Context, key string) ([]byte, error) {
+	atomic.AddInt32(&s.reads, 1)
+	select {
+	case <-time.After(s.delay):
+		return s.FileStorage.Retrieve(ctx, key)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// cancellingWriter cancels a context on its first write
+type cancellingWriter struct {
+	cancel context.CancelFunc
+	bytes.Buffer
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	return w.Buffer.Write(p)
+}
+
+func TestRetrieveFileCancelled(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		cm, fileStorage := newTestChunkManager(t, 4)
+		cm.SetDownloadWorkers(workers)
+
+		data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+		fileInfo := &types.FileInfo{ID: types.GenerateFileID("slow.txt", data), Name: "slow.txt"}
+		if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+			t.Fatalf("Failed to store file: %v", err)
+		}
+
+		slow := &slowStorage{FileStorage: fileStorage, delay: time.Second}
+		cm.storage = slow
+
+		// The client goes away while the first chunk is still being read
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := cm.RetrieveFile(ctx, fileInfo)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("workers=%d: expected context.Canceled, got %v", workers, err)
+		}
+		if elapsed := time.Since(start); elapsed > slow.delay/2 {
+			t.Errorf("workers=%d: expected retrieval to stop promptly, took %s", workers, elapsed)
+		}
+	}
+}
+
+func TestRetrieveFileToStopsWhenCancelled(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("stream.txt", data), Name: "stream.txt"}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	slow := &slowStorage{FileStorage: fileStorage, delay: time.Millisecond}
+	cm.storage = slow
+
+	// Cancelling after the first chunk is written leaves the rest unread
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancellingWriter{cancel: cancel}
+	if err := cm.RetrieveFileTo(ctx, fileInfo, w); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if w.Len() != 4 {
+		t.Errorf("Expected only the first chunk to be written, got %d bytes", w.Len())
+	}
+	if reads := atomic.LoadInt32(&slow.reads); reads > 2 {
+		t.Errorf("Expected reads to stop after cancellation, got %d of %d", reads, len(fileInfo.Chunks))
+	}
+}
+
+func TestStoreFileCancelled(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("cancelled.txt", data), Name: "cancelled.txt"}
+	if err := cm.StoreFile(ctx, fileInfo, data); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	keys, err := fileStorage.List()
+	if err != nil {
+		t.Fatalf("Failed to list chunks: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("Expected no chunks to be left behind, got %d", len(keys))
+	}
+}
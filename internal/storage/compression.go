@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// Chunk codecs, recorded in a one-byte header in front of the chunk data before encryption
+const (
+	codecNone byte = 0
+	codecGzip byte = 1
+)
+
+// SetCompression enables compressing chunks with the named codec before they are encrypted.
+// An empty name or "none" disables compression.
+func (cm *ChunkManager) SetCompression(name string) error {
+	var codec byte
+	switch name {
+	case "", "none":
+		codec = codecNone
+	case "gzip":
+		codec = codecGzip
+	default:
+		return fmt.Errorf("unsupported compression codec: %s", name)
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.compression = codec
+	return nil
+}
+
+// sealChunk prepends the codec header, compressing the chunk when that makes it smaller,
+// and the chunk's length and checksum, encrypts the result with key under the selected
+// cipher and puts the chunk header in front, authenticated as additional data
+func (cm *ChunkManager) sealChunk(key crypto.EncryptionKey, header chunkHeader, chunk []byte) ([]byte, error) {
+	cm.mu.RLock()
+	codec, cipher := cm.compression, cm.cipher
+	cm.mu.RUnlock()
+
+	if header.Algorithm == "" {
+		header.Algorithm = types.DefaultHashAlgorithm
+	}
+	hasher := header.Algorithm.New()
+	hasher.Write(chunk)
+	header.Length = int64(len(chunk))
+	header.Checksum = hasher.Sum(nil)
+	digest := encodeChunkDigest(header)
+
+	encoded := append(append(digest, codecNone), chunk...)
+	if codec == codecGzip {
+		var buf bytes.Buffer
+		buf.Write(digest)
+		buf.WriteByte(codecGzip)
+
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(chunk); err != nil {
+			return nil, fmt.Errorf("failed to compress chunk: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress chunk: %w", err)
+		}
+
+		// Incompressible data is stored as is
+		if buf.Len() < len(encoded) {
+			encoded = buf.Bytes()
+		}
+	}
+
+	encodedHeader := encodeChunkHeader(header)
+	nonces := cm.keyRing().Nonces(header.keyVersion())
+	encrypted, err := crypto.EncryptWithAD(cipher, encoded, encodedHeader, key, nonces)
+	if err != nil {
+		return nil, err
+	}
+	return append(encodedHeader, encrypted...), nil
+}
+
+// openStoredChunk opens a chunk as stored for chunkInfo with the key version its header names
+func (cm *ChunkManager) openStoredChunk(chunkInfo types.ChunkInfo, stored []byte) ([]byte, error) {
+	header, _, err := decodeChunkHeader(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := cm.versionKey(header.keyVersion(), chunkInfo.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return cm.openChunk(key, stored, chunkInfo.Size)
+}
+
+// openChunk strips the chunk header, decrypts the sealed chunk with key and decompresses
+// it if needed, checking the result against the length and checksum recorded for it.
+// The decompressed size is limited to size bytes.
+func (cm *ChunkManager) openChunk(key crypto.EncryptionKey, stored []byte, size int64) ([]byte, error) {
+	header, sealed, err := decodeChunkHeader(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := crypto.DecryptWithAD(sealed, stored[:len(stored)-len(sealed)], key)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, err = header.decodeDigest(encoded); err != nil {
+		return nil, err
+	}
+
+	chunk, err := decodeChunk(encoded, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := header.verify(chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// decodeChunk decompresses a decrypted chunk according to its codec header
+func decodeChunk(encoded []byte, size int64) ([]byte, error) {
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("chunk is missing its codec header")
+	}
+
+	switch encoded[0] {
+	case codecNone:
+		return encoded[1:], nil
+	case codecGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(encoded[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress chunk: %w", err)
+		}
+		defer zr.Close()
+
+		chunk, err := io.ReadAll(io.LimitReader(zr, size+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress chunk: %w", err)
+		}
+		if int64(len(chunk)) > size {
+			return nil, fmt.Errorf("decompressed chunk exceeds %d bytes", size)
+		}
+		return chunk, nil
+	default:
+		return nil, fmt.Errorf("unknown chunk codec: %d", encoded[0])
+	}
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// chunkBucket is the metadata bucket holding chunk reference counts, keyed by content hash
+const chunkBucket = "chunks"
+
+// chunkRecord tracks a stored chunk shared by one or more files
+type chunkRecord struct {
+	ID       string   `json:"id"`
+	Checksum string   `json:"checksum"`
+	NodeIDs  []string `json:"node_ids"`
+	KeyID    string   `json:"key_id,omitempty"`
+	RefCount int      `json:"ref_count"`
+
+	KeyVersion int `json:"key_version,omitempty"`
+}
+
+// EnableDeduplication makes the chunk manager store each distinct chunk content once,
+// tracking reference counts in the metadata store
+func (cm *ChunkManager) EnableDeduplication(store metadata.Store) {
+	cm.refMu.Lock()
+	defer cm.refMu.Unlock()
+
+	cm.refs = store
+}
+
+// reuseChunk increments the reference count of an already stored chunk with the given
+// content hash and returns its layout. It returns false if no such chunk exists.
+func (cm *ChunkManager) reuseChunk(hash string, index int, size int64) (types.ChunkInfo, bool, error) {
+	cm.refMu.Lock()
+	defer cm.refMu.Unlock()
+
+	if cm.refs == nil {
+		return types.ChunkInfo{}, false, nil
+	}
+
+	record, err := cm.getChunkRecord(hash)
+	if err != nil || record == nil {
+		return types.ChunkInfo{}, false, err
+	}
+
+	// The record is stale if the chunk has disappeared from local storage
+	if !cm.storage.Exists(record.ID) {
+		return types.ChunkInfo{}, false, nil
+	}
+
+	record.RefCount++
+	if err := cm.refs.Put(chunkBucket, hash, record); err != nil {
+		return types.ChunkInfo{}, false, err
+	}
+
+	return record.chunkInfo(index, size, hash), true, nil
+}
+
+// registerChunk records a newly stored chunk with a reference count of one. If another
+// upload registered the same content concurrently, that chunk is reused and the
+// duplicate copy is removed.
+func (cm *ChunkManager) registerChunk(chunkInfo types.ChunkInfo) (types.ChunkInfo, error) {
+	cm.refMu.Lock()
+	defer cm.refMu.Unlock()
+
+	if cm.refs == nil {
+		return chunkInfo, nil
+	}
+
+	record, err := cm.getChunkRecord(chunkInfo.Hash)
+	if err != nil {
+		return chunkInfo, err
+	}
+
+	if record != nil && record.ID != chunkInfo.ID && cm.storage.Exists(record.ID) {
+		record.RefCount++
+		if err := cm.refs.Put(chunkBucket, chunkInfo.Hash, record); err != nil {
+			return chunkInfo, err
+		}
+		cm.removeChunk(chunkInfo)
+		return record.chunkInfo(chunkInfo.Index, chunkInfo.Size, chunkInfo.Hash), nil
+	}
+
+	if record != nil && record.ID == chunkInfo.ID {
+		record.RefCount++
+	} else {
+		record = &chunkRecord{
+			ID:       chunkInfo.ID,
+			Checksum: chunkInfo.Checksum,
+			NodeIDs:  chunkInfo.NodeIDs,
+			KeyID:    chunkInfo.KeyID,
+			RefCount: 1,
+
+			KeyVersion: chunkInfo.KeyVersion,
+		}
+	}
+
+	return chunkInfo, cm.refs.Put(chunkBucket, chunkInfo.Hash, record)
+}
+
+// releaseChunk decrements a chunk's reference count and reports whether the chunk is no
+// longer referenced and should be removed
+func (cm *ChunkManager) releaseChunk(chunkInfo types.ChunkInfo) (bool, error) {
+	cm.refMu.Lock()
+	defer cm.refMu.Unlock()
+
+	if cm.refs == nil {
+		return true, nil
+	}
+
+	record, err := cm.getChunkRecord(chunkInfo.Hash)
+	if err != nil {
+		return false, err
+	}
+	if record == nil || record.ID != chunkInfo.ID {
+		// Not tracked, so this file is the only reference
+		return true, nil
+	}
+
+	record.RefCount--
+	if record.RefCount > 0 {
+		return false, cm.refs.Put(chunkBucket, chunkInfo.Hash, record)
+	}
+
+	return true, cm.refs.Delete(chunkBucket, chunkInfo.Hash)
+}
+
+// ErrChunksNotShareable is returned when a file's chunks cannot be referenced by a copy
+var ErrChunksNotShareable = errors.New("chunks cannot be shared")
+
+// ShareChunks takes another reference to each of the file's chunks, so that a copy of
+// the file can use them and deleting either one releases only its own references.
+// It fails with ErrChunksNotShareable when reference counts are not tracked, for
+// erasure coded files, whose shards are never deduplicated, and for chunks whose content
+// is tracked under another chunk.
+func (cm *ChunkManager) ShareChunks(fileInfo *types.FileInfo) error {
+	if fileInfo.Erasure != nil {
+		return fmt.Errorf("%w: file %s is erasure coded", ErrChunksNotShareable, fileInfo.ID)
+	}
+
+	cm.refMu.Lock()
+	defer cm.refMu.Unlock()
+
+	if cm.refs == nil {
+		return fmt.Errorf("%w: deduplication is disabled", ErrChunksNotShareable)
+	}
+
+	for i, chunkInfo := range fileInfo.Chunks {
+		if err := cm.shareChunk(chunkInfo); err != nil {
+			cm.unshareChunks(fileInfo.Chunks[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// shareChunk takes another reference to a chunk. Callers must hold refMu.
+func (cm *ChunkManager) shareChunk(chunkInfo types.ChunkInfo) error {
+	record, err := cm.getChunkRecord(chunkInfo.Hash)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case record == nil:
+		// Chunks stored before deduplication was enabled become tracked, counting the
+		// reference of the file they were stored for as well
+		record = &chunkRecord{
+			ID:       chunkInfo.ID,
+			Checksum: chunkInfo.Checksum,
+			NodeIDs:  chunkInfo.NodeIDs,
+			KeyID:    chunkInfo.KeyID,
+			RefCount: 2,
+
+			KeyVersion: chunkInfo.KeyVersion,
+		}
+	case record.ID != chunkInfo.ID:
+		return fmt.Errorf("%w: chunk %d is tracked under another chunk", ErrChunksNotShareable, chunkInfo.Index)
+	default:
+		record.RefCount++
+	}
+
+	return cm.refs.Put(chunkBucket, chunkInfo.Hash, record)
+}
+
+// unshareChunks gives back the references taken by shareChunk after a failed share,
+// logging failures. Callers must hold refMu.
+func (cm *ChunkManager) unshareChunks(chunks []types.ChunkInfo) {
+	for _, chunkInfo := range chunks {
+		record, err := cm.getChunkRecord(chunkInfo.Hash)
+		if err == nil && record != nil {
+			record.RefCount--
+			err = cm.refs.Put(chunkBucket, chunkInfo.Hash, record)
+		}
+		if err != nil {
+			cm.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Failed to release shared chunk")
+		}
+	}
+}
+
+// updateChunkRecord records the new stored form of a re-encrypted chunk, if it is tracked
+func (cm *ChunkManager) updateChunkRecord(chunkInfo types.ChunkInfo) error {
+	cm.refMu.Lock()
+	defer cm.refMu.Unlock()
+
+	if cm.refs == nil {
+		return nil
+	}
+
+	record, err := cm.getChunkRecord(chunkInfo.Hash)
+	if err != nil || record == nil || record.ID != chunkInfo.ID {
+		return err
+	}
+
+	record.Checksum = chunkInfo.Checksum
+	record.KeyVersion = chunkInfo.KeyVersion
+	return cm.refs.Put(chunkBucket, chunkInfo.Hash, record)
+}
+
+// ChunkRefCount returns the number of references to the chunk with the given content hash
+func (cm *ChunkManager) ChunkRefCount(hash string) (int, error) {
+	cm.refMu.Lock()
+	defer cm.refMu.Unlock()
+
+	if cm.refs == nil {
+		return 0, nil
+	}
+
+	record, err := cm.getChunkRecord(hash)
+	if err != nil || record == nil {
+		return 0, err
+	}
+	return record.RefCount, nil
+}
+
+// getChunkRecord loads the record for a content hash, returning nil if none exists.
+// Callers must hold refMu.
+func (cm *ChunkManager) getChunkRecord(hash string) (*chunkRecord, error) {
+	var record chunkRecord
+	if err := cm.refs.Get(chunkBucket, hash, &record); err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// chunkInfo builds the layout entry for a file referencing this chunk
+func (r *chunkRecord) chunkInfo(index int, size int64, hash string) types.ChunkInfo {
+	return types.ChunkInfo{
+		ID:       r.ID,
+		Index:    index,
+		Size:     size,
+		Hash:     hash,
+		NodeIDs:  r.NodeIDs,
+		Checksum: r.Checksum,
+		KeyID:    r.KeyID,
+
+		KeyVersion: r.KeyVersion,
+	}
+}
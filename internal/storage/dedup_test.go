@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestChunkDeduplication(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	cm.EnableDeduplication(metadata.NewMemoryStore())
+
+	// The files share their first two chunks
+	data1 := []byte("AAAABBBBCCCC")
+	data2 := []byte("AAAABBBBDDDD")
+
+	file1 := &types.FileInfo{ID: types.GenerateFileID("one.txt", data1)}
+	if err := cm.StoreFile(context.Background(), file1, data1); err != nil {
+		t.Fatalf("Failed to store first file: %v", err)
+	}
+
+	file2 := &types.FileInfo{ID: types.GenerateFileID("two.txt", data2)}
+	if err := cm.StoreFile(context.Background(), file2, data2); err != nil {
+		t.Fatalf("Failed to store second file: %v", err)
+	}
+
+	// Shared chunks should be stored once
+	keys, _ := fileStorage.List()
+	if len(keys) != 4 {
+		t.Errorf("Expected 4 distinct stored chunks, got %d", len(keys))
+	}
+
+	for i := 0; i < 2; i++ {
+		if file1.Chunks[i].ID != file2.Chunks[i].ID {
+			t.Errorf("Chunk %d: expected shared chunk ID", i)
+		}
+		if count, _ := cm.ChunkRefCount(file1.Chunks[i].Hash); count != 2 {
+			t.Errorf("Chunk %d: expected refcount 2, got %d", i, count)
+		}
+	}
+
+	// Deleting one file keeps the shared chunks
+	if err := cm.DeleteFile(context.Background(), file1); err != nil {
+		t.Fatalf("Failed to delete first file: %v", err)
+	}
+
+	keys, _ = fileStorage.List()
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 stored chunks after delete, got %d", len(keys))
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), file2)
+	if err != nil {
+		t.Fatalf("Failed to retrieve second file: %v", err)
+	}
+	if !bytes.Equal(data2, retrieved) {
+		t.Errorf("Expected %s, got %s", data2, retrieved)
+	}
+
+	// Deleting the last reference removes everything
+	if err := cm.DeleteFile(context.Background(), file2); err != nil {
+		t.Fatalf("Failed to delete second file: %v", err)
+	}
+
+	keys, _ = fileStorage.List()
+	if len(keys) != 0 {
+		t.Errorf("Expected no stored chunks, got %d", len(keys))
+	}
+}
+
+func TestChunkDeduplicationWithinFile(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	store := metadata.NewMemoryStore()
+	cm.EnableDeduplication(store)
+
+	data := []byte("XXXXXXXXXXXX")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("repeat.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	keys, _ := fileStorage.List()
+	if len(keys) != 1 {
+		t.Errorf("Expected 1 stored chunk, got %d", len(keys))
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil || !bytes.Equal(data, retrieved) {
+		t.Errorf("Expected %s, got %s (%v)", data, retrieved, err)
+	}
+
+	if err := cm.DeleteFile(context.Background(), fileInfo); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	keys, _ = fileStorage.List()
+	if len(keys) != 0 {
+		t.Errorf("Expected no stored chunks, got %d", len(keys))
+	}
+
+	if refs, _ := store.Keys(chunkBucket); len(refs) != 0 {
+		t.Errorf("Expected no chunk records, got %v", refs)
+	}
+}
+
+func TestShareChunks(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	data := []byte("AAAABBBBCC")
+
+	// Chunks stored before deduplication was enabled are not tracked yet
+	original := &types.FileInfo{ID: types.GenerateFileID("original.txt", data)}
+	if err := cm.StoreFile(context.Background(), original, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+	if err := cm.ShareChunks(original); !errors.Is(err, ErrChunksNotShareable) {
+		t.Errorf("Expected ErrChunksNotShareable without deduplication, got %v", err)
+	}
+
+	cm.EnableDeduplication(metadata.NewMemoryStore())
+	if err := cm.ShareChunks(original); err != nil {
+		t.Fatalf("Failed to share chunks: %v", err)
+	}
+	for i, chunkInfo := range original.Chunks {
+		if count, _ := cm.ChunkRefCount(chunkInfo.Hash); count != 2 {
+			t.Errorf("Chunk %d: expected refcount 2, got %d", i, count)
+		}
+	}
+
+	// Deleting the original keeps the chunks the copy references
+	copied := *original
+	copied.ID = "copy"
+	if err := cm.DeleteFile(context.Background(), original); err != nil {
+		t.Fatalf("Failed to delete original: %v", err)
+	}
+	retrieved, err := cm.RetrieveFile(context.Background(), &copied)
+	if err != nil {
+		t.Fatalf("Failed to retrieve copy: %v", err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Errorf("Expected %s, got %s", data, retrieved)
+	}
+
+	if err := cm.DeleteFile(context.Background(), &copied); err != nil {
+		t.Fatalf("Failed to delete copy: %v", err)
+	}
+	if keys, _ := fileStorage.List(); len(keys) != 0 {
+		t.Errorf("Expected no stored chunks after deleting both, got %d", len(keys))
+	}
+
+	erasureFile := &types.FileInfo{ID: "coded", Erasure: &types.ErasureInfo{DataShards: 2, ParityShards: 1}}
+	if err := cm.ShareChunks(erasureFile); !errors.Is(err, ErrChunksNotShareable) {
+		t.Errorf("Expected ErrChunksNotShareable for an erasure coded file, got %v", err)
+	}
+}
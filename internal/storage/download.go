@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// SetDownloadWorkers sets how many chunks of a file are fetched concurrently on retrieval.
+// With more than one worker, chunks are spread across the nodes holding replicas of them.
+func (cm *ChunkManager) SetDownloadWorkers(workers int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.downloadWorkers = workers
+}
+
+// retrieveChunksParallel fetches the chunks of a file with a bounded pool of workers and
+// returns them in index order. Each chunk is first requested from a different node of its
+// replica set, moving on to the next replica if a fetch fails.
+func (cm *ChunkManager) retrieveChunksParallel(ctx context.Context, fileInfo *types.FileInfo, workers int) ([][]byte, error) {
+	chunks := make([][]byte, len(fileInfo.Chunks))
+	errs := make([]error, len(fileInfo.Chunks))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(fileInfo.Chunks); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for position := range jobs {
+				chunks[position], errs[position] = cm.fetchChunk(ctx, fileInfo.Chunks[position], position)
+			}
+		}()
+	}
+
+	for position := range fileInfo.Chunks {
+		jobs <- position
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+// fetchChunk retrieves a chunk from the nodes holding it, starting at the node selected by
+// position so that consecutive chunks are read from different nodes
+func (cm *ChunkManager) fetchChunk(ctx context.Context, chunkInfo types.ChunkInfo, position int) ([]byte, error) {
+	nodeIDs := chunkInfo.NodeIDs
+	if len(nodeIDs) == 0 {
+		return cm.retrieveChunk(ctx, chunkInfo)
+	}
+
+	lastErr := fmt.Errorf("no replica available for chunk %d", chunkInfo.Index)
+	for i := range nodeIDs {
+		nodeID := nodeIDs[(position+i)%len(nodeIDs)]
+
+		chunk, err := cm.fetchChunkFrom(ctx, chunkInfo, nodeID)
+		if err == nil {
+			return chunk, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		cm.logger.WithError(err).WithFields(logrus.Fields{
+			"chunk_id": chunkInfo.ID,
+			"node_id":  nodeID,
+		}).Warn("Failed to fetch chunk, trying next replica")
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("failed to retrieve chunk %d: %w", chunkInfo.Index, lastErr)
+}
+
+// fetchChunkFrom retrieves and verifies a chunk from a single node
+func (cm *ChunkManager) fetchChunkFrom(ctx context.Context, chunkInfo types.ChunkInfo, nodeID string) ([]byte, error) {
+	cm.mu.RLock()
+	local := nodeID == cm.nodeID
+	peer, exists := cm.peers[nodeID]
+	cm.mu.RUnlock()
+
+	var encrypted []byte
+	var err error
+	switch {
+	case local:
+		encrypted, err = cm.storage.Retrieve(ctx, chunkInfo.ID)
+	case exists:
+		encrypted, err = peer.RetrieveChunk(chunkInfo.ID)
+	default:
+		return nil, fmt.Errorf("unknown node %s", nodeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject corrupted replicas before spending time on decryption
+	if chunkInfo.Checksum != "" && types.CalculateHash(encrypted) != chunkInfo.Checksum {
+		return nil, fmt.Errorf("%w on node %s", &ChunkIntegrityError{Index: chunkInfo.Index, ChunkID: chunkInfo.ID}, nodeID)
+	}
+
+	return cm.openVerifiedChunk(chunkInfo, encrypted)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// countingPeer wraps a mock peer and counts chunk retrievals
+type countingPeer struct {
+	*mockPeer
+	fetches int32
+}
+
+func (p *countingPeer) RetrieveChunk(chunkID string) ([]byte, error) {
+	atomic.AddInt32(&p.fetches, 1)
+	return p.mockPeer.RetrieveChunk(chunkID)
+}
+
+// newMultiNodeChunkManager creates a chunk manager replicating every chunk to all of its peers
+func newMultiNodeChunkManager(t *testing.T, chunkSize, peerCount int) (*ChunkManager, *FileStorage, []*countingPeer) {
+	t.Helper()
+
+	cm, fileStorage := newTestChunkManager(t, chunkSize)
+	cm.SetReplication("node-0", peerCount+1)
+	cm.SetDownloadWorkers(4)
+
+	var peers []*countingPeer
+	for i := 1; i <= peerCount; i++ {
+		peer := &countingPeer{mockPeer: newMockPeer(fmt.Sprintf("node-%d", i))}
+		peers = append(peers, peer)
+		cm.AddPeer(peer)
+	}
+	return cm, fileStorage, peers
+}
+
+func TestParallelRetrieveSpreadsAcrossNodes(t *testing.T) {
+	cm, fileStorage, peers := newMultiNodeChunkManager(t, 16, 2)
+
+	data := bytes.Repeat([]byte("parallel multi-node download "), 40)
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("parallel.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file: %v", err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Errorf("Expected reassembled data to match original (%d bytes), got %d bytes", len(data), len(retrieved))
+	}
+
+	// Every node, local included, should have served part of the file
+	var peerFetches int32
+	for _, peer := range peers {
+		if peer.fetches == 0 {
+			t.Errorf("Expected peer %s to serve chunks", peer.id)
+		}
+		peerFetches += peer.fetches
+	}
+	if int(peerFetches) >= len(fileInfo.Chunks) {
+		t.Errorf("Expected some chunks to be read locally, got %d peer fetches for %d chunks", peerFetches, len(fileInfo.Chunks))
+	}
+
+	// The local copies should not be needed either
+	for _, chunkInfo := range fileInfo.Chunks {
+		fileStorage.Delete(context.Background(), chunkInfo.ID)
+	}
+	retrieved, err = cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file from peers: %v", err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Error("Expected file retrieved from peers to match original")
+	}
+}
+
+func TestParallelRetrieveFailsOver(t *testing.T) {
+	cm, fileStorage, peers := newMultiNodeChunkManager(t, 8, 3)
+
+	data := bytes.Repeat([]byte("failover "), 30)
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("failover.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	// Lose the local copies, take one peer offline and corrupt every replica on another
+	for _, chunkInfo := range fileInfo.Chunks {
+		fileStorage.Delete(context.Background(), chunkInfo.ID)
+	}
+	peers[0].offline = true
+	for chunkID, chunk := range peers[1].chunks {
+		corrupted := append([]byte{}, chunk...)
+		corrupted[len(corrupted)-1] ^= 0xff
+		peers[1].chunks[chunkID] = corrupted
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file with failover: %v", err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Error("Expected failover retrieval to match original")
+	}
+
+	// With the last intact replica gone the download fails
+	peers[2].offline = true
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err == nil {
+		t.Error("Expected error when no replica is available")
+	}
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/erasure"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// SetErasureCoding switches the chunk manager from full replication to Reed-Solomon
+// erasure coding with the given number of data and parity shards per stripe.
+// Passing zero data shards switches back to replication.
+func (cm *ChunkManager) SetErasureCoding(dataShards, parityShards int) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if dataShards == 0 {
+		cm.erasure = nil
+		return nil
+	}
+
+	enc, err := erasure.New(dataShards, parityShards)
+	if err != nil {
+		return err
+	}
+	cm.erasure = enc
+	return nil
+}
+
+// storeFileErasure reads r in stripes of dataShards chunks of chunkSize bytes, computes
+// parity shards for each stripe and spreads the shards across the local node and its peers
+func (cm *ChunkManager) storeFileErasure(ctx context.Context, fileInfo *types.FileInfo, r io.Reader, enc *erasure.Encoder, algorithm types.HashAlgorithm, chunkSize int) error {
+	var chunks []types.ChunkInfo
+	var size int64
+	hasher := newFileHasher(algorithm)
+	stripeSize := chunkSize * enc.DataShards()
+	shardsPerStripe := enc.DataShards() + enc.ParityShards()
+
+	err := utils.SplitReader(r, stripeSize, func(stripe int, data []byte) error {
+		hasher.Write(data)
+		size += int64(len(data))
+
+		shards := enc.Split(data)
+		if err := enc.Encode(shards); err != nil {
+			return fmt.Errorf("failed to encode stripe %d: %w", stripe, err)
+		}
+
+		for i, shard := range shards {
+			chunkInfo, err := cm.storeShard(ctx, fileInfo.ID, stripe*shardsPerStripe+i, shard, i >= enc.DataShards(), algorithm)
+			if err != nil {
+				return err
+			}
+			chunks = append(chunks, chunkInfo)
+		}
+		return nil
+	})
+	if err != nil {
+		cm.deleteChunks(chunks)
+		return err
+	}
+
+	fileInfo.Chunks = chunks
+	fileInfo.ChunkSize = chunkSize
+	fileInfo.Replicas = 1
+	fileInfo.Size = size
+	fileInfo.Hash = hasher.sum()
+	fileInfo.HashAlgorithm = algorithm
+	fileInfo.IsEncrypted = true
+	fileInfo.Erasure = &types.ErasureInfo{
+		DataShards:   enc.DataShards(),
+		ParityShards: enc.ParityShards(),
+	}
+	if err := setMerkleRoot(fileInfo); err != nil {
+		cm.deleteChunks(chunks)
+		return err
+	}
+	stampFile(fileInfo)
+
+	cm.logger.WithFields(logrus.Fields{
+		"file_id":       fileInfo.ID,
+		"shards":        len(chunks),
+		"data_shards":   enc.DataShards(),
+		"parity_shards": enc.ParityShards(),
+		"size":          fileInfo.Size,
+	}).Debug("Stored erasure coded file")
+
+	return nil
+}
+
+// storeShard encrypts a shard and places it on a single node chosen by its position,
+// so that the shards of a stripe land on different nodes where possible
+func (cm *ChunkManager) storeShard(ctx context.Context, fileID string, index int, shard []byte, parity bool, algorithm types.HashAlgorithm) (types.ChunkInfo, error) {
+	keyVersion, key := cm.currentKey(fileID)
+	header := chunkHeader{FileID: fileID, Index: index, Flags: chunkFlagErasure, Algorithm: algorithm, KeyVersion: keyVersion}
+	encrypted, err := cm.sealChunk(key, header, shard)
+	if err != nil {
+		return types.ChunkInfo{}, fmt.Errorf("failed to encrypt shard %d: %w", index, err)
+	}
+
+	chunkID, err := cm.IDGenerator().ChunkID(fileID, index, shard)
+	if err != nil {
+		return types.ChunkInfo{}, fmt.Errorf("failed to identify shard %d: %w", index, err)
+	}
+	cm.keepChunk(chunkID)
+	nodeID, err := cm.placeShard(ctx, chunkID, encrypted, index)
+	if err != nil {
+		return types.ChunkInfo{}, fmt.Errorf("failed to store shard %d: %w", index, err)
+	}
+
+	return types.ChunkInfo{
+		ID:       chunkID,
+		Index:    index,
+		Size:     int64(len(shard)),
+		Hash:     types.CalculateHashWith(algorithm, shard),
+		NodeIDs:  []string{nodeID},
+		Checksum: types.CalculateHash(encrypted),
+		Parity:   parity,
+		KeyID:    fileID,
+
+		HashAlgorithm: algorithm,
+		KeyVersion:    int(keyVersion),
+	}, nil
+}
+
+// placeShard stores a shard on the node at position in the sorted node list,
+// moving on to the next node if that one fails
+func (cm *ChunkManager) placeShard(ctx context.Context, chunkID string, data []byte, position int) (string, error) {
+	cm.mu.RLock()
+	nodeIDs := make([]string, 0, len(cm.peers))
+	for id := range cm.peers {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	nodeIDs = append([]string{cm.nodeID}, nodeIDs...)
+	peers := cm.peers
+	cm.mu.RUnlock()
+
+	var lastErr error
+	for i := 0; i < len(nodeIDs); i++ {
+		nodeID := nodeIDs[(position+i)%len(nodeIDs)]
+
+		if nodeID == cm.nodeID {
+			lastErr = cm.storage.Store(ctx, chunkID, data)
+		} else {
+			lastErr = peers[nodeID].StoreChunk(chunkID, data)
+		}
+
+		if lastErr == nil {
+			return nodeID, nil
+		}
+
+		cm.logger.WithError(lastErr).WithFields(logrus.Fields{
+			"chunk_id": chunkID,
+			"node_id":  nodeID,
+		}).Warn("Failed to place shard")
+	}
+
+	return "", lastErr
+}
+
+// retrieveErasureRangeTo writes the bytes in the inclusive range [start, end] of an
+// erasure coded file to w, decoding one stripe at a time and skipping the stripes before
+// the range, reconstructing any missing or corrupted shards from parity. Every shard of
+// a stripe is checked against its recorded hash before any of the stripe is written.
+func (cm *ChunkManager) retrieveErasureRangeTo(ctx context.Context, fileInfo *types.FileInfo, start, end int64, w io.Writer) error {
+	enc, err := erasure.New(fileInfo.Erasure.DataShards, fileInfo.Erasure.ParityShards)
+	if err != nil {
+		return err
+	}
+
+	shardsPerStripe := enc.DataShards() + enc.ParityShards()
+	if len(fileInfo.Chunks)%shardsPerStripe != 0 {
+		return fmt.Errorf("file has %d shards, not a multiple of %d", len(fileInfo.Chunks), shardsPerStripe)
+	}
+	if fileInfo.ChunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size: %d", fileInfo.ChunkSize)
+	}
+
+	// Every stripe but the last holds a full chunk in each data shard
+	stripeSize := int64(fileInfo.ChunkSize) * int64(enc.DataShards())
+	first := int(start / stripeSize)
+	if first*shardsPerStripe >= len(fileInfo.Chunks) {
+		return fmt.Errorf("file %s has %d stripes, range starts in stripe %d", fileInfo.ID, len(fileInfo.Chunks)/shardsPerStripe, first)
+	}
+
+	for index := first; index*shardsPerStripe < len(fileInfo.Chunks); index++ {
+		stripeStart := int64(index) * stripeSize
+		if stripeStart > end {
+			break
+		}
+		stripeLen := min64(stripeSize, fileInfo.Size-stripeStart)
+
+		data, err := cm.decodeStripe(ctx, enc, fileInfo.Chunks[index*shardsPerStripe:(index+1)*shardsPerStripe], index, stripeLen)
+		if err != nil {
+			return err
+		}
+
+		from := max64(start, stripeStart) - stripeStart
+		to := min64(end, stripeStart+stripeLen-1) - stripeStart + 1
+		if _, err := w.Write(data[from:to]); err != nil {
+			return fmt.Errorf("failed to write file data: %w", err)
+		}
+	}
+	return nil
+}
+
+// decodeStripe retrieves the shards of one stripe, reconstructs any that are missing or
+// corrupted and returns the first size bytes of the data they hold
+func (cm *ChunkManager) decodeStripe(ctx context.Context, enc *erasure.Encoder, stripe []types.ChunkInfo, index int, size int64) ([]byte, error) {
+	shards := make([][]byte, len(stripe))
+	for i, chunkInfo := range stripe {
+		shard, err := cm.retrieveChunk(ctx, chunkInfo)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			cm.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Shard unavailable, reconstructing")
+			continue
+		}
+		shards[i] = shard
+	}
+
+	if err := enc.Reconstruct(shards); err != nil {
+		if errors.Is(err, erasure.ErrTooFewShards) {
+			return nil, fmt.Errorf("stripe %d is unrecoverable: %w", index, err)
+		}
+		return nil, err
+	}
+
+	// Reconstructed shards must match the hashes the Merkle root was checked over
+	for i, shard := range shards {
+		if chunkHash(stripe[i], shard) != stripe[i].Hash {
+			return nil, &ChunkIntegrityError{ChunkID: stripe[i].ID, Index: stripe[i].Index}
+		}
+	}
+
+	return enc.Join(shards, int(size))
+}
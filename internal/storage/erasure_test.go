@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestErasureCodedFile(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 16)
+	if err := cm.SetErasureCoding(4, 2); err != nil {
+		t.Fatalf("Failed to enable erasure coding: %v", err)
+	}
+
+	// Two full stripes plus a partial one
+	data := make([]byte, 2*4*16+21)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("erasure.bin", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if fileInfo.Erasure == nil || fileInfo.Erasure.DataShards != 4 || fileInfo.Erasure.ParityShards != 2 {
+		t.Fatalf("Expected erasure layout 4+2, got %+v", fileInfo.Erasure)
+	}
+
+	if len(fileInfo.Chunks) != 3*6 {
+		t.Fatalf("Expected 18 shards, got %d", len(fileInfo.Chunks))
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil || !bytes.Equal(data, retrieved) {
+		t.Fatalf("Failed to retrieve intact file: %v", err)
+	}
+
+	// Delete two arbitrary shards from every stripe
+	for stripe := 0; stripe < 3; stripe++ {
+		fileStorage.Delete(context.Background(), fileInfo.Chunks[stripe*6+stripe].ID)
+		fileStorage.Delete(context.Background(), fileInfo.Chunks[stripe*6+4].ID)
+	}
+
+	retrieved, err = cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to reconstruct file: %v", err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Errorf("Reconstructed data does not match original")
+	}
+
+	ranged, err := cm.RetrieveFileRange(context.Background(), fileInfo, 60, 99)
+	if err != nil || !bytes.Equal(data[60:100], ranged) {
+		t.Errorf("Range over reconstructed stripes does not match (%v)", err)
+	}
+
+	// A third missing shard in a stripe is unrecoverable
+	fileStorage.Delete(context.Background(), fileInfo.Chunks[5].ID)
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err == nil {
+		t.Errorf("Expected error with more than M shards missing")
+	}
+
+	// Ranges past the lost stripe are decoded without touching it
+	ranged, err = cm.RetrieveFileRange(context.Background(), fileInfo, 70, int64(len(data))-1)
+	if err != nil || !bytes.Equal(data[70:], ranged) {
+		t.Errorf("Range after the unrecoverable stripe does not match (%v)", err)
+	}
+}
+
+func TestErasureStreamsStripes(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 16)
+	if err := cm.SetErasureCoding(4, 2); err != nil {
+		t.Fatalf("Failed to enable erasure coding: %v", err)
+	}
+
+	data := make([]byte, 3*4*16+5)
+	rand.New(rand.NewSource(2)).Read(data)
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("stream.bin", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	// Every range matches the file, whichever stripes it starts and ends in
+	for _, r := range [][2]int64{{0, 0}, {0, 63}, {63, 64}, {10, 150}, {128, 196}, {196, 196}} {
+		ranged, err := cm.RetrieveFileRange(context.Background(), fileInfo, r[0], r[1])
+		if err != nil || !bytes.Equal(data[r[0]:r[1]+1], ranged) {
+			t.Errorf("Range %d-%d does not match (%v)", r[0], r[1], err)
+		}
+	}
+
+	// A stripe that cannot be decoded stops the stream after the stripes before it
+	for _, i := range []int{12, 13, 14} {
+		fileStorage.Delete(context.Background(), fileInfo.Chunks[i].ID)
+	}
+	var out bytes.Buffer
+	if err := cm.RetrieveFileTo(context.Background(), fileInfo, &out); err == nil {
+		t.Errorf("Expected streaming to fail at the unrecoverable stripe")
+	}
+	if !bytes.Equal(out.Bytes(), data[:128]) {
+		t.Errorf("Expected the first two stripes only, got %d bytes", out.Len())
+	}
+}
+
+func TestErasureShardPlacement(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 8)
+	cm.SetReplication("node-0", 1)
+	if err := cm.SetErasureCoding(2, 1); err != nil {
+		t.Fatalf("Failed to enable erasure coding: %v", err)
+	}
+
+	peers := []*mockPeer{newMockPeer("node-1"), newMockPeer("node-2")}
+	for _, peer := range peers {
+		cm.AddPeer(peer)
+	}
+
+	data := []byte("shards spread across three nodes")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("spread.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	// The shards of each stripe should land on distinct nodes
+	for start := 0; start < len(fileInfo.Chunks); start += 3 {
+		seen := make(map[string]bool)
+		for _, chunkInfo := range fileInfo.Chunks[start : start+3] {
+			seen[chunkInfo.NodeIDs[0]] = true
+		}
+		if len(seen) != 3 {
+			t.Errorf("Stripe %d: expected shards on 3 nodes, got %v", start/3, seen)
+		}
+	}
+
+	// Losing one peer is tolerated
+	peers[0].offline = true
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil || !bytes.Equal(data, retrieved) {
+		t.Errorf("Failed to retrieve with one node offline: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// gcPageSize is how many keys a collection pass reads from storage at a time
+const gcPageSize = 1000
+
+// GCReport summarizes a single garbage collection pass
+type GCReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DryRun     bool      `json:"dry_run"`
+	Scanned    int       `json:"scanned"`
+	Orphaned   int       `json:"orphaned"` // Unreferenced keys, including those still in the grace period
+	Deleted    []string  `json:"deleted"`  // Orphans removed, or that would be removed in a dry run
+	Errors     []string  `json:"errors,omitempty"`
+}
+
+// GCStatus reports whether a collection is running and the result of the last one
+type GCStatus struct {
+	Running bool      `json:"running"`
+	LastRun *GCReport `json:"last_run,omitempty"`
+}
+
+// GarbageCollector removes stored chunks that no file references any more, such as
+// chunks left behind by lost metadata or a crash partway through a delete. A key is
+// only removed once it has been unreferenced for the whole grace period, so chunks
+// written by in-flight uploads before their metadata is saved are never collected.
+type GarbageCollector struct {
+	storage    Storage
+	referenced func() []types.ChunkInfo
+	uploads    *UploadManager
+	grace      time.Duration
+	dryRun     bool
+	logger     *logrus.Logger
+	now        func() time.Time
+
+	orphanSince map[string]time.Time // When each unreferenced key was first seen
+	status      GCStatus
+	mu          sync.Mutex
+}
+
+// NewGarbageCollector creates a collector for the keys in storage that are neither
+// chunks returned by referenced nor staged chunks of an upload in uploads
+func NewGarbageCollector(storage Storage, referenced func() []types.ChunkInfo, uploads *UploadManager, grace time.Duration, logger *logrus.Logger) *GarbageCollector {
+	return &GarbageCollector{
+		storage:     storage,
+		referenced:  referenced,
+		uploads:     uploads,
+		grace:       grace,
+		logger:      logger,
+		now:         time.Now,
+		orphanSince: make(map[string]time.Time),
+	}
+}
+
+// SetDryRun makes collections report the orphans they would delete without deleting them
+func (gc *GarbageCollector) SetDryRun(dryRun bool) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.dryRun = dryRun
+}
+
+// Status returns the current collection status
+func (gc *GarbageCollector) Status() GCStatus {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	status := gc.status
+	if status.LastRun != nil {
+		report := *status.LastRun
+		status.LastRun = &report
+	}
+	return status
+}
+
+// Run collects every interval until stop is closed
+func (gc *GarbageCollector) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			gc.Collect()
+		}
+	}
+}
+
+// Collect runs one garbage collection pass and returns the report, or nil if another
+// collection is already running
+func (gc *GarbageCollector) Collect() *GCReport {
+	gc.mu.Lock()
+	if gc.status.Running {
+		gc.mu.Unlock()
+		return nil
+	}
+	gc.status.Running = true
+	dryRun := gc.dryRun
+	gc.mu.Unlock()
+
+	report := gc.collect(dryRun)
+
+	gc.mu.Lock()
+	gc.status.Running = false
+	gc.status.LastRun = report
+	gc.mu.Unlock()
+
+	gc.logger.WithFields(logrus.Fields{
+		"scanned":  report.Scanned,
+		"orphaned": report.Orphaned,
+		"deleted":  len(report.Deleted),
+		"dry_run":  report.DryRun,
+	}).Info("Garbage collection completed")
+
+	return report
+}
+
+// collect finds unreferenced keys and deletes those past the grace period
+func (gc *GarbageCollector) collect(dryRun bool) *GCReport {
+	report := &GCReport{StartedAt: gc.now(), DryRun: dryRun, Deleted: []string{}}
+	defer func() { report.FinishedAt = gc.now() }()
+
+	referenced := make(map[string]bool)
+	for _, chunkInfo := range gc.referenced() {
+		referenced[chunkInfo.ID] = true
+	}
+	if gc.uploads != nil {
+		staged, err := gc.uploads.StagedKeys()
+		if err != nil {
+			// Without the staged keys live uploads could be collected
+			report.Errors = append(report.Errors, "failed to list staged uploads: "+err.Error())
+			return report
+		}
+		for _, key := range staged {
+			referenced[key] = true
+		}
+	}
+
+	// Chunks of in-flight uploads may be listed before anything references them;
+	// the grace period keeps them from being collected. Keys are read a page at a
+	// time so large stores are never listed whole.
+	now := gc.now()
+	orphanSince := make(map[string]time.Time)
+	after := ""
+	for {
+		keys, err := gc.storage.ListPage(after, gcPageSize)
+		if err != nil {
+			report.Errors = append(report.Errors, "failed to list storage: "+err.Error())
+			return report
+		}
+
+		for _, key := range keys {
+			report.Scanned++
+			if referenced[key] {
+				continue
+			}
+			report.Orphaned++
+
+			since, seen := gc.orphanSince[key]
+			if !seen {
+				since = now
+			}
+			if now.Sub(since) < gc.grace {
+				orphanSince[key] = since
+				continue
+			}
+
+			if dryRun {
+				orphanSince[key] = since
+				report.Deleted = append(report.Deleted, key)
+				continue
+			}
+			if err := gc.storage.Delete(context.Background(), key); err != nil {
+				orphanSince[key] = since
+				report.Errors = append(report.Errors, "failed to delete "+key+": "+err.Error())
+				continue
+			}
+			report.Deleted = append(report.Deleted, key)
+			gc.logger.WithField("chunk_id", key).Info("Deleted orphaned chunk")
+		}
+
+		if len(keys) < gcPageSize {
+			break
+		}
+		after = keys[len(keys)-1]
+	}
+
+	// Forget keys that are gone or referenced again
+	gc.mu.Lock()
+	gc.orphanSince = orphanSince
+	gc.mu.Unlock()
+
+	return report
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// chunksOf returns the chunks of the given files
+func chunksOf(files []*types.FileInfo) []types.ChunkInfo {
+	var chunks []types.ChunkInfo
+	for _, fileInfo := range files {
+		chunks = append(chunks, fileInfo.Chunks...)
+	}
+	return chunks
+}
+
+// newTestGC creates a collector over the chunks of the given files with a controllable clock
+func newTestGC(cm *ChunkManager, uploads *UploadManager, grace time.Duration, files ...*types.FileInfo) (*GarbageCollector, *time.Time) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	gc := NewGarbageCollector(cm.storage, func() []types.ChunkInfo { return chunksOf(files) }, uploads, grace, logger)
+
+	now := time.Unix(1700000000, 0)
+	gc.now = func() time.Time { return now }
+	return gc, &now
+}
+
+func TestGarbageCollectorReclaimsOrphans(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	uploads := NewUploadManager(metadata.NewMemoryStore(), cm)
+
+	data := []byte("referenced chunks")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("kept.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	// A chunk whose file metadata was lost
+	orphan := types.CalculateHash([]byte("orphan"))
+	fileStorage.Store(context.Background(), orphan, []byte("leaked"))
+
+	// A half-finished resumable upload
+	session, err := uploads.CreateSession("partial.txt", "", "", 8, false, 0)
+	if err != nil {
+		t.Fatalf("Failed to create upload: %v", err)
+	}
+	if err := uploads.PutChunk(context.Background(), session.ID, 0, []byte("half")); err != nil {
+		t.Fatalf("Failed to stage chunk: %v", err)
+	}
+
+	gc, now := newTestGC(cm, uploads, time.Hour, fileInfo)
+
+	// Newly seen orphans are kept for the grace period
+	report := gc.Collect()
+	if report.Orphaned != 1 || len(report.Deleted) != 0 {
+		t.Errorf("Expected 1 orphan within the grace period, got %+v", report)
+	}
+	if report.Scanned != len(fileInfo.Chunks)+2 {
+		t.Errorf("Expected %d keys scanned, got %d", len(fileInfo.Chunks)+2, report.Scanned)
+	}
+
+	*now = now.Add(time.Hour)
+	report = gc.Collect()
+	if len(report.Deleted) != 1 || report.Deleted[0] != orphan {
+		t.Fatalf("Expected orphan %s deleted, got %+v", orphan, report)
+	}
+	if fileStorage.Exists(orphan) {
+		t.Errorf("Expected orphan to be removed from storage")
+	}
+
+	// Referenced and staged chunks are untouched
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err != nil {
+		t.Errorf("Expected referenced file to stay intact: %v", err)
+	}
+	if keys, _ := uploads.StagedKeys(); len(keys) != 1 || !fileStorage.Exists(keys[0]) {
+		t.Errorf("Expected staged upload chunk to be kept, got %v", keys)
+	}
+
+	if status := gc.Status(); status.Running || status.LastRun == nil || len(status.LastRun.Deleted) != 1 {
+		t.Errorf("Expected status of the last run, got %+v", status)
+	}
+}
+
+func TestGarbageCollectorDryRun(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+
+	orphan := types.CalculateHash([]byte("orphan"))
+	fileStorage.Store(context.Background(), orphan, []byte("leaked"))
+
+	gc, _ := newTestGC(cm, nil, 0)
+	gc.SetDryRun(true)
+
+	report := gc.Collect()
+	if !report.DryRun || len(report.Deleted) != 1 || report.Deleted[0] != orphan {
+		t.Errorf("Expected dry run to report orphan %s, got %+v", orphan, report)
+	}
+	if !fileStorage.Exists(orphan) {
+		t.Errorf("Expected dry run to keep the orphan")
+	}
+}
+
+func TestGarbageCollectorForgetsReferencedKeys(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 4)
+
+	data := []byte("late metadata")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("late.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	// The metadata is saved only after the first pass saw the chunks unreferenced
+	var files []*types.FileInfo
+	gc, now := newTestGC(cm, nil, time.Hour)
+	gc.referenced = func() []types.ChunkInfo { return chunksOf(files) }
+
+	if report := gc.Collect(); report.Orphaned != len(fileInfo.Chunks) {
+		t.Fatalf("Expected %d orphans, got %+v", len(fileInfo.Chunks), report)
+	}
+
+	files = append(files, fileInfo)
+	*now = now.Add(2 * time.Hour)
+	if report := gc.Collect(); report.Orphaned != 0 || len(report.Deleted) != 0 {
+		t.Errorf("Expected referenced chunks to be kept, got %+v", report)
+	}
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err != nil {
+		t.Errorf("Expected file to stay intact: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// chunkMagic starts every stored chunk
+var chunkMagic = []byte("DCSC")
+
+// chunkHeaderVersion is the version of the chunk header format, the only one read or
+// written. The header names the file, index, hash algorithm and master key version of
+// the chunk and is protected by a CRC. It is also authenticated as additional data of
+// the encryption, so a chunk whose header was altered fails to decrypt. The plaintext
+// length and checksum are kept at the start of the encrypted payload, where they reveal
+// nothing about the plaintext.
+const chunkHeaderVersion = 1
+
+// Chunk header flags
+const (
+	chunkFlagErasure        byte = 1 << 0 // The chunk is an erasure coded shard
+	chunkFlagContentAddress byte = 1 << 1 // FileID is a content ID shared by identical files
+)
+
+var (
+	// ErrInvalidChunkHeader is returned for a chunk whose header is missing, malformed,
+	// corrupted or of an unknown version
+	ErrInvalidChunkHeader = errors.New("invalid chunk header")
+
+	// ErrChunkHeaderMismatch is returned when a decrypted chunk does not match the
+	// length or checksum recorded in its header
+	ErrChunkHeaderMismatch = errors.New("chunk does not match its header")
+)
+
+// chunkHeader is stored unencrypted in front of each chunk so that the chunk describes
+// itself without the metadata store. FileID is the ID the chunk's storage key and
+// encryption key derive from; Length and Checksum describe the plaintext and are only
+// known once the payload is decrypted. KeyVersion is the master key version, with 0
+// read as the first version.
+type chunkHeader struct {
+	Version    byte
+	Flags      byte
+	Algorithm  types.HashAlgorithm
+	FileID     string
+	Index      int
+	Length     int64
+	Checksum   []byte
+	KeyVersion uint32
+}
+
+// encodeChunkHeader returns the stored form of header. The encrypted payload follows
+// it, sealed with the encoded header as additional data.
+func encodeChunkHeader(header chunkHeader) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(chunkMagic) + 19 + len(header.Algorithm) + len(header.FileID))
+
+	buf.Write(chunkMagic)
+	buf.WriteByte(chunkHeaderVersion)
+	buf.WriteByte(header.Flags)
+	binary.Write(&buf, binary.BigEndian, uint32(header.Index))
+	buf.WriteByte(byte(len(header.Algorithm)))
+	buf.WriteString(string(header.Algorithm))
+	binary.Write(&buf, binary.BigEndian, uint16(len(header.FileID)))
+	buf.WriteString(header.FileID)
+	binary.Write(&buf, binary.BigEndian, header.KeyVersion)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes()
+}
+
+// encodeChunkDigest returns the plaintext length and checksum of header in the form a
+// chunk keeps them at the start of its encrypted payload
+func encodeChunkDigest(header chunkHeader) []byte {
+	digest := binary.BigEndian.AppendUint64(nil, uint64(header.Length))
+	digest = append(digest, byte(len(header.Checksum)))
+	return append(digest, header.Checksum...)
+}
+
+// decodeDigest reads the plaintext length and checksum from the start of a decrypted
+// payload into the header, returning the rest of the payload
+func (h *chunkHeader) decodeDigest(encoded []byte) ([]byte, error) {
+	r := &headerReader{data: encoded}
+	h.Length = int64(r.uint64())
+	h.Checksum = r.bytes(int(r.byte()))
+	if r.short {
+		return nil, fmt.Errorf("%w: truncated", ErrChunkHeaderMismatch)
+	}
+	return encoded[r.pos:], nil
+}
+
+// decodeChunkHeader splits a stored chunk into its header and payload
+func decodeChunkHeader(stored []byte) (header chunkHeader, payload []byte, err error) {
+	if !bytes.HasPrefix(stored, chunkMagic) {
+		return chunkHeader{}, nil, fmt.Errorf("%w: bad magic", ErrInvalidChunkHeader)
+	}
+
+	r := &headerReader{data: stored, pos: len(chunkMagic)}
+	header.Version = r.byte()
+	if !r.short && header.Version != chunkHeaderVersion {
+		return chunkHeader{}, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidChunkHeader, header.Version)
+	}
+	header.Flags = r.byte()
+	header.Index = int(r.uint32())
+	header.Algorithm = types.HashAlgorithm(r.bytes(int(r.byte())))
+	header.FileID = string(r.bytes(int(r.uint16())))
+	header.KeyVersion = r.uint32()
+
+	end := r.pos
+	crc := r.uint32()
+	if r.short {
+		return chunkHeader{}, nil, fmt.Errorf("%w: truncated", ErrInvalidChunkHeader)
+	}
+	if crc != crc32.ChecksumIEEE(stored[:end]) {
+		return chunkHeader{}, nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidChunkHeader)
+	}
+	return header, stored[r.pos:], nil
+}
+
+// keyVersion returns the master key version the chunk is encrypted under
+func (h chunkHeader) keyVersion() uint32 {
+	if h.KeyVersion == 0 {
+		return 1
+	}
+	return h.KeyVersion
+}
+
+// verify checks a decrypted chunk against the length and checksum recorded for it
+func (h chunkHeader) verify(chunk []byte) error {
+	if int64(len(chunk)) != h.Length {
+		return fmt.Errorf("%w: %d bytes, header says %d", ErrChunkHeaderMismatch, len(chunk), h.Length)
+	}
+
+	hasher := h.Algorithm.New()
+	hasher.Write(chunk)
+	if !bytes.Equal(hasher.Sum(nil), h.Checksum) {
+		return fmt.Errorf("%w: checksum", ErrChunkHeaderMismatch)
+	}
+	return nil
+}
+
+// headerReader reads big-endian header fields, recording rather than failing on
+// truncation so that decoding can check once at the end
+type headerReader struct {
+	data  []byte
+	pos   int
+	short bool
+}
+
+func (r *headerReader) bytes(n int) []byte {
+	if r.short || len(r.data)-r.pos < n {
+		r.short = true
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *headerReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *headerReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *headerReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *headerReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
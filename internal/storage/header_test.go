@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestChunkHeaderRoundTrip(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 1024)
+	chunk := []byte("plaintext chunk")
+
+	header := chunkHeader{Flags: chunkFlagContentAddress, Algorithm: types.HashSHA512, FileID: "file-id", Index: 300, KeyVersion: 7}
+	stored, err := cm.sealChunk(cm.chunkKey(""), header, chunk)
+	if err != nil {
+		t.Fatalf("Failed to seal chunk: %v", err)
+	}
+
+	decoded, _, err := decodeChunkHeader(stored)
+	if err != nil {
+		t.Fatalf("Expected header to decode, got %v", err)
+	}
+	if decoded.Version != chunkHeaderVersion || decoded.Flags != header.Flags || decoded.Algorithm != header.Algorithm ||
+		decoded.FileID != header.FileID || decoded.Index != header.Index || decoded.KeyVersion != header.KeyVersion {
+		t.Errorf("Expected header %+v, got %+v", header, decoded)
+	}
+
+	// The plaintext length and checksum are only inside the encrypted payload
+	checksum := mustDecodeHex(t, types.CalculateHashWith(types.HashSHA512, chunk))
+	if decoded.Length != 0 || decoded.Checksum != nil || bytes.Contains(stored, checksum) {
+		t.Errorf("Expected no plaintext length or checksum in the clear, got %+v", decoded)
+	}
+
+	opened, err := cm.openChunk(cm.chunkKey(""), stored, int64(len(chunk)))
+	if err != nil || !bytes.Equal(opened, chunk) {
+		t.Errorf("Expected %q, got %q (%v)", chunk, opened, err)
+	}
+}
+
+func TestChunkHeaderRejectsCorruption(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 1024)
+	chunk := []byte("plaintext chunk")
+	stored, err := cm.sealChunk(cm.chunkKey(""), chunkHeader{FileID: "file-id", Index: 1}, chunk)
+	if err != nil {
+		t.Fatalf("Failed to seal chunk: %v", err)
+	}
+	headerLen := len(stored) - len(mustPayload(t, stored))
+
+	corrupt := func(mutate func(b []byte) []byte) []byte {
+		return mutate(append([]byte(nil), stored...))
+	}
+
+	tests := []struct {
+		name   string
+		stored []byte
+	}{
+		{"flipped index", corrupt(func(b []byte) []byte { b[7] ^= 0xff; return b })},
+		{"flipped file ID", corrupt(func(b []byte) []byte { b[headerLen-9] ^= 0x01; return b })},
+		{"bad magic", corrupt(func(b []byte) []byte { b[0] = 'X'; return b })},
+		{"unknown version", corrupt(func(b []byte) []byte { b[4] = 9; return b })},
+		{"truncated", stored[:headerLen-2]},
+		{"magic only", []byte("DCSC")},
+	}
+
+	for _, tt := range tests {
+		if _, err := cm.openChunk(cm.chunkKey(""), tt.stored, int64(len(chunk))); !errors.Is(err, ErrInvalidChunkHeader) {
+			t.Errorf("%s: expected ErrInvalidChunkHeader, got %v", tt.name, err)
+		}
+	}
+
+	// A header altered along with its CRC no longer matches the encryption
+	retagged := corrupt(func(b []byte) []byte {
+		b[7] ^= 0x01
+		binary.BigEndian.PutUint32(b[headerLen-4:], crc32.ChecksumIEEE(b[:headerLen-4]))
+		return b
+	})
+	if header, _, err := decodeChunkHeader(retagged); err != nil || header.Index == 1 {
+		t.Fatalf("Expected altered header to decode, got %+v (%v)", header, err)
+	}
+	if _, err := cm.openChunk(cm.chunkKey(""), retagged, int64(len(chunk))); err == nil {
+		t.Errorf("Expected altered header to fail decryption")
+	}
+
+	// The header vouches for the plaintext it was written with
+	header, _, _ := decodeChunkHeader(stored)
+	header.Length, header.Checksum = int64(len(chunk)), mustDecodeHex(t, types.CalculateHash(chunk))
+	if err := header.verify([]byte("plaintext chunK")); !errors.Is(err, ErrChunkHeaderMismatch) {
+		t.Errorf("Expected ErrChunkHeaderMismatch for altered plaintext, got %v", err)
+	}
+	if err := header.verify(chunk[:4]); !errors.Is(err, ErrChunkHeaderMismatch) {
+		t.Errorf("Expected ErrChunkHeaderMismatch for wrong length, got %v", err)
+	}
+}
+
+// mustPayload returns the encrypted payload following a stored chunk's header
+func mustPayload(t *testing.T, stored []byte) []byte {
+	t.Helper()
+	_, payload, err := decodeChunkHeader(stored)
+	if err != nil {
+		t.Fatalf("Failed to decode header: %v", err)
+	}
+	return payload
+}
+
+// mustDecodeHex decodes a hex string
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Failed to decode hex: %v", err)
+	}
+	return b
+}
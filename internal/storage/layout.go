@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// layoutFile records the directory layout of a filesystem store in its base directory
+const layoutFile = "layout.json"
+
+// ErrLayoutMismatch is returned when a store was written with a different layout than configured
+var ErrLayoutMismatch = errors.New("storage layout mismatch")
+
+// Layout describes how FileStorage fans keys out into subdirectories
+type Layout struct {
+	Depth int `json:"depth"` // Levels of subdirectories, 0 for a flat store
+	Width int `json:"width"` // Key characters used for each subdirectory name
+}
+
+// DefaultLayout is one level of two-character subdirectories
+var DefaultLayout = Layout{Depth: 1, Width: 2}
+
+// Validate checks that the layout can be applied to 64-character keys
+func (l Layout) Validate() error {
+	if l.Depth < 0 || l.Depth > 4 {
+		return fmt.Errorf("invalid layout depth: %d", l.Depth)
+	}
+	if l.Depth > 0 && (l.Width < 1 || l.Width > 8) {
+		return fmt.Errorf("invalid layout width: %d", l.Width)
+	}
+	return nil
+}
+
+func (l Layout) String() string {
+	return fmt.Sprintf("depth %d width %d", l.Depth, l.Width)
+}
+
+// path returns where the layout stores key under basePath, refusing keys that would
+// resolve outside it
+func (l Layout) path(basePath, key string) (string, error) {
+	return utils.SafeJoin(basePath, utils.GetStoragePathLayout("", key, l.Depth, l.Width))
+}
+
+// equal reports whether two layouts place keys identically
+func (l Layout) equal(other Layout) bool {
+	if l.Depth == 0 || other.Depth == 0 {
+		return l.Depth == other.Depth
+	}
+	return l == other
+}
+
+// readLayout returns the layout recorded for the store at basePath. Stores holding data
+// without a record predate configurable layouts and use DefaultLayout; ok is false for
+// empty stores without a record.
+func readLayout(basePath string) (layout Layout, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(basePath, layoutFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &layout); err != nil {
+			return Layout{}, false, fmt.Errorf("failed to parse %s: %w", layoutFile, err)
+		}
+		return layout, true, nil
+	}
+	if !os.IsNotExist(err) {
+		return Layout{}, false, fmt.Errorf("failed to read %s: %w", layoutFile, err)
+	}
+
+	keys, err := findKeys(basePath)
+	if err != nil {
+		return Layout{}, false, err
+	}
+	if len(keys) > 0 {
+		return DefaultLayout, true, nil
+	}
+	return Layout{}, false, nil
+}
+
+// writeLayout records the layout of the store at basePath
+func writeLayout(basePath string, layout Layout) error {
+	data, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(basePath, layoutFile), bytes.NewReader(data), false); err != nil {
+		return fmt.Errorf("failed to write %s: %w", layoutFile, err)
+	}
+	return nil
+}
+
+// findKeys returns the path of every stored key below basePath, keyed by the key
+func findKeys(basePath string) (map[string]string, error) {
+	keys := make(map[string]string)
+	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && utils.ValidateFileID(info.Name()) {
+			keys[info.Name()] = path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan storage: %w", err)
+	}
+	return keys, nil
+}
+
+// MigrateLayout moves every key stored under basePath to the given layout, removes the
+// emptied subdirectories and records the new layout. It returns the number of keys moved.
+// The store must not be in use while it is migrated.
+func MigrateLayout(basePath string, layout Layout, logger *logrus.Logger) (int, error) {
+	if err := layout.Validate(); err != nil {
+		return 0, err
+	}
+
+	keys, err := findKeys(basePath)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for key, oldPath := range keys {
+		newPath, err := layout.path(basePath, key)
+		if err != nil {
+			return moved, err
+		}
+		if newPath == oldPath {
+			continue
+		}
+
+		if err := utils.EnsureDir(filepath.Dir(newPath)); err != nil {
+			return moved, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return moved, fmt.Errorf("failed to move %s: %w", key, err)
+		}
+		moved++
+	}
+
+	if err := removeEmptyDirs(basePath); err != nil {
+		return moved, err
+	}
+	if err := writeLayout(basePath, layout); err != nil {
+		return moved, err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"path":  basePath,
+		"depth": layout.Depth,
+		"width": layout.Width,
+		"moved": moved,
+	}).Info("Migrated storage layout")
+
+	return moved, nil
+}
+
+// removeEmptyDirs removes empty subdirectories below basePath, deepest first
+func removeEmptyDirs(basePath string) error {
+	var dirs []string
+	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != basePath {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan storage: %w", err)
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err == nil && len(entries) == 0 {
+			os.Remove(dir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func newLayoutTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestFileStorageLayout(t *testing.T) {
+	key := types.CalculateHash([]byte("layout"))
+
+	tests := []struct {
+		layout   Layout
+		expected string
+	}{
+		{Layout{Depth: 0}, key},
+		{Layout{Depth: 1, Width: 2}, filepath.Join(key[:2], key)},
+		{Layout{Depth: 2, Width: 2}, filepath.Join(key[:2], key[2:4], key)},
+	}
+
+	for _, tt := range tests {
+		basePath := t.TempDir()
+		fs, err := NewFileStorageWithLayout(basePath, tt.layout, newLayoutTestLogger())
+		if err != nil {
+			t.Fatalf("%s: failed to create storage: %v", tt.layout, err)
+		}
+
+		if err := fs.Store(context.Background(), key, []byte("data")); err != nil {
+			t.Fatalf("%s: failed to store: %v", tt.layout, err)
+		}
+		if _, err := os.Stat(filepath.Join(basePath, tt.expected)); err != nil {
+			t.Errorf("%s: expected key at %s: %v", tt.layout, tt.expected, err)
+		}
+
+		keys, err := fs.List()
+		if err != nil || len(keys) != 1 || keys[0] != key {
+			t.Errorf("%s: expected list to return the key, got %v (%v)", tt.layout, keys, err)
+		}
+		if usage, _ := fs.GetUsage(); usage != 4 {
+			t.Errorf("%s: expected usage 4, got %d", tt.layout, usage)
+		}
+	}
+}
+
+func TestLayoutPathStaysInBase(t *testing.T) {
+	base := t.TempDir()
+
+	for _, key := range []string{"../../etc/passwd", "/etc/passwd", "ab\x00cd"} {
+		for _, layout := range []Layout{{Depth: 0}, DefaultLayout} {
+			if path, err := layout.path(base, key); !errors.Is(err, utils.ErrUnsafePath) {
+				t.Errorf("%s: expected ErrUnsafePath for %q, got %q, %v", layout, key, path, err)
+			}
+		}
+	}
+}
+
+func TestFileStorageLayoutMismatch(t *testing.T) {
+	basePath := t.TempDir()
+	key := types.CalculateHash([]byte("mismatch"))
+
+	fs, err := NewFileStorage(basePath, newLayoutTestLogger())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := fs.Store(context.Background(), key, []byte("data")); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+
+	deeper := Layout{Depth: 2, Width: 2}
+	if _, err := NewFileStorageWithLayout(basePath, deeper, newLayoutTestLogger()); !errors.Is(err, ErrLayoutMismatch) {
+		t.Fatalf("Expected ErrLayoutMismatch, got %v", err)
+	}
+
+	// Stores written before layouts were recorded are treated as the default layout
+	if err := os.Remove(filepath.Join(basePath, layoutFile)); err != nil {
+		t.Fatalf("Failed to remove layout record: %v", err)
+	}
+	if _, err := NewFileStorageWithLayout(basePath, deeper, newLayoutTestLogger()); !errors.Is(err, ErrLayoutMismatch) {
+		t.Errorf("Expected ErrLayoutMismatch for unrecorded store, got %v", err)
+	}
+
+	moved, err := MigrateLayout(basePath, deeper, newLayoutTestLogger())
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("Expected 1 key moved, got %d", moved)
+	}
+	if _, err := os.Stat(filepath.Join(basePath, key[:2], key[2:4])); err != nil {
+		t.Errorf("Expected key in new layout: %v", err)
+	}
+
+	fs, err = NewFileStorageWithLayout(basePath, deeper, newLayoutTestLogger())
+	if err != nil {
+		t.Fatalf("Expected migrated store to open, got %v", err)
+	}
+	data, err := fs.Retrieve(context.Background(), key)
+	if err != nil || string(data) != "data" {
+		t.Errorf("Expected migrated data, got %q (%v)", data, err)
+	}
+
+	// Empty stores can adopt any layout
+	if _, err := NewFileStorageWithLayout(t.TempDir(), deeper, newLayoutTestLogger()); err != nil {
+		t.Errorf("Expected empty store to accept layout, got %v", err)
+	}
+	if _, err := NewFileStorageWithLayout(t.TempDir(), Layout{Depth: 1, Width: 0}, newLayoutTestLogger()); err == nil {
+		t.Error("Expected error for invalid layout")
+	}
+}
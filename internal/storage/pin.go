@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// chunkPins tracks the chunks being read by streams. A chunk released while pinned is
+// only removed once its last reader is done, so a long download never needs to hold
+// off appends and deletes of its file to keep its chunks in place.
+type chunkPins struct {
+	readers  map[string]int             // Readers of each pinned chunk
+	pending  map[string]types.ChunkInfo // Pinned chunks released meanwhile, removed on unpin
+	removing map[string]chan struct{}   // Pending chunks being removed, closed when done
+	mu       sync.Mutex
+}
+
+// PinChunks keeps chunks in place until the returned function is called, for reading
+// a file without holding off changes to it. Releasing a pinned chunk defers its removal
+// to the unpin.
+func (cm *ChunkManager) PinChunks(chunks []types.ChunkInfo) func() {
+	pins := &cm.pins
+	pins.mu.Lock()
+	if pins.readers == nil {
+		pins.readers = make(map[string]int)
+	}
+	for _, chunkInfo := range chunks {
+		pins.readers[chunkInfo.ID]++
+	}
+	pins.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { cm.unpinChunks(chunks) })
+	}
+}
+
+// PinnedChunks returns the chunks waiting for their readers before being removed, which
+// no file references any more but must not be collected as garbage yet
+func (cm *ChunkManager) PinnedChunks() []types.ChunkInfo {
+	pins := &cm.pins
+	pins.mu.Lock()
+	defer pins.mu.Unlock()
+
+	chunks := make([]types.ChunkInfo, 0, len(pins.pending))
+	for _, chunkInfo := range pins.pending {
+		chunks = append(chunks, chunkInfo)
+	}
+	return chunks
+}
+
+// unpinChunks drops a reader of each chunk, removing those released while pinned once
+// their last reader is gone
+func (cm *ChunkManager) unpinChunks(chunks []types.ChunkInfo) {
+	pins := &cm.pins
+	pins.mu.Lock()
+	var released []types.ChunkInfo
+	for _, chunkInfo := range chunks {
+		if pins.readers[chunkInfo.ID]--; pins.readers[chunkInfo.ID] > 0 {
+			continue
+		}
+		delete(pins.readers, chunkInfo.ID)
+		if pending, exists := pins.pending[chunkInfo.ID]; exists {
+			delete(pins.pending, chunkInfo.ID)
+			if pins.removing == nil {
+				pins.removing = make(map[string]chan struct{})
+			}
+			pins.removing[chunkInfo.ID] = make(chan struct{})
+			released = append(released, pending)
+		}
+	}
+	pins.mu.Unlock()
+
+	for _, chunkInfo := range released {
+		cm.removeChunk(chunkInfo)
+
+		pins.mu.Lock()
+		close(pins.removing[chunkInfo.ID])
+		delete(pins.removing, chunkInfo.ID)
+		pins.mu.Unlock()
+	}
+}
+
+// deferRemoval holds back the removal of a released chunk while it is pinned, reporting
+// whether it did
+func (cm *ChunkManager) deferRemoval(chunkInfo types.ChunkInfo) bool {
+	pins := &cm.pins
+	pins.mu.Lock()
+	defer pins.mu.Unlock()
+
+	if pins.readers[chunkInfo.ID] == 0 {
+		return false
+	}
+	if pins.pending == nil {
+		pins.pending = make(map[string]types.ChunkInfo)
+	}
+	pins.pending[chunkInfo.ID] = chunkInfo
+	return true
+}
+
+// keepChunk cancels a deferred removal of the chunk with the given ID before it is
+// stored again, waiting for one already under way to finish so it cannot remove the
+// new copy
+func (cm *ChunkManager) keepChunk(chunkID string) {
+	pins := &cm.pins
+	pins.mu.Lock()
+	defer pins.mu.Unlock()
+
+	for {
+		done, removing := pins.removing[chunkID]
+		if !removing {
+			break
+		}
+		pins.mu.Unlock()
+		<-done
+		pins.mu.Lock()
+	}
+	delete(pins.pending, chunkID)
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestPinnedChunksOutliveDelete(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	content := []byte("pinned chunk data")
+	fileInfo := &types.FileInfo{ID: "pinned-file"}
+	if err := cm.StoreFile(context.Background(), fileInfo, content); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	unpin := cm.PinChunks(fileInfo.Chunks)
+	if err := cm.DeleteFile(context.Background(), fileInfo); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	// The chunks stay readable until the reader lets go, and are reported as pinned
+	data, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil || string(data) != string(content) {
+		t.Fatalf("Expected pinned chunks to stay readable, got %q (%v)", data, err)
+	}
+	if pinned := cm.PinnedChunks(); len(pinned) != len(fileInfo.Chunks) {
+		t.Errorf("Expected %d pinned chunks, got %d", len(fileInfo.Chunks), len(pinned))
+	}
+
+	unpin()
+	unpin() // Unpinning twice releases only once
+	for _, chunkInfo := range fileInfo.Chunks {
+		if fileStorage.Exists(chunkInfo.ID) {
+			t.Errorf("Expected chunk %d to be removed after unpinning", chunkInfo.Index)
+		}
+	}
+	if pinned := cm.PinnedChunks(); len(pinned) != 0 {
+		t.Errorf("Expected no pinned chunks, got %d", len(pinned))
+	}
+}
+
+func TestPinnedChunkStoredAgain(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	content := []byte("stored twice")
+	first := &types.FileInfo{ID: "same-file"}
+	if err := cm.StoreFile(context.Background(), first, content); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	unpin := cm.PinChunks(first.Chunks)
+	if err := cm.DeleteFile(context.Background(), first); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	// Storing the same chunks again cancels their pending removal
+	second := &types.FileInfo{ID: "same-file"}
+	if err := cm.StoreFile(context.Background(), second, content); err != nil {
+		t.Fatalf("Failed to store file again: %v", err)
+	}
+	unpin()
+
+	for _, chunkInfo := range second.Chunks {
+		if !fileStorage.Exists(chunkInfo.ID) {
+			t.Errorf("Expected re-stored chunk %d to survive the unpin", chunkInfo.Index)
+		}
+	}
+	if data, err := cm.RetrieveFile(context.Background(), second); err != nil || string(data) != string(content) {
+		t.Errorf("Expected %q, got %q (%v)", content, data, err)
+	}
+}
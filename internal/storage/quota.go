@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+)
+
+// Metadata buckets used by the quota manager
+const (
+	quotaBucket = "quotas"
+	usageBucket = "usage"
+)
+
+// ErrQuotaExceeded is returned when an owner would exceed their storage quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// QuotaStatus is an owner's quota and the bytes counted against it
+type QuotaStatus struct {
+	Owner   string `json:"owner"`
+	Quota   int64  `json:"quota"`   // Bytes the owner may store, 0 for unlimited
+	Used    int64  `json:"used"`    // Bytes currently stored by the owner
+	Default bool   `json:"default"` // Whether the quota is the default rather than set for the owner
+}
+
+// QuotaManager tracks bytes used per owner and enforces per-owner quotas.
+// A quota of zero means unlimited.
+type QuotaManager struct {
+	store        metadata.Store
+	defaultQuota int64
+	mu           sync.Mutex
+}
+
+// NewQuotaManager creates a quota manager persisting its state in the metadata store
+func NewQuotaManager(store metadata.Store, defaultQuota int64) *QuotaManager {
+	return &QuotaManager{
+		store:        store,
+		defaultQuota: defaultQuota,
+	}
+}
+
+// SetDefaultQuota changes the quota applied to owners without their own quota
+func (qm *QuotaManager) SetDefaultQuota(quota int64) {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	qm.defaultQuota = quota
+}
+
+// SetQuota sets the quota in bytes for an owner
+func (qm *QuotaManager) SetQuota(owner string, quota int64) error {
+	if quota < 0 {
+		return fmt.Errorf("invalid quota: %d", quota)
+	}
+
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	return qm.store.Put(quotaBucket, owner, quota)
+}
+
+// GetQuota returns the quota in bytes for an owner, falling back to the default
+func (qm *QuotaManager) GetQuota(owner string) (int64, error) {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	return qm.getQuota(owner)
+}
+
+// Status returns an owner's quota and usage, read together
+func (qm *QuotaManager) Status(owner string) (*QuotaStatus, error) {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	status := &QuotaStatus{Owner: owner}
+	if err := qm.store.Get(quotaBucket, owner, &status.Quota); err != nil {
+		if !errors.Is(err, metadata.ErrNotFound) {
+			return nil, err
+		}
+		status.Quota = qm.defaultQuota
+		status.Default = true
+	}
+
+	used, err := qm.getUsage(owner)
+	if err != nil {
+		return nil, err
+	}
+	status.Used = used
+	return status, nil
+}
+
+// Usage returns the bytes currently used by an owner
+func (qm *QuotaManager) Usage(owner string) (int64, error) {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	return qm.getUsage(owner)
+}
+
+// Reserve records size bytes against the owner's usage, failing with ErrQuotaExceeded
+// if the owner's quota would be exceeded
+func (qm *QuotaManager) Reserve(owner string, size int64) error {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	quota, err := qm.getQuota(owner)
+	if err != nil {
+		return err
+	}
+
+	usage, err := qm.getUsage(owner)
+	if err != nil {
+		return err
+	}
+
+	if quota > 0 && usage+size > quota {
+		return fmt.Errorf("%w: %d of %d bytes used", ErrQuotaExceeded, usage, quota)
+	}
+
+	return qm.store.Put(usageBucket, owner, usage+size)
+}
+
+// Release subtracts size bytes from the owner's usage
+func (qm *QuotaManager) Release(owner string, size int64) error {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	usage, err := qm.getUsage(owner)
+	if err != nil {
+		return err
+	}
+
+	usage -= size
+	if usage < 0 {
+		usage = 0
+	}
+
+	return qm.store.Put(usageBucket, owner, usage)
+}
+
+// getQuota reads an owner's quota. Callers must hold the lock.
+func (qm *QuotaManager) getQuota(owner string) (int64, error) {
+	var quota int64
+	if err := qm.store.Get(quotaBucket, owner, &quota); err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return qm.defaultQuota, nil
+		}
+		return 0, err
+	}
+	return quota, nil
+}
+
+// getUsage reads an owner's usage. Callers must hold the lock.
+func (qm *QuotaManager) getUsage(owner string) (int64, error) {
+	var usage int64
+	if err := qm.store.Get(usageBucket, owner, &usage); err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return usage, nil
+}
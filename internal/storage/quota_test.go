@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+)
+
+func TestQuotaManager(t *testing.T) {
+	qm := NewQuotaManager(metadata.NewMemoryStore(), 100)
+
+	// Default quota applies to unknown owners
+	quota, err := qm.GetQuota("alice")
+	if err != nil || quota != 100 {
+		t.Errorf("Expected default quota 100, got %d (%v)", quota, err)
+	}
+
+	// Reserve up to the quota
+	if err := qm.Reserve("alice", 60); err != nil {
+		t.Fatalf("Failed to reserve: %v", err)
+	}
+	if err := qm.Reserve("alice", 40); err != nil {
+		t.Fatalf("Failed to reserve up to quota: %v", err)
+	}
+
+	// Reject past the quota
+	if err := qm.Reserve("alice", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// Releasing recovers capacity
+	if err := qm.Release("alice", 60); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	if err := qm.Reserve("alice", 50); err != nil {
+		t.Errorf("Expected reserve to succeed after release: %v", err)
+	}
+
+	usage, _ := qm.Usage("alice")
+	if usage != 90 {
+		t.Errorf("Expected usage 90, got %d", usage)
+	}
+
+	// Per-owner quota overrides the default, zero is unlimited
+	if err := qm.SetQuota("bob", 0); err != nil {
+		t.Fatalf("Failed to set quota: %v", err)
+	}
+	if err := qm.Reserve("bob", 1000); err != nil {
+		t.Errorf("Expected unlimited quota for bob: %v", err)
+	}
+
+	if err := qm.SetQuota("carol", -1); err == nil {
+		t.Errorf("Expected error for negative quota")
+	}
+}
+
+func TestQuotaStatus(t *testing.T) {
+	qm := NewQuotaManager(metadata.NewMemoryStore(), 100)
+	qm.Reserve("alice", 30)
+
+	status, err := qm.Status("alice")
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+	if *status != (QuotaStatus{Owner: "alice", Quota: 100, Used: 30, Default: true}) {
+		t.Errorf("Expected default quota with 30 bytes used, got %+v", status)
+	}
+
+	// An owner's own quota is reported even when it equals the default
+	qm.SetQuota("alice", 100)
+	if status, _ := qm.Status("alice"); status.Quota != 100 || status.Default {
+		t.Errorf("Expected alice's own quota, got %+v", status)
+	}
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ReindexResult describes the files rebuilt from stored chunks
+type ReindexResult struct {
+	Files      []*types.FileInfo // Files rebuilt from complete runs of chunks, ordered by ID
+	Unassigned []string          // Chunks that could not be attributed to a rebuilt file
+}
+
+// reindexGroup collects the stored chunks naming the same file in their headers
+type reindexGroup struct {
+	header chunkHeader
+	keys   map[int]string // Storage key of each chunk index
+}
+
+// Reindex rebuilds file metadata from the headers of stored chunks, leaving out chunks
+// for which skip returns true, such as those of files that are still known. A file is
+// rebuilt when its chunks form a run from index 0 that decrypts. Names, owners and
+// content types are not stored with chunks, so rebuilt files carry none; neither can a
+// truncated run be told from a complete file. Erasure coded shards and chunks without
+// a valid header are reported as unassigned.
+func (cm *ChunkManager) Reindex(skip func(chunkID string) bool) (*ReindexResult, error) {
+	keys, err := cm.storage.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+
+	result := &ReindexResult{}
+	groups := make(map[string]*reindexGroup)
+	for _, key := range keys {
+		if skip != nil && skip(key) {
+			continue
+		}
+
+		stored, err := cm.storage.Retrieve(context.Background(), key)
+		if err != nil {
+			result.Unassigned = append(result.Unassigned, key)
+			continue
+		}
+		header, _, err := decodeChunkHeader(stored)
+		if err != nil || header.Flags&chunkFlagErasure != 0 {
+			result.Unassigned = append(result.Unassigned, key)
+			continue
+		}
+
+		group, exists := groups[header.FileID]
+		if !exists {
+			group = &reindexGroup{header: header, keys: make(map[int]string)}
+			groups[header.FileID] = group
+		}
+		if _, duplicate := group.keys[header.Index]; duplicate {
+			result.Unassigned = append(result.Unassigned, key)
+			continue
+		}
+		group.keys[header.Index] = key
+	}
+
+	fileIDs := make([]string, 0, len(groups))
+	for fileID := range groups {
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+
+	for _, fileID := range fileIDs {
+		group := groups[fileID]
+		fileInfo, err := cm.rebuildFile(group)
+		if err != nil {
+			cm.logger.WithError(err).WithField("file_id", fileID).Warn("Could not rebuild file from chunks")
+			for _, key := range group.keys {
+				result.Unassigned = append(result.Unassigned, key)
+			}
+			continue
+		}
+		result.Files = append(result.Files, fileInfo)
+	}
+
+	sort.Strings(result.Unassigned)
+	cm.logger.WithFields(logrus.Fields{
+		"recovered":  len(result.Files),
+		"unassigned": len(result.Unassigned),
+	}).Info("Reindexed stored chunks")
+
+	return result, nil
+}
+
+// rebuildFile reads back a group's chunks in order and builds the file they form
+func (cm *ChunkManager) rebuildFile(group *reindexGroup) (*types.FileInfo, error) {
+	if !utils.ValidateFileID(group.header.FileID) {
+		return nil, fmt.Errorf("invalid file ID %q", group.header.FileID)
+	}
+	for index := 0; index < len(group.keys); index++ {
+		if _, exists := group.keys[index]; !exists {
+			return nil, fmt.Errorf("chunk %d is missing", index)
+		}
+	}
+
+	cm.mu.RLock()
+	algorithm := cm.hashAlgorithm
+	nodeID := cm.nodeID
+	maxChunkSize := cm.maxChunkSize
+	cm.mu.RUnlock()
+
+	// Hash with the algorithm the file was stored with
+	if group.header.Algorithm != "" {
+		algorithm = group.header.Algorithm
+	}
+
+	// Chunks are never larger than the chunk size they were split with, which is the
+	// manager's unless the file asked for its own
+	limit := int64(cm.chunkSize)
+	if limit <= 0 {
+		limit = defaultChunkSize
+	}
+	if int64(maxChunkSize) > limit {
+		limit = int64(maxChunkSize)
+	}
+
+	fileID := group.header.FileID
+	fileInfo := &types.FileInfo{ID: fileID, HashAlgorithm: algorithm, IsEncrypted: true}
+	if group.header.Flags&chunkFlagContentAddress != 0 {
+		fileInfo.IDMode = types.FileIDModeDedup
+		fileInfo.ContentID = fileID
+	}
+
+	hasher := newFileHasher(algorithm)
+	for index := 0; index < len(group.keys); index++ {
+		key := group.keys[index]
+		stored, err := cm.storage.Retrieve(context.Background(), key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %d: %w", index, err)
+		}
+		header, _, err := decodeChunkHeader(stored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt chunk %d: %w", index, err)
+		}
+		chunkKey, err := cm.versionKey(header.keyVersion(), fileID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt chunk %d: %w", index, err)
+		}
+		chunk, err := cm.openChunk(chunkKey, stored, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt chunk %d: %w", index, err)
+		}
+
+		hasher.Write(chunk)
+		fileInfo.Size += int64(len(chunk))
+		fileInfo.Chunks = append(fileInfo.Chunks, types.ChunkInfo{
+			ID:       key,
+			Index:    index,
+			Size:     int64(len(chunk)),
+			Hash:     types.CalculateHashWith(algorithm, chunk),
+			NodeIDs:  []string{nodeID},
+			Checksum: types.CalculateHash(stored),
+			KeyID:    fileID,
+
+			HashAlgorithm: algorithm,
+			KeyVersion:    int(header.KeyVersion),
+		})
+	}
+
+	// Every chunk but the last is full, so the first shows the size the file was split
+	// with; a single chunk could have come from any size, so the manager's is assumed
+	chunkSize, err := cm.fileChunkSize(&types.FileInfo{})
+	if err != nil {
+		return nil, err
+	}
+	if len(fileInfo.Chunks) > 1 {
+		chunkSize = int(fileInfo.Chunks[0].Size)
+	}
+	fileInfo.ChunkSize = chunkSize
+	fileInfo.Hash = hasher.sum()
+	fileInfo.Replicas = minReplicas(fileInfo.Chunks)
+	if err := setMerkleRoot(fileInfo); err != nil {
+		return nil, err
+	}
+	return fileInfo, nil
+}
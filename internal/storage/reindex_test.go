@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+func TestReindex(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+
+	contents := map[string][]byte{
+		types.GenerateFileID("one.txt", []byte("first file data")):  []byte("first file data"),
+		types.GenerateFileID("two.txt", []byte("second, longer")):   []byte("second, longer"),
+		types.GenerateFileID("kept.txt", []byte("still indexed!")):  []byte("still indexed!"),
+		types.GenerateFileID("broken.txt", []byte("loses a chunk")): []byte("loses a chunk"),
+	}
+	files := make(map[string]*types.FileInfo)
+	for id, data := range contents {
+		fileInfo := &types.FileInfo{ID: id}
+		if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+			t.Fatalf("Failed to store file: %v", err)
+		}
+		files[id] = fileInfo
+	}
+
+	kept := files[types.GenerateFileID("kept.txt", []byte("still indexed!"))]
+	broken := files[types.GenerateFileID("broken.txt", []byte("loses a chunk"))]
+	fileStorage.Delete(context.Background(), broken.Chunks[1].ID)
+	fileStorage.Store(context.Background(), types.GenerateContentID([]byte("stray")), []byte("not a chunk"))
+
+	known := make(map[string]bool)
+	for _, chunkInfo := range kept.Chunks {
+		known[chunkInfo.ID] = true
+	}
+
+	result, err := cm.Reindex(func(chunkID string) bool { return known[chunkID] })
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+
+	if len(result.Files) != 2 {
+		t.Fatalf("Expected 2 rebuilt files, got %d", len(result.Files))
+	}
+	for _, rebuilt := range result.Files {
+		original := files[rebuilt.ID]
+		if original == nil || original == kept || original == broken {
+			t.Errorf("Unexpected rebuilt file %s", rebuilt.ID)
+			continue
+		}
+		if rebuilt.Hash != original.Hash || rebuilt.MerkleRoot != original.MerkleRoot || len(rebuilt.Chunks) != len(original.Chunks) || rebuilt.ChunkSize != original.ChunkSize {
+			t.Errorf("Expected rebuilt file to match original, got %+v", rebuilt)
+		}
+
+		data, err := cm.RetrieveFile(context.Background(), rebuilt)
+		if err != nil {
+			t.Errorf("Failed to retrieve rebuilt file: %v", err)
+			continue
+		}
+		if !bytes.Equal(data, contents[rebuilt.ID]) {
+			t.Errorf("Expected %q, got %q", contents[rebuilt.ID], data)
+		}
+	}
+
+	// The stray blob and the remaining chunks of the incomplete file cannot be placed
+	if want := 1 + len(broken.Chunks) - 1; len(result.Unassigned) != want {
+		t.Errorf("Expected %d unassigned chunks, got %d: %v", want, len(result.Unassigned), result.Unassigned)
+	}
+}
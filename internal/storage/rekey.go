@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// rekeyQueueSize is how many chunks read under an old key version may wait for
+// re-encryption before further ones are left to the next pass
+const rekeyQueueSize = 256
+
+// SetKeyRing replaces the master key versions chunks are encrypted under. New chunks use
+// the ring's current version; chunks under older versions stay readable.
+func (cm *ChunkManager) SetKeyRing(keys *crypto.KeyRing) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.keys = keys
+}
+
+// KeyRing returns the master key versions chunks are encrypted under
+func (cm *ChunkManager) KeyRing() *crypto.KeyRing {
+	return cm.keyRing()
+}
+
+func (cm *ChunkManager) keyRing() *crypto.KeyRing {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.keys
+}
+
+// onStaleKey sets the function told about chunks read under an old key version
+func (cm *ChunkManager) onStaleKey(fn func(types.ChunkInfo)) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.staleKey = fn
+}
+
+// noteKeyVersion tells the stale key handler, if any, about a chunk that was read from
+// its stored form under an old key version
+func (cm *ChunkManager) noteKeyVersion(chunkInfo types.ChunkInfo, stored []byte) {
+	cm.mu.RLock()
+	fn := cm.staleKey
+	cm.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	header, _, err := decodeChunkHeader(stored)
+	if current, _ := cm.keyRing().Current(); err == nil && header.keyVersion() < current {
+		fn(chunkInfo)
+	}
+}
+
+// RekeyChunk re-encrypts the local copy of a chunk under the current key version,
+// updating its replicas and deduplication record, and returns the chunk's updated
+// layout. It reports false, changing nothing, when the chunk is already under the
+// current version.
+func (cm *ChunkManager) RekeyChunk(chunkInfo types.ChunkInfo) (types.ChunkInfo, bool, error) {
+	cm.rekeyMu.Lock()
+	defer cm.rekeyMu.Unlock()
+
+	stored, err := cm.storage.Retrieve(context.Background(), chunkInfo.ID)
+	if err != nil {
+		return chunkInfo, false, fmt.Errorf("failed to read chunk %s: %w", chunkInfo.ID, err)
+	}
+	header, _, err := decodeChunkHeader(stored)
+	if err != nil {
+		return chunkInfo, false, fmt.Errorf("failed to read chunk %s: %w", chunkInfo.ID, err)
+	}
+
+	version, key := cm.currentKey(chunkInfo.KeyID)
+	if header.keyVersion() == version {
+		// Another file sharing the chunk may have had it re-encrypted already
+		chunkInfo.KeyVersion = int(version)
+		chunkInfo.Checksum = types.CalculateHash(stored)
+		return chunkInfo, false, nil
+	}
+
+	chunk, err := cm.openStoredChunk(chunkInfo, stored)
+	if err != nil {
+		return chunkInfo, false, fmt.Errorf("failed to decrypt chunk %s: %w", chunkInfo.ID, err)
+	}
+	if err := verifyChunk(chunkInfo, chunk); err != nil {
+		return chunkInfo, false, err
+	}
+
+	header.KeyVersion = version
+	sealed, err := cm.sealChunk(key, header, chunk)
+	if err != nil {
+		return chunkInfo, false, fmt.Errorf("failed to encrypt chunk %s: %w", chunkInfo.ID, err)
+	}
+
+	if err := cm.storage.Store(context.Background(), chunkInfo.ID, sealed); err != nil {
+		return chunkInfo, false, fmt.Errorf("failed to store chunk %s: %w", chunkInfo.ID, err)
+	}
+	cm.rewriteReplicas(chunkInfo.ID, chunkInfo.NodeIDs, sealed)
+
+	chunkInfo.Checksum = types.CalculateHash(sealed)
+	chunkInfo.KeyVersion = int(version)
+	if err := cm.updateChunkRecord(chunkInfo); err != nil {
+		cm.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Failed to update chunk record")
+	}
+	return chunkInfo, true, nil
+}
+
+// rewriteReplicas replaces the copies of a chunk held by the peers in nodeIDs, logging
+// failures. Peers keeping the old copy can still serve it while its key version exists.
+func (cm *ChunkManager) rewriteReplicas(chunkID string, nodeIDs []string, data []byte) {
+	cm.mu.RLock()
+	localID := cm.nodeID
+	peers := cm.peers
+	tracker := cm.tracker
+	cm.mu.RUnlock()
+
+	for _, nodeID := range nodeIDs {
+		peer, exists := peers[nodeID]
+		if nodeID == localID || !exists {
+			continue
+		}
+
+		err := peer.StoreChunk(chunkID, data)
+		cm.trackRequest(tracker, nodeID, err)
+		if err != nil {
+			cm.logger.WithError(err).WithFields(logrus.Fields{
+				"chunk_id": chunkID,
+				"peer_id":  nodeID,
+			}).Warn("Failed to rewrite chunk replica")
+		}
+	}
+}
+
+// RekeyReport summarizes a single re-encryption pass
+type RekeyReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	KeyVersion uint32    `json:"key_version"` // Version chunks were re-encrypted under
+	Checked    int       `json:"checked"`
+	Rekeyed    int       `json:"rekeyed"`
+	Failed     int       `json:"failed"`
+}
+
+// RekeyStatus reports whether a re-encryption pass is running and the result of the last one
+type RekeyStatus struct {
+	Running bool         `json:"running"`
+	LastRun *RekeyReport `json:"last_run,omitempty"`
+}
+
+// Rekeyer lazily re-encrypts chunks under the current master key version after a
+// rotation: chunks read under an old version are queued as they are accessed, and
+// periodic passes pick up the rest when the node is otherwise idle
+type Rekeyer struct {
+	chunkManager *ChunkManager
+	chunks       func() []types.ChunkInfo
+	update       func(types.ChunkInfo)
+	queue        chan types.ChunkInfo
+	logger       *logrus.Logger
+
+	status RekeyStatus
+	mu     sync.Mutex
+}
+
+// NewRekeyer creates a rekeyer for the chunks returned by the chunks function. update
+// is called with the new layout of every re-encrypted chunk so that the files referencing
+// it can record its new checksum and key version.
+func NewRekeyer(chunkManager *ChunkManager, chunks func() []types.ChunkInfo, update func(types.ChunkInfo), logger *logrus.Logger) *Rekeyer {
+	r := &Rekeyer{
+		chunkManager: chunkManager,
+		chunks:       chunks,
+		update:       update,
+		queue:        make(chan types.ChunkInfo, rekeyQueueSize),
+		logger:       logger,
+	}
+	chunkManager.onStaleKey(r.Enqueue)
+	return r
+}
+
+// Status returns the current re-encryption status
+func (r *Rekeyer) Status() RekeyStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	if status.LastRun != nil {
+		report := *status.LastRun
+		status.LastRun = &report
+	}
+	return status
+}
+
+// Enqueue asks for a chunk to be re-encrypted by Run. The chunk is left to the next pass
+// when the queue is full.
+func (r *Rekeyer) Enqueue(chunkInfo types.ChunkInfo) {
+	select {
+	case r.queue <- chunkInfo:
+	default:
+	}
+}
+
+// Run re-encrypts queued chunks as they arrive and runs a pass every interval until stop
+// is closed. A zero interval leaves chunks that are never read under their old version.
+func (r *Rekeyer) Run(interval time.Duration, stop <-chan struct{}) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case chunkInfo := <-r.queue:
+			if err := r.rekey(chunkInfo); err != nil {
+				r.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Failed to re-encrypt chunk")
+			}
+		case <-tick:
+			r.Rekey()
+		}
+	}
+}
+
+// Rekey re-encrypts every local chunk not yet under the current key version and returns
+// the report, or nil if another pass is already running
+func (r *Rekeyer) Rekey() *RekeyReport {
+	r.mu.Lock()
+	if r.status.Running {
+		r.mu.Unlock()
+		return nil
+	}
+	r.status.Running = true
+	r.mu.Unlock()
+
+	current, _ := r.chunkManager.keyRing().Current()
+	report := &RekeyReport{StartedAt: time.Now(), KeyVersion: current}
+	seen := make(map[string]bool)
+
+	for _, chunkInfo := range r.chunks() {
+		// Deduplicated chunks are shared between files, so handle each one once
+		if seen[chunkInfo.ID] || !r.chunkManager.isLocal(chunkInfo) || chunkKeyVersion(chunkInfo) >= current {
+			continue
+		}
+		seen[chunkInfo.ID] = true
+		report.Checked++
+
+		if err := r.rekey(chunkInfo); err != nil {
+			report.Failed++
+			r.logger.WithError(err).WithField("chunk_id", chunkInfo.ID).Warn("Failed to re-encrypt chunk")
+			continue
+		}
+		report.Rekeyed++
+	}
+
+	report.FinishedAt = time.Now()
+
+	r.mu.Lock()
+	r.status.Running = false
+	r.status.LastRun = report
+	r.mu.Unlock()
+
+	if report.Checked > 0 {
+		r.logger.WithFields(logrus.Fields{
+			"key_version": report.KeyVersion,
+			"rekeyed":     report.Rekeyed,
+			"failed":      report.Failed,
+		}).Info("Re-encryption pass completed")
+	}
+
+	return report
+}
+
+// rekey re-encrypts a single chunk, passing its new layout to update when that changed
+func (r *Rekeyer) rekey(chunkInfo types.ChunkInfo) error {
+	updated, _, err := r.chunkManager.RekeyChunk(chunkInfo)
+	if err != nil {
+		return err
+	}
+	if updated.KeyVersion != chunkInfo.KeyVersion || updated.Checksum != chunkInfo.Checksum {
+		r.update(updated)
+	}
+	return nil
+}
+
+// chunkKeyVersion returns the key version recorded for a chunk, counting chunks stored
+// before versions were recorded as version 1
+func chunkKeyVersion(chunkInfo types.ChunkInfo) uint32 {
+	if chunkInfo.KeyVersion <= 0 {
+		return 1
+	}
+	return uint32(chunkInfo.KeyVersion)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRekeyAfterRotation(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 8)
+	oldData := []byte("written before the rotation")
+	newData := []byte("written after the rotation")
+
+	oldFile := &types.FileInfo{ID: types.GenerateFileID("old.txt", oldData), Name: "old.txt"}
+	if err := cm.StoreFile(context.Background(), oldFile, oldData); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if _, err := cm.KeyRing().Rotate(""); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+
+	newFile := &types.FileInfo{ID: types.GenerateFileID("new.txt", newData), Name: "new.txt"}
+	if err := cm.StoreFile(context.Background(), newFile, newData); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+	if newFile.Chunks[0].KeyVersion != 2 {
+		t.Errorf("Expected new chunks under key version 2, got %d", newFile.Chunks[0].KeyVersion)
+	}
+
+	// Both versions stay readable
+	for _, tt := range []struct {
+		fileInfo *types.FileInfo
+		want     []byte
+	}{{oldFile, oldData}, {newFile, newData}} {
+		got, err := cm.RetrieveFile(context.Background(), tt.fileInfo)
+		if err != nil {
+			t.Fatalf("Failed to retrieve %s: %v", tt.fileInfo.Name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	chunks := func() []types.ChunkInfo {
+		return append(append([]types.ChunkInfo(nil), oldFile.Chunks...), newFile.Chunks...)
+	}
+	update := func(updated types.ChunkInfo) {
+		for i := range oldFile.Chunks {
+			if oldFile.Chunks[i].ID == updated.ID {
+				oldFile.Chunks[i] = updated
+			}
+		}
+	}
+	rekeyer := NewRekeyer(cm, chunks, update, logger)
+
+	report := rekeyer.Rekey()
+	if report.Rekeyed != len(oldFile.Chunks) || report.Failed != 0 {
+		t.Errorf("Expected %d chunks rekeyed, got %d (%d failed)", len(oldFile.Chunks), report.Rekeyed, report.Failed)
+	}
+	for _, chunkInfo := range oldFile.Chunks {
+		if chunkInfo.KeyVersion != 2 {
+			t.Errorf("Expected chunk %d under key version 2, got %d", chunkInfo.Index, chunkInfo.KeyVersion)
+		}
+	}
+
+	got, err := cm.RetrieveFile(context.Background(), oldFile)
+	if err != nil {
+		t.Fatalf("Failed to retrieve re-encrypted file: %v", err)
+	}
+	if !bytes.Equal(got, oldData) {
+		t.Errorf("Expected %q, got %q", oldData, got)
+	}
+
+	// A second pass finds nothing left to do
+	if report := rekeyer.Rekey(); report.Checked != 0 {
+		t.Errorf("Expected no chunks checked on the second pass, got %d", report.Checked)
+	}
+}
+
+func TestRekeyChunkCurrent(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 8)
+	data := []byte("already current")
+
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("current.txt", data), Name: "current.txt"}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	updated, rekeyed, err := cm.RekeyChunk(fileInfo.Chunks[0])
+	if err != nil {
+		t.Fatalf("Failed to rekey chunk: %v", err)
+	}
+	if rekeyed {
+		t.Errorf("Expected a chunk under the current version to be left alone")
+	}
+	if updated.Checksum != fileInfo.Chunks[0].Checksum {
+		t.Errorf("Expected the checksum to be unchanged")
+	}
+}
+
+func TestStoreFileCounterNonces(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 8)
+	if err := cm.KeyRing().EnableNonceCounters(""); err != nil {
+		t.Fatalf("Failed to enable nonce counters: %v", err)
+	}
+
+	data := []byte("sealed with counted nonces")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("counted.txt", data), Name: "counted.txt"}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	counter, ok := cm.KeyRing().Nonces(1).(*crypto.CounterNonces)
+	if !ok {
+		t.Fatalf("Expected a nonce counter for key version 1")
+	}
+	if counter.Count() != uint64(len(fileInfo.Chunks)) {
+		t.Errorf("Expected one nonce per chunk (%d), got %d", len(fileInfo.Chunks), counter.Count())
+	}
+
+	got, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Expected %q, got %q", data, got)
+	}
+}
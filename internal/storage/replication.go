@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Peer is a remote storage node that can hold chunk replicas
+type Peer interface {
+	ID() string
+	StoreChunk(chunkID string, data []byte) error
+	RetrieveChunk(chunkID string) ([]byte, error)
+	DeleteChunk(chunkID string) error
+}
+
+// PeerTracker is told the outcome of every chunk request made to a peer
+type PeerTracker interface {
+	RecordSuccess(peerID string)
+	RecordFailure(peerID string)
+}
+
+// SetReplication configures the local node ID and the total number of copies kept of each chunk,
+// including the local one
+func (cm *ChunkManager) SetReplication(nodeID string, replicas int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if nodeID != "" {
+		cm.nodeID = nodeID
+	}
+	cm.replicas = replicas
+}
+
+// AddPeer registers a peer that can receive chunk replicas
+func (cm *ChunkManager) AddPeer(peer Peer) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.peers[peer.ID()] = peer
+	cm.ring.Add(peer.ID())
+}
+
+// SetPeerTracker reports the outcome of chunk requests to peers to tracker
+func (cm *ChunkManager) SetPeerTracker(tracker PeerTracker) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.tracker = tracker
+}
+
+// trackRequest reports the outcome of a chunk request to the peer tracker, if any
+func (cm *ChunkManager) trackRequest(tracker PeerTracker, peerID string, err error) {
+	if tracker == nil {
+		return
+	}
+	if err != nil {
+		tracker.RecordFailure(peerID)
+	} else {
+		tracker.RecordSuccess(peerID)
+	}
+}
+
+// RemovePeer unregisters a peer
+func (cm *ChunkManager) RemovePeer(peerID string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	delete(cm.peers, peerID)
+	cm.ring.Remove(peerID)
+}
+
+// ChunkPlacement returns the nodes a chunk is placed on when stored: the local node
+// followed by the peers the hash ring assigns its replicas to, in order of preference.
+// Replicas move further along the ring when a preferred peer fails.
+func (cm *ChunkManager) ChunkPlacement(chunkID string) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return append([]string{cm.nodeID}, cm.ring.Owners(chunkID, cm.replicas-1)...)
+}
+
+// replicateChunk copies an encrypted chunk to replicas-1 peers and returns the IDs of the peers
+// that accepted it. Peers are chosen by walking the hash ring from the chunk ID, so that
+// placement stays stable as peers join and leave, moving on to the next peer when one fails.
+func (cm *ChunkManager) replicateChunk(chunkID string, data []byte) []string {
+	cm.mu.RLock()
+	wanted := cm.replicas - 1
+	peerIDs := cm.ring.Owners(chunkID, len(cm.peers))
+	peers := cm.peers
+	tracker := cm.tracker
+	cm.mu.RUnlock()
+
+	if wanted <= 0 || len(peerIDs) == 0 {
+		return nil
+	}
+
+	var stored []string
+	for i := 0; i < len(peerIDs) && len(stored) < wanted; i++ {
+		peerID := peerIDs[i]
+		err := peers[peerID].StoreChunk(chunkID, data)
+		cm.trackRequest(tracker, peerID, err)
+		if err != nil {
+			cm.logger.WithError(err).WithFields(logrus.Fields{
+				"chunk_id": chunkID,
+				"peer_id":  peerID,
+			}).Warn("Failed to replicate chunk")
+			continue
+		}
+		stored = append(stored, peerID)
+	}
+
+	if len(stored) < wanted {
+		cm.logger.WithFields(logrus.Fields{
+			"chunk_id": chunkID,
+			"wanted":   wanted,
+			"stored":   len(stored),
+		}).Warn("Chunk is under-replicated")
+	}
+
+	return stored
+}
+
+// retrieveReplica fetches an encrypted chunk from the first available peer holding a replica
+func (cm *ChunkManager) retrieveReplica(chunkID string, nodeIDs []string) ([]byte, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	lastErr := fmt.Errorf("no replica available for chunk %s", chunkID)
+	for _, nodeID := range nodeIDs {
+		peer, exists := cm.peers[nodeID]
+		if nodeID == cm.nodeID || !exists {
+			continue
+		}
+
+		data, err := peer.RetrieveChunk(chunkID)
+		cm.trackRequest(cm.tracker, nodeID, err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return data, nil
+	}
+
+	return nil, lastErr
+}
+
+// deleteReplicas removes a chunk from every peer holding a replica
+func (cm *ChunkManager) deleteReplicas(chunkID string, nodeIDs []string) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	for _, nodeID := range nodeIDs {
+		peer, exists := cm.peers[nodeID]
+		if nodeID == cm.nodeID || !exists {
+			continue
+		}
+
+		if err := peer.DeleteChunk(chunkID); err != nil {
+			cm.logger.WithError(err).WithFields(logrus.Fields{
+				"chunk_id": chunkID,
+				"peer_id":  nodeID,
+			}).Warn("Failed to delete chunk replica")
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// mockPeer is an in-memory peer that can be taken offline
+type mockPeer struct {
+	id      string
+	chunks  map[string][]byte
+	offline bool
+	mu      sync.Mutex
+}
+
+func newMockPeer(id string) *mockPeer {
+	return &mockPeer{id: id, chunks: make(map[string][]byte)}
+}
+
+func (p *mockPeer) ID() string { return p.id }
+
+func (p *mockPeer) StoreChunk(chunkID string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.offline {
+		return errors.New("peer offline")
+	}
+	p.chunks[chunkID] = append([]byte{}, data...)
+	return nil
+}
+
+func (p *mockPeer) RetrieveChunk(chunkID string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.offline {
+		return nil, errors.New("peer offline")
+	}
+	data, exists := p.chunks[chunkID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (p *mockPeer) DeleteChunk(chunkID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.offline {
+		return errors.New("peer offline")
+	}
+	delete(p.chunks, chunkID)
+	return nil
+}
+
+func TestChunkReplication(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	cm.SetReplication("node-0", 3)
+
+	peers := make(map[string]*mockPeer)
+	for i := 1; i <= 4; i++ {
+		peer := newMockPeer(fmt.Sprintf("node-%d", i))
+		peers[peer.id] = peer
+		cm.AddPeer(peer)
+	}
+
+	data := []byte("replicated chunk data across peers")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("replicated.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if fileInfo.Replicas != 3 {
+		t.Errorf("Expected 3 replicas, got %d", fileInfo.Replicas)
+	}
+
+	// Each chunk should be held locally and by exactly two peers
+	for _, chunkInfo := range fileInfo.Chunks {
+		if len(chunkInfo.NodeIDs) != 3 || chunkInfo.NodeIDs[0] != "node-0" {
+			t.Errorf("Chunk %d: expected local + 2 peers, got %v", chunkInfo.Index, chunkInfo.NodeIDs)
+		}
+
+		for _, nodeID := range chunkInfo.NodeIDs[1:] {
+			if _, exists := peers[nodeID].chunks[chunkInfo.ID]; !exists {
+				t.Errorf("Chunk %d: missing on peer %s", chunkInfo.Index, nodeID)
+			}
+		}
+	}
+
+	// Lose the local copy and take one peer offline; the file should still be retrievable
+	for _, chunkInfo := range fileInfo.Chunks {
+		fileStorage.Delete(context.Background(), chunkInfo.ID)
+	}
+	peers[fileInfo.Chunks[0].NodeIDs[1]].offline = true
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file from replicas: %v", err)
+	}
+
+	if !bytes.Equal(data, retrieved) {
+		t.Errorf("Expected %s, got %s", data, retrieved)
+	}
+}
+
+func TestChunkReplicationSkipsFailedPeer(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 1024)
+	cm.SetReplication("node-0", 3)
+
+	offline := newMockPeer("node-1")
+	offline.offline = true
+	cm.AddPeer(offline)
+	cm.AddPeer(newMockPeer("node-2"))
+	cm.AddPeer(newMockPeer("node-3"))
+
+	data := []byte("data")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("skip.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	nodeIDs := fileInfo.Chunks[0].NodeIDs
+	if len(nodeIDs) != 3 {
+		t.Fatalf("Expected 3 replicas despite offline peer, got %v", nodeIDs)
+	}
+
+	for _, nodeID := range nodeIDs {
+		if nodeID == "node-1" {
+			t.Errorf("Offline peer should not hold a replica")
+		}
+	}
+}
+
+// countingTracker records chunk request outcomes per peer
+type countingTracker struct {
+	successes map[string]int
+	failures  map[string]int
+	mu        sync.Mutex
+}
+
+func (c *countingTracker) RecordSuccess(peerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.successes[peerID]++
+}
+
+func (c *countingTracker) RecordFailure(peerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[peerID]++
+}
+
+func TestPeerTracker(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 1024)
+	cm.SetReplication("node-0", 4)
+
+	tracker := &countingTracker{successes: make(map[string]int), failures: make(map[string]int)}
+	cm.SetPeerTracker(tracker)
+
+	offline := newMockPeer("node-1")
+	offline.offline = true
+	cm.AddPeer(offline)
+	cm.AddPeer(newMockPeer("node-2"))
+	cm.AddPeer(newMockPeer("node-3"))
+
+	data := []byte("data")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("tracked.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	if tracker.failures["node-1"] != 1 || tracker.successes["node-2"] != 1 || tracker.successes["node-3"] != 1 {
+		t.Errorf("Expected one failure for node-1 and one success each for node-2 and node-3, got %v and %v",
+			tracker.failures, tracker.successes)
+	}
+
+	// Reading from a replica counts as a request too
+	fileStorage.Delete(context.Background(), fileInfo.Chunks[0].ID)
+	if _, err := cm.RetrieveFile(context.Background(), fileInfo); err != nil {
+		t.Fatalf("Failed to retrieve file from replica: %v", err)
+	}
+	if tracker.successes["node-2"]+tracker.successes["node-3"] != 3 {
+		t.Errorf("Expected replica read to be tracked, got %v", tracker.successes)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// defaultVirtualNodes is how many points each node gets on a ring created with no count,
+// enough to spread keys within a few percent of evenly across a handful of nodes
+const defaultVirtualNodes = 128
+
+// HashRing places keys on nodes by consistent hashing. Every node is hashed onto the ring
+// at several virtual points, and a key belongs to the nodes at the first points found
+// walking clockwise from the key's hash. Adding or removing a node only moves the keys
+// next to its points, about 1/n of them.
+type HashRing struct {
+	virtualNodes int
+	points       []ringPoint // Ordered by hash
+	nodes        map[string]bool
+	mu           sync.RWMutex
+}
+
+// ringPoint is one virtual node on the ring
+type ringPoint struct {
+	hash   uint64
+	nodeID string
+}
+
+// NewHashRing creates an empty ring giving each node virtualNodes points, or the default
+// when virtualNodes is not positive
+func NewHashRing(virtualNodes int) *HashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+	return &HashRing{virtualNodes: virtualNodes, nodes: make(map[string]bool)}
+}
+
+// Add places a node on the ring. Adding a node already on it has no effect.
+func (r *HashRing) Add(nodeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nodes[nodeID] {
+		return
+	}
+	r.nodes[nodeID] = true
+
+	for i := 0; i < r.virtualNodes; i++ {
+		r.points = append(r.points, ringPoint{hash: ringHash(nodeID + "#" + strconv.Itoa(i)), nodeID: nodeID})
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash != r.points[j].hash {
+			return r.points[i].hash < r.points[j].hash
+		}
+		return r.points[i].nodeID < r.points[j].nodeID
+	})
+}
+
+// Remove takes a node off the ring
+func (r *HashRing) Remove(nodeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.nodes[nodeID] {
+		return
+	}
+	delete(r.nodes, nodeID)
+
+	points := r.points[:0]
+	for _, point := range r.points {
+		if point.nodeID != nodeID {
+			points = append(points, point)
+		}
+	}
+	r.points = points
+}
+
+// Len returns the number of nodes on the ring
+func (r *HashRing) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.nodes)
+}
+
+// Owners returns up to n distinct nodes owning key, in order of preference
+func (r *HashRing) Owners(key string, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	hash := ringHash(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= hash })
+
+	owners := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; len(owners) < n; i++ {
+		point := r.points[(start+i)%len(r.points)]
+		if !seen[point.nodeID] {
+			seen[point.nodeID] = true
+			owners = append(owners, point.nodeID)
+		}
+	}
+	return owners
+}
+
+// ringHash maps a key to its position on the ring
+func ringHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
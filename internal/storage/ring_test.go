@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// ringAssignments returns the first owner of each of count keys
+func ringAssignments(ring *HashRing, count int) map[string]string {
+	assignments := make(map[string]string, count)
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("chunk-%d", i)
+		assignments[key] = ring.Owners(key, 1)[0]
+	}
+	return assignments
+}
+
+func TestHashRingOwners(t *testing.T) {
+	ring := NewHashRing(0)
+	if owners := ring.Owners("chunk", 2); len(owners) != 0 {
+		t.Errorf("Expected no owners on an empty ring, got %v", owners)
+	}
+
+	for i := 1; i <= 3; i++ {
+		ring.Add(fmt.Sprintf("node-%d", i))
+	}
+	ring.Add("node-1")
+	if ring.Len() != 3 {
+		t.Errorf("Expected 3 nodes, got %d", ring.Len())
+	}
+
+	tests := []struct {
+		n    int
+		want int
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 3},
+		{5, 3}, // Capped at the number of nodes
+		{0, 0},
+	}
+
+	for _, tt := range tests {
+		owners := ring.Owners("chunk", tt.n)
+		if len(owners) != tt.want {
+			t.Errorf("Owners(%d): expected %d owners, got %v", tt.n, tt.want, owners)
+		}
+		seen := make(map[string]bool)
+		for _, owner := range owners {
+			if seen[owner] {
+				t.Errorf("Owners(%d): expected distinct owners, got %v", tt.n, owners)
+			}
+			seen[owner] = true
+		}
+	}
+
+	// Owners are stable and a prefix of the longer list
+	first, all := ring.Owners("chunk", 1), ring.Owners("chunk", 3)
+	if first[0] != all[0] {
+		t.Errorf("Expected the first owner %s to lead %v", first[0], all)
+	}
+
+	ring.Remove(all[0])
+	if owners := ring.Owners("chunk", 1); owners[0] != all[1] {
+		t.Errorf("Expected %s to take over from the removed node, got %s", all[1], owners[0])
+	}
+}
+
+func TestHashRingRebalance(t *testing.T) {
+	const keys = 10000
+
+	ring := NewHashRing(0)
+	for i := 1; i <= 10; i++ {
+		ring.Add(fmt.Sprintf("node-%d", i))
+	}
+	before := ringAssignments(ring, keys)
+
+	// Load is spread roughly evenly
+	load := make(map[string]int)
+	for _, owner := range before {
+		load[owner]++
+	}
+	for owner, count := range load {
+		if count < keys/10/2 || count > keys/10*2 {
+			t.Errorf("Expected about %d keys on %s, got %d", keys/10, owner, count)
+		}
+	}
+
+	// A new node takes about 1/11 of the keys and nothing else moves
+	ring.Add("node-11")
+	after := ringAssignments(ring, keys)
+
+	moved := 0
+	for key, owner := range after {
+		if owner == before[key] {
+			continue
+		}
+		moved++
+		if owner != "node-11" {
+			t.Errorf("Expected %s to move only to the new node, got %s", key, owner)
+		}
+	}
+	if fraction := float64(moved) / keys; fraction > 0.15 {
+		t.Errorf("Expected under 15%% of keys to move, got %.1f%%", fraction*100)
+	}
+
+	// Removing it again restores the original placement
+	ring.Remove("node-11")
+	for key, owner := range ringAssignments(ring, keys) {
+		if owner != before[key] {
+			t.Errorf("Expected %s back on %s, got %s", key, before[key], owner)
+			break
+		}
+	}
+}
+
+func TestReplicationFollowsRing(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 8)
+	cm.SetReplication("node-0", 3)
+
+	peers := make(map[string]*mockPeer)
+	for i := 1; i <= 5; i++ {
+		peer := newMockPeer(fmt.Sprintf("node-%d", i))
+		peers[peer.id] = peer
+		cm.AddPeer(peer)
+	}
+
+	data := []byte("placed by consistent hashing")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("ring.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	for _, chunkInfo := range fileInfo.Chunks {
+		placement := cm.ChunkPlacement(chunkInfo.ID)
+		if fmt.Sprint(chunkInfo.NodeIDs) != fmt.Sprint(placement) {
+			t.Errorf("Chunk %d: expected nodes %v, got %v", chunkInfo.Index, placement, chunkInfo.NodeIDs)
+		}
+	}
+
+	// A failed owner hands its replica to the next peer on the ring
+	chunkID := fileInfo.Chunks[0].ID
+	preferred := cm.ring.Owners(chunkID, 5)
+	peers[preferred[0]].offline = true
+	stored := cm.replicateChunk(chunkID, []byte("replica"))
+	if fmt.Sprint(stored) != fmt.Sprint(preferred[1:3]) {
+		t.Errorf("Expected replicas on %v, got %v", preferred[1:3], stored)
+	}
+}
@@ -0,0 +1,534 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// s3Service is the service name used in AWS Signature Version 4 scopes
+	s3Service = "s3"
+
+	// s3Attempts is how many times a request is sent before a network error, throttling or
+	// server error is returned
+	s3Attempts = 3
+
+	// s3RetryDelay is the wait before the first retry, doubled for each one after it
+	s3RetryDelay = 200 * time.Millisecond
+)
+
+// S3Storage implements Storage on an S3-compatible object store such as AWS S3 or MinIO.
+// Objects are addressed path-style as endpoint/bucket/prefix+key. Requests are signed
+// here with Signature Version 4 rather than through an AWS SDK, which this build cannot
+// depend on; the signer is checked against the AWS examples. Only what chunk storage
+// needs is covered: single-request PUTs, which chunks stay far below the 5GB limit of,
+// and retries of transient failures. There are no region redirects, so region must
+// match the bucket's, and credentials come from the configuration alone, not the AWS
+// credential chain of environment, shared files and instance roles.
+type S3Storage struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	prefix          string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+	logger          *logrus.Logger
+	now             func() time.Time
+	retryDelay      time.Duration
+
+	usage       int64 // Bytes stored, counted by listing the bucket on first use
+	usageLoaded bool
+	usageMu     sync.Mutex
+}
+
+// NewS3Storage creates a storage backend for the configured bucket
+func NewS3Storage(cfg config.S3Config, logger *logrus.Logger) (*S3Storage, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Storage{
+		endpoint:        endpoint,
+		region:          region,
+		bucket:          cfg.Bucket,
+		prefix:          cfg.Prefix,
+		accessKeyID:     cfg.AccessKeyID,
+		secretAccessKey: cfg.SecretAccessKey,
+		client:          &http.Client{Timeout: 30 * time.Second},
+		logger:          logger,
+		now:             time.Now,
+		retryDelay:      s3RetryDelay,
+	}, nil
+}
+
+// Store writes data under the given key
+func (s *S3Storage) Store(ctx context.Context, key string, data []byte) error {
+	if !utils.ValidateFileID(key) {
+		return fmt.Errorf("invalid storage key: %s", key)
+	}
+
+	// Overwriting a key replaces the bytes counted for it; until usage is first asked
+	// for there is no count to correct
+	var previous int64
+	if s.usageCounted() {
+		previous, _ = s.size(ctx, key)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, s.prefix+key, nil, data)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to write %s: %w", key, s3Error(resp))
+	}
+	s.addUsage(int64(len(data)) - previous)
+
+	s.logger.WithFields(logrus.Fields{
+		"key":    key,
+		"size":   len(data),
+		"bucket": s.bucket,
+	}).Debug("Stored data")
+
+	return nil
+}
+
+// Retrieve reads the data stored under the given key
+func (s *S3Storage) Retrieve(ctx context.Context, key string) ([]byte, error) {
+	if !utils.ValidateFileID(key) {
+		return nil, fmt.Errorf("invalid storage key: %s", key)
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, s.prefix+key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	default:
+		return nil, fmt.Errorf("failed to read %s: %w", key, s3Error(resp))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// Delete removes the data stored under the given key
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if !utils.ValidateFileID(key) {
+		return fmt.Errorf("invalid storage key: %s", key)
+	}
+
+	// S3 deletes are idempotent, so check existence to report missing keys like FileStorage
+	size, found := s.size(ctx, key)
+	if !found {
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, s.prefix+key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete %s: %w", key, s3Error(resp))
+	}
+	s.addUsage(-size)
+	return nil
+}
+
+// Exists checks whether data is stored under the given key
+func (s *S3Storage) Exists(key string) bool {
+	return s.exists(context.Background(), key)
+}
+
+// exists checks whether data is stored under the given key, giving up when ctx is done
+func (s *S3Storage) exists(ctx context.Context, key string) bool {
+	_, found := s.size(ctx, key)
+	return found
+}
+
+// size returns the size of the object stored under the given key and whether there is one
+func (s *S3Storage) size(ctx context.Context, key string) (int64, bool) {
+	if !utils.ValidateFileID(key) {
+		return 0, false
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, s.prefix+key, nil, nil)
+	if err != nil {
+		s.logger.WithError(err).WithField("key", key).Warn("Failed to check object")
+		return 0, false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, false
+	}
+	return max(resp.ContentLength, 0), true
+}
+
+// List returns all stored keys
+func (s *S3Storage) List() ([]string, error) {
+	var keys []string
+	err := s.listObjects(func(key string, size int64) {
+		keys = append(keys, key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	return keys, nil
+}
+
+// ListPrefix returns the stored keys starting with prefix, in order
+func (s *S3Storage) ListPrefix(prefix string) ([]string, error) {
+	var keys []string
+	err := s.listObjectsFrom(prefix, "", func(key string, size int64) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	return keys, nil
+}
+
+// ListPage returns up to limit stored keys sorting after the given key, in order
+func (s *S3Storage) ListPage(after string, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid page limit: %d", limit)
+	}
+
+	var keys []string
+	err := s.listObjectsFrom("", after, func(key string, size int64) bool {
+		keys = append(keys, key)
+		return len(keys) < limit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	return keys, nil
+}
+
+// GetUsage returns the total number of bytes stored. The bucket is listed once, on the
+// first call; after that the count is kept as keys are stored and deleted.
+func (s *S3Storage) GetUsage() (int64, error) {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if s.usageLoaded {
+		return s.usage, nil
+	}
+
+	var usage int64
+	err := s.listObjects(func(key string, size int64) {
+		usage += size
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate usage: %w", err)
+	}
+	s.usage = usage
+	s.usageLoaded = true
+	return usage, nil
+}
+
+// usageCounted reports whether the stored byte count has been loaded
+func (s *S3Storage) usageCounted() bool {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	return s.usageLoaded
+}
+
+// addUsage adjusts the stored byte count once it has been loaded
+func (s *S3Storage) addUsage(delta int64) {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if s.usageLoaded {
+		s.usage += delta
+	}
+}
+
+// listBucketResult is the subset of a ListObjectsV2 response used by the backend
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// listObjects calls fn for every stored key under the prefix, following pagination
+func (s *S3Storage) listObjects(fn func(key string, size int64)) error {
+	return s.listObjectsFrom("", "", func(key string, size int64) bool {
+		fn(key, size)
+		return true
+	})
+}
+
+// listObjectsFrom calls fn in key order for the stored keys starting with keyPrefix and
+// sorting after startAfter, following pagination until fn returns false
+func (s *S3Storage) listObjectsFrom(keyPrefix, startAfter string, fn func(key string, size int64) bool) error {
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if s.prefix+keyPrefix != "" {
+			query.Set("prefix", s.prefix+keyPrefix)
+		}
+		if startAfter != "" {
+			query.Set("start-after", s.prefix+startAfter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(context.Background(), http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error(resp)
+			resp.Body.Close()
+			return err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse list response: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			key := strings.TrimPrefix(object.Key, s.prefix)
+			if utils.ValidateFileID(key) && !fn(key, object.Size) {
+				return nil
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request for an object key (or the bucket itself when key is empty),
+// abandoning it when ctx is done. Network errors, throttling and server errors are
+// retried with exponential backoff; every call used is idempotent.
+func (s *S3Storage) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	delay := s.retryDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := s.send(ctx, method, key, query, body)
+		if attempt == s3Attempts || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"method":  method,
+			"key":     key,
+			"attempt": attempt,
+		}).Debug("Retrying S3 request")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// retryable reports whether a request failed in a way that may pass when sent again
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// send sends a signed request once, signing it afresh so its date stays current
+func (s *S3Storage) send(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	s.sign(req, body, s.now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers to the request
+func (s *S3Storage) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalHeaders, signedHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, s3Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, s3Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalHeaders returns the canonical header block and signed header list for the
+// host header and every header set on the request
+func canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// canonicalQuery returns the query string sorted and encoded as required by SigV4
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte except unreserved characters. Slashes are kept
+// unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Error builds an error from an unexpected S3 response
+func s3Error(resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&body); err == nil && body.Code != "" {
+		return fmt.Errorf("s3 error %d %s: %s", resp.StatusCode, body.Code, body.Message)
+	}
+	return fmt.Errorf("s3 error %d", resp.StatusCode)
+}
+
+// sha256Hex returns the hex-encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ScrubFailure describes a chunk that failed verification during a scrub
+type ScrubFailure struct {
+	ChunkID  string `json:"chunk_id"`
+	Error    string `json:"error"`
+	Repaired bool   `json:"repaired"`
+}
+
+// ScrubReport summarizes a single scrub pass
+type ScrubReport struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Checked    int            `json:"checked"`
+	Corrupted  int            `json:"corrupted"`
+	Repaired   int            `json:"repaired"`
+	Failures   []ScrubFailure `json:"failures"`
+}
+
+// ScrubStatus reports whether a scrub is running and the result of the last one
+type ScrubStatus struct {
+	Running bool         `json:"running"`
+	LastRun *ScrubReport `json:"last_run,omitempty"`
+}
+
+// Scrubber periodically verifies locally stored chunks against their checksums,
+// repairing corrupted or missing chunks from replicas where possible
+type Scrubber struct {
+	chunkManager *ChunkManager
+	chunks       func() []types.ChunkInfo
+	logger       *logrus.Logger
+
+	status ScrubStatus
+	mu     sync.Mutex
+}
+
+// NewScrubber creates a scrubber verifying the chunks returned by the chunks function
+func NewScrubber(chunkManager *ChunkManager, chunks func() []types.ChunkInfo, logger *logrus.Logger) *Scrubber {
+	return &Scrubber{
+		chunkManager: chunkManager,
+		chunks:       chunks,
+		logger:       logger,
+	}
+}
+
+// Status returns the current scrub status
+func (s *Scrubber) Status() ScrubStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	if status.LastRun != nil {
+		report := *status.LastRun
+		status.LastRun = &report
+	}
+	return status
+}
+
+// Run scrubs every interval until stop is closed
+func (s *Scrubber) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Scrub()
+		}
+	}
+}
+
+// Scrub verifies every locally stored chunk once and returns the report,
+// or nil if another scrub is already running
+func (s *Scrubber) Scrub() *ScrubReport {
+	s.mu.Lock()
+	if s.status.Running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.status.Running = true
+	s.mu.Unlock()
+
+	report := &ScrubReport{StartedAt: time.Now(), Failures: []ScrubFailure{}}
+	seen := make(map[string]bool)
+
+	for _, chunkInfo := range s.chunks() {
+		// Deduplicated chunks are shared between files, so check each one once
+		if seen[chunkInfo.ID] || !s.chunkManager.isLocal(chunkInfo) {
+			continue
+		}
+		seen[chunkInfo.ID] = true
+		report.Checked++
+
+		err := s.chunkManager.verifyChunk(chunkInfo)
+		if err == nil {
+			continue
+		}
+
+		report.Corrupted++
+		failure := ScrubFailure{ChunkID: chunkInfo.ID, Error: err.Error()}
+		if repairErr := s.chunkManager.repairChunk(chunkInfo); repairErr == nil {
+			failure.Repaired = true
+			report.Repaired++
+		} else {
+			failure.Error = fmt.Sprintf("%v; repair failed: %v", err, repairErr)
+		}
+		report.Failures = append(report.Failures, failure)
+
+		s.logger.WithFields(logrus.Fields{
+			"chunk_id": chunkInfo.ID,
+			"repaired": failure.Repaired,
+		}).Warn("Scrub found corrupted chunk")
+	}
+
+	report.FinishedAt = time.Now()
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.LastRun = report
+	s.mu.Unlock()
+
+	s.logger.WithFields(logrus.Fields{
+		"checked":   report.Checked,
+		"corrupted": report.Corrupted,
+		"repaired":  report.Repaired,
+	}).Info("Scrub completed")
+
+	return report
+}
+
+// isLocal reports whether the chunk should be held by this node
+func (cm *ChunkManager) isLocal(chunkInfo types.ChunkInfo) bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	for _, nodeID := range chunkInfo.NodeIDs {
+		if nodeID == cm.nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyChunk checks the local copy of a chunk against its checksum
+func (cm *ChunkManager) verifyChunk(chunkInfo types.ChunkInfo) error {
+	data, err := cm.storage.Retrieve(context.Background(), chunkInfo.ID)
+	if err != nil {
+		return err
+	}
+
+	if types.CalculateHash(data) != chunkInfo.Checksum {
+		return fmt.Errorf("checksum mismatch for chunk %s", chunkInfo.ID)
+	}
+	return nil
+}
+
+// repairChunk replaces the local copy of a chunk with the first replica matching its checksum
+func (cm *ChunkManager) repairChunk(chunkInfo types.ChunkInfo) error {
+	for _, nodeID := range chunkInfo.NodeIDs {
+		data, err := cm.retrieveReplica(chunkInfo.ID, []string{nodeID})
+		if err != nil || types.CalculateHash(data) != chunkInfo.Checksum {
+			continue
+		}
+
+		if err := cm.storage.Store(context.Background(), chunkInfo.ID, data); err != nil {
+			return fmt.Errorf("failed to store repaired chunk: %w", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no intact replica available")
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestScrubber creates a scrubber over the chunks of the given files
+func newTestScrubber(cm *ChunkManager, files ...*types.FileInfo) *Scrubber {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return NewScrubber(cm, func() []types.ChunkInfo {
+		var chunks []types.ChunkInfo
+		for _, fileInfo := range files {
+			chunks = append(chunks, fileInfo.Chunks...)
+		}
+		return chunks
+	}, logger)
+}
+
+func TestScrubberRepairsFromReplica(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	cm.SetReplication("node-0", 2)
+	cm.AddPeer(newMockPeer("node-1"))
+
+	data := []byte("scrub me please")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("scrub.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	scrubber := newTestScrubber(cm, fileInfo)
+	if report := scrubber.Scrub(); report.Checked != len(fileInfo.Chunks) || report.Corrupted != 0 {
+		t.Fatalf("Expected %d clean chunks, got %+v", len(fileInfo.Chunks), report)
+	}
+
+	// Flip a bit in one chunk
+	corrupted := fileInfo.Chunks[1]
+	stored, _ := fileStorage.Retrieve(context.Background(), corrupted.ID)
+	stored[len(stored)-1] ^= 0x01
+	fileStorage.Store(context.Background(), corrupted.ID, stored)
+
+	report := scrubber.Scrub()
+	if report.Corrupted != 1 || report.Repaired != 1 {
+		t.Fatalf("Expected 1 corrupted and repaired chunk, got %+v", report)
+	}
+	if report.Failures[0].ChunkID != corrupted.ID {
+		t.Errorf("Expected failure for chunk %s, got %s", corrupted.ID, report.Failures[0].ChunkID)
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve repaired file: %v", err)
+	}
+	if !bytes.Equal(retrieved, data) {
+		t.Errorf("Expected %q, got %q", data, retrieved)
+	}
+
+	if status := scrubber.Status(); status.Running || status.LastRun == nil || status.LastRun.Repaired != 1 {
+		t.Errorf("Expected status to report the last run, got %+v", status)
+	}
+}
+
+func TestScrubberFlagsUnrepairable(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+
+	data := []byte("single copy")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("single.txt", data)}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+		t.Fatalf("Failed to store file: %v", err)
+	}
+
+	fileStorage.Store(context.Background(), fileInfo.Chunks[0].ID, []byte("garbage"))
+	fileStorage.Delete(context.Background(), fileInfo.Chunks[2].ID)
+
+	report := newTestScrubber(cm, fileInfo, fileInfo).Scrub()
+	if report.Checked != len(fileInfo.Chunks) {
+		t.Errorf("Expected shared chunks to be checked once (%d), got %d", len(fileInfo.Chunks), report.Checked)
+	}
+	if report.Corrupted != 2 || report.Repaired != 0 {
+		t.Errorf("Expected 2 corrupted and 0 repaired chunks, got %+v", report)
+	}
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// Metadata buckets used by the share manager
+const (
+	shareBucket    = "shares"
+	shareKeyBucket = "share_keys"
+	shareKeyName   = "signing_key"
+)
+
+// Errors returned when resolving share tokens
+var (
+	ErrShareInvalid = errors.New("invalid share token")
+	ErrShareExpired = errors.New("share link expired")
+	ErrShareRevoked = errors.New("share link revoked")
+)
+
+// ShareLink grants access to a single file without authentication until it expires
+type ShareLink struct {
+	ID        string    `json:"id"`
+	FileID    string    `json:"file_id"`
+	Owner     string    `json:"owner"`
+	SingleUse bool      `json:"single_use"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShareManager issues and validates share tokens. A token carries the link ID and
+// expiry signed with HMAC-SHA256; the link record is kept in the metadata store so
+// links can be revoked or consumed.
+type ShareManager struct {
+	store metadata.Store
+	key   []byte
+	now   func() time.Time
+	mu    sync.Mutex
+}
+
+// NewShareManager creates a share manager persisting links in the metadata store.
+// The signing key is loaded from the store, or generated on first use.
+func NewShareManager(store metadata.Store) *ShareManager {
+	return &ShareManager{store: store, now: time.Now}
+}
+
+// Create issues a link to a file valid for ttl and returns it with its token
+func (sm *ShareManager) Create(fileID, owner string, ttl time.Duration, singleUse bool) (*ShareLink, string, error) {
+	if ttl <= 0 {
+		return nil, "", fmt.Errorf("invalid share ttl: %s", ttl)
+	}
+
+	id, err := utils.GenerateRandomID(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate share ID: %w", err)
+	}
+
+	now := sm.now()
+	link := &ShareLink{
+		ID:        id,
+		FileID:    fileID,
+		Owner:     owner,
+		SingleUse: singleUse,
+		ExpiresAt: now.Add(ttl).Truncate(time.Millisecond),
+		CreatedAt: now,
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err := sm.loadKey(); err != nil {
+		return nil, "", err
+	}
+	if err := sm.store.Put(shareBucket, id, link); err != nil {
+		return nil, "", fmt.Errorf("failed to save share link: %w", err)
+	}
+
+	return link, sm.sign(link), nil
+}
+
+// Resolve validates a token and returns its link. Single-use links are consumed.
+func (sm *ShareManager) Resolve(token string) (*ShareLink, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err := sm.loadKey(); err != nil {
+		return nil, err
+	}
+
+	id, expiresAt, err := sm.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if !sm.now().Before(expiresAt) {
+		return nil, ErrShareExpired
+	}
+
+	var link ShareLink
+	if err := sm.store.Get(shareBucket, id, &link); err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return nil, ErrShareRevoked
+		}
+		return nil, fmt.Errorf("failed to load share link: %w", err)
+	}
+
+	if link.SingleUse {
+		if err := sm.store.Delete(shareBucket, id); err != nil {
+			return nil, fmt.Errorf("failed to consume share link: %w", err)
+		}
+	}
+
+	return &link, nil
+}
+
+// Get returns the link with the given ID
+func (sm *ShareManager) Get(id string) (*ShareLink, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	var link ShareLink
+	if err := sm.store.Get(shareBucket, id, &link); err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return nil, ErrShareRevoked
+		}
+		return nil, fmt.Errorf("failed to load share link: %w", err)
+	}
+	return &link, nil
+}
+
+// Revoke invalidates a link before it expires
+func (sm *ShareManager) Revoke(id string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err := sm.store.Delete(shareBucket, id); err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return ErrShareRevoked
+		}
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	return nil
+}
+
+// loadKey loads the signing key from the store, generating and saving one if none
+// exists yet. Callers must hold sm.mu.
+func (sm *ShareManager) loadKey() error {
+	if sm.key != nil {
+		return nil
+	}
+
+	var encoded string
+	err := sm.store.Get(shareKeyBucket, shareKeyName, &encoded)
+	if err == nil {
+		key, err := hex.DecodeString(encoded)
+		if err != nil || len(key) != sha256.Size {
+			return fmt.Errorf("invalid share signing key")
+		}
+		sm.key = key
+		return nil
+	}
+	if !errors.Is(err, metadata.ErrNotFound) {
+		return fmt.Errorf("failed to load share signing key: %w", err)
+	}
+
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate share signing key: %w", err)
+	}
+	if err := sm.store.Put(shareKeyBucket, shareKeyName, hex.EncodeToString(key)); err != nil {
+		return fmt.Errorf("failed to save share signing key: %w", err)
+	}
+	sm.key = key
+	return nil
+}
+
+// sign builds the token for a link: base64url("<id>.<expiry ms>") "." base64url(hmac)
+func (sm *ShareManager) sign(link *ShareLink) string {
+	payload := link.ID + "." + strconv.FormatInt(link.ExpiresAt.UnixMilli(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(sm.mac(payload))
+}
+
+// verify checks a token's signature and returns the link ID and expiry it carries
+func (sm *ShareManager) verify(token string) (string, time.Time, error) {
+	encodedPayload, encodedMAC, found := strings.Cut(token, ".")
+	if !found {
+		return "", time.Time{}, ErrShareInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", time.Time{}, ErrShareInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, sm.mac(string(payload))) {
+		return "", time.Time{}, ErrShareInvalid
+	}
+
+	id, expiry, found := strings.Cut(string(payload), ".")
+	if !found {
+		return "", time.Time{}, ErrShareInvalid
+	}
+	millis, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", time.Time{}, ErrShareInvalid
+	}
+
+	return id, time.UnixMilli(millis), nil
+}
+
+// mac returns the HMAC-SHA256 of a token payload
+func (sm *ShareManager) mac(payload string) []byte {
+	h := hmac.New(sha256.New, sm.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
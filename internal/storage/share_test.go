@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+)
+
+func TestShareManager(t *testing.T) {
+	store := metadata.NewMemoryStore()
+	shares := NewShareManager(store)
+
+	now := time.Now()
+	shares.now = func() time.Time { return now }
+
+	link, token, err := shares.Create("file-1", "alice", time.Hour, false)
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+
+	// Links can be used repeatedly until they expire
+	for i := 0; i < 2; i++ {
+		resolved, err := shares.Resolve(token)
+		if err != nil {
+			t.Fatalf("Failed to resolve token: %v", err)
+		}
+		if resolved.ID != link.ID || resolved.FileID != "file-1" || resolved.Owner != "alice" {
+			t.Errorf("Expected link %+v, got %+v", link, resolved)
+		}
+	}
+
+	// Tokens stay valid across managers sharing a store
+	if _, err := NewShareManager(store).Resolve(token); err != nil {
+		t.Errorf("Expected token to verify with the persisted key, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := shares.Resolve(token); !errors.Is(err, ErrShareExpired) {
+		t.Errorf("Expected ErrShareExpired, got %v", err)
+	}
+}
+
+func TestShareTokenTampering(t *testing.T) {
+	shares := NewShareManager(metadata.NewMemoryStore())
+
+	_, token, err := shares.Create("file-1", "alice", time.Millisecond, false)
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+	payload, mac, _ := strings.Cut(token, ".")
+
+	// Pushing the expiry out invalidates the signature
+	decoded, _ := base64.RawURLEncoding.DecodeString(payload)
+	id, _, _ := strings.Cut(string(decoded), ".")
+	extended := base64.RawURLEncoding.EncodeToString([]byte(id + ".99999999999999"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"extended expiry", extended + "." + mac},
+		{"bad signature", payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged"))},
+		{"no signature", payload},
+		{"not base64", "!!!." + mac},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := shares.Resolve(tt.token); !errors.Is(err, ErrShareInvalid) {
+				t.Errorf("Expected ErrShareInvalid, got %v", err)
+			}
+		})
+	}
+
+	if _, err := NewShareManager(metadata.NewMemoryStore()).Resolve(token); !errors.Is(err, ErrShareInvalid) {
+		t.Errorf("Expected token signed with another key to be invalid, got %v", err)
+	}
+}
+
+func TestShareRevokeAndSingleUse(t *testing.T) {
+	shares := NewShareManager(metadata.NewMemoryStore())
+
+	link, token, err := shares.Create("file-1", "alice", time.Hour, false)
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+	if err := shares.Revoke(link.ID); err != nil {
+		t.Fatalf("Failed to revoke: %v", err)
+	}
+	if _, err := shares.Resolve(token); !errors.Is(err, ErrShareRevoked) {
+		t.Errorf("Expected ErrShareRevoked, got %v", err)
+	}
+	if err := shares.Revoke(link.ID); !errors.Is(err, ErrShareRevoked) {
+		t.Errorf("Expected ErrShareRevoked for repeated revoke, got %v", err)
+	}
+
+	_, token, err = shares.Create("file-1", "alice", time.Hour, true)
+	if err != nil {
+		t.Fatalf("Failed to create share link: %v", err)
+	}
+	if _, err := shares.Resolve(token); err != nil {
+		t.Fatalf("Failed to resolve single-use token: %v", err)
+	}
+	if _, err := shares.Resolve(token); !errors.Is(err, ErrShareRevoked) {
+		t.Errorf("Expected single-use token to be consumed, got %v", err)
+	}
+
+	if _, _, err := shares.Create("file-1", "alice", 0, false); err == nil {
+		t.Errorf("Expected error for zero ttl")
+	}
+}
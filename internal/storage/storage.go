@@ -0,0 +1,366 @@
+// Package storage provides chunk storage backends for the distributed storage system
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/config"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotFound is returned when a key does not exist in storage
+var ErrNotFound = errors.New("not found")
+
+// Storage defines the interface for a key-value blob store. Reads and writes of blobs
+// give up with the context's error once ctx is done.
+type Storage interface {
+	Store(ctx context.Context, key string, data []byte) error
+	Retrieve(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	Exists(key string) bool
+	List() ([]string, error)
+	ListPrefix(prefix string) ([]string, error)         // Keys starting with prefix, in order
+	ListPage(after string, limit int) ([]string, error) // Up to limit keys sorting after the given one, in order
+	GetUsage() (int64, error)
+}
+
+// NewStorage creates the storage backend selected by the configuration
+func NewStorage(cfg config.StorageConfig, logger *logrus.Logger) (Storage, error) {
+	switch cfg.Backend {
+	case "", "filesystem":
+		fs, err := NewFileStorageWithLayout(cfg.Path, Layout{Depth: cfg.PathDepth, Width: cfg.PathWidth}, logger)
+		if err != nil {
+			return nil, err
+		}
+		fs.SetDurable(cfg.Durable)
+		return fs, nil
+	case "s3":
+		return NewS3Storage(cfg.S3, logger)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+// FileStorage implements Storage on the local filesystem
+type FileStorage struct {
+	basePath string
+	layout   Layout
+	durable  bool  // Sync writes to disk before reporting them done
+	usage    int64 // Bytes stored, counted when the store is opened and kept up to date by Store and Delete
+	logger   *logrus.Logger
+	mu       sync.RWMutex
+}
+
+// NewFileStorage creates a new filesystem storage rooted at basePath using the default layout
+func NewFileStorage(basePath string, logger *logrus.Logger) (*FileStorage, error) {
+	return NewFileStorageWithLayout(basePath, DefaultLayout, logger)
+}
+
+// NewFileStorageWithLayout creates a new filesystem storage rooted at basePath that fans keys
+// out into subdirectories as described by layout. Opening an existing store with a different
+// layout fails with ErrLayoutMismatch until it is converted with MigrateLayout.
+func NewFileStorageWithLayout(basePath string, layout Layout, logger *logrus.Logger) (*FileStorage, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := utils.EnsureDir(basePath); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	current, recorded, err := readLayout(basePath)
+	if err != nil {
+		return nil, err
+	}
+	if recorded && !current.equal(layout) {
+		return nil, fmt.Errorf("%w: %s uses %s but %s is configured; migrate the store first",
+			ErrLayoutMismatch, basePath, current, layout)
+	}
+	if err := writeLayout(basePath, layout); err != nil {
+		return nil, err
+	}
+
+	// Writes cut short by a crash leave only their temporary files behind
+	removed, err := removeTempFiles(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove interrupted writes: %w", err)
+	}
+	if removed > 0 {
+		logger.WithField("files", removed).Warn("Removed files of interrupted writes")
+	}
+
+	// Usage is counted once here rather than walking the store on every request
+	usage, err := diskUsage(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStorage{
+		basePath: basePath,
+		layout:   layout,
+		usage:    usage,
+		logger:   logger,
+	}, nil
+}
+
+// SetDurable makes Store sync each file and its directory to disk before returning,
+// so stored chunks survive a power loss at the cost of slower writes
+func (fs *FileStorage) SetDurable(durable bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.durable = durable
+}
+
+// path returns where key is stored, refusing keys that are not IDs or that would
+// resolve outside the store
+func (fs *FileStorage) path(key string) (string, error) {
+	if !utils.ValidateFileID(key) {
+		return "", fmt.Errorf("invalid storage key: %s", key)
+	}
+	return fs.layout.path(fs.basePath, key)
+}
+
+// Store writes data under the given key
+func (fs *FileStorage) Store(ctx context.Context, key string, data []byte) error {
+	path, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := utils.EnsureDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Overwriting a key replaces the bytes counted for it
+	var previous int64
+	if info, err := os.Stat(path); err == nil {
+		previous = info.Size()
+	}
+
+	// Readers see either the previous content or all of data, never part of it
+	if err := writeFileAtomic(path, bytes.NewReader(data), fs.durable); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	fs.usage += int64(len(data)) - previous
+
+	fs.logger.WithFields(logrus.Fields{
+		"key":  key,
+		"size": len(data),
+	}).Debug("Stored data")
+
+	return nil
+}
+
+// Retrieve reads the data stored under the given key
+func (fs *FileStorage) Retrieve(ctx context.Context, key string) ([]byte, error) {
+	path, err := fs.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	return data, nil
+}
+
+// Delete removes the data stored under the given key
+func (fs *FileStorage) Delete(ctx context.Context, key string) error {
+	path, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	fs.usage -= info.Size()
+
+	return nil
+}
+
+// Exists checks whether data is stored under the given key
+func (fs *FileStorage) Exists(key string) bool {
+	path, err := fs.path(key)
+	if err != nil {
+		return false
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return utils.FileExists(path)
+}
+
+// List returns all stored keys
+func (fs *FileStorage) List() ([]string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	var keys []string
+	err := filepath.Walk(fs.basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && utils.ValidateFileID(info.Name()) {
+			keys = append(keys, info.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+
+	return keys, nil
+}
+
+// ListPrefix returns the stored keys starting with prefix, in order. Only the
+// subdirectories of the layout that can hold such keys are read.
+func (fs *FileStorage) ListPrefix(prefix string) ([]string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	var keys []string
+	_, err := fs.scan(fs.basePath, 0, "", func(dirKey string) bool {
+		return strings.HasPrefix(dirKey, prefix) || strings.HasPrefix(prefix, dirKey)
+	}, func(key string) bool {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	return keys, nil
+}
+
+// ListPage returns up to limit stored keys sorting after the given key, in order. An
+// empty after starts from the first key, and the last key returned continues the scan.
+func (fs *FileStorage) ListPage(after string, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid page limit: %d", limit)
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	var keys []string
+	_, err := fs.scan(fs.basePath, 0, "", func(dirKey string) bool {
+		// Skip subdirectories whose keys all sort at or before after
+		n := len(dirKey)
+		if n > len(after) {
+			n = len(after)
+		}
+		return dirKey >= after[:n]
+	}, func(key string) bool {
+		if key > after {
+			keys = append(keys, key)
+		}
+		return len(keys) < limit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	return keys, nil
+}
+
+// scan walks the layout below dir in key order. The subdirectories of each level hold
+// the keys starting with their name appended to dirKey; enter decides from that prefix
+// whether to descend into one. visit is called with each key found and stops the scan
+// by returning false, which scan passes on as its own result.
+func (fs *FileStorage) scan(dir string, level int, dirKey string, enter func(dirKey string) bool, visit func(key string) bool) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	// os.ReadDir sorts by name, and subdirectory names are key prefixes, so keys come
+	// out in order
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if utils.ValidateFileID(entry.Name()) && !visit(entry.Name()) {
+				return false, nil
+			}
+			continue
+		}
+		if level >= fs.layout.Depth || !enter(dirKey+entry.Name()) {
+			continue
+		}
+		if more, err := fs.scan(filepath.Join(dir, entry.Name()), level+1, dirKey+entry.Name(), enter, visit); !more || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// GetUsage returns the total number of bytes stored. The count is kept as keys are
+// stored and deleted, so files changed behind the store's back are only picked up when
+// it is reopened.
+func (fs *FileStorage) GetUsage() (int64, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.usage, nil
+}
+
+// diskUsage returns the bytes taken by the files under basePath, other than the layout record
+func diskUsage(basePath string) (int64, error) {
+	var usage int64
+	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && path != filepath.Join(basePath, layoutFile) {
+			usage += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate usage: %w", err)
+	}
+
+	return usage, nil
+}
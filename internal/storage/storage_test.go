@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+func TestFileStorage(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fs, err := NewFileStorage(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	key := types.CalculateHash([]byte("key"))
+	data := []byte("stored data")
+
+	if err := fs.Store(context.Background(), key, data); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+
+	if !fs.Exists(key) {
+		t.Errorf("Expected key to exist")
+	}
+
+	retrieved, err := fs.Retrieve(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Failed to retrieve: %v", err)
+	}
+	if string(retrieved) != string(data) {
+		t.Errorf("Expected %s, got %s", data, retrieved)
+	}
+
+	keys, err := fs.List()
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != key {
+		t.Errorf("Expected [%s], got %v", key, keys)
+	}
+
+	usage, err := fs.GetUsage()
+	if err != nil {
+		t.Fatalf("Failed to get usage: %v", err)
+	}
+	if usage != int64(len(data)) {
+		t.Errorf("Expected usage %d, got %d", len(data), usage)
+	}
+
+	if err := fs.Delete(context.Background(), key); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	if _, err := fs.Retrieve(context.Background(), key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+
+	// Keys must be valid IDs
+	if err := fs.Store(context.Background(), "../escape", data); err == nil {
+		t.Errorf("Expected error for invalid key")
+	}
+}
+
+func TestFileStorageInterruptedWrite(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	dir := t.TempDir()
+	fs, err := NewFileStorage(dir, logger)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	fs.SetDurable(true)
+
+	key := types.CalculateHash([]byte("key"))
+	if err := fs.Store(context.Background(), key, []byte("previous content")); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+
+	// A write failing halfway leaves the previous content and no temporary file
+	path, _ := fs.path(key)
+	interrupted := io.MultiReader(bytes.NewReader([]byte("partial")), iotest.ErrReader(errors.New("disk unplugged")))
+	if err := writeFileAtomic(path, interrupted, true); err == nil {
+		t.Fatalf("Expected interrupted write to fail")
+	}
+	if data, err := fs.Retrieve(context.Background(), key); err != nil || string(data) != "previous content" {
+		t.Errorf("Expected previous content after interrupted write, got %q (%v)", data, err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), tempPrefix+"*")); len(leftovers) != 0 {
+		t.Errorf("Expected no temporary files after a failed write, got %v", leftovers)
+	}
+
+	// A crash mid-write leaves only a temporary file, which is never read as a chunk
+	crashed := types.CalculateHash([]byte("crashed"))
+	crashedPath, _ := fs.path(crashed)
+	if err := os.MkdirAll(filepath.Dir(crashedPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	leftover := filepath.Join(filepath.Dir(crashedPath), tempPrefix+crashed+"-12345")
+	if err := os.WriteFile(leftover, []byte("trunc"), 0600); err != nil {
+		t.Fatalf("Failed to write leftover: %v", err)
+	}
+
+	if fs.Exists(crashed) {
+		t.Errorf("Expected partially written key not to exist")
+	}
+	if _, err := fs.Retrieve(context.Background(), crashed); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for partially written key, got %v", err)
+	}
+	if keys, _ := fs.List(); len(keys) != 1 || keys[0] != key {
+		t.Errorf("Expected [%s], got %v", key, keys)
+	}
+
+	// Reopening the store cleans up after the crash
+	if _, err := NewFileStorage(dir, logger); err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("Expected leftover temporary file to be removed on open, got %v", err)
+	}
+}
+
+func TestFileStorageListPrefix(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for _, layout := range []Layout{{Depth: 0}, DefaultLayout, {Depth: 2, Width: 1}, {Depth: 3, Width: 2}} {
+		fs, err := NewFileStorageWithLayout(t.TempDir(), layout, logger)
+		if err != nil {
+			t.Fatalf("%s: failed to create storage: %v", layout, err)
+		}
+		keys := storeTestKeys(t, fs, 300)
+		checkListing(t, layout.String(), fs, keys)
+	}
+}
+
+// storeTestKeys stores n distinct keys and returns them sorted
+func storeTestKeys(t *testing.T, store Storage, n int) []string {
+	t.Helper()
+
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = types.CalculateHash([]byte("key " + strconv.Itoa(i)))
+		if err := store.Store(context.Background(), keys[i], []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("Failed to store: %v", err)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkListing checks ListPrefix and ListPage of store against its sorted keys
+func checkListing(t *testing.T, name string, store Storage, keys []string) {
+	t.Helper()
+
+	for _, prefix := range []string{"", "a", "0f", keys[0][:1], keys[len(keys)/2][:3], keys[len(keys)-1], "zz"} {
+		var want []string
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				want = append(want, key)
+			}
+		}
+
+		got, err := store.ListPrefix(prefix)
+		if err != nil {
+			t.Fatalf("%s: failed to list prefix %q: %v", name, prefix, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: prefix %q: expected %d keys, got %d: %v", name, prefix, len(want), len(got), got)
+		}
+	}
+
+	var paged []string
+	after := ""
+	for pages := 0; ; pages++ {
+		if pages > len(keys) {
+			t.Fatalf("%s: paging did not finish", name)
+		}
+		page, err := store.ListPage(after, 7)
+		if err != nil {
+			t.Fatalf("%s: failed to list page after %q: %v", name, after, err)
+		}
+		if len(page) > 7 {
+			t.Fatalf("%s: expected at most 7 keys per page, got %d", name, len(page))
+		}
+		paged = append(paged, page...)
+		if len(page) < 7 {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if !reflect.DeepEqual(paged, keys) {
+		t.Errorf("%s: expected paging to return all %d keys in order, got %d", name, len(keys), len(paged))
+	}
+
+	// Cursors need not be stored keys
+	middle := keys[len(keys)/2]
+	cursor := middle[:10]
+	page, err := store.ListPage(cursor, 3)
+	if err != nil {
+		t.Fatalf("%s: failed to list page after %q: %v", name, cursor, err)
+	}
+	first := sort.SearchStrings(keys, cursor)
+	if !reflect.DeepEqual(page, keys[first:first+3]) {
+		t.Errorf("%s: expected %v after %q, got %v", name, keys[first:first+3], cursor, page)
+	}
+
+	if _, err := store.ListPage("", 0); err == nil {
+		t.Errorf("%s: expected error for page limit 0", name)
+	}
+}
+
+func TestFileStorageUsageCounter(t *testing.T) {
+	basePath := t.TempDir()
+	fs, err := NewFileStorage(basePath, logrus.New())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	first, second := types.CalculateHash([]byte("first")), types.CalculateHash([]byte("second"))
+	fs.Store(context.Background(), first, make([]byte, 10))
+	fs.Store(context.Background(), second, make([]byte, 20))
+	fs.Store(context.Background(), first, make([]byte, 4))
+	fs.Delete(context.Background(), second)
+	fs.Delete(context.Background(), second)
+
+	if usage, _ := fs.GetUsage(); usage != 4 {
+		t.Errorf("Expected usage 4 after overwrite and delete, got %d", usage)
+	}
+
+	// Reopening the store counts what is on disk
+	reopened, err := NewFileStorage(basePath, logrus.New())
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	if usage, _ := reopened.GetUsage(); usage != 4 {
+		t.Errorf("Expected usage 4 after reopening, got %d", usage)
+	}
+}
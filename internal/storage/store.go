@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// errStoreCancelled stops reading a file once one of its chunks has failed to store
+var errStoreCancelled = errors.New("chunk store cancelled")
+
+// SetStoreWorkers sets how many chunks of a file are encrypted and stored concurrently.
+// Each worker holds one chunk in memory while it is stored.
+func (cm *ChunkManager) SetStoreWorkers(workers int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.storeWorkers = workers
+}
+
+// storeJob is a chunk waiting for a store worker
+type storeJob struct {
+	index int
+	chunk []byte
+}
+
+// storeChunksParallel reads r in chunks, hashing the file in order with hasher, and
+// stores the chunks with a bounded pool of workers. It returns the chunks in index order
+// and the file size. Once a chunk fails no further chunks are started, and the chunks
+// already stored are released.
+func (cm *ChunkManager) storeChunksParallel(ctx context.Context, address chunkHeader, r io.Reader, chunkSize int, algorithm types.HashAlgorithm, hasher *fileHasher, workers int) ([]types.ChunkInfo, int64, error) {
+	stored := make(map[int]types.ChunkInfo)
+	var storeErr error
+	failed := make(chan struct{})
+	var mu sync.Mutex
+
+	jobs := make(chan storeJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case <-failed:
+					continue // Drain jobs handed out before the failure was seen
+				default:
+				}
+
+				chunkInfo, err := cm.storeChunk(ctx, address, job.index, job.chunk, algorithm)
+
+				mu.Lock()
+				if err == nil {
+					stored[job.index] = chunkInfo
+				} else if storeErr == nil {
+					storeErr = err
+					close(failed)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var size int64
+	count := 0
+	readErr := utils.SplitReader(r, chunkSize, func(index int, chunk []byte) error {
+		hasher.Write(chunk)
+		size += int64(len(chunk))
+
+		// The reader reuses its buffer, so each worker gets its own copy
+		job := storeJob{index: index, chunk: append([]byte(nil), chunk...)}
+		select {
+		case jobs <- job:
+			count++
+			return nil
+		case <-failed:
+			return errStoreCancelled
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	chunks := make([]types.ChunkInfo, 0, len(stored))
+	for index := 0; index < count; index++ {
+		if chunkInfo, ok := stored[index]; ok {
+			chunks = append(chunks, chunkInfo)
+		}
+	}
+
+	if storeErr != nil || readErr != nil {
+		cm.deleteChunks(chunks)
+		if storeErr != nil {
+			return nil, 0, storeErr
+		}
+		return nil, 0, readErr
+	}
+	return chunks, size, nil
+}
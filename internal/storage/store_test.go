@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+)
+
+// failingStore fails to store the chunk written as the failAt'th call
+type failingStore struct {
+	*FileStorage
+	calls  int32
+	failAt int32
+}
+
+func (f *failingStore) Store(ctx context.Context, key string, data []byte) error {
+	if atomic.AddInt32(&f.calls, 1) == f.failAt {
+		return errors.New("disk full")
+	}
+	return f.FileStorage.Store(ctx, key, data)
+}
+
+func TestStoreFileParallel(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdefghijklmnopqrstuvwxyz"), 10)
+
+	var layouts []*types.FileInfo
+	for _, workers := range []int{1, 4} {
+		cm, _ := newTestChunkManager(t, 7)
+		cm.SetStoreWorkers(workers)
+
+		fileInfo := &types.FileInfo{ID: types.GenerateFileID("parallel.txt", data), Name: "parallel.txt"}
+		if err := cm.StoreFile(context.Background(), fileInfo, data); err != nil {
+			t.Fatalf("%d workers: Failed to store file: %v", workers, err)
+		}
+
+		retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+		if err != nil {
+			t.Fatalf("%d workers: Failed to retrieve file: %v", workers, err)
+		}
+		if !bytes.Equal(retrieved, data) {
+			t.Errorf("%d workers: Expected the stored data back", workers)
+		}
+		layouts = append(layouts, fileInfo)
+	}
+
+	sequential, parallel := layouts[0], layouts[1]
+	if parallel.Hash != sequential.Hash || parallel.MerkleRoot != sequential.MerkleRoot || parallel.Size != sequential.Size {
+		t.Errorf("Expected the same hash, root and size, got %+v and %+v", sequential, parallel)
+	}
+	if len(parallel.Chunks) != len(sequential.Chunks) {
+		t.Fatalf("Expected %d chunks, got %d", len(sequential.Chunks), len(parallel.Chunks))
+	}
+	for i, chunkInfo := range parallel.Chunks {
+		want := sequential.Chunks[i]
+		if chunkInfo.Index != i || chunkInfo.ID != want.ID || chunkInfo.Hash != want.Hash || chunkInfo.Size != want.Size {
+			t.Errorf("Chunk %d: expected %+v, got %+v", i, want, chunkInfo)
+		}
+	}
+}
+
+func TestStoreFileParallelFailure(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	failing := &failingStore{FileStorage: fileStorage, failAt: 5}
+	cm.storage = failing
+	cm.SetStoreWorkers(3)
+
+	data := []byte("a file long enough for many chunks")
+	fileInfo := &types.FileInfo{ID: types.GenerateFileID("broken.txt", data), Name: "broken.txt"}
+	if err := cm.StoreFile(context.Background(), fileInfo, data); err == nil {
+		t.Fatal("Expected the failed chunk to fail the store")
+	}
+
+	if fileInfo.Chunks != nil {
+		t.Errorf("Expected no chunks recorded on the file, got %d", len(fileInfo.Chunks))
+	}
+	if keys, _ := fileStorage.List(); len(keys) != 0 {
+		t.Errorf("Expected partial writes to be cleaned up, got %d stored chunks", len(keys))
+	}
+	if calls := atomic.LoadInt32(&failing.calls); int(calls) >= (len(data)+3)/4 {
+		t.Errorf("Expected the batch to stop early, got %d store calls", calls)
+	}
+}
@@ -0,0 +1,502 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/utils"
+)
+
+// uploadBucket is the metadata bucket holding resumable upload sessions, keyed by upload ID
+const uploadBucket = "uploads"
+
+// Errors returned by the upload manager
+var (
+	ErrUploadNotFound   = errors.New("upload session not found")
+	ErrUploadIncomplete = errors.New("upload is missing chunks")
+	ErrInvalidChunk     = errors.New("invalid upload chunk")
+	ErrStagingFull      = errors.New("upload staging space exhausted")
+)
+
+// UploadSession tracks the progress of a resumable upload
+type UploadSession struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	ContentType string     `json:"content_type"`
+	Owner       string     `json:"owner"`
+	Public      bool       `json:"public"`
+	Size        int64      `json:"size"`
+	ChunkSize   int        `json:"chunk_size"`
+	Received    []int      `json:"received"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Nil when sessions never expire
+}
+
+// expired reports whether the session's TTL has passed as of now
+func (s *UploadSession) expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// ChunkCount returns the number of chunks the upload is split into
+func (s *UploadSession) ChunkCount() int {
+	return int((s.Size + int64(s.ChunkSize) - 1) / int64(s.ChunkSize))
+}
+
+// Missing returns the chunk indices that have not been received yet
+func (s *UploadSession) Missing() []int {
+	received := make(map[int]bool, len(s.Received))
+	for _, index := range s.Received {
+		received[index] = true
+	}
+
+	missing := []int{}
+	for index := 0; index < s.ChunkCount(); index++ {
+		if !received[index] {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
+// chunkLength returns the expected length of the chunk at index
+func (s *UploadSession) chunkLength(index int) int64 {
+	offset := int64(index) * int64(s.ChunkSize)
+	if remaining := s.Size - offset; remaining < int64(s.ChunkSize) {
+		return remaining
+	}
+	return int64(s.ChunkSize)
+}
+
+// UploadManager stages the chunks of resumable uploads until they are complete and
+// then stores the assembled file through the chunk manager. Each session has its own
+// lock, so staging or completing one upload never waits on another.
+type UploadManager struct {
+	store        metadata.Store
+	chunkManager *ChunkManager
+	idMode       types.FileIDMode
+	ttl          time.Duration
+	maxStaged    int64
+	staged       int64 // Bytes declared by open sessions
+	stagedLoaded bool  // Whether staged has been counted from the stored sessions
+	locks        map[string]*sessionLock
+	mu           sync.Mutex // Guards the fields above, never held across I/O on chunks
+}
+
+// sessionLock is the lock of one upload session, counting the holders and waiters still using it
+type sessionLock struct {
+	sync.Mutex
+	users int
+}
+
+// NewUploadManager creates an upload manager persisting sessions in the metadata store
+func NewUploadManager(store metadata.Store, chunkManager *ChunkManager) *UploadManager {
+	return &UploadManager{
+		store:        store,
+		chunkManager: chunkManager,
+		idMode:       types.DefaultFileIDMode,
+	}
+}
+
+// SetFileIDMode sets how completed uploads are identified
+func (um *UploadManager) SetFileIDMode(mode types.FileIDMode) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	um.idMode = mode
+}
+
+// SetSessionTTL sets how long new sessions stay open before Sweep discards them, 0 for no limit
+func (um *UploadManager) SetSessionTTL(ttl time.Duration) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	um.ttl = ttl
+}
+
+// SetMaxStaged caps the bytes open sessions may declare together, 0 for unlimited
+func (um *UploadManager) SetMaxStaged(limit int64) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	um.maxStaged = limit
+}
+
+// CreateSession starts a new upload of size bytes, received and stored in chunks of
+// chunkSize bytes, or of the chunk manager's size when chunkSize is 0. It returns
+// ErrStagingFull if the open sessions would stage more than the configured limit.
+func (um *UploadManager) CreateSession(name, contentType, owner string, size int64, public bool, chunkSize int) (*UploadSession, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid upload size: %d", size)
+	}
+	chunkSize, err := um.chunkManager.fileChunkSize(&types.FileInfo{ChunkSize: chunkSize})
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := utils.GenerateRandomID(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+
+	session := &UploadSession{
+		ID:          id,
+		Name:        name,
+		ContentType: contentType,
+		Owner:       owner,
+		Public:      public,
+		Size:        size,
+		ChunkSize:   chunkSize,
+		Received:    []int{},
+		CreatedAt:   time.Now(),
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.ttl > 0 {
+		expiresAt := session.CreatedAt.Add(um.ttl)
+		session.ExpiresAt = &expiresAt
+	}
+
+	// The whole declared size is counted up front so staging can never outgrow the limit
+	staged, err := um.stagedBytes()
+	if err != nil {
+		return nil, err
+	}
+	if um.maxStaged > 0 && staged+size > um.maxStaged {
+		return nil, fmt.Errorf("%w: %d of %d bytes staged", ErrStagingFull, staged, um.maxStaged)
+	}
+
+	if err := um.store.Put(uploadBucket, id, session); err != nil {
+		return nil, fmt.Errorf("failed to save upload session: %w", err)
+	}
+	um.staged += size
+	return session, nil
+}
+
+// GetSession returns the upload session with the given ID. Expired sessions are
+// reported as not found.
+func (um *UploadManager) GetSession(id string) (*UploadSession, error) {
+	return um.getSession(id)
+}
+
+// PutChunk stages the chunk at index. Re-sending a chunk replaces the staged copy.
+func (um *UploadManager) PutChunk(ctx context.Context, id string, index int, data []byte) error {
+	unlock := um.lockSession(id)
+	defer unlock()
+
+	session, err := um.getSession(id)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= session.ChunkCount() {
+		return fmt.Errorf("%w: index %d out of range", ErrInvalidChunk, index)
+	}
+	if expected := session.chunkLength(index); int64(len(data)) != expected {
+		return fmt.Errorf("%w: chunk %d has %d bytes, expected %d", ErrInvalidChunk, index, len(data), expected)
+	}
+
+	version, key := um.chunkManager.currentKey(stagingKeyID(id))
+	encrypted, err := crypto.EncryptWithNonces(um.chunkManager.chunkCipher(), data, key, um.chunkManager.keyRing().Nonces(version))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt chunk: %w", err)
+	}
+
+	if err := um.chunkManager.storage.Store(ctx, stagingKey(id, index), encrypted); err != nil {
+		return fmt.Errorf("failed to stage chunk: %w", err)
+	}
+
+	pos := sort.SearchInts(session.Received, index)
+	if pos < len(session.Received) && session.Received[pos] == index {
+		return nil
+	}
+	session.Received = append(session.Received, 0)
+	copy(session.Received[pos+1:], session.Received[pos:])
+	session.Received[pos] = index
+
+	if err := um.store.Put(uploadBucket, id, session); err != nil {
+		return fmt.Errorf("failed to save upload session: %w", err)
+	}
+	return nil
+}
+
+// Complete assembles the staged chunks into a stored file and removes the session.
+// It returns ErrUploadIncomplete if any chunk has not been received.
+func (um *UploadManager) Complete(ctx context.Context, id string) (*types.FileInfo, error) {
+	unlock := um.lockSession(id)
+	defer unlock()
+
+	session, err := um.getSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if missing := session.Missing(); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrUploadIncomplete, missing)
+	}
+
+	um.mu.Lock()
+	idMode := um.idMode
+	um.mu.Unlock()
+
+	ids := um.chunkManager.IDGenerator()
+	fileID, contentID, err := types.GenerateFileIDsWith(ids, idMode, session.Name, um.stagedReader(ctx, session))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged chunks: %w", err)
+	}
+
+	fileInfo := &types.FileInfo{
+		ID:          fileID,
+		Name:        session.Name,
+		ContentType: session.ContentType,
+		Owner:       session.Owner,
+		Public:      session.Public,
+		IDMode:      idMode,
+		ContentID:   contentID,
+		IDScheme:    ids.Scheme(),
+		ChunkSize:   session.ChunkSize,
+	}
+
+	if err := um.chunkManager.StoreFileStream(ctx, fileInfo, um.stagedReader(ctx, session)); err != nil {
+		return nil, err
+	}
+
+	um.removeSession(session)
+	return fileInfo, nil
+}
+
+// Abort discards an upload session and its staged chunks, returning the session removed
+func (um *UploadManager) Abort(id string) (*UploadSession, error) {
+	unlock := um.lockSession(id)
+	defer unlock()
+
+	session, err := um.getSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	um.removeSession(session)
+	return session, nil
+}
+
+// Sweep discards the sessions whose TTL has passed as of now along with their staged
+// chunks, returning the sessions removed
+func (um *UploadManager) Sweep(now time.Time) ([]*UploadSession, error) {
+	ids, err := um.store.Keys(uploadBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var swept []*UploadSession
+	for _, id := range ids {
+		session, err := um.sweepSession(id, now)
+		if err != nil {
+			return swept, err
+		}
+		if session != nil {
+			swept = append(swept, session)
+		}
+	}
+	return swept, nil
+}
+
+// sweepSession removes the session with the given ID if it has expired as of now,
+// returning it, or nil if it is still open or already gone
+func (um *UploadManager) sweepSession(id string, now time.Time) (*UploadSession, error) {
+	unlock := um.lockSession(id)
+	defer unlock()
+
+	session, err := um.loadSession(id)
+	if errors.Is(err, ErrUploadNotFound) || (err == nil && !session.expired(now)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	um.removeSession(session)
+	return session, nil
+}
+
+// StagedKeys returns the storage keys of every chunk staged by an unfinished upload.
+// Expired sessions keep their chunks until Sweep removes them.
+func (um *UploadManager) StagedKeys() ([]string, error) {
+	ids, err := um.store.Keys(uploadBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, id := range ids {
+		session, err := um.loadSession(id)
+		if errors.Is(err, ErrUploadNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range session.Received {
+			keys = append(keys, stagingKey(session.ID, index))
+		}
+	}
+	return keys, nil
+}
+
+// getSession loads a session from the metadata store, reporting expired sessions as not found
+func (um *UploadManager) getSession(id string) (*UploadSession, error) {
+	session, err := um.loadSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.expired(time.Now()) {
+		return nil, ErrUploadNotFound
+	}
+	return session, nil
+}
+
+// loadSession loads a session from the metadata store whether or not it has expired
+func (um *UploadManager) loadSession(id string) (*UploadSession, error) {
+	var session UploadSession
+	if err := um.store.Get(uploadBucket, id, &session); err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to load upload session: %w", err)
+	}
+	return &session, nil
+}
+
+// removeSession deletes a session's staged chunks and metadata, logging failures.
+// The caller must hold the session's lock.
+func (um *UploadManager) removeSession(session *UploadSession) {
+	for _, index := range session.Received {
+		if err := um.chunkManager.storage.Delete(context.Background(), stagingKey(session.ID, index)); err != nil && !errors.Is(err, ErrNotFound) {
+			um.chunkManager.logger.WithError(err).WithField("upload_id", session.ID).Warn("Failed to delete staged chunk")
+		}
+	}
+
+	// The staged count is updated with the session so a first count cannot see both
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	if err := um.store.Delete(uploadBucket, session.ID); err != nil && !errors.Is(err, metadata.ErrNotFound) {
+		um.chunkManager.logger.WithError(err).WithField("upload_id", session.ID).Warn("Failed to delete upload session")
+		return
+	}
+	if um.stagedLoaded {
+		um.staged -= session.Size
+	}
+}
+
+// stagedBytes returns the bytes declared by open sessions, counting them from the
+// metadata store the first time. The caller must hold um.mu.
+func (um *UploadManager) stagedBytes() (int64, error) {
+	if um.stagedLoaded {
+		return um.staged, nil
+	}
+
+	ids, err := um.store.Keys(uploadBucket)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list upload sessions: %w", err)
+	}
+	var staged int64
+	for _, id := range ids {
+		session, err := um.loadSession(id)
+		if errors.Is(err, ErrUploadNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		staged += session.Size
+	}
+
+	um.staged = staged
+	um.stagedLoaded = true
+	return staged, nil
+}
+
+// lockSession locks the session with the given ID, returning the function that unlocks it
+func (um *UploadManager) lockSession(id string) func() {
+	um.mu.Lock()
+	if um.locks == nil {
+		um.locks = make(map[string]*sessionLock)
+	}
+	lock := um.locks[id]
+	if lock == nil {
+		lock = &sessionLock{}
+		um.locks[id] = lock
+	}
+	lock.users++
+	um.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		um.mu.Lock()
+		defer um.mu.Unlock()
+		if lock.users--; lock.users == 0 {
+			delete(um.locks, id)
+		}
+	}
+}
+
+// stagedReader returns a reader over the decrypted staged chunks of a session in order
+func (um *UploadManager) stagedReader(ctx context.Context, session *UploadSession) io.Reader {
+	return &stagedReader{ctx: ctx, um: um, session: session}
+}
+
+// stagingKey returns the storage key of a staged upload chunk
+func stagingKey(id string, index int) string {
+	return types.CalculateHash([]byte(fmt.Sprintf("upload:%s:%d", id, index)))
+}
+
+// stagingKeyID names the subkey staged chunks of an upload session are encrypted with,
+// kept apart from file IDs by its prefix
+func stagingKeyID(id string) string {
+	return "upload:" + id
+}
+
+// stagedReader loads one staged chunk at a time
+type stagedReader struct {
+	ctx     context.Context
+	um      *UploadManager
+	session *UploadSession
+	next    int
+	buf     []byte
+}
+
+// Read implements io.Reader
+func (r *stagedReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.next >= r.session.ChunkCount() {
+			return 0, io.EOF
+		}
+
+		encrypted, err := r.um.chunkManager.storage.Retrieve(r.ctx, stagingKey(r.session.ID, r.next))
+		if err != nil {
+			return 0, fmt.Errorf("failed to load staged chunk %d: %w", r.next, err)
+		}
+
+		data, err := r.um.chunkManager.decryptAnyVersion(stagingKeyID(r.session.ID), encrypted)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt staged chunk %d: %w", r.next, err)
+		}
+
+		r.buf = data
+		r.next++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
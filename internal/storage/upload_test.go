@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nshmdayo/distributed-cloud-storage/internal/crypto"
+	"github.com/nshmdayo/distributed-cloud-storage/internal/metadata"
+)
+
+func TestUploadSessionOutOfOrder(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	um := NewUploadManager(metadata.NewMemoryStore(), cm)
+	data := []byte("resumable upload!")
+
+	session, err := um.CreateSession("resume.txt", "text/plain", "alice", int64(len(data)), false, 0)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if session.ChunkCount() != 5 {
+		t.Fatalf("Expected 5 chunks, got %d", session.ChunkCount())
+	}
+
+	// Send chunks in reverse order, repeating one of them
+	for _, index := range []int{4, 2, 3, 2, 1, 0} {
+		end := (index + 1) * 4
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := um.PutChunk(context.Background(), session.ID, index, data[index*4:end]); err != nil {
+			t.Fatalf("Failed to put chunk %d: %v", index, err)
+		}
+	}
+
+	session, _ = um.GetSession(session.ID)
+	if len(session.Received) != 5 || len(session.Missing()) != 0 {
+		t.Errorf("Expected 5 received and none missing, got %v and %v", session.Received, session.Missing())
+	}
+
+	fileInfo, err := um.Complete(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to complete upload: %v", err)
+	}
+
+	retrieved, err := cm.RetrieveFile(context.Background(), fileInfo)
+	if err != nil {
+		t.Fatalf("Failed to retrieve file: %v", err)
+	}
+	if string(retrieved) != string(data) {
+		t.Errorf("Expected %q, got %q", data, retrieved)
+	}
+
+	// The session and its staged chunks are gone
+	if _, err := um.GetSession(session.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound after completion, got %v", err)
+	}
+	if fileStorage.Exists(stagingKey(session.ID, 0)) {
+		t.Errorf("Expected staged chunk to be removed")
+	}
+}
+
+func TestUploadSessionValidation(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 4)
+	um := NewUploadManager(metadata.NewMemoryStore(), cm)
+
+	session, err := um.CreateSession("partial.txt", "", "alice", 10, false, 0)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		index int
+		data  string
+	}{
+		{"negative index", -1, "abcd"},
+		{"index past end", 3, "ab"},
+		{"short chunk", 0, "abc"},
+		{"long last chunk", 2, "abcd"},
+	}
+
+	for _, test := range tests {
+		if err := um.PutChunk(context.Background(), session.ID, test.index, []byte(test.data)); !errors.Is(err, ErrInvalidChunk) {
+			t.Errorf("%s: expected ErrInvalidChunk, got %v", test.name, err)
+		}
+	}
+
+	um.PutChunk(context.Background(), session.ID, 0, []byte("abcd"))
+	um.PutChunk(context.Background(), session.ID, 2, []byte("ij"))
+
+	if _, err := um.Complete(context.Background(), session.ID); !errors.Is(err, ErrUploadIncomplete) {
+		t.Errorf("Expected ErrUploadIncomplete, got %v", err)
+	}
+
+	// The missing chunk can still be sent afterwards
+	if err := um.PutChunk(context.Background(), session.ID, 1, []byte("efgh")); err != nil {
+		t.Fatalf("Failed to put missing chunk: %v", err)
+	}
+	if _, err := um.Complete(context.Background(), session.ID); err != nil {
+		t.Errorf("Failed to complete after resending: %v", err)
+	}
+
+	if err := um.PutChunk(context.Background(), "unknown", 0, []byte("abcd")); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound, got %v", err)
+	}
+}
+
+func TestUploadSessionSweep(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	um := NewUploadManager(metadata.NewMemoryStore(), cm)
+	um.SetSessionTTL(time.Hour)
+
+	session, err := um.CreateSession("abandoned.txt", "", "alice", 8, false, 0)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if session.ExpiresAt == nil || !session.ExpiresAt.Equal(session.CreatedAt.Add(time.Hour)) {
+		t.Fatalf("Expected session to expire an hour after creation, got %v", session.ExpiresAt)
+	}
+	if err := um.PutChunk(context.Background(), session.ID, 0, []byte("abcd")); err != nil {
+		t.Fatalf("Failed to put chunk: %v", err)
+	}
+
+	swept, err := um.Sweep(session.CreatedAt.Add(time.Minute))
+	if err != nil || len(swept) != 0 {
+		t.Fatalf("Expected nothing swept before the TTL, got %v, %v", swept, err)
+	}
+
+	swept, err = um.Sweep(session.CreatedAt.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to sweep: %v", err)
+	}
+	if len(swept) != 1 || swept[0].ID != session.ID || swept[0].Owner != "alice" || swept[0].Size != 8 {
+		t.Fatalf("Expected the session to be swept, got %v", swept)
+	}
+
+	if _, err := um.GetSession(session.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound after sweeping, got %v", err)
+	}
+	if fileStorage.Exists(stagingKey(session.ID, 0)) {
+		t.Errorf("Expected staged chunk to be removed")
+	}
+	if keys, _ := um.StagedKeys(); len(keys) != 0 {
+		t.Errorf("Expected no staged keys, got %v", keys)
+	}
+}
+
+func TestUploadSessionStagingLimit(t *testing.T) {
+	cm, _ := newTestChunkManager(t, 4)
+	store := metadata.NewMemoryStore()
+	um := NewUploadManager(store, cm)
+	um.SetMaxStaged(10)
+
+	first, err := um.CreateSession("first.txt", "", "alice", 6, false, 0)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := um.CreateSession("second.txt", "", "bob", 5, false, 0); !errors.Is(err, ErrStagingFull) {
+		t.Errorf("Expected ErrStagingFull, got %v", err)
+	}
+
+	// Open sessions left by an earlier run are counted too
+	restarted := NewUploadManager(store, cm)
+	restarted.SetMaxStaged(10)
+	if _, err := restarted.CreateSession("second.txt", "", "bob", 5, false, 0); !errors.Is(err, ErrStagingFull) {
+		t.Errorf("Expected ErrStagingFull after restart, got %v", err)
+	}
+
+	if _, err := um.Abort(first.ID); err != nil {
+		t.Fatalf("Failed to abort: %v", err)
+	}
+	if _, err := um.CreateSession("second.txt", "", "bob", 10, false, 0); err != nil {
+		t.Errorf("Expected the aborted session's space to be freed, got %v", err)
+	}
+}
+
+func TestUploadSessionCipher(t *testing.T) {
+	cm, fileStorage := newTestChunkManager(t, 4)
+	cm.SetCipher(crypto.CipherChaCha20Poly1305)
+	um := NewUploadManager(metadata.NewMemoryStore(), cm)
+	data := []byte("staged chacha")
+
+	session, err := um.CreateSession("staged.txt", "text/plain", "alice", int64(len(data)), false, 0)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	for index := 0; index < session.ChunkCount(); index++ {
+		end := (index + 1) * 4
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := um.PutChunk(context.Background(), session.ID, index, data[index*4:end]); err != nil {
+			t.Fatalf("Failed to put chunk %d: %v", index, err)
+		}
+	}
+
+	// Staged chunks are encrypted with the configured cipher too
+	staged, err := fileStorage.Retrieve(context.Background(), stagingKey(session.ID, 0))
+	if err != nil {
+		t.Fatalf("Failed to read staged chunk: %v", err)
+	}
+	if c := crypto.Cipher(staged[0]); c != crypto.CipherChaCha20Poly1305 {
+		t.Errorf("Expected staged chunk to be sealed with ChaCha20-Poly1305, got %s", c)
+	}
+
+	fileInfo, err := um.Complete(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to complete upload: %v", err)
+	}
+	if retrieved, err := cm.RetrieveFile(context.Background(), fileInfo); err != nil || string(retrieved) != string(data) {
+		t.Errorf("Expected %q, got %q (%v)", data, retrieved, err)
+	}
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FileIDMode selects how the identity of a file's content is derived
+type FileIDMode string
+
+// Supported file ID modes
+const (
+	// FileIDModeName derives the ID from the name and content, so the same bytes
+	// uploaded under two names are stored twice
+	FileIDModeName FileIDMode = "name"
+
+	// FileIDModeDedup additionally records a content-only ID, so every file with
+	// the same bytes shares one stored copy. File IDs still include the name, as each
+	// name needs its own metadata entry.
+	FileIDModeDedup FileIDMode = "dedup"
+)
+
+// DefaultFileIDMode is used when no mode is recorded, which covers all files stored
+// before the mode was configurable
+const DefaultFileIDMode = FileIDModeName
+
+// ParseFileIDMode returns the mode with the given name. An empty name selects the default.
+func ParseFileIDMode(name string) (FileIDMode, error) {
+	switch mode := FileIDMode(strings.ToLower(name)); mode {
+	case "":
+		return DefaultFileIDMode, nil
+	case FileIDModeName, FileIDModeDedup:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported file ID mode: %s", name)
+}
+
+// GenerateContentID generates an ID from a file's content alone
+func GenerateContentID(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// GenerateFileIDs streams a file's content once to generate its content hash ID and, in
+// dedup mode, its content ID. The content ID is empty in name mode.
+func GenerateFileIDs(mode FileIDMode, name string, r io.Reader) (fileID, contentID string, err error) {
+	return GenerateFileIDsWith(ContentHashIDs{}, mode, name, r)
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateFileIDs(t *testing.T) {
+	content := []byte("same bytes")
+
+	tests := []struct {
+		mode        FileIDMode
+		sameContent bool // Whether the two names share a content ID
+	}{
+		{FileIDModeName, false},
+		{FileIDModeDedup, true},
+	}
+
+	for _, tt := range tests {
+		idA, contentA, err := GenerateFileIDs(tt.mode, "a.txt", bytes.NewReader(content))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.mode, err)
+		}
+		idB, contentB, err := GenerateFileIDs(tt.mode, "b.txt", bytes.NewReader(content))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.mode, err)
+		}
+
+		// The file ID always includes the name, in either mode
+		if idA != GenerateFileID("a.txt", content) {
+			t.Errorf("%s: expected file ID to match GenerateFileID", tt.mode)
+		}
+		if idA == idB {
+			t.Errorf("%s: expected different file IDs for different names", tt.mode)
+		}
+
+		if tt.sameContent {
+			if contentA == "" || contentA != contentB {
+				t.Errorf("%s: expected shared content ID, got %q and %q", tt.mode, contentA, contentB)
+			}
+			if contentA != GenerateContentID(content) {
+				t.Errorf("%s: expected content ID to match GenerateContentID", tt.mode)
+			}
+		} else if contentA != "" || contentB != "" {
+			t.Errorf("%s: expected no content IDs, got %q and %q", tt.mode, contentA, contentB)
+		}
+	}
+
+	_, other, _ := GenerateFileIDs(FileIDModeDedup, "a.txt", bytes.NewReader([]byte("other bytes")))
+	if other == GenerateContentID(content) {
+		t.Errorf("Expected different content IDs for different content")
+	}
+}
+
+func TestParseFileIDMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    FileIDMode
+		wantErr bool
+	}{
+		{"", FileIDModeName, false},
+		{"name", FileIDModeName, false},
+		{"Dedup", FileIDModeDedup, false},
+		{"content", "", true},
+		{"random", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFileIDMode(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
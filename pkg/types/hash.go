@@ -0,0 +1,90 @@
+package types
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// HashAlgorithm names the hash function used for file and chunk hashes and IDs
+type HashAlgorithm string
+
+// Supported hash algorithms
+const (
+	HashSHA256  HashAlgorithm = "sha256"
+	HashSHA512  HashAlgorithm = "sha512"
+	HashBLAKE2b HashAlgorithm = "blake2b-256"
+)
+
+// DefaultHashAlgorithm is used when no algorithm is recorded, which covers all
+// data stored before algorithms were configurable
+const DefaultHashAlgorithm = HashSHA256
+
+// HashAlgorithms lists every supported algorithm
+var HashAlgorithms = []HashAlgorithm{HashSHA256, HashSHA512, HashBLAKE2b}
+
+// ParseHashAlgorithm returns the algorithm with the given name. An empty name
+// selects the default.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	if name == "" {
+		return DefaultHashAlgorithm, nil
+	}
+
+	algorithm := HashAlgorithm(strings.ToLower(name))
+	for _, supported := range HashAlgorithms {
+		if algorithm == supported {
+			return algorithm, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported hash algorithm: %s", name)
+}
+
+// New returns a hash computing the algorithm, falling back to the default for an
+// empty or unknown algorithm
+func (a HashAlgorithm) New() hash.Hash {
+	switch a {
+	case HashSHA512:
+		return sha512.New()
+	case HashBLAKE2b:
+		h, _ := blake2b.New256(nil) // Only fails for keys longer than 64 bytes
+		return h
+	default:
+		return sha256.New()
+	}
+}
+
+// CalculateHashWith calculates the hex-encoded hash of data with the given algorithm
+func CalculateHashWith(algorithm HashAlgorithm, data []byte) string {
+	hasher := algorithm.New()
+	hasher.Write(data)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// GenerateFileIDWith generates the ID of a file from its name and content with the
+// given algorithm
+func GenerateFileIDWith(algorithm HashAlgorithm, name string, content []byte) string {
+	hasher := algorithm.New()
+	hasher.Write([]byte(name))
+	hasher.Write(content)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// GenerateChunkIDWith generates the ID of a chunk from its file, position and content
+// with the given algorithm
+func GenerateChunkIDWith(algorithm HashAlgorithm, fileID string, index int, content []byte) string {
+	// The index is fixed-width so that positions 256 apart do not hash alike
+	var position [8]byte
+	binary.BigEndian.PutUint64(position[:], uint64(index))
+
+	hasher := algorithm.New()
+	hasher.Write([]byte(fileID))
+	hasher.Write(position[:])
+	hasher.Write(content)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
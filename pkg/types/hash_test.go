@@ -0,0 +1,83 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestHashAlgorithms(t *testing.T) {
+	data := []byte("abc")
+
+	tests := []struct {
+		algorithm HashAlgorithm
+		length    int
+		abc       string // Published test vector for "abc"
+	}{
+		{HashSHA256, 64, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{HashSHA512, 128, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{HashBLAKE2b, 64, "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"},
+	}
+
+	seen := make(map[string]HashAlgorithm)
+	for _, tt := range tests {
+		hash := CalculateHashWith(tt.algorithm, data)
+		if len(hash) != tt.length {
+			t.Errorf("%s: expected %d hex characters, got %d", tt.algorithm, tt.length, len(hash))
+		}
+		if hash != tt.abc {
+			t.Errorf("%s: expected %s, got %s", tt.algorithm, tt.abc, hash)
+		}
+		if CalculateHashWith(tt.algorithm, data) != hash {
+			t.Errorf("%s: expected stable output", tt.algorithm)
+		}
+		if other, exists := seen[hash]; exists {
+			t.Errorf("%s: same output as %s", tt.algorithm, other)
+		}
+		seen[hash] = tt.algorithm
+
+		fileID := GenerateFileIDWith(tt.algorithm, "a.txt", data)
+		if fileID != GenerateFileIDWith(tt.algorithm, "a.txt", data) {
+			t.Errorf("%s: expected stable file ID", tt.algorithm)
+		}
+		if fileID == GenerateFileIDWith(tt.algorithm, "b.txt", data) {
+			t.Errorf("%s: expected file ID to depend on the name", tt.algorithm)
+		}
+		if GenerateChunkIDWith(tt.algorithm, fileID, 0, data) == GenerateChunkIDWith(tt.algorithm, fileID, 1, data) {
+			t.Errorf("%s: expected chunk ID to depend on the index", tt.algorithm)
+		}
+	}
+
+	// The default keeps the outputs of the original SHA-256 functions
+	if CalculateHash(data) != CalculateHashWith(HashSHA256, data) {
+		t.Errorf("Expected CalculateHash to use SHA-256")
+	}
+	if GenerateFileID("a.txt", data) != GenerateFileIDWith(HashSHA256, "a.txt", data) {
+		t.Errorf("Expected GenerateFileID to use SHA-256")
+	}
+	if CalculateHashWith("", data) != CalculateHash(data) {
+		t.Errorf("Expected an unrecorded algorithm to mean SHA-256")
+	}
+}
+
+func TestParseHashAlgorithm(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    HashAlgorithm
+		wantErr bool
+	}{
+		{"", HashSHA256, false},
+		{"sha256", HashSHA256, false},
+		{"SHA512", HashSHA512, false},
+		{"blake2b-256", HashBLAKE2b, false},
+		{"md5", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHashAlgorithm(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
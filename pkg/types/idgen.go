@@ -0,0 +1,134 @@
+package types
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// IDScheme names how file and chunk IDs are generated
+type IDScheme string
+
+// Supported ID schemes
+const (
+	// IDSchemeContentHash derives IDs from the name and content, so storing the same
+	// file twice yields the same ID
+	IDSchemeContentHash IDScheme = "content-hash"
+
+	// IDSchemeRandom draws IDs at random, for systems that must not reveal anything
+	// about the content through its ID
+	IDSchemeRandom IDScheme = "random"
+)
+
+// DefaultIDScheme is used when no scheme is recorded, which covers all files stored
+// before the scheme was configurable
+const DefaultIDScheme = IDSchemeContentHash
+
+// ParseIDScheme returns the scheme with the given name. An empty name selects the default.
+func ParseIDScheme(name string) (IDScheme, error) {
+	switch scheme := IDScheme(strings.ToLower(name)); scheme {
+	case "":
+		return DefaultIDScheme, nil
+	case IDSchemeContentHash, IDSchemeRandom:
+		return scheme, nil
+	}
+	return "", fmt.Errorf("unsupported ID scheme: %s", name)
+}
+
+// IDGenerator generates the IDs of new files and chunks. IDs are recorded where they are
+// used, so files keep resolving whatever generator is configured later.
+type IDGenerator interface {
+	// Scheme returns the scheme recorded on files identified by the generator
+	Scheme() IDScheme
+
+	// FileID returns the ID of a file with the given name. Generators that do not
+	// depend on the content may leave r unread.
+	FileID(name string, r io.Reader) (string, error)
+
+	// ChunkID returns the storage key of chunk index of a file
+	ChunkID(fileID string, index int, chunk []byte) (string, error)
+}
+
+// NewIDGenerator returns the generator for a scheme, falling back to the default for an
+// empty or unknown scheme
+func NewIDGenerator(scheme IDScheme) IDGenerator {
+	if scheme == IDSchemeRandom {
+		return RandomIDs{}
+	}
+	return ContentHashIDs{}
+}
+
+// ContentHashIDs generates SHA-256 IDs from names and content, as GenerateFileID and
+// GenerateChunkID do
+type ContentHashIDs struct{}
+
+// Scheme returns IDSchemeContentHash
+func (ContentHashIDs) Scheme() IDScheme {
+	return IDSchemeContentHash
+}
+
+// FileID hashes the name and content
+func (ContentHashIDs) FileID(name string, r io.Reader) (string, error) {
+	return GenerateFileIDFromReader(name, r)
+}
+
+// ChunkID hashes the file ID, index and chunk content
+func (ContentHashIDs) ChunkID(fileID string, index int, chunk []byte) (string, error) {
+	return GenerateChunkID(fileID, index, chunk), nil
+}
+
+// RandomIDs generates random IDs of the same form as content hash IDs
+type RandomIDs struct{}
+
+// Scheme returns IDSchemeRandom
+func (RandomIDs) Scheme() IDScheme {
+	return IDSchemeRandom
+}
+
+// FileID returns a random ID without reading the content
+func (RandomIDs) FileID(name string, r io.Reader) (string, error) {
+	return randomID()
+}
+
+// ChunkID returns a random ID
+func (RandomIDs) ChunkID(fileID string, index int, chunk []byte) (string, error) {
+	return randomID()
+}
+
+// randomID returns 32 random bytes as 64 hex characters
+func randomID() (string, error) {
+	id := make([]byte, sha256.Size)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate random ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// GenerateFileIDsWith generates a file's ID with ids and, in dedup mode, its content
+// ID, streaming the content once. The content ID is empty in name mode.
+func GenerateFileIDsWith(ids IDGenerator, mode FileIDMode, name string, r io.Reader) (fileID, contentID string, err error) {
+	var content hash.Hash
+	source := r
+	if mode == FileIDModeDedup {
+		content = sha256.New()
+		r = io.TeeReader(source, content)
+	}
+
+	fileID, err = ids.FileID(name, r)
+	if err != nil {
+		return "", "", err
+	}
+	if content == nil {
+		return fileID, "", nil
+	}
+
+	// The generator may not have read the content to the end
+	if _, err := io.Copy(content, source); err != nil {
+		return "", "", err
+	}
+	return fileID, hex.EncodeToString(content.Sum(nil)), nil
+}
@@ -0,0 +1,103 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseIDScheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    IDScheme
+		wantErr bool
+	}{
+		{"", DefaultIDScheme, false},
+		{"content-hash", IDSchemeContentHash, false},
+		{"RANDOM", IDSchemeRandom, false},
+		{"uuid", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseIDScheme(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseIDScheme(%q): expected %q (error %v), got %q (%v)", tt.name, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	content := []byte("identified content")
+
+	tests := []struct {
+		ids           IDGenerator
+		deterministic bool // Whether the same input always yields the same ID
+	}{
+		{NewIDGenerator(IDSchemeContentHash), true},
+		{NewIDGenerator(IDSchemeRandom), false},
+	}
+
+	for _, tt := range tests {
+		scheme := tt.ids.Scheme()
+		seen := make(map[string]bool)
+
+		for i := 0; i < 2; i++ {
+			fileID, err := tt.ids.FileID("file.txt", bytes.NewReader(content))
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", scheme, err)
+			}
+			chunkID, err := tt.ids.ChunkID(fileID, 0, content)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", scheme, err)
+			}
+
+			for _, id := range []string{fileID, chunkID} {
+				if decoded, err := hex.DecodeString(id); err != nil || len(decoded) != 32 {
+					t.Errorf("%s: expected 64 hex characters, got %q", scheme, id)
+				}
+				seen[id] = true
+			}
+		}
+
+		// Two files and two chunks, deduplicated when the IDs are deterministic
+		want := 4
+		if tt.deterministic {
+			want = 2
+		}
+		if len(seen) != want {
+			t.Errorf("%s: expected %d distinct IDs, got %d", scheme, want, len(seen))
+		}
+	}
+
+	// Content hash IDs match the package-level functions
+	ids := ContentHashIDs{}
+	fileID, _ := ids.FileID("file.txt", bytes.NewReader(content))
+	if fileID != GenerateFileID("file.txt", content) {
+		t.Errorf("Expected content hash file ID to match GenerateFileID")
+	}
+	if chunkID, _ := ids.ChunkID(fileID, 3, content); chunkID != GenerateChunkID(fileID, 3, content) {
+		t.Errorf("Expected content hash chunk ID to match GenerateChunkID")
+	}
+}
+
+func TestGenerateFileIDsWithRandom(t *testing.T) {
+	content := []byte("same bytes")
+
+	idA, contentA, err := GenerateFileIDsWith(RandomIDs{}, FileIDModeDedup, "a.txt", bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	idB, contentB, _ := GenerateFileIDsWith(RandomIDs{}, FileIDModeDedup, "a.txt", bytes.NewReader(content))
+
+	if idA == idB {
+		t.Errorf("Expected random file IDs to differ")
+	}
+	// The content ID is still derived from the content, so identical files share a copy
+	if contentA != GenerateContentID(content) || contentA != contentB {
+		t.Errorf("Expected content ID %s, got %q and %q", GenerateContentID(content), contentA, contentB)
+	}
+
+	if _, contentID, _ := GenerateFileIDsWith(RandomIDs{}, FileIDModeName, "a.txt", bytes.NewReader(content)); contentID != "" {
+		t.Errorf("Expected no content ID in name mode, got %q", contentID)
+	}
+}
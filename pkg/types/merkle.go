@@ -0,0 +1,119 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Domain separation prefixes prevent an internal node from being passed off as a leaf
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// ComputeMerkleRoot computes the Merkle root over ordered hex-encoded chunk hashes.
+// A level with an odd number of nodes pairs its last node with itself.
+func ComputeMerkleRoot(chunkHashes []string) (string, error) {
+	if len(chunkHashes) == 0 {
+		return "", nil
+	}
+
+	level, err := merkleLeaves(chunkHashes)
+	if err != nil {
+		return "", err
+	}
+
+	for len(level) > 1 {
+		level = merkleNextLevel(level)
+	}
+
+	return hex.EncodeToString(level[0]), nil
+}
+
+// MerkleProof returns the sibling hashes needed to prove that the chunk at index belongs to the tree
+func MerkleProof(chunkHashes []string, index int) ([]string, error) {
+	if index < 0 || index >= len(chunkHashes) {
+		return nil, fmt.Errorf("invalid chunk index %d for %d chunks", index, len(chunkHashes))
+	}
+
+	level, err := merkleLeaves(chunkHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	var proof []string
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof = append(proof, hex.EncodeToString(level[sibling]))
+
+		level = merkleNextLevel(level)
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// VerifyChunkProof checks that chunkHash at index is part of the Merkle tree with the given root
+func VerifyChunkProof(root, chunkHash string, proof []string, index int) bool {
+	leaf, err := hex.DecodeString(chunkHash)
+	if err != nil {
+		return false
+	}
+	node := merkleHash(merkleLeafPrefix, leaf)
+
+	for _, siblingHex := range proof {
+		sibling, err := hex.DecodeString(siblingHex)
+		if err != nil {
+			return false
+		}
+
+		if index%2 == 0 {
+			node = merkleHash(merkleNodePrefix, node, sibling)
+		} else {
+			node = merkleHash(merkleNodePrefix, sibling, node)
+		}
+		index /= 2
+	}
+
+	return hex.EncodeToString(node) == root
+}
+
+// merkleLeaves hashes the chunk hashes into leaf nodes
+func merkleLeaves(chunkHashes []string) ([][]byte, error) {
+	leaves := make([][]byte, len(chunkHashes))
+	for i, chunkHash := range chunkHashes {
+		raw, err := hex.DecodeString(chunkHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk hash at index %d: %w", i, err)
+		}
+		leaves[i] = merkleHash(merkleLeafPrefix, raw)
+	}
+	return leaves, nil
+}
+
+// merkleNextLevel combines pairs of nodes into their parents
+func merkleNextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		right := level[i]
+		if i+1 < len(level) {
+			right = level[i+1]
+		}
+		next = append(next, merkleHash(merkleNodePrefix, level[i], right))
+	}
+	return next
+}
+
+// merkleHash hashes a prefix byte followed by the given parts
+func merkleHash(prefix byte, parts ...[]byte) []byte {
+	hasher := sha256.New()
+	hasher.Write([]byte{prefix})
+	for _, part := range parts {
+		hasher.Write(part)
+	}
+	return hasher.Sum(nil)
+}
@@ -0,0 +1,96 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testChunkHashes(count int) []string {
+	hashes := make([]string, count)
+	for i := range hashes {
+		hashes[i] = CalculateHash([]byte(fmt.Sprintf("chunk %d", i)))
+	}
+	return hashes
+}
+
+func TestMerkleProof(t *testing.T) {
+	for _, count := range []int{1, 2, 3, 5, 8, 13} {
+		hashes := testChunkHashes(count)
+
+		root, err := ComputeMerkleRoot(hashes)
+		if err != nil {
+			t.Fatalf("Count %d: failed to compute root: %v", count, err)
+		}
+
+		for index := range hashes {
+			proof, err := MerkleProof(hashes, index)
+			if err != nil {
+				t.Fatalf("Count %d: failed to build proof for %d: %v", count, index, err)
+			}
+
+			if !VerifyChunkProof(root, hashes[index], proof, index) {
+				t.Errorf("Count %d: expected valid proof for chunk %d", count, index)
+			}
+		}
+	}
+}
+
+func TestMerkleProofTamperedChunk(t *testing.T) {
+	hashes := testChunkHashes(4)
+	root, _ := ComputeMerkleRoot(hashes)
+	proof, _ := MerkleProof(hashes, 2)
+
+	tampered := CalculateHash([]byte("tampered"))
+	if VerifyChunkProof(root, tampered, proof, 2) {
+		t.Errorf("Expected tampered chunk to fail verification")
+	}
+
+	// A valid chunk at the wrong index should also fail
+	if VerifyChunkProof(root, hashes[2], proof, 3) {
+		t.Errorf("Expected proof at wrong index to fail verification")
+	}
+
+	// Changing any chunk changes the root
+	hashes[1] = tampered
+	if changed, _ := ComputeMerkleRoot(hashes); changed == root {
+		t.Errorf("Expected root to change when a chunk changes")
+	}
+}
+
+func TestMerkleSingleChunk(t *testing.T) {
+	hashes := testChunkHashes(1)
+
+	root, err := ComputeMerkleRoot(hashes)
+	if err != nil {
+		t.Fatalf("Failed to compute root: %v", err)
+	}
+
+	proof, err := MerkleProof(hashes, 0)
+	if err != nil {
+		t.Fatalf("Failed to build proof: %v", err)
+	}
+
+	if len(proof) != 0 {
+		t.Errorf("Expected empty proof for single chunk, got %d entries", len(proof))
+	}
+
+	if !VerifyChunkProof(root, hashes[0], proof, 0) {
+		t.Errorf("Expected single chunk proof to verify")
+	}
+
+	// The root is the leaf hash, not the raw chunk hash
+	if root == hashes[0] {
+		t.Errorf("Expected root to be domain separated from the chunk hash")
+	}
+}
+
+func TestComputeMerkleRootEmpty(t *testing.T) {
+	root, err := ComputeMerkleRoot(nil)
+	if err != nil || root != "" {
+		t.Errorf("Expected empty root for no chunks, got %q (%v)", root, err)
+	}
+
+	if _, err := ComputeMerkleRoot([]string{"not hex"}); err == nil {
+		t.Errorf("Expected error for invalid chunk hash")
+	}
+}
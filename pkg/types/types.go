@@ -4,32 +4,63 @@ package types
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"io"
 	"time"
 )
 
 // FileInfo represents metadata about a stored file
 type FileInfo struct {
-	ID          string      `json:"id"`
-	Name        string      `json:"name"`
-	Size        int64       `json:"size"`
-	Hash        string      `json:"hash"`
-	ContentType string      `json:"content_type"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
-	Owner       string      `json:"owner"`
-	Chunks      []ChunkInfo `json:"chunks"`
-	Replicas    int         `json:"replicas"`
-	IsEncrypted bool        `json:"is_encrypted"`
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Size            int64             `json:"size"`
+	Hash            string            `json:"hash"`
+	HashAlgorithm   HashAlgorithm     `json:"hash_algorithm,omitempty"` // Algorithm of Hash and the chunk hashes; empty means sha256
+	MerkleRoot      string            `json:"merkle_root,omitempty"`    // Root over the ordered chunk hashes
+	ContentType     string            `json:"content_type"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty"` // Set while the file is in the trash
+	ExpiresAt       *time.Time        `json:"expires_at,omitempty"` // Set for files deleted automatically once their TTL passes
+	Owner           string            `json:"owner"`
+	Public          bool              `json:"public"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	Chunks          []ChunkInfo       `json:"chunks"`
+	ChunkSize       int               `json:"chunk_size,omitempty"` // Size the file was split into chunks of
+	Replicas        int               `json:"replicas"`
+	IsEncrypted     bool              `json:"is_encrypted"`
+	KeySalt         string            `json:"key_salt,omitempty"`         // Hex-encoded salt for password-derived keys
+	ClientEncrypted bool              `json:"client_encrypted,omitempty"` // Content was encrypted by the client before upload
+	Erasure         *ErasureInfo      `json:"erasure,omitempty"`
+	AnchorTx        string            `json:"anchor_tx,omitempty"`  // Transaction anchoring the file's root hash on-chain
+	IDMode          FileIDMode        `json:"id_mode,omitempty"`    // How the file was identified; empty means name
+	ContentID       string            `json:"content_id,omitempty"` // Content-only ID shared by identical files in dedup mode
+	IDScheme        IDScheme          `json:"id_scheme,omitempty"`  // How the file and chunk IDs were generated; empty means content-hash
+
+	// Versioning: files re-uploaded under the same name by the same owner form a chain
+	Version         int    `json:"version"`
+	PreviousVersion string `json:"previous_version,omitempty"` // ID of the prior version
+}
+
+// ErasureInfo describes the erasure coding layout of a file.
+// Chunks are grouped into stripes of DataShards+ParityShards shards, in index order.
+type ErasureInfo struct {
+	DataShards   int `json:"data_shards"`
+	ParityShards int `json:"parity_shards"`
 }
 
 // ChunkInfo represents a chunk of a file
 type ChunkInfo struct {
-	ID       string   `json:"id"`
-	Index    int      `json:"index"`
-	Size     int64    `json:"size"`
-	Hash     string   `json:"hash"`
-	NodeIDs  []string `json:"node_ids"`
-	Checksum string   `json:"checksum"`
+	ID         string   `json:"id"`
+	Index      int      `json:"index"`
+	Size       int64    `json:"size"`
+	Hash       string   `json:"hash"`
+	NodeIDs    []string `json:"node_ids"`
+	Checksum   string   `json:"checksum"`
+	Parity     bool     `json:"parity,omitempty"`      // Erasure coding parity shard
+	KeyID      string   `json:"key_id,omitempty"`      // File whose subkey encrypted the chunk; empty for the master key
+	KeyVersion int      `json:"key_version,omitempty"` // Master key version the chunk is encrypted under; 0 for chunks stored before versions were recorded, under version 1
+
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty"` // Algorithm of Hash; empty means sha256
 }
 
 // NodeInfo represents information about a storage node
@@ -37,12 +68,12 @@ type NodeInfo struct {
 	ID           string     `json:"id"`
 	Address      string     `json:"address"`
 	Port         int        `json:"port"`
-	PublicKey    string     `json:"public_key"`
+	PublicKey    string     `json:"public_key,omitempty"` // Key the node announced itself with, if any
 	StorageUsed  int64      `json:"storage_used"`
 	StorageTotal int64      `json:"storage_total"`
 	Status       NodeStatus `json:"status"`
 	LastSeen     time.Time  `json:"last_seen"`
-	Reputation   float64    `json:"reputation"`
+	Reputation   float64    `json:"reputation,omitempty"` // Reliability of a peer as judged by this node, from 0 to 1
 }
 
 // NodeStatus represents the status of a node
@@ -119,24 +150,25 @@ func (m MessageType) String() string {
 
 // GenerateFileID generates a unique ID for a file
 func GenerateFileID(name string, content []byte) string {
+	return GenerateFileIDWith(DefaultHashAlgorithm, name, content)
+}
+
+// GenerateFileIDFromReader generates the same ID as GenerateFileID while streaming the content
+func GenerateFileIDFromReader(name string, r io.Reader) (string, error) {
 	hasher := sha256.New()
 	hasher.Write([]byte(name))
-	hasher.Write(content)
-	return hex.EncodeToString(hasher.Sum(nil))
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 // GenerateChunkID generates a unique ID for a chunk
 func GenerateChunkID(fileID string, index int, content []byte) string {
-	hasher := sha256.New()
-	hasher.Write([]byte(fileID))
-	hasher.Write([]byte{byte(index)})
-	hasher.Write(content)
-	return hex.EncodeToString(hasher.Sum(nil))
+	return GenerateChunkIDWith(DefaultHashAlgorithm, fileID, index, content)
 }
 
 // CalculateHash calculates SHA256 hash of data
 func CalculateHash(data []byte) string {
-	hasher := sha256.New()
-	hasher.Write(data)
-	return hex.EncodeToString(hasher.Sum(nil))
+	return CalculateHashWith(DefaultHashAlgorithm, data)
 }
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"bytes"
 	"testing"
 	"time"
 )
@@ -29,6 +30,21 @@ func TestGenerateFileID(t *testing.T) {
 	}
 }
 
+func TestGenerateFileIDFromReader(t *testing.T) {
+	name := "test.txt"
+	content := []byte("test content")
+
+	id, err := GenerateFileIDFromReader(name, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+
+	// Streaming should produce the same ID as the buffered version
+	if expected := GenerateFileID(name, content); id != expected {
+		t.Errorf("Expected %s, got %s", expected, id)
+	}
+}
+
 func TestGenerateChunkID(t *testing.T) {
 	fileID := "test-file-id"
 	index := 0
@@ -47,6 +63,13 @@ func TestGenerateChunkID(t *testing.T) {
 	if id1 == id3 {
 		t.Errorf("Expected different ID for different index")
 	}
+
+	// Indices that agree in their low byte must still be distinguished
+	for _, pair := range [][2]int{{0, 256}, {1, 257}, {255, 511}, {0, 1 << 32}} {
+		if GenerateChunkID(fileID, pair[0], content) == GenerateChunkID(fileID, pair[1], content) {
+			t.Errorf("Expected different IDs for indices %d and %d", pair[0], pair[1])
+		}
+	}
 }
 
 func TestCalculateHash(t *testing.T) {
@@ -4,18 +4,35 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
 )
 
-// GenerateRandomID generates a random hexadecimal ID
+// ErrUnsafePath is returned by SafeJoin for paths that would resolve outside their base
+var ErrUnsafePath = errors.New("unsafe path")
+
+// ErrChunkSequence is returned by JoinChunksOrdered for chunks whose indexes do not run
+// from 0 without gaps or duplicates, or whose data is missing
+var ErrChunkSequence = errors.New("invalid chunk sequence")
+
+// GenerateRandomID generates a random hexadecimal ID of exactly length characters.
+// Odd lengths are rounded up to whole random bytes and the extra character trimmed.
 func GenerateRandomID(length int) (string, error) {
-	bytes := make([]byte, length/2)
+	if length < 0 {
+		return "", fmt.Errorf("invalid ID length: %d", length)
+	}
+
+	bytes := make([]byte, (length+1)/2)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", err
 	}
-	return hex.EncodeToString(bytes), nil
+	return hex.EncodeToString(bytes)[:length], nil
 }
 
 // EnsureDir ensures that a directory exists, creating it if necessary
@@ -41,7 +58,8 @@ func FileExists(filePath string) bool {
 	return !os.IsNotExist(err)
 }
 
-// SplitData splits data into chunks of specified size
+// SplitData splits data into chunks of specified size. The chunks share the
+// backing array of data.
 func SplitData(data []byte, chunkSize int) [][]byte {
 	if chunkSize <= 0 {
 		return [][]byte{data}
@@ -58,6 +76,34 @@ func SplitData(data []byte, chunkSize int) [][]byte {
 	return chunks
 }
 
+// SplitReader reads r in chunks of chunkSize bytes, calling fn with each chunk in
+// order as it is read. Only the final chunk may be shorter than chunkSize, and an
+// empty reader yields no chunks. The chunk buffer is reused between calls, so fn
+// must copy any data it keeps. An error returned by fn stops the split and is
+// returned unchanged.
+func SplitReader(r io.Reader, chunkSize int, fn func(index int, chunk []byte) error) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size: %d", chunkSize)
+	}
+
+	buf := make([]byte, chunkSize)
+	for index := 0; ; index++ {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read data: %w", err)
+		}
+
+		if n > 0 {
+			if err := fn(index, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
 // JoinChunks combines chunks back into original data
 func JoinChunks(chunks [][]byte) []byte {
 	var result []byte
@@ -67,12 +113,71 @@ func JoinChunks(chunks [][]byte) []byte {
 	return result
 }
 
+// JoinChunksOrdered combines the data of chunks, keyed by chunk ID, back into original
+// data in order of chunk index rather than slice order, so chunks fetched concurrently
+// need not be sorted first. The indexes must run from 0 without gaps or duplicates.
+func JoinChunksOrdered(chunks []types.ChunkInfo, data map[string][]byte) ([]byte, error) {
+	byIndex := make(map[int][]byte, len(chunks))
+	for _, chunk := range chunks {
+		if _, exists := byIndex[chunk.Index]; exists {
+			return nil, fmt.Errorf("%w: duplicate chunk index %d", ErrChunkSequence, chunk.Index)
+		}
+		chunkData, ok := data[chunk.ID]
+		if !ok {
+			return nil, fmt.Errorf("%w: no data for chunk %d", ErrChunkSequence, chunk.Index)
+		}
+		byIndex[chunk.Index] = chunkData
+	}
+
+	ordered := make([][]byte, len(chunks))
+	for index := range ordered {
+		chunkData, ok := byIndex[index]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing chunk index %d", ErrChunkSequence, index)
+		}
+		ordered[index] = chunkData
+	}
+	return JoinChunks(ordered), nil
+}
+
 // GetStoragePath returns the full path for storing a file
 func GetStoragePath(baseDir, fileID string) string {
 	// Create subdirectories based on first 2 characters of file ID
 	// to avoid too many files in a single directory
-	subDir := fileID[:2]
-	return filepath.Join(baseDir, subDir, fileID)
+	return GetStoragePathLayout(baseDir, fileID, 1, 2)
+}
+
+// GetStoragePathLayout returns the full path for storing a file under depth levels of
+// subdirectories, each named by the next width characters of the file ID.
+// For example depth 2 and width 2 store "abcdef..." as baseDir/ab/cd/abcdef...
+func GetStoragePathLayout(baseDir, fileID string, depth, width int) string {
+	parts := []string{baseDir}
+	for level := 0; level < depth && (level+1)*width <= len(fileID); level++ {
+		parts = append(parts, fileID[level*width:(level+1)*width])
+	}
+	return filepath.Join(append(parts, fileID)...)
+}
+
+// SafeJoin joins the untrusted relative path onto base, refusing paths that are
+// absolute, contain null bytes or, once cleaned, escape base through "..". Callers
+// must still not follow symlinks placed below base by someone else.
+func SafeJoin(base, untrusted string) (string, error) {
+	if untrusted == "" {
+		return "", fmt.Errorf("%w: empty path", ErrUnsafePath)
+	}
+	if strings.ContainsRune(untrusted, 0) {
+		return "", fmt.Errorf("%w: %q contains a null byte", ErrUnsafePath, untrusted)
+	}
+	if filepath.IsAbs(untrusted) || filepath.VolumeName(untrusted) != "" || strings.HasPrefix(untrusted, "/") {
+		return "", fmt.Errorf("%w: %q is absolute", ErrUnsafePath, untrusted)
+	}
+
+	joined := filepath.Join(base, untrusted)
+	rel, err := filepath.Rel(filepath.Clean(base), joined)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q escapes %s", ErrUnsafePath, untrusted, base)
+	}
+	return joined, nil
 }
 
 // FormatBytes formats bytes into human readable format
@@ -1,9 +1,17 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/nshmdayo/distributed-cloud-storage/pkg/types"
 )
 
 func TestGenerateRandomID(t *testing.T) {
@@ -28,6 +36,25 @@ func TestGenerateRandomID(t *testing.T) {
 	}
 }
 
+func TestGenerateRandomIDLength(t *testing.T) {
+	for _, length := range []int{0, 1, 2, 15, 16, 31, 32, 63, 64} {
+		id, err := GenerateRandomID(length)
+		if err != nil {
+			t.Fatalf("Length %d: failed to generate ID: %v", length, err)
+		}
+		if len(id) != length {
+			t.Errorf("Length %d: expected exactly %d characters, got %d (%q)", length, length, len(id), id)
+		}
+		if _, err := hex.DecodeString(id + strings.Repeat("0", length%2)); err != nil {
+			t.Errorf("Length %d: expected hexadecimal ID, got %q", length, id)
+		}
+	}
+
+	if _, err := GenerateRandomID(-1); err == nil {
+		t.Errorf("Expected error for negative length")
+	}
+}
+
 func TestEnsureDir(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "test-ensure-dir")
 	defer os.RemoveAll(tmpDir)
@@ -75,6 +102,11 @@ func TestSplitData(t *testing.T) {
 		}
 	}
 
+	// Chunks are slices of data rather than copies
+	if &chunks[1][0] != &data[3] {
+		t.Errorf("Expected chunks to share the backing array of data")
+	}
+
 	// Test edge cases
 	singleChunk := SplitData(data, 20)
 	if len(singleChunk) != 1 {
@@ -87,6 +119,99 @@ func TestSplitData(t *testing.T) {
 	}
 }
 
+func TestSplitReader(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	tests := []struct {
+		name      string
+		size      int
+		chunkSize int
+		reader    func(io.Reader) io.Reader
+	}{
+		{"empty", 0, 64, nil},
+		{"smaller than chunk", 10, 64, nil},
+		{"exact multiple", 256, 64, nil},
+		{"with remainder", 1000, 64, nil},
+		{"one byte reads", 1000, 64, iotest.OneByteReader},
+		{"half reads", 1000, 64, iotest.HalfReader},
+		{"data with eof", 1000, 100, iotest.DataErrReader},
+		{"chunk size one", 10, 1, iotest.HalfReader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r io.Reader = bytes.NewReader(data[:tt.size])
+			if tt.reader != nil {
+				r = tt.reader(r)
+			}
+
+			var got []byte
+			var count int
+			err := SplitReader(r, tt.chunkSize, func(index int, chunk []byte) error {
+				if index != count {
+					t.Errorf("Expected index %d, got %d", count, index)
+				}
+				if len(chunk) > tt.chunkSize {
+					t.Errorf("Chunk %d: expected at most %d bytes, got %d", index, tt.chunkSize, len(chunk))
+				}
+				if len(chunk) < tt.chunkSize && len(got)+len(chunk) != tt.size {
+					t.Errorf("Chunk %d: short chunk of %d bytes before end of data", index, len(chunk))
+				}
+				got = append(got, chunk...)
+				count++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to split reader: %v", err)
+			}
+
+			expectedCount := (tt.size + tt.chunkSize - 1) / tt.chunkSize
+			if count != expectedCount {
+				t.Errorf("Expected %d chunks, got %d", expectedCount, count)
+			}
+			if !bytes.Equal(got, data[:tt.size]) {
+				t.Errorf("Reassembled data does not match input")
+			}
+		})
+	}
+}
+
+func TestSplitReaderErrors(t *testing.T) {
+	if err := SplitReader(bytes.NewReader([]byte("data")), 0, func(int, []byte) error { return nil }); err == nil {
+		t.Errorf("Expected error for zero chunk size")
+	}
+
+	readErr := errors.New("read failed")
+	r := io.MultiReader(bytes.NewReader([]byte("0123456789")), iotest.ErrReader(readErr))
+	var count int
+	err := SplitReader(r, 4, func(int, []byte) error {
+		count++
+		return nil
+	})
+	if !errors.Is(err, readErr) {
+		t.Errorf("Expected read error, got %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 chunks before the error, got %d", count)
+	}
+
+	stopErr := errors.New("stop")
+	count = 0
+	err = SplitReader(bytes.NewReader([]byte("0123456789")), 4, func(int, []byte) error {
+		count++
+		return stopErr
+	})
+	if err != stopErr {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected split to stop after 1 chunk, got %d", count)
+	}
+}
+
 func TestJoinChunks(t *testing.T) {
 	chunks := [][]byte{
 		[]byte("Hello"),
@@ -103,6 +228,44 @@ func TestJoinChunks(t *testing.T) {
 	}
 }
 
+func TestJoinChunksOrdered(t *testing.T) {
+	data := map[string][]byte{
+		"c0": []byte("Hello"),
+		"c1": []byte(" "),
+		"c2": []byte("World"),
+		"c3": []byte("!"),
+	}
+	chunk := func(id string, index int) types.ChunkInfo {
+		return types.ChunkInfo{ID: id, Index: index}
+	}
+
+	// Chunks are joined by index whatever order they arrive in
+	result, err := JoinChunksOrdered([]types.ChunkInfo{chunk("c2", 2), chunk("c0", 0), chunk("c3", 3), chunk("c1", 1)}, data)
+	if err != nil {
+		t.Fatalf("Failed to join chunks: %v", err)
+	}
+	if string(result) != "Hello World!" {
+		t.Errorf("Expected Hello World!, got %s", result)
+	}
+
+	tests := []struct {
+		name   string
+		chunks []types.ChunkInfo
+		want   string
+	}{
+		{"missing index", []types.ChunkInfo{chunk("c0", 0), chunk("c1", 1), chunk("c3", 3)}, "missing chunk index 2"},
+		{"duplicate index", []types.ChunkInfo{chunk("c0", 0), chunk("c1", 1), chunk("c2", 1)}, "duplicate chunk index 1"},
+		{"missing data", []types.ChunkInfo{chunk("c0", 0), chunk("c9", 1)}, "no data for chunk 1"},
+	}
+
+	for _, test := range tests {
+		_, err := JoinChunksOrdered(test.chunks, data)
+		if !errors.Is(err, ErrChunkSequence) || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: expected ErrChunkSequence reporting %q, got %v", test.name, test.want, err)
+		}
+	}
+}
+
 func TestGetStoragePath(t *testing.T) {
 	baseDir := "/storage"
 	fileID := "abcdef1234567890"
@@ -115,6 +278,71 @@ func TestGetStoragePath(t *testing.T) {
 	}
 }
 
+func TestGetStoragePathLayout(t *testing.T) {
+	baseDir := "/storage"
+	fileID := "abcdef1234567890"
+
+	tests := []struct {
+		depth    int
+		width    int
+		expected string
+	}{
+		{0, 2, filepath.Join(baseDir, fileID)},
+		{1, 2, filepath.Join(baseDir, "ab", fileID)},
+		{2, 2, filepath.Join(baseDir, "ab", "cd", fileID)},
+		{2, 3, filepath.Join(baseDir, "abc", "def", fileID)},
+		{1, 1, filepath.Join(baseDir, "a", fileID)},
+	}
+
+	for _, tt := range tests {
+		path := GetStoragePathLayout(baseDir, fileID, tt.depth, tt.width)
+		if path != tt.expected {
+			t.Errorf("Depth %d width %d: expected %s, got %s", tt.depth, tt.width, tt.expected, path)
+		}
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	base := filepath.Join("/storage", "chunks")
+
+	tests := []struct {
+		name      string
+		untrusted string
+		expected  string // Empty when the path must be rejected
+	}{
+		{"plain key", "abcdef", filepath.Join(base, "abcdef")},
+		{"nested", "ab/abcdef", filepath.Join(base, "ab", "abcdef")},
+		{"dot segments staying inside", "ab/../cd/./abcdef", filepath.Join(base, "cd", "abcdef")},
+		{"dotted name", "..abc", filepath.Join(base, "..abc")},
+		{"parent", "..", ""},
+		{"traversal", "../etc/passwd", ""},
+		{"nested traversal", "ab/../../etc/passwd", ""},
+		{"sibling with shared prefix", "../chunks-other/key", ""},
+		{"base itself", "ab/..", ""},
+		{"absolute", "/etc/passwd", ""},
+		{"null byte", "abc\x00def", ""},
+		{"null byte hiding traversal", "abc\x00/../../etc", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		path, err := SafeJoin(base, tt.untrusted)
+		if tt.expected == "" {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("%s: expected ErrUnsafePath for %q, got %q, %v", tt.name, tt.untrusted, path, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if path != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, path)
+		}
+	}
+}
+
 func TestFormatBytes(t *testing.T) {
 	tests := []struct {
 		bytes    int64